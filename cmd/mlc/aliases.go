@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/musicbrainz"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+var aliasesCmd = &cobra.Command{
+	Use:   "aliases",
+	Short: "Manage artist aliases",
+	Long: `Artist aliases map different spellings of an artist to one canonical name
+so their tracks are matched as duplicates, e.g. "AC/DC": ["ACDC", "AC DC"].
+
+Aliases come from the alias_map config key and the alias file (alias_file).
+They are checked before MusicBrainz, so they also correct MusicBrainz.`,
+}
+
+var aliasesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export artist names learned from MusicBrainz as aliases",
+	Long: `Export the artist names resolved by MusicBrainz lookups (--musicbrainz)
+into an alias file, so they keep working offline and can be corrected by hand.
+
+Writes to --output, or the alias_file config key; prints to stdout if neither
+is set. Aliases already in the file are kept and win over learned names.`,
+	RunE: runAliasesExport,
+}
+
+func init() {
+	rootCmd.AddCommand(aliasesCmd)
+	aliasesCmd.AddCommand(aliasesExportCmd)
+
+	rootCmd.PersistentFlags().String("alias-file", "", "artist alias file (YAML map of canonical name to aliases)")
+	viper.BindPFlag("alias_file", rootCmd.PersistentFlags().Lookup("alias-file"))
+
+	aliasesExportCmd.Flags().StringP("output", "o", "", "Alias file to write (default: alias_file config, or stdout)")
+}
+
+// loadAliasMap builds the alias map from the alias_map config key and the
+// alias file
+func loadAliasMap() (*meta.AliasMap, error) {
+	entries := viper.GetStringMapStringSlice("alias_map")
+	if path := viper.GetString("alias_file"); path != "" {
+		fileEntries, err := meta.LoadAliasFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, aliases := range fileEntries {
+			entries[name] = append(entries[name], aliases...)
+		}
+	}
+
+	aliases, err := meta.NewAliasMap(entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artist aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+func runAliasesExport(cmd *cobra.Command, args []string) error {
+	util.SetVerbose(viper.GetBool("verbose"))
+	util.SetQuiet(viper.GetBool("quiet"))
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		output = viper.GetString("alias_file")
+	}
+
+	db, err := openReadOnlyDB(viper.GetString("db"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	mbCache := musicbrainz.NewCache(db.DB(), nil)
+	learned, err := mbCache.Mappings()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string][]string)
+	if output != "" {
+		if existing, err := meta.LoadAliasFile(output); err == nil {
+			entries = existing
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	added := meta.MergeAliases(entries, learned)
+
+	if output == "" {
+		data, err := yaml.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("failed to encode aliases: %w", err)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	if err := meta.WriteAliasFile(output, entries); err != nil {
+		return err
+	}
+	util.SuccessLog("Exported %d aliases to %s (%d artists)", added, output, len(entries))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/classify"
+	"github.com/franz/music-janitor/internal/plan"
+	"github.com/spf13/viper"
+)
+
+// classConfig is an entry of the "classes" config map; unset fields keep the
+// class default
+type classConfig struct {
+	Layout  string   `mapstructure:"layout"`
+	Dedup   *bool    `mapstructure:"dedup"`
+	Exclude bool     `mapstructure:"exclude"`
+	Genres  []string `mapstructure:"genres"`
+	Folders []string `mapstructure:"folders"`
+}
+
+// loadClassifier builds the content classifier from the "classes" config, or
+// returns nil when classification is disabled
+func loadClassifier() (*classify.Classifier, error) {
+	if !viper.GetBool("classify") {
+		return nil, nil
+	}
+
+	var configured map[string]classConfig
+	if err := viper.UnmarshalKey("classes", &configured); err != nil {
+		return nil, fmt.Errorf("failed to parse classes config: %w", err)
+	}
+
+	rules := make(map[string]classify.Rule, len(configured))
+	for class, cc := range configured {
+		if !classify.Valid(class) {
+			return nil, fmt.Errorf("unknown class in classes config: %s (must be one of: %s)", class, strings.Join(classify.Classes, ", "))
+		}
+		rule := classify.DefaultRules[class]
+		if cc.Layout != "" {
+			if err := plan.ValidateLayout(cc.Layout); err != nil {
+				return nil, fmt.Errorf("invalid layout for class %s: %w", class, err)
+			}
+			rule.Layout = cc.Layout
+		}
+		if cc.Dedup != nil {
+			rule.Dedup = *cc.Dedup
+		}
+		rule.Exclude = cc.Exclude
+		rule.Genres = cc.Genres
+		rule.Folders = cc.Folders
+		rules[class] = rule
+	}
+
+	return classify.New(&classify.Config{
+		Rules:                rules,
+		MixMinDuration:       viper.GetDuration("mix_min_duration"),
+		AudiobookMinChapters: viper.GetInt("audiobook_min_chapters"),
+	})
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Manually correct duplicate clusters",
+	Long: `Record manual overrides for duplicate clusters.
+
+Overrides are stored in the database, survive --force-recluster, and are
+applied after every automatic clustering pass:
+
+  merge    Merge all files of one cluster into another
+  split    Move files out of a cluster into a cluster of their own
+  pin      Force a file to be the winner of its cluster
+  exclude  Keep a file out of clustering and planning entirely
+
+Files can be given by ID or by source path. Use 'mlc show --verbose' to see
+file IDs and cluster keys. Run 'mlc plan' afterwards to rescore and replan
+the affected clusters.`,
+}
+
+var clusterMergeCmd = &cobra.Command{
+	Use:   "merge <target-cluster-key> <other-cluster-key>",
+	Short: "Merge the files of one cluster into another",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runClusterMerge,
+}
+
+var clusterSplitCmd = &cobra.Command{
+	Use:   "split <cluster-key> <file>...",
+	Short: "Move files out of a cluster into a new cluster",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runClusterSplit,
+}
+
+var clusterPinCmd = &cobra.Command{
+	Use:   "pin <file>",
+	Short: "Force a file to win its cluster",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runClusterPin,
+}
+
+var clusterExcludeCmd = &cobra.Command{
+	Use:   "exclude <file>",
+	Short: "Exclude a file from clustering and planning",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runClusterExclude,
+}
+
+var clusterOverridesCmd = &cobra.Command{
+	Use:   "overrides",
+	Short: "List manual cluster overrides",
+	Args:  cobra.NoArgs,
+	RunE:  runClusterOverrides,
+}
+
+var clusterUnsetCmd = &cobra.Command{
+	Use:   "unset <override-id>",
+	Short: "Remove a manual cluster override",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runClusterUnset,
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.AddCommand(clusterMergeCmd, clusterSplitCmd, clusterPinCmd, clusterExcludeCmd,
+		clusterOverridesCmd, clusterUnsetCmd)
+
+	for _, cmd := range []*cobra.Command{clusterMergeCmd, clusterSplitCmd, clusterPinCmd, clusterExcludeCmd} {
+		cmd.Flags().String("note", "", "Optional note explaining the override")
+	}
+}
+
+// openClusterDB opens the database for cluster override commands
+func openClusterDB() (*store.Store, error) {
+	dbPath := viper.GetString("db")
+	util.SetVerbose(viper.GetBool("verbose"))
+	util.SetQuiet(viper.GetBool("quiet"))
+
+	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
+		Lock:      "cluster",
+		BreakLock: viper.GetBool("break_lock"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// resolveFile looks up a file by ID or source path
+func resolveFile(db *store.Store, arg string) (*store.File, error) {
+	var file *store.File
+	var err error
+	if id, parseErr := strconv.ParseInt(arg, 10, 64); parseErr == nil {
+		file, err = db.GetFileByID(id)
+	} else {
+		file, err = db.GetFileBySrcPath(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("file not found: %s", arg)
+	}
+	return file, nil
+}
+
+// applyClusterOverrides applies stored overrides to the current clusters
+func applyClusterOverrides(db *store.Store) error {
+	clusterer := cluster.New(&cluster.Config{Store: db})
+	if _, err := clusterer.ApplyOverrides(context.Background()); err != nil {
+		return fmt.Errorf("failed to apply overrides: %w", err)
+	}
+	util.InfoLog("Run 'mlc plan' to rescore and replan affected clusters")
+	return nil
+}
+
+func runClusterMerge(cmd *cobra.Command, args []string) error {
+	db, err := openClusterDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	targetKey, otherKey := args[0], args[1]
+	if targetKey == otherKey {
+		return fmt.Errorf("cannot merge a cluster into itself")
+	}
+
+	// Anchor the merge on a member of each cluster, so it still finds them
+	// when their keys change
+	var anchors []int64
+	for _, key := range []string{targetKey, otherKey} {
+		members, err := db.GetClusterMembers(key)
+		if err != nil {
+			return fmt.Errorf("failed to get cluster members: %w", err)
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("cluster not found: %s", key)
+		}
+		anchor := members[0].FileID
+		for _, m := range members[1:] {
+			if m.FileID < anchor {
+				anchor = m.FileID
+			}
+		}
+		anchors = append(anchors, anchor)
+	}
+
+	note, _ := cmd.Flags().GetString("note")
+	override := &store.ClusterOverride{
+		Kind:        store.OverrideMerge,
+		ClusterKey:  targetKey,
+		OtherKey:    otherKey,
+		FileID:      anchors[0],
+		OtherFileID: anchors[1],
+		Note:        note,
+	}
+	if err := db.InsertClusterOverride(override); err != nil {
+		return err
+	}
+
+	util.SuccessLog("Override #%d: merge %s into %s", override.ID, otherKey, targetKey)
+	return applyClusterOverrides(db)
+}
+
+func runClusterSplit(cmd *cobra.Command, args []string) error {
+	db, err := openClusterDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	clusterKey := args[0]
+	members, err := db.GetClusterMembers(clusterKey)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster members: %w", err)
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("cluster not found: %s", clusterKey)
+	}
+
+	isMember := make(map[int64]bool, len(members))
+	for _, m := range members {
+		isMember[m.FileID] = true
+	}
+
+	var files []*store.File
+	for _, arg := range args[1:] {
+		file, err := resolveFile(db, arg)
+		if err != nil {
+			return err
+		}
+		if !isMember[file.ID] {
+			return fmt.Errorf("file %d is not a member of cluster %s", file.ID, clusterKey)
+		}
+		files = append(files, file)
+	}
+	if len(files) == len(members) {
+		return fmt.Errorf("cannot split all files out of a cluster")
+	}
+
+	note, _ := cmd.Flags().GetString("note")
+	newKey := cluster.SplitClusterKey(clusterKey, files[0].ID)
+	for _, file := range files {
+		override := &store.ClusterOverride{
+			Kind:       store.OverrideSplit,
+			ClusterKey: clusterKey,
+			OtherKey:   newKey,
+			FileID:     file.ID,
+			Note:       note,
+		}
+		if err := db.InsertClusterOverride(override); err != nil {
+			return err
+		}
+		util.SuccessLog("Override #%d: split %s out of %s", override.ID, file.SrcPath, clusterKey)
+	}
+
+	return applyClusterOverrides(db)
+}
+
+func runClusterPin(cmd *cobra.Command, args []string) error {
+	db, err := openClusterDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file, err := resolveFile(db, args[0])
+	if err != nil {
+		return err
+	}
+
+	keys, err := db.GetFileClusterKeys()
+	if err != nil {
+		return err
+	}
+	clusterKey, ok := keys[file.ID]
+	if !ok {
+		return fmt.Errorf("file %d is not in any cluster (run 'mlc plan' first)", file.ID)
+	}
+
+	// Only one pinned winner per cluster: drop pins on the other members
+	members, err := db.GetClusterMembers(clusterKey)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster members: %w", err)
+	}
+	for _, m := range members {
+		if err := db.DeleteFileOverrides(store.OverridePin, m.FileID); err != nil {
+			return err
+		}
+	}
+
+	note, _ := cmd.Flags().GetString("note")
+	override := &store.ClusterOverride{
+		Kind:       store.OverridePin,
+		ClusterKey: clusterKey,
+		FileID:     file.ID,
+		Note:       note,
+	}
+	if err := db.InsertClusterOverride(override); err != nil {
+		return err
+	}
+
+	if err := db.MarkClustersDirty([]string{clusterKey}); err != nil {
+		return err
+	}
+
+	util.SuccessLog("Override #%d: pin %s as winner of %s", override.ID, file.SrcPath, clusterKey)
+	util.InfoLog("Run 'mlc plan' to rescore and replan affected clusters")
+	return nil
+}
+
+func runClusterExclude(cmd *cobra.Command, args []string) error {
+	db, err := openClusterDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file, err := resolveFile(db, args[0])
+	if err != nil {
+		return err
+	}
+
+	keys, err := db.GetFileClusterKeys()
+	if err != nil {
+		return err
+	}
+
+	note, _ := cmd.Flags().GetString("note")
+	override := &store.ClusterOverride{
+		Kind:       store.OverrideExclude,
+		ClusterKey: keys[file.ID],
+		FileID:     file.ID,
+		Note:       note,
+	}
+	if err := db.InsertClusterOverride(override); err != nil {
+		return err
+	}
+
+	util.SuccessLog("Override #%d: exclude %s", override.ID, file.SrcPath)
+	return applyClusterOverrides(db)
+}
+
+func runClusterOverrides(cmd *cobra.Command, args []string) error {
+	util.SetVerbose(viper.GetBool("verbose"))
+	util.SetQuiet(viper.GetBool("quiet"))
+
+	db, err := openReadOnlyDB(viper.GetString("db"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	overrides, err := db.GetClusterOverrides()
+	if err != nil {
+		return err
+	}
+
+	if len(overrides) == 0 {
+		util.InfoLog("No manual cluster overrides")
+		return nil
+	}
+
+	keys, err := db.GetFileClusterKeys()
+	if err != nil {
+		return err
+	}
+
+	util.InfoLog("=== Manual Cluster Overrides ===")
+	for _, o := range overrides {
+		switch o.Kind {
+		case store.OverrideMerge:
+			fmt.Printf("#%d  merge    %s <- %s\n", o.ID, o.ClusterKey, o.OtherKey)
+		case store.OverrideSplit:
+			fmt.Printf("#%d  split    file %d: %s -> %s\n", o.ID, o.FileID, o.ClusterKey, o.OtherKey)
+		default:
+			fmt.Printf("#%d  %-8s file %d (%s)\n", o.ID, o.Kind, o.FileID, o.ClusterKey)
+		}
+		if !cluster.OverrideMatches(o, keys) {
+			fmt.Printf("     No longer matches any clustered file (remove with 'mlc cluster unset %d')\n", o.ID)
+		}
+		if o.Note != "" {
+			fmt.Printf("     Note: %s\n", o.Note)
+		}
+	}
+
+	return nil
+}
+
+func runClusterUnset(cmd *cobra.Command, args []string) error {
+	db, err := openClusterDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid override ID: %s", args[0])
+	}
+
+	override, err := db.GetClusterOverride(id)
+	if err != nil {
+		return err
+	}
+	if override == nil {
+		return fmt.Errorf("override not found: #%d", id)
+	}
+
+	if err := db.DeleteClusterOverride(id); err != nil {
+		return err
+	}
+
+	// Re-key the affected files on the next incremental clustering run
+	var affected []int64
+	switch override.Kind {
+	case store.OverrideMerge:
+		members, err := db.GetClusterMembers(override.ClusterKey)
+		if err != nil {
+			return fmt.Errorf("failed to get cluster members: %w", err)
+		}
+		for _, m := range members {
+			affected = append(affected, m.FileID)
+		}
+	case store.OverrideSplit, store.OverrideExclude:
+		affected = append(affected, override.FileID)
+	case store.OverridePin:
+		keys, err := db.GetFileClusterKeys()
+		if err != nil {
+			return err
+		}
+		if key, ok := keys[override.FileID]; ok {
+			if err := db.MarkClustersDirty([]string{key}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.ForgetClusteredFiles(affected); err != nil {
+		return err
+	}
+
+	util.SuccessLog("Removed override #%d (%s)", id, override.Kind)
+	util.InfoLog("Run 'mlc plan' to recluster, rescore and replan affected clusters")
+	return nil
+}
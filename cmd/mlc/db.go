@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// openReadOnlyDB opens the state database for a command that only reads it,
+// without taking the writer lock, and warns when a mutating command holds it
+func openReadOnlyDB(dbPath string) (*store.Store, error) {
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("database not found: %s (run 'mlc scan' first)", dbPath)
+	}
+
+	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	holder, err := db.WriterLock()
+	if err != nil {
+		util.DebugLog("Failed to read writer lock: %v", err)
+	} else if holder != nil {
+		if holder.Stale() {
+			util.WarnLog("Database has a stale lock from %s; the next mutating command needs --break-lock", holder)
+		} else {
+			util.WarnLog("Database is in use by %s; results may change", holder)
+		}
+	}
+	return db, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -257,8 +258,15 @@ func checkDatabase(dbPath string) checkResult {
 		}
 	}
 
-	// Try to open it
-	db, err := store.Open(dbPath)
+	// Try to open it, read-only so a running command is not disturbed
+	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{ReadOnly: true})
+	if errors.Is(err, store.ErrOutdatedSchema) {
+		return checkResult{
+			name:    "Database",
+			warning: true,
+			message: err.Error(),
+		}
+	}
 	if err != nil {
 		return checkResult{
 			name:    "Database",
@@ -268,6 +276,23 @@ func checkDatabase(dbPath string) checkResult {
 	}
 	defer db.Close()
 
+	// Check the writer lock
+	holder, err := store.ReadLock(store.LockPath(dbPath))
+	if err != nil {
+		return checkResult{
+			name:    "Database",
+			warning: true,
+			message: fmt.Sprintf("%v (remove it with --break-lock if no mlc process is running)", err),
+		}
+	}
+	if holder != nil && holder.Stale() {
+		return checkResult{
+			name:    "Database",
+			warning: true,
+			message: fmt.Sprintf("stale lock from %s; rerun the next command with --break-lock", holder),
+		}
+	}
+
 	// Check integrity
 	if err := db.CheckIntegrity(); err != nil {
 		return checkResult{
@@ -281,9 +306,13 @@ func checkDatabase(dbPath string) checkResult {
 	fileCount, _ := db.CountFilesByStatus("meta_ok")
 	size := util.FormatBytes(info.Size())
 
+	message := fmt.Sprintf("%s (%s, %d files)", dbPath, size, fileCount)
+	if holder != nil {
+		message += fmt.Sprintf(", in use by %s", holder)
+	}
 	return checkResult{
 		name:    "Database",
-		message: fmt.Sprintf("%s (%s, %d files)", dbPath, size, fileCount),
+		message: message,
 	}
 }
 
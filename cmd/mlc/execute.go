@@ -38,6 +38,17 @@ Use --dry-run with 'mlc plan' to preview before executing.`,
 
 func init() {
 	rootCmd.AddCommand(executeCmd)
+
+	executeCmd.Flags().Bool("artwork", false, "Write cover.jpg into each album folder from embedded artwork")
+	executeCmd.Flags().Bool("embed-artwork", false, "Embed the album cover in every copied/moved track (FLAC, MP3, M4A)")
+	executeCmd.Flags().Int("artwork-max-size", execute.DefaultArtworkMaxSize, "Longest side in pixels of embedded covers (larger covers are resized)")
+
+	viper.BindPFlag("artwork", executeCmd.Flags().Lookup("artwork"))
+	viper.BindPFlag("artwork_embed", executeCmd.Flags().Lookup("embed-artwork"))
+	viper.BindPFlag("artwork_max_size", executeCmd.Flags().Lookup("artwork-max-size"))
+
+	executeCmd.Flags().Bool("write-inferred-tags", false, "Also write low-confidence values guessed from file and folder names as tags")
+	viper.BindPFlag("write_inferred_tags", executeCmd.Flags().Lookup("write-inferred-tags"))
 }
 
 func runExecute(cmd *cobra.Command, args []string) error {
@@ -62,6 +73,13 @@ func runExecute(cmd *cobra.Command, args []string) error {
 		writeTags = true // Default to true - write enriched metadata tags
 	}
 
+	artwork := viper.GetBool("artwork")
+	embedArtwork := viper.GetBool("artwork_embed")
+	artworkMaxSize := viper.GetInt("artwork_max_size")
+	if artworkMaxSize < 0 {
+		return fmt.Errorf("invalid artwork max size: %d (must be positive)", artworkMaxSize)
+	}
+
 	// Set log level
 	util.SetVerbose(verbose)
 	util.SetQuiet(quiet)
@@ -79,6 +97,8 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	// For now, use basic optimization if DB is on network
 	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
 		NetworkOptimized: dbNetworkOptimized,
+		Lock:             "execute",
+		BreakLock:        viper.GetBool("break_lock"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -148,6 +168,9 @@ func runExecute(cmd *cobra.Command, args []string) error {
 	util.InfoLog("Concurrency: %d workers", concurrency)
 	util.InfoLog("Verification: %s", verifyMode)
 	util.InfoLog("Write tags: %v", writeTags)
+	if artwork || embedArtwork {
+		util.InfoLog("Artwork: cover.jpg %v, embed %v (max %dpx)", artwork, embedArtwork, artworkMaxSize)
+	}
 	if bufferSize > 0 {
 		util.InfoLog("Buffer size: %d KB (NAS-optimized)", bufferSize/1024)
 	}
@@ -161,6 +184,12 @@ func runExecute(cmd *cobra.Command, args []string) error {
 		BufferSize:  bufferSize,
 		RetryConfig: retryConfig,
 		Logger:      logger,
+
+		Artwork:        artwork,
+		EmbedArtwork:   embedArtwork,
+		ArtworkMaxSize: artworkMaxSize,
+
+		WriteInferredTags: viper.GetBool("write_inferred_tags"),
 	})
 
 	startTime := time.Now()
@@ -183,6 +212,9 @@ func runExecute(cmd *cobra.Command, args []string) error {
 		util.WarnLog("  Failed: %d", result.Failed)
 	}
 	util.InfoLog("Bytes written: %s", util.FormatBytes(result.BytesWritten))
+	if result.CoversWritten > 0 || result.CoversEmbedded > 0 {
+		util.InfoLog("Covers written: %d, embedded: %d", result.CoversWritten, result.CoversEmbedded)
+	}
 
 	if result.Failed > 0 && len(result.Errors) > 0 {
 		util.InfoLog("")
@@ -33,12 +33,14 @@ func init() {
 	rootCmd.PersistentFlags().StringP("source", "s", "", "source directory to scan")
 	rootCmd.PersistentFlags().StringP("dest", "d", "", "destination directory for clean library")
 	rootCmd.PersistentFlags().String("db", "mlc-state.db", "state database file")
+	rootCmd.PersistentFlags().Bool("break-lock", false, "remove a database lock left by an mlc process that is no longer running")
 
 	// Global flags - Output control
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "quiet output (errors only)")
 	rootCmd.PersistentFlags().Bool("dry-run", false, "plan without executing (dry-run mode)")
 	rootCmd.PersistentFlags().Bool("no-auto-healing", false, "disable automatic self-healing (warnings only)")
+	rootCmd.PersistentFlags().String("rules", "", "metadata cleaning rules file (default: built-in rules)")
 
 	// Global flags - Execution options
 	rootCmd.PersistentFlags().String("mode", "", "execution mode: copy, move, hardlink, symlink (default: copy)")
@@ -55,6 +57,7 @@ func init() {
 	// Global flags - MusicBrainz integration
 	rootCmd.PersistentFlags().Bool("musicbrainz", false, "enable MusicBrainz artist name normalization (requires internet)")
 	rootCmd.PersistentFlags().Bool("musicbrainz-preload", false, "preload all artists from MusicBrainz before clustering (slower but more accurate)")
+	rootCmd.PersistentFlags().Bool("musicbrainz-offline", false, "use cached MusicBrainz artist names only, without network lookups")
 
 	// Global flags - Duplicate handling
 	rootCmd.PersistentFlags().String("duplicates", "", "duplicate policy: keep, quarantine, delete (default: keep)")
@@ -64,10 +67,12 @@ func init() {
 	viper.BindPFlag("source", rootCmd.PersistentFlags().Lookup("source"))
 	viper.BindPFlag("destination", rootCmd.PersistentFlags().Lookup("dest"))
 	viper.BindPFlag("db", rootCmd.PersistentFlags().Lookup("db"))
+	viper.BindPFlag("break_lock", rootCmd.PersistentFlags().Lookup("break-lock"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
 	viper.BindPFlag("dry_run", rootCmd.PersistentFlags().Lookup("dry-run"))
 	viper.BindPFlag("no-auto-healing", rootCmd.PersistentFlags().Lookup("no-auto-healing"))
+	viper.BindPFlag("rules", rootCmd.PersistentFlags().Lookup("rules"))
 	viper.BindPFlag("mode", rootCmd.PersistentFlags().Lookup("mode"))
 	viper.BindPFlag("concurrency", rootCmd.PersistentFlags().Lookup("concurrency"))
 	viper.BindPFlag("layout", rootCmd.PersistentFlags().Lookup("layout"))
@@ -80,6 +85,7 @@ func init() {
 	viper.BindPFlag("prefer_existing", rootCmd.PersistentFlags().Lookup("prefer-existing"))
 	viper.BindPFlag("musicbrainz", rootCmd.PersistentFlags().Lookup("musicbrainz"))
 	viper.BindPFlag("musicbrainz_preload", rootCmd.PersistentFlags().Lookup("musicbrainz-preload"))
+	viper.BindPFlag("musicbrainz_offline", rootCmd.PersistentFlags().Lookup("musicbrainz-offline"))
 }
 
 func initConfig() {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 	"github.com/spf13/cobra"
@@ -17,7 +18,8 @@ var metadataCmd = &cobra.Command{
 	Short: "Query and display metadata for files",
 	Long: `Query and display metadata stored in the database for audio files.
 
-Supports flexible filtering by artist, album, title, format, and more.
+Supports flexible filtering by artist, album, title, format, genre, composer,
+label, catalog number, ISRC, BPM, and more.
 Can output in human-readable format, JSONL, or CSV.
 
 Examples:
@@ -35,6 +37,15 @@ Examples:
 
   # Show files from specific path
   mlc metadata --path "*Ärzte*" --limit 20
+
+  # Show classical recordings by composer and conductor
+  mlc metadata --composer "Beethoven" --conductor "Karajan" --sort composer
+
+  # Export tracks between 120 and 130 BPM as JSONL
+  mlc metadata --bpm-min 120 --bpm-max 130 --output jsonl
+
+  # Show where each field came from (tag, filename, folder, rule, ...)
+  mlc metadata --empty-title --provenance
 `,
 	RunE: runMetadata,
 }
@@ -55,11 +66,21 @@ func init() {
 	metadataCmd.Flags().Bool("empty-album", false, "Show files with missing album tag")
 	metadataCmd.Flags().Bool("empty-title", false, "Show files with missing title tag")
 	metadataCmd.Flags().Bool("errors", false, "Show files with metadata extraction errors")
+	metadataCmd.Flags().StringP("genre", "g", "", "Filter by genre (supports partial match)")
+	metadataCmd.Flags().String("composer", "", "Filter by composer (supports partial match)")
+	metadataCmd.Flags().String("conductor", "", "Filter by conductor (supports partial match)")
+	metadataCmd.Flags().String("label", "", "Filter by record label (supports partial match)")
+	metadataCmd.Flags().String("catalog", "", "Filter by catalog number")
+	metadataCmd.Flags().String("isrc", "", "Filter by ISRC")
+	metadataCmd.Flags().Int("bpm-min", 0, "Show only files with at least this BPM")
+	metadataCmd.Flags().Int("bpm-max", 0, "Show only files with at most this BPM")
+	metadataCmd.Flags().Bool("empty-genre", false, "Show files with missing genre tag")
 
 	// Output flags
 	metadataCmd.Flags().StringP("output", "o", "human", "Output format: human, jsonl, csv")
 	metadataCmd.Flags().IntP("limit", "l", 0, "Limit number of results (0 = no limit)")
-	metadataCmd.Flags().String("sort", "path", "Sort by: artist, album, title, path, duration, bitrate")
+	metadataCmd.Flags().String("sort", "path", "Sort by: artist, album, title, path, duration, bitrate, genre, composer, bpm")
+	metadataCmd.Flags().Bool("provenance", false, "Show where each field value came from and its confidence (human and jsonl output)")
 }
 
 func runMetadata(cmd *cobra.Command, args []string) error {
@@ -70,9 +91,9 @@ func runMetadata(cmd *cobra.Command, args []string) error {
 	}
 
 	// Open database
-	db, err := store.Open(dbPath)
+	db, err := openReadOnlyDB(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -93,6 +114,21 @@ func runMetadata(cmd *cobra.Command, args []string) error {
 	opts.ShowErrors, _ = cmd.Flags().GetBool("errors")
 	opts.Limit, _ = cmd.Flags().GetInt("limit")
 	opts.SortBy, _ = cmd.Flags().GetString("sort")
+	opts.Genre, _ = cmd.Flags().GetString("genre")
+	opts.Composer, _ = cmd.Flags().GetString("composer")
+	opts.Conductor, _ = cmd.Flags().GetString("conductor")
+	opts.Label, _ = cmd.Flags().GetString("label")
+	opts.CatalogNumber, _ = cmd.Flags().GetString("catalog")
+	opts.BPMMin, _ = cmd.Flags().GetInt("bpm-min")
+	opts.BPMMax, _ = cmd.Flags().GetInt("bpm-max")
+	opts.EmptyGenre, _ = cmd.Flags().GetBool("empty-genre")
+
+	if isrc, _ := cmd.Flags().GetString("isrc"); isrc != "" {
+		opts.ISRC = meta.NormalizeISRC(isrc)
+		if opts.ISRC == "" {
+			return fmt.Errorf("invalid ISRC: %s", isrc)
+		}
+	}
 
 	// Query database
 	results, err := db.QueryFilesWithMetadata(opts)
@@ -100,6 +136,19 @@ func runMetadata(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to query metadata: %w", err)
 	}
 
+	if showProvenance, _ := cmd.Flags().GetBool("provenance"); showProvenance {
+		for _, result := range results {
+			provenance, err := db.GetProvenance(result.File.ID)
+			if err != nil {
+				return err
+			}
+			if provenance == nil {
+				provenance = []store.FieldProvenance{} // Loaded, but nothing recorded
+			}
+			result.Metadata.Provenance = provenance
+		}
+	}
+
 	// Output results
 	outputFormat, _ := cmd.Flags().GetString("output")
 
@@ -127,6 +176,12 @@ func outputHuman(results []*store.FileWithMetadata, opts *store.MetadataQueryOpt
 	if opts.Album != "" {
 		fmt.Printf("Album: %s\n", opts.Album)
 	}
+	if opts.Genre != "" {
+		fmt.Printf("Genre: %s\n", opts.Genre)
+	}
+	if opts.Composer != "" {
+		fmt.Printf("Composer: %s\n", opts.Composer)
+	}
 	if opts.EmptyTitle {
 		fmt.Printf("Filter: Empty titles only\n")
 	}
@@ -140,6 +195,15 @@ func outputHuman(results []*store.FileWithMetadata, opts *store.MetadataQueryOpt
 
 		fmt.Printf("[%d] Path: %s\n", i+1, f.SrcPath)
 		fmt.Printf("    Artist:   %s\n", formatStringOrEmpty(m.TagArtist))
+		if credits := meta.CreditsOf(m); len(credits.Collaborators) > 0 || len(credits.Featured) > 0 {
+			fmt.Printf("    Primary:  %s\n", credits.Primary)
+			if len(credits.Collaborators) > 0 {
+				fmt.Printf("    With:     %s\n", strings.Join(credits.Collaborators, ", "))
+			}
+			if len(credits.Featured) > 0 {
+				fmt.Printf("    Featured: %s\n", strings.Join(credits.Featured, ", "))
+			}
+		}
 		fmt.Printf("    Album:    %s\n", formatStringOrEmpty(m.TagAlbum))
 		fmt.Printf("    Title:    %s\n", formatStringOrEmpty(m.TagTitle))
 
@@ -166,13 +230,43 @@ func outputHuman(results []*store.FileWithMetadata, opts *store.MetadataQueryOpt
 		}
 		fmt.Printf("    Format:   %s (%s) @ %dkbps\n", strings.ToUpper(m.Format), m.Codec, m.BitrateKbps)
 		fmt.Printf("    Lossless: %s\n", losslessStr)
+		if encoder := meta.ParseEncoderJSON(m.EncoderJSON); encoder != nil {
+			fmt.Printf("    Encoder:  %s\n", encoder)
+		}
+		switch m.IntegrityStatus {
+		case store.IntegrityOK:
+			fmt.Printf("    Integrity: ok\n")
+		case store.IntegrityDamaged:
+			fmt.Printf("    Integrity: DAMAGED (%s)\n", m.IntegrityError)
+		}
 
 		if m.DurationMs > 0 {
 			fmt.Printf("    Duration: %s\n", formatDuration(m.DurationMs))
 		}
 
+		// Extended tags (only when present)
+		printIfSet := func(label, value string) {
+			if value != "" {
+				fmt.Printf("    %-9s %s\n", label+":", value)
+			}
+		}
+		printIfSet("Genre", m.TagGenre)
+		printIfSet("Composer", m.TagComposer)
+		printIfSet("Conductor", m.TagConductor)
+		printIfSet("Label", m.TagLabel)
+		printIfSet("Catalog", m.TagCatalogNumber)
+		printIfSet("ISRC", m.ISRC)
+		if m.TagBPM > 0 {
+			fmt.Printf("    BPM:      %d\n", m.TagBPM)
+		}
+		printIfSet("Original", m.TagOriginalDate)
+
 		fmt.Printf("    Size:     %s\n", formatBytes(f.SizeBytes))
 
+		if m.Provenance != nil {
+			printProvenance(m.Provenance)
+		}
+
 		fmt.Println()
 
 		totalSize += f.SizeBytes
@@ -190,6 +284,7 @@ func outputJSONL(results []*store.FileWithMetadata) error {
 	encoder := json.NewEncoder(os.Stdout)
 
 	for _, result := range results {
+		credits := meta.CreditsOf(result.Metadata)
 		obj := map[string]interface{}{
 			"file_id":      result.File.ID,
 			"path":         result.File.SrcPath,
@@ -204,6 +299,36 @@ func outputJSONL(results []*store.FileWithMetadata) error {
 			"duration_ms":  result.Metadata.DurationMs,
 			"bitrate_kbps": result.Metadata.BitrateKbps,
 			"lossless":     result.Metadata.Lossless,
+			"encoder":      meta.ParseEncoderJSON(result.Metadata.EncoderJSON),
+			"integrity":    result.Metadata.IntegrityStatus,
+
+			"genre":          result.Metadata.TagGenre,
+			"composer":       result.Metadata.TagComposer,
+			"conductor":      result.Metadata.TagConductor,
+			"label":          result.Metadata.TagLabel,
+			"catalog_number": result.Metadata.TagCatalogNumber,
+			"isrc":           result.Metadata.ISRC,
+			"bpm":            result.Metadata.TagBPM,
+			"original_date":  result.Metadata.TagOriginalDate,
+			"artist_sort":    result.Metadata.TagArtistSort,
+			"album_sort":     result.Metadata.TagAlbumSort,
+			"comment":        result.Metadata.TagComment,
+
+			"primary_artist":   credits.Primary,
+			"collaborators":    credits.Collaborators,
+			"featured_artists": credits.Featured,
+		}
+		if result.Metadata.Provenance != nil {
+			provenance := make(map[string]interface{}, len(result.Metadata.Provenance))
+			for _, p := range result.Metadata.Provenance {
+				provenance[p.Field] = map[string]interface{}{
+					"source":     p.Source,
+					"confidence": p.Confidence,
+					"value":      p.Value,
+					"detail":     p.Detail,
+				}
+			}
+			obj["provenance"] = provenance
 		}
 
 		if err := encoder.Encode(obj); err != nil {
@@ -223,6 +348,8 @@ func outputCSV(results []*store.FileWithMetadata) error {
 		"path", "artist", "album", "title", "track", "disc",
 		"format", "codec", "bitrate_kbps", "duration_ms",
 		"lossless", "size_bytes",
+		"genre", "composer", "conductor", "label", "catalog_number", "isrc",
+		"bpm", "original_date", "artist_sort", "album_sort", "comment",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
@@ -251,6 +378,17 @@ func outputCSV(results []*store.FileWithMetadata) error {
 			fmt.Sprintf("%d", m.DurationMs),
 			losslessStr,
 			fmt.Sprintf("%d", f.SizeBytes),
+			m.TagGenre,
+			m.TagComposer,
+			m.TagConductor,
+			m.TagLabel,
+			m.TagCatalogNumber,
+			m.ISRC,
+			fmt.Sprintf("%d", m.TagBPM),
+			m.TagOriginalDate,
+			m.TagArtistSort,
+			m.TagAlbumSort,
+			m.TagComment,
 		}
 
 		if err := writer.Write(row); err != nil {
@@ -261,6 +399,26 @@ func outputCSV(results []*store.FileWithMetadata) error {
 	return nil
 }
 
+// printProvenance lists the source and confidence of each field; inferred
+// values below the tag write-back threshold are marked
+func printProvenance(provenance []store.FieldProvenance) {
+	if len(provenance) == 0 {
+		fmt.Printf("    Provenance: (none recorded, rescan to record)\n")
+		return
+	}
+	fmt.Printf("    Provenance:\n")
+	for _, p := range provenance {
+		line := fmt.Sprintf("      %-13s %-11s %.2f", p.Field, p.Source, p.Confidence)
+		if p.Detail != "" {
+			line += fmt.Sprintf("  (%s)", p.Detail)
+		}
+		if p.Inferred() && p.Confidence < meta.DefaultMinWriteConfidence {
+			line += "  [low confidence]"
+		}
+		fmt.Println(line)
+	}
+}
+
 func formatStringOrEmpty(s string) string {
 	if s == "" {
 		return "(empty)"
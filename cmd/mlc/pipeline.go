@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/franz/music-janitor/internal/classify"
+	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/integrity"
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/plan"
+	"github.com/franz/music-janitor/internal/report"
+	"github.com/franz/music-janitor/internal/score"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/spf13/viper"
+)
+
+// pipelineConfig holds the settings of the cluster, score, integrity and plan
+// phases shared by 'mlc plan' and 'mlc watch'
+type pipelineConfig struct {
+	db     *store.Store
+	logger *report.EventLogger
+
+	dest           string
+	mode           string
+	featCredits    string
+	fuzzyThreshold float64
+	integrityMode  string
+	forceRecluster bool
+
+	normalizer meta.NormalizerChain
+	classifier *classify.Classifier
+	rules      *meta.RuleSet
+}
+
+// pipelineResult holds the results of each phase
+type pipelineResult struct {
+	cluster  *cluster.Result
+	score    *score.Result
+	plan     *plan.Result
+	duration time.Duration
+}
+
+// integrityModeFromConfig returns the configured integrity mode
+func integrityModeFromConfig() (string, error) {
+	mode := viper.GetString("integrity")
+	if mode == "" {
+		mode = integrity.ModeOff
+	}
+	if !integrity.ValidMode(mode) {
+		return "", fmt.Errorf("invalid integrity mode: %s (must be one of: off, winners, all)", mode)
+	}
+	return mode, nil
+}
+
+// runPipeline clusters, scores, checks integrity and plans the files with metadata
+func runPipeline(ctx context.Context, cfg *pipelineConfig) (*pipelineResult, error) {
+	// Phase 1: Clustering
+	util.InfoLog("=== Phase 1: Clustering ===")
+
+	// Stale clusters (metadata updated after clustering) no longer require a full
+	// re-cluster: incremental clustering re-keys exactly the changed files
+	if !cfg.forceRecluster {
+		isStale, clusterTime, metadataTime, err := cfg.db.DetectStaleClusters()
+		if err != nil {
+			util.WarnLog("Failed to detect stale clusters: %v", err)
+		} else if isStale {
+			util.InfoLog("Metadata updated after clustering (clusters: %s, newest metadata: %s)",
+				clusterTime.Format("2006-01-02 15:04:05"), metadataTime.Format("2006-01-02 15:04:05"))
+			util.InfoLog("Changed files will be re-clustered incrementally")
+		}
+	}
+
+	clusterer := cluster.New(&cluster.Config{
+		Store:          cfg.db,
+		Logger:         cfg.logger,
+		ForceRecluster: cfg.forceRecluster,
+		FuzzyThreshold: cfg.fuzzyThreshold,
+		Normalizer:     cfg.normalizer,
+		Transliterate:  viper.GetBool("transliterate"),
+		Classifier:     cfg.classifier,
+
+		NoAutoRecluster: viper.GetBool("no-auto-healing"),
+	})
+
+	startTime := time.Now()
+
+	clusterResult, err := clusterer.Cluster(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("clustering failed: %w", err)
+	}
+
+	clusterDuration := time.Since(startTime)
+
+	util.SuccessLog("Clustering complete in %v", clusterDuration.Round(time.Millisecond))
+	if clusterResult.Incremental {
+		util.InfoLog("  Files added: %d, moved: %d, removed: %d",
+			clusterResult.FilesAdded, clusterResult.FilesMoved, clusterResult.FilesRemoved)
+		util.InfoLog("  Clusters changed: %d", clusterResult.ClustersTouched)
+	}
+	util.InfoLog("  Clusters created: %d", clusterResult.ClustersCreated)
+	util.InfoLog("  Singleton clusters: %d", clusterResult.SingletonClusters)
+	util.InfoLog("  Duplicate clusters: %d", clusterResult.DuplicateClusters)
+	if clusterResult.DurationLinked > 0 {
+		util.InfoLog("  Linked across duration buckets: %d files", clusterResult.DurationLinked)
+	}
+	if clusterResult.FuzzyMerged > 0 {
+		util.InfoLog("  Fuzzy matches: %d files", clusterResult.FuzzyMerged)
+	}
+	if clusterResult.IdentityMoved > 0 {
+		util.InfoLog("  Placed by MusicBrainz ID/ISRC: %d files", clusterResult.IdentityMoved)
+	}
+	if clusterResult.IdentityConflicts > 0 {
+		util.WarnLog("  Identity conflicts: %d (see 'mlc report')", clusterResult.IdentityConflicts)
+	}
+	if len(clusterResult.Errors) > 0 {
+		util.WarnLog("  Errors: %d", len(clusterResult.Errors))
+	}
+
+	// Phase 2: Quality Scoring
+	util.InfoLog("")
+	util.InfoLog("=== Phase 2: Quality Scoring ===")
+
+	scorer := score.New(&score.Config{
+		Store:        cfg.db,
+		Logger:       cfg.logger,
+		ForceRescore: cfg.forceRecluster,
+
+		SourcePriorityBonus: viper.GetFloat64("source_priority_bonus"),
+	})
+
+	scoreStart := time.Now()
+
+	scoreResult, err := scorer.Score(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scoring failed: %w", err)
+	}
+
+	scoreDuration := time.Since(scoreStart)
+
+	util.SuccessLog("Scoring complete in %v", scoreDuration.Round(time.Millisecond))
+	util.InfoLog("  Files scored: %d", scoreResult.FilesScored)
+	util.InfoLog("  Winners selected: %d", scoreResult.WinnersSelected)
+	if len(scoreResult.Errors) > 0 {
+		util.WarnLog("  Errors: %d", len(scoreResult.Errors))
+	}
+
+	if cfg.integrityMode != integrity.ModeOff {
+		util.InfoLog("")
+		util.InfoLog("=== Integrity Check ===")
+		integrityStart := time.Now()
+		if err := runIntegrity(ctx, cfg.db, cfg.logger, cfg.integrityMode); err != nil {
+			return nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+		scoreDuration += time.Since(integrityStart)
+	}
+
+	// Phase 3: Planning
+	util.InfoLog("")
+	util.InfoLog("=== Phase 3: Planning ===")
+	util.InfoLog("Destination: %s", cfg.dest)
+	util.InfoLog("Mode: %s", cfg.mode)
+
+	planner := plan.New(&plan.Config{
+		Store:       cfg.db,
+		Mode:        cfg.mode,
+		Logger:      cfg.logger,
+		Incremental: clusterResult.Incremental,
+		FeatCredits: cfg.featCredits,
+		ASCIIPaths:  viper.GetBool("ascii_paths"),
+		Classifier:  cfg.classifier,
+		Rules:       cfg.rules,
+	})
+
+	planStart := time.Now()
+
+	planResult, err := planner.Plan(ctx, cfg.dest)
+	if err != nil {
+		return nil, fmt.Errorf("planning failed: %w", err)
+	}
+
+	planDuration := time.Since(planStart)
+
+	util.SuccessLog("Planning complete in %v", planDuration.Round(time.Millisecond))
+	util.InfoLog("  Winners planned: %d", planResult.WinnersPlanned)
+	util.InfoLog("  Duplicates skipped: %d", planResult.DuplicatesSkipped)
+	util.InfoLog("  Singletons: %d", planResult.SingletonsPlanned)
+	if planResult.Excluded > 0 {
+		util.InfoLog("  Excluded by class: %d", planResult.Excluded)
+	}
+	if len(planResult.Errors) > 0 {
+		util.WarnLog("  Errors: %d", len(planResult.Errors))
+	}
+
+	return &pipelineResult{
+		cluster:  clusterResult,
+		score:    scoreResult,
+		plan:     planResult,
+		duration: clusterDuration + scoreDuration + planDuration,
+	}, nil
+}
+
+// runIntegrity decodes unchecked files (all of them, or only cluster winners)
+// and rescores clusters whose winner turned out to be damaged. In winners mode
+// this repeats until every winner has been checked, since a rescore can promote
+// a file that has not been decoded yet.
+func runIntegrity(ctx context.Context, db *store.Store, logger *report.EventLogger, mode string) error {
+	if err := integrity.ValidateFFmpeg(); err != nil {
+		util.WarnLog("Skipping integrity check: %v", err)
+		return nil
+	}
+
+	checker := integrity.New(&integrity.Config{
+		Store:       db,
+		Logger:      logger,
+		Concurrency: viper.GetInt("concurrency"),
+	})
+	rescorer := score.New(&score.Config{
+		Store:  db,
+		Logger: logger,
+
+		SourcePriorityBonus: viper.GetFloat64("source_priority_bonus"),
+	})
+
+	checked, damaged, rescored := 0, 0, 0
+	for {
+		result, err := checker.Check(ctx, mode == integrity.ModeWinners)
+		if err != nil {
+			return err
+		}
+		checked += result.FilesChecked
+		damaged += result.Damaged
+		if len(result.Errors) > 0 {
+			util.WarnLog("  Errors: %d", len(result.Errors))
+		}
+
+		demoted, err := checker.DemoteDamagedWinners()
+		if err != nil {
+			return err
+		}
+		if demoted == 0 {
+			break
+		}
+		rescored += demoted
+		if _, err := rescorer.Score(ctx); err != nil {
+			return fmt.Errorf("rescoring failed: %w", err)
+		}
+	}
+
+	util.SuccessLog("Integrity check complete")
+	util.InfoLog("  Files decoded: %d", checked)
+	if damaged > 0 {
+		util.WarnLog("  Damaged files: %d", damaged)
+	}
+	if rescored > 0 {
+		util.InfoLog("  Clusters rescored after damaged winners: %d", rescored)
+	}
+	return nil
+}
@@ -6,11 +6,11 @@ import (
 	"time"
 
 	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/integrity"
 	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/musicbrainz"
 	"github.com/franz/music-janitor/internal/plan"
 	"github.com/franz/music-janitor/internal/report"
-	"github.com/franz/music-janitor/internal/score"
 	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 	"github.com/spf13/cobra"
@@ -39,6 +39,26 @@ func init() {
 	// Plan-specific flags
 	planCmd.Flags().Bool("dry-run", false, "Preview plan without saving to database")
 	planCmd.Flags().Bool("force-recluster", false, "Force complete re-clustering (discards resume state)")
+	planCmd.Flags().Float64("fuzzy-threshold", cluster.DefaultFuzzyThreshold, "Minimum title/artist similarity (0-1) for fuzzy duplicate matching (0 disables)")
+
+	planCmd.Flags().String("feat-credits", plan.FeatCreditsTitle, "Where feature credits go in destination paths: title, artist, drop, keep")
+
+	viper.BindPFlag("fuzzy_threshold", planCmd.Flags().Lookup("fuzzy-threshold"))
+	planCmd.Flags().String("integrity", integrity.ModeOff, "Decode files with ffmpeg to find corrupt or truncated ones: off, winners, all")
+
+	viper.BindPFlag("feat_credits", planCmd.Flags().Lookup("feat-credits"))
+	viper.BindPFlag("integrity", planCmd.Flags().Lookup("integrity"))
+
+	planCmd.Flags().Bool("transliterate", false, "Match Cyrillic, Greek and kana artists/titles with their romanized spelling")
+	planCmd.Flags().Bool("ascii-paths", false, "Transliterate destination folders and file names to ASCII")
+	viper.BindPFlag("transliterate", planCmd.Flags().Lookup("transliterate"))
+	viper.BindPFlag("ascii_paths", planCmd.Flags().Lookup("ascii-paths"))
+
+	planCmd.Flags().Float64("source-priority-bonus", 0, "Score points added per level of source priority (0: priority only breaks ties)")
+	viper.BindPFlag("source_priority_bonus", planCmd.Flags().Lookup("source-priority-bonus"))
+
+	planCmd.Flags().Bool("classify", true, "Sort audiobooks, podcasts, DJ mixes and spoken word into their own layouts (see 'classes' config)")
+	viper.BindPFlag("classify", planCmd.Flags().Lookup("classify"))
 }
 
 func runPlan(cmd *cobra.Command, args []string) error {
@@ -66,16 +86,41 @@ func runPlan(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid mode: %s (must be one of: copy, move, hardlink, symlink)", mode)
 	}
 
+	featCredits := viper.GetString("feat_credits")
+	if !plan.ValidFeatCredits(featCredits) {
+		return fmt.Errorf("invalid feat_credits: %s (must be one of: title, artist, drop, keep)", featCredits)
+	}
+
+	integrityMode, err := integrityModeFromConfig()
+	if err != nil {
+		return err
+	}
+
 	dbPath := viper.GetString("db")
 	verbose := viper.GetBool("verbose")
 	quiet := viper.GetBool("quiet")
 	dryRun := viper.GetBool("dry-run")
 	forceRecluster, _ := cmd.Flags().GetBool("force-recluster")
 
+	fuzzyThreshold := viper.GetFloat64("fuzzy_threshold")
+	if fuzzyThreshold < 0 || fuzzyThreshold > 1 {
+		return fmt.Errorf("invalid fuzzy threshold: %.2f (must be between 0 and 1)", fuzzyThreshold)
+	}
+
+	classifier, err := loadClassifier()
+	if err != nil {
+		return err
+	}
+
 	// Set log level
 	util.SetVerbose(verbose)
 	util.SetQuiet(quiet)
 
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+
 	// Auto-tune for NAS if destination is on network storage
 	// Plan stage doesn't use concurrency, but we detect and log network info
 	var nasMode *bool
@@ -103,6 +148,8 @@ func runPlan(cmd *cobra.Command, args []string) error {
 	// Open database with network optimizations if needed
 	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
 		NetworkOptimized: dbNetworkOptimized || (nasConfig != nil && nasConfig.IsNASMode),
+		Lock:             "plan",
+		BreakLock:        viper.GetBool("break_lock"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -128,18 +175,33 @@ func runPlan(cmd *cobra.Command, args []string) error {
 		util.InfoLog("Event log: %s", logger.Path())
 	}
 
+	// Artist name resolution: the user's alias map, then cached MusicBrainz
+	// names, then MusicBrainz lookups
+	var normalizer meta.NormalizerChain
+	aliases, err := loadAliasMap()
+	if err != nil {
+		return err
+	}
+	if aliases.Len() > 0 {
+		util.InfoLog("Artist aliases: %d names", aliases.Len())
+		normalizer = append(normalizer, aliases)
+	}
+
 	// MusicBrainz integration (optional)
 	var mbCache *musicbrainz.Cache
 	enableMusicBrainz := viper.GetBool("musicbrainz")
+	offlineMusicBrainz := viper.GetBool("musicbrainz_offline")
 	preloadMusicBrainz := viper.GetBool("musicbrainz_preload")
 
-	if enableMusicBrainz {
+	if enableMusicBrainz || offlineMusicBrainz {
 		util.InfoLog("=== MusicBrainz Integration ===")
-		util.InfoLog("Initializing MusicBrainz client...")
 
-		// Create MusicBrainz client
-		mbClient := musicbrainz.NewClient()
-		defer mbClient.Close()
+		var mbClient *musicbrainz.Client
+		if !offlineMusicBrainz {
+			util.InfoLog("Initializing MusicBrainz client...")
+			mbClient = musicbrainz.NewClient()
+			defer mbClient.Close()
+		}
 
 		// Create cache
 		mbCache = musicbrainz.NewCache(db.DB(), mbClient)
@@ -149,17 +211,22 @@ func runPlan(cmd *cobra.Command, args []string) error {
 			util.WarnLog("Failed to initialize MusicBrainz cache: %v", err)
 			util.WarnLog("Continuing without MusicBrainz...")
 		} else {
-			// Set global normalizer for meta package
-			meta.GlobalMBNormalizer = mbCache
-
-			util.SuccessLog("MusicBrainz integration enabled")
+			normalizer = append(normalizer, mbCache.Offline())
+			if offlineMusicBrainz {
+				util.SuccessLog("MusicBrainz integration enabled (offline: cached names only)")
+			} else {
+				normalizer = append(normalizer, mbCache)
+				util.SuccessLog("MusicBrainz integration enabled")
+			}
 
 			// Show cache stats
 			entries, hits, _ := mbCache.GetStats()
 			util.InfoLog("  Cache: %d artists, %d hits", entries, hits)
 
 			// Optional preload
-			if preloadMusicBrainz {
+			if preloadMusicBrainz && offlineMusicBrainz {
+				util.WarnLog("Skipping MusicBrainz preload in offline mode")
+			} else if preloadMusicBrainz {
 				util.InfoLog("")
 				util.InfoLog("Preloading artists from MusicBrainz...")
 				util.InfoLog("This will take a while (1 request/second rate limit)")
@@ -179,134 +246,31 @@ func runPlan(cmd *cobra.Command, args []string) error {
 		util.InfoLog("")
 	}
 
-	// Phase 1: Clustering
-	util.InfoLog("=== Phase 1: Clustering ===")
-
-	// Auto-healing: Detect stale clusters
-	noAutoHealing := viper.GetBool("no-auto-healing")
-	autoRecluster := false
-
-	if !forceRecluster && !noAutoHealing {
-		isStale, clusterTime, metadataTime, err := db.DetectStaleClusters()
-		if err != nil {
-			util.WarnLog("Failed to detect stale clusters: %v", err)
-		} else if isStale {
-			util.WarnLog("⚠️  STALE CLUSTERS DETECTED")
-			util.WarnLog("   Clusters created: %s", clusterTime.Format("2006-01-02 15:04:05"))
-			util.WarnLog("   Newest metadata:  %s", metadataTime.Format("2006-01-02 15:04:05"))
-			util.WarnLog("")
-			util.WarnLog("   Metadata was updated AFTER clustering, which may cause")
-			util.WarnLog("   incorrect duplicate detection and quality scoring.")
-			util.WarnLog("")
-			util.InfoLog("🔧 Auto-healing: Triggering automatic re-clustering...")
-
-			logger.Log(&report.Event{
-				Timestamp: time.Now(),
-				Level:     report.LevelInfo,
-				Event:     report.EventAutoHeal,
-				Action:    "auto_recluster",
-				Reason:    "stale_clusters_detected",
-				Extra: map[string]string{
-					"cluster_time":    clusterTime.Format(time.RFC3339),
-					"metadata_time":   metadataTime.Format(time.RFC3339),
-					"time_diff_hours": fmt.Sprintf("%.2f", metadataTime.Sub(clusterTime).Hours()),
-				},
-			})
-
-			autoRecluster = true
-			forceRecluster = true
-		}
-	}
-
-	clusterer := cluster.New(&cluster.Config{
-		Store:          db,
-		Logger:         logger,
-		ForceRecluster: forceRecluster,
-	})
-
-	startTime := time.Now()
-
-	clusterResult, err := clusterer.Cluster(ctx)
-	if err != nil {
-		return fmt.Errorf("clustering failed: %w", err)
-	}
-
-	clusterDuration := time.Since(startTime)
-
-	if autoRecluster {
-		util.SuccessLog("✓ Auto-healing: Re-clustering completed successfully")
-	}
-
-	util.SuccessLog("Clustering complete in %v", clusterDuration.Round(time.Millisecond))
-	util.InfoLog("  Clusters created: %d", clusterResult.ClustersCreated)
-	util.InfoLog("  Singleton clusters: %d", clusterResult.SingletonClusters)
-	util.InfoLog("  Duplicate clusters: %d", clusterResult.DuplicateClusters)
-	if len(clusterResult.Errors) > 0 {
-		util.WarnLog("  Errors: %d", len(clusterResult.Errors))
-	}
-
-	// Phase 2: Quality Scoring
-	util.InfoLog("")
-	util.InfoLog("=== Phase 2: Quality Scoring ===")
-
-	scorer := score.New(&score.Config{
-		Store:       db,
-		Logger:      logger,
-		ForceRescore: forceRecluster,
-	})
-
-	scoreStart := time.Now()
-
-	scoreResult, err := scorer.Score(ctx)
-	if err != nil {
-		return fmt.Errorf("scoring failed: %w", err)
-	}
-
-	scoreDuration := time.Since(scoreStart)
-
-	util.SuccessLog("Scoring complete in %v", scoreDuration.Round(time.Millisecond))
-	util.InfoLog("  Files scored: %d", scoreResult.FilesScored)
-	util.InfoLog("  Winners selected: %d", scoreResult.WinnersSelected)
-	if len(scoreResult.Errors) > 0 {
-		util.WarnLog("  Errors: %d", len(scoreResult.Errors))
-	}
-
-	// Phase 3: Planning
-	util.InfoLog("")
-	util.InfoLog("=== Phase 3: Planning ===")
-	util.InfoLog("Destination: %s", dest)
-	util.InfoLog("Mode: %s", mode)
 	if dryRun {
 		util.InfoLog("Dry-run mode: no changes will be made")
 	}
 
-	planner := plan.New(&plan.Config{
-		Store:  db,
-		Mode:   mode,
-		Logger: logger,
+	result, err := runPipeline(ctx, &pipelineConfig{
+		db:             db,
+		logger:         logger,
+		dest:           dest,
+		mode:           mode,
+		featCredits:    featCredits,
+		fuzzyThreshold: fuzzyThreshold,
+		integrityMode:  integrityMode,
+		forceRecluster: forceRecluster,
+		normalizer:     normalizer,
+		classifier:     classifier,
+		rules:          rules,
 	})
-
-	planStart := time.Now()
-
-	planResult, err := planner.Plan(ctx, dest)
 	if err != nil {
-		return fmt.Errorf("planning failed: %w", err)
-	}
-
-	planDuration := time.Since(planStart)
-
-	util.SuccessLog("Planning complete in %v", planDuration.Round(time.Millisecond))
-	util.InfoLog("  Winners planned: %d", planResult.WinnersPlanned)
-	util.InfoLog("  Duplicates skipped: %d", planResult.DuplicatesSkipped)
-	util.InfoLog("  Singletons: %d", planResult.SingletonsPlanned)
-	if len(planResult.Errors) > 0 {
-		util.WarnLog("  Errors: %d", len(planResult.Errors))
+		return err
 	}
 
 	// Summary
 	util.InfoLog("")
 	util.SuccessLog("=== Plan Summary ===")
-	util.InfoLog("Total time: %v", (clusterDuration + scoreDuration + planDuration).Round(time.Millisecond))
+	util.InfoLog("Total time: %v", result.duration.Round(time.Millisecond))
 	util.InfoLog("Database: %s", dbPath)
 
 	// Show action counts
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/franz/music-janitor/internal/prune"
+	"github.com/franz/music-janitor/internal/report"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Mark files whose source path no longer exists as missing",
+	Long: `Check that every known source file still exists and mark vanished files
+as missing (status=missing).
+
+Missing files leave their clusters and lose their plans, so 'mlc execute'
+no longer tries to open them. The clusters they belonged to are rescored
+and replanned by the next 'mlc plan'. Files that come back are picked up
+again by the next scan.
+
+Executed files are never pruned. Files under a source root that is missing,
+empty or not responding are skipped, and so is a root where more than half
+of the files are gone (see --max-missing-ratio), as that usually means a
+network share is not mounted. Use --force to prune such a root anyway.
+
+Use --dry-run to list missing files without changing the database.`,
+	RunE: runPrune,
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().Bool("force", false, "Prune roots even when more files are missing than --max-missing-ratio allows")
+	pruneCmd.Flags().Float64("max-missing-ratio", prune.DefaultMaxMissingRatio, "Share of a source's files that may be missing before the source is treated as unmounted")
+	pruneCmd.Flags().Duration("stat-timeout", prune.DefaultStatTimeout, "Give up on a path that does not respond within this time")
+	viper.BindPFlag("prune_max_missing_ratio", pruneCmd.Flags().Lookup("max-missing-ratio"))
+	viper.BindPFlag("prune_stat_timeout", pruneCmd.Flags().Lookup("stat-timeout"))
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	concurrency := viper.GetInt("concurrency")
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+
+	dbPath := viper.GetString("db")
+	verbose := viper.GetBool("verbose")
+	quiet := viper.GetBool("quiet")
+	dryRun := viper.GetBool("dry_run")
+	force, _ := cmd.Flags().GetBool("force")
+
+	util.SetVerbose(verbose)
+	util.SetQuiet(quiet)
+
+	util.InfoLog("Opening database: %s", dbPath)
+
+	dbNetworkOptimized := false
+	if dbInfo, err := util.DetectNetworkFilesystem(dbPath); err == nil && dbInfo.IsNetwork {
+		dbNetworkOptimized = true
+		util.InfoLog("Database on network storage (%s) - applying optimizations", dbInfo.Protocol)
+	}
+
+	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
+		NetworkOptimized: dbNetworkOptimized,
+		Lock:             "prune",
+		BreakLock:        viper.GetBool("break_lock"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	logLevel := report.LevelInfo
+	if quiet {
+		logLevel = report.LevelWarning
+	} else if verbose {
+		logLevel = report.LevelDebug
+	}
+
+	logger, err := report.NewEventLogger("artifacts", logLevel)
+	if err != nil {
+		util.WarnLog("Failed to create event logger: %v", err)
+		logger = report.NullLogger()
+	}
+	defer logger.Close()
+
+	result, err := runPruneStep(ctx, db, logger, concurrency, dryRun, force)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Missing) > 0 && !dryRun {
+		util.InfoLog("")
+		util.InfoLog("Next step: mlc plan --dest <destination> --db %s", dbPath)
+	}
+	return nil
+}
+
+// runPruneStep prunes vanished files and prints what was removed
+func runPruneStep(ctx context.Context, db *store.Store, logger *report.EventLogger, concurrency int, dryRun, force bool) (*prune.Result, error) {
+	pruner := prune.New(&prune.Config{
+		Store:           db,
+		Logger:          logger,
+		Concurrency:     concurrency,
+		DryRun:          dryRun,
+		Force:           force,
+		MaxMissingRatio: viper.GetFloat64("prune_max_missing_ratio"),
+		StatTimeout:     viper.GetDuration("prune_stat_timeout"),
+	})
+
+	result, err := pruner.Prune(ctx)
+	if err != nil {
+		return result, fmt.Errorf("prune failed: %w", err)
+	}
+
+	for _, file := range result.Missing {
+		if dryRun {
+			util.InfoLog("  Would mark missing: %s", file.SrcPath)
+		} else {
+			util.DebugLog("  Marked missing: %s", file.SrcPath)
+		}
+	}
+
+	if dryRun {
+		util.SuccessLog("Prune dry run: %d of %d files missing", len(result.Missing), result.FilesChecked)
+	} else {
+		util.SuccessLog("Prune complete: %d of %d files marked missing", len(result.Missing), result.FilesChecked)
+		if result.PlansRemoved > 0 {
+			util.InfoLog("  Plans removed: %d", result.PlansRemoved)
+		}
+		if result.ClustersRescored > 0 {
+			util.InfoLog("  Clusters to rescore: %d", result.ClustersRescored)
+		}
+		if result.ClustersRemoved > 0 {
+			util.InfoLog("  Empty clusters removed: %d", result.ClustersRemoved)
+		}
+	}
+	if result.Unreachable > 0 {
+		util.WarnLog("  Unreachable files (left alone): %d", result.Unreachable)
+	}
+	for _, root := range result.SkippedRoots {
+		name := root.Root
+		if name == "" {
+			name = "files outside known sources"
+		} else if root.Label != "" && root.Label != root.Root {
+			name = fmt.Sprintf("%s (%s)", root.Root, root.Label)
+		}
+		util.WarnLog("  Skipped %s: %d files, %s", name, root.Files, root.Reason)
+	}
+
+	return result, nil
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/franz/music-janitor/internal/report"
-	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -51,9 +50,9 @@ func runReport(cmd *cobra.Command, args []string) error {
 	util.InfoLog("Database: %s", dbPath)
 
 	// Open database
-	db, err := store.Open(dbPath)
+	db, err := openReadOnlyDB(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -99,6 +98,16 @@ func runReport(cmd *cobra.Command, args []string) error {
 		util.InfoLog("  Duplicate groups: %d", summaryReport.DuplicateClusters)
 		util.InfoLog("  Duplicates skipped: %d", summaryReport.DuplicatesSkipped)
 	}
+	if len(summaryReport.Sources) > 1 {
+		util.InfoLog("  Sources:")
+		for _, src := range summaryReport.Sources {
+			label := src.Source.Label
+			if src.Unlabelled {
+				label = "(unlabelled)"
+			}
+			util.InfoLog("    %s: %d files, %d winners, %d duplicates skipped", label, src.Files, src.Winners, src.Skipped)
+		}
+	}
 	if summaryReport.FilesExecuted > 0 {
 		util.InfoLog("  Files executed: %d", summaryReport.FilesExecuted)
 		util.InfoLog("  Bytes written: %s", util.FormatBytes(summaryReport.BytesWritten))
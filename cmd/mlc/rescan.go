@@ -67,6 +67,8 @@ func runRescan(cmd *cobra.Command, args []string) error {
 	// Open database with network optimizations if needed
 	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
 		NetworkOptimized: dbNetworkOptimized,
+		Lock:             "rescan",
+		BreakLock:        viper.GetBool("break_lock"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -184,46 +186,13 @@ func runRescan(cmd *cobra.Command, args []string) error {
 				go func(f *store.File) {
 					defer func() { <-semaphore }()
 
-					// Re-extract metadata using the hybrid approach
-					newMetadata, err := meta.ExtractFromPath(f.SrcPath)
+					changed, err := rescanFile(db, f)
 					if err != nil {
-						util.ErrorLog("Failed to re-extract metadata for %s: %v", f.SrcPath, err)
+						util.ErrorLog("%v", err)
 						errors.Add(1)
-						processed.Add(1)
-						// Update status to error
-						db.UpdateFileStatus(f.ID, "error", err.Error())
-						return
-					}
-
-					// Get old metadata (may not exist for previously failed files)
-					oldMetadata, err := db.GetMetadata(f.ID)
-					wasError := f.Status == "error"
-
-					// If file previously had error status, this is a successful recovery
-					if wasError {
+					} else if changed {
 						updated.Add(1)
-						util.DebugLog("Successfully extracted metadata for previously failed file: %s", f.SrcPath)
-					} else if err == nil && oldMetadata != nil {
-						// Check if compilation flag changed
-						if oldMetadata.TagCompilation != newMetadata.TagCompilation {
-							updated.Add(1)
-							util.DebugLog("Updated compilation flag for %s: %v -> %v",
-								f.SrcPath, oldMetadata.TagCompilation, newMetadata.TagCompilation)
-						}
 					}
-
-					// Update metadata
-					newMetadata.FileID = f.ID
-					if err := db.InsertMetadata(newMetadata); err != nil {
-						util.ErrorLog("Failed to update metadata for file %d: %v", f.ID, err)
-						errors.Add(1)
-						processed.Add(1)
-						return
-					}
-
-					// Update status to meta_ok
-					db.UpdateFileStatus(f.ID, "meta_ok", "")
-
 					processed.Add(1)
 				}(file)
 			}
@@ -258,3 +227,38 @@ func runRescan(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// rescanFile re-extracts and stores the metadata of f and marks it meta_ok
+// changed reports a recovered failed file or a changed compilation flag.
+func rescanFile(db *store.Store, f *store.File) (changed bool, err error) {
+	// Re-extract metadata using the hybrid approach
+	newMetadata, err := meta.ExtractFromPath(f.SrcPath)
+	if err != nil {
+		// Update status to error
+		db.UpdateFileStatus(f.ID, "error", err.Error())
+		return false, fmt.Errorf("failed to re-extract metadata for %s: %w", f.SrcPath, err)
+	}
+
+	// Get old metadata (may not exist for previously failed files)
+	oldMetadata, err := db.GetMetadata(f.ID)
+
+	// If file previously had error status, this is a successful recovery
+	if f.Status == "error" {
+		changed = true
+		util.DebugLog("Successfully extracted metadata for previously failed file: %s", f.SrcPath)
+	} else if err == nil && oldMetadata != nil && oldMetadata.TagCompilation != newMetadata.TagCompilation {
+		changed = true
+		util.DebugLog("Updated compilation flag for %s: %v -> %v",
+			f.SrcPath, oldMetadata.TagCompilation, newMetadata.TagCompilation)
+	}
+
+	// Update metadata
+	newMetadata.FileID = f.ID
+	if err := db.InsertMetadata(newMetadata); err != nil {
+		return changed, fmt.Errorf("failed to update metadata for file %d: %w", f.ID, err)
+	}
+
+	// Update status to meta_ok
+	db.UpdateFileStatus(f.ID, "meta_ok", "")
+	return changed, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// mp4Box encodes an MP4 box with the given body
+func mp4Box(typ string, body ...[]byte) []byte {
+	payload := bytes.Join(body, nil)
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], typ)
+	return append(b, payload...)
+}
+
+// testMusicVideo returns an MP4 with a video track and a 2 s AAC sound track
+func testMusicVideo() []byte {
+	be := binary.BigEndian
+	mvhd := make([]byte, 100)
+	be.PutUint32(mvhd[12:], 1000)
+	be.PutUint32(mvhd[16:], 2000)
+	mdhd := make([]byte, 24)
+	be.PutUint32(mdhd[12:], 44100)
+	be.PutUint32(mdhd[16:], 88200)
+	handler := func(typ string) []byte {
+		return append(append(make([]byte, 8), typ...), make([]byte, 13)...)
+	}
+	esds := []byte{0, 0, 0, 0,
+		0x03, 22, 0, 1, 0,
+		0x04, 17, 0x40, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0x05, 2, 0x12, 0x10}
+	sampleEntry := make([]byte, 28)
+	be.PutUint16(sampleEntry[6:], 1)
+	be.PutUint16(sampleEntry[16:], 2)
+	be.PutUint16(sampleEntry[18:], 16)
+	be.PutUint32(sampleEntry[24:], 44100<<16)
+	stsd := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, mp4Box("mp4a", sampleEntry, mp4Box("esds", esds))...)
+
+	sound := mp4Box("trak", mp4Box("mdia", mp4Box("mdhd", mdhd), mp4Box("hdlr", handler("soun")),
+		mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))))
+	video := mp4Box("trak", mp4Box("mdia", mp4Box("mdhd", mdhd), mp4Box("hdlr", handler("vide"))))
+	data := append(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")), mp4Box("moov", mp4Box("mvhd", mvhd), video, sound)...)
+	return append(data, mp4Box("mdat", make([]byte, 1000))...)
+}
+
+func TestRescanFileKeepsVideo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clip.mp4")
+	if err := os.WriteFile(path, testMusicVideo(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := store.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	file := &store.File{FileKey: "clip", SrcPath: path, SizeBytes: 1024, Status: "meta_ok"}
+	if err := db.InsertFile(file); err != nil {
+		t.Fatalf("failed to insert file: %v", err)
+	}
+	if err := db.InsertMetadata(&store.Metadata{FileID: file.ID, Codec: "aac", HasVideo: true}); err != nil {
+		t.Fatalf("failed to insert metadata: %v", err)
+	}
+
+	if _, err := rescanFile(db, file); err != nil {
+		t.Fatalf("rescanFile failed: %v", err)
+	}
+
+	m, err := db.GetMetadata(file.ID)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if !m.HasVideo {
+		t.Error("expected a rescanned music video to keep has_video")
+	}
+	if m.DurationMs != 2000 {
+		t.Errorf("DurationMs = %d, want 2000", m.DurationMs)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Inspect and test metadata cleaning rules",
+	Long: `Metadata cleaning rules are regular expressions applied to tags during
+metadata extraction (auto-healing) and to artist names in destination paths.
+
+The built-in rules can be replaced or extended with a YAML rules file, set
+with --rules or the "rules" config key. Start from the built-in rules:
+
+  mlc rules default > rules.yaml`,
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run the examples embedded in the rules",
+	Long: `Run every example (tests:) embedded in the active rules and report the
+ones that do not produce the expected result.
+
+Checks the built-in rules, or the rules file given with --rules.`,
+	RunE: runRulesTest,
+}
+
+var rulesDefaultCmd = &cobra.Command{
+	Use:   "default",
+	Short: "Print the built-in rules",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := os.Stdout.Write(meta.DefaultRulesYAML())
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesTestCmd, rulesDefaultCmd)
+}
+
+// loadRules reads the rules file from the config; without one it returns
+// nil, which the stages take as the built-in rules
+func loadRules() (*meta.RuleSet, error) {
+	path := viper.GetString("rules")
+	if path == "" {
+		return nil, nil
+	}
+	rs, err := meta.LoadRules(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules: %w", err)
+	}
+	util.DebugLog("Using cleaning rules from %s (%d rules)", path, len(rs.Rules))
+	return rs, nil
+}
+
+func runRulesTest(cmd *cobra.Command, args []string) error {
+	util.SetVerbose(viper.GetBool("verbose"))
+	util.SetQuiet(viper.GetBool("quiet"))
+
+	rs, err := loadRules()
+	if err != nil {
+		return err
+	}
+	if rs == nil {
+		rs = meta.DefaultRules()
+	}
+	count, failures := rs.RunTests()
+	for _, f := range failures {
+		util.ErrorLog("%s", f)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d rule examples failed", len(failures), count)
+	}
+	util.SuccessLog("All %d examples passed (%d rules)", count, len(rs.Rules))
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/franz/music-janitor/internal/meta"
@@ -31,16 +33,38 @@ resumed if interrupted.`,
 
 func init() {
 	rootCmd.AddCommand(scanCmd)
+
+	scanCmd.Flags().StringSlice("include", nil, "Only scan files matching these glob patterns (relative to each source)")
+	scanCmd.Flags().StringSlice("exclude", nil, "Skip files and folders matching these glob patterns")
+	scanCmd.Flags().StringSlice("junk-presets", scan.DefaultJunkPresets, "Built-in junk folders to skip: synology, qnap, macos, windows, podcasts, incomplete, none")
+	scanCmd.Flags().String("min-file-size", "", "Skip audio files smaller than this (e.g. 64KB)")
+	viper.BindPFlag("include", scanCmd.Flags().Lookup("include"))
+	viper.BindPFlag("exclude", scanCmd.Flags().Lookup("exclude"))
+	viper.BindPFlag("junk_presets", scanCmd.Flags().Lookup("junk-presets"))
+	viper.BindPFlag("min_file_size", scanCmd.Flags().Lookup("min-file-size"))
+
+	scanCmd.Flags().Bool("content-id", true, "Hash the start and end of new files to relink moved, copied or touched files")
+	viper.BindPFlag("content_id", scanCmd.Flags().Lookup("content-id"))
+
+	scanCmd.Flags().Bool("archives", true, "Scan audio files inside zip and tar archives")
+	viper.BindPFlag("archives", scanCmd.Flags().Lookup("archives"))
+
+	scanCmd.Flags().Bool("prune", false, "Mark files whose source path no longer exists as missing (see 'mlc prune')")
+	viper.BindPFlag("prune", scanCmd.Flags().Lookup("prune"))
 }
 
 func runScan(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 
 	// Get configuration from viper (flags override config file)
-	source := viper.GetString("source")
-	if source == "" {
-		return fmt.Errorf("source directory is required (use --source/-s or set in config)")
+	sources, err := loadSources(cmd)
+	if err != nil {
+		return err
 	}
+	if len(sources) == 0 {
+		return fmt.Errorf("source directory is required (use --source/-s, or set source or sources in config)")
+	}
+	source := sources[0].Path
 
 	concurrency := viper.GetInt("concurrency")
 	if concurrency <= 0 {
@@ -55,9 +79,16 @@ func runScan(cmd *cobra.Command, args []string) error {
 	util.SetVerbose(verbose)
 	util.SetQuiet(quiet)
 
-	// Verify source exists
-	if _, err := os.Stat(source); os.IsNotExist(err) {
-		return fmt.Errorf("source directory does not exist: %s", source)
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+
+	// Verify sources exist
+	for _, src := range sources {
+		if _, err := os.Stat(src.Path); os.IsNotExist(err) {
+			return fmt.Errorf("source directory does not exist: %s", src.Path)
+		}
 	}
 
 	// Auto-tune for NAS if source is on network storage
@@ -88,6 +119,8 @@ func runScan(cmd *cobra.Command, args []string) error {
 	// Open database with network optimizations if needed
 	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
 		NetworkOptimized: dbNetworkOptimized || (nasConfig != nil && nasConfig.IsNASMode),
+		Lock:             "scan",
+		BreakLock:        viper.GetBool("break_lock"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -115,18 +148,23 @@ func runScan(cmd *cobra.Command, args []string) error {
 
 	// Phase 1: Discovery
 	util.InfoLog("=== Phase 1: File Discovery ===")
-	util.InfoLog("Source: %s", source)
+	for _, src := range sources {
+		if src.Label != "" {
+			util.InfoLog("Source: %s (%s, priority %d)", src.Path, src.Label, src.Priority)
+		} else {
+			util.InfoLog("Source: %s", src.Path)
+		}
+	}
 	util.InfoLog("Concurrency: %d", concurrency)
 
-	scanner := scan.New(&scan.Config{
-		Store:       db,
-		Concurrency: concurrency,
-		Logger:      logger,
-	})
+	scanner, err := newScanner(db, logger, concurrency)
+	if err != nil {
+		return err
+	}
 
 	startTime := time.Now()
 
-	scanResult, err := scanner.Scan(ctx, source)
+	scanResult, err := scanner.ScanSources(ctx, sources)
 	if err != nil {
 		return fmt.Errorf("scan failed: %w", err)
 	}
@@ -136,10 +174,32 @@ func runScan(cmd *cobra.Command, args []string) error {
 	util.SuccessLog("Discovery complete in %v", scanDuration.Round(time.Millisecond))
 	util.InfoLog("  Files discovered: %d", scanResult.FilesDiscovered)
 	util.InfoLog("  Files skipped: %d", scanResult.FilesSkipped)
+	if scanResult.FilesRelinked > 0 {
+		util.InfoLog("  Files relinked: %d", scanResult.FilesRelinked)
+	}
+	if scanResult.FilesExcluded > 0 {
+		util.InfoLog("  Files excluded: %d", scanResult.FilesExcluded)
+	}
+	if scanResult.ArchivesScanned > 0 {
+		util.InfoLog("  Archives scanned: %d", scanResult.ArchivesScanned)
+	}
+	if scanResult.FilesRestored > 0 {
+		util.InfoLog("  Files restored: %d", scanResult.FilesRestored)
+	}
+	printSkippedByRule(scanResult.SkippedByRule)
 	if len(scanResult.Errors) > 0 {
 		util.WarnLog("  Errors: %d", len(scanResult.Errors))
 	}
 
+	// Optional: drop files that vanished from the sources
+	if viper.GetBool("prune") {
+		util.InfoLog("")
+		util.InfoLog("=== Pruning Missing Files ===")
+		if _, err := runPruneStep(ctx, db, logger, concurrency, false, false); err != nil {
+			return err
+		}
+	}
+
 	// Phase 2: Metadata Extraction
 	util.InfoLog("")
 	util.InfoLog("=== Phase 2: Metadata Extraction ===")
@@ -154,6 +214,7 @@ func runScan(cmd *cobra.Command, args []string) error {
 		Store:       db,
 		Concurrency: concurrency,
 		Logger:      logger,
+		Rules:       rules,
 	})
 
 	extractStart := time.Now()
@@ -220,3 +281,94 @@ func runScan(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// newScanner creates a scanner with the filter and discovery settings of the config
+func newScanner(db *store.Store, logger *report.EventLogger, concurrency int) (*scan.Scanner, error) {
+	minFileSize, err := util.ParseBytes(viper.GetString("min_file_size"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid min_file_size: %w", err)
+	}
+
+	return scan.New(&scan.Config{
+		Store:       db,
+		Concurrency: concurrency,
+		Logger:      logger,
+
+		Include:     viper.GetStringSlice("include"),
+		Exclude:     viper.GetStringSlice("exclude"),
+		JunkPresets: viper.GetStringSlice("junk_presets"),
+		MinFileSize: minFileSize,
+
+		DisableContentID: !viper.GetBool("content_id"),
+		DisableArchives:  !viper.GetBool("archives"),
+	}), nil
+}
+
+// sourceConfig is an entry of the "sources" config list
+type sourceConfig struct {
+	Label    string   `mapstructure:"label"`
+	Path     string   `mapstructure:"path"`
+	Priority int      `mapstructure:"priority"`
+	Include  []string `mapstructure:"include"`
+	Exclude  []string `mapstructure:"exclude"`
+}
+
+// loadSources returns the source roots to scan: the "sources" config list, or
+// the single --source directory when it is given on the command line or no list
+// is configured. A --source matching a configured path keeps its label, priority
+// and patterns.
+func loadSources(cmd *cobra.Command) ([]*scan.Source, error) {
+	var configured []sourceConfig
+	if err := viper.UnmarshalKey("sources", &configured); err != nil {
+		return nil, fmt.Errorf("failed to parse sources config: %w", err)
+	}
+
+	sources := make([]*scan.Source, 0, len(configured))
+	for i, sc := range configured {
+		if sc.Path == "" {
+			return nil, fmt.Errorf("sources[%d] has no path", i)
+		}
+		sources = append(sources, &scan.Source{
+			Label:    sc.Label,
+			Path:     sc.Path,
+			Priority: sc.Priority,
+			Include:  sc.Include,
+			Exclude:  sc.Exclude,
+		})
+	}
+
+	source := viper.GetString("source")
+	if source == "" || (len(sources) > 0 && !cmd.Flags().Changed("source")) {
+		return sources, nil
+	}
+
+	for _, src := range sources {
+		if filepath.Clean(src.Path) == filepath.Clean(source) {
+			return []*scan.Source{src}, nil
+		}
+	}
+	return []*scan.Source{{Path: source}}, nil
+}
+
+// printSkippedByRule lists the files and folders each filter rule skipped, most first
+func printSkippedByRule(skipped map[string]int) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	rules := make([]string, 0, len(skipped))
+	for rule := range skipped {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if skipped[rules[i]] != skipped[rules[j]] {
+			return skipped[rules[i]] > skipped[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+
+	util.InfoLog("  Skipped by rule:")
+	for _, rule := range rules {
+		util.InfoLog("    %-40s %d", rule, skipped[rule])
+	}
+}
@@ -49,9 +49,9 @@ func runShow(cmd *cobra.Command, args []string) error {
 	dirsOnly, _ := cmd.Flags().GetBool("dirs-only")
 
 	// Open database
-	db, err := store.Open(dbPath)
+	db, err := openReadOnlyDB(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -77,6 +77,12 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get clusters: %w", err)
 	}
 
+	// Clusters with manual overrides (mlc cluster merge/split/pin/exclude)
+	overridden, err := db.GetOverriddenClusterKeys()
+	if err != nil {
+		return fmt.Errorf("failed to get cluster overrides: %w", err)
+	}
+
 	util.InfoLog("=== Execution Plan ===")
 	util.InfoLog("Database: %s", dbPath)
 	util.InfoLog("")
@@ -144,11 +150,17 @@ func runShow(cmd *cobra.Command, args []string) error {
 			util.WarnLog("Duplicate Cluster: %s", cluster.Hint)
 			util.InfoLog("Cluster Key: %s", cluster.ClusterKey)
 			util.InfoLog("Files: %d", len(members))
+			if kinds, ok := overridden[cluster.ClusterKey]; ok {
+				util.WarnLog("Manual override: %s", strings.Join(kinds, ", "))
+			}
 			fmt.Println()
 		} else {
 			singletonCount++
 			if !duplicatesOnly {
 				fmt.Println()
+				if kinds, ok := overridden[cluster.ClusterKey]; ok {
+					util.WarnLog("Manual override: %s (cluster %s)", strings.Join(kinds, ", "), cluster.ClusterKey)
+				}
 			}
 		}
 
@@ -185,6 +197,9 @@ func runShow(cmd *cobra.Command, args []string) error {
 
 			// Source full path
 			fmt.Printf("     Source: %s\n", file.SrcPath)
+			if verbose {
+				fmt.Printf("     File ID: %d\n", file.ID)
+			}
 
 			// Destination
 			if plan.Action == "skip" {
@@ -216,6 +231,11 @@ func runShow(cmd *cobra.Command, args []string) error {
 				fmt.Println()
 			}
 
+			// Fuzzy matches are less certain than exact key matches
+			if member.Confidence < 1.0 {
+				fmt.Printf("     Match:  fuzzy (%.0f%% similar)\n", member.Confidence*100)
+			}
+
 			// Verbose metadata
 			if verbose && metadata != nil {
 				if metadata.TagArtist != "" {
@@ -244,6 +264,9 @@ func runShow(cmd *cobra.Command, args []string) error {
 	util.InfoLog("Total clusters: %d", len(clusters))
 	util.InfoLog("  Singletons: %d", singletonCount)
 	util.InfoLog("  Duplicates: %d", duplicateCount)
+	if len(overridden) > 0 {
+		util.InfoLog("  With manual overrides: %d", len(overridden))
+	}
 	fmt.Println()
 
 	if !winnersOnly {
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/franz/music-janitor/internal/execute"
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/musicbrainz"
+	"github.com/franz/music-janitor/internal/plan"
+	"github.com/franz/music-janitor/internal/report"
+	"github.com/franz/music-janitor/internal/scan"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/franz/music-janitor/internal/watch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch the sources and ingest new files as they arrive",
+	Long: `Watch the source roots and keep the plan up to date as files arrive.
+
+A new or changed file is picked up once its size and modification time
+have not changed for --settle (default 30s), so downloads and copies in
+progress are left alone. Each batch of settled files is scanned, its
+metadata extracted, and the affected clusters re-clustered, rescored,
+integrity-checked (config 'integrity') and replanned incrementally, exactly
+as 'mlc plan' does. With --execute the new plans are executed too.
+
+On start, the sources are scanned once to catch files that arrived while
+watch was not running (disable with --initial-scan=false).
+
+Scan filters (include, exclude, junk_presets, min_file_size, archives) and
+plan settings (destination, mode, feat_credits, ...) come from the config
+file. Artist names are resolved with the alias map and, when MusicBrainz is
+enabled, with cached MusicBrainz names only, so ingesting never waits on
+rate-limited lookups.
+
+Events go to the event log like those of the other commands. Stop with Ctrl+C.`,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().Duration("settle", watch.DefaultSettle, "How long a file must stay unchanged before it is ingested")
+	watchCmd.Flags().Bool("execute", false, "Execute new plans after each batch")
+	watchCmd.Flags().Bool("initial-scan", true, "Scan the sources once on start")
+	viper.BindPFlag("watch_settle", watchCmd.Flags().Lookup("settle"))
+	viper.BindPFlag("watch_execute", watchCmd.Flags().Lookup("execute"))
+	viper.BindPFlag("watch_initial_scan", watchCmd.Flags().Lookup("initial-scan"))
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sources, err := loadSources(cmd)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("source directory is required (use --source/-s, or set source or sources in config)")
+	}
+
+	dest := viper.GetString("destination")
+	if dest == "" {
+		return fmt.Errorf("destination directory is required (use --dest/-d or set in config)")
+	}
+
+	mode := viper.GetString("mode")
+	if mode == "" {
+		mode = "copy"
+	}
+	validModes := map[string]bool{"copy": true, "move": true, "hardlink": true, "symlink": true}
+	if !validModes[mode] {
+		return fmt.Errorf("invalid mode: %s (must be one of: copy, move, hardlink, symlink)", mode)
+	}
+
+	featCredits := viper.GetString("feat_credits")
+	if !plan.ValidFeatCredits(featCredits) {
+		return fmt.Errorf("invalid feat_credits: %s (must be one of: title, artist, drop, keep)", featCredits)
+	}
+
+	fuzzyThreshold := viper.GetFloat64("fuzzy_threshold")
+	if fuzzyThreshold < 0 || fuzzyThreshold > 1 {
+		return fmt.Errorf("invalid fuzzy threshold: %.2f (must be between 0 and 1)", fuzzyThreshold)
+	}
+
+	integrityMode, err := integrityModeFromConfig()
+	if err != nil {
+		return err
+	}
+
+	classifier, err := loadClassifier()
+	if err != nil {
+		return err
+	}
+
+	concurrency := viper.GetInt("concurrency")
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+
+	dbPath := viper.GetString("db")
+	verbose := viper.GetBool("verbose")
+	quiet := viper.GetBool("quiet")
+	dryRun := viper.GetBool("dry_run")
+
+	util.SetVerbose(verbose)
+	util.SetQuiet(quiet)
+
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+
+	for _, src := range sources {
+		if _, err := os.Stat(src.Path); os.IsNotExist(err) {
+			return fmt.Errorf("source directory does not exist: %s", src.Path)
+		}
+	}
+
+	util.InfoLog("Opening database: %s", dbPath)
+
+	dbNetworkOptimized := false
+	if dbInfo, err := util.DetectNetworkFilesystem(dbPath); err == nil && dbInfo.IsNetwork {
+		dbNetworkOptimized = true
+		util.InfoLog("Database on network storage (%s) - applying optimizations", dbInfo.Protocol)
+	}
+
+	db, err := store.OpenWithOptions(dbPath, &store.OpenOptions{
+		NetworkOptimized: dbNetworkOptimized,
+		Lock:             "watch",
+		BreakLock:        viper.GetBool("break_lock"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	logLevel := report.LevelInfo
+	if quiet {
+		logLevel = report.LevelWarning
+	} else if verbose {
+		logLevel = report.LevelDebug
+	}
+
+	logger, err := report.NewEventLogger("artifacts", logLevel)
+	if err != nil {
+		util.WarnLog("Failed to create event logger: %v", err)
+		logger = report.NullLogger()
+	}
+	defer logger.Close()
+
+	if logger.Path() != "" {
+		util.InfoLog("Event log: %s", logger.Path())
+	}
+
+	if !meta.CheckFFprobeAvailable() {
+		util.WarnLog("ffprobe not found in PATH - using tag library only")
+	}
+
+	// Artist name resolution without network lookups
+	var normalizer meta.NormalizerChain
+	aliases, err := loadAliasMap()
+	if err != nil {
+		return err
+	}
+	if aliases.Len() > 0 {
+		normalizer = append(normalizer, aliases)
+	}
+	if viper.GetBool("musicbrainz") || viper.GetBool("musicbrainz_offline") {
+		mbCache := musicbrainz.NewCache(db.DB(), nil)
+		if err := mbCache.EnsureSchema(); err != nil {
+			util.WarnLog("Failed to initialize MusicBrainz cache: %v", err)
+		} else {
+			normalizer = append(normalizer, mbCache.Offline())
+		}
+	}
+
+	scanner, err := newScanner(db, logger, concurrency)
+	if err != nil {
+		return err
+	}
+
+	p := &watchPipeline{
+		sources:     sources,
+		scanner:     scanner,
+		concurrency: concurrency,
+		execute:     viper.GetBool("watch_execute") && !dryRun,
+		pipeline: &pipelineConfig{
+			db:             db,
+			logger:         logger,
+			dest:           dest,
+			mode:           mode,
+			featCredits:    featCredits,
+			fuzzyThreshold: fuzzyThreshold,
+			integrityMode:  integrityMode,
+			normalizer:     normalizer,
+			classifier:     classifier,
+			rules:          rules,
+		},
+	}
+	if viper.GetBool("watch_execute") && dryRun {
+		util.InfoLog("Dry-run mode: new plans will not be executed")
+	}
+
+	roots := make([]string, 0, len(sources))
+	for _, src := range sources {
+		roots = append(roots, src.Path)
+	}
+
+	// Start watching before the initial scan so nothing arriving during it is missed
+	watcher, err := watch.New(&watch.Config{
+		Roots:      roots,
+		Settle:     viper.GetDuration("watch_settle"),
+		Interested: scanner.Handles,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if viper.GetBool("watch_initial_scan") {
+		util.InfoLog("=== Initial Scan ===")
+		initial := make(watch.Batch)
+		for _, root := range roots {
+			initial[filepath.Clean(root)] = []string{filepath.Clean(root)}
+		}
+		if err := p.ingest(ctx, initial); err != nil && ctx.Err() == nil {
+			util.ErrorLog("Initial scan failed: %v", err)
+		}
+	}
+
+	util.InfoLog("")
+	util.SuccessLog("Watching %d source(s) for new files (settle time %v, Ctrl+C to stop)",
+		len(roots), viper.GetDuration("watch_settle"))
+
+	if err := watcher.Run(ctx, p.ingest); err != nil {
+		return err
+	}
+	util.InfoLog("Stopped watching")
+	return nil
+}
+
+// watchPipeline runs the scan and metadata steps, the shared cluster, score,
+// integrity and plan pipeline, and (optionally) execution for each batch of
+// settled files
+type watchPipeline struct {
+	sources     []*scan.Source
+	scanner     *scan.Scanner
+	concurrency int
+	execute     bool
+	pipeline    *pipelineConfig
+}
+
+// ingest processes a batch of settled paths; a root listed as its own path is scanned completely
+func (p *watchPipeline) ingest(ctx context.Context, batch watch.Batch) error {
+	start := time.Now()
+	discovered, planned, err := p.run(ctx, batch)
+	p.pipeline.logger.LogWatch(batch.Len(), discovered, planned, time.Since(start), err)
+	return err
+}
+
+// run runs the pipeline and returns the number of files discovered and planned
+func (p *watchPipeline) run(ctx context.Context, batch watch.Batch) (int, int, error) {
+	// Phase 1: Discovery
+	changed := 0
+	for _, src := range p.sources {
+		root := filepath.Clean(src.Path)
+		paths, ok := batch[root]
+		if !ok {
+			continue
+		}
+
+		var result *scan.Result
+		var err error
+		if len(paths) == 1 && paths[0] == root {
+			result, err = p.scanner.ScanSource(ctx, src)
+		} else {
+			result, err = p.scanner.ScanPaths(ctx, src, paths)
+		}
+		if err != nil {
+			return changed, 0, fmt.Errorf("scan failed: %w", err)
+		}
+		changed += result.FilesDiscovered + result.FilesRelinked + result.FilesRestored
+	}
+	if changed == 0 {
+		util.DebugLog("No new files in %d changed paths", batch.Len())
+		return 0, 0, nil
+	}
+
+	// Phase 2: Metadata Extraction
+	extractor := meta.New(&meta.Config{
+		Store:       p.pipeline.db,
+		Concurrency: p.concurrency,
+		Logger:      p.pipeline.logger,
+		Rules:       p.pipeline.rules,
+	})
+	extractResult, err := extractor.Extract(ctx)
+	if err != nil {
+		return changed, 0, fmt.Errorf("metadata extraction failed: %w", err)
+	}
+
+	// Phase 3: Clustering, scoring, integrity check and planning of the changed clusters
+	result, err := runPipeline(ctx, p.pipeline)
+	if err != nil {
+		return changed, 0, err
+	}
+	planResult := result.plan
+	planned := planResult.WinnersPlanned + planResult.SingletonsPlanned
+
+	util.SuccessLog("Ingested %d new files: %d with metadata, %d planned, %d duplicates skipped",
+		changed, extractResult.Success, planned, planResult.DuplicatesSkipped)
+
+	// Phase 4: Execution
+	if !p.execute {
+		return changed, planned, nil
+	}
+
+	verifyMode := viper.GetString("verify")
+	if verifyMode == "" {
+		verifyMode = "size"
+	}
+	writeTags := viper.GetBool("write-tags")
+	if !viper.IsSet("write-tags") {
+		writeTags = true
+	}
+
+	executor := execute.New(&execute.Config{
+		Store:       p.pipeline.db,
+		Concurrency: p.concurrency,
+		VerifyMode:  verifyMode,
+		WriteTags:   writeTags,
+		Logger:      p.pipeline.logger,
+
+		Artwork:        viper.GetBool("artwork"),
+		EmbedArtwork:   viper.GetBool("artwork_embed"),
+		ArtworkMaxSize: viper.GetInt("artwork_max_size"),
+
+		WriteInferredTags: viper.GetBool("write_inferred_tags"),
+	})
+	execResult, err := executor.Execute(ctx)
+	if err != nil {
+		return changed, planned, fmt.Errorf("execution failed: %w", err)
+	}
+	if execResult.Failed > 0 {
+		util.WarnLog("Executed %d files, %d failed (retry with 'mlc execute')", execResult.Succeeded, execResult.Failed)
+	} else {
+		util.SuccessLog("Executed %d files (%s)", execResult.Succeeded, util.FormatBytes(execResult.BytesWritten))
+	}
+	return changed, planned, nil
+}
@@ -0,0 +1,281 @@
+package classify
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Content classes
+const (
+	Music     = "music"
+	Audiobook = "audiobook"
+	Podcast   = "podcast"
+	Mix       = "mix"    // DJ mixes and sets
+	Spoken    = "spoken" // Spoken word, lectures, radio plays
+)
+
+// Classes lists every content class
+var Classes = []string{Music, Audiobook, Podcast, Mix, Spoken}
+
+// Valid reports whether class is a known content class
+func Valid(class string) bool {
+	for _, c := range Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Defaults for the duration signals
+const (
+	DefaultMixMinDuration       = 20 * time.Minute
+	DefaultAudiobookMinChapters = 3
+)
+
+// Rule decides how files of one class are detected beyond the built-in
+// signals, deduplicated and placed
+type Rule struct {
+	// Layout is the destination path template below the destination root,
+	// without extension, e.g. "Podcasts/{album}/{date} - {title}".
+	// Empty for music means the regular Artist/Year - Album layout.
+	Layout string
+
+	// Dedup clusters duplicates of this class; without it every file is
+	// planned on its own and only identical destination paths collide
+	Dedup bool
+
+	// Exclude leaves files of this class out of the plan
+	Exclude bool
+
+	// Genres and Folders are extra genre tag fragments and folder names
+	// (case-insensitive) that put a file in this class
+	Genres  []string
+	Folders []string
+}
+
+// DefaultRules are the rules of every class unless configured otherwise
+var DefaultRules = map[string]Rule{
+	Music:     {Dedup: true},
+	Audiobook: {Layout: "Audiobooks/{albumartist}/{album}/{disc}/{track} - {title}"},
+	Podcast:   {Layout: "Podcasts/{album}/{date} - {title}", Dedup: true},
+	Mix:       {Layout: "Mixes/{artist}/{year} - {title}", Dedup: true},
+	Spoken:    {Layout: "Spoken Word/{albumartist}/{album}/{disc}/{track} - {title}", Dedup: true},
+}
+
+// Built-in genre tag fragments (matched as lowercase substrings), in the order
+// the classes are tried: "Audiobook / Spoken" is an audiobook
+var defaultGenres = []struct {
+	class     string
+	fragments []string
+}{
+	{Podcast, []string{"podcast"}},
+	{Audiobook, []string{"audiobook", "audio book", "hörbuch", "hoerbuch", "livre audio", "audiolibro"}},
+	{Mix, []string{"dj mix", "dj-mix", "dj set", "mixtape", "continuous mix"}},
+	{Spoken, []string{"spoken", "speech", "lecture", "poetry", "radio play", "hörspiel", "hoerspiel", "audio drama"}},
+}
+
+// Built-in folder names (whole path components, lowercase)
+var defaultFolders = map[string][]string{
+	Podcast:   {"podcast", "podcasts"},
+	Audiobook: {"audiobook", "audiobooks", "audio books", "hörbücher", "hoerbuecher", "livres audio"},
+	Mix:       {"mixes", "dj mixes", "dj sets", "mixtapes"},
+	Spoken:    {"spoken word", "lectures", "speeches", "hörspiele", "hoerspiele", "radio plays"},
+}
+
+// Title and album fragments that mark a long file as a mix
+var mixTitleFragments = []string{
+	"dj mix", "dj-mix", "dj set", "live set", "mixtape", "continuous mix",
+	"essential mix", "boiler room", "radio show", "guest mix", "podcast mix",
+}
+
+// Classifier labels files with a content class
+type Classifier struct {
+	rules                map[string]Rule
+	mixMinDuration       time.Duration
+	audiobookMinChapters int
+}
+
+// Config holds classifier configuration
+type Config struct {
+	// Rules replaces the default rule of each class it lists
+	Rules map[string]Rule
+
+	// MixMinDuration is the minimum length of a mix recognized by its title or
+	// chapter markers (<=0: DefaultMixMinDuration)
+	MixMinDuration time.Duration
+
+	// AudiobookMinChapters is the number of chapter markers that makes a file
+	// without a genre an audiobook (<=0: DefaultAudiobookMinChapters)
+	AudiobookMinChapters int
+}
+
+// New creates a classifier
+func New(cfg *Config) (*Classifier, error) {
+	c := &Classifier{
+		rules:                make(map[string]Rule, len(DefaultRules)),
+		mixMinDuration:       cfg.MixMinDuration,
+		audiobookMinChapters: cfg.AudiobookMinChapters,
+	}
+	if c.mixMinDuration <= 0 {
+		c.mixMinDuration = DefaultMixMinDuration
+	}
+	if c.audiobookMinChapters <= 0 {
+		c.audiobookMinChapters = DefaultAudiobookMinChapters
+	}
+
+	for class, rule := range DefaultRules {
+		c.rules[class] = rule
+	}
+	for class, rule := range cfg.Rules {
+		if !Valid(class) {
+			return nil, fmt.Errorf("unknown content class: %s (must be one of: %s)", class, strings.Join(Classes, ", "))
+		}
+		c.rules[class] = rule
+	}
+	return c, nil
+}
+
+// Rule returns the rule of class; a nil classifier has the default rules
+func (c *Classifier) Rule(class string) Rule {
+	if c == nil {
+		return DefaultRules[class]
+	}
+	return c.rules[class]
+}
+
+// Fingerprint identifies the classifier settings: signals and class rules.
+// A nil classifier has none.
+func (c *Classifier) Fingerprint() string {
+	if c == nil {
+		return ""
+	}
+	parts := []string{
+		fmt.Sprintf("mix_min_duration=%s", c.mixMinDuration),
+		fmt.Sprintf("audiobook_min_chapters=%d", c.audiobookMinChapters),
+	}
+	for _, class := range Classes {
+		rule := c.rules[class]
+		parts = append(parts, fmt.Sprintf("%s: layout=%s dedup=%t exclude=%t genres=%s folders=%s",
+			class, rule.Layout, rule.Dedup, rule.Exclude,
+			strings.Join(rule.Genres, "|"), strings.Join(rule.Folders, "|")))
+	}
+	return util.SettingsFingerprint(parts...)
+}
+
+// Result is the class of a file and the signal that decided it
+type Result struct {
+	Class  string
+	Reason string
+}
+
+// Classify labels a file by its tags, container, duration, chapter markers and
+// folder names. Explicit signals (podcast tags, genre, folders) win over
+// inferred ones (title keywords of long files, container, chapters).
+// A nil classifier labels everything music.
+func (c *Classifier) Classify(m *store.Metadata, srcPath string) Result {
+	if c == nil || m == nil {
+		return Result{Class: Music}
+	}
+
+	if m.PodcastID != "" {
+		return Result{Class: Podcast, Reason: "podcast tag"}
+	}
+
+	if genre := strings.ToLower(m.TagGenre); genre != "" {
+		for _, class := range Classes {
+			if fragment, ok := c.matchGenre(class, genre); ok {
+				return Result{Class: class, Reason: fmt.Sprintf("genre %q", fragment)}
+			}
+		}
+	}
+
+	if class, folder, ok := c.matchFolder(srcPath); ok {
+		return Result{Class: class, Reason: fmt.Sprintf("folder %q", folder)}
+	}
+
+	duration := time.Duration(m.DurationMs) * time.Millisecond
+	if duration >= c.mixMinDuration {
+		text := strings.ToLower(m.TagTitle + " " + m.TagAlbum)
+		for _, fragment := range mixTitleFragments {
+			if strings.Contains(text, fragment) {
+				return Result{Class: Mix, Reason: fmt.Sprintf("title %q", fragment)}
+			}
+		}
+	}
+
+	if strings.EqualFold(filepath.Ext(srcPath), ".m4b") {
+		return Result{Class: Audiobook, Reason: "m4b container"}
+	}
+
+	if m.Chapters >= c.audiobookMinChapters {
+		// Tracklists of mixes are chapters too; a genre tells them apart
+		if m.TagGenre == "" {
+			return Result{Class: Audiobook, Reason: fmt.Sprintf("%d chapters", m.Chapters)}
+		}
+		if duration >= c.mixMinDuration {
+			return Result{Class: Mix, Reason: fmt.Sprintf("%d chapters", m.Chapters)}
+		}
+	}
+
+	return Result{Class: Music}
+}
+
+// matchGenre returns the genre fragment of class found in genre
+func (c *Classifier) matchGenre(class, genre string) (string, bool) {
+	for _, fragment := range c.rules[class].Genres {
+		if fragment != "" && strings.Contains(genre, strings.ToLower(fragment)) {
+			return fragment, true
+		}
+	}
+	for _, g := range defaultGenres {
+		if g.class != class {
+			continue
+		}
+		for _, fragment := range g.fragments {
+			if strings.Contains(genre, fragment) {
+				return fragment, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchFolder returns the class of the innermost folder of srcPath with a
+// class folder name; an archive counts as a folder
+func (c *Classifier) matchFolder(srcPath string) (string, string, bool) {
+	dir := filepath.Dir(util.ArchiveFolderPath(srcPath))
+	for {
+		name := strings.ToLower(filepath.Base(dir))
+		for _, class := range Classes {
+			if c.isClassFolder(class, name) {
+				return class, filepath.Base(dir), true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// isClassFolder reports whether the lowercase folder name belongs to class
+func (c *Classifier) isClassFolder(class, name string) bool {
+	for _, folder := range c.rules[class].Folders {
+		if strings.ToLower(folder) == name {
+			return true
+		}
+	}
+	for _, folder := range defaultFolders[class] {
+		if folder == name {
+			return true
+		}
+	}
+	return false
+}
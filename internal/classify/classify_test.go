@@ -0,0 +1,138 @@
+package classify
+
+import (
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestClassify(t *testing.T) {
+	c, err := New(&Config{
+		Rules: map[string]Rule{
+			Spoken: {Dedup: true, Genres: []string{"Kabarett"}, Folders: []string{"Comedy"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	minutes := func(n int) int { return n * 60 * 1000 }
+
+	tests := []struct {
+		name      string
+		metadata  *store.Metadata
+		srcPath   string
+		wantClass string
+	}{
+		{
+			name:      "regular song",
+			metadata:  &store.Metadata{TagTitle: "Song", TagGenre: "Rock", DurationMs: minutes(4)},
+			srcPath:   "/music/Artist/Album/01 - Song.mp3",
+			wantClass: Music,
+		},
+		{
+			name:      "podcast tag",
+			metadata:  &store.Metadata{TagTitle: "Episode 12", PodcastID: "https://example.com/feed.xml", DurationMs: minutes(45)},
+			srcPath:   "/music/downloads/ep12.mp3",
+			wantClass: Podcast,
+		},
+		{
+			name:      "podcast genre",
+			metadata:  &store.Metadata{TagTitle: "Episode 3", TagGenre: "Podcast", DurationMs: minutes(30)},
+			srcPath:   "/music/ep3.mp3",
+			wantClass: Podcast,
+		},
+		{
+			name:      "audiobook genre wins over spoken",
+			metadata:  &store.Metadata{TagTitle: "Chapter 1", TagGenre: "Audiobook / Spoken", DurationMs: minutes(20)},
+			srcPath:   "/music/book/01.mp3",
+			wantClass: Audiobook,
+		},
+		{
+			name:      "configured genre",
+			metadata:  &store.Metadata{TagTitle: "Programm", TagGenre: "kabarett", DurationMs: minutes(50)},
+			srcPath:   "/music/show.mp3",
+			wantClass: Spoken,
+		},
+		{
+			name:      "built-in folder",
+			metadata:  &store.Metadata{TagTitle: "Part 1", DurationMs: minutes(10)},
+			srcPath:   "/music/Audiobooks/Author/Book/01.mp3",
+			wantClass: Audiobook,
+		},
+		{
+			name:      "configured folder",
+			metadata:  &store.Metadata{TagTitle: "Bit", DurationMs: minutes(5)},
+			srcPath:   "/music/comedy/Comedian/bit.mp3",
+			wantClass: Spoken,
+		},
+		{
+			name:      "archive counts as folder",
+			metadata:  &store.Metadata{TagTitle: "Episode", DurationMs: minutes(10)},
+			srcPath:   "/music/Podcasts.zip!/ep1.mp3",
+			wantClass: Podcast,
+		},
+		{
+			name:      "mix by title",
+			metadata:  &store.Metadata{TagTitle: "Essential Mix 2019-05-04", TagGenre: "Electronic", DurationMs: minutes(120)},
+			srcPath:   "/music/set.mp3",
+			wantClass: Mix,
+		},
+		{
+			name:      "short track with mix in title stays music",
+			metadata:  &store.Metadata{TagTitle: "Song (DJ Mix)", TagGenre: "House", DurationMs: minutes(6)},
+			srcPath:   "/music/song.mp3",
+			wantClass: Music,
+		},
+		{
+			name:      "m4b container",
+			metadata:  &store.Metadata{TagTitle: "Book", DurationMs: minutes(600)},
+			srcPath:   "/music/book.m4b",
+			wantClass: Audiobook,
+		},
+		{
+			name:      "chapters without genre",
+			metadata:  &store.Metadata{TagTitle: "Book", Chapters: 12, DurationMs: minutes(300)},
+			srcPath:   "/music/book.mp3",
+			wantClass: Audiobook,
+		},
+		{
+			name:      "chapters with music genre",
+			metadata:  &store.Metadata{TagTitle: "Live at Fabric", TagGenre: "Techno", Chapters: 15, DurationMs: minutes(75)},
+			srcPath:   "/music/fabric.mp3",
+			wantClass: Mix,
+		},
+		{
+			name:      "very long file is still music",
+			metadata:  &store.Metadata{TagTitle: "Untitled", TagGenre: "Ambient", DurationMs: minutes(90)},
+			srcPath:   "/music/untitled.flac",
+			wantClass: Music,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Classify(tt.metadata, tt.srcPath)
+			if got.Class != tt.wantClass {
+				t.Errorf("Classify() = %q (%s), want %q", got.Class, got.Reason, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestClassifyNil(t *testing.T) {
+	var c *Classifier
+	got := c.Classify(&store.Metadata{TagGenre: "Podcast"}, "/music/ep.mp3")
+	if got.Class != Music {
+		t.Errorf("nil Classify() = %q, want %q", got.Class, Music)
+	}
+	if rule := c.Rule(Audiobook); rule.Layout != DefaultRules[Audiobook].Layout {
+		t.Errorf("nil Rule() layout = %q, want default", rule.Layout)
+	}
+}
+
+func TestNewRejectsUnknownClass(t *testing.T) {
+	if _, err := New(&Config{Rules: map[string]Rule{"ringtone": {}}}); err == nil {
+		t.Error("New() with unknown class succeeded, want error")
+	}
+}
@@ -8,35 +8,101 @@ import (
 	"strings"
 	"time"
 
+	"github.com/franz/music-janitor/internal/classify"
 	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/report"
 	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 )
 
+// Repo is the state clustering reads and writes; plans of files that leave
+// their cluster are dropped
+type Repo interface {
+	store.FileRepo
+	store.MetadataRepo
+	store.ClusterRepo
+	store.ClusterStateRepo
+	store.PlanRepo
+	store.FingerprintRepo
+}
+
 // Clusterer groups files into duplicate clusters
 type Clusterer struct {
-	store          *store.Store
+	store          Repo
 	logger         *report.EventLogger
-	forceRecluster bool
+	forceRecluster      bool
+	fuzzyThreshold      float64
+	durationToleranceMs int
+
+	normalizer    meta.MusicBrainzNormalizer
+	transliterate bool
+	classifier    *classify.Classifier
+
+	noAutoRecluster bool
 }
 
 // Config holds clusterer configuration
 type Config struct {
-	Store          *store.Store
+	Store          Repo
 	Logger         *report.EventLogger
-	ForceRecluster bool // If true, discards resume state and starts fresh
+	ForceRecluster      bool    // If true, discards resume state and starts fresh
+	FuzzyThreshold      float64 // Minimum title/artist similarity for fuzzy merges (0 disables)
+	DurationToleranceMs int     // Max duration difference for linking across buckets (default 1500)
+
+	// Normalizer resolves artist aliases in cluster keys, e.g. a
+	// meta.NormalizerChain of alias map and MusicBrainz (nil: local rules only)
+	Normalizer meta.MusicBrainzNormalizer
+
+	// Transliterate compares artists and titles by their romanized, ASCII form
+	// ("Кино" matches "Kino"); cluster keys keep the original script
+	Transliterate bool
+
+	// Classifier keeps audiobooks, podcasts, mixes and spoken word out of music
+	// clusters, and each file of a class without dedup in its own cluster
+	// (nil: everything is music)
+	Classifier *classify.Classifier
+
+	// NoAutoRecluster only warns when the settings that shape cluster keys
+	// changed since the clusters were built, instead of re-clustering all files
+	NoAutoRecluster bool
 }
 
+// keyVersion identifies the cluster key algorithm; bump it whenever
+// GenerateClusterKey or clusterKey change, so existing keys are rebuilt
+const keyVersion = "3"
+
 // New creates a new Clusterer
 func New(cfg *Config) *Clusterer {
+	durationTolerance := cfg.DurationToleranceMs
+	if durationTolerance <= 0 {
+		durationTolerance = DefaultDurationToleranceMs
+	}
+
 	return &Clusterer{
-		store:          cfg.Store,
-		logger:         cfg.Logger,
-		forceRecluster: cfg.ForceRecluster,
+		store:               cfg.Store,
+		logger:              cfg.Logger,
+		forceRecluster:      cfg.ForceRecluster,
+		fuzzyThreshold:      cfg.FuzzyThreshold,
+		durationToleranceMs: durationTolerance,
+		normalizer:          cfg.Normalizer,
+		transliterate:       cfg.Transliterate,
+		classifier:          cfg.Classifier,
+		noAutoRecluster:     cfg.NoAutoRecluster,
 	}
 }
 
+// fingerprint identifies the settings cluster keys are built with
+func (c *Clusterer) fingerprint() string {
+	return util.SettingsFingerprint(
+		"keys="+keyVersion,
+		"normalizer="+meta.NormalizerFingerprint(c.normalizer),
+		fmt.Sprintf("fuzzy=%g", c.fuzzyThreshold),
+		fmt.Sprintf("duration_tolerance=%d", c.durationToleranceMs),
+		fmt.Sprintf("transliterate=%t", c.transliterate),
+		"classes="+c.classifier.Fingerprint(),
+	)
+}
+
 // Result represents clustering results
 type Result struct {
 	ClustersCreated  int
@@ -44,6 +110,27 @@ type Result struct {
 	DuplicateClusters int
 	FilesGrouped      int
 	Errors            []error
+
+	// Incremental run details (only set when existing clusters were updated)
+	Incremental     bool
+	FilesAdded      int
+	FilesMoved      int
+	FilesRemoved    int
+	ClustersTouched int
+
+	// Files merged into another cluster by duration linking across buckets
+	DurationLinked int
+
+	// Files merged into another cluster by fuzzy title/artist matching
+	FuzzyMerged int
+
+	// Files moved by MusicBrainz recording ID / ISRC reconciliation, and
+	// identifier disagreements found (see store.IdentityConflict)
+	IdentityMoved     int
+	IdentityConflicts int
+
+	// Files moved or excluded by manual overrides
+	OverridesApplied int
 }
 
 // Cluster performs duplicate detection clustering
@@ -65,27 +152,51 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 	var resuming bool
 	var lastProcessedID int64
 
-	// If clusters exist and force-recluster is not set, skip clustering
-	if clusterCount > 0 && !c.forceRecluster && progress == nil {
-		util.InfoLog("Clustering already complete (%d clusters exist)", clusterCount)
-		util.InfoLog("Use --force-recluster to re-cluster from scratch")
+	// Keys built with other settings (aliases, thresholds, ...) are all stale
+	fingerprint := c.fingerprint()
+	forceRecluster := c.forceRecluster
+	if (clusterCount > 0 || progress != nil) && !forceRecluster {
+		stored, err := c.store.GetFingerprint(store.FingerprintClusterKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read clustering settings: %w", err)
+		}
+		if stored != fingerprint {
+			if c.noAutoRecluster {
+				util.WarnLog("Clustering settings changed since the clusters were built; run with --force-recluster to rebuild them")
+			} else {
+				util.WarnLog("Clustering settings changed since the clusters were built; re-clustering all files")
+				if c.logger != nil {
+					c.logger.LogSettingsChanged(store.FingerprintClusterKeys, "auto_recluster")
+				}
+				forceRecluster = true
+			}
+		}
+	}
 
-		// Return current cluster stats
-		duplicateCount, _ := c.store.CountDuplicateClusters()
-		return &Result{
-			ClustersCreated:   clusterCount,
-			SingletonClusters: clusterCount - duplicateCount,
-			DuplicateClusters: duplicateCount,
-		}, nil
+	// If clusters exist and force-recluster is not set, only cluster new or changed files
+	if clusterCount > 0 && !forceRecluster && progress == nil {
+		result, err := c.clusterIncremental(ctx)
+		if err != nil {
+			return result, err
+		}
+		if err := c.reconcileIdentities(ctx, nil, result); err != nil {
+			return result, err
+		}
+		if result.IdentityMoved > 0 {
+			if result, err = c.fillTotals(result); err != nil {
+				return result, err
+			}
+		}
+		return c.applyOverridesToResult(ctx, result)
 	}
 
-	if progress != nil && !c.forceRecluster {
+	if progress != nil && !forceRecluster {
 		// Resume from previous incomplete run
 		resuming = true
 		lastProcessedID = progress.LastProcessedFileID
 		util.InfoLog("Resuming clustering from file ID %d (%d/%d files processed)",
 			lastProcessedID, progress.FilesProcessed, progress.TotalFiles)
-	} else if c.forceRecluster {
+	} else if forceRecluster {
 		// Force recluster - clear everything
 		util.InfoLog("Force re-clustering: clearing previous state")
 		if err := c.store.ClearClusters(); err != nil {
@@ -118,6 +229,10 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 		if err := c.store.InitClusteringProgress(len(files)); err != nil {
 			return nil, fmt.Errorf("failed to init progress: %w", err)
 		}
+		// The clusters about to be built, and any run resumed from them, use these settings
+		if err := c.store.SetFingerprint(store.FingerprintClusterKeys, fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to save clustering settings: %w", err)
+		}
 	}
 
 	result := &Result{
@@ -126,6 +241,7 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 
 	// Group files by cluster key
 	clusterMap := make(map[string][]*store.File)
+	durations := make(map[int64]int)
 
 	// If resuming, rebuild cluster map from existing clusters
 	if resuming {
@@ -151,6 +267,9 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 				}
 				if file != nil {
 					clusterMap[cluster.ClusterKey] = append(clusterMap[cluster.ClusterKey], file)
+					if metadata, _ := c.store.GetMetadata(file.ID); metadata != nil {
+						durations[file.ID] = metadata.DurationMs
+					}
 				}
 			}
 		}
@@ -224,10 +343,11 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 		}
 
 		// Generate cluster key (pass source path for filename fallback)
-		clusterKey := GenerateClusterKey(metadata, file.SrcPath)
+		clusterKey := c.clusterKey(metadata, file)
 
 		// Add to cluster map
 		clusterMap[clusterKey] = append(clusterMap[clusterKey], file)
+		durations[file.ID] = metadata.DurationMs
 		result.FilesGrouped++
 		processed++
 
@@ -246,6 +366,20 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 
 	util.InfoLog("Grouped %d files into %d potential clusters", result.FilesGrouped, len(clusterMap))
 
+	// Join clusters split by a duration bucket boundary (e.g. 181.4s vs 181.6s)
+	c.linkDurationClusterMap(clusterMap, durations, result)
+
+	// Second pass: merge near-identical keys (typos, diacritics, punctuation)
+	confidence := c.fuzzyMergeClusterMap(clusterMap, result)
+
+	// Remember each file's heuristic key; identifiers and overrides may move it later
+	heuristic := make(map[int64]string, result.FilesGrouped)
+	for clusterKey, members := range clusterMap {
+		for _, file := range members {
+			heuristic[file.ID] = clusterKey
+		}
+	}
+
 	// Insert clusters and members using batch operations
 	util.InfoLog("Writing clusters to database...")
 
@@ -295,6 +429,7 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 				FileID:       file.ID,
 				QualityScore: 0, // Will be set by scorer
 				Preferred:    false,
+				Confidence:   confidence[file.ID],
 			}
 			allMembers = append(allMembers, member)
 		}
@@ -368,6 +503,29 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 	util.SuccessLog("Clustering complete: %d clusters created (%d singletons, %d duplicates)",
 		result.ClustersCreated, result.SingletonClusters, result.DuplicateClusters)
 
+	// Recording identifiers (MBID, ISRC) override the heuristic clusters
+	if err := c.reconcileIdentities(ctx, heuristic, result); err != nil {
+		return result, err
+	}
+	if result.IdentityMoved > 0 {
+		if result, err = c.fillTotals(result); err != nil {
+			return result, err
+		}
+	}
+
+	// Apply manual overrides on top of the automatic pass
+	result, err = c.applyOverridesToResult(ctx, result)
+	if err != nil {
+		return result, err
+	}
+
+	// Record clustered files so later runs only process new or changed files
+	if err := c.store.SyncClusteredFilesFromMembers(); err != nil {
+		util.WarnLog("Failed to record clustered files: %v", err)
+	} else if err := c.store.SetHeuristicKeys(heuristic); err != nil {
+		util.WarnLog("Failed to record heuristic cluster keys: %v", err)
+	}
+
 	// Clear progress tracking since we completed successfully
 	if err := c.store.ClearClusteringProgress(); err != nil {
 		util.WarnLog("Failed to clear clustering progress: %v", err)
@@ -379,6 +537,9 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 // GenerateClusterKey creates a cluster key from metadata
 // Key format: artist_norm|title_base|version_type|duration_bucket
 //
+// Only the primary artist is keyed, and feature credits are removed from the
+// title, so "A feat. B - Song", "A - Song (ft. B)" and "A - Song" cluster together.
+//
 // The version_type separates different artistic works:
 //   - "studio" = original studio recording (includes remasters, deluxe editions)
 //   - "remix" = remixed versions (radio edit, club mix, etc.)
@@ -386,18 +547,27 @@ func (c *Clusterer) Cluster(ctx context.Context) (*Result, error) {
 //   - "acoustic" = acoustic/unplugged versions
 //   - "demo" = demo recordings
 //   - "instrumental" = instrumental/karaoke versions
+//   - "video" = music videos (by file type, whatever the title says)
 //
 // Duration bucketing naturally separates versions with different lengths,
 // while version_type ensures separation even when durations are similar.
 func GenerateClusterKey(m *store.Metadata, srcPath string) string {
+	return GenerateClusterKeyWith(nil, m, srcPath)
+}
+
+// GenerateClusterKeyWith creates a cluster key like GenerateClusterKey,
+// resolving the artist to its canonical name with n when set
+func GenerateClusterKeyWith(n meta.MusicBrainzNormalizer, m *store.Metadata, srcPath string) string {
+	credits := meta.CreditsOf(m)
+
 	// Normalize artist and title
-	artistNorm := meta.NormalizeArtist(m.TagArtist)
+	artistNorm := meta.NormalizeArtistWith(n, credits.Primary)
 
 	// Detect version type BEFORE normalizing title (need original text)
-	versionType := meta.DetectVersionType(m.TagTitle)
+	versionType := meta.DetectVersionType(credits.Title)
 
 	// Normalize title (this removes ALL version suffixes for base title)
-	titleNorm := meta.NormalizeTitle(m.TagTitle)
+	titleNorm := meta.NormalizeTitle(credits.Title)
 
 	// If both are empty, use filename to prevent false duplicates
 	// Files without metadata should only cluster if they have the same filename
@@ -420,6 +590,12 @@ func GenerateClusterKey(m *store.Metadata, srcPath string) string {
 		}
 	}
 
+	// A music video is never a duplicate of the audio release, only of other
+	// copies of the video
+	if m.HasVideo {
+		versionType = "video"
+	}
+
 	// Duration bucket (±1.5s tolerance)
 	// Round to nearest 3-second bucket to group similar durations
 	durationBucket := bucketDuration(m.DurationMs)
@@ -438,8 +614,29 @@ func GenerateClusterKey(m *store.Metadata, srcPath string) string {
 	return fmt.Sprintf("%s|%s|%s|%d|disc%d|track%d", artistNorm, titleNorm, versionType, durationBucket, discNum, trackNum)
 }
 
+// clusterKey keys a file like GenerateClusterKeyWith, in place of the version
+// type putting the content class of files that aren't music, so they only match
+// their own class. Files of a class without dedup get a key of their own.
+func (c *Clusterer) clusterKey(m *store.Metadata, file *store.File) string {
+	key := GenerateClusterKeyWith(c.normalizer, m, file.SrcPath)
+	class := c.classifier.Classify(m, file.SrcPath).Class
+	if class == classify.Music {
+		return key
+	}
+
+	if parts, ok := splitClusterKey(key); ok {
+		parts[len(parts)-4] = class
+		key = strings.Join(parts, "|")
+	}
+	if !c.classifier.Rule(class).Dedup {
+		key = fmt.Sprintf("%s|file%d", key, file.ID)
+	}
+	return key
+}
+
 // bucketDuration rounds duration to nearest 3-second bucket
-// This allows files with durations within ±1.5s to cluster together
+// Files on either side of a bucket boundary (e.g. 181.4s and 181.6s) get different
+// buckets; linkDurationKeys joins them when their real durations are within tolerance
 func bucketDuration(durationMs int) int {
 	if durationMs <= 0 {
 		return 0
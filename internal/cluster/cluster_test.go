@@ -1,8 +1,13 @@
 package cluster
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/franz/music-janitor/internal/classify"
+	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/store"
 )
 
@@ -283,6 +288,27 @@ func TestGenerateClusterKey_EdgeCases(t *testing.T) {
 			srcPath:  "/music/song.mp3",
 			expected: "artist|epic song|studio|3600|disc0|track0",
 		},
+		{
+			name: "music video",
+			metadata: &store.Metadata{
+				TagArtist:  "Artist",
+				TagTitle:   "Title (Live)",
+				DurationMs: 240000,
+				HasVideo:   true,
+			},
+			srcPath:  "/music/videos/Title.mkv",
+			expected: "artist|title|video|240|disc0|track0",
+		},
+		{
+			name: "audio-only mp4",
+			metadata: &store.Metadata{
+				TagArtist:  "Artist",
+				TagTitle:   "Title",
+				DurationMs: 240000,
+			},
+			srcPath:  "/music/Title.mp4",
+			expected: "artist|title|studio|240|disc0|track0",
+		},
 		{
 			name: "special characters in tags",
 			metadata: &store.Metadata{
@@ -293,6 +319,47 @@ func TestGenerateClusterKey_EdgeCases(t *testing.T) {
 			srcPath:  "/music/song.mp3",
 			expected: "acdc|rock and roll|studio|180|disc0|track0",
 		},
+		{
+			name: "featured artist in artist tag",
+			metadata: &store.Metadata{
+				TagArtist:  "Calvin Harris feat. Rihanna",
+				TagTitle:   "We Found Love",
+				DurationMs: 215000,
+			},
+			srcPath:  "/music/song.mp3",
+			expected: "calvin harris|we found love|studio|216|disc0|track0",
+		},
+		{
+			name: "featured artist in title",
+			metadata: &store.Metadata{
+				TagArtist:  "Calvin Harris",
+				TagTitle:   "We Found Love (ft. Rihanna)",
+				DurationMs: 215000,
+			},
+			srcPath:  "/music/song.mp3",
+			expected: "calvin harris|we found love|studio|216|disc0|track0",
+		},
+		{
+			name: "collaboration keys on primary artist",
+			metadata: &store.Metadata{
+				TagArtist:  "Skrillex x Diplo; Justin Bieber",
+				TagArtists: "Skrillex; Diplo; Justin Bieber",
+				TagTitle:   "Where Are U Now",
+				DurationMs: 250000,
+			},
+			srcPath:  "/music/song.mp3",
+			expected: "skrillex|where are u now|studio|249|disc0|track0",
+		},
+		{
+			name: "duo without ARTISTS keys on the whole credit",
+			metadata: &store.Metadata{
+				TagArtist:  "Simon & Garfunkel",
+				TagTitle:   "The Boxer",
+				DurationMs: 310000,
+			},
+			srcPath:  "/music/song.mp3",
+			expected: "simon and garfunkel|the boxer|studio|309|disc0|track0",
+		},
 		{
 			name: "empty filename fallback",
 			metadata: &store.Metadata{
@@ -399,3 +466,418 @@ func TestNormalizeForClustering_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestIncrementalClustering(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := store.Open(tmpDir + "/test.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	addFile := func(n int, title string) *store.File {
+		f := &store.File{
+			FileKey: fmt.Sprintf("key-%d", n),
+			SrcPath: fmt.Sprintf("/src/%d.mp3", n),
+			Status:  "meta_ok",
+		}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("Failed to insert file %d: %v", n, err)
+		}
+		m := &store.Metadata{FileID: f.ID, TagArtist: "Artist", TagTitle: title, TagTrack: 1, DurationMs: 180000}
+		if err := db.InsertMetadata(m); err != nil {
+			t.Fatalf("Failed to insert metadata %d: %v", n, err)
+		}
+		return f
+	}
+
+	file1 := addFile(1, "Song")
+	addFile(2, "Song")
+
+	// First run clusters everything
+	result, err := New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Initial clustering failed: %v", err)
+	}
+	if result.Incremental || result.ClustersCreated != 1 {
+		t.Fatalf("Expected full run with 1 cluster, got incremental=%v clusters=%d", result.Incremental, result.ClustersCreated)
+	}
+	candidates, _ := db.GetClusteringCandidates()
+	if len(candidates) != 0 {
+		t.Errorf("Expected no candidates after full run, got %d", len(candidates))
+	}
+
+	// New files only touch their clusters
+	addFile(3, "Song")
+	file4 := addFile(4, "Other Song")
+
+	result, err = New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if !result.Incremental || result.FilesAdded != 2 || result.ClustersTouched != 2 {
+		t.Errorf("Expected 2 files added to 2 clusters, got incremental=%v added=%d touched=%d",
+			result.Incremental, result.FilesAdded, result.ClustersTouched)
+	}
+	dirty, _ := db.GetDirtyClusters()
+	if len(dirty) != 2 {
+		t.Errorf("Expected 2 dirty clusters, got %d", len(dirty))
+	}
+	if err := db.ClearDirtyClusters(); err != nil {
+		t.Fatalf("Failed to clear dirty clusters: %v", err)
+	}
+
+	// Changed metadata moves the file and drops its emptied cluster
+	if err := db.InsertMetadata(&store.Metadata{FileID: file4.ID, TagArtist: "Artist", TagTitle: "Song", TagTrack: 1, DurationMs: 180000}); err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+	if err := db.UpdateFileStatus(file4.ID, "meta_ok", ""); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+
+	result, err = New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if result.FilesMoved != 1 || result.ClustersCreated != 1 || result.ClustersTouched != 1 {
+		t.Errorf("Expected 1 file moved into 1 remaining cluster, got moved=%d clusters=%d touched=%d",
+			result.FilesMoved, result.ClustersCreated, result.ClustersTouched)
+	}
+
+	// Files that fail re-extraction leave their cluster
+	if err := db.UpdateFileStatus(file1.ID, "error", "unreadable"); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+
+	result, err = New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if result.FilesRemoved != 1 {
+		t.Errorf("Expected 1 file removed, got %d", result.FilesRemoved)
+	}
+	keys, _ := db.GetFileClusterKeys()
+	if _, ok := keys[file1.ID]; ok {
+		t.Error("Expected removed file to have no cluster membership")
+	}
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 clustered files, got %d", len(keys))
+	}
+
+	// Nothing changed: no work and no dirty clusters
+	if err := db.ClearDirtyClusters(); err != nil {
+		t.Fatalf("Failed to clear dirty clusters: %v", err)
+	}
+	result, err = New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if result.FilesAdded+result.FilesMoved+result.FilesRemoved != 0 {
+		t.Errorf("Expected no changes, got %+v", result)
+	}
+}
+
+func TestClusterSettingsChange(t *testing.T) {
+	db := store.NewMemory()
+	ctx := context.Background()
+
+	for i, artist := range []string{"Puff Daddy", "Diddy"} {
+		f := &store.File{FileKey: fmt.Sprintf("key-%d", i), SrcPath: fmt.Sprintf("/src/%d.mp3", i), Status: "meta_ok"}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("Failed to insert file: %v", err)
+		}
+		if err := db.InsertMetadata(&store.Metadata{FileID: f.ID, TagArtist: artist, TagTitle: "Come with Me", DurationMs: 292000}); err != nil {
+			t.Fatalf("Failed to insert metadata: %v", err)
+		}
+	}
+	aliases, err := meta.NewAliasMap(map[string][]string{"Diddy": {"Puff Daddy"}})
+	if err != nil {
+		t.Fatalf("NewAliasMap failed: %v", err)
+	}
+
+	run := func(cfg Config) *Result {
+		t.Helper()
+		cfg.Store = db
+		result, err := New(&cfg).Cluster(ctx)
+		if err != nil {
+			t.Fatalf("Cluster() error: %v", err)
+		}
+		return result
+	}
+	expect := func(step string, result *Result, incremental bool, duplicates int) {
+		t.Helper()
+		if result.Incremental != incremental || result.DuplicateClusters != duplicates {
+			t.Errorf("%s: incremental=%v duplicates=%d, want incremental=%v duplicates=%d",
+				step, result.Incremental, result.DuplicateClusters, incremental, duplicates)
+		}
+	}
+
+	expect("first run", run(Config{}), false, 0)
+	expect("same settings", run(Config{}), true, 0)
+
+	// Adding an alias re-keys every file, unless automatic re-clustering is off
+	expect("alias without auto re-cluster", run(Config{Normalizer: aliases, NoAutoRecluster: true}), true, 0)
+	expect("alias added", run(Config{Normalizer: aliases}), false, 1)
+	expect("alias unchanged", run(Config{Normalizer: aliases}), true, 1)
+	expect("fuzzy threshold changed", run(Config{Normalizer: aliases, FuzzyThreshold: 0.9}), false, 1)
+
+	// Clusters built before settings were recorded are rebuilt once
+	if err := db.SetFingerprint(store.FingerprintClusterKeys, ""); err != nil {
+		t.Fatalf("SetFingerprint() error: %v", err)
+	}
+	expect("no recorded settings", run(Config{Normalizer: aliases, FuzzyThreshold: 0.9}), false, 1)
+	expect("after upgrade", run(Config{Normalizer: aliases, FuzzyThreshold: 0.9}), true, 1)
+
+	// Class rules shape the keys too: spoken word from this folder is not deduplicated
+	spoken, err := classify.New(&classify.Config{Rules: map[string]classify.Rule{classify.Spoken: {Folders: []string{"src"}}}})
+	if err != nil {
+		t.Fatalf("classify.New failed: %v", err)
+	}
+	expect("class rules changed", run(Config{Normalizer: aliases, FuzzyThreshold: 0.9, Classifier: spoken}), false, 0)
+}
+
+func TestApplyOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := store.Open(tmpDir + "/test.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	var files []*store.File
+	for i, title := range []string{"Song", "Song", "Song", "Other Song", "Third Song"} {
+		f := &store.File{
+			FileKey: fmt.Sprintf("key-%d", i),
+			SrcPath: fmt.Sprintf("/src/%d.mp3", i),
+			Status:  "meta_ok",
+		}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("Failed to insert file %d: %v", i, err)
+		}
+		m := &store.Metadata{FileID: f.ID, TagArtist: "Artist", TagTitle: title, TagTrack: 1, DurationMs: 180000}
+		if err := db.InsertMetadata(m); err != nil {
+			t.Fatalf("Failed to insert metadata %d: %v", i, err)
+		}
+		files = append(files, f)
+	}
+
+	if _, err := New(&Config{Store: db}).Cluster(ctx); err != nil {
+		t.Fatalf("Initial clustering failed: %v", err)
+	}
+	keys, _ := db.GetFileClusterKeys()
+	songKey, otherKey, thirdKey := keys[files[0].ID], keys[files[3].ID], keys[files[4].ID]
+
+	overrides := []*store.ClusterOverride{
+		{Kind: store.OverrideSplit, ClusterKey: songKey, OtherKey: SplitClusterKey(songKey, files[2].ID), FileID: files[2].ID},
+		{Kind: store.OverrideMerge, ClusterKey: songKey, OtherKey: otherKey},
+		{Kind: store.OverrideExclude, ClusterKey: thirdKey, FileID: files[4].ID},
+	}
+	for _, o := range overrides {
+		if err := db.InsertClusterOverride(o); err != nil {
+			t.Fatalf("Failed to insert override: %v", err)
+		}
+	}
+
+	// Overrides must survive a forced re-cluster
+	result, err := New(&Config{Store: db, ForceRecluster: true}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Forced re-clustering failed: %v", err)
+	}
+	if result.OverridesApplied != 3 {
+		t.Errorf("Expected 3 files affected by overrides, got %d", result.OverridesApplied)
+	}
+
+	keys, _ = db.GetFileClusterKeys()
+	if keys[files[3].ID] != songKey {
+		t.Errorf("Expected merged file in %s, got %s", songKey, keys[files[3].ID])
+	}
+	if keys[files[2].ID] != SplitClusterKey(songKey, files[2].ID) {
+		t.Errorf("Expected split file in its own cluster, got %s", keys[files[2].ID])
+	}
+	if _, ok := keys[files[4].ID]; ok {
+		t.Error("Expected excluded file to have no cluster")
+	}
+	if c, _ := db.GetClusterByKey(otherKey); c != nil {
+		t.Error("Expected merged-away cluster to be deleted")
+	}
+
+	overridden, _ := db.GetOverriddenClusterKeys()
+	if len(overridden[songKey]) == 0 {
+		t.Error("Expected merge target to be flagged as overridden")
+	}
+
+	// Re-running applies nothing new and excluded files stay out
+	result, err = New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if result.OverridesApplied != 0 || result.FilesAdded != 0 {
+		t.Errorf("Expected stable result, got %d overrides applied, %d files added", result.OverridesApplied, result.FilesAdded)
+	}
+}
+
+// TestOverridesSurviveKeyChanges checks that splits and merges still apply when
+// an alias change re-keys every cluster
+func TestOverridesSurviveKeyChanges(t *testing.T) {
+	db := store.NewMemory()
+	ctx := context.Background()
+
+	n := 0
+	addFile := func(title string) *store.File {
+		n++
+		f := &store.File{FileKey: fmt.Sprintf("key-%d", n), SrcPath: fmt.Sprintf("/src/%d.mp3", n), Status: "meta_ok"}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("Failed to insert file: %v", err)
+		}
+		if err := db.InsertMetadata(&store.Metadata{FileID: f.ID, TagArtist: "Puff Daddy", TagTitle: title, DurationMs: 292000}); err != nil {
+			t.Fatalf("Failed to insert metadata: %v", err)
+		}
+		return f
+	}
+	song, live, other, third := addFile("Come with Me"), addFile("Come with Me"), addFile("Come with Me (Single Edit)"), addFile("Victory")
+
+	if _, err := New(&Config{Store: db}).Cluster(ctx); err != nil {
+		t.Fatalf("Initial clustering failed: %v", err)
+	}
+	keys, _ := db.GetFileClusterKeys()
+	songKey, otherKey, thirdKey := keys[song.ID], keys[other.ID], keys[third.ID]
+	splitKey := SplitClusterKey(songKey, live.ID)
+
+	for _, o := range []*store.ClusterOverride{
+		{Kind: store.OverrideSplit, ClusterKey: songKey, OtherKey: splitKey, FileID: live.ID},
+		{Kind: store.OverrideMerge, ClusterKey: songKey, OtherKey: otherKey, FileID: song.ID, OtherFileID: other.ID},
+	} {
+		if err := db.InsertClusterOverride(o); err != nil {
+			t.Fatalf("Failed to insert override: %v", err)
+		}
+	}
+
+	// New aliases re-key every cluster automatically
+	aliases, err := meta.NewAliasMap(map[string][]string{"Diddy": {"Puff Daddy"}})
+	if err != nil {
+		t.Fatalf("NewAliasMap failed: %v", err)
+	}
+	if _, err := New(&Config{Store: db, Normalizer: aliases}).Cluster(ctx); err != nil {
+		t.Fatalf("Re-clustering failed: %v", err)
+	}
+
+	keys, _ = db.GetFileClusterKeys()
+	if keys[third.ID] == thirdKey {
+		t.Fatalf("Expected new aliases to change cluster keys, still %s", thirdKey)
+	}
+	if keys[other.ID] != keys[song.ID] {
+		t.Errorf("Expected merged file with %s, got %s", keys[song.ID], keys[other.ID])
+	}
+	if keys[live.ID] != splitKey {
+		t.Errorf("Expected split file in %s, got %s", splitKey, keys[live.ID])
+	}
+
+	overrides, _ := db.GetClusterOverrides()
+	if overrides[1].ClusterKey != keys[song.ID] {
+		t.Errorf("Expected merge target re-keyed to %s, got %s", keys[song.ID], overrides[1].ClusterKey)
+	}
+
+	// Files arriving later under the new key of the merged cluster join it too
+	late := addFile("Come with Me (Single Edit)")
+	if _, err := New(&Config{Store: db, Normalizer: aliases}).Cluster(ctx); err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	keys, _ = db.GetFileClusterKeys()
+	if keys[late.ID] != keys[song.ID] {
+		t.Errorf("Expected late file merged into %s, got %s", keys[song.ID], keys[late.ID])
+	}
+}
+
+func TestOverrideMatches(t *testing.T) {
+	keys := map[int64]string{1: "a", 2: "b"}
+	testCases := []struct {
+		name     string
+		override store.ClusterOverride
+		want     bool
+	}{
+		{"split of clustered file", store.ClusterOverride{Kind: store.OverrideSplit, ClusterKey: "old", FileID: 1}, true},
+		{"split of removed file", store.ClusterOverride{Kind: store.OverrideSplit, ClusterKey: "a", FileID: 9}, false},
+		{"merge by anchor file", store.ClusterOverride{Kind: store.OverrideMerge, ClusterKey: "old", OtherKey: "gone", FileID: 9, OtherFileID: 2}, true},
+		{"merge by key", store.ClusterOverride{Kind: store.OverrideMerge, ClusterKey: "a", OtherKey: "gone"}, true},
+		{"merge of vanished clusters", store.ClusterOverride{Kind: store.OverrideMerge, ClusterKey: "old", OtherKey: "gone", FileID: 8, OtherFileID: 9}, false},
+		{"exclude", store.ClusterOverride{Kind: store.OverrideExclude, FileID: 9}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := OverrideMatches(&tc.override, keys); got != tc.want {
+				t.Errorf("OverrideMatches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// aliasNormalizer resolves a fixed set of aliases
+type aliasNormalizer map[string]string
+
+func (a aliasNormalizer) NormalizeArtistName(ctx context.Context, name string) (string, error) {
+	return a[name], nil
+}
+
+func TestGenerateClusterKeyWithNormalizer(t *testing.T) {
+	m := &store.Metadata{TagArtist: "ACDC", TagTitle: "Thunderstruck", DurationMs: 292000}
+	testCases := []struct {
+		name       string
+		normalizer aliasNormalizer
+		want       string
+	}{
+		{"no normalizer", nil, GenerateClusterKey(m, "/a.mp3")},
+		{"alias", aliasNormalizer{"ACDC": "AC/DC"}, GenerateClusterKey(&store.Metadata{TagArtist: "AC/DC", TagTitle: "Thunderstruck", DurationMs: 292000}, "/a.mp3")},
+		{"other alias", aliasNormalizer{"ACDC": "Acca Dacca"}, GenerateClusterKey(&store.Metadata{TagArtist: "Acca Dacca", TagTitle: "Thunderstruck", DurationMs: 292000}, "/a.mp3")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Normalizers are per call, so keys can be generated in parallel
+			t.Parallel()
+			for i := 0; i < 100; i++ {
+				if got := GenerateClusterKeyWith(tc.normalizer, m, "/a.mp3"); got != tc.want {
+					t.Fatalf("key = %q, want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterKeyClasses(t *testing.T) {
+	classifier, err := classify.New(&classify.Config{})
+	if err != nil {
+		t.Fatalf("classify.New() error: %v", err)
+	}
+	c := New(&Config{Classifier: classifier})
+
+	song := &store.Metadata{TagArtist: "Host", TagTitle: "Episode 1", TagGenre: "Rock", DurationMs: 240000}
+	episode := &store.Metadata{TagArtist: "Host", TagTitle: "Episode 1", TagGenre: "Podcast", DurationMs: 240000}
+	chapter := &store.Metadata{TagArtist: "Host", TagTitle: "Episode 1", TagGenre: "Audiobook", DurationMs: 240000}
+
+	songKey := c.clusterKey(song, &store.File{ID: 1, SrcPath: "/a.mp3"})
+	episodeKey := c.clusterKey(episode, &store.File{ID: 2, SrcPath: "/b.mp3"})
+	if songKey == episodeKey {
+		t.Errorf("song and podcast episode share key %q", songKey)
+	}
+	if !strings.Contains(episodeKey, "|podcast|") {
+		t.Errorf("podcast key = %q, want podcast version slot", episodeKey)
+	}
+	if again := c.clusterKey(episode, &store.File{ID: 3, SrcPath: "/c.mp3"}); again != episodeKey {
+		t.Errorf("podcast copies got keys %q and %q, want one cluster", episodeKey, again)
+	}
+
+	// Audiobooks are not deduplicated by default, so every file gets its own key
+	first := c.clusterKey(chapter, &store.File{ID: 4, SrcPath: "/d.mp3"})
+	second := c.clusterKey(chapter, &store.File{ID: 5, SrcPath: "/e.mp3"})
+	if first == second {
+		t.Errorf("audiobook files share key %q, want separate clusters", first)
+	}
+
+	// Without a classifier everything keeps the music key
+	if got := New(&Config{}).clusterKey(episode, &store.File{ID: 2, SrcPath: "/b.mp3"}); got != GenerateClusterKey(episode, "/b.mp3") {
+		t.Errorf("unclassified key = %q, want %q", got, GenerateClusterKey(episode, "/b.mp3"))
+	}
+}
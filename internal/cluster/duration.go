@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// DefaultDurationToleranceMs is the maximum duration difference for files to be
+// linked across duration buckets (matches the ±1.5s bucket tolerance)
+const DefaultDurationToleranceMs = 1500
+
+// keyDuration is one file's duration under its cluster key
+type keyDuration struct {
+	key        string
+	durationMs int
+}
+
+// splitClusterKey splits a cluster key (artist|title|version|bucket|discN|trackN) into parts
+// Keys that don't have this shape (e.g. clusters created by manual splits) are rejected.
+func splitClusterKey(key string) ([]string, bool) {
+	parts := strings.Split(key, "|")
+	n := len(parts)
+	if n < 6 || !strings.HasPrefix(parts[n-1], "track") || !strings.HasPrefix(parts[n-2], "disc") {
+		return nil, false
+	}
+	return parts, true
+}
+
+// keyWithoutBucket returns the cluster key with its duration bucket removed
+func keyWithoutBucket(key string) (string, bool) {
+	parts, ok := splitClusterKey(key)
+	if !ok {
+		return "", false
+	}
+	n := len(parts)
+	return strings.Join(parts[:n-3], "|") + "|" + strings.Join(parts[n-2:], "|"), true
+}
+
+// linkDurationKeys merges cluster keys that differ only in duration bucket
+// when any of their files are within toleranceMs of each other
+//
+// Keys are grouped on everything but the bucket, then files are single-linked on
+// their real duration: sorted by duration, consecutive files no more than
+// toleranceMs apart belong to the same component. This never splits a key - it
+// only joins keys whose files straddle a bucket boundary (e.g. 181.4s and 181.6s).
+// Each component's files go to its largest key (ties: smallest key).
+// Returns the keys to merge away, mapped to their target key.
+func linkDurationKeys(entries []keyDuration, toleranceMs int) map[string]string {
+	groups := make(map[string][]keyDuration)
+	counts := make(map[string]int)
+	for _, e := range entries {
+		if e.durationMs <= 0 {
+			continue // Unknown durations all share bucket 0 and can't be compared
+		}
+		rest, ok := keyWithoutBucket(e.key)
+		if !ok {
+			continue
+		}
+		groups[rest] = append(groups[rest], e)
+		counts[e.key]++
+	}
+
+	parent := make(map[string]string)
+	var find func(key string) string
+	find = func(key string) string {
+		p, ok := parent[key]
+		if !ok || p == key {
+			return key
+		}
+		root := find(p)
+		parent[key] = root
+		return root
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].durationMs != group[j].durationMs {
+				return group[i].durationMs < group[j].durationMs
+			}
+			return group[i].key < group[j].key
+		})
+		for i := 1; i < len(group); i++ {
+			if group[i].key != group[i-1].key && group[i].durationMs-group[i-1].durationMs <= toleranceMs {
+				union(group[i-1].key, group[i].key)
+			}
+		}
+	}
+
+	// Collect components and pick the largest key of each as target
+	components := make(map[string][]string)
+	for key := range counts {
+		root := find(key)
+		components[root] = append(components[root], key)
+	}
+
+	merges := make(map[string]string)
+	for _, keys := range components {
+		if len(keys) < 2 {
+			continue
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if counts[keys[i]] != counts[keys[j]] {
+				return counts[keys[i]] > counts[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		for _, key := range keys[1:] {
+			merges[key] = keys[0]
+		}
+	}
+
+	return merges
+}
+
+// linkDurationClusterMap merges clusters of a full clustering pass whose files
+// straddle a duration bucket boundary, in place
+func (c *Clusterer) linkDurationClusterMap(clusterMap map[string][]*store.File, durations map[int64]int, result *Result) {
+	var entries []keyDuration
+	for key, files := range clusterMap {
+		for _, file := range files {
+			entries = append(entries, keyDuration{key: key, durationMs: durations[file.ID]})
+		}
+	}
+
+	merges := linkDurationKeys(entries, c.durationToleranceMs)
+	for key, target := range merges {
+		clusterMap[target] = append(clusterMap[target], clusterMap[key]...)
+		result.DurationLinked += len(clusterMap[key])
+		delete(clusterMap, key)
+	}
+
+	if len(merges) > 0 {
+		util.InfoLog("Duration linking merged %d clusters across bucket boundaries (%d files, tolerance %dms)",
+			len(merges), result.DurationLinked, c.durationToleranceMs)
+	}
+}
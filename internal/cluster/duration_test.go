@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// durationsFromOffsets spreads durations over a few buckets around 3:00
+func durationsFromOffsets(offsets []uint16) []int {
+	durations := make([]int, len(offsets))
+	for i, o := range offsets {
+		durations[i] = 178000 + int(o%9000)
+	}
+	return durations
+}
+
+// linkedKeys returns the final cluster key of each file after duration linking
+func linkedKeys(keys []string, durations []int) []string {
+	entries := make([]keyDuration, len(keys))
+	for i := range keys {
+		entries[i] = keyDuration{key: keys[i], durationMs: durations[i]}
+	}
+	merges := linkDurationKeys(entries, DefaultDurationToleranceMs)
+
+	final := make([]string, len(keys))
+	for i, key := range keys {
+		final[i] = key
+		if target, ok := merges[key]; ok {
+			final[i] = target
+		}
+	}
+	return final
+}
+
+func TestBucketBoundaryLinking(t *testing.T) {
+	// 181.4s and 181.6s fall into buckets 180 and 183
+	a := GenerateClusterKey(&store.Metadata{TagArtist: "Artist", TagTitle: "Song", DurationMs: 181400}, "/a.mp3")
+	b := GenerateClusterKey(&store.Metadata{TagArtist: "Artist", TagTitle: "Song", DurationMs: 181600}, "/b.mp3")
+	if a == b {
+		t.Fatalf("Expected different buckets, got %q for both", a)
+	}
+
+	final := linkedKeys([]string{a, b}, []int{181400, 181600})
+	if final[0] != final[1] {
+		t.Errorf("Expected 181.4s and 181.6s to cluster together, got %q and %q", final[0], final[1])
+	}
+
+	// Far apart in the same neighbouring buckets: not linked
+	final = linkedKeys([]string{a, b}, []int{179000, 183000})
+	if final[0] == final[1] {
+		t.Errorf("Expected 179.0s and 183.0s to stay apart, got %q", final[0])
+	}
+}
+
+// Property: any two files within the tolerance always end up in the same cluster
+func TestDurationLinkingWithinTolerance(t *testing.T) {
+	property := func(offsets []uint16) bool {
+		durations := durationsFromOffsets(offsets)
+		keys := make([]string, len(durations))
+		for i, d := range durations {
+			keys[i] = GenerateClusterKey(&store.Metadata{TagArtist: "Artist", TagTitle: "Song", DurationMs: d}, "/song.mp3")
+		}
+
+		final := linkedKeys(keys, durations)
+		for i := range durations {
+			for j := range durations {
+				if GetDurationDelta(durations[i], durations[j]) <= DefaultDurationToleranceMs && final[i] != final[j] {
+					t.Logf("%dms and %dms within tolerance but in %q and %q", durations[i], durations[j], final[i], final[j])
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Property: linking never joins files that differ in anything but the duration bucket
+func TestDurationLinkingKeepsOtherKeyParts(t *testing.T) {
+	property := func(offsets []uint16) bool {
+		durations := durationsFromOffsets(offsets)
+		keys := make([]string, len(durations))
+		for i, d := range durations {
+			// Alternate track numbers: files on different tracks must never link
+			m := &store.Metadata{TagArtist: "Artist", TagTitle: "Song", TagTrack: i % 2, DurationMs: d}
+			keys[i] = GenerateClusterKey(m, "/song.mp3")
+		}
+
+		final := linkedKeys(keys, durations)
+		for i := range durations {
+			for j := range durations {
+				if i%2 != j%2 && final[i] == final[j] {
+					t.Logf("Tracks %d and %d linked into %q", i%2, j%2, final[i])
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+// Property: full and incremental clustering both keep files within tolerance together
+func TestDurationLinkingClustering(t *testing.T) {
+	ctx := context.Background()
+
+	property := func(offsets []uint16, split uint8) bool {
+		if len(offsets) > 12 {
+			offsets = offsets[:12]
+		}
+		durations := durationsFromOffsets(offsets)
+
+		db, err := store.Open(t.TempDir() + "/test.db")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		ids := make([]int64, len(durations))
+		addFiles := func(from, to int) {
+			for i := from; i < to; i++ {
+				f := &store.File{
+					FileKey: fmt.Sprintf("key-%d", i),
+					SrcPath: fmt.Sprintf("/src/%d.mp3", i),
+					Status:  "meta_ok",
+				}
+				if err := db.InsertFile(f); err != nil {
+					t.Fatalf("Failed to insert file: %v", err)
+				}
+				m := &store.Metadata{FileID: f.ID, TagArtist: "Artist", TagTitle: "Song", DurationMs: durations[i]}
+				if err := db.InsertMetadata(m); err != nil {
+					t.Fatalf("Failed to insert metadata: %v", err)
+				}
+				ids[i] = f.ID
+			}
+		}
+
+		// First part in a full run, the rest incrementally
+		cut := 0
+		if len(durations) > 0 {
+			cut = int(split) % (len(durations) + 1)
+		}
+		addFiles(0, cut)
+		if _, err := New(&Config{Store: db}).Cluster(ctx); err != nil {
+			t.Fatalf("Clustering failed: %v", err)
+		}
+		addFiles(cut, len(durations))
+		if _, err := New(&Config{Store: db}).Cluster(ctx); err != nil {
+			t.Fatalf("Incremental clustering failed: %v", err)
+		}
+
+		keys, err := db.GetFileClusterKeys()
+		if err != nil {
+			t.Fatalf("Failed to get cluster keys: %v", err)
+		}
+		for i := range durations {
+			for j := range durations {
+				if GetDurationDelta(durations[i], durations[j]) <= DefaultDurationToleranceMs && keys[ids[i]] != keys[ids[j]] {
+					t.Logf("%dms and %dms within tolerance but in %q and %q (cut %d)",
+						durations[i], durations[j], keys[ids[i]], keys[ids[j]], cut)
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	cfg := &quick.Config{MaxCount: 25, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(property, cfg); err != nil {
+		t.Error(err)
+	}
+}
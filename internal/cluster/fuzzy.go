@@ -0,0 +1,332 @@
+package cluster
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// DefaultFuzzyThreshold is the minimum similarity for merging near-identical cluster keys
+const DefaultFuzzyThreshold = 0.92
+
+// fuzzyKey holds the parts of a cluster key compared by the fuzzy pass
+type fuzzyKey struct {
+	key    string
+	block  string // version_type|duration_bucket|discN|trackN - only keys in the same block are compared
+	artist string // folded, token-sorted artist
+	title  string // folded, token-sorted title
+	digits string // numbers in the title; keys with different numbers never match ("Part 1" vs "Part 2")
+}
+
+// fuzzyMatch is the result of matching a cluster key against existing clusters
+type fuzzyMatch struct {
+	Target     string
+	Confidence float64
+}
+
+// fuzzyMatcher finds near-identical cluster keys within the same duration bucket and track
+// Keys are matched against representatives (clusters that already exist or were
+// not merged) so every merge records its similarity to the cluster it joined.
+type fuzzyMatcher struct {
+	threshold     float64
+	transliterate bool // Compare the romanized form of non-Latin scripts
+	blocks        map[string][]fuzzyKey
+	known         map[string]bool
+}
+
+// newFuzzyMatcher creates a matcher that merges keys at or above threshold similarity
+func newFuzzyMatcher(threshold float64, transliterate bool) *fuzzyMatcher {
+	return &fuzzyMatcher{
+		threshold:     threshold,
+		transliterate: transliterate,
+		blocks:        make(map[string][]fuzzyKey),
+		known:         make(map[string]bool),
+	}
+}
+
+// addRepresentative registers an existing cluster key as a merge target
+func (m *fuzzyMatcher) addRepresentative(key string) {
+	m.known[key] = true
+	fk, ok := parseFuzzyKey(key, m.transliterate)
+	if !ok {
+		return
+	}
+	m.blocks[fk.block] = append(m.blocks[fk.block], fk)
+}
+
+// match returns the most similar representative for key, if any reaches the threshold
+// Ties are resolved in favour of the representative added first.
+func (m *fuzzyMatcher) match(key string) (fuzzyMatch, bool) {
+	fk, ok := parseFuzzyKey(key, m.transliterate)
+	if !ok {
+		return fuzzyMatch{}, false
+	}
+
+	var best fuzzyMatch
+	for _, rep := range m.blocks[fk.block] {
+		if rep.key == key {
+			return fuzzyMatch{Target: key, Confidence: 1.0}, true
+		}
+		sim := keySimilarity(fk, rep)
+		if sim >= m.threshold && sim > best.Confidence {
+			best = fuzzyMatch{Target: rep.key, Confidence: sim}
+		}
+	}
+
+	return best, best.Target != ""
+}
+
+// resolve returns the cluster a key should join and the confidence of that choice
+// Known keys join themselves; unknown keys join the most similar representative,
+// or become a representative themselves when nothing is similar enough.
+func (m *fuzzyMatcher) resolve(key string) (string, float64) {
+	if m.known[key] {
+		return key, 1.0
+	}
+	if match, ok := m.match(key); ok {
+		return match.Target, match.Confidence
+	}
+	m.addRepresentative(key)
+	return key, 1.0
+}
+
+// fuzzyMergeKeys merges near-identical cluster keys
+// counts maps each key to its number of files; larger clusters become merge targets first.
+// Returns the keys to merge away, with their target and confidence.
+func fuzzyMergeKeys(counts map[string]int, threshold float64, transliterate bool) map[string]fuzzyMatch {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	matcher := newFuzzyMatcher(threshold, transliterate)
+	merges := make(map[string]fuzzyMatch)
+	for _, key := range keys {
+		if match, ok := matcher.match(key); ok {
+			merges[key] = match
+			continue
+		}
+		matcher.addRepresentative(key)
+	}
+
+	return merges
+}
+
+// fuzzyMergeClusterMap merges near-identical clusters of a full clustering pass in place
+// Returns the confidence of every file that was moved into another cluster.
+func (c *Clusterer) fuzzyMergeClusterMap(clusterMap map[string][]*store.File, result *Result) map[int64]float64 {
+	confidence := make(map[int64]float64)
+	threshold := c.matchThreshold()
+	if threshold <= 0 {
+		return confidence
+	}
+
+	counts := make(map[string]int, len(clusterMap))
+	for key, files := range clusterMap {
+		counts[key] = len(files)
+	}
+
+	merges := fuzzyMergeKeys(counts, threshold, c.transliterate)
+	for key, match := range merges {
+		for _, file := range clusterMap[key] {
+			confidence[file.ID] = match.Confidence
+		}
+		clusterMap[match.Target] = append(clusterMap[match.Target], clusterMap[key]...)
+		result.FuzzyMerged += len(clusterMap[key])
+		delete(clusterMap, key)
+	}
+
+	if len(merges) > 0 {
+		util.InfoLog("Fuzzy matching merged %d near-identical clusters (%d files, threshold %.2f)",
+			len(merges), result.FuzzyMerged, threshold)
+	}
+
+	return confidence
+}
+
+// matchThreshold returns the similarity needed to merge clusters: the fuzzy
+// threshold, or exact matches of the transliterated form when only
+// transliteration is enabled. 0 disables the pass
+func (c *Clusterer) matchThreshold() float64 {
+	if c.fuzzyThreshold > 0 {
+		return c.fuzzyThreshold
+	}
+	if c.transliterate {
+		return 1.0
+	}
+	return 0
+}
+
+// newIncrementalMatcher loads existing clusters as representatives for incremental runs
+// Clusters in skip are being merged away and can't be targets.
+// Returns nil when fuzzy matching is disabled.
+func (c *Clusterer) newIncrementalMatcher(skip map[string]string, heuristicKeys map[string][]int64) (*fuzzyMatcher, error) {
+	threshold := c.matchThreshold()
+	if threshold <= 0 {
+		return nil, nil
+	}
+
+	clusters, err := c.store.GetAllClusters()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := newFuzzyMatcher(threshold, c.transliterate)
+	for _, cl := range clusters {
+		if _, ok := skip[cl.ClusterKey]; ok {
+			continue
+		}
+		matcher.addRepresentative(cl.ClusterKey)
+	}
+
+	// Heuristic keys of files that identifiers moved into mbid:/isrc: clusters
+	keys := make([]string, 0, len(heuristicKeys))
+	for key := range heuristicKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := skip[key]; ok || matcher.known[key] {
+			continue
+		}
+		matcher.addRepresentative(key)
+	}
+	return matcher, nil
+}
+
+// parseFuzzyKey splits a cluster key into the parts compared by the fuzzy pass
+// With transliterate, artist and title are compared in their romanized form
+func parseFuzzyKey(key string, transliterate bool) (fuzzyKey, bool) {
+	parts, ok := splitClusterKey(key)
+	if !ok {
+		return fuzzyKey{}, false
+	}
+	n := len(parts)
+
+	// Artist never contains "|" after normalization in practice; anything extra belongs to the title
+	title := strings.Join(parts[1:n-4], "|")
+	artist := parts[0]
+	if transliterate {
+		artist, title = meta.Transliterate(artist), meta.Transliterate(title)
+	}
+	return fuzzyKey{
+		key:    key,
+		block:  strings.Join(parts[n-4:], "|"),
+		artist: foldForMatching(artist),
+		title:  foldForMatching(title),
+		digits: extractDigits(title),
+	}, true
+}
+
+// foldForMatching folds diacritics, drops punctuation and sorts tokens
+// so "Beyoncé", "beyonce" and word-order variants compare equal
+func foldForMatching(s string) string {
+	s = strings.ToLower(meta.FoldDiacritics(s))
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return r
+		case unicode.IsSpace(r):
+			return ' '
+		default:
+			return -1 // Apostrophes and other punctuation: "don’t" -> "dont"
+		}
+	}, s)
+
+	tokens := strings.Fields(s)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// extractDigits returns the numbers in s, space-separated
+func extractDigits(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }), " ")
+}
+
+// keySimilarity scores two keys of the same block; the weaker of artist and title wins
+func keySimilarity(a, b fuzzyKey) float64 {
+	if a.digits != b.digits {
+		return 0
+	}
+	artistSim := JaroWinkler(a.artist, b.artist)
+	titleSim := JaroWinkler(a.title, b.title)
+	if artistSim < titleSim {
+		return artistSim
+	}
+	return titleSim
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings (0.0 - 1.0)
+func JaroWinkler(a, b string) float64 {
+	if a == b {
+		return 1.0
+	}
+
+	r1, r2 := []rune(a), []rune(b)
+	if len(r1) == 0 || len(r2) == 0 {
+		return 0.0
+	}
+
+	// Characters match if equal and no further apart than this window
+	window := max(len(r1), len(r2))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(r1))
+	matched2 := make([]bool, len(r2))
+	matches := 0
+	for i := range r1 {
+		lo := max(0, i-window)
+		hi := min(len(r2), i+window+1)
+		for j := lo; j < hi; j++ {
+			if matched2[j] || r1[i] != r2[j] {
+				continue
+			}
+			matched1[i] = true
+			matched2[j] = true
+			matches++
+			break
+		}
+	}
+	if matches == 0 {
+		return 0.0
+	}
+
+	// Count transpositions (matched characters in a different order)
+	transpositions := 0
+	j := 0
+	for i := range r1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if r1[i] != r2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(r1)) + m/float64(len(r2)) + (m-float64(transpositions)/2)/m) / 3
+
+	// Winkler boost for a common prefix (up to 4 characters)
+	prefix := 0
+	for prefix < min(4, len(r1), len(r2)) && r1[prefix] == r2[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
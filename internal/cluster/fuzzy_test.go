@@ -0,0 +1,232 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected float64
+	}{
+		{"martha", "marhta", 0.961},
+		{"dixon", "dicksonx", 0.813},
+		{"dwayne", "duane", 0.840},
+		{"same", "same", 1.0},
+		{"", "abc", 0.0},
+		{"abc", "xyz", 0.0},
+	}
+
+	for _, tc := range testCases {
+		result := JaroWinkler(tc.a, tc.b)
+		if math.Abs(result-tc.expected) > 0.001 {
+			t.Errorf("JaroWinkler(%q, %q) = %.3f, expected %.3f", tc.a, tc.b, result, tc.expected)
+		}
+	}
+}
+
+func TestFuzzyMergeKeys(t *testing.T) {
+	testCases := []struct {
+		name   string
+		a, b   string
+		merged bool
+	}{
+		{
+			name:   "apostrophe variants",
+			a:      "queen|dont stop me now|studio|210|disc0|track12",
+			b:      "queen|don’t stop me now|studio|210|disc0|track12",
+			merged: true,
+		},
+		{
+			name:   "diacritics",
+			a:      "beyonce|halo|studio|261|disc0|track3",
+			b:      "beyoncé|halo|studio|261|disc0|track3",
+			merged: true,
+		},
+		{
+			name:   "typo in title",
+			a:      "nirvana|smells like teen spirit|studio|300|disc0|track1",
+			b:      "nirvana|smells like teen sprit|studio|300|disc0|track1",
+			merged: true,
+		},
+		{
+			name:   "different track number",
+			a:      "nirvana|smells like teen spirit|studio|300|disc0|track1",
+			b:      "nirvana|smells like teen sprit|studio|300|disc0|track2",
+			merged: false,
+		},
+		{
+			name:   "different duration bucket",
+			a:      "nirvana|smells like teen spirit|studio|300|disc0|track1",
+			b:      "nirvana|smells like teen sprit|studio|303|disc0|track1",
+			merged: false,
+		},
+		{
+			name:   "different version type",
+			a:      "nirvana|smells like teen spirit|studio|300|disc0|track1",
+			b:      "nirvana|smells like teen sprit|live|300|disc0|track1",
+			merged: false,
+		},
+		{
+			name:   "numbered parts",
+			a:      "pink floyd|another brick in the wall part 1|studio|189|disc0|track3",
+			b:      "pink floyd|another brick in the wall part 2|studio|189|disc0|track3",
+			merged: false,
+		},
+		{
+			name:   "different songs",
+			a:      "queen|bohemian rhapsody|studio|354|disc0|track11",
+			b:      "queen|love of my life|studio|354|disc0|track11",
+			merged: false,
+		},
+		{
+			name:   "manual split keys are never matched",
+			a:      "queen|bohemian rhapsody|studio|354|disc0|track11",
+			b:      "queen|bohemian rhapsody|studio|354|disc0|track11|split7",
+			merged: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// a is the larger cluster, so it is the merge target
+			merges := fuzzyMergeKeys(map[string]int{tc.a: 2, tc.b: 1}, DefaultFuzzyThreshold, false)
+
+			match, ok := merges[tc.b]
+			if ok != tc.merged {
+				t.Fatalf("Expected merged=%v, got %v (merges: %+v)", tc.merged, ok, merges)
+			}
+			if !ok {
+				return
+			}
+			if match.Target != tc.a {
+				t.Errorf("Expected target %q, got %q", tc.a, match.Target)
+			}
+			if match.Confidence < DefaultFuzzyThreshold || match.Confidence > 1.0 {
+				t.Errorf("Expected confidence in [%.2f, 1.0], got %.3f", DefaultFuzzyThreshold, match.Confidence)
+			}
+		})
+	}
+}
+
+func TestFuzzyMergeKeysTransliterate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		a, b      string
+		threshold float64
+	}{
+		{"cyrillic exact", "kino|gruppa krovi|studio|285|disc0|track1", "кино|группа крови|studio|285|disc0|track1", 1.0},
+		{"greek exact", "mikis theodorakis|zorba|studio|260|disc0|track2", "μίκης θεοδωράκης|zorba|studio|260|disc0|track2", 1.0},
+		{"kana with fuzzy threshold", "kyary pamyu pamyu|ponponpon|studio|240|disc0|track1", "きゃりーぱみゅぱみゅ|ponponpon|studio|240|disc0|track1", 0.8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if merges := fuzzyMergeKeys(map[string]int{tc.a: 2, tc.b: 1}, tc.threshold, false); len(merges) != 0 {
+				t.Errorf("Expected no merge without transliteration, got %+v", merges)
+			}
+			merges := fuzzyMergeKeys(map[string]int{tc.a: 2, tc.b: 1}, tc.threshold, true)
+			if match, ok := merges[tc.b]; !ok || match.Target != tc.a {
+				t.Errorf("Expected %q to merge into %q, got %+v", tc.b, tc.a, merges)
+			}
+		})
+	}
+}
+
+func TestFuzzyClustering(t *testing.T) {
+	tmpDir := t.TempDir()
+	db, err := store.Open(tmpDir + "/test.db")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	addFile := func(n int, artist, title string) *store.File {
+		f := &store.File{
+			FileKey: fmt.Sprintf("key-%d", n),
+			SrcPath: fmt.Sprintf("/src/%d.mp3", n),
+			Status:  "meta_ok",
+		}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("Failed to insert file %d: %v", n, err)
+		}
+		m := &store.Metadata{FileID: f.ID, TagArtist: artist, TagTitle: title, TagTrack: 1, DurationMs: 300000}
+		if err := db.InsertMetadata(m); err != nil {
+			t.Fatalf("Failed to insert metadata %d: %v", n, err)
+		}
+		return f
+	}
+
+	addFile(1, "Nirvana", "Smells Like Teen Spirit")
+	addFile(2, "Nirvana", "Smells Like Teen Spirit")
+	typo := addFile(3, "Nirvana", "Smells Like Teen Sprit")
+	addFile(4, "Nirvana", "Lithium")
+
+	// Disabled by default: the typo stays in its own cluster
+	result, err := New(&Config{Store: db}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Clustering failed: %v", err)
+	}
+	if result.ClustersCreated != 3 || result.FuzzyMerged != 0 {
+		t.Fatalf("Expected 3 clusters without fuzzy matching, got %d (fuzzy %d)", result.ClustersCreated, result.FuzzyMerged)
+	}
+
+	// Full pass with fuzzy matching merges the typo into the larger cluster
+	result, err = New(&Config{Store: db, ForceRecluster: true, FuzzyThreshold: DefaultFuzzyThreshold}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Clustering failed: %v", err)
+	}
+	if result.ClustersCreated != 2 || result.FuzzyMerged != 1 {
+		t.Fatalf("Expected 2 clusters with 1 fuzzy match, got %d (fuzzy %d)", result.ClustersCreated, result.FuzzyMerged)
+	}
+
+	keys, _ := db.GetFileClusterKeys()
+	target := "nirvana|smells like teen spirit|studio|300|disc0|track1"
+	if keys[typo.ID] != target {
+		t.Fatalf("Expected typo file in %q, got %q", target, keys[typo.ID])
+	}
+
+	members, _ := db.GetClusterMembers(target)
+	for _, m := range members {
+		if m.FileID == typo.ID {
+			if m.Confidence >= 1.0 || m.Confidence < DefaultFuzzyThreshold {
+				t.Errorf("Expected fuzzy confidence below 1.0, got %.3f", m.Confidence)
+			}
+		} else if m.Confidence != 1.0 {
+			t.Errorf("Expected exact member confidence 1.0, got %.3f", m.Confidence)
+		}
+	}
+
+	// Incremental pass: a new variant joins the existing cluster
+	accented := addFile(5, "Nirvana", "Smells Like Teen Spírit")
+	result, err = New(&Config{Store: db, FuzzyThreshold: DefaultFuzzyThreshold}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if !result.Incremental || result.FilesAdded != 1 || result.FuzzyMerged != 1 {
+		t.Errorf("Expected 1 fuzzy file added incrementally, got %+v", result)
+	}
+	keys, _ = db.GetFileClusterKeys()
+	if keys[accented.ID] != target {
+		t.Errorf("Expected accented file in %q, got %q", target, keys[accented.ID])
+	}
+
+	// Re-running is stable: fuzzy members are not moved back and forth
+	if err := db.UpdateFileStatus(typo.ID, "meta_ok", ""); err != nil {
+		t.Fatalf("Failed to update file: %v", err)
+	}
+	result, err = New(&Config{Store: db, FuzzyThreshold: DefaultFuzzyThreshold}).Cluster(ctx)
+	if err != nil {
+		t.Fatalf("Incremental clustering failed: %v", err)
+	}
+	if result.FilesAdded+result.FilesMoved+result.FilesRemoved != 0 {
+		t.Errorf("Expected no changes on re-run, got %+v", result)
+	}
+}
@@ -0,0 +1,219 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Identity cluster key prefixes; recording identifiers are authoritative over heuristics
+const (
+	mbidKeyPrefix = "mbid:"
+	isrcKeyPrefix = "isrc:"
+)
+
+// isIdentityKey reports whether a cluster key was assigned from a recording identifier
+func isIdentityKey(key string) bool {
+	return (strings.HasPrefix(key, mbidKeyPrefix) || strings.HasPrefix(key, isrcKeyPrefix)) &&
+		!strings.Contains(key, "|")
+}
+
+// resolveIdentities assigns every file its target cluster from recording identifiers
+//
+// A file with a MusicBrainz recording ID goes to mbid:<id>. A file with only an
+// ISRC joins the recording other files with that ISRC are tagged with, or
+// isrc:<ISRC> when there is none (or several - an ISRC conflict).
+// Files without identifiers follow their heuristic cluster: when it holds exactly
+// one identity they join it, otherwise they keep the heuristic key. A heuristic
+// cluster holding several identities is a cluster conflict; its identified files
+// still go to their own recordings.
+func resolveIdentities(members []*store.IdentityMember) (map[int64]string, []*store.IdentityConflict) {
+	isrcRecordings := make(map[string]map[string]bool)
+	isrcFiles := make(map[string][]int64)
+	for _, m := range members {
+		if m.ISRC == "" {
+			continue
+		}
+		isrcFiles[m.ISRC] = append(isrcFiles[m.ISRC], m.FileID)
+		if m.MBID != "" {
+			if isrcRecordings[m.ISRC] == nil {
+				isrcRecordings[m.ISRC] = make(map[string]bool)
+			}
+			isrcRecordings[m.ISRC][mbidKeyPrefix+m.MBID] = true
+		}
+	}
+
+	var conflicts []*store.IdentityConflict
+	for isrc, recordings := range isrcRecordings {
+		if len(recordings) > 1 {
+			conflicts = append(conflicts, &store.IdentityConflict{
+				ConflictKey: isrcKeyPrefix + isrc,
+				Kind:        store.ConflictISRC,
+				Identities:  sortedSet(recordings),
+				FileIDs:     isrcFiles[isrc],
+			})
+		}
+	}
+
+	identities := make(map[int64]string, len(members))
+	groupIdentities := make(map[string]map[string]bool)
+	groupFiles := make(map[string][]int64)
+	for _, m := range members {
+		identity := ""
+		switch {
+		case m.MBID != "":
+			identity = mbidKeyPrefix + m.MBID
+		case m.ISRC != "" && len(isrcRecordings[m.ISRC]) == 1:
+			identity = sortedSet(isrcRecordings[m.ISRC])[0]
+		case m.ISRC != "":
+			identity = isrcKeyPrefix + m.ISRC
+		}
+		identities[m.FileID] = identity
+
+		groupFiles[m.HeuristicKey] = append(groupFiles[m.HeuristicKey], m.FileID)
+		if identity != "" {
+			if groupIdentities[m.HeuristicKey] == nil {
+				groupIdentities[m.HeuristicKey] = make(map[string]bool)
+			}
+			groupIdentities[m.HeuristicKey][identity] = true
+		}
+	}
+
+	for key, ids := range groupIdentities {
+		if len(ids) > 1 {
+			conflicts = append(conflicts, &store.IdentityConflict{
+				ConflictKey: key,
+				Kind:        store.ConflictCluster,
+				Identities:  sortedSet(ids),
+				FileIDs:     groupFiles[key],
+			})
+		}
+	}
+
+	targets := make(map[int64]string, len(members))
+	for _, m := range members {
+		switch ids := groupIdentities[m.HeuristicKey]; {
+		case identities[m.FileID] != "":
+			targets[m.FileID] = identities[m.FileID]
+		case len(ids) == 1:
+			targets[m.FileID] = sortedSet(ids)[0]
+		default:
+			targets[m.FileID] = m.HeuristicKey
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind < conflicts[j].Kind
+		}
+		return conflicts[i].ConflictKey < conflicts[j].ConflictKey
+	})
+	for _, c := range conflicts {
+		sort.Slice(c.FileIDs, func(i, j int) bool { return c.FileIDs[i] < c.FileIDs[j] })
+	}
+
+	return targets, conflicts
+}
+
+// reconcileIdentities moves clustered files to the clusters their recording
+// identifiers (MBID, ISRC) call for and records identity conflicts
+// heuristic overrides the stored heuristic keys (set by a full pass before they are recorded).
+// Files a manual override placed elsewhere are left alone; overrides are applied afterwards.
+func (c *Clusterer) reconcileIdentities(ctx context.Context, heuristic map[int64]string, result *Result) error {
+	members, err := c.store.GetIdentityMembers()
+	if err != nil {
+		return fmt.Errorf("failed to load cluster identifiers: %w", err)
+	}
+	for _, m := range members {
+		if key, ok := heuristic[m.FileID]; ok {
+			m.HeuristicKey = key
+		}
+	}
+
+	targets, conflicts := resolveIdentities(members)
+
+	moves := make(map[string][]int64) // target key -> files
+	touched := make(map[string]bool)
+	for _, m := range members {
+		target := targets[m.FileID]
+		if target == m.ClusterKey {
+			continue
+		}
+		if m.ClusterKey != m.HeuristicKey && !isIdentityKey(m.ClusterKey) {
+			continue // Placed by a manual override
+		}
+		moves[target] = append(moves[target], m.FileID)
+		touched[m.ClusterKey] = true
+		touched[target] = true
+		result.IdentityMoved++
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	targetKeys := make([]string, 0, len(moves))
+	for key := range moves {
+		targetKeys = append(targetKeys, key)
+	}
+	sort.Strings(targetKeys)
+
+	var newClusters []*store.Cluster
+	for _, key := range targetKeys {
+		newClusters = append(newClusters, &store.Cluster{ClusterKey: key, Hint: c.hintForFile(moves[key][0])})
+	}
+	if err := c.store.EnsureClusterBatch(newClusters); err != nil {
+		return fmt.Errorf("failed to create identity clusters: %w", err)
+	}
+	for _, key := range targetKeys {
+		if err := c.store.MoveClusterMembers(moves[key], key); err != nil {
+			return fmt.Errorf("failed to move files to %s: %w", key, err)
+		}
+	}
+
+	touchedKeys := make([]string, 0, len(touched))
+	for key := range touched {
+		touchedKeys = append(touchedKeys, key)
+	}
+	sort.Strings(touchedKeys)
+
+	if _, err := c.store.DeleteEmptyClusters(touchedKeys); err != nil {
+		return fmt.Errorf("failed to delete empty clusters: %w", err)
+	}
+	if err := c.store.MarkClustersDirty(touchedKeys); err != nil {
+		return fmt.Errorf("failed to mark clusters dirty: %w", err)
+	}
+
+	if err := c.store.ReplaceIdentityConflicts(conflicts); err != nil {
+		return fmt.Errorf("failed to record identity conflicts: %w", err)
+	}
+	result.IdentityConflicts = len(conflicts)
+
+	if result.IdentityMoved > 0 {
+		util.InfoLog("Recording identifiers placed %d files", result.IdentityMoved)
+	}
+	if len(conflicts) > 0 {
+		util.WarnLog("Found %d identity conflicts (see 'mlc report')", len(conflicts))
+	}
+	if c.logger != nil {
+		for _, conflict := range conflicts {
+			c.logger.LogIdentityConflict(conflict.ConflictKey, conflict.Kind, conflict.Identities, len(conflict.FileIDs))
+		}
+	}
+
+	return nil
+}
+
+// sortedSet returns the members of a string set in sorted order
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
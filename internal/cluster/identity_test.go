@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+const (
+	testMBIDA = "aaaaaaaa-0000-4000-8000-000000000001"
+	testMBIDB = "bbbbbbbb-0000-4000-8000-000000000002"
+	testMBIDC = "cccccccc-0000-4000-8000-000000000003"
+)
+
+func TestResolveIdentities(t *testing.T) {
+	members := []*store.IdentityMember{
+		{FileID: 1, HeuristicKey: "song", MBID: testMBIDA, ISRC: "USRC17607839"},
+		{FileID: 2, HeuristicKey: "song"},                        // joins the only identity in its cluster
+		{FileID: 3, HeuristicKey: "other", ISRC: "USRC17607839"}, // ISRC resolves to the MBID
+		{FileID: 4, HeuristicKey: "lone", ISRC: "GBAYE0601498"},  // ISRC without any MBID
+		{FileID: 5, HeuristicKey: "live", MBID: testMBIDB},
+		{FileID: 6, HeuristicKey: "live", MBID: testMBIDC},
+		{FileID: 7, HeuristicKey: "live"}, // ambiguous: stays heuristic
+		{FileID: 8, HeuristicKey: "plain"},
+	}
+
+	targets, conflicts := resolveIdentities(members)
+
+	expected := map[int64]string{
+		1: "mbid:" + testMBIDA,
+		2: "mbid:" + testMBIDA,
+		3: "mbid:" + testMBIDA,
+		4: "isrc:GBAYE0601498",
+		5: "mbid:" + testMBIDB,
+		6: "mbid:" + testMBIDC,
+		7: "live",
+		8: "plain",
+	}
+	for fileID, want := range expected {
+		if targets[fileID] != want {
+			t.Errorf("File %d: target %q, want %q", fileID, targets[fileID], want)
+		}
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	if c.Kind != store.ConflictCluster || c.ConflictKey != "live" || len(c.Identities) != 2 || len(c.FileIDs) != 3 {
+		t.Errorf("Unexpected conflict: %+v", c)
+	}
+}
+
+func TestResolveIdentitiesISRCConflict(t *testing.T) {
+	members := []*store.IdentityMember{
+		{FileID: 1, HeuristicKey: "a", MBID: testMBIDA, ISRC: "USRC17607839"},
+		{FileID: 2, HeuristicKey: "b", MBID: testMBIDB, ISRC: "USRC17607839"},
+		{FileID: 3, HeuristicKey: "c", ISRC: "USRC17607839"},
+	}
+
+	targets, conflicts := resolveIdentities(members)
+
+	if targets[3] != "isrc:USRC17607839" {
+		t.Errorf("Expected ISRC-only file to keep its ISRC key, got %q", targets[3])
+	}
+	if len(conflicts) != 1 || conflicts[0].Kind != store.ConflictISRC || len(conflicts[0].FileIDs) != 3 {
+		t.Errorf("Expected one ISRC conflict over 3 files, got %+v", conflicts)
+	}
+}
+
+// Full and incremental clustering must agree on identifier-based clusters
+func TestIdentityClustering(t *testing.T) {
+	ctx := context.Background()
+
+	type testFile struct {
+		title string
+		mbid  string
+		isrc  string
+	}
+	files := []testFile{
+		{"Song", testMBIDA, "USRC17607839"},
+		{"Live Song", testMBIDB, ""},
+		{"Live Song", testMBIDC, ""},
+		{"Song", "", ""},                       // same heuristic cluster as the MBID file
+		{"Song (Single)", testMBIDA, ""},       // different title, same recording
+		{"Something Else", "", "USRC17607839"}, // ISRC shared with the MBID file
+		{"Live Song", "", ""},                  // ambiguous between two recordings
+	}
+
+	run := func(t *testing.T, cut int) (map[int64]string, []int64, int) {
+		db, err := store.Open(t.TempDir() + "/test.db")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		defer db.Close()
+
+		ids := make([]int64, len(files))
+		addFiles := func(from, to int) {
+			for i := from; i < to; i++ {
+				f := &store.File{
+					FileKey: fmt.Sprintf("key-%d", i),
+					SrcPath: fmt.Sprintf("/src/%d.mp3", i),
+					Status:  "meta_ok",
+				}
+				if err := db.InsertFile(f); err != nil {
+					t.Fatalf("Failed to insert file: %v", err)
+				}
+				m := &store.Metadata{
+					FileID:                 f.ID,
+					TagArtist:              "Artist",
+					TagTitle:               files[i].title,
+					DurationMs:             180000,
+					MusicBrainzRecordingID: files[i].mbid,
+					ISRC:                   files[i].isrc,
+				}
+				if err := db.InsertMetadata(m); err != nil {
+					t.Fatalf("Failed to insert metadata: %v", err)
+				}
+				ids[i] = f.ID
+			}
+		}
+
+		addFiles(0, cut)
+		if _, err := New(&Config{Store: db}).Cluster(ctx); err != nil {
+			t.Fatalf("Clustering failed: %v", err)
+		}
+		addFiles(cut, len(files))
+		result, err := New(&Config{Store: db}).Cluster(ctx)
+		if err != nil {
+			t.Fatalf("Incremental clustering failed: %v", err)
+		}
+
+		keys, err := db.GetFileClusterKeys()
+		if err != nil {
+			t.Fatalf("Failed to get cluster keys: %v", err)
+		}
+		return keys, ids, result.IdentityConflicts
+	}
+
+	for _, cut := range []int{len(files), 3, 1} {
+		t.Run(fmt.Sprintf("cut%d", cut), func(t *testing.T) {
+			keys, ids, conflicts := run(t, cut)
+
+			mbidA := "mbid:" + testMBIDA
+			for _, i := range []int{0, 3, 4, 5} {
+				if keys[ids[i]] != mbidA {
+					t.Errorf("File %d (%s): cluster %q, want %q", i, files[i].title, keys[ids[i]], mbidA)
+				}
+			}
+			if keys[ids[1]] != "mbid:"+testMBIDB || keys[ids[2]] != "mbid:"+testMBIDC {
+				t.Errorf("Different recordings must stay apart, got %q and %q", keys[ids[1]], keys[ids[2]])
+			}
+			if isIdentityKey(keys[ids[6]]) {
+				t.Errorf("Ambiguous file should keep its heuristic cluster, got %q", keys[ids[6]])
+			}
+			if conflicts != 1 {
+				t.Errorf("Expected 1 identity conflict, got %d", conflicts)
+			}
+		})
+	}
+}
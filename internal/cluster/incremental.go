@@ -0,0 +1,312 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// clusterIncremental updates existing clusters with new, changed and removed files
+// Only clusters that gain or lose members are marked dirty for rescoring and replanning
+func (c *Clusterer) clusterIncremental(ctx context.Context) (*Result, error) {
+	result := &Result{
+		Incremental: true,
+		Errors:      make([]error, 0),
+	}
+
+	candidates, err := c.store.GetClusteringCandidates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clustering candidates: %w", err)
+	}
+
+	removed, err := c.store.GetRemovedClusteredFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get removed files: %w", err)
+	}
+
+	if len(candidates) == 0 && len(removed) == 0 {
+		util.InfoLog("Clustering up to date (no new or changed files)")
+		util.InfoLog("Use --force-recluster to re-cluster from scratch")
+		return c.fillTotals(result)
+	}
+
+	util.InfoLog("Incremental clustering: %d new/changed files, %d removed files", len(candidates), len(removed))
+
+	currentKeys, err := c.store.GetFileClusterKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster memberships: %w", err)
+	}
+
+	// Linking and fuzzy matching work on heuristic keys; files placed by
+	// identifiers are reconciled again afterwards
+	heuristicKeys, err := c.store.GetHeuristicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load heuristic cluster keys: %w", err)
+	}
+	for fileID, key := range currentKeys {
+		if _, ok := heuristicKeys[fileID]; !ok {
+			heuristicKeys[fileID] = key
+		}
+	}
+
+	excluded, err := c.store.GetOverrideFileIDs(store.OverrideExclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load excluded files: %w", err)
+	}
+
+	startTime := time.Now()
+	touched := make(map[string]bool)
+	hints := make(map[string]string)
+	var moveIDs []int64
+	var newMembers []*store.ClusterMember
+	var tracked []*store.ClusteredFile
+
+	// Pass 1: compute exact cluster keys for new and changed files
+	type pendingFile struct {
+		file     *store.File
+		metadata *store.Metadata
+		tracked  *store.ClusteredFile
+	}
+	var pending []pendingFile
+
+	for _, candidate := range candidates {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		default:
+		}
+
+		file := candidate.File
+		metadata, err := c.store.GetMetadata(file.ID)
+		if err != nil {
+			util.ErrorLog("Failed to get metadata for file %d: %v", file.ID, err)
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		if metadata == nil {
+			util.WarnLog("No metadata found for file %d", file.ID)
+			continue
+		}
+
+		trackedFile := &store.ClusteredFile{
+			FileID:          file.ID,
+			ClusterKey:      c.clusterKey(metadata, file),
+			MetadataVersion: candidate.MetadataVersion,
+		}
+		tracked = append(tracked, trackedFile)
+
+		// Manually excluded files are tracked but never join a cluster
+		if excluded[file.ID] {
+			continue
+		}
+
+		pending = append(pending, pendingFile{file: file, metadata: metadata, tracked: trackedFile})
+	}
+
+	// Pass 2: link across duration bucket boundaries, using the files that stay
+	// in their clusters together with the new keys
+	durations, err := c.store.GetClusterMemberDurations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster member durations: %w", err)
+	}
+	leaving := make(map[int64]bool, len(candidates)+len(removed))
+	for _, candidate := range candidates {
+		leaving[candidate.File.ID] = true
+	}
+	for _, cf := range removed {
+		leaving[cf.FileID] = true
+	}
+
+	var entries []keyDuration
+	staying := make(map[string][]int64) // heuristic key -> files that keep their membership
+	for fileID := range currentKeys {
+		if leaving[fileID] {
+			continue
+		}
+		key := heuristicKeys[fileID]
+		entries = append(entries, keyDuration{key: key, durationMs: durations[fileID]})
+		staying[key] = append(staying[key], fileID)
+	}
+	for _, p := range pending {
+		entries = append(entries, keyDuration{key: p.tracked.ClusterKey, durationMs: p.metadata.DurationMs})
+	}
+	linked := linkDurationKeys(entries, c.durationToleranceMs)
+
+	// Existing clusters bridged by a new file join the linked cluster
+	bridged := make(map[string][]int64) // target key -> files
+	for key, target := range linked {
+		if ids := staying[key]; len(ids) > 0 {
+			bridged[target] = append(bridged[target], ids...)
+			touched[key] = true
+			touched[target] = true
+			result.DurationLinked += len(ids)
+		}
+	}
+
+	matcher, err := c.newIncrementalMatcher(linked, staying)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load clusters for fuzzy matching: %w", err)
+	}
+
+	// Pass 3: place each file in its final cluster
+	resolved := make(map[string]string) // linked key -> final key after fuzzy matching
+	for _, p := range pending {
+		file, metadata := p.file, p.metadata
+
+		clusterKey := p.tracked.ClusterKey
+		if target, ok := linked[clusterKey]; ok {
+			clusterKey = target
+			result.DurationLinked++
+		}
+
+		// New keys may join a near-identical existing cluster
+		linkedKey := clusterKey
+		confidence := 1.0
+		if matcher != nil {
+			clusterKey, confidence = matcher.resolve(clusterKey)
+		}
+		p.tracked.ClusterKey = clusterKey
+		p.tracked.HeuristicKey = clusterKey
+		resolved[linkedKey] = clusterKey
+
+		oldKey, wasClustered := currentKeys[file.ID]
+		if wasClustered && heuristicKeys[file.ID] == clusterKey {
+			// Metadata was refreshed but the key is unchanged - nothing to redo
+			p.tracked.ClusterKey = oldKey
+			continue
+		}
+
+		if wasClustered {
+			moveIDs = append(moveIDs, file.ID)
+			touched[oldKey] = true
+			result.FilesMoved++
+		} else {
+			result.FilesAdded++
+		}
+		if clusterKey != linkedKey {
+			result.FuzzyMerged++
+		}
+
+		if _, ok := hints[clusterKey]; !ok {
+			hints[clusterKey] = fmt.Sprintf("%s - %s", metadata.TagArtist, metadata.TagTitle)
+		}
+		touched[clusterKey] = true
+		newMembers = append(newMembers, &store.ClusterMember{
+			ClusterKey:   clusterKey,
+			FileID:       file.ID,
+			QualityScore: 0, // Will be set by scorer
+			Preferred:    false,
+			Confidence:   confidence,
+		})
+		result.FilesGrouped++
+	}
+
+	// Files that disappeared or failed re-extraction leave their clusters
+	var removedIDs []int64
+	for _, cf := range removed {
+		removedIDs = append(removedIDs, cf.FileID)
+		if key, ok := currentKeys[cf.FileID]; ok {
+			touched[key] = true
+		}
+		result.FilesRemoved++
+	}
+
+	// Write changes: drop old memberships, then add new ones
+	if err := c.store.DeleteClusterMembersByFileIDs(append(moveIDs, removedIDs...)); err != nil {
+		return nil, fmt.Errorf("failed to remove cluster members: %w", err)
+	}
+	if err := c.store.DeletePlansByFileIDs(removedIDs); err != nil {
+		return nil, fmt.Errorf("failed to remove plans: %w", err)
+	}
+
+	var newClusters []*store.Cluster
+	for key, hint := range hints {
+		newClusters = append(newClusters, &store.Cluster{ClusterKey: key, Hint: hint})
+	}
+	if err := c.store.EnsureClusterBatch(newClusters); err != nil {
+		return nil, fmt.Errorf("failed to insert clusters: %w", err)
+	}
+	if err := c.store.InsertClusterMemberBatch(newMembers); err != nil {
+		return nil, fmt.Errorf("failed to insert cluster members: %w", err)
+	}
+	bridgedKeys := make(map[int64]string)
+	for target, ids := range bridged {
+		if final, ok := resolved[target]; ok && final != target {
+			touched[final] = true
+			target = final
+		}
+		// Files placed by identifiers or overrides only get their heuristic key updated
+		var moving []int64
+		for _, id := range ids {
+			if currentKeys[id] == heuristicKeys[id] {
+				moving = append(moving, id)
+			}
+			bridgedKeys[id] = target
+		}
+		if err := c.store.MoveClusterMembers(moving, target); err != nil {
+			return nil, fmt.Errorf("failed to link clusters into %s: %w", target, err)
+		}
+	}
+	if err := c.store.UpsertClusteredFileBatch(tracked); err != nil {
+		return nil, fmt.Errorf("failed to record clustered files: %w", err)
+	}
+	if err := c.store.SetHeuristicKeys(bridgedKeys); err != nil {
+		return nil, fmt.Errorf("failed to record heuristic cluster keys: %w", err)
+	}
+
+	touchedKeys := make([]string, 0, len(touched))
+	for key := range touched {
+		touchedKeys = append(touchedKeys, key)
+	}
+	sort.Strings(touchedKeys)
+
+	emptied, err := c.store.DeleteEmptyClusters(touchedKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete empty clusters: %w", err)
+	}
+	if err := c.store.MarkClustersDirty(touchedKeys); err != nil {
+		return nil, fmt.Errorf("failed to mark clusters dirty: %w", err)
+	}
+	result.ClustersTouched = len(touchedKeys) - emptied
+
+	// Log cluster events for files that joined a cluster (if logger enabled)
+	if c.logger != nil {
+		filesByID := make(map[int64]*store.File, len(candidates))
+		for _, candidate := range candidates {
+			filesByID[candidate.File.ID] = candidate.File
+		}
+		memberCounts := make(map[string]int)
+		for _, member := range newMembers {
+			if _, ok := memberCounts[member.ClusterKey]; !ok {
+				members, _ := c.store.GetClusterMembers(member.ClusterKey)
+				memberCounts[member.ClusterKey] = len(members)
+			}
+			file := filesByID[member.FileID]
+			c.logger.LogCluster(file.FileKey, file.SrcPath, member.ClusterKey, memberCounts[member.ClusterKey])
+		}
+	}
+
+	util.InfoLog("Database write complete in %v", time.Since(startTime))
+	util.SuccessLog("Incremental clustering complete: %d added, %d moved, %d removed (%d clusters need rescoring)",
+		result.FilesAdded, result.FilesMoved, result.FilesRemoved, result.ClustersTouched)
+
+	return c.fillTotals(result)
+}
+
+// fillTotals sets cluster totals on a result from the current database state
+func (c *Clusterer) fillTotals(result *Result) (*Result, error) {
+	clusterCount, err := c.store.CountClusters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count clusters: %w", err)
+	}
+	duplicateCount, _ := c.store.CountDuplicateClusters()
+
+	result.ClustersCreated = clusterCount
+	result.SingletonClusters = clusterCount - duplicateCount
+	result.DuplicateClusters = duplicateCount
+	return result, nil
+}
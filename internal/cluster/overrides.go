@@ -0,0 +1,200 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// ApplyOverrides applies manual merge, split and exclude overrides to the current clusters
+// Pins are applied by the scorer when selecting winners. Splits follow their file
+// and merges follow the member files recorded with them, so both survive cluster
+// key changes (new aliases, key version bumps). Overrides that no longer match
+// any clustered file are logged and skipped.
+// Returns the number of files moved or removed; touched clusters are marked dirty.
+func (c *Clusterer) ApplyOverrides(ctx context.Context) (int, error) {
+	overrides, err := c.store.GetClusterOverrides()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load cluster overrides: %w", err)
+	}
+	if len(overrides) == 0 {
+		return 0, nil
+	}
+
+	currentKeys, err := c.store.GetFileClusterKeys()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load cluster memberships: %w", err)
+	}
+
+	touched := make(map[string]bool)
+	applied := 0
+
+	// Apply in a fixed order: exclude, then split, then merge
+	var excludedIDs []int64
+	for _, o := range overrides {
+		if o.Kind != store.OverrideExclude {
+			continue
+		}
+		if key, ok := currentKeys[o.FileID]; ok {
+			excludedIDs = append(excludedIDs, o.FileID)
+			touched[key] = true
+			delete(currentKeys, o.FileID)
+		}
+	}
+	if err := c.store.RemoveClusterMembers(excludedIDs); err != nil {
+		return 0, fmt.Errorf("failed to exclude files: %w", err)
+	}
+	if err := c.store.DeletePlansByFileIDs(excludedIDs); err != nil {
+		return 0, fmt.Errorf("failed to remove plans for excluded files: %w", err)
+	}
+	applied += len(excludedIDs)
+
+	moves := make(map[string][]int64) // target key -> files
+	move := func(fileID int64, to string) {
+		touched[currentKeys[fileID]] = true
+		moves[to] = append(moves[to], fileID)
+		currentKeys[fileID] = to
+	}
+
+	for _, o := range overrides {
+		if ctx.Err() != nil {
+			return applied, ctx.Err()
+		}
+		if !OverrideMatches(o, currentKeys) {
+			util.WarnLog("Override #%d (%s %s) no longer matches any clustered file; remove it with 'mlc cluster unset %d'",
+				o.ID, o.Kind, o.ClusterKey, o.ID)
+			continue
+		}
+
+		switch o.Kind {
+		case store.OverrideSplit:
+			if currentKeys[o.FileID] != o.OtherKey {
+				move(o.FileID, o.OtherKey)
+			}
+		case store.OverrideMerge:
+			target, other := resolveMerge(o, currentKeys)
+			if target != o.ClusterKey || other != o.OtherKey {
+				// Later files with the new keys are merged too
+				if err := c.store.UpdateClusterOverrideKeys(o.ID, target, other); err != nil {
+					return applied, fmt.Errorf("failed to update override #%d: %w", o.ID, err)
+				}
+			}
+			for fileID, key := range currentKeys {
+				if key != target && (key == other || key == o.OtherKey) {
+					move(fileID, target)
+				}
+			}
+		}
+	}
+
+	targets := make([]string, 0, len(moves))
+	for key := range moves {
+		targets = append(targets, key)
+	}
+	sort.Strings(targets)
+
+	var newClusters []*store.Cluster
+	for _, key := range targets {
+		newClusters = append(newClusters, &store.Cluster{ClusterKey: key, Hint: c.hintForFile(moves[key][0])})
+	}
+	if err := c.store.EnsureClusterBatch(newClusters); err != nil {
+		return applied, fmt.Errorf("failed to create override clusters: %w", err)
+	}
+
+	for _, key := range targets {
+		if err := c.store.MoveClusterMembers(moves[key], key); err != nil {
+			return applied, fmt.Errorf("failed to move files to %s: %w", key, err)
+		}
+		touched[key] = true
+		applied += len(moves[key])
+	}
+
+	if applied == 0 {
+		return 0, nil
+	}
+
+	touchedKeys := make([]string, 0, len(touched))
+	for key := range touched {
+		touchedKeys = append(touchedKeys, key)
+	}
+	sort.Strings(touchedKeys)
+
+	if _, err := c.store.DeleteEmptyClusters(touchedKeys); err != nil {
+		return applied, fmt.Errorf("failed to delete empty clusters: %w", err)
+	}
+	if err := c.store.MarkClustersDirty(touchedKeys); err != nil {
+		return applied, fmt.Errorf("failed to mark clusters dirty: %w", err)
+	}
+
+	util.InfoLog("Applied manual overrides: %d files moved or excluded", applied)
+	return applied, nil
+}
+
+// OverrideMatches reports whether an override still applies to the current
+// clusters: its file is clustered, or for merges either cluster can be found
+// by its member files or its recorded key. Excludes always apply.
+func OverrideMatches(o *store.ClusterOverride, currentKeys map[int64]string) bool {
+	switch o.Kind {
+	case store.OverrideExclude:
+		return true
+	case store.OverrideMerge:
+		_, hasTarget := currentKeys[o.FileID]
+		_, hasOther := currentKeys[o.OtherFileID]
+		if hasTarget || hasOther {
+			return true
+		}
+		for _, key := range currentKeys {
+			if key == o.ClusterKey || key == o.OtherKey {
+				return true
+			}
+		}
+		return false
+	default:
+		_, ok := currentKeys[o.FileID]
+		return ok
+	}
+}
+
+// resolveMerge returns the current keys of the clusters a merge override joins,
+// located by the member files recorded with it and falling back to its stored keys
+func resolveMerge(o *store.ClusterOverride, currentKeys map[int64]string) (target, other string) {
+	target, other = o.ClusterKey, o.OtherKey
+	if key, ok := currentKeys[o.FileID]; ok {
+		target = key
+	}
+	if key, ok := currentKeys[o.OtherFileID]; ok && key != target {
+		other = key
+	}
+	return target, other
+}
+
+// applyOverridesToResult applies manual overrides and refreshes cluster totals when anything changed
+func (c *Clusterer) applyOverridesToResult(ctx context.Context, result *Result) (*Result, error) {
+	applied, err := c.ApplyOverrides(ctx)
+	if err != nil {
+		return result, err
+	}
+	if applied == 0 {
+		return result, nil
+	}
+
+	result.OverridesApplied = applied
+	return c.fillTotals(result)
+}
+
+// hintForFile builds a cluster hint from a file's metadata
+func (c *Clusterer) hintForFile(fileID int64) string {
+	metadata, _ := c.store.GetMetadata(fileID)
+	if metadata == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s - %s", metadata.TagArtist, metadata.TagTitle)
+}
+
+// SplitClusterKey returns the key of the cluster created when files are split out of clusterKey
+func SplitClusterKey(clusterKey string, firstFileID int64) string {
+	return fmt.Sprintf("%s|split%d", clusterKey, firstFileID)
+}
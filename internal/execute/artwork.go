@@ -0,0 +1,148 @@
+package execute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// CoverFileName is the album cover written into each destination album folder
+const CoverFileName = "cover.jpg"
+
+// DefaultArtworkMaxSize is the default longest side of embedded covers in pixels
+const DefaultArtworkMaxSize = 1000
+
+// writeArtwork writes the planned album covers: cover.jpg into album folders
+// that lack one and, when enabled, the cover embedded into the tracks copied or
+// moved in this run
+func (e *Executor) writeArtwork(ctx context.Context, plans []*store.Plan, filesMap map[int64]*store.File, written map[string]bool, result *Result) {
+	covers, err := e.store.GetAlbumCovers()
+	if err != nil {
+		util.WarnLog("Failed to load album covers: %v", err)
+		return
+	}
+	if len(covers) == 0 {
+		return
+	}
+
+	destByFile := make(map[int64]string, len(plans))
+	tracksByDir := make(map[string][]string)
+	for _, plan := range plans {
+		destByFile[plan.FileID] = plan.DestPath
+		if written[plan.DestPath] {
+			dir := filepath.Dir(plan.DestPath)
+			tracksByDir[dir] = append(tracksByDir[dir], plan.DestPath)
+		}
+	}
+
+	util.InfoLog("Writing album artwork (%d albums)", len(covers))
+	for _, cover := range covers {
+		if ctx.Err() != nil {
+			return
+		}
+
+		coverPath := filepath.Join(cover.AlbumDir, CoverFileName)
+		needCoverFile := false
+		if e.artwork {
+			if _, err := os.Stat(coverPath); os.IsNotExist(err) {
+				needCoverFile = true
+			}
+		}
+		tracks := tracksByDir[cover.AlbumDir]
+		if !e.embedArtwork {
+			tracks = nil
+		}
+		if !needCoverFile && len(tracks) == 0 {
+			continue
+		}
+		if _, err := os.Stat(cover.AlbumDir); err != nil {
+			continue // Album not executed (yet)
+		}
+
+		data, err := e.readCover(cover, filesMap, destByFile)
+		if err != nil {
+			util.WarnLog("Failed to read cover for %s: %v", cover.AlbumDir, err)
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if needCoverFile {
+			if err := writeCoverFile(coverPath, data); err != nil {
+				util.WarnLog("Failed to write %s: %v", coverPath, err)
+				result.Errors = append(result.Errors, err)
+			} else {
+				result.CoversWritten++
+				util.DebugLog("Wrote cover: %s", coverPath)
+			}
+		}
+
+		if len(tracks) == 0 {
+			continue
+		}
+		embedded, _, _, err := meta.CoverJPEG(data, e.artworkMaxSize)
+		if err != nil {
+			util.WarnLog("Failed to prepare cover for %s: %v", cover.AlbumDir, err)
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		for _, track := range tracks {
+			if err := meta.EmbedCover(track, embedded); err != nil {
+				if errors.Is(err, meta.ErrArtworkUnsupported) {
+					util.DebugLog("Not embedding cover in %s: %v", track, err)
+					continue
+				}
+				util.WarnLog("Failed to embed cover in %s: %v", track, err)
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+			result.CoversEmbedded++
+		}
+	}
+}
+
+// readCover reads the chosen picture from the file it is embedded in, which
+// after a move is at its destination path
+func (e *Executor) readCover(cover *store.AlbumCover, filesMap map[int64]*store.File, destByFile map[int64]string) ([]byte, error) {
+	var paths []string
+	if file, ok := filesMap[cover.FileID]; ok {
+		paths = append(paths, file.SrcPath)
+	}
+	if dest := destByFile[cover.FileID]; dest != "" {
+		paths = append(paths, dest)
+	}
+
+	var lastErr error = fmt.Errorf("file %d not found", cover.FileID)
+	for _, path := range paths {
+		data, err := meta.ReadPicture(path, cover.SHA1)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// writeCoverFile writes cover.jpg at its original size, converting other image
+// formats to JPEG
+func writeCoverFile(path string, data []byte) error {
+	jpegData, _, _, err := meta.CoverJPEG(data, 0)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".part"
+	if err := os.WriteFile(tempPath, jpegData, 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write cover: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename cover: %w", err)
+	}
+	return nil
+}
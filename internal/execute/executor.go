@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,9 +18,17 @@ import (
 	"github.com/franz/music-janitor/internal/util"
 )
 
+// Repo is the state execution reads and writes
+type Repo interface {
+	store.FileRepo
+	store.MetadataRepo
+	store.PlanRepo
+	store.ExecutionRepo
+}
+
 // Executor executes the planned actions (copy/move/link)
 type Executor struct {
-	store       *store.Store
+	store       Repo
 	concurrency int
 	verifyMode  string // "none", "size", "hash"
 	dryRun      bool
@@ -27,11 +36,17 @@ type Executor struct {
 	bufferSize  int // Buffer size for file copying (bytes)
 	retryConfig *util.RetryConfig
 	logger      *report.EventLogger
+
+	artwork        bool // Write cover.jpg into album folders
+	embedArtwork   bool // Embed album covers in copied/moved tracks
+	artworkMaxSize int  // Longest side of embedded covers in pixels
+
+	writeInferredTags bool // Write inferred values below meta.DefaultMinWriteConfidence as tags
 }
 
 // Config holds executor configuration
 type Config struct {
-	Store       *store.Store
+	Store       Repo
 	Concurrency int
 	VerifyMode  string // "none", "size", "hash"
 	DryRun      bool
@@ -39,6 +54,14 @@ type Config struct {
 	BufferSize  int         // Buffer size for file copying (0 = use default)
 	RetryConfig *util.RetryConfig // Retry configuration (nil = use default)
 	Logger      *report.EventLogger
+
+	Artwork        bool // Write cover.jpg into album folders from the planned covers
+	EmbedArtwork   bool // Embed the album cover in copied/moved tracks
+	ArtworkMaxSize int  // Longest side of embedded covers in pixels (0 = DefaultArtworkMaxSize)
+
+	// WriteInferredTags also writes low-confidence values guessed from file and
+	// folder names as tags; by default only tags and confident guesses are written
+	WriteInferredTags bool
 }
 
 // New creates a new Executor
@@ -54,6 +77,9 @@ func New(cfg *Config) *Executor {
 		// Can be increased to 256KB+ for NAS via config or auto-tuning
 		cfg.BufferSize = 128 * 1024
 	}
+	if cfg.ArtworkMaxSize <= 0 {
+		cfg.ArtworkMaxSize = DefaultArtworkMaxSize
+	}
 	if cfg.RetryConfig == nil {
 		// Use default retry config (no retries for local, can be overridden for NAS)
 		cfg.RetryConfig = &util.RetryConfig{
@@ -72,6 +98,12 @@ func New(cfg *Config) *Executor {
 		bufferSize:  cfg.BufferSize,
 		retryConfig: cfg.RetryConfig,
 		logger:      cfg.Logger,
+
+		artwork:        cfg.Artwork,
+		embedArtwork:   cfg.EmbedArtwork,
+		artworkMaxSize: cfg.ArtworkMaxSize,
+
+		writeInferredTags: cfg.WriteInferredTags,
 	}
 }
 
@@ -83,6 +115,9 @@ type Result struct {
 	Failed       int
 	BytesWritten int64
 	Errors       []error
+
+	CoversWritten  int // cover.jpg files written
+	CoversEmbedded int // Tracks the album cover was embedded in
 }
 
 // Execute executes all planned actions
@@ -136,6 +171,16 @@ func (e *Executor) Execute(ctx context.Context) (*Result, error) {
 			return nil, fmt.Errorf("failed to load metadata: %w", err)
 		}
 		util.InfoLog("Loaded %d metadata records", len(metadataMap))
+
+		provenance, err := e.store.GetInferredProvenance()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load metadata provenance: %w", err)
+		}
+		for fileID, p := range provenance {
+			if m, ok := metadataMap[fileID]; ok {
+				m.Provenance = p
+			}
+		}
 	}
 
 	result := &Result{
@@ -266,6 +311,10 @@ func (e *Executor) Execute(ctx context.Context) (*Result, error) {
 		}
 	}()
 
+	// Destination paths copied or moved in this run, for embedding artwork
+	var writtenMu sync.Mutex
+	written := make(map[string]bool)
+
 	// Create worker pool
 	plansChan := make(chan *store.Plan, e.concurrency*2)
 	doneChan := make(chan struct{})
@@ -295,6 +344,11 @@ func (e *Executor) Execute(ctx context.Context) (*Result, error) {
 				} else {
 					succeeded.Add(1)
 					bytesWritten.Add(bytes)
+					if file := filesMap[plan.FileID]; plan.Action == "copy" || plan.Action == "move" || (file != nil && util.IsArchivePath(file.SrcPath)) {
+						writtenMu.Lock()
+						written[plan.DestPath] = true
+						writtenMu.Unlock()
+					}
 				}
 			}
 			doneChan <- struct{}{}
@@ -325,6 +379,11 @@ func (e *Executor) Execute(ctx context.Context) (*Result, error) {
 
 	cancelProgress()
 
+	// Album artwork once the tracks are in place
+	if (e.artwork || e.embedArtwork) && !e.dryRun && ctx.Err() == nil {
+		e.writeArtwork(ctx, plans, filesMap, written, result)
+	}
+
 	// Update final counts
 	result.Processed = int(processed.Load())
 	result.Succeeded = int(succeeded.Load())
@@ -360,8 +419,12 @@ func (e *Executor) executePlan(ctx context.Context, plan *store.Plan) (int64, er
 		StartedAt: time.Now(),
 	}
 
-	// Execute based on action
+	// Execute based on action; archive entries can only be extracted
 	var bytesWritten int64
+	action := plan.Action
+	if util.IsArchivePath(file.SrcPath) {
+		action = "copy"
+	}
 
 	if e.dryRun {
 		// Dry run - just log what would happen
@@ -369,7 +432,7 @@ func (e *Executor) executePlan(ctx context.Context, plan *store.Plan) (int64, er
 		bytesWritten = file.SizeBytes
 		exec.VerifyOK = true
 	} else {
-		switch plan.Action {
+		switch action {
 		case "copy":
 			bytesWritten, err = e.copyFile(ctx, file.SrcPath, plan.DestPath)
 		case "move":
@@ -379,7 +442,7 @@ func (e *Executor) executePlan(ctx context.Context, plan *store.Plan) (int64, er
 		case "symlink":
 			bytesWritten, err = e.symlinkFile(file.SrcPath, plan.DestPath)
 		default:
-			return 0, fmt.Errorf("unknown action: %s", plan.Action)
+			return 0, fmt.Errorf("unknown action: %s", action)
 		}
 
 		if err != nil {
@@ -392,15 +455,18 @@ func (e *Executor) executePlan(ctx context.Context, plan *store.Plan) (int64, er
 		exec.BytesWritten = bytesWritten
 
 		// Write enriched metadata tags to destination file (if enabled)
-		if e.writeTags && (plan.Action == "copy" || plan.Action == "move") {
+		if e.writeTags && (action == "copy" || action == "move") {
 			if meta.CanWriteTags(plan.DestPath) {
 				// Get metadata for this file
 				metadata, metaErr := e.store.GetMetadata(file.ID)
+				if metaErr == nil && metadata != nil {
+					metadata.Provenance, metaErr = e.store.GetProvenance(file.ID)
+				}
 				if metaErr != nil {
 					util.WarnLog("Failed to get metadata for tag writing (file %d): %v", file.ID, metaErr)
 				} else if metadata != nil {
 					// Write tags to destination file
-					if tagErr := meta.WriteTagsToFile(plan.DestPath, metadata); tagErr != nil {
+					if tagErr := meta.WriteTagsToFile(plan.DestPath, e.tagsToWrite(file, metadata)); tagErr != nil {
 						util.WarnLog("Failed to write tags to %s: %v", plan.DestPath, tagErr)
 						// Don't fail the entire operation - just log the warning
 					} else {
@@ -454,6 +520,19 @@ func (e *Executor) executePlan(ctx context.Context, plan *store.Plan) (int64, er
 	return bytesWritten, nil
 }
 
+// tagsToWrite returns the metadata to write as tags: without inferred values
+// below meta.DefaultMinWriteConfidence unless writing them was requested
+func (e *Executor) tagsToWrite(file *store.File, metadata *store.Metadata) *store.Metadata {
+	if e.writeInferredTags {
+		return metadata
+	}
+	tags, blocked := meta.WithoutUncertainFields(metadata, metadata.Provenance, meta.DefaultMinWriteConfidence)
+	if len(blocked) > 0 {
+		util.DebugLog("Not writing low-confidence inferred tags for %s: %s", file.SrcPath, strings.Join(blocked, ", "))
+	}
+	return tags
+}
+
 // executePlanOptimized executes a single plan using pre-loaded data and batch operations
 func (e *Executor) executePlanOptimized(
 	ctx context.Context,
@@ -487,8 +566,12 @@ func (e *Executor) executePlanOptimized(
 		StartedAt: time.Now(),
 	}
 
-	// Execute based on action
+	// Execute based on action; archive entries can only be extracted
 	var bytesWritten int64
+	action := plan.Action
+	if util.IsArchivePath(file.SrcPath) {
+		action = "copy"
+	}
 	var err error
 
 	if e.dryRun {
@@ -496,7 +579,7 @@ func (e *Executor) executePlanOptimized(
 		bytesWritten = file.SizeBytes
 		exec.VerifyOK = true
 	} else {
-		switch plan.Action {
+		switch action {
 		case "copy":
 			bytesWritten, err = e.copyFile(ctx, file.SrcPath, plan.DestPath)
 		case "move":
@@ -506,7 +589,7 @@ func (e *Executor) executePlanOptimized(
 		case "symlink":
 			bytesWritten, err = e.symlinkFile(file.SrcPath, plan.DestPath)
 		default:
-			return 0, fmt.Errorf("unknown action: %s", plan.Action)
+			return 0, fmt.Errorf("unknown action: %s", action)
 		}
 
 		if err != nil {
@@ -519,7 +602,7 @@ func (e *Executor) executePlanOptimized(
 		exec.BytesWritten = bytesWritten
 
 		// Write enriched metadata tags to destination file (if enabled)
-		if e.writeTags && (plan.Action == "copy" || plan.Action == "move") {
+		if e.writeTags && (action == "copy" || action == "move") {
 			if meta.CanWriteTags(plan.DestPath) {
 				// Get metadata from pre-loaded map
 				metadata, metaExists := metadataMap[file.ID]
@@ -527,7 +610,7 @@ func (e *Executor) executePlanOptimized(
 					util.WarnLog("Failed to get metadata for tag writing (file %d): not in map", file.ID)
 				} else if metadata != nil {
 					// Write tags to destination file
-					if tagErr := meta.WriteTagsToFile(plan.DestPath, metadata); tagErr != nil {
+					if tagErr := meta.WriteTagsToFile(plan.DestPath, e.tagsToWrite(file, metadata)); tagErr != nil {
 						util.WarnLog("Failed to write tags to %s: %v", plan.DestPath, tagErr)
 					} else {
 						util.DebugLog("Successfully wrote enriched tags to: %s", plan.DestPath)
@@ -596,8 +679,14 @@ func (e *Executor) copyFile(ctx context.Context, srcPath, destPath string) (int6
 		return 0, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Open source file with retry
-	src, err := util.RetryableOpen(srcPath, e.retryConfig)
+	// Open source file with retry, or stream the entry out of its archive
+	var src io.ReadCloser
+	var err error
+	if util.IsArchivePath(srcPath) {
+		src, err = util.OpenArchiveEntry(srcPath)
+	} else {
+		src, err = util.RetryableOpen(srcPath, e.retryConfig)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to open source: %w", err)
 	}
@@ -846,7 +935,7 @@ func (e *Executor) verifyHashWithRetry(srcPath, destPath string, originalSrcHash
 
 // hashFile computes SHA1 hash of a file
 func hashFile(path string) (string, error) {
-	f, err := os.Open(path)
+	f, err := util.OpenSource(path)
 	if err != nil {
 		return "", err
 	}
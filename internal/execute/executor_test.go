@@ -1,6 +1,7 @@
 package execute
 
 import (
+	"archive/zip"
 	"context"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
 )
 
 func setupTestDB(t *testing.T) (*store.Store, string) {
@@ -430,6 +432,61 @@ func TestExecutePlanCopy(t *testing.T) {
 	}
 }
 
+func TestExecutePlanArchiveEntry(t *testing.T) {
+	db, tmpDir := setupTestDB(t)
+	defer db.Close()
+
+	archivePath := filepath.Join(tmpDir, "album.zip")
+	content := []byte("flac entry content")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	fw, _ := w.Create("CD1/01.flac")
+	fw.Write(content)
+	w.Close()
+	f.Close()
+
+	srcPath := util.ArchiveEntryPath(archivePath, "CD1/01.flac")
+
+	// Entries can't be linked or moved; they are extracted whatever the action
+	for _, action := range []string{"hardlink", "move"} {
+		t.Run(action, func(t *testing.T) {
+			file := &store.File{
+				FileKey:   action + ":" + srcPath,
+				SrcPath:   srcPath,
+				SizeBytes: int64(len(content)),
+				Status:    "meta_ok",
+			}
+			if err := db.InsertFile(file); err != nil {
+				t.Fatalf("Failed to insert file: %v", err)
+			}
+			destPath := filepath.Join(tmpDir, action, "01.flac")
+			plan := &store.Plan{FileID: file.ID, Action: action, DestPath: destPath}
+
+			executor := New(&Config{Store: db, Concurrency: 1, VerifyMode: "hash"})
+			if _, err := executor.executePlan(context.Background(), plan); err != nil {
+				t.Fatalf("executePlan failed: %v", err)
+			}
+
+			destContent, err := os.ReadFile(destPath)
+			if err != nil || string(destContent) != string(content) {
+				t.Errorf("Expected extracted content %q, got %q (%v)", content, destContent, err)
+			}
+			if info, err := os.Lstat(destPath); err != nil || info.Mode()&os.ModeSymlink != 0 {
+				t.Errorf("Expected a regular file at %s", destPath)
+			}
+			if _, err := os.Stat(archivePath); err != nil {
+				t.Errorf("Expected the archive to be left in place: %v", err)
+			}
+			if exec, _ := db.GetExecution(file.ID); exec == nil || !exec.VerifyOK {
+				t.Errorf("Expected a verified execution, got %+v", exec)
+			}
+		})
+	}
+}
+
 func TestExecuteMultipleFiles(t *testing.T) {
 	db, tmpDir := setupTestDB(t)
 	defer db.Close()
@@ -0,0 +1,183 @@
+package integrity
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Decoded duration may differ from the header duration by this much, or by
+// durationToleranceFraction of it if larger, before a file counts as truncated
+const (
+	durationToleranceMs       = 1000
+	durationToleranceFraction = 0.02
+)
+
+// ffmpeg -progress writes key=value lines; anything else on stderr is a decode error
+var progressLine = regexp.MustCompile(`^[a-z_0-9]+=`)
+
+// FLAC's MD5 covers interleaved little-endian samples at the stream's sample size
+var flacMD5Codecs = map[int]string{
+	8:  "pcm_s8",
+	16: "pcm_s16le",
+	24: "pcm_s24le",
+	32: "pcm_s32le",
+}
+
+// decodeOutput is what a full decode of a file reported
+type decodeOutput struct {
+	errors     []string // Decode error lines
+	decodedMs  int      // Duration reached; 0 if unknown
+	md5        string   // Hex MD5 of the decoded samples, when requested
+	exitFailed bool     // ffmpeg exited with an error
+}
+
+// CheckFile decodes a file with ffmpeg and compares the result with its metadata:
+// decode errors, decoded duration against the header duration, and for FLAC the
+// STREAMINFO MD5 signature
+// Returns an error only if the check could not run (e.g. ffmpeg missing)
+func CheckFile(ctx context.Context, path string, m *store.Metadata) (*store.IntegrityResult, error) {
+	wantMD5 := ""
+	md5Codec := ""
+	if strings.EqualFold(m.Codec, "flac") {
+		if sig, bits, err := meta.ReadFLACSignature(path); err == nil && sig != ([16]byte{}) && flacMD5Codecs[bits] != "" {
+			wantMD5 = hex.EncodeToString(sig[:])
+			md5Codec = flacMD5Codecs[bits]
+		}
+	}
+
+	out, err := decode(ctx, path, md5Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &store.IntegrityResult{
+		FileID:            m.FileID,
+		Status:            store.IntegrityOK,
+		DecodedDurationMs: out.decodedMs,
+	}
+	if reason := evaluate(m, out, wantMD5); reason != "" {
+		result.Status = store.IntegrityDamaged
+		result.Error = reason
+	}
+	return result, nil
+}
+
+// decode runs ffmpeg over the first audio stream, discarding the output or, with
+// md5Codec set, hashing it as raw samples of that codec
+// Archive entries are streamed to ffmpeg on stdin.
+func decode(ctx context.Context, path, md5Codec string) (*decodeOutput, error) {
+	input := path
+	var stdin io.Reader
+	if util.IsArchivePath(path) {
+		src, err := util.OpenSourceReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		input, stdin = "pipe:0", src.Reader()
+	}
+
+	args := []string{"-v", "error", "-nostdin", "-nostats", "-progress", "pipe:2", "-i", input, "-map", "0:a:0"}
+	if md5Codec != "" {
+		args = append(args, "-c:a", md5Codec, "-f", "md5", "-")
+	} else {
+		args = append(args, "-f", "null", "-")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return nil, fmt.Errorf("failed to run ffmpeg: %w", runErr)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	out := parseDecodeOutput(stderr.String(), stdout.String())
+	out.exitFailed = runErr != nil
+	return out, nil
+}
+
+// parseDecodeOutput splits ffmpeg's stderr into progress and error lines and
+// reads the md5 muxer's "MD5=<hex>" from stdout
+func parseDecodeOutput(stderr, stdout string) *decodeOutput {
+	out := &decodeOutput{}
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !progressLine.MatchString(line) {
+			out.errors = append(out.errors, line)
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		if key == "out_time_us" {
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+				out.decodedMs = int(us / 1000)
+			}
+		}
+	}
+	if _, sum, ok := strings.Cut(strings.TrimSpace(stdout), "MD5="); ok {
+		out.md5 = strings.ToLower(strings.TrimSpace(sum))
+	}
+	return out
+}
+
+// evaluate returns why a decode shows the file is damaged, or "" if it is fine
+func evaluate(m *store.Metadata, out *decodeOutput, wantMD5 string) string {
+	var reasons []string
+
+	if len(out.errors) > 0 {
+		reason := "decode error: " + out.errors[0]
+		if len(out.errors) > 1 {
+			reason += fmt.Sprintf(" (+%d more)", len(out.errors)-1)
+		}
+		reasons = append(reasons, reason)
+	} else if out.exitFailed {
+		reasons = append(reasons, "decode failed")
+	}
+
+	if m.DurationMs > 0 && out.decodedMs > 0 {
+		tolerance := int(float64(m.DurationMs) * durationToleranceFraction)
+		if tolerance < durationToleranceMs {
+			tolerance = durationToleranceMs
+		}
+		if diff := m.DurationMs - out.decodedMs; diff > tolerance || -diff > tolerance {
+			reasons = append(reasons, fmt.Sprintf("duration mismatch: decoded %s of %s",
+				formatMs(out.decodedMs), formatMs(m.DurationMs)))
+		}
+	} else if m.DurationMs > 0 && !out.exitFailed && len(out.errors) == 0 && out.decodedMs == 0 {
+		reasons = append(reasons, "no audio decoded")
+	}
+
+	if wantMD5 != "" && out.md5 != "" && out.md5 != wantMD5 {
+		reasons = append(reasons, "FLAC MD5 signature mismatch")
+	}
+
+	return strings.Join(reasons, "; ")
+}
+
+// formatMs formats a duration as m:ss
+func formatMs(ms int) string {
+	s := ms / 1000
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
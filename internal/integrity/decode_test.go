@@ -0,0 +1,99 @@
+package integrity
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestParseDecodeOutput(t *testing.T) {
+	stderr := strings.Join([]string{
+		"out_time_us=1000000",
+		"progress=continue",
+		"[mp3float @ 0x55] Header missing",
+		"Error while decoding stream #0:0: Invalid data found when processing input",
+		"out_time_us=123456789",
+		"speed=500x",
+		"progress=end",
+		"",
+	}, "\n")
+	out := parseDecodeOutput(stderr, "MD5=0123ABCDef\n")
+
+	if out.decodedMs != 123456 {
+		t.Errorf("decodedMs = %d, want 123456", out.decodedMs)
+	}
+	if len(out.errors) != 2 || out.errors[0] != "[mp3float @ 0x55] Header missing" {
+		t.Errorf("errors = %q", out.errors)
+	}
+	if out.md5 != "0123abcdef" {
+		t.Errorf("md5 = %q, want 0123abcdef", out.md5)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	const md5 = "d41d8cd98f00b204e9800998ecf8427e"
+	testCases := []struct {
+		name       string
+		durationMs int
+		out        decodeOutput
+		wantMD5    string
+		wantReason string
+	}{
+		{"clean decode", 240000, decodeOutput{decodedMs: 239990}, "", ""},
+		{"within 2% tolerance", 600000, decodeOutput{decodedMs: 590000}, "", ""},
+		{"truncated", 540000, decodeOutput{decodedMs: 120000}, "", "duration mismatch: decoded 2:00 of 9:00"},
+		{"short file within one second", 3000, decodeOutput{decodedMs: 2100}, "", ""},
+		{"decode errors", 240000, decodeOutput{decodedMs: 240000, errors: []string{"Header missing", "Invalid data"}}, "", "decode error: Header missing (+1 more)"},
+		{"exit without message", 240000, decodeOutput{decodedMs: 240000, exitFailed: true}, "", "decode failed"},
+		{"nothing decoded", 240000, decodeOutput{}, "", "no audio decoded"},
+		{"md5 match", 240000, decodeOutput{decodedMs: 240000, md5: md5}, md5, ""},
+		{"md5 mismatch", 240000, decodeOutput{decodedMs: 240000, md5: "00000000000000000000000000000000"}, md5, "FLAC MD5 signature mismatch"},
+		{"errors and truncation", 540000, decodeOutput{decodedMs: 120000, errors: []string{"Packet corrupt"}}, "",
+			"decode error: Packet corrupt; duration mismatch: decoded 2:00 of 9:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := tc.out
+			got := evaluate(&store.Metadata{DurationMs: tc.durationMs}, &out, tc.wantMD5)
+			if got != tc.wantReason {
+				t.Errorf("evaluate = %q, want %q", got, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestCheckFileWithFFmpeg(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg not available")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tone.flac")
+	gen := exec.Command("ffmpeg", "-v", "error", "-f", "lavfi", "-i", "sine=frequency=440:duration=3",
+		"-c:a", "flac", path)
+	if out, err := gen.CombinedOutput(); err != nil {
+		t.Skipf("failed to generate test file: %v: %s", err, out)
+	}
+
+	r, err := CheckFile(context.Background(), path, &store.Metadata{FileID: 7, Codec: "flac", DurationMs: 3000})
+	if err != nil {
+		t.Fatalf("CheckFile failed: %v", err)
+	}
+	if r.Status != store.IntegrityOK || r.FileID != 7 {
+		t.Errorf("result = %+v, want ok for file 7", r)
+	}
+
+	// A header claiming 9 seconds for 3 seconds of audio is a truncated file
+	r, err = CheckFile(context.Background(), path, &store.Metadata{FileID: 7, Codec: "flac", DurationMs: 9000})
+	if err != nil {
+		t.Fatalf("CheckFile failed: %v", err)
+	}
+	if r.Status != store.IntegrityDamaged {
+		t.Errorf("result = %+v, want damaged", r)
+	}
+}
@@ -0,0 +1,180 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/franz/music-janitor/internal/report"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Modes for the integrity stage
+const (
+	ModeOff     = "off"     // No decode checks
+	ModeWinners = "winners" // Check cluster winners, rescoring clusters whose winner is damaged
+	ModeAll     = "all"     // Check every file, rescoring clusters whose winner is damaged
+)
+
+// ValidMode reports whether mode is a known integrity mode
+func ValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeWinners || mode == ModeAll
+}
+
+// Checker decodes files to find corrupt and truncated ones
+type Checker struct {
+	store       *store.Store
+	logger      *report.EventLogger
+	concurrency int
+}
+
+// Config holds checker configuration
+type Config struct {
+	Store       *store.Store
+	Logger      *report.EventLogger
+	Concurrency int
+}
+
+// Result summarizes an integrity run
+type Result struct {
+	FilesChecked int
+	Damaged      int
+	Errors       []error
+}
+
+// New creates a new integrity checker
+func New(cfg *Config) *Checker {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = report.NullLogger()
+	}
+	return &Checker{
+		store:       cfg.Store,
+		logger:      logger,
+		concurrency: concurrency,
+	}
+}
+
+// ValidateFFmpeg checks that ffmpeg is available for decoding
+func ValidateFFmpeg() error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+	return nil
+}
+
+// Check decodes every file that has not been checked yet; with winnersOnly,
+// only current cluster winners
+func (c *Checker) Check(ctx context.Context, winnersOnly bool) (*Result, error) {
+	files, err := c.store.GetUncheckedFiles(winnersOnly)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	if len(files) == 0 {
+		return result, nil
+	}
+
+	metadataMap, err := c.store.GetAllMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	total := len(files)
+	util.InfoLog("Decoding %d files (concurrency: %d)", total, c.concurrency)
+
+	var processed, damaged atomic.Int64
+	var errorsMu sync.Mutex
+
+	progressCtx, cancelProgress := context.WithCancel(ctx)
+	defer cancelProgress()
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-progressCtx.Done():
+				return
+			case <-ticker.C:
+				if p := processed.Load(); p > 0 {
+					util.InfoLog("Integrity: %d/%d (%.1f%%) - damaged: %d",
+						p, total, float64(p)/float64(total)*100, damaged.Load())
+				}
+			}
+		}
+	}()
+
+	fileChan := make(chan *store.File)
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range fileChan {
+				m := metadataMap[file.ID]
+				if m == nil {
+					continue
+				}
+				r, err := CheckFile(ctx, file.SrcPath, m)
+				if err == nil {
+					err = c.store.UpdateIntegrity(r)
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						util.ErrorLog("Integrity check failed for %s: %v", file.SrcPath, err)
+						errorsMu.Lock()
+						result.Errors = append(result.Errors, fmt.Errorf("%s: %w", file.SrcPath, err))
+						errorsMu.Unlock()
+					}
+				} else if r.Status == store.IntegrityDamaged {
+					damaged.Add(1)
+					util.WarnLog("Damaged: %s (%s)", file.SrcPath, r.Error)
+					c.logger.LogIntegrity(file.FileKey, file.SrcPath, r.Error)
+				}
+				processed.Add(1)
+			}
+		}()
+	}
+
+feed:
+	for _, file := range files {
+		select {
+		case <-ctx.Done():
+			break feed
+		case fileChan <- file:
+		}
+	}
+	close(fileChan)
+	wg.Wait()
+
+	result.FilesChecked = int(processed.Load())
+	result.Damaged = int(damaged.Load())
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// DemoteDamagedWinners marks clusters whose winner is damaged for rescoring
+// Returns the number of clusters marked
+func (c *Checker) DemoteDamagedWinners() (int, error) {
+	keys, err := c.store.GetClustersWithDamagedWinners()
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := c.store.MarkClustersDirty(keys); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,195 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/util"
+	"go.yaml.in/yaml/v3"
+)
+
+// AliasMap resolves artist aliases from a user-maintained map of canonical
+// names to their aliases, e.g. "AC/DC": ["ACDC", "AC DC"]
+type AliasMap struct {
+	canonical map[string]string // Normalized alias or canonical name -> canonical name
+}
+
+// NewAliasMap builds an alias map from canonical names and their aliases
+// Returns an error when an alias is given for two different artists
+func NewAliasMap(entries map[string][]string) (*AliasMap, error) {
+	a := &AliasMap{canonical: make(map[string]string)}
+
+	// Sorted so conflicts are reported the same way every run
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		canonical := strings.TrimSpace(name)
+		if canonical == "" {
+			continue
+		}
+		for _, alias := range append([]string{canonical}, entries[name]...) {
+			key := normalizeArtistLocal(alias)
+			if key == "" {
+				continue
+			}
+			if existing, ok := a.canonical[key]; ok && existing != canonical {
+				return nil, fmt.Errorf("alias %q is listed for both %q and %q", alias, existing, canonical)
+			}
+			a.canonical[key] = canonical
+		}
+	}
+	return a, nil
+}
+
+// Len returns the number of names (aliases and canonical names) in the map
+func (a *AliasMap) Len() int {
+	return len(a.canonical)
+}
+
+// NormalizeArtistName returns the canonical name of an alias, or "" if the
+// name is not in the map
+func (a *AliasMap) NormalizeArtistName(ctx context.Context, artistName string) (string, error) {
+	return a.canonical[normalizeArtistLocal(artistName)], nil
+}
+
+// LoadAliasFile reads an alias file: a YAML map of canonical artist names to
+// lists of aliases, the same format as the alias_map config key
+func LoadAliasFile(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alias file: %w", err)
+	}
+	entries := make(map[string][]string)
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse alias file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// MergeAliases adds the aliases in add to entries, skipping aliases that are
+// already known under any canonical name, and returns the number added.
+// Existing entries always win, so learned names never override the user's
+func MergeAliases(entries, add map[string][]string) int {
+	known := make(map[string]bool)
+	for name, aliases := range entries {
+		known[normalizeArtistLocal(name)] = true
+		for _, alias := range aliases {
+			known[normalizeArtistLocal(alias)] = true
+		}
+	}
+
+	names := make([]string, 0, len(add))
+	for name := range add {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	added := 0
+	for _, name := range names {
+		for _, alias := range add[name] {
+			key := normalizeArtistLocal(alias)
+			if key == "" || known[key] || key == normalizeArtistLocal(name) {
+				continue
+			}
+			known[key] = true
+			entries[name] = append(entries[name], alias)
+			added++
+		}
+	}
+	return added
+}
+
+// WriteAliasFile writes entries as an alias file, sorted by canonical name
+func WriteAliasFile(path string, entries map[string][]string) error {
+	for name := range entries {
+		slices.Sort(entries[name])
+	}
+	data, err := yaml.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode aliases: %w", err)
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write alias file: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write alias file: %w", err)
+	}
+	return nil
+}
+
+// NormalizerChain asks each normalizer in turn, e.g. the user's alias map,
+// then cached MusicBrainz names, then the MusicBrainz API; the first canonical
+// name found wins
+type NormalizerChain []MusicBrainzNormalizer
+
+// NormalizeArtistName returns the first canonical name found, or "" and the
+// last lookup error if no normalizer knows the name
+func (c NormalizerChain) NormalizeArtistName(ctx context.Context, artistName string) (string, error) {
+	var lastErr error
+	for _, n := range c {
+		if n == nil {
+			continue
+		}
+		canonical, err := n.NormalizeArtistName(ctx, artistName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if canonical != "" {
+			return canonical, nil
+		}
+	}
+	return "", lastErr
+}
+
+// FingerprintedNormalizer is a normalizer that names its own fingerprint, so
+// normalizers resolving the same names (e.g. MusicBrainz with and without API
+// lookups) fingerprint alike
+type FingerprintedNormalizer interface {
+	MusicBrainzNormalizer
+	NormalizerFingerprint() string
+}
+
+// NormalizerFingerprint identifies the names a normalizer resolves, so a
+// change of alias map or MusicBrainz use can be noticed between runs. Alias
+// maps are identified by their entries, other normalizers by their own
+// fingerprint or their type. Repeated links of a chain count once.
+func NormalizerFingerprint(n MusicBrainzNormalizer) string {
+	switch n := n.(type) {
+	case nil:
+		return ""
+	case NormalizerChain:
+		parts := make([]string, 0, len(n))
+		for _, link := range n {
+			part := NormalizerFingerprint(link)
+			if len(parts) > 0 && parts[len(parts)-1] == part {
+				continue
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, ",")
+	case FingerprintedNormalizer:
+		return n.NormalizerFingerprint()
+	case *AliasMap:
+		if n == nil {
+			return ""
+		}
+		pairs := make([]string, 0, len(n.canonical))
+		for alias, canonical := range n.canonical {
+			pairs = append(pairs, alias+"="+canonical)
+		}
+		slices.Sort(pairs)
+		return "aliases:" + util.SettingsFingerprint(pairs...)
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
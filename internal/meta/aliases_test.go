@@ -0,0 +1,156 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAliasMap(t *testing.T) {
+	aliases, err := NewAliasMap(map[string][]string{
+		"AC/DC":       {"ACDC", "AC DC"},
+		"The Beatles": {"Beatles", "Beatles, The"},
+	})
+	if err != nil {
+		t.Fatalf("NewAliasMap failed: %v", err)
+	}
+
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"acdc", "AC/DC"},
+		{"AC-DC", "AC/DC"}, // Matched after local normalization
+		{"ac dc", "AC/DC"},
+		{"AC/DC", "AC/DC"},
+		{"beatles", "The Beatles"},
+		{"Beatles, The", "The Beatles"},
+		{"Queen", ""},
+	}
+	for _, tc := range testCases {
+		got, err := aliases.NormalizeArtistName(context.Background(), tc.input)
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizeArtistName(%q) = %q, %v; want %q", tc.input, got, err, tc.want)
+		}
+	}
+
+	if got := NormalizeArtistWith(aliases, "AC DC"); got != NormalizeArtist("AC/DC") {
+		t.Errorf("NormalizeArtistWith = %q, want %q", got, NormalizeArtist("AC/DC"))
+	}
+
+	_, err = NewAliasMap(map[string][]string{"A": {"Same"}, "B": {"same"}})
+	if err == nil || !strings.Contains(err.Error(), "both") {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+}
+
+type fakeNormalizer struct {
+	names map[string]string
+	err   error
+	calls int
+}
+
+func (f *fakeNormalizer) NormalizeArtistName(ctx context.Context, name string) (string, error) {
+	f.calls++
+	return f.names[name], f.err
+}
+
+func TestNormalizerChain(t *testing.T) {
+	aliases := &fakeNormalizer{names: map[string]string{"ACDC": "AC/DC"}}
+	cache := &fakeNormalizer{names: map[string]string{"Beatles": "The Beatles", "ACDC": "wrong"}}
+	online := &fakeNormalizer{err: errors.New("offline")}
+	chain := NormalizerChain{aliases, nil, cache, online}
+
+	if got, err := chain.NormalizeArtistName(context.Background(), "ACDC"); got != "AC/DC" || err != nil {
+		t.Errorf("alias map should win: %q, %v", got, err)
+	}
+	if cache.calls != 0 {
+		t.Error("later normalizers asked after a match")
+	}
+	if got, _ := chain.NormalizeArtistName(context.Background(), "Beatles"); got != "The Beatles" {
+		t.Errorf("cache not used: %q", got)
+	}
+	if got, err := chain.NormalizeArtistName(context.Background(), "Queen"); got != "" || err == nil {
+		t.Errorf("expected lookup error for unknown name, got %q, %v", got, err)
+	}
+	if got := NormalizeArtistWith(chain, "Queen"); got != "queen" {
+		t.Errorf("failed lookup should fall back to local rules: %q", got)
+	}
+}
+
+// fingerprintedNormalizer resolves nothing and names its fingerprint
+type fingerprintedNormalizer struct {
+	fingerprint string
+}
+
+func (f *fingerprintedNormalizer) NormalizeArtistName(ctx context.Context, name string) (string, error) {
+	return "", nil
+}
+
+func (f *fingerprintedNormalizer) NormalizerFingerprint() string {
+	return f.fingerprint
+}
+
+func TestNormalizerFingerprint(t *testing.T) {
+	aliases := func(entries map[string][]string) *AliasMap {
+		a, err := NewAliasMap(entries)
+		if err != nil {
+			t.Fatalf("NewAliasMap failed: %v", err)
+		}
+		return a
+	}
+	acdc := aliases(map[string][]string{"AC/DC": {"ACDC"}})
+	base := NormalizerFingerprint(NormalizerChain{acdc, &fakeNormalizer{}})
+
+	if got := NormalizerFingerprint(NormalizerChain{aliases(map[string][]string{"AC/DC": {"ACDC"}}), &fakeNormalizer{}}); got != base {
+		t.Errorf("same aliases fingerprinted differently: %q != %q", got, base)
+	}
+	// MusicBrainz with and without API lookups (watch and plan) resolve the same names
+	offline := NormalizerFingerprint(NormalizerChain{acdc, &fingerprintedNormalizer{"musicbrainz"}})
+	if got := NormalizerFingerprint(NormalizerChain{acdc, &fingerprintedNormalizer{"musicbrainz"}, &fingerprintedNormalizer{"musicbrainz"}}); got != offline {
+		t.Errorf("online lookups changed the fingerprint: %q != %q", got, offline)
+	}
+	if offline == NormalizerFingerprint(NormalizerChain{acdc}) {
+		t.Error("expected MusicBrainz use to change the fingerprint")
+	}
+
+	for name, n := range map[string]MusicBrainzNormalizer{
+		"alias added":       NormalizerChain{aliases(map[string][]string{"AC/DC": {"ACDC", "AC DC"}}), &fakeNormalizer{}},
+		"lookups dropped":   NormalizerChain{acdc},
+		"no normalizer":     nil,
+		"lookups reordered": NormalizerChain{&fakeNormalizer{}, acdc},
+	} {
+		if NormalizerFingerprint(n) == base {
+			t.Errorf("%s: fingerprint unchanged", name)
+		}
+	}
+}
+
+func TestAliasFileExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	entries := map[string][]string{"AC/DC": {"ACDC"}}
+	added := MergeAliases(entries, map[string][]string{
+		"AC/DC":       {"acdc", "ac dc"},          // acdc already known
+		"Wrong":       {"ACDC"},                   // user entry wins
+		"The Beatles": {"beatles", "the beatles"}, // canonical name itself is skipped
+	})
+	if added != 2 {
+		t.Errorf("added %d aliases, want 2", added)
+	}
+
+	if err := WriteAliasFile(path, entries); err != nil {
+		t.Fatalf("WriteAliasFile failed: %v", err)
+	}
+	loaded, err := LoadAliasFile(path)
+	if err != nil {
+		t.Fatalf("LoadAliasFile failed: %v", err)
+	}
+	if len(loaded) != 2 || len(loaded["AC/DC"]) != 2 || len(loaded["The Beatles"]) != 1 || loaded["Wrong"] != nil {
+		t.Errorf("loaded %v", loaded)
+	}
+	if _, err := NewAliasMap(loaded); err != nil {
+		t.Errorf("exported aliases conflict: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package meta
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+var (
+	// Separators between values of a multi-valued artist tag: ID3v2.4 null
+	// separators, ";" as written by most taggers and ffmpeg, and " / " from ID3v2.3
+	multiValueSeparator = regexp.MustCompile(`\x00|\s*;\s*|\s+/\s+`)
+
+	// "A feat. B", "A ft. B", "A featuring B", "A (feat. B)"
+	artistFeatPattern = regexp.MustCompile(`(?i)(?:^|\s+|\s*[(\[]\s*)\b(?:feat\.?|ft\.?|featuring)\s+`)
+
+	// "Title (feat. B)", "Title [ft. B]"
+	titleFeatBracketPattern = regexp.MustCompile(`(?i)\s*[(\[]\s*(?:feat\.?|ft\.?|featuring)\s+([^)\]]+)[)\]]`)
+
+	// "Title feat. B", up to a following bracket or " - " suffix
+	titleFeatTrailingPattern = regexp.MustCompile(`(?i)\s+(?:feat\.|ft\.|featuring)\s+([^(\[]+?)(\s*[(\[].*|\s+-\s+.*)?$`)
+
+	// Equal-billing separators: "A & B", "A x B", "A × B", "A vs. B"
+	collaboratorSeparator = regexp.MustCompile(`(?i)\s+(?:&|x|×|vs\.?)\s+`)
+
+	// Separators inside a featured list: "B, C & D", "B and C"
+	featuredSeparator = regexp.MustCompile(`(?i)\s*,\s*|\s+(?:&|and|x|×)\s+`)
+)
+
+// ArtistCredits is an artist tag split into individual credited artists
+type ArtistCredits struct {
+	Primary       string   // First credited artist, used for cluster keys
+	Collaborators []string // Other artists with equal billing
+	Featured      []string // Featured artists from the artist tag and the title
+
+	Main  string // Artist tag without feature credits, e.g. "A & B" for "A & B feat. C"
+	Title string // Title without feature credits
+
+	titleFeatPos int // Where the title's feature credit was removed; -1 if none
+}
+
+// ParseArtistCredits parses an artist tag and title into primary, collaborating
+// and featured artists. "A & B", "A x B" and "A vs. B" stay one artist, since
+// band names ("Simon & Garfunkel") look the same; ParseArtistCreditsWith splits
+// them when the ARTISTS tag confirms the parts.
+func ParseArtistCredits(artist, title string) *ArtistCredits {
+	return ParseArtistCreditsWith(artist, title, "")
+}
+
+// CreditsOf parses the artist, title and ARTISTS tags of m
+func CreditsOf(m *store.Metadata) *ArtistCredits {
+	return ParseArtistCreditsWith(m.TagArtist, m.TagTitle, m.TagArtists)
+}
+
+// ParseArtistCreditsWith parses credits like ParseArtistCredits, splitting
+// "A & B", "A x B" and "A vs. B" into collaborators when artists, the
+// multi-valued ARTISTS tag, lists the parts
+func ParseArtistCreditsWith(artist, title, artists string) *ArtistCredits {
+	known := make(map[string]bool)
+	for _, name := range multiValueSeparator.Split(artists, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			known[strings.ToLower(name)] = true
+		}
+	}
+
+	c := &ArtistCredits{Title: strings.TrimSpace(title), titleFeatPos: -1}
+	seen := make(map[string]bool)
+	add := func(list *[]string, name string) {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		*list = append(*list, name)
+	}
+
+	var mains, names []string
+	for _, value := range multiValueSeparator.Split(strings.TrimSpace(artist), -1) {
+		main, featured := splitArtistFeaturing(value)
+		if main != "" {
+			mains = append(mains, main)
+		}
+		for _, name := range splitCollaborators(main, known) {
+			add(&names, name)
+		}
+		for _, name := range splitFeaturedList(featured) {
+			add(&c.Featured, name)
+		}
+	}
+
+	if len(names) > 0 {
+		c.Primary = names[0]
+	}
+	if len(names) > 1 {
+		c.Collaborators = names[1:]
+	}
+	c.Main = strings.Join(mains, " & ")
+
+	// Feature credits in the title
+	if loc := titleFeatBracketPattern.FindStringSubmatchIndex(c.Title); loc != nil {
+		featured := c.Title[loc[2]:loc[3]]
+		c.Title = strings.TrimSpace(c.Title[:loc[0]] + c.Title[loc[1]:])
+		c.titleFeatPos = loc[0]
+		for _, name := range splitFeaturedList(featured) {
+			add(&c.Featured, name)
+		}
+	} else if loc := titleFeatTrailingPattern.FindStringSubmatchIndex(c.Title); loc != nil {
+		featured := c.Title[loc[2]:loc[3]]
+		suffix := ""
+		if loc[4] >= 0 {
+			suffix = c.Title[loc[4]:loc[5]]
+		}
+		c.Title = strings.TrimSpace(c.Title[:loc[0]] + suffix)
+		c.titleFeatPos = loc[0]
+		for _, name := range splitFeaturedList(featured) {
+			add(&c.Featured, name)
+		}
+	}
+
+	return c
+}
+
+// MainWithFeaturing returns the main artists followed by " feat. " and the featured artists
+func (c *ArtistCredits) MainWithFeaturing() string {
+	if len(c.Featured) == 0 {
+		return c.Main
+	}
+	return c.Main + " feat. " + JoinArtistNames(c.Featured)
+}
+
+// TitleWithFeaturing returns the title with one "(feat. ...)" credit listing every featured artist
+// The credit goes where the title had one, otherwise at the end
+func (c *ArtistCredits) TitleWithFeaturing() string {
+	if len(c.Featured) == 0 {
+		return c.Title
+	}
+	credit := "(feat. " + JoinArtistNames(c.Featured) + ")"
+	if c.titleFeatPos >= 0 && c.titleFeatPos < len(c.Title) {
+		return strings.TrimSpace(c.Title[:c.titleFeatPos]) + " " + credit + " " + strings.TrimSpace(c.Title[c.titleFeatPos:])
+	}
+	if c.Title == "" {
+		return ""
+	}
+	return c.Title + " " + credit
+}
+
+// Names returns every credited artist: primary, collaborators, then featured
+func (c *ArtistCredits) Names() []string {
+	var names []string
+	if c.Primary != "" {
+		names = append(names, c.Primary)
+	}
+	names = append(names, c.Collaborators...)
+	return append(names, c.Featured...)
+}
+
+// StoreCredits converts the credits to metadata_artists rows
+func (c *ArtistCredits) StoreCredits() []store.ArtistCredit {
+	var credits []store.ArtistCredit
+	if c.Primary != "" {
+		credits = append(credits, store.ArtistCredit{Role: store.ArtistRolePrimary, Name: c.Primary})
+	}
+	for i, name := range c.Collaborators {
+		credits = append(credits, store.ArtistCredit{Role: store.ArtistRoleCollaborator, Position: i, Name: name})
+	}
+	for i, name := range c.Featured {
+		credits = append(credits, store.ArtistCredit{Role: store.ArtistRoleFeatured, Position: i, Name: name})
+	}
+	return credits
+}
+
+// AssignArtistCredits parses the artist, title and ARTISTS tags into m.Artists
+func AssignArtistCredits(m *store.Metadata) {
+	m.Artists = CreditsOf(m).StoreCredits()
+}
+
+// JoinArtistNames joins names as "A", "A & B" or "A, B & C"
+func JoinArtistNames(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " & " + names[len(names)-1]
+}
+
+// splitArtistFeaturing splits "A feat. B" into "A" and "B"
+func splitArtistFeaturing(s string) (main, featured string) {
+	s = strings.TrimSpace(s)
+	loc := artistFeatPattern.FindStringIndex(s)
+	if loc == nil {
+		return s, ""
+	}
+	main = strings.TrimSpace(s[:loc[0]])
+	featured = strings.TrimSpace(strings.TrimRight(s[loc[1]:], ")] "))
+	return main, featured
+}
+
+// splitCollaborators splits "A & B x C" into the artists listed in known
+// (lowercased), so "Mumford & Sons x Baaba Maal" splits only at " x " when
+// both are listed. Unless every part is listed, s stays whole.
+func splitCollaborators(s string, known map[string]bool) []string {
+	if s == "" {
+		return nil
+	}
+	if len(known) == 0 {
+		return []string{s}
+	}
+
+	var parts []string
+	start := 0
+	for _, sep := range collaboratorSeparator.FindAllStringIndex(s, -1) {
+		if part := strings.TrimSpace(s[start:sep[0]]); known[strings.ToLower(part)] {
+			parts = append(parts, part)
+			start = sep[1]
+		}
+	}
+	last := strings.TrimSpace(s[start:])
+	if !known[strings.ToLower(last)] {
+		return []string{s}
+	}
+	return append(parts, last)
+}
+
+// splitFeaturedList splits "B, C & D" into its artists
+func splitFeaturedList(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var names []string
+	for _, name := range featuredSeparator.Split(s, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,114 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArtistCredits(t *testing.T) {
+	tests := []struct {
+		name          string
+		artist        string
+		title         string
+		primary       string
+		collaborators []string
+		featured      []string
+		main          string
+		baseTitle     string
+	}{
+		{"single artist", "Radiohead", "Creep", "Radiohead", nil, nil, "Radiohead", "Creep"},
+		{"feat. in artist", "Calvin Harris feat. Rihanna", "This Is What You Came For", "Calvin Harris", nil, []string{"Rihanna"}, "Calvin Harris", "This Is What You Came For"},
+		{"ft in brackets", "Eminem (ft. Dido)", "Stan", "Eminem", nil, []string{"Dido"}, "Eminem", "Stan"},
+		{"featuring list", "DJ Khaled featuring Rihanna & Bryson Tiller", "Wild Thoughts", "DJ Khaled", nil, []string{"Rihanna", "Bryson Tiller"}, "DJ Khaled", "Wild Thoughts"},
+		{"ampersand kept whole", "Simon & Garfunkel", "The Boxer", "Simon & Garfunkel", nil, nil, "Simon & Garfunkel", "The Boxer"},
+		{"x kept whole", "Skrillex x Diplo", "Where Are Ü Now", "Skrillex x Diplo", nil, nil, "Skrillex x Diplo", "Where Are Ü Now"},
+		{"vs kept whole", "Armin van Buuren vs. Vini Vici", "Great Spirit", "Armin van Buuren vs. Vini Vici", nil, nil, "Armin van Buuren vs. Vini Vici", "Great Spirit"},
+		{"band with ampersand", "Mumford & Sons", "The Cave", "Mumford & Sons", nil, nil, "Mumford & Sons", "The Cave"},
+		{"band with ampersand the", "Echo & the Bunnymen", "The Killing Moon", "Echo & the Bunnymen", nil, nil, "Echo & the Bunnymen", "The Killing Moon"},
+		{"multi-valued tag", "Daft Punk; Pharrell Williams; Nile Rodgers", "Get Lucky", "Daft Punk", []string{"Pharrell Williams", "Nile Rodgers"}, nil, "Daft Punk & Pharrell Williams & Nile Rodgers", "Get Lucky"},
+		{"null-separated tag", "Daft Punk\x00Pharrell Williams", "Get Lucky", "Daft Punk", []string{"Pharrell Williams"}, nil, "Daft Punk & Pharrell Williams", "Get Lucky"},
+		{"feat. in title", "Gorillaz", "Feel Good Inc. (feat. De La Soul)", "Gorillaz", nil, []string{"De La Soul"}, "Gorillaz", "Feel Good Inc."},
+		{"feat. in title before version", "Avicii", "Lonely Together (feat. Rita Ora) (Remix)", "Avicii", nil, []string{"Rita Ora"}, "Avicii", "Lonely Together (Remix)"},
+		{"trailing feat. in title", "Drake", "Work ft. Rihanna - Radio Edit", "Drake", nil, []string{"Rihanna"}, "Drake", "Work - Radio Edit"},
+		{"same featured artist twice", "Jay-Z feat. Alicia Keys", "Empire State of Mind (feat. Alicia Keys)", "Jay-Z", nil, []string{"Alicia Keys"}, "Jay-Z", "Empire State of Mind"},
+		{"slash in name", "AC/DC", "Thunderstruck", "AC/DC", nil, nil, "AC/DC", "Thunderstruck"},
+		{"no feat. inside word", "Daft Punk", "Aftermath", "Daft Punk", nil, nil, "Daft Punk", "Aftermath"},
+		{"empty", "", "", "", nil, nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ParseArtistCredits(tt.artist, tt.title)
+			if c.Primary != tt.primary {
+				t.Errorf("Primary = %q, want %q", c.Primary, tt.primary)
+			}
+			if !reflect.DeepEqual(c.Collaborators, tt.collaborators) {
+				t.Errorf("Collaborators = %q, want %q", c.Collaborators, tt.collaborators)
+			}
+			if !reflect.DeepEqual(c.Featured, tt.featured) {
+				t.Errorf("Featured = %q, want %q", c.Featured, tt.featured)
+			}
+			if c.Main != tt.main {
+				t.Errorf("Main = %q, want %q", c.Main, tt.main)
+			}
+			if c.Title != tt.baseTitle {
+				t.Errorf("Title = %q, want %q", c.Title, tt.baseTitle)
+			}
+		})
+	}
+}
+
+func TestParseArtistCreditsWith(t *testing.T) {
+	tests := []struct {
+		name          string
+		artist        string
+		artists       string // ARTISTS tag
+		primary       string
+		collaborators []string
+	}{
+		{"x confirmed", "Skrillex x Diplo", "Skrillex; Diplo", "Skrillex", []string{"Diplo"}},
+		{"vs confirmed", "Armin van Buuren vs. Vini Vici", "Armin van Buuren\x00Vini Vici", "Armin van Buuren", []string{"Vini Vici"}},
+		{"confirmed ignoring case", "SKRILLEX & diplo", "Skrillex; Diplo", "SKRILLEX", []string{"diplo"}},
+		{"band in a collaboration", "Mumford & Sons x Baaba Maal", "Mumford & Sons; Baaba Maal", "Mumford & Sons", []string{"Baaba Maal"}},
+		{"duo listed as one", "Simon & Garfunkel", "Simon & Garfunkel", "Simon & Garfunkel", nil},
+		{"part not listed", "A & B & C", "A; B", "A & B & C", nil},
+		{"featured artists still split", "A & B feat. C", "A; B; C", "A", []string{"B"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := ParseArtistCreditsWith(tt.artist, "Song", tt.artists)
+			if c.Primary != tt.primary {
+				t.Errorf("Primary = %q, want %q", c.Primary, tt.primary)
+			}
+			if !reflect.DeepEqual(c.Collaborators, tt.collaborators) {
+				t.Errorf("Collaborators = %q, want %q", c.Collaborators, tt.collaborators)
+			}
+		})
+	}
+}
+
+func TestArtistCreditsFormatting(t *testing.T) {
+	tests := []struct {
+		artist        string
+		title         string
+		withFeaturing string
+		mainFeaturing string
+	}{
+		{"A feat. B", "Song", "Song (feat. B)", "A feat. B"},
+		{"A", "Song (feat. B) (Remix)", "Song (feat. B) (Remix)", "A feat. B"},
+		{"A feat. B", "Song (ft. C)", "Song (feat. B & C)", "A feat. B & C"},
+		{"A ft. B, C & D", "Song", "Song (feat. B, C & D)", "A feat. B, C & D"},
+		{"A & B", "Song", "Song", "A & B"},
+	}
+
+	for _, tt := range tests {
+		c := ParseArtistCredits(tt.artist, tt.title)
+		if got := c.TitleWithFeaturing(); got != tt.withFeaturing {
+			t.Errorf("TitleWithFeaturing(%q, %q) = %q, want %q", tt.artist, tt.title, got, tt.withFeaturing)
+		}
+		if got := c.MainWithFeaturing(); got != tt.mainFeaturing {
+			t.Errorf("MainWithFeaturing(%q, %q) = %q, want %q", tt.artist, tt.title, got, tt.mainFeaturing)
+		}
+	}
+}
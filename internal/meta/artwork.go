@@ -0,0 +1,409 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // Register GIF for image.DecodeConfig
+	"image/jpeg"
+	_ "image/png" // Register PNG for image.DecodeConfig
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// ErrArtworkUnsupported is returned when covers cannot be embedded in a format
+var ErrArtworkUnsupported = errors.New("embedding artwork not supported for this format")
+
+// coverJPEGQuality is the JPEG quality of converted and resized covers
+const coverJPEGQuality = 90
+
+// EmbeddedPicture is an image embedded in an audio file
+type EmbeddedPicture struct {
+	Type     int // ID3/FLAC picture type (store.PictureFrontCover, ...)
+	MIMEType string
+	Data     []byte
+}
+
+// pictureTypeNames are dhowden/tag's names for the ID3/FLAC picture types, by type number
+var pictureTypeNames = []string{
+	"Other",
+	"32x32 pixels 'file icon' (PNG only)",
+	"Other file icon",
+	"Cover (front)",
+	"Cover (back)",
+	"Leaflet page",
+	"Media (e.g. lable side of CD)",
+	"Lead artist/lead performer/soloist",
+	"Artist/performer",
+	"Conductor",
+	"Band/Orchestra",
+	"Composer",
+	"Lyricist/text writer",
+	"Recording Location",
+	"During recording",
+	"During performance",
+	"Movie/video screen capture",
+	"A bright coloured fish",
+	"Illustration",
+	"Band/artist logotype",
+	"Publisher/Studio logotype",
+}
+
+// pictureType returns the type number for a dhowden/tag picture type name
+// MP4 covr atoms have no type and are front covers
+func pictureType(name string) int {
+	if name == "" {
+		return store.PictureFrontCover
+	}
+	for i, n := range pictureTypeNames {
+		if n == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// ReadPictures returns the images embedded in an audio file
+func ReadPictures(path string) ([]EmbeddedPicture, error) {
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	m, err := tag.ReadFrom(src.Reader())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return picturesFromTag(m, src), nil
+}
+
+// ReadPicture returns the data of the embedded image with the given hash
+func ReadPicture(path, sha1Hex string) ([]byte, error) {
+	pictures, err := ReadPictures(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pictures {
+		if pictureHash(p.Data) == sha1Hex {
+			return p.Data, nil
+		}
+	}
+	return nil, fmt.Errorf("picture %s not found in %s", sha1Hex, path)
+}
+
+// picturesFromTag collects every embedded image. dhowden/tag keeps only one
+// picture per FLAC file and per MP4/Vorbis tag, but every ID3 APIC frame, so
+// FLAC PICTURE blocks are read directly
+func picturesFromTag(m tag.Metadata, src *util.SourceReader) []EmbeddedPicture {
+	if m.FileType() == tag.FLAC {
+		if pictures, err := readFLACPictures(src.Reader()); err == nil && len(pictures) > 0 {
+			return pictures
+		}
+	}
+
+	var pictures []EmbeddedPicture
+	if raw := m.Raw(); raw != nil {
+		// Repeated ID3 frames are keyed APIC, APIC_1, ...
+		keys := make([]string, 0, len(raw))
+		for key, value := range raw {
+			if _, ok := value.(*tag.Picture); ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p := raw[key].(*tag.Picture)
+			pictures = append(pictures, EmbeddedPicture{Type: pictureType(p.Type), MIMEType: p.MIMEType, Data: p.Data})
+		}
+	}
+	if len(pictures) == 0 {
+		if p := m.Picture(); p != nil {
+			pictures = append(pictures, EmbeddedPicture{Type: pictureType(p.Type), MIMEType: p.MIMEType, Data: p.Data})
+		}
+	}
+	return pictures
+}
+
+// readFLACPictures reads every PICTURE block of the FLAC stream in r
+func readFLACPictures(r io.Reader) ([]EmbeddedPicture, error) {
+	_, blocks, err := readFLACHeader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	var pictures []EmbeddedPicture
+	for _, block := range blocks {
+		if block.blockType != flacBlockPicture {
+			continue
+		}
+		p, err := parseFLACPicture(block.data)
+		if err != nil {
+			return nil, err
+		}
+		pictures = append(pictures, p)
+	}
+	return pictures, nil
+}
+
+// parseFLACPicture decodes a PICTURE block
+func parseFLACPicture(b []byte) (EmbeddedPicture, error) {
+	var p EmbeddedPicture
+	readField := func() ([]byte, error) {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("PICTURE block truncated")
+		}
+		n := binary.BigEndian.Uint32(b)
+		if uint64(n) > uint64(len(b)-4) {
+			return nil, fmt.Errorf("PICTURE field length %d exceeds block", n)
+		}
+		field := b[4 : 4+n]
+		b = b[4+n:]
+		return field, nil
+	}
+
+	if len(b) < 4 {
+		return p, fmt.Errorf("PICTURE block truncated")
+	}
+	p.Type = int(binary.BigEndian.Uint32(b))
+	b = b[4:]
+	mime, err := readField()
+	if err != nil {
+		return p, err
+	}
+	p.MIMEType = string(mime)
+	if _, err := readField(); err != nil { // description
+		return p, err
+	}
+	if len(b) < 16 {
+		return p, fmt.Errorf("PICTURE block truncated")
+	}
+	b = b[16:] // width, height, color depth, palette size
+	if p.Data, err = readField(); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// encodeFLACPicture encodes a PICTURE block for a front cover
+func encodeFLACPicture(mimeType string, width, height int, data []byte) []byte {
+	var buf bytes.Buffer
+	writeUint := func(v int) {
+		binary.Write(&buf, binary.BigEndian, uint32(v))
+	}
+	writeUint(store.PictureFrontCover)
+	writeUint(len(mimeType))
+	buf.WriteString(mimeType)
+	writeUint(0) // description
+	writeUint(width)
+	writeUint(height)
+	writeUint(24) // color depth
+	writeUint(0)  // palette size
+	writeUint(len(data))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// DescribePictures hashes embedded images and reads their dimensions
+func DescribePictures(pictures []EmbeddedPicture) []store.Picture {
+	var described []store.Picture
+	for i, p := range pictures {
+		if len(p.Data) == 0 {
+			continue
+		}
+		info := store.Picture{
+			Position:  i,
+			Type:      p.Type,
+			MIMEType:  p.MIMEType,
+			SizeBytes: len(p.Data),
+			SHA1:      pictureHash(p.Data),
+		}
+		if cfg, format, err := image.DecodeConfig(bytes.NewReader(p.Data)); err == nil {
+			info.Width = cfg.Width
+			info.Height = cfg.Height
+			info.MIMEType = "image/" + format
+		}
+		described = append(described, info)
+	}
+	return described
+}
+
+// pictureHash returns the hex SHA-1 of image data
+func pictureHash(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CoverJPEG returns image data as a JPEG no larger than maxSize pixels on its
+// longest side; JPEGs that already fit are returned unchanged
+// maxSize <= 0 keeps the original size
+func CoverJPEG(data []byte, maxSize int) (jpegData []byte, width, height int, err error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	fits := maxSize <= 0 || (cfg.Width <= maxSize && cfg.Height <= maxSize)
+	if format == "jpeg" && fits {
+		return data, cfg.Width, cfg.Height, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if !fits {
+		img = downscale(img, maxSize)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: coverJPEGQuality}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	bounds := img.Bounds()
+	return buf.Bytes(), bounds.Dx(), bounds.Dy(), nil
+}
+
+// downscale shrinks an image so its longest side is maxSize, averaging the
+// source pixels covered by each destination pixel
+func downscale(src image.Image, maxSize int) image.Image {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dw, dh := maxSize, maxSize
+	if sw >= sh {
+		dh = max(1, sh*maxSize/sw)
+	} else {
+		dw = max(1, sw*maxSize/sh)
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += int(px[0])
+					g += int(px[1])
+					b += int(px[2])
+					a += int(px[3])
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// EmbedCover makes a JPEG the front cover of an audio file, replacing any
+// existing front cover. FLAC files are rewritten directly; MP3 and M4A files
+// are remuxed with ffmpeg. Other formats return ErrArtworkUnsupported
+func EmbedCover(path string, jpegData []byte) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".flac":
+		return embedFLACCover(path, jpegData)
+	case ".mp3", ".m4a":
+		return embedCoverWithFFmpeg(path, jpegData)
+	}
+	return ErrArtworkUnsupported
+}
+
+// embedFLACCover replaces the front cover PICTURE blocks of a FLAC file
+func embedFLACCover(path string, jpegData []byte) error {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(jpegData))
+	if err != nil {
+		return fmt.Errorf("failed to read cover: %w", err)
+	}
+	cover := flacBlock{
+		blockType: flacBlockPicture,
+		data:      encodeFLACPicture("image/jpeg", cfg.Width, cfg.Height, jpegData),
+	}
+
+	return rewriteFLACHeader(path, func(blocks []flacBlock) ([]flacBlock, error) {
+		kept := []flacBlock{}
+		inserted := false
+		for _, block := range blocks {
+			if block.blockType == flacBlockPicture {
+				if p, err := parseFLACPicture(block.data); err == nil && p.Type == store.PictureFrontCover {
+					continue
+				}
+			}
+			kept = append(kept, block)
+			// Cover goes right after STREAMINFO and the comments, before padding
+			if !inserted && block.blockType == flacBlockVorbisComment {
+				kept = append(kept, cover)
+				inserted = true
+			}
+		}
+		if !inserted {
+			index := 0
+			if len(kept) > 0 && kept[0].blockType == flacBlockStreamInfo {
+				index = 1
+			}
+			kept = append(kept[:index], append([]flacBlock{cover}, kept[index:]...)...)
+		}
+		return kept, nil
+	})
+}
+
+// embedCoverWithFFmpeg remuxes an MP3 or M4A file with the cover as its only
+// attached picture
+func embedCoverWithFFmpeg(path string, jpegData []byte) error {
+	coverPath := path + ".cover.jpg"
+	if err := os.WriteFile(coverPath, jpegData, 0644); err != nil {
+		return fmt.Errorf("failed to write cover: %w", err)
+	}
+	defer os.Remove(coverPath)
+
+	// Keep the extension so ffmpeg picks the right muxer
+	tempPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".tagged" + filepath.Ext(path)
+	args := []string{
+		"-i", path,
+		"-i", coverPath,
+		"-map", "0:a",
+		"-map", "1:0",
+		"-map_metadata", "0",
+		"-c", "copy",
+		"-disposition:v:0", "attached_pic",
+	}
+	if strings.EqualFold(filepath.Ext(path), ".mp3") {
+		args = append(args, "-id3v2_version", "3", "-metadata:s:v", "comment=Cover (front)")
+	}
+	args = append(args, "-y", tempPath)
+
+	cmd := exec.Command("ffmpeg", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("ffmpeg failed: %w (output: %s)", err, string(output))
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
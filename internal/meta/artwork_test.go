@@ -0,0 +1,177 @@
+package meta
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// testImage encodes a solid w x h image as PNG or JPEG
+func testImage(t *testing.T, w, h int, format string) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{200, 40, 40, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDescribePictures(t *testing.T) {
+	pictures := []EmbeddedPicture{
+		{Type: store.PictureFrontCover, MIMEType: "image/jpg", Data: testImage(t, 600, 500, "jpeg")},
+		{Type: 4, MIMEType: "image/png", Data: testImage(t, 32, 16, "png")},
+		{Type: 0, MIMEType: "image/webp", Data: []byte("not an image")},
+		{Type: 0, Data: nil},
+	}
+
+	got := DescribePictures(pictures)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 pictures, got %d", len(got))
+	}
+	if got[0].Width != 600 || got[0].Height != 500 || got[0].MIMEType != "image/jpeg" || got[0].Type != store.PictureFrontCover {
+		t.Errorf("front cover = %+v", got[0])
+	}
+	if got[1].Width != 32 || got[1].Height != 16 || got[1].MIMEType != "image/png" || got[1].Position != 1 {
+		t.Errorf("back cover = %+v", got[1])
+	}
+	if got[2].Width != 0 || got[2].MIMEType != "image/webp" || got[2].SizeBytes != len("not an image") {
+		t.Errorf("undecodable picture = %+v", got[2])
+	}
+	if got[0].SHA1 == got[1].SHA1 || len(got[0].SHA1) != 40 {
+		t.Errorf("unexpected hashes %q, %q", got[0].SHA1, got[1].SHA1)
+	}
+}
+
+func TestPictureType(t *testing.T) {
+	testCases := []struct {
+		name string
+		want int
+	}{
+		{"Cover (front)", store.PictureFrontCover},
+		{"Cover (back)", 4},
+		{"", store.PictureFrontCover}, // MP4 covr
+		{"Publisher/Studio logotype", 20},
+		{"unknown", 0},
+	}
+	for _, tc := range testCases {
+		if got := pictureType(tc.name); got != tc.want {
+			t.Errorf("pictureType(%q) = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCoverJPEG(t *testing.T) {
+	small := testImage(t, 400, 400, "jpeg")
+	got, w, h, err := CoverJPEG(small, 1000)
+	if err != nil {
+		t.Fatalf("CoverJPEG failed: %v", err)
+	}
+	if !bytes.Equal(got, small) || w != 400 || h != 400 {
+		t.Errorf("JPEG that fits should be returned unchanged (%dx%d)", w, h)
+	}
+
+	// PNG is converted even when it fits
+	got, w, h, err = CoverJPEG(testImage(t, 300, 200, "png"), 0)
+	if err != nil {
+		t.Fatalf("CoverJPEG failed: %v", err)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(got)); err != nil || format != "jpeg" || w != 300 || h != 200 {
+		t.Errorf("PNG not converted to 300x200 JPEG: format %q, %dx%d, err %v", format, w, h, err)
+	}
+
+	// Large covers are resized to the maximum, keeping the aspect ratio
+	got, w, h, err = CoverJPEG(testImage(t, 1600, 1200, "jpeg"), 800)
+	if err != nil {
+		t.Fatalf("CoverJPEG failed: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(got))
+	if err != nil || cfg.Width != 800 || cfg.Height != 600 || w != 800 || h != 600 {
+		t.Errorf("resized cover = %dx%d (reported %dx%d), want 800x600", cfg.Width, cfg.Height, w, h)
+	}
+	img, _ := jpeg.Decode(bytes.NewReader(got))
+	if r, _, _, _ := img.At(400, 300).RGBA(); r>>8 < 180 {
+		t.Errorf("resized colour lost: red = %d", r>>8)
+	}
+
+	if _, _, _, err := CoverJPEG([]byte("garbage"), 0); err == nil {
+		t.Error("expected error for invalid image")
+	}
+}
+
+func TestFLACPictureRoundTrip(t *testing.T) {
+	data := testImage(t, 16, 8, "jpeg")
+	p, err := parseFLACPicture(encodeFLACPicture("image/jpeg", 16, 8, data))
+	if err != nil {
+		t.Fatalf("parseFLACPicture failed: %v", err)
+	}
+	if p.Type != store.PictureFrontCover || p.MIMEType != "image/jpeg" || !bytes.Equal(p.Data, data) {
+		t.Errorf("round trip = type %d, mime %q, %d bytes", p.Type, p.MIMEType, len(p.Data))
+	}
+
+	if _, err := parseFLACPicture([]byte{0, 0, 0, 3, 0, 0, 1, 0}); err == nil {
+		t.Error("expected error for truncated PICTURE block")
+	}
+}
+
+func TestEmbedFLACCover(t *testing.T) {
+	audio := []byte("audio frames must survive")
+	path := writeTestFLAC(t, []string{"TITLE=Song"}, audio)
+
+	first := testImage(t, 20, 20, "jpeg")
+	second := testImage(t, 40, 30, "jpeg")
+	if err := EmbedCover(path, first); err != nil {
+		t.Fatalf("EmbedCover failed: %v", err)
+	}
+	// Embedding again replaces the front cover rather than adding another
+	if err := EmbedCover(path, second); err != nil {
+		t.Fatalf("EmbedCover failed: %v", err)
+	}
+
+	pictures, err := ReadPictures(path)
+	if err != nil {
+		t.Fatalf("ReadPictures failed: %v", err)
+	}
+	if len(pictures) != 1 || !bytes.Equal(pictures[0].Data, second) || pictures[0].Type != store.PictureFrontCover {
+		t.Fatalf("expected only the second cover, got %d pictures", len(pictures))
+	}
+
+	described := DescribePictures(pictures)
+	data, err := ReadPicture(path, described[0].SHA1)
+	if err != nil || !bytes.Equal(data, second) {
+		t.Errorf("ReadPicture by hash failed: %v", err)
+	}
+	if _, err := ReadPicture(path, "0000"); err == nil {
+		t.Error("expected error for unknown picture hash")
+	}
+
+	fields, err := ReadFLACComments(path)
+	if err != nil || fields["TITLE"][0] != "Song" {
+		t.Errorf("comments lost: %v, %v", fields, err)
+	}
+	content, _ := os.ReadFile(path)
+	if !bytes.HasSuffix(content, audio) {
+		t.Error("audio frames not preserved")
+	}
+
+	if err := EmbedCover(path+".ogg", second); err != ErrArtworkUnsupported {
+		t.Errorf("expected ErrArtworkUnsupported for Ogg, got %v", err)
+	}
+}
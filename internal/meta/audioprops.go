@@ -0,0 +1,180 @@
+package meta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Pure-Go header parsers for audio stream properties, so metadata extraction
+// does not have to spawn ffprobe for every file. Container and codec names
+// follow ffprobe's format_name and codec_name so stored values do not depend
+// on which path produced them.
+
+var errUnsupportedAudio = errors.New("unsupported audio format")
+
+// AudioProperties are stream properties parsed from file headers
+type AudioProperties struct {
+	Container   string // ffprobe format_name, e.g. "flac", "mp3", "mov,mp4,m4a,3gp,3g2,mj2"
+	Codec       string // ffprobe codec_name, e.g. "flac", "mp3", "aac", "pcm_s16le"
+	DurationMs  int
+	SampleRate  int
+	BitDepth    int // 0 for lossy codecs
+	Channels    int
+	BitrateKbps int
+	HasVideo    bool // MP4 only: a video track besides the sound track
+
+	Encoder *EncoderInfo // MP3 only: Xing/Info or VBRI header and LAME tag
+}
+
+// ReadAudioProperties parses the stream properties of an audio file from its headers
+// Returns an error for formats it does not understand; callers fall back to ffprobe
+func ReadAudioProperties(path string) (*AudioProperties, error) {
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return readAudioProperties(src.SectionReader)
+}
+
+// readAudioProperties parses the stream properties from the headers read through f
+func readAudioProperties(f *io.SectionReader) (*AudioProperties, error) {
+	size := f.Size()
+
+	head := make([]byte, 12)
+	if _, err := f.ReadAt(head, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// MP3 and some FLAC files start with one or more ID3v2 tags
+	offset := int64(0)
+	for {
+		tagSize, ok := id3v2Size(head)
+		if !ok {
+			break
+		}
+		offset += tagSize
+		if _, err := f.ReadAt(head, offset); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	}
+
+	var props *AudioProperties
+	var err error
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		props, err = parseFLACProperties(f, offset)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		props, err = parseOggProperties(f, offset, size)
+	case bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WAVE":
+		props, err = parseWAVProperties(f, size)
+	case bytes.HasPrefix(head, []byte("FORM")) && (string(head[8:12]) == "AIFF" || string(head[8:12]) == "AIFC"):
+		props, err = parseAIFFProperties(f, size)
+	case string(head[4:8]) == "ftyp":
+		props, err = parseMP4Properties(f, size)
+	case isFFprobeOnlyHeader(head):
+		// Left to ffprobe; scanning their payload for MPEG frame syncs could
+		// find stray matches
+		return nil, errUnsupportedAudio
+	default:
+		props, err = parseMP3Properties(f, offset, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if props.SampleRate <= 0 || props.Channels <= 0 || props.DurationMs <= 0 {
+		return nil, fmt.Errorf("incomplete stream properties: %+v", *props)
+	}
+	// Like ffprobe's format bit_rate: whole file size over duration
+	if props.BitrateKbps == 0 {
+		props.BitrateKbps = int(size * 8 / int64(props.DurationMs))
+	}
+	return props, nil
+}
+
+// ffprobeOnlyMagic are the leading bytes of containers without a native parser
+var ffprobeOnlyMagic = [][]byte{
+	[]byte("DSD "),           // DSF
+	[]byte("FRM8"),           // DSDIFF
+	[]byte("tBaK"),           // TAK
+	[]byte("caff"),           // Core Audio
+	[]byte("riff"),           // Wave64
+	{0x1A, 0x45, 0xDF, 0xA3}, // Matroska (EBML)
+}
+
+// isFFprobeOnlyHeader reports whether head starts a container without a native parser
+func isFFprobeOnlyHeader(head []byte) bool {
+	for _, magic := range ffprobeOnlyMagic {
+		if bytes.HasPrefix(head, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Metadata converts the properties to metadata without tags
+func (p *AudioProperties) Metadata() *store.Metadata {
+	m := &store.Metadata{}
+	p.Apply(m)
+	return m
+}
+
+// Apply sets the audio property fields of m, leaving its tags alone
+func (p *AudioProperties) Apply(m *store.Metadata) {
+	m.Container = p.Container
+	m.Codec = p.Codec
+	m.DurationMs = p.DurationMs
+	m.SampleRate = p.SampleRate
+	m.BitDepth = p.BitDepth
+	m.Channels = p.Channels
+	m.BitrateKbps = p.BitrateKbps
+	m.Lossless = isLosslessCodec(p.Codec)
+	m.HasVideo = p.HasVideo
+	m.EncoderJSON = p.Encoder.JSON()
+}
+
+// id3v2Size returns the total size of an ID3v2 tag starting at header, including
+// its 10-byte header and optional footer
+func id3v2Size(header []byte) (int64, bool) {
+	if len(header) < 10 || !bytes.HasPrefix(header, []byte("ID3")) {
+		return 0, false
+	}
+	// Sizes are syncsafe: 7 bits per byte
+	for _, b := range header[6:10] {
+		if b&0x80 != 0 {
+			return 0, false
+		}
+	}
+	size := int64(header[6])<<21 | int64(header[7])<<14 | int64(header[8])<<7 | int64(header[9])
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10 // footer
+	}
+	return size, true
+}
+
+// samplesToMs converts a sample count to milliseconds, truncating like the ffprobe path
+func samplesToMs(samples int64, sampleRate int) int {
+	if sampleRate <= 0 || samples <= 0 {
+		return 0
+	}
+	return int(samples * 1000 / int64(sampleRate))
+}
+
+// readFull reads len(buf) bytes at off, treating a short read at EOF as an error
+func readFull(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
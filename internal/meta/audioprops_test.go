@@ -0,0 +1,287 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mpegFrameBytes returns an MPEG-1 layer III frame with the given bitrate index
+// (9 = 128 kbps, 11 = 192 kbps) at 44.1 kHz stereo
+func mpegFrameBytes(bitrateIndex byte) []byte {
+	h, _ := parseMPEGFrame([]byte{0xff, 0xfb, bitrateIndex << 4, 0x00})
+	frame := make([]byte, h.length())
+	copy(frame, []byte{0xff, 0xfb, bitrateIndex << 4, 0x00})
+	return frame
+}
+
+// mp4Box encodes a box with the given body
+func mp4Box(typ string, body ...[]byte) []byte {
+	payload := bytes.Join(body, nil)
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], typ)
+	return append(b, payload...)
+}
+
+// oggPage encodes an Ogg page holding one packet
+func oggPage(serial uint32, granule int64, packet []byte) []byte {
+	b := make([]byte, 27, 28+len(packet))
+	copy(b, "OggS")
+	binary.LittleEndian.PutUint64(b[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(b[14:], serial)
+	b[26] = 1
+	b = append(b, byte(len(packet)))
+	return append(b, packet...)
+}
+
+func writeTestAudio(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return path
+}
+
+func TestReadAudioProperties(t *testing.T) {
+	u16 := func(order binary.ByteOrder, v uint16) []byte { b := make([]byte, 2); order.PutUint16(b, v); return b }
+	u32 := func(order binary.ByteOrder, v uint32) []byte { b := make([]byte, 4); order.PutUint32(b, v); return b }
+	be, le := binary.BigEndian, binary.LittleEndian
+
+	// FLAC: 44.1 kHz, stereo, 16-bit, 88200 samples
+	streamInfo := make([]byte, 34)
+	streamInfo[10], streamInfo[11], streamInfo[12], streamInfo[13] = 0x0a, 0xc4, 0x42, 0xf0
+	copy(streamInfo[14:], u32(be, 88200))
+	flac := append([]byte("fLaC\x80\x00\x00\x22"), streamInfo...)
+	id3 := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0}
+
+	// MP3 CBR: 100 frames of 128 kbps
+	var cbr []byte
+	for i := 0; i < 100; i++ {
+		cbr = append(cbr, mpegFrameBytes(9)...)
+	}
+
+	// MP3 with a Xing header claiming 1000 frames and 300000 bytes
+	xing := mpegFrameBytes(9)
+	copy(xing[36:], "Xing")
+	copy(xing[40:], u32(be, 3))
+	copy(xing[44:], u32(be, 1000))
+	copy(xing[48:], u32(be, 300000))
+	xingFile := append([]byte{}, xing...)
+	for i := 0; i < 10; i++ {
+		xingFile = append(xingFile, mpegFrameBytes(11)...)
+	}
+
+	// MP3 VBR without a header: alternating 128 and 192 kbps frames
+	var vbr []byte
+	for i := 0; i < 100; i++ {
+		vbr = append(vbr, mpegFrameBytes(byte(9+2*(i%2)))...)
+	}
+
+	// MP4: AAC LC, 44.1 kHz stereo, 2 s movie
+	mvhd := make([]byte, 100)
+	copy(mvhd[12:], u32(be, 1000))
+	copy(mvhd[16:], u32(be, 2000))
+	mdhd := make([]byte, 24)
+	copy(mdhd[12:], u32(be, 44100))
+	copy(mdhd[16:], u32(be, 88200))
+	hdlr := append(make([]byte, 8), []byte("soun\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")...)
+	esds := append([]byte{0, 0, 0, 0,
+		0x03, 22, 0, 1, 0,
+		0x04, 17, 0x40, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		0x05, 2, 0x12, 0x10)
+	sampleEntry := make([]byte, 28)
+	copy(sampleEntry[6:], u16(be, 1))
+	copy(sampleEntry[16:], u16(be, 2))
+	copy(sampleEntry[18:], u16(be, 16))
+	copy(sampleEntry[24:], u32(be, 44100<<16))
+	stsd := append(u32(be, 0), u32(be, 1)...)
+	stsd = append(stsd, mp4Box("mp4a", sampleEntry, mp4Box("esds", esds))...)
+	trak := mp4Box("trak", mp4Box("mdia", mp4Box("mdhd", mdhd), mp4Box("hdlr", hdlr),
+		mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))))
+	m4a := append(mp4Box("ftyp", []byte("M4A \x00\x00\x02\x00")), mp4Box("moov", mp4Box("mvhd", mvhd), trak)...)
+	m4a = append(m4a, mp4Box("mdat", make([]byte, 1000))...)
+
+	// MP4 music video: a video track before the same sound track
+	videoHdlr := append(make([]byte, 8), []byte("vide\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")...)
+	videoTrak := mp4Box("trak", mp4Box("mdia", mp4Box("mdhd", mdhd), mp4Box("hdlr", videoHdlr)))
+	video := append(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")), mp4Box("moov", mp4Box("mvhd", mvhd), videoTrak, trak)...)
+	video = append(video, mp4Box("mdat", make([]byte, 1000))...)
+
+	// Ogg Vorbis: 44.1 kHz stereo, last granule 88200
+	vorbisHead := append([]byte("\x01vorbis"), make([]byte, 23)...)
+	vorbisHead[11] = 2
+	copy(vorbisHead[12:], u32(le, 44100))
+	vorbis := append(oggPage(7, 0, vorbisHead), oggPage(7, 44100, make([]byte, 200))...)
+	vorbis = append(vorbis, oggPage(7, 88200, make([]byte, 200))...)
+
+	// Ogg Opus: stereo, 312 samples pre-skip, 2 s of audio
+	opusHead := append([]byte("OpusHead\x01\x02"), make([]byte, 9)...)
+	copy(opusHead[10:], u16(le, 312))
+	opus := append(oggPage(3, 0, opusHead), oggPage(3, 96312, make([]byte, 200))...)
+
+	// WAV: 44.1 kHz stereo 16-bit, 1 s of audio
+	wavFmt := append(u16(le, waveFormatPCM), u16(le, 2)...)
+	wavFmt = append(wavFmt, u32(le, 44100)...)
+	wavFmt = append(wavFmt, u32(le, 176400)...)
+	wavFmt = append(wavFmt, u16(le, 4)...)
+	wavFmt = append(wavFmt, u16(le, 16)...)
+	wav := append([]byte("RIFF\x00\x00\x00\x00WAVE"), []byte("LIST\x03\x00\x00\x00abc\x00")...)
+	wav = append(wav, append([]byte("fmt "), u32(le, 16)...)...)
+	wav = append(wav, wavFmt...)
+	wav = append(wav, append([]byte("data"), u32(le, 176400)...)...)
+	wav = append(wav, make([]byte, 176400)...)
+
+	// AIFF: 44.1 kHz stereo 16-bit, 44100 frames
+	comm := append(u16(be, 2), u32(be, 44100)...)
+	comm = append(comm, u16(be, 16)...)
+	comm = append(comm, u16(be, 0x400e)...)
+	comm = append(comm, 0xac, 0x44, 0, 0, 0, 0, 0, 0)
+	aiff := append([]byte("FORM\x00\x00\x00\x00AIFF"), append([]byte("COMM"), u32(be, 18)...)...)
+	aiff = append(aiff, comm...)
+	aiff = append(aiff, []byte("SSND\x00\x00\x00\x08")...)
+	aiff = append(aiff, make([]byte, 8)...)
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want AudioProperties
+	}{
+		{"FLAC", "a.flac", flac,
+			AudioProperties{Container: "flac", Codec: "flac", DurationMs: 2000, SampleRate: 44100, BitDepth: 16, Channels: 2, BitrateKbps: 0}},
+		{"FLAC with ID3", "b.flac", append(id3, flac...),
+			AudioProperties{Container: "flac", Codec: "flac", DurationMs: 2000, SampleRate: 44100, BitDepth: 16, Channels: 2, BitrateKbps: 0}},
+		{"MP3 CBR", "a.mp3", append(id3, cbr...),
+			AudioProperties{Container: "mp3", Codec: "mp3", DurationMs: 2606, SampleRate: 44100, Channels: 2, BitrateKbps: 128}},
+		{"MP3 Xing", "b.mp3", xingFile,
+			AudioProperties{Container: "mp3", Codec: "mp3", DurationMs: 26122, SampleRate: 44100, Channels: 2, BitrateKbps: 91}},
+		{"MP3 VBR scan", "c.mp3", vbr,
+			AudioProperties{Container: "mp3", Codec: "mp3", DurationMs: 2612, SampleRate: 44100, Channels: 2, BitrateKbps: 159}},
+		{"MP4 AAC", "a.m4a", m4a,
+			AudioProperties{Container: "mov,mp4,m4a,3gp,3g2,mj2", Codec: "aac", DurationMs: 2000, SampleRate: 44100, Channels: 2}},
+		{"MP4 video", "a.mp4", video,
+			AudioProperties{Container: "mov,mp4,m4a,3gp,3g2,mj2", Codec: "aac", DurationMs: 2000, SampleRate: 44100, Channels: 2, HasVideo: true}},
+		{"Ogg Vorbis", "a.ogg", vorbis,
+			AudioProperties{Container: "ogg", Codec: "vorbis", DurationMs: 2000, SampleRate: 44100, Channels: 2}},
+		{"Ogg Opus", "a.opus", opus,
+			AudioProperties{Container: "ogg", Codec: "opus", DurationMs: 2000, SampleRate: 48000, Channels: 2}},
+		{"WAV", "a.wav", wav,
+			AudioProperties{Container: "wav", Codec: "pcm_s16le", DurationMs: 1000, SampleRate: 44100, BitDepth: 16, Channels: 2}},
+		{"AIFF", "a.aiff", aiff,
+			AudioProperties{Container: "aiff", Codec: "pcm_s16be", DurationMs: 1000, SampleRate: 44100, BitDepth: 16, Channels: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestAudio(t, tt.file, tt.data)
+			got, err := ReadAudioProperties(path)
+			if err != nil {
+				t.Fatalf("ReadAudioProperties failed: %v", err)
+			}
+			// Size-derived bitrates depend on the synthetic file size
+			if tt.want.BitrateKbps == 0 {
+				tt.want.BitrateKbps = int(int64(len(tt.data)) * 8 / int64(tt.want.DurationMs))
+			}
+			got.Encoder = nil // Covered by TestReadMP3EncoderInfo
+			if *got != tt.want {
+				t.Errorf("ReadAudioProperties = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAudioPropertiesUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"text", []byte("this is not an audio file at all")},
+		{"FLAC without sample count", append([]byte("fLaC\x80\x00\x00\x22"), make([]byte, 34)...)},
+		// Containers left to ffprobe, even when their payload happens to look like MPEG frames
+		{"DSF", append(append([]byte("DSD \x1c\x00\x00\x00"), mpegFrameBytes(9)...), mpegFrameBytes(9)...)},
+		{"Matroska", append(append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0, 0, 0, 0}, mpegFrameBytes(9)...), mpegFrameBytes(9)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestAudio(t, "test.bin", tt.data)
+			if _, err := ReadAudioProperties(path); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExtendedToFloat(t *testing.T) {
+	for _, rate := range []float64{8000, 44100, 48000, 96000, 192000} {
+		exp := math.Ilogb(rate)
+		b := make([]byte, 10)
+		binary.BigEndian.PutUint16(b, uint16(16383+exp))
+		binary.BigEndian.PutUint64(b[2:], uint64(rate)<<(63-exp))
+		if got := extendedToFloat(b); got != rate {
+			t.Errorf("extendedToFloat(%v) = %v", rate, got)
+		}
+	}
+}
+
+// TestAudioPropertiesMatchFFprobe checks the native parsers against ffprobe on the fixtures
+// Run: cd internal/meta/testdata && ./generate-fixtures.sh
+func TestAudioPropertiesMatchFFprobe(t *testing.T) {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available")
+	}
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Skip("Test fixtures not generated")
+	}
+
+	e := &Extractor{}
+	tested := 0
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".mp3", ".flac", ".m4a", ".ogg", ".opus", ".wav", ".aiff":
+		default:
+			continue
+		}
+		path := filepath.Join("testdata", entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			want, err := e.extractWithFFprobe(path, nil)
+			if err != nil {
+				t.Skipf("ffprobe failed: %v", err)
+			}
+			props, err := ReadAudioProperties(path)
+			if err != nil {
+				t.Fatalf("ReadAudioProperties failed: %v", err)
+			}
+			got := props.Metadata()
+
+			if got.Container != want.Container || got.Codec != want.Codec || got.Lossless != want.Lossless {
+				t.Errorf("format = %s/%s lossless=%v, ffprobe %s/%s lossless=%v",
+					got.Container, got.Codec, got.Lossless, want.Container, want.Codec, want.Lossless)
+			}
+			if got.SampleRate != want.SampleRate || got.Channels != want.Channels {
+				t.Errorf("%d Hz %d ch, ffprobe %d Hz %d ch", got.SampleRate, got.Channels, want.SampleRate, want.Channels)
+			}
+			if want.BitDepth > 0 && got.BitDepth != want.BitDepth {
+				t.Errorf("bit depth = %d, ffprobe %d", got.BitDepth, want.BitDepth)
+			}
+			if diff := got.DurationMs - want.DurationMs; diff < -50 || diff > 50 {
+				t.Errorf("duration = %d ms, ffprobe %d ms", got.DurationMs, want.DurationMs)
+			}
+			if diff := math.Abs(float64(got.BitrateKbps - want.BitrateKbps)); diff > math.Max(2, 0.05*float64(want.BitrateKbps)) {
+				t.Errorf("bitrate = %d kbps, ffprobe %d kbps", got.BitrateKbps, want.BitrateKbps)
+			}
+		})
+		tested++
+	}
+	if tested == 0 {
+		t.Skip("Test fixtures not generated")
+	}
+}
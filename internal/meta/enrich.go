@@ -18,7 +18,7 @@ type EnrichmentResult struct {
 // EnrichFromPathAndSiblings attempts to fill in missing metadata fields using path analysis
 // and sibling file inference. This is called after primary metadata extraction.
 // This provides more advanced enrichment than the basic filename parsing.
-func EnrichFromPathAndSiblings(metadata *store.Metadata, srcPath string, db *store.Store) (*EnrichmentResult, error) {
+func EnrichFromPathAndSiblings(metadata *store.Metadata, srcPath string, db Repo) (*EnrichmentResult, error) {
 	result := &EnrichmentResult{
 		Enriched:      false,
 		FieldsChanged: make([]string, 0),
@@ -58,6 +58,7 @@ func enrichFromPath(metadata *store.Metadata, pathParts []string, filename strin
 			// Clean up artist name (skip numeric folders like "02/")
 			if !isNumericFolder(artistPart) && !isSpecialFolder(artistPart) {
 				metadata.TagArtist = artistPart
+				SetProvenance(metadata, "artist", store.SourceFolder, folderConfidence, artistPart)
 				result.Enriched = true
 				result.FieldsChanged = append(result.FieldsChanged, "artist_from_path")
 			}
@@ -69,17 +70,20 @@ func enrichFromPath(metadata *store.Metadata, pathParts []string, filename strin
 			album, year := parseYearAlbumPattern(albumPart)
 			if album != "" {
 				metadata.TagAlbum = album
+				SetProvenance(metadata, "album", store.SourceFolder, folderYearConfidence, albumPart)
 				result.Enriched = true
 				result.FieldsChanged = append(result.FieldsChanged, "album_from_path")
 
 				// Also extract year if found and not already set
 				if year != "" && metadata.TagDate == "" {
 					metadata.TagDate = year
+					SetProvenance(metadata, "date", store.SourceFolder, folderYearConfidence, albumPart)
 					result.FieldsChanged = append(result.FieldsChanged, "year_from_path")
 				}
 			} else if !isNumericFolder(albumPart) && !isSpecialFolder(albumPart) {
 				// Use folder name as-is
 				metadata.TagAlbum = albumPart
+				SetProvenance(metadata, "album", store.SourceFolder, folderConfidence, albumPart)
 				result.Enriched = true
 				result.FieldsChanged = append(result.FieldsChanged, "album_from_path")
 			}
@@ -90,6 +94,7 @@ func enrichFromPath(metadata *store.Metadata, pathParts []string, filename strin
 			disc := extractDiscNumber(albumPart)
 			if disc > 0 {
 				metadata.TagDisc = disc
+				SetProvenance(metadata, "disc", store.SourceFolder, discFolderConfidence, albumPart)
 				result.Enriched = true
 				result.FieldsChanged = append(result.FieldsChanged, "disc_from_path")
 			}
@@ -101,11 +106,13 @@ func enrichFromPath(metadata *store.Metadata, pathParts []string, filename strin
 		track, title := parseTrackFilename(filename)
 		if track > 0 && metadata.TagTrack == 0 {
 			metadata.TagTrack = track
+			SetProvenance(metadata, "track", store.SourceFilename, trackNameConfidence, filename)
 			result.Enriched = true
 			result.FieldsChanged = append(result.FieldsChanged, "track_from_filename")
 		}
 		if title != "" && metadata.TagTitle == "" {
 			metadata.TagTitle = title
+			SetProvenance(metadata, "title", store.SourceFilename, titleNameConfidence, filename)
 			result.Enriched = true
 			result.FieldsChanged = append(result.FieldsChanged, "title_from_filename")
 		}
@@ -113,7 +120,7 @@ func enrichFromPath(metadata *store.Metadata, pathParts []string, filename strin
 }
 
 // enrichFromSiblings infers missing metadata from files in the same directory
-func enrichFromSiblings(metadata *store.Metadata, dir string, db *store.Store, result *EnrichmentResult) {
+func enrichFromSiblings(metadata *store.Metadata, dir string, db Repo, result *EnrichmentResult) {
 	// Get sibling files (files in same directory)
 	siblings, err := db.GetFilesByDirectory(dir)
 	if err != nil || len(siblings) < 2 {
@@ -123,9 +130,10 @@ func enrichFromSiblings(metadata *store.Metadata, dir string, db *store.Store, r
 
 	// Infer artist from most common artist among siblings
 	if metadata.TagArtist == "" {
-		artist := mostCommonArtist(siblings, db)
+		artist, share := mostCommonArtist(siblings, db)
 		if artist != "" {
 			metadata.TagArtist = artist
+			SetProvenance(metadata, "artist", store.SourceSibling, share, dir)
 			result.Enriched = true
 			result.FieldsChanged = append(result.FieldsChanged, "artist_from_siblings")
 		}
@@ -133,9 +141,10 @@ func enrichFromSiblings(metadata *store.Metadata, dir string, db *store.Store, r
 
 	// Infer album from most common album among siblings
 	if metadata.TagAlbum == "" {
-		album := mostCommonAlbum(siblings, db)
+		album, share := mostCommonAlbum(siblings, db)
 		if album != "" {
 			metadata.TagAlbum = album
+			SetProvenance(metadata, "album", store.SourceSibling, share, dir)
 			result.Enriched = true
 			result.FieldsChanged = append(result.FieldsChanged, "album_from_siblings")
 		}
@@ -143,9 +152,10 @@ func enrichFromSiblings(metadata *store.Metadata, dir string, db *store.Store, r
 
 	// Infer album artist from most common album artist among siblings
 	if metadata.TagAlbumArtist == "" {
-		albumArtist := mostCommonAlbumArtist(siblings, db)
+		albumArtist, share := mostCommonAlbumArtist(siblings, db)
 		if albumArtist != "" {
 			metadata.TagAlbumArtist = albumArtist
+			SetProvenance(metadata, "album_artist", store.SourceSibling, share, dir)
 			result.Enriched = true
 			result.FieldsChanged = append(result.FieldsChanged, "album_artist_from_siblings")
 		}
@@ -275,8 +285,8 @@ func isSpecialFolder(s string) bool {
 	return special[s]
 }
 
-// mostCommonArtist returns the most frequent artist name among siblings
-func mostCommonArtist(siblings []*store.File, db *store.Store) string {
+// mostCommonArtist returns the most frequent artist name among siblings and its share
+func mostCommonArtist(siblings []*store.File, db Repo) (string, float64) {
 	counts := make(map[string]int)
 
 	for _, sibling := range siblings {
@@ -292,8 +302,8 @@ func mostCommonArtist(siblings []*store.File, db *store.Store) string {
 	return mostFrequent(counts)
 }
 
-// mostCommonAlbum returns the most frequent album name among siblings
-func mostCommonAlbum(siblings []*store.File, db *store.Store) string {
+// mostCommonAlbum returns the most frequent album name among siblings and its share
+func mostCommonAlbum(siblings []*store.File, db Repo) (string, float64) {
 	counts := make(map[string]int)
 
 	for _, sibling := range siblings {
@@ -309,8 +319,8 @@ func mostCommonAlbum(siblings []*store.File, db *store.Store) string {
 	return mostFrequent(counts)
 }
 
-// mostCommonAlbumArtist returns the most frequent album artist among siblings
-func mostCommonAlbumArtist(siblings []*store.File, db *store.Store) string {
+// mostCommonAlbumArtist returns the most frequent album artist among siblings and its share
+func mostCommonAlbumArtist(siblings []*store.File, db Repo) (string, float64) {
 	counts := make(map[string]int)
 
 	for _, sibling := range siblings {
@@ -326,11 +336,11 @@ func mostCommonAlbumArtist(siblings []*store.File, db *store.Store) string {
 	return mostFrequent(counts)
 }
 
-// mostFrequent returns the key with the highest count
+// mostFrequent returns the key with the highest count and its share of all votes
 // Requires >50% consensus for safety
-func mostFrequent(counts map[string]int) string {
+func mostFrequent(counts map[string]int) (string, float64) {
 	if len(counts) == 0 {
-		return ""
+		return "", 0
 	}
 
 	var maxKey string
@@ -346,9 +356,10 @@ func mostFrequent(counts map[string]int) string {
 	}
 
 	// Require majority consensus (>50%)
-	if float64(maxCount)/float64(totalCount) > 0.5 {
-		return maxKey
+	share := float64(maxCount) / float64(totalCount)
+	if share > 0.5 {
+		return maxKey, share
 	}
 
-	return ""
+	return "", 0
 }
@@ -190,10 +190,13 @@ func TestMostFrequent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := mostFrequent(tt.counts)
+			got, share := mostFrequent(tt.counts)
 			if got != tt.want {
 				t.Errorf("mostFrequent() = %q, want %q", got, tt.want)
 			}
+			if got != "" && (share <= 0.5 || share > 1.0) {
+				t.Errorf("mostFrequent() share = %.2f, want majority", share)
+			}
 		})
 	}
 }
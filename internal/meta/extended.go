@@ -0,0 +1,158 @@
+package meta
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// Tag names (lowercased) for secondary fields across ID3v2.2/2.3/2.4, Vorbis comments,
+// MP4 atoms and freeform atoms, and ffprobe's normalized names. First match wins.
+var (
+	genreKeys         = []string{"genre", "tcon", "tco", "©gen"}
+	composerKeys      = []string{"composer", "tcom", "tcm", "©wrt"}
+	conductorKeys     = []string{"conductor", "tpe3", "tp3"}
+	labelKeys         = []string{"label", "publisher", "organization", "tpub", "tpb"}
+	catalogNumberKeys = []string{"catalognumber", "catalog number", "catalog"}
+	bpmKeys           = []string{"bpm", "tbpm", "tbp", "tmpo"}
+	originalDateKeys  = []string{"originaldate", "original date", "tdor", "originalyear", "original year", "tory", "tor"}
+	artistSortKeys    = []string{"artistsort", "artist-sort", "sort_artist", "artist sort", "tsop", "soar"}
+	albumSortKeys     = []string{"albumsort", "album-sort", "sort_album", "album sort", "tsoa", "soal"}
+	commentKeys       = []string{"comment", "description", "comm", "com", "©cmt"}
+	podcastIDKeys     = []string{"tgid", "podcast_id", "egid", "wfed", "podcasturl", "purl"}
+	artistsKeys       = []string{"artists"}
+)
+
+// rawTextIndex flattens dhowden/tag raw frames into lowercased name -> text
+// ID3 TXXX frames are indexed by their description, repeated frames (TXXX_0) by the first value.
+func rawTextIndex(raw map[string]interface{}) map[string]string {
+	index := make(map[string]string, len(raw))
+	add := func(name, value string) {
+		name = strings.ToLower(name)
+		value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+		if _, ok := index[name]; !ok && value != "" {
+			index[name] = value
+		}
+	}
+
+	for _, key := range sortedKeys(raw) {
+		// Repeated ID3 frames are suffixed: TXXX, TXXX_0, TXXX_1, ...
+		name := key
+		if i := strings.Index(key, "_"); (i == 4 || i == 3) && strings.ToUpper(key[:i]) == key[:i] {
+			name = key[:i]
+		}
+
+		switch v := raw[key].(type) {
+		case string:
+			add(name, v)
+		case int:
+			if v > 0 {
+				add(name, strconv.Itoa(v))
+			}
+		case *tag.Comm:
+			if name == "TXXX" || name == "TXX" {
+				add(v.Description, v.Text)
+			} else {
+				add(name, v.Text)
+			}
+		}
+	}
+	return index
+}
+
+// ffprobeTextIndex lowercases ffprobe tag names
+func ffprobeTextIndex(tags map[string]string) map[string]string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	// Sorted so differently-cased duplicates resolve deterministically
+	sort.Strings(keys)
+
+	index := make(map[string]string, len(tags))
+	for _, key := range keys {
+		name := strings.ToLower(key)
+		if value := strings.TrimSpace(tags[key]); value != "" {
+			if _, ok := index[name]; !ok {
+				index[name] = value
+			}
+		}
+	}
+	return index
+}
+
+// lookupText returns the first non-empty value among keys
+func lookupText(index map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value := index[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// fillExtendedTags sets the secondary tag fields that are still empty from a text index
+func fillExtendedTags(m *store.Metadata, index map[string]string) {
+	setIfEmpty := func(field *string, keys []string) {
+		if *field == "" {
+			*field = lookupText(index, keys)
+		}
+	}
+
+	setIfEmpty(&m.TagGenre, genreKeys)
+	setIfEmpty(&m.TagComposer, composerKeys)
+	setIfEmpty(&m.TagConductor, conductorKeys)
+	setIfEmpty(&m.TagLabel, labelKeys)
+	setIfEmpty(&m.TagCatalogNumber, catalogNumberKeys)
+	setIfEmpty(&m.TagOriginalDate, originalDateKeys)
+	setIfEmpty(&m.TagArtistSort, artistSortKeys)
+	setIfEmpty(&m.TagAlbumSort, albumSortKeys)
+	setIfEmpty(&m.TagComment, commentKeys)
+	setIfEmpty(&m.PodcastID, podcastIDKeys)
+	setIfEmpty(&m.TagArtists, artistsKeys)
+
+	if m.TagBPM == 0 {
+		m.TagBPM = ParseBPM(lookupText(index, bpmKeys))
+	}
+}
+
+// overlayExtendedTags copies non-empty secondary tags from src onto dst
+func overlayExtendedTags(dst, src *store.Metadata) {
+	overlay := func(to *string, from string) {
+		if from != "" {
+			*to = from
+		}
+	}
+
+	overlay(&dst.TagGenre, src.TagGenre)
+	overlay(&dst.TagComposer, src.TagComposer)
+	overlay(&dst.TagConductor, src.TagConductor)
+	overlay(&dst.TagLabel, src.TagLabel)
+	overlay(&dst.TagCatalogNumber, src.TagCatalogNumber)
+	overlay(&dst.TagOriginalDate, src.TagOriginalDate)
+	overlay(&dst.TagArtistSort, src.TagArtistSort)
+	overlay(&dst.TagAlbumSort, src.TagAlbumSort)
+	overlay(&dst.TagComment, src.TagComment)
+	overlay(&dst.PodcastID, src.PodcastID)
+	overlay(&dst.TagArtists, src.TagArtists)
+	if src.TagBPM > 0 {
+		dst.TagBPM = src.TagBPM
+	}
+}
+
+// ParseBPM parses a BPM tag ("128", "127.9", "128 BPM"), returning 0 if invalid
+func ParseBPM(s string) int {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "BPM"))
+	if s == "" {
+		return 0
+	}
+	bpm, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil || bpm <= 0 || bpm > 1000 {
+		return 0
+	}
+	return int(math.Round(bpm))
+}
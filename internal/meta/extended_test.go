@@ -0,0 +1,136 @@
+package meta
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhowden/tag"
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestParseBPM(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"128", 128},
+		{"127.6", 128},
+		{"120,0", 120},
+		{"174 BPM", 174},
+		{"", 0},
+		{"fast", 0},
+		{"-5", 0},
+	}
+
+	for _, tt := range tests {
+		if got := ParseBPM(tt.input); got != tt.expected {
+			t.Errorf("ParseBPM(%q) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFillExtendedTagsFromRaw(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      map[string]interface{}
+		expected store.Metadata
+	}{
+		{
+			name: "ID3v2.4",
+			raw: map[string]interface{}{
+				"TCON":   "Classical",
+				"TCOM":   "Ludwig van Beethoven",
+				"TPE3":   "Herbert von Karajan",
+				"TPUB":   "Deutsche Grammophon",
+				"TBPM":   "72",
+				"TDOR":   "1963",
+				"TSOP":   "Berliner Philharmoniker",
+				"TSOA":   "Symphonies 5 & 7",
+				"TXXX":   &tag.Comm{Description: "CATALOGNUMBER", Text: "447 400-2"},
+				"TXXX_0": &tag.Comm{Description: "MusicBrainz Album Id", Text: "x"},
+			},
+			expected: store.Metadata{
+				TagGenre: "Classical", TagComposer: "Ludwig van Beethoven", TagConductor: "Herbert von Karajan",
+				TagLabel: "Deutsche Grammophon", TagCatalogNumber: "447 400-2", TagBPM: 72,
+				TagOriginalDate: "1963", TagArtistSort: "Berliner Philharmoniker", TagAlbumSort: "Symphonies 5 & 7",
+			},
+		},
+		{
+			name: "ID3v2.3 original year",
+			raw: map[string]interface{}{
+				"TORY": "1971",
+				"TPE3": "Conductor",
+			},
+			expected: store.Metadata{TagConductor: "Conductor", TagOriginalDate: "1971"},
+		},
+		{
+			name: "Vorbis comments",
+			raw: map[string]interface{}{
+				"genre":         "Techno",
+				"label":         "Tresor",
+				"catalognumber": "TRESOR100",
+				"bpm":           "134.2",
+				"originaldate":  "1998-05-04",
+				"artistsort":    "Mills, Jeff",
+				"comment":       "Vinyl rip",
+				"artists":       "Jeff Mills; Mike Banks",
+			},
+			expected: store.Metadata{
+				TagGenre: "Techno", TagLabel: "Tresor", TagCatalogNumber: "TRESOR100", TagBPM: 134,
+				TagOriginalDate: "1998-05-04", TagArtistSort: "Mills, Jeff", TagComment: "Vinyl rip",
+				TagArtists: "Jeff Mills; Mike Banks",
+			},
+		},
+		{
+			name: "MP4 atoms",
+			raw: map[string]interface{}{
+				"tmpo":          120,
+				"soar":          "Beatles, The",
+				"soal":          "Abbey Road",
+				"LABEL":         "Apple",
+				"CATALOGNUMBER": "PCS 7088",
+			},
+			expected: store.Metadata{
+				TagBPM: 120, TagArtistSort: "Beatles, The", TagAlbumSort: "Abbey Road",
+				TagLabel: "Apple", TagCatalogNumber: "PCS 7088",
+			},
+		},
+		{
+			name: "ID3 podcast episode",
+			raw: map[string]interface{}{
+				"TCON": "Podcast",
+				"TGID": "https://example.com/episodes/42",
+				"WFED": "https://example.com/feed.xml",
+			},
+			expected: store.Metadata{TagGenre: "Podcast", PodcastID: "https://example.com/episodes/42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &store.Metadata{}
+			fillExtendedTags(got, rawTextIndex(tt.raw))
+			if !reflect.DeepEqual(*got, tt.expected) {
+				t.Errorf("fillExtendedTags() = %+v, want %+v", *got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFillExtendedTagsFromFFprobe(t *testing.T) {
+	got := &store.Metadata{TagGenre: "From Tags"}
+	fillExtendedTags(got, ffprobeTextIndex(map[string]string{
+		"GENRE":       "Ignored",
+		"composer":    "Bach",
+		"publisher":   "Naxos",
+		"TBPM":        "90",
+		"artist-sort": "Gould, Glenn",
+	}))
+
+	expected := store.Metadata{
+		TagGenre: "From Tags", TagComposer: "Bach", TagLabel: "Naxos", TagBPM: 90, TagArtistSort: "Gould, Glenn",
+	}
+	if !reflect.DeepEqual(*got, expected) {
+		t.Errorf("fillExtendedTags() = %+v, want %+v", *got, expected)
+	}
+}
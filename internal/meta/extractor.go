@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,45 +15,54 @@ import (
 	"github.com/franz/music-janitor/internal/util"
 )
 
+// Repo is the state metadata extraction reads and writes
+type Repo interface {
+	store.FileRepo
+	store.MetadataRepo
+}
+
 // Extractor extracts metadata from audio files
 type Extractor struct {
-	store       *store.Store
+	store       Repo
 	concurrency int
 	logger      *report.EventLogger
+	rules       *RuleSet
 }
 
 // Config holds extractor configuration
 type Config struct {
-	Store       *store.Store
+	Store       Repo
 	Concurrency int
 	Logger      *report.EventLogger
+
+	// Rules cleans the extracted tags (nil: the built-in rules)
+	Rules *RuleSet
 }
 
 // ExtractFromPath extracts metadata from a single file path
 // This is a convenience function for re-scanning individual files
 func ExtractFromPath(path string) (*store.Metadata, error) {
-	// Try tag library first (faster, covers most formats)
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
 	e := &Extractor{}
-	metadata, err := e.extractWithTag(path)
-	if err == nil && metadata != nil {
-		// Use ffprobe to fill in audio properties (tag library doesn't provide these)
-		ffprobeMetadata, ffErr := e.extractWithFFprobe(path)
-		if ffErr == nil && ffprobeMetadata != nil {
-			// Merge: keep tags from tag library, use audio properties from ffprobe
-			metadata.Container = ffprobeMetadata.Container
-			metadata.Codec = ffprobeMetadata.Codec
-			metadata.DurationMs = ffprobeMetadata.DurationMs
-			metadata.SampleRate = ffprobeMetadata.SampleRate
-			metadata.BitDepth = ffprobeMetadata.BitDepth
-			metadata.BitrateKbps = ffprobeMetadata.BitrateKbps
-			metadata.Channels = ffprobeMetadata.Channels
-			metadata.Lossless = ffprobeMetadata.Lossless
-		}
-		return metadata, nil
+	tagMetadata, tagErr := e.extractWithTag(src)
+	ffprobeMetadata, ffprobeErr := e.extractAudioProperties(path, src, tagMetadata)
+	if tagErr != nil && ffprobeErr != nil {
+		return nil, fmt.Errorf("all extraction methods failed: tag: %v, ffprobe: %v", tagErr, ffprobeErr)
 	}
 
-	// Fallback to ffprobe if tag library fails
-	return e.extractWithFFprobe(path)
+	// Same merge as metadata extraction, so a rescan keeps every field
+	metadata := mergeExtracted(tagMetadata, ffprobeMetadata)
+	if metadata == nil {
+		return nil, fmt.Errorf("no metadata could be extracted")
+	}
+	recordReadProvenance(metadata, tagMetadata)
+	AssignArtistCredits(metadata)
+	return metadata, nil
 }
 
 // New creates a new metadata extractor
@@ -67,6 +75,7 @@ func New(cfg *Config) *Extractor {
 		store:       cfg.Store,
 		concurrency: cfg.Concurrency,
 		logger:      cfg.Logger,
+		rules:       cfg.Rules,
 	}
 }
 
@@ -322,68 +331,44 @@ func (e *Extractor) Extract(ctx context.Context) (*Result, error) {
 func (e *Extractor) extractFile(file *store.File) (*store.Metadata, error) {
 	util.DebugLog("Extracting metadata: %s", file.SrcPath)
 
-	var metadata *store.Metadata
+	// Archive entries are read in place (or from memory when compressed)
+	src, err := util.OpenSourceReader(file.SrcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
 
 	// Try tag library for tags
-	tagMetadata, tagErr := e.extractWithTag(file.SrcPath)
+	tagMetadata, tagErr := e.extractWithTag(src)
 
-	// Always try ffprobe for audio properties (codec, bitrate, sample rate, etc.)
-	// ffprobe provides comprehensive audio properties that tag libraries don't
-	ffprobeMetadata, ffprobeErr := e.extractWithFFprobe(file.SrcPath)
+	// Audio properties (codec, bitrate, sample rate, etc.) come from the file
+	// headers, or from ffprobe when the format isn't parsed natively
+	ffprobeMetadata, ffprobeErr := e.extractAudioProperties(file.SrcPath, src, tagMetadata)
 
 	if tagErr != nil && ffprobeErr != nil {
 		return nil, fmt.Errorf("all extraction methods failed: tag: %v, ffprobe: %v", tagErr, ffprobeErr)
 	}
 
 	// Merge results: prefer tag library for tags, ffprobe for audio properties
-	if ffprobeMetadata != nil {
-		metadata = ffprobeMetadata // Start with ffprobe (has audio properties)
-
-		// Overlay tags from tag library if available (often more accurate for tags)
-		if tagMetadata != nil {
-			if tagMetadata.TagTitle != "" {
-				metadata.TagTitle = tagMetadata.TagTitle
-			}
-			if tagMetadata.TagArtist != "" {
-				metadata.TagArtist = tagMetadata.TagArtist
-			}
-			if tagMetadata.TagAlbum != "" {
-				metadata.TagAlbum = tagMetadata.TagAlbum
-			}
-			if tagMetadata.TagAlbumArtist != "" {
-				metadata.TagAlbumArtist = tagMetadata.TagAlbumArtist
-			}
-			if tagMetadata.TagDate != "" {
-				metadata.TagDate = tagMetadata.TagDate
-			}
-			if tagMetadata.TagTrack > 0 {
-				metadata.TagTrack = tagMetadata.TagTrack
-				metadata.TagTrackTotal = tagMetadata.TagTrackTotal
-			}
-			if tagMetadata.TagDisc > 0 {
-				metadata.TagDisc = tagMetadata.TagDisc
-				metadata.TagDiscTotal = tagMetadata.TagDiscTotal
-			}
-			if tagMetadata.Format != "" {
-				metadata.Format = tagMetadata.Format
-			}
-		}
-	} else if tagMetadata != nil {
-		metadata = tagMetadata // Fallback to tag-only if ffprobe failed
-	} else {
+	metadata := mergeExtracted(tagMetadata, ffprobeMetadata)
+	if metadata == nil {
 		return nil, fmt.Errorf("no metadata could be extracted")
 	}
 
 	// Set file ID
 	metadata.FileID = file.ID
+	recordReadProvenance(metadata, tagMetadata)
+
+	// Folder and file names hint at missing fields; an archive counts as a folder
+	hintPath := util.ArchiveFolderPath(file.SrcPath)
 
 	// Enrich with filename-based hints for missing fields
-	EnrichMetadata(metadata, file.SrcPath)
+	EnrichMetadata(metadata, hintPath)
 
 	// Auto-healing: Advanced path-based enrichment
 	// Note: Sibling enrichment disabled here (requires database in optimized path)
 	if util.GetAutoHealing() {
-		enrichResult, err := EnrichFromPathAndSiblings(metadata, file.SrcPath, nil)
+		enrichResult, err := EnrichFromPathAndSiblings(metadata, hintPath, nil)
 		if err != nil {
 			util.WarnLog("Enrichment failed for %s: %v", file.SrcPath, err)
 		} else if enrichResult.Enriched {
@@ -401,18 +386,13 @@ func (e *Extractor) extractFile(file *store.File) (*store.Metadata, error) {
 		}
 
 		// Auto-healing: Pattern-based cleaning (from tree.txt analysis)
-		cleanResult := ApplyPatternCleaning(metadata, file.SrcPath)
+		cleanResult := ApplyPatternCleaningWith(e.rules, metadata, hintPath)
 		if cleanResult.Changed {
 			util.DebugLog("Auto-healing cleaned %s: %v", file.SrcPath, cleanResult.FieldsCleaned)
 			if e.logger != nil {
-				e.logger.Log(&report.Event{
-					Timestamp: time.Now(),
-					Level:     report.LevelInfo,
-					Event:     report.EventAutoHeal,
-					SrcPath:   file.SrcPath,
-					Action:    "clean_metadata",
-					Reason:    strings.Join(cleanResult.FieldsCleaned, ","),
-				})
+				for _, c := range cleanResult.Changes {
+					e.logger.LogRuleChange(file.SrcPath, c.Rule, c.Field, c.Before, c.After)
+				}
 			}
 		}
 		// Log warnings about suspicious content
@@ -423,6 +403,9 @@ func (e *Extractor) extractFile(file *store.File) (*store.Metadata, error) {
 		}
 	}
 
+	// Primary, collaborating and featured artists
+	AssignArtistCredits(metadata)
+
 	// Store metadata
 	if err := e.store.InsertMetadata(metadata); err != nil {
 		return nil, fmt.Errorf("failed to store metadata: %w", err)
@@ -435,66 +418,42 @@ func (e *Extractor) extractFile(file *store.File) (*store.Metadata, error) {
 func (e *Extractor) extractFileOptimized(file *store.File, metadataChan chan<- *store.Metadata) (*store.Metadata, error) {
 	util.DebugLog("Extracting metadata: %s", file.SrcPath)
 
-	var metadata *store.Metadata
+	// Archive entries are read in place (or from memory when compressed)
+	src, err := util.OpenSourceReader(file.SrcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
 
 	// Try tag library for tags
-	tagMetadata, tagErr := e.extractWithTag(file.SrcPath)
+	tagMetadata, tagErr := e.extractWithTag(src)
 
-	// Always try ffprobe for audio properties
-	ffprobeMetadata, ffprobeErr := e.extractWithFFprobe(file.SrcPath)
+	// Audio properties from file headers, or ffprobe as a fallback
+	ffprobeMetadata, ffprobeErr := e.extractAudioProperties(file.SrcPath, src, tagMetadata)
 
 	if tagErr != nil && ffprobeErr != nil {
 		return nil, fmt.Errorf("all extraction methods failed: tag: %v, ffprobe: %v", tagErr, ffprobeErr)
 	}
 
 	// Merge results: prefer tag library for tags, ffprobe for audio properties
-	if ffprobeMetadata != nil {
-		metadata = ffprobeMetadata
-
-		// Overlay tags from tag library if available
-		if tagMetadata != nil {
-			if tagMetadata.TagTitle != "" {
-				metadata.TagTitle = tagMetadata.TagTitle
-			}
-			if tagMetadata.TagArtist != "" {
-				metadata.TagArtist = tagMetadata.TagArtist
-			}
-			if tagMetadata.TagAlbum != "" {
-				metadata.TagAlbum = tagMetadata.TagAlbum
-			}
-			if tagMetadata.TagAlbumArtist != "" {
-				metadata.TagAlbumArtist = tagMetadata.TagAlbumArtist
-			}
-			if tagMetadata.TagDate != "" {
-				metadata.TagDate = tagMetadata.TagDate
-			}
-			if tagMetadata.TagTrack > 0 {
-				metadata.TagTrack = tagMetadata.TagTrack
-				metadata.TagTrackTotal = tagMetadata.TagTrackTotal
-			}
-			if tagMetadata.TagDisc > 0 {
-				metadata.TagDisc = tagMetadata.TagDisc
-				metadata.TagDiscTotal = tagMetadata.TagDiscTotal
-			}
-			if tagMetadata.Format != "" {
-				metadata.Format = tagMetadata.Format
-			}
-		}
-	} else if tagMetadata != nil {
-		metadata = tagMetadata
-	} else {
+	metadata := mergeExtracted(tagMetadata, ffprobeMetadata)
+	if metadata == nil {
 		return nil, fmt.Errorf("no metadata could be extracted")
 	}
 
 	// Set file ID
 	metadata.FileID = file.ID
+	recordReadProvenance(metadata, tagMetadata)
+
+	// Folder and file names hint at missing fields; an archive counts as a folder
+	hintPath := util.ArchiveFolderPath(file.SrcPath)
 
 	// Enrich with filename-based hints
-	EnrichMetadata(metadata, file.SrcPath)
+	EnrichMetadata(metadata, hintPath)
 
 	// Auto-healing: Advanced path-based enrichment (no sibling enrichment in batch mode)
 	if util.GetAutoHealing() {
-		enrichResult, err := EnrichFromPathAndSiblings(metadata, file.SrcPath, nil)
+		enrichResult, err := EnrichFromPathAndSiblings(metadata, hintPath, nil)
 		if err != nil {
 			util.WarnLog("Enrichment failed for %s: %v", file.SrcPath, err)
 		} else if enrichResult.Enriched {
@@ -512,18 +471,13 @@ func (e *Extractor) extractFileOptimized(file *store.File, metadataChan chan<- *
 		}
 
 		// Auto-healing: Pattern-based cleaning (from tree.txt analysis)
-		cleanResult := ApplyPatternCleaning(metadata, file.SrcPath)
+		cleanResult := ApplyPatternCleaningWith(e.rules, metadata, hintPath)
 		if cleanResult.Changed {
 			util.DebugLog("Auto-healing cleaned %s: %v", file.SrcPath, cleanResult.FieldsCleaned)
 			if e.logger != nil {
-				e.logger.Log(&report.Event{
-					Timestamp: time.Now(),
-					Level:     report.LevelInfo,
-					Event:     report.EventAutoHeal,
-					SrcPath:   file.SrcPath,
-					Action:    "clean_metadata",
-					Reason:    strings.Join(cleanResult.FieldsCleaned, ","),
-				})
+				for _, c := range cleanResult.Changes {
+					e.logger.LogRuleChange(file.SrcPath, c.Rule, c.Field, c.Before, c.After)
+				}
 			}
 		}
 		// Log warnings (suppressed in batch mode for performance)
@@ -534,21 +488,67 @@ func (e *Extractor) extractFileOptimized(file *store.File, metadataChan chan<- *
 		}
 	}
 
+	// Primary, collaborating and featured artists
+	AssignArtistCredits(metadata)
+
 	// Queue for batch insert
 	metadataChan <- metadata
 
 	return metadata, nil
 }
 
-// extractWithTag uses dhowden/tag library to extract metadata
-func (e *Extractor) extractWithTag(path string) (*store.Metadata, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+// mergeExtracted merges the tag library's tags into the audio properties read
+// from the file headers or ffprobe (which carry HasVideo, Chapters, etc.)
+// Returns nil if neither extraction succeeded.
+func mergeExtracted(tagMetadata, ffprobeMetadata *store.Metadata) *store.Metadata {
+	if ffprobeMetadata != nil {
+		metadata := ffprobeMetadata // Start with ffprobe (has audio properties)
+
+		// Overlay tags from tag library if available (often more accurate for tags)
+		if tagMetadata != nil {
+			if tagMetadata.TagTitle != "" {
+				metadata.TagTitle = tagMetadata.TagTitle
+			}
+			if tagMetadata.TagArtist != "" {
+				metadata.TagArtist = tagMetadata.TagArtist
+			}
+			if tagMetadata.TagAlbum != "" {
+				metadata.TagAlbum = tagMetadata.TagAlbum
+			}
+			if tagMetadata.TagAlbumArtist != "" {
+				metadata.TagAlbumArtist = tagMetadata.TagAlbumArtist
+			}
+			if tagMetadata.TagDate != "" {
+				metadata.TagDate = tagMetadata.TagDate
+			}
+			if tagMetadata.TagTrack > 0 {
+				metadata.TagTrack = tagMetadata.TagTrack
+				metadata.TagTrackTotal = tagMetadata.TagTrackTotal
+			}
+			if tagMetadata.TagDisc > 0 {
+				metadata.TagDisc = tagMetadata.TagDisc
+				metadata.TagDiscTotal = tagMetadata.TagDiscTotal
+			}
+			if tagMetadata.Format != "" {
+				metadata.Format = tagMetadata.Format
+			}
+			if tagMetadata.MusicBrainzRecordingID != "" {
+				metadata.MusicBrainzRecordingID = tagMetadata.MusicBrainzRecordingID
+			}
+			if tagMetadata.ISRC != "" {
+				metadata.ISRC = tagMetadata.ISRC
+			}
+			overlayExtendedTags(metadata, tagMetadata)
+			metadata.Pictures = tagMetadata.Pictures
+		}
+		return metadata
 	}
-	defer f.Close()
+	return tagMetadata // Tag-only if ffprobe failed (nil if both failed)
+}
 
-	m, err := tag.ReadFrom(f)
+// extractWithTag uses dhowden/tag library to extract metadata
+func (e *Extractor) extractWithTag(src *util.SourceReader) (*store.Metadata, error) {
+	m, err := tag.ReadFrom(src.Reader())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read tags: %w", err)
 	}
@@ -562,6 +562,16 @@ func (e *Extractor) extractWithTag(path string) (*store.Metadata, error) {
 		TagAlbumArtist: m.AlbumArtist(),
 	}
 
+	// Repeated Vorbis ARTIST and ARTISTS fields (dhowden/tag keeps only the last value)
+	if m.FileType() == tag.FLAC {
+		if fields, err := readFLACComments(src.Reader()); err == nil {
+			if artist := flacArtist(fields); artist != "" {
+				metadata.TagArtist = artist
+			}
+			metadata.TagArtists = strings.Join(fields["ARTISTS"], "; ")
+		}
+	}
+
 	// Extract year from various formats
 	if m.Year() > 0 {
 		metadata.TagDate = fmt.Sprintf("%d", m.Year())
@@ -598,6 +608,18 @@ func (e *Extractor) extractWithTag(path string) (*store.Metadata, error) {
 		}
 	}
 
+	// Recording identifiers (ID3 UFID/TSRC, Vorbis MUSICBRAINZ_TRACKID/ISRC, MP4 freeform atoms)
+	metadata.MusicBrainzRecordingID, metadata.ISRC = identifiersFromRaw(m.Raw())
+
+	// Secondary tags: genre, composer, conductor, label, catalog number, BPM, ...
+	metadata.TagGenre = strings.TrimSpace(m.Genre())
+	metadata.TagComposer = strings.TrimSpace(m.Composer())
+	metadata.TagComment = strings.TrimSpace(m.Comment())
+	fillExtendedTags(metadata, rawTextIndex(m.Raw()))
+
+	// Embedded artwork: hash, dimensions and MIME type of each picture
+	metadata.Pictures = DescribePictures(picturesFromTag(m, src))
+
 	// Store raw tags as JSON
 	rawTags := map[string]interface{}{
 		"format":       m.Format(),
@@ -614,6 +636,8 @@ func (e *Extractor) extractWithTag(path string) (*store.Metadata, error) {
 		"disc":         disc,
 		"disc_total":   discTotal,
 		"compilation":  metadata.TagCompilation,
+		"isrc":         metadata.ISRC,
+		"mb_recording": metadata.MusicBrainzRecordingID,
 	}
 
 	rawJSON, _ := json.Marshal(rawTags)
@@ -624,15 +648,59 @@ func (e *Extractor) extractWithTag(path string) (*store.Metadata, error) {
 	return metadata, nil
 }
 
+// extractAudioProperties returns metadata with audio properties for merging with tags
+// When the tag library already read the tags, properties are parsed from the file
+// headers and ffprobe only runs for formats the native parsers don't handle
+// Without tags, ffprobe runs first since it also reads tags the tag library can't
+func (e *Extractor) extractAudioProperties(path string, src *util.SourceReader, tagMetadata *store.Metadata) (*store.Metadata, error) {
+	if tagMetadata != nil {
+		props, err := readAudioProperties(src.Reader())
+		if err == nil {
+			metadata := *tagMetadata
+			props.Apply(&metadata)
+			if metadata.DurationMs >= chapterProbeMinMs {
+				metadata.Chapters = countChapters(path, src)
+			}
+			return &metadata, nil
+		}
+		util.DebugLog("Native audio properties unavailable for %s, using ffprobe: %v", path, err)
+		return e.extractWithFFprobe(path, src)
+	}
+
+	metadata, err := e.extractWithFFprobe(path, src)
+	if err == nil {
+		return metadata, nil
+	}
+	props, nativeErr := readAudioProperties(src.Reader())
+	if nativeErr != nil {
+		return nil, err
+	}
+	return props.Metadata(), nil
+}
+
+// chapterProbeMinMs is the duration from which files read natively are also
+// probed for chapter markers; audiobooks, podcasts and DJ mixes are long
+const chapterProbeMinMs = 10 * 60 * 1000
+
+// countChapters returns the number of chapter markers ffprobe finds, or 0
+func countChapters(path string, src *util.SourceReader) int {
+	info, err := probeSource(path, src)
+	if err != nil {
+		return 0
+	}
+	return len(info.Chapters)
+}
+
 // extractWithFFprobe uses ffprobe to extract metadata (fallback)
-func (e *Extractor) extractWithFFprobe(path string) (*store.Metadata, error) {
+// Archive entries are streamed from src (nil: opened again).
+func (e *Extractor) extractWithFFprobe(path string, src *util.SourceReader) (*store.Metadata, error) {
 	// Get ffprobe info
-	info, err := RunFFprobe(path)
+	info, err := probeSource(path, src)
 	if err != nil {
 		return nil, fmt.Errorf("ffprobe failed: %w", err)
 	}
 
-	metadata := &store.Metadata{}
+	metadata := &store.Metadata{Chapters: len(info.Chapters)}
 
 	// Extract format info
 	if info.Format != nil {
@@ -670,12 +738,16 @@ func (e *Extractor) extractWithFFprobe(path string) (*store.Metadata, error) {
 			if discStr := getTag(tags, "disc", "DISC"); discStr != "" {
 				fmt.Sscanf(discStr, "%d", &metadata.TagDisc)
 			}
+
+			metadata.MusicBrainzRecordingID, metadata.ISRC = identifiersFromFFprobe(tags)
+			fillExtendedTags(metadata, ffprobeTextIndex(tags))
 		}
 	}
 
+	metadata.HasVideo = info.HasVideo()
+
 	// Extract stream info (audio properties)
-	if len(info.Streams) > 0 {
-		stream := info.Streams[0] // First audio stream
+	if stream := info.AudioStream(); stream != nil {
 		metadata.Codec = stream.CodecName
 		metadata.SampleRate = stream.SampleRate
 		metadata.Channels = stream.Channels
@@ -689,6 +761,13 @@ func (e *Extractor) extractWithFFprobe(path string) (*store.Metadata, error) {
 		} else if stream.BitsPerRawSample.Value > 0 {
 			metadata.BitDepth = stream.BitsPerRawSample.Value
 		}
+
+		// The container bitrate of a music video is mostly picture
+		if info.HasVideo() && stream.BitRate != "" {
+			var bitrate int
+			fmt.Sscanf(stream.BitRate, "%d", &bitrate)
+			metadata.BitrateKbps = bitrate / 1000
+		}
 	}
 
 	// Store raw ffprobe output
@@ -718,12 +797,14 @@ func isLosslessCodec(codec string) bool {
 		"wavpack": true,
 		"wv":      true,
 		"tta":     true,
+		"tak":     true,
 		"pcm":     true,
 		"wav":     true,
 		"aiff":    true,
 	}
 	// Also check for PCM variants (pcm_s16le, pcm_s16be, pcm_s24le, etc.)
-	if strings.HasPrefix(codec, "pcm_") {
+	// and DSD (dsd_lsbf_planar in DSF, dsd_msbf in DFF)
+	if strings.HasPrefix(codec, "pcm_") || strings.HasPrefix(codec, "dsd_") {
 		return true
 	}
 	return lossless[codec]
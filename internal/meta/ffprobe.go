@@ -3,6 +3,7 @@ package meta
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os/exec"
 	"strconv"
 
@@ -11,8 +12,17 @@ import (
 
 // FFprobeInfo represents the output from ffprobe
 type FFprobeInfo struct {
-	Streams []FFprobeStream `json:"streams"`
-	Format  *FFprobeFormat  `json:"format"`
+	Streams  []FFprobeStream  `json:"streams"`
+	Format   *FFprobeFormat   `json:"format"`
+	Chapters []FFprobeChapter `json:"chapters"`
+}
+
+// FFprobeChapter represents a chapter marker (MP4 chapters, ID3 CHAP, Matroska)
+type FFprobeChapter struct {
+	ID        int               `json:"id"`
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
 }
 
 // IntOrString can unmarshal both integers and strings from JSON
@@ -63,6 +73,30 @@ type FFprobeStream struct {
 	BitsPerRawSample   IntOrString `json:"bits_per_raw_sample"`
 	Duration           string      `json:"duration"`
 	BitRate            string      `json:"bit_rate"`
+	Disposition        struct {
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+// AudioStream returns the first audio stream, or nil. Music videos and files
+// with embedded cover art also carry video streams, which may come first.
+func (info *FFprobeInfo) AudioStream() *FFprobeStream {
+	for i := range info.Streams {
+		if info.Streams[i].CodecType == "audio" {
+			return &info.Streams[i]
+		}
+	}
+	return nil
+}
+
+// HasVideo reports whether the file has a video stream other than cover art
+func (info *FFprobeInfo) HasVideo() bool {
+	for _, s := range info.Streams {
+		if s.CodecType == "video" && s.Disposition.AttachedPic == 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // FFprobeFormat represents container format metadata
@@ -77,7 +111,30 @@ type FFprobeFormat struct {
 }
 
 // RunFFprobe executes ffprobe and parses the JSON output
+// Archive entries are streamed to ffprobe on stdin.
 func RunFFprobe(path string) (*FFprobeInfo, error) {
+	if !util.IsArchivePath(path) {
+		return runFFprobe(path, nil)
+	}
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return runFFprobe("pipe:0", src.Reader())
+}
+
+// probeSource runs ffprobe on the source at path, streaming archive entries
+// from the already open src (nil: open the entry again)
+func probeSource(path string, src *util.SourceReader) (*FFprobeInfo, error) {
+	if src == nil || !util.IsArchivePath(path) {
+		return RunFFprobe(path)
+	}
+	return runFFprobe("pipe:0", src.Reader())
+}
+
+// runFFprobe runs ffprobe on input, reading stdin when it is "pipe:0"
+func runFFprobe(input string, stdin io.Reader) (*FFprobeInfo, error) {
 	// Check if ffprobe is available
 	if _, err := exec.LookPath("ffprobe"); err != nil {
 		return nil, util.ErrNotFound
@@ -89,8 +146,10 @@ func RunFFprobe(path string) (*FFprobeInfo, error) {
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
-		path,
+		"-show_chapters",
+		input,
 	)
+	cmd.Stdin = stdin
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -126,3 +126,61 @@ func TestFFprobeStreamUnmarshalAIFF(t *testing.T) {
 		t.Errorf("Expected bits_per_raw_sample 16, got %d", stream.BitsPerRawSample.Value)
 	}
 }
+
+func TestFFprobeAudioStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		jsonData  string
+		codec     string
+		wantVideo bool
+	}{
+		{
+			name: "music video with video stream first",
+			jsonData: `{"streams": [
+				{"index": 0, "codec_name": "h264", "codec_type": "video"},
+				{"index": 1, "codec_name": "aac", "codec_type": "audio", "sample_rate": "48000", "bit_rate": "256000"}
+			]}`,
+			codec:     "aac",
+			wantVideo: true,
+		},
+		{
+			name: "embedded cover art",
+			jsonData: `{"streams": [
+				{"index": 0, "codec_name": "mjpeg", "codec_type": "video", "disposition": {"attached_pic": 1}},
+				{"index": 1, "codec_name": "flac", "codec_type": "audio", "sample_rate": "44100"}
+			]}`,
+			codec:     "flac",
+			wantVideo: false,
+		},
+		{
+			name:     "DSF",
+			jsonData: `{"streams": [{"index": 0, "codec_name": "dsd_lsbf_planar", "codec_type": "audio", "sample_rate": "352800"}]}`,
+			codec:    "dsd_lsbf_planar",
+		},
+		{
+			name:      "no audio",
+			jsonData:  `{"streams": [{"index": 0, "codec_name": "h264", "codec_type": "video"}]}`,
+			wantVideo: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var info FFprobeInfo
+			if err := json.Unmarshal([]byte(tt.jsonData), &info); err != nil {
+				t.Fatalf("Failed to unmarshal FFprobeInfo: %v", err)
+			}
+
+			codec := ""
+			if stream := info.AudioStream(); stream != nil {
+				codec = stream.CodecName
+			}
+			if codec != tt.codec {
+				t.Errorf("AudioStream() codec = %q, expected %q", codec, tt.codec)
+			}
+			if got := info.HasVideo(); got != tt.wantVideo {
+				t.Errorf("HasVideo() = %v, expected %v", got, tt.wantVideo)
+			}
+		})
+	}
+}
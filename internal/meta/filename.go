@@ -18,6 +18,8 @@ type FilenameMeta struct {
 	Disc      int
 	Year      string
 	Confidence float64 // 0.0-1.0 how confident we are in the parse
+
+	artistFromPath bool // Artist is the grandparent folder, not from the file name
 }
 
 // ParseFilename attempts to extract metadata from a filename
@@ -141,6 +143,7 @@ func (m *FilenameMeta) inferFromPath(dir string) {
 
 		if m.Artist == "" {
 			m.Artist = grandParent
+			m.artistFromPath = true
 		}
 
 		// Try to extract year from album folder
@@ -164,9 +167,10 @@ func (m *FilenameMeta) inferFromPath(dir string) {
 }
 
 // EnrichMetadata enriches store metadata with filename-based hints
-// Only fills in missing fields
+// Only fills in missing fields, recording their provenance
 func EnrichMetadata(meta *store.Metadata, path string) {
 	fileMeta := ParseFilename(path)
+	dir := filepath.Base(filepath.Dir(path))
 
 	// Use different confidence thresholds for different fields
 	// Title is critical - use lower threshold (0.3) if title is empty
@@ -176,6 +180,11 @@ func EnrichMetadata(meta *store.Metadata, path string) {
 	if fileMeta.Confidence >= 0.5 {
 		if meta.TagArtist == "" && fileMeta.Artist != "" {
 			meta.TagArtist = fileMeta.Artist
+			if fileMeta.artistFromPath {
+				SetProvenance(meta, "artist", store.SourceFolder, folderConfidence, filepath.Dir(filepath.Dir(path)))
+			} else {
+				SetProvenance(meta, "artist", store.SourceFilename, fileMeta.Confidence, filepath.Base(path))
+			}
 		}
 	}
 
@@ -190,24 +199,29 @@ func EnrichMetadata(meta *store.Metadata, path string) {
 	if fileMeta.Confidence >= titleConfidenceThreshold {
 		if meta.TagTitle == "" && fileMeta.Title != "" {
 			meta.TagTitle = fileMeta.Title
+			SetProvenance(meta, "title", store.SourceFilename, fileMeta.Confidence, filepath.Base(path))
 		}
 	}
 
 	// Album from path is usually reliable
 	if meta.TagAlbum == "" && fileMeta.Album != "" {
 		meta.TagAlbum = fileMeta.Album
+		SetProvenance(meta, "album", store.SourceFolder, folderConfidence, dir)
 	}
 
 	// Track/Disc numbers from filename are quite reliable
 	if meta.TagTrack == 0 && fileMeta.Track > 0 {
 		meta.TagTrack = fileMeta.Track
+		SetProvenance(meta, "track", store.SourceFilename, max(fileMeta.Confidence, trackNameConfidence), filepath.Base(path))
 	}
 	if meta.TagDisc == 0 && fileMeta.Disc > 0 {
 		meta.TagDisc = fileMeta.Disc
+		SetProvenance(meta, "disc", store.SourceFolder, discFolderConfidence, dir)
 	}
 
 	// Year from path
 	if meta.TagDate == "" && fileMeta.Year != "" {
 		meta.TagDate = fileMeta.Year
+		SetProvenance(meta, "date", store.SourceFolder, folderYearConfidence, dir)
 	}
 }
@@ -0,0 +1,373 @@
+package meta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// dhowden/tag keeps only the last value of a repeated Vorbis comment and ffmpeg's
+// -metadata can only write one, so multi-valued fields in FLAC files are read
+// and written here directly
+
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockVorbisComment = 4
+	flacBlockPicture       = 6
+)
+
+// flacBlock is one metadata block from a FLAC stream header
+type flacBlock struct {
+	blockType byte
+	data      []byte
+}
+
+// readFLACHeader reads everything before the first audio frame: an optional
+// ID3v2 prefix and the metadata blocks
+func readFLACHeader(r io.Reader) (prefix []byte, blocks []flacBlock, err error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, nil, fmt.Errorf("failed to read FLAC magic: %w", err)
+	}
+
+	// Some taggers prepend an ID3v2 tag to FLAC files
+	if bytes.HasPrefix(magic, []byte("ID3")) {
+		header := make([]byte, 10)
+		copy(header, magic)
+		if _, err := io.ReadFull(r, header[4:]); err != nil {
+			return nil, nil, fmt.Errorf("failed to read ID3 header: %w", err)
+		}
+		size := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
+		if header[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		tag := make([]byte, size)
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return nil, nil, fmt.Errorf("failed to read ID3 tag: %w", err)
+		}
+		prefix = append(header, tag...)
+		if _, err := io.ReadFull(r, magic); err != nil {
+			return nil, nil, fmt.Errorf("failed to read FLAC magic: %w", err)
+		}
+	}
+
+	if string(magic) != "fLaC" {
+		return nil, nil, fmt.Errorf("not a FLAC file")
+	}
+
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, nil, fmt.Errorf("failed to read FLAC block header: %w", err)
+		}
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, nil, fmt.Errorf("failed to read FLAC block: %w", err)
+		}
+		blocks = append(blocks, flacBlock{blockType: header[0] & 0x7f, data: data})
+		if header[0]&0x80 != 0 {
+			return prefix, blocks, nil
+		}
+	}
+}
+
+// parseVorbisComments decodes a VORBIS_COMMENT block into its vendor string and
+// comments in file order
+func parseVorbisComments(data []byte) (vendor string, comments []string, err error) {
+	r := bytes.NewReader(data)
+	readString := func() (string, error) {
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return "", err
+		}
+		if int64(length) > int64(r.Len()) {
+			return "", fmt.Errorf("comment length %d exceeds block", length)
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	if vendor, err = readString(); err != nil {
+		return "", nil, fmt.Errorf("failed to read vendor string: %w", err)
+	}
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return "", nil, fmt.Errorf("failed to read comment count: %w", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		comment, err := readString()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+	return vendor, comments, nil
+}
+
+// encodeVorbisComments encodes a VORBIS_COMMENT block
+func encodeVorbisComments(vendor string, comments []string) []byte {
+	var buf bytes.Buffer
+	writeString := func(s string) {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(s)))
+		buf.WriteString(s)
+	}
+	writeString(vendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		writeString(comment)
+	}
+	return buf.Bytes()
+}
+
+// ReadFLACComments returns every Vorbis comment of a FLAC file, keyed by
+// uppercased field name with repeated fields in file order
+func ReadFLACComments(path string) (map[string][]string, error) {
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return readFLACComments(src.Reader())
+}
+
+// readFLACComments reads the Vorbis comments of the FLAC stream in r
+func readFLACComments(r io.Reader) (map[string][]string, error) {
+	_, blocks, err := readFLACHeader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]string)
+	for _, block := range blocks {
+		if block.blockType != flacBlockVorbisComment {
+			continue
+		}
+		_, comments, err := parseVorbisComments(block.data)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			if key, value, ok := strings.Cut(comment, "="); ok && value != "" {
+				key = strings.ToUpper(key)
+				fields[key] = append(fields[key], value)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// SetFLACComments replaces the given Vorbis comment fields of a FLAC file, writing
+// one comment per value; fields with no values are removed
+func SetFLACComments(path string, fields map[string][]string) error {
+	// Vorbis field names are case-insensitive
+	replace := make(map[string][]string, len(fields))
+	for key, values := range fields {
+		replace[strings.ToUpper(key)] = values
+	}
+
+	return rewriteFLACHeader(path, func(blocks []flacBlock) ([]flacBlock, error) {
+		// Rewrite the existing comment block, or add one after STREAMINFO
+		index := -1
+		for i, block := range blocks {
+			if block.blockType == flacBlockVorbisComment {
+				index = i
+				break
+			}
+		}
+		vendor, comments := "music-janitor", []string(nil)
+		if index >= 0 {
+			var err error
+			if vendor, comments, err = parseVorbisComments(blocks[index].data); err != nil {
+				return nil, err
+			}
+		} else {
+			index = 1
+			if len(blocks) == 0 || blocks[0].blockType != flacBlockStreamInfo {
+				index = 0
+			}
+			blocks = append(blocks[:index], append([]flacBlock{{blockType: flacBlockVorbisComment}}, blocks[index:]...)...)
+		}
+
+		var kept []string
+		for _, comment := range comments {
+			key, _, _ := strings.Cut(comment, "=")
+			if _, replaced := replace[strings.ToUpper(key)]; !replaced {
+				kept = append(kept, comment)
+			}
+		}
+		for _, key := range sortedFieldNames(replace) {
+			for _, value := range replace[key] {
+				kept = append(kept, key+"="+value)
+			}
+		}
+		blocks[index].data = encodeVorbisComments(vendor, kept)
+		return blocks, nil
+	})
+}
+
+// rewriteFLACHeader replaces the metadata blocks of a FLAC file with the result of
+// edit, copying the audio frames unchanged
+func rewriteFLACHeader(path string, edit func(blocks []flacBlock) ([]flacBlock, error)) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	r := bufio.NewReader(src)
+	prefix, blocks, err := readFLACHeader(r)
+	if err != nil {
+		return err
+	}
+	if blocks, err = edit(blocks); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if len(block.data) >= 1<<24 {
+			return fmt.Errorf("FLAC metadata block too large")
+		}
+	}
+
+	tempPath := path + ".tagged"
+	dst, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	w := bufio.NewWriter(dst)
+	w.Write(prefix)
+	w.WriteString("fLaC")
+	for i, block := range blocks {
+		header := byte(block.blockType)
+		if i == len(blocks)-1 {
+			header |= 0x80
+		}
+		length := len(block.data)
+		w.Write([]byte{header, byte(length >> 16), byte(length >> 8), byte(length)})
+		w.Write(block.data)
+	}
+	_, err = io.Copy(w, r)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write FLAC file: %w", err)
+	}
+
+	src.Close()
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace FLAC file: %w", err)
+	}
+	return nil
+}
+
+// sortedFieldNames returns field names in a stable order
+func sortedFieldNames(fields map[string][]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flacArtist joins a repeated ARTIST field (or ARTISTS when ARTIST is missing)
+// into one multi-valued artist tag; a single ARTIST value is left to the tag reader
+func flacArtist(fields map[string][]string) string {
+	if artists := fields["ARTIST"]; len(artists) > 1 {
+		return strings.Join(artists, "; ")
+	}
+	if len(fields["ARTIST"]) == 0 && len(fields["ARTISTS"]) > 0 {
+		return strings.Join(fields["ARTISTS"], "; ")
+	}
+	return ""
+}
+
+// flacStreamInfo holds the fields of a STREAMINFO block
+type flacStreamInfo struct {
+	sampleRate    int
+	channels      int
+	bitsPerSample int
+	totalSamples  int64
+	md5           [16]byte
+}
+
+// parseFLACStreamInfo decodes a 34-byte STREAMINFO block
+func parseFLACStreamInfo(b []byte) (flacStreamInfo, error) {
+	var si flacStreamInfo
+	if len(b) < 34 {
+		return si, fmt.Errorf("STREAMINFO too short: %d bytes", len(b))
+	}
+	si.sampleRate = int(b[10])<<12 | int(b[11])<<4 | int(b[12])>>4
+	si.channels = int(b[12]>>1&0x07) + 1
+	si.bitsPerSample = int(b[12]&0x01)<<4 | int(b[13])>>4 + 1
+	si.totalSamples = int64(b[13]&0x0f)<<32 | int64(binary.BigEndian.Uint32(b[14:18]))
+	copy(si.md5[:], b[18:34])
+	return si, nil
+}
+
+// parseFLACProperties reads stream properties from the STREAMINFO block at offset
+func parseFLACProperties(r io.ReaderAt, offset int64) (*AudioProperties, error) {
+	header := make([]byte, 8+34)
+	if err := readFull(r, header, offset); err != nil {
+		return nil, fmt.Errorf("failed to read STREAMINFO: %w", err)
+	}
+	if header[4]&0x7f != flacBlockStreamInfo {
+		return nil, fmt.Errorf("first FLAC block is not STREAMINFO")
+	}
+	si, err := parseFLACStreamInfo(header[8:])
+	if err != nil {
+		return nil, err
+	}
+	if si.totalSamples == 0 {
+		return nil, fmt.Errorf("FLAC total sample count unknown")
+	}
+	return &AudioProperties{
+		Container:  "flac",
+		Codec:      "flac",
+		DurationMs: samplesToMs(si.totalSamples, si.sampleRate),
+		SampleRate: si.sampleRate,
+		BitDepth:   si.bitsPerSample,
+		Channels:   si.channels,
+	}, nil
+}
+
+// ReadFLACSignature returns the MD5 signature of the decoded audio stored in a
+// FLAC file's STREAMINFO, and the sample size it was computed over
+// The signature is all zeros when the encoder did not compute one
+func ReadFLACSignature(path string) (md5 [16]byte, bitsPerSample int, err error) {
+	src, err := util.OpenSourceReader(path)
+	if err != nil {
+		return md5, 0, err
+	}
+	defer src.Close()
+
+	_, blocks, err := readFLACHeader(bufio.NewReader(src))
+	if err != nil {
+		return md5, 0, err
+	}
+	if len(blocks) == 0 || blocks[0].blockType != flacBlockStreamInfo {
+		return md5, 0, fmt.Errorf("first FLAC block is not STREAMINFO")
+	}
+	si, err := parseFLACStreamInfo(blocks[0].data)
+	if err != nil {
+		return md5, 0, err
+	}
+	return si.md5, si.bitsPerSample, nil
+}
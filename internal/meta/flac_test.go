@@ -0,0 +1,122 @@
+package meta
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// writeTestFLAC writes a FLAC header with the given comments followed by fake audio frames
+func writeTestFLAC(t *testing.T, comments []string, audio []byte) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("fLaC")
+	buf.Write([]byte{flacBlockStreamInfo, 0, 0, 34})
+	buf.Write(make([]byte, 34))
+	block := encodeVorbisComments("test", comments)
+	buf.Write([]byte{0x80 | flacBlockVorbisComment, byte(len(block) >> 16), byte(len(block) >> 8), byte(len(block))})
+	buf.Write(block)
+	buf.Write(audio)
+
+	path := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return path
+}
+
+func TestReadFLACComments(t *testing.T) {
+	path := writeTestFLAC(t, []string{"ARTIST=Daft Punk", "artist=Pharrell Williams", "TITLE=Get Lucky"}, []byte("frames"))
+
+	fields, err := ReadFLACComments(path)
+	if err != nil {
+		t.Fatalf("ReadFLACComments failed: %v", err)
+	}
+	if want := []string{"Daft Punk", "Pharrell Williams"}; !reflect.DeepEqual(fields["ARTIST"], want) {
+		t.Errorf("ARTIST = %q, want %q", fields["ARTIST"], want)
+	}
+	if got := flacArtist(fields); got != "Daft Punk; Pharrell Williams" {
+		t.Errorf("flacArtist() = %q", got)
+	}
+	if got := ParseArtistCredits(flacArtist(fields), "").Collaborators; !reflect.DeepEqual(got, []string{"Pharrell Williams"}) {
+		t.Errorf("Collaborators = %q", got)
+	}
+}
+
+func TestSetFLACComments(t *testing.T) {
+	audio := []byte("audio frames must survive")
+	path := writeTestFLAC(t, []string{"ARTIST=A feat. B", "ARTISTS=stale", "TITLE=Song"}, audio)
+
+	if err := SetFLACComments(path, map[string][]string{"artists": {"A", "B"}}); err != nil {
+		t.Fatalf("SetFLACComments failed: %v", err)
+	}
+
+	fields, err := ReadFLACComments(path)
+	if err != nil {
+		t.Fatalf("ReadFLACComments failed: %v", err)
+	}
+	if want := []string{"A", "B"}; !reflect.DeepEqual(fields["ARTISTS"], want) {
+		t.Errorf("ARTISTS = %q, want %q", fields["ARTISTS"], want)
+	}
+	if len(fields["ARTIST"]) != 1 || fields["TITLE"][0] != "Song" {
+		t.Errorf("Other comments should be kept, got %v", fields)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.HasSuffix(data, audio) {
+		t.Error("Audio frames were not preserved")
+	}
+	if _, err := os.Stat(path + ".tagged"); !os.IsNotExist(err) {
+		t.Error("Temporary file was left behind")
+	}
+}
+
+func TestReadFLACCommentsNotFLAC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.flac")
+	if err := os.WriteFile(path, []byte("RIFF0000WAVE"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if _, err := ReadFLACComments(path); err == nil {
+		t.Error("Expected an error for a non-FLAC file")
+	}
+}
+
+func TestReadFLACCommentsFromArchive(t *testing.T) {
+	data, err := os.ReadFile(writeTestFLAC(t, []string{"ARTIST=Daft Punk", "TITLE=Get Lucky"}, []byte("frames")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		archive := filepath.Join(t.TempDir(), "album.zip")
+		f, err := os.Create(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := zip.NewWriter(f)
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: "CD1/01.flac", Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		w.Close()
+		f.Close()
+
+		fields, err := ReadFLACComments(util.ArchiveEntryPath(archive, "CD1/01.flac"))
+		if err != nil {
+			t.Fatalf("method %d: ReadFLACComments failed: %v", method, err)
+		}
+		if fields["TITLE"][0] != "Get Lucky" {
+			t.Errorf("method %d: TITLE = %q", method, fields["TITLE"])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package meta
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+var (
+	// ISRC: 2-letter country, 3-character registrant, 2-digit year, 5-digit designation
+	isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+	// MusicBrainz IDs are lowercase UUIDs
+	mbidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// musicBrainzUFIDOwner is the ID3 UFID owner used by MusicBrainz Picard for recording IDs
+const musicBrainzUFIDOwner = "http://musicbrainz.org"
+
+// NormalizeISRC canonicalizes an ISRC ("us-rc1-76-07839" -> "USRC17607839")
+// Returns "" if the value is not a valid ISRC
+func NormalizeISRC(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.NewReplacer("-", "", " ", "", "ISRC:", "").Replace(s)
+	if !isrcPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// NormalizeMBID canonicalizes a MusicBrainz ID (lowercase UUID)
+// Returns "" if the value is not a valid MBID
+func NormalizeMBID(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if !mbidPattern.MatchString(s) {
+		return ""
+	}
+	return s
+}
+
+// firstValue returns the first value of a multi-valued tag ("A; B" or "A/B" -> "A")
+func firstValue(s string) string {
+	if i := strings.IndexAny(s, ";/\x00"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// identifiersFromRaw extracts the recording MBID and ISRC from dhowden/tag raw frames
+// Handles ID3 TSRC/UFID/TXXX frames, Vorbis comments (lowercased keys) and MP4 freeform atoms
+func identifiersFromRaw(raw map[string]interface{}) (mbid, isrc string) {
+	for _, key := range sortedKeys(raw) {
+		value := raw[key]
+		// Repeated ID3 frames are suffixed: UFID, UFID_0, UFID_1, ...
+		name := key
+		if i := strings.Index(key, "_"); i == 4 || i == 3 {
+			name = key[:i]
+		}
+
+		switch v := value.(type) {
+		case string:
+			switch {
+			case isrc == "" && (name == "TSRC" || name == "TRC" || strings.EqualFold(key, "isrc")):
+				isrc = NormalizeISRC(firstValue(v))
+			case mbid == "" && (strings.EqualFold(key, "musicbrainz_trackid") || strings.EqualFold(key, "MusicBrainz Track Id")):
+				mbid = NormalizeMBID(firstValue(v))
+			}
+		case *tag.UFID:
+			if mbid == "" && (name == "UFID" || name == "UFI") && v.Provider == musicBrainzUFIDOwner {
+				mbid = NormalizeMBID(string(v.Identifier))
+			}
+		case *tag.Comm:
+			if mbid == "" && (name == "TXXX" || name == "TXX") && strings.EqualFold(v.Description, "MusicBrainz Track Id") {
+				mbid = NormalizeMBID(firstValue(v.Text))
+			}
+		}
+	}
+	return mbid, isrc
+}
+
+// identifiersFromFFprobe extracts the recording MBID and ISRC from ffprobe format tags
+func identifiersFromFFprobe(tags map[string]string) (mbid, isrc string) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := tags[key]
+		switch {
+		case isrc == "" && (strings.EqualFold(key, "isrc") || strings.EqualFold(key, "TSRC")):
+			isrc = NormalizeISRC(firstValue(value))
+		case mbid == "" && (strings.EqualFold(key, "musicbrainz_trackid") || strings.EqualFold(key, "MusicBrainz Track Id")):
+			mbid = NormalizeMBID(firstValue(value))
+		}
+	}
+	return mbid, isrc
+}
+
+// sortedKeys returns map keys in a stable order so repeated frames resolve deterministically
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
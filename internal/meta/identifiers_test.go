@@ -0,0 +1,127 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/dhowden/tag"
+)
+
+func TestNormalizeISRC(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"USRC17607839", "USRC17607839"},
+		{"us-rc1-76-07839", "USRC17607839"},
+		{" GBAYE0601498 ", "GBAYE0601498"},
+		{"ISRC:USRC17607839", "USRC17607839"},
+		{"USRC1760783", ""},  // too short
+		{"USRC1760783X", ""}, // designation must be digits
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeISRC(tt.input); got != tt.expected {
+			t.Errorf("NormalizeISRC(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestNormalizeMBID(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"b1a9c0e9-d987-4042-ae91-78d6a3267d69", "b1a9c0e9-d987-4042-ae91-78d6a3267d69"},
+		{" B1A9C0E9-D987-4042-AE91-78D6A3267D69 ", "b1a9c0e9-d987-4042-ae91-78d6a3267d69"},
+		{"b1a9c0e9d9874042ae9178d6a3267d69", ""},
+		{"not-an-id", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeMBID(tt.input); got != tt.expected {
+			t.Errorf("NormalizeMBID(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestIdentifiersFromRaw(t *testing.T) {
+	const mbid = "b1a9c0e9-d987-4042-ae91-78d6a3267d69"
+
+	tests := []struct {
+		name     string
+		raw      map[string]interface{}
+		wantMBID string
+		wantISRC string
+	}{
+		{
+			name: "ID3v2.4 TSRC and UFID",
+			raw: map[string]interface{}{
+				"TSRC":   "USRC17607839",
+				"UFID":   &tag.UFID{Provider: "http://www.cddb.com/id3/taginfo1.html", Identifier: []byte("3CD3N")},
+				"UFID_0": &tag.UFID{Provider: musicBrainzUFIDOwner, Identifier: []byte(mbid)},
+			},
+			wantMBID: mbid,
+			wantISRC: "USRC17607839",
+		},
+		{
+			name: "ID3v2.2 TRC and TXXX track ID",
+			raw: map[string]interface{}{
+				"TRC":   "US-RC1-76-07839",
+				"TXX":   &tag.Comm{Description: "MusicBrainz Track Id", Text: mbid},
+				"TXX_0": &tag.Comm{Description: "MusicBrainz Album Id", Text: "00000000-0000-0000-0000-000000000000"},
+			},
+			wantMBID: mbid,
+			wantISRC: "USRC17607839",
+		},
+		{
+			name: "Vorbis comments",
+			raw: map[string]interface{}{
+				"isrc":                "USRC17607839",
+				"musicbrainz_trackid": mbid,
+			},
+			wantMBID: mbid,
+			wantISRC: "USRC17607839",
+		},
+		{
+			name: "MP4 freeform atoms",
+			raw: map[string]interface{}{
+				"ISRC":                 "USRC17607839;GBAYE0601498",
+				"MusicBrainz Track Id": mbid,
+			},
+			wantMBID: mbid,
+			wantISRC: "USRC17607839",
+		},
+		{
+			name:     "No identifiers",
+			raw:      map[string]interface{}{"TIT2": "Song", "TPE1": "Artist"},
+			wantMBID: "",
+			wantISRC: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMBID, gotISRC := identifiersFromRaw(tt.raw)
+			if gotMBID != tt.wantMBID {
+				t.Errorf("MBID = %q, want %q", gotMBID, tt.wantMBID)
+			}
+			if gotISRC != tt.wantISRC {
+				t.Errorf("ISRC = %q, want %q", gotISRC, tt.wantISRC)
+			}
+		})
+	}
+}
+
+func TestIdentifiersFromFFprobe(t *testing.T) {
+	mbid, isrc := identifiersFromFFprobe(map[string]string{
+		"TSRC":                 "USRC17607839",
+		"MusicBrainz Track Id": "B1A9C0E9-D987-4042-AE91-78D6A3267D69",
+	})
+	if mbid != "b1a9c0e9-d987-4042-ae91-78d6a3267d69" {
+		t.Errorf("MBID = %q", mbid)
+	}
+	if isrc != "USRC17607839" {
+		t.Errorf("ISRC = %q", isrc)
+	}
+}
@@ -0,0 +1,159 @@
+package meta
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MP3 encoding methods
+const (
+	EncodingCBR = "cbr"
+	EncodingABR = "abr"
+	EncodingVBR = "vbr"
+)
+
+// EncoderInfo describes how an MP3 was encoded, from its Xing/Info or VBRI
+// header and the LAME tag that follows it
+type EncoderInfo struct {
+	Header         string `json:"header"`                    // "Xing", "Info" or "VBRI"
+	Encoder        string `json:"encoder,omitempty"`         // e.g. "LAME3.100", "Lavc58.54"
+	Method         string `json:"method,omitempty"`          // cbr, abr or vbr
+	Preset         string `json:"preset,omitempty"`          // e.g. "V0", "extreme", "ABR 192"
+	LowpassHz      int    `json:"lowpass_hz,omitempty"`      // Encoder lowpass filter
+	EncoderDelay   int    `json:"encoder_delay,omitempty"`   // Samples added at the start
+	EncoderPadding int    `json:"encoder_padding,omitempty"` // Samples added at the end
+}
+
+// LAME --preset names by preset code (lame.h preset_mode_e)
+var lamePresetNames = map[int]string{
+	1000: "r3mix",
+	1001: "standard",
+	1002: "extreme",
+	1003: "insane",
+	1004: "fast standard",
+	1005: "fast extreme",
+	1006: "medium",
+	1007: "fast medium",
+}
+
+// VBR level of the named presets: standard is -V 2, extreme -V 0, medium -V 4
+var lamePresetLevels = map[string]int{
+	"standard": 2, "fast standard": 2,
+	"extreme": 0, "fast extreme": 0,
+	"medium": 4, "fast medium": 4,
+}
+
+// parseLAMETag reads the 36-byte LAME tag that follows a Xing/Info header
+// quality is the Xing quality indicator, or -1 if the header has none
+func (e *EncoderInfo) parseLAMETag(tag []byte, quality int) {
+	if len(tag) < 9 {
+		return
+	}
+	version := strings.TrimRight(string(tag[:9]), "\x00 ")
+	if !isPrintableASCII(version) {
+		return
+	}
+	e.Encoder = version
+
+	// Only LAME and ffmpeg (which writes a LAME-compatible tag) fill in the rest
+	if len(tag) < 36 || !(strings.HasPrefix(version, "LAME") || strings.HasPrefix(version, "L3.99") ||
+		strings.HasPrefix(version, "Lavc") || strings.HasPrefix(version, "Lavf")) {
+		return
+	}
+
+	switch tag[9] & 0x0f {
+	case 1, 8:
+		e.Method = EncodingCBR
+	case 2, 9:
+		e.Method = EncodingABR
+	case 3, 4, 5, 6:
+		e.Method = EncodingVBR
+	}
+	e.LowpassHz = int(tag[10]) * 100
+	e.EncoderDelay = int(tag[21])<<4 | int(tag[22])>>4
+	e.EncoderPadding = int(tag[22]&0x0f)<<8 | int(tag[23])
+
+	preset := int(binary.BigEndian.Uint16(tag[26:]) & 0x07ff)
+	switch {
+	case preset >= 410 && preset <= 500 && preset%10 == 0:
+		e.Preset = fmt.Sprintf("V%d", (500-preset)/10)
+	case lamePresetNames[preset] != "":
+		e.Preset = lamePresetNames[preset]
+	case preset >= 8 && preset <= 320:
+		e.Preset = fmt.Sprintf("%s %d", strings.ToUpper(e.Method), preset)
+	case e.Method == EncodingVBR && quality > 0 && quality <= 100 && strings.HasPrefix(version, "LAME"):
+		// LAME writes 100 - 10*V - q as the Xing quality indicator
+		e.Preset = fmt.Sprintf("V%d", (100-quality)/10)
+	}
+}
+
+// VBRLevel returns the LAME -V level of the encoding (0 best, 9 worst), or -1
+// if the file was not encoded with a VBR preset
+func (e *EncoderInfo) VBRLevel() int {
+	if level, ok := lamePresetLevels[e.Preset]; ok {
+		return level
+	}
+	var level int
+	if _, err := fmt.Sscanf(e.Preset, "V%d", &level); err == nil && level >= 0 && level <= 9 {
+		return level
+	}
+	return -1
+}
+
+// String returns a short human-readable description, e.g. "LAME3.100 V0 (vbr, lowpass 19.5 kHz)"
+func (e *EncoderInfo) String() string {
+	name := e.Encoder
+	if name == "" {
+		name = e.Header + " header"
+	}
+	if e.Preset != "" {
+		name += " " + e.Preset
+	}
+	var details []string
+	if e.Method != "" {
+		details = append(details, e.Method)
+	}
+	if e.LowpassHz > 0 {
+		details = append(details, fmt.Sprintf("lowpass %.1f kHz", float64(e.LowpassHz)/1000))
+	}
+	if len(details) > 0 {
+		name += " (" + strings.Join(details, ", ") + ")"
+	}
+	return name
+}
+
+// JSON encodes the info for the metadata encoder_json column
+func (e *EncoderInfo) JSON() string {
+	if e == nil {
+		return ""
+	}
+	data, _ := json.Marshal(e)
+	return string(data)
+}
+
+// ParseEncoderJSON decodes an encoder_json column, returning nil if empty or invalid
+func ParseEncoderJSON(s string) *EncoderInfo {
+	if s == "" {
+		return nil
+	}
+	var e EncoderInfo
+	if err := json.Unmarshal([]byte(s), &e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+// isPrintableASCII reports whether s is non-empty printable ASCII
+func isPrintableASCII(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
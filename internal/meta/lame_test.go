@@ -0,0 +1,115 @@
+package meta
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// xingFrame returns a 128 kbps MPEG-1 layer III frame holding a Xing/Info
+// header with a quality indicator, followed by lameTag
+func xingFrame(header string, quality uint32, lameTag []byte) []byte {
+	frame := mpegFrameBytes(9)
+	copy(frame[36:], header)
+	binary.BigEndian.PutUint32(frame[40:], 0x0b) // frames, bytes, quality
+	binary.BigEndian.PutUint32(frame[44:], 1000)
+	binary.BigEndian.PutUint32(frame[48:], 300000)
+	binary.BigEndian.PutUint32(frame[52:], quality)
+	copy(frame[56:], lameTag)
+	return frame
+}
+
+// lameTag builds a LAME tag with the given VBR method, lowpass (in 100 Hz),
+// encoder delay/padding and preset code
+func lameTag(version string, method, lowpass byte, delay, padding int, preset uint16) []byte {
+	tag := make([]byte, 36)
+	copy(tag, version)
+	tag[9] = 0x10 | method
+	tag[10] = lowpass
+	tag[21] = byte(delay >> 4)
+	tag[22] = byte(delay<<4) | byte(padding>>8)
+	tag[23] = byte(padding)
+	binary.BigEndian.PutUint16(tag[26:], preset)
+	return tag
+}
+
+func TestReadMP3EncoderInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  *EncoderInfo
+	}{
+		{
+			name:  "LAME V0 preset",
+			frame: xingFrame("Xing", 97, lameTag("LAME3.100", 4, 195, 576, 1124, 500)),
+			want: &EncoderInfo{Header: "Xing", Encoder: "LAME3.100", Method: EncodingVBR, Preset: "V0",
+				LowpassHz: 19500, EncoderDelay: 576, EncoderPadding: 1124},
+		},
+		{
+			name:  "LAME V2 from quality indicator",
+			frame: xingFrame("Xing", 77, lameTag("LAME3.99r", 4, 186, 576, 300, 0)),
+			want: &EncoderInfo{Header: "Xing", Encoder: "LAME3.99r", Method: EncodingVBR, Preset: "V2",
+				LowpassHz: 18600, EncoderDelay: 576, EncoderPadding: 300},
+		},
+		{
+			name:  "LAME preset extreme",
+			frame: xingFrame("Xing", 78, lameTag("LAME3.97 ", 3, 190, 576, 0, 1002)),
+			want: &EncoderInfo{Header: "Xing", Encoder: "LAME3.97", Method: EncodingVBR, Preset: "extreme",
+				LowpassHz: 19000, EncoderDelay: 576},
+		},
+		{
+			name:  "LAME CBR 320",
+			frame: xingFrame("Info", 0, lameTag("LAME3.100", 1, 205, 576, 1500, 320)),
+			want: &EncoderInfo{Header: "Info", Encoder: "LAME3.100", Method: EncodingCBR, Preset: "CBR 320",
+				LowpassHz: 20500, EncoderDelay: 576, EncoderPadding: 1500},
+		},
+		{
+			name:  "Xing header without LAME tag",
+			frame: xingFrame("Xing", 0, nil),
+			want:  &EncoderInfo{Header: "Xing", Method: EncodingVBR},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append([]byte{}, tt.frame...)
+			for i := 0; i < 10; i++ {
+				data = append(data, mpegFrameBytes(9)...)
+			}
+			props, err := ReadAudioProperties(writeTestAudio(t, "test.mp3", data))
+			if err != nil {
+				t.Fatalf("ReadAudioProperties failed: %v", err)
+			}
+			if !reflect.DeepEqual(props.Encoder, tt.want) {
+				t.Errorf("Encoder = %+v, want %+v", props.Encoder, tt.want)
+			}
+			if got := ParseEncoderJSON(props.Metadata().EncoderJSON); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderInfoVBRLevel(t *testing.T) {
+	tests := []struct {
+		preset string
+		want   int
+	}{
+		{"V0", 0},
+		{"V2", 2},
+		{"V9", 9},
+		{"extreme", 0},
+		{"fast standard", 2},
+		{"medium", 4},
+		{"insane", -1},
+		{"CBR 320", -1},
+		{"ABR 192", -1},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		e := &EncoderInfo{Preset: tt.preset}
+		if got := e.VBRLevel(); got != tt.want {
+			t.Errorf("VBRLevel(%q) = %d, want %d", tt.preset, got, tt.want)
+		}
+	}
+}
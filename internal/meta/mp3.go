@@ -0,0 +1,314 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MPEG audio frame headers, from ISO 11172-3 and ISO 13818-3
+var (
+	mpegBitrates = [2][3][15]int{
+		{ // MPEG-1: layer I, II, III
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+		{ // MPEG-2 and 2.5: layer I, II, III
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+	}
+	mpegSampleRates = map[int][3]int{
+		10: {44100, 48000, 32000}, // MPEG-1
+		20: {22050, 24000, 16000}, // MPEG-2
+		25: {11025, 12000, 8000},  // MPEG-2.5
+	}
+)
+
+const (
+	mp3ScanWindow   = 128 * 1024 // How far past the ID3 tag to look for the first frame
+	mp3SampleFrames = 64         // Frames checked for a constant bitrate
+)
+
+// mpegFrame is a decoded MPEG audio frame header
+type mpegFrame struct {
+	version    int // 10 = MPEG-1, 20 = MPEG-2, 25 = MPEG-2.5
+	layer      int
+	bitrate    int // kbps
+	sampleRate int
+	padding    bool
+	mono       bool
+}
+
+// parseMPEGFrame decodes a 4-byte frame header
+func parseMPEGFrame(b []byte) (mpegFrame, bool) {
+	var h mpegFrame
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return h, false
+	}
+	switch b[1] >> 3 & 0x03 {
+	case 0:
+		h.version = 25
+	case 2:
+		h.version = 20
+	case 3:
+		h.version = 10
+	default:
+		return h, false
+	}
+	layerBits := int(b[1] >> 1 & 0x03)
+	if layerBits == 0 {
+		return h, false
+	}
+	h.layer = 4 - layerBits
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2] >> 2 & 0x03)
+	if bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return h, false // free-format streams are left to ffprobe
+	}
+	table := 0
+	if h.version != 10 {
+		table = 1
+	}
+	h.bitrate = mpegBitrates[table][h.layer-1][bitrateIndex]
+	h.sampleRate = mpegSampleRates[h.version][rateIndex]
+	h.padding = b[2]&0x02 != 0
+	h.mono = b[3]>>6 == 3
+	return h, true
+}
+
+// samples returns the number of samples per frame
+func (h mpegFrame) samples() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != 10:
+		return 576
+	}
+	return 1152
+}
+
+// length returns the frame length in bytes, including the header
+func (h mpegFrame) length() int {
+	pad := 0
+	if h.padding {
+		pad = 1
+	}
+	if h.layer == 1 {
+		return (12*h.bitrate*1000/h.sampleRate + pad) * 4
+	}
+	return h.samples()/8*h.bitrate*1000/h.sampleRate + pad
+}
+
+// sideInfoSize returns the size of the layer III side information after the header
+func (h mpegFrame) sideInfoSize() int {
+	switch {
+	case h.version == 10 && !h.mono:
+		return 32
+	case h.version == 10, !h.mono:
+		return 17
+	}
+	return 9
+}
+
+// mp3Stream describes an MP3 stream located by parseMP3Stream
+type mp3Stream struct {
+	first   mpegFrame
+	offset  int64        // Offset of the first frame
+	frames  int64        // Audio frames from a Xing or VBRI header; 0 if unknown
+	bytes   int64        // Audio bytes from a Xing or VBRI header; 0 if unknown
+	vbr     bool         // Xing (not Info) or VBRI header present
+	encoder *EncoderInfo // Xing/Info or VBRI header and LAME tag; nil if none
+}
+
+// parseMP3Properties reads stream properties from MPEG audio frame headers,
+// using a Xing/Info or VBRI header when present and scanning frames otherwise
+func parseMP3Properties(r io.ReaderAt, offset, size int64) (*AudioProperties, error) {
+	s, err := parseMP3Stream(r, offset, size)
+	if err != nil {
+		return nil, err
+	}
+
+	props := &AudioProperties{
+		Container:  "mp3",
+		Codec:      [4]string{"", "mp1", "mp2", "mp3"}[s.first.layer],
+		SampleRate: s.first.sampleRate,
+		Channels:   2,
+		Encoder:    s.encoder,
+	}
+	if s.first.mono {
+		props.Channels = 1
+	}
+
+	audioEnd := size
+	if hasID3v1(r, size) {
+		audioEnd -= 128
+	}
+
+	spf := int64(s.first.samples())
+	switch {
+	case s.frames > 0:
+		props.DurationMs = samplesToMs(s.frames*spf, s.first.sampleRate)
+		if s.vbr && props.DurationMs > 0 {
+			audioBytes := s.bytes
+			if audioBytes == 0 {
+				audioBytes = audioEnd - s.offset
+			}
+			props.BitrateKbps = int(audioBytes * 8 / int64(props.DurationMs))
+		} else {
+			props.BitrateKbps = s.first.bitrate
+		}
+	default:
+		frames, audioBytes, constant, err := scanMPEGFrames(r, s.offset, audioEnd, mp3SampleFrames)
+		if err != nil {
+			return nil, err
+		}
+		if !constant {
+			// VBR without a header: count every frame, as ffprobe does
+			if frames, audioBytes, _, err = scanMPEGFrames(r, s.offset, audioEnd, 0); err != nil {
+				return nil, err
+			}
+			props.DurationMs = samplesToMs(frames*spf, s.first.sampleRate)
+			if props.DurationMs > 0 {
+				props.BitrateKbps = int(audioBytes * 8 / int64(props.DurationMs))
+			}
+		} else {
+			// CBR: duration follows from the stream size
+			props.BitrateKbps = s.first.bitrate
+			props.DurationMs = int((audioEnd - s.offset) * 8 / int64(s.first.bitrate))
+		}
+	}
+	return props, nil
+}
+
+// parseMP3Stream finds the first MPEG audio frame at or after offset and reads
+// its Xing/Info or VBRI header
+func parseMP3Stream(r io.ReaderAt, offset, size int64) (*mp3Stream, error) {
+	window := int64(mp3ScanWindow)
+	if offset+window > size {
+		window = size - offset
+	}
+	if window < 4 {
+		return nil, errUnsupportedAudio
+	}
+	buf := make([]byte, window)
+	if err := readFull(r, buf, offset); err != nil {
+		return nil, fmt.Errorf("failed to read MPEG frames: %w", err)
+	}
+
+	for i := 0; i+4 <= len(buf); i++ {
+		h, ok := parseMPEGFrame(buf[i:])
+		if !ok {
+			continue
+		}
+		// Require the next frame to follow, so stray sync bytes are skipped
+		next := i + h.length()
+		if next+4 <= len(buf) {
+			nh, ok := parseMPEGFrame(buf[next:])
+			if !ok || nh.version != h.version || nh.layer != h.layer || nh.sampleRate != h.sampleRate {
+				continue
+			}
+		} else if offset+int64(next) < size {
+			continue
+		}
+
+		s := &mp3Stream{first: h, offset: offset + int64(i)}
+		frame := buf[i:]
+		if len(frame) > h.length() {
+			frame = frame[:h.length()]
+		}
+		if h.layer == 3 {
+			s.readVBRHeader(frame)
+		}
+		return s, nil
+	}
+	return nil, errUnsupportedAudio
+}
+
+// readVBRHeader reads a Xing/Info or VBRI header and any LAME tag from the first frame
+func (s *mp3Stream) readVBRHeader(frame []byte) {
+	pos := 4 + s.first.sideInfoSize()
+	if pos+8 <= len(frame) {
+		if tag := string(frame[pos : pos+4]); tag == "Xing" || tag == "Info" {
+			s.vbr = tag == "Xing"
+			s.encoder = &EncoderInfo{Header: tag, Method: EncodingCBR}
+			if s.vbr {
+				s.encoder.Method = EncodingVBR
+			}
+			flags := binary.BigEndian.Uint32(frame[pos+4:])
+			p := pos + 8
+			if flags&0x01 != 0 && p+4 <= len(frame) {
+				s.frames = int64(binary.BigEndian.Uint32(frame[p:]))
+				p += 4
+			}
+			if flags&0x02 != 0 && p+4 <= len(frame) {
+				s.bytes = int64(binary.BigEndian.Uint32(frame[p:]))
+				p += 4
+			}
+			if flags&0x04 != 0 {
+				p += 100 // Seek table
+			}
+			quality := -1
+			if flags&0x08 != 0 && p+4 <= len(frame) {
+				quality = int(binary.BigEndian.Uint32(frame[p:]))
+				p += 4
+			}
+			if p < len(frame) {
+				s.encoder.parseLAMETag(frame[p:], quality)
+			}
+			return
+		}
+	}
+
+	// VBRI (Fraunhofer encoders) always sits 32 bytes after the header
+	if len(frame) >= 36+18 && bytes.Equal(frame[36:40], []byte("VBRI")) {
+		s.vbr = true
+		s.encoder = &EncoderInfo{Header: "VBRI", Method: EncodingVBR}
+		s.bytes = int64(binary.BigEndian.Uint32(frame[46:]))
+		s.frames = int64(binary.BigEndian.Uint32(frame[50:]))
+	}
+}
+
+// scanMPEGFrames walks frame headers from offset until end, sync loss, or limit
+// frames (0 for no limit), reporting whether every bitrate matched the first
+func scanMPEGFrames(r io.ReaderAt, offset, end int64, limit int) (frames, audioBytes int64, constant bool, err error) {
+	constant = true
+	header := make([]byte, 4)
+	firstBitrate := 0
+	for pos := offset; pos+4 <= end; {
+		if err := readFull(r, header, pos); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to read MPEG frame: %w", err)
+		}
+		h, ok := parseMPEGFrame(header)
+		if !ok {
+			break
+		}
+		if firstBitrate == 0 {
+			firstBitrate = h.bitrate
+		} else if h.bitrate != firstBitrate {
+			constant = false
+		}
+		frames++
+		audioBytes += int64(h.length())
+		pos += int64(h.length())
+		if limit > 0 && frames >= int64(limit) {
+			break
+		}
+	}
+	if frames == 0 {
+		return 0, 0, false, errUnsupportedAudio
+	}
+	return frames, audioBytes, constant, nil
+}
+
+// hasID3v1 reports whether the file ends with a 128-byte ID3v1 tag
+func hasID3v1(r io.ReaderAt, size int64) bool {
+	if size < 128 {
+		return false
+	}
+	tag := make([]byte, 3)
+	return readFull(r, tag, size-128) == nil && string(tag) == "TAG"
+}
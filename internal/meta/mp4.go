@@ -0,0 +1,388 @@
+package meta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const mp4MaxMoovSize = 64 << 20 // Larger moov boxes are left to ffprobe
+
+// MPEG-4 audio sampling frequencies by index (ISO 14496-3)
+var aacSampleRates = [13]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseMP4Properties reads stream properties from the moov box of an MP4/M4A file
+func parseMP4Properties(r io.ReaderAt, size int64) (*AudioProperties, error) {
+	moov, err := readMP4Moov(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	props := &AudioProperties{Container: "mov,mp4,m4a,3gp,3g2,mj2"}
+	var movieDuration int64
+	found := false
+	forEachMP4Box(moov, func(typ string, body []byte) bool {
+		switch typ {
+		case "mvhd":
+			movieDuration = mp4HeaderDuration(body)
+		case "trak":
+			if isMP4VideoTrack(body) {
+				props.HasVideo = true
+			} else if !found {
+				found = parseMP4Track(body, props)
+			}
+		}
+		return true
+	})
+	if !found {
+		return nil, fmt.Errorf("no supported audio track in MP4 file")
+	}
+	// The movie header covers every track; prefer it like ffprobe's format duration
+	if movieDuration > 0 {
+		props.DurationMs = int(movieDuration)
+	}
+	return props, nil
+}
+
+// readMP4Moov returns the body of the top-level moov box
+func readMP4Moov(r io.ReaderAt, size int64) ([]byte, error) {
+	header := make([]byte, 16)
+	for pos := int64(0); pos+8 <= size; {
+		if err := readFull(r, header[:8], pos); err != nil {
+			return nil, fmt.Errorf("failed to read MP4 box: %w", err)
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - pos
+		case 1:
+			if err := readFull(r, header[8:16], pos+8); err != nil {
+				return nil, fmt.Errorf("failed to read MP4 box: %w", err)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+			headerLen = 16
+		}
+		if boxSize < headerLen {
+			return nil, fmt.Errorf("invalid MP4 box size %d", boxSize)
+		}
+		if typ == "moov" {
+			if boxSize > mp4MaxMoovSize || pos+boxSize > size {
+				return nil, fmt.Errorf("MP4 moov box too large or truncated")
+			}
+			moov := make([]byte, boxSize-headerLen)
+			if err := readFull(r, moov, pos+headerLen); err != nil {
+				return nil, fmt.Errorf("failed to read MP4 moov box: %w", err)
+			}
+			return moov, nil
+		}
+		pos += boxSize
+	}
+	return nil, fmt.Errorf("MP4 file has no moov box")
+}
+
+// forEachMP4Box calls fn for each box in b until fn returns false
+func forEachMP4Box(b []byte, fn func(typ string, body []byte) bool) {
+	for len(b) >= 8 {
+		size := int(binary.BigEndian.Uint32(b))
+		headerLen := 8
+		switch {
+		case size == 0:
+			size = len(b)
+		case size == 1 && len(b) >= 16:
+			size64 := binary.BigEndian.Uint64(b[8:])
+			if size64 > uint64(len(b)) {
+				return
+			}
+			size = int(size64)
+			headerLen = 16
+		}
+		if size < headerLen || size > len(b) {
+			return
+		}
+		if !fn(string(b[4:8]), b[headerLen:size]) {
+			return
+		}
+		b = b[size:]
+	}
+}
+
+// findMP4Box returns the body of the box at path below b
+func findMP4Box(b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var next []byte
+		forEachMP4Box(b, func(t string, body []byte) bool {
+			if t == typ {
+				next = body
+				return false
+			}
+			return true
+		})
+		if next == nil {
+			return nil
+		}
+		b = next
+	}
+	return b
+}
+
+// mp4HeaderDuration returns the duration in milliseconds from an mvhd or mdhd body
+func mp4HeaderDuration(body []byte) int64 {
+	var timescale, duration uint64
+	switch {
+	case len(body) >= 32 && body[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(body[20:]))
+		duration = binary.BigEndian.Uint64(body[24:])
+	case len(body) >= 20 && body[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(body[12:]))
+		duration = uint64(binary.BigEndian.Uint32(body[16:]))
+		if duration == 0xffffffff {
+			return 0
+		}
+	}
+	if timescale == 0 {
+		return 0
+	}
+	return int64(duration * 1000 / timescale)
+}
+
+// isMP4VideoTrack reports whether trak is a video track; cover art is stored
+// in the covr tag, not as a track
+func isMP4VideoTrack(trak []byte) bool {
+	hdlr := findMP4Box(trak, "mdia", "hdlr")
+	return len(hdlr) >= 12 && string(hdlr[8:12]) == "vide"
+}
+
+// parseMP4Track fills props from a sound track, reporting whether it was one
+func parseMP4Track(trak []byte, props *AudioProperties) bool {
+	hdlr := findMP4Box(trak, "mdia", "hdlr")
+	if len(hdlr) < 12 || string(hdlr[8:12]) != "soun" {
+		return false
+	}
+	stsd := findMP4Box(trak, "mdia", "minf", "stbl", "stsd")
+	if len(stsd) < 8+36 {
+		return false
+	}
+
+	// First sample entry: size, format, 6 reserved, data reference index, then
+	// the sound sample description
+	entry := stsd[8:]
+	entrySize := int(binary.BigEndian.Uint32(entry))
+	if entrySize < 36 || entrySize > len(entry) {
+		return false
+	}
+	format := string(entry[4:8])
+	entry = entry[:entrySize]
+	version := binary.BigEndian.Uint16(entry[16:])
+	props.Channels = int(binary.BigEndian.Uint16(entry[24:]))
+	sampleSize := int(binary.BigEndian.Uint16(entry[26:]))
+	props.SampleRate = int(binary.BigEndian.Uint32(entry[32:]) >> 16)
+	children := entry[36:]
+	switch version {
+	case 1:
+		if len(children) < 16 {
+			return false
+		}
+		children = children[16:]
+	case 2:
+		if len(children) < 36 {
+			return false
+		}
+		children = children[36:]
+	}
+
+	switch format {
+	case "mp4a":
+		if !parseMP4ESDS(findMP4Box(children, "esds"), props) {
+			return false
+		}
+	case "alac":
+		alac := findMP4Box(children, "alac")
+		if len(alac) < 28 {
+			return false
+		}
+		props.Codec = "alac"
+		props.BitDepth = int(alac[9])
+		props.Channels = int(alac[13])
+		props.SampleRate = int(binary.BigEndian.Uint32(alac[24:]))
+	case "fLaC":
+		dfla := findMP4Box(children, "dfLa")
+		if len(dfla) < 8+34 {
+			return false
+		}
+		si, err := parseFLACStreamInfo(dfla[8:])
+		if err != nil {
+			return false
+		}
+		props.Codec = "flac"
+		props.SampleRate = si.sampleRate
+		props.Channels = si.channels
+		props.BitDepth = si.bitsPerSample
+	case "Opus":
+		props.Codec = "opus"
+		props.SampleRate = 48000
+	case "ac-3":
+		props.Codec = "ac3"
+	case "ec-3":
+		props.Codec = "eac3"
+	case ".mp3":
+		props.Codec = "mp3"
+	default:
+		return false
+	}
+	if props.Codec != "alac" && props.Codec != "flac" {
+		props.BitDepth = 0
+	} else if props.BitDepth == 0 {
+		props.BitDepth = sampleSize
+	}
+
+	props.DurationMs = int(mp4HeaderDuration(findMP4Box(trak, "mdia", "mdhd")))
+	return true
+}
+
+// parseMP4ESDS reads the codec from an esds box and, for AAC, the sample rate
+// and channels from its AudioSpecificConfig
+func parseMP4ESDS(esds []byte, props *AudioProperties) bool {
+	if len(esds) < 4 {
+		return false
+	}
+	b := esds[4:]
+
+	// ES_Descriptor
+	tag, body, _ := readMP4Descriptor(b)
+	if tag != 0x03 || len(body) < 3 {
+		return false
+	}
+	flags := body[2]
+	b = body[3:]
+	if flags&0x80 != 0 {
+		b = skipBytes(b, 2)
+	}
+	if flags&0x40 != 0 && len(b) > 0 {
+		b = skipBytes(b, 1+int(b[0]))
+	}
+	if flags&0x20 != 0 {
+		b = skipBytes(b, 2)
+	}
+
+	// DecoderConfigDescriptor
+	tag, body, _ = readMP4Descriptor(b)
+	if tag != 0x04 || len(body) < 13 {
+		return false
+	}
+	switch body[0] {
+	case 0x40, 0x66, 0x67, 0x68:
+		props.Codec = "aac"
+	case 0x69, 0x6b:
+		props.Codec = "mp3"
+		return true
+	case 0xa5:
+		props.Codec = "ac3"
+		return true
+	case 0xa6:
+		props.Codec = "eac3"
+		return true
+	case 0xad:
+		props.Codec = "opus"
+		props.SampleRate = 48000
+		return true
+	default:
+		return false
+	}
+
+	// DecoderSpecificInfo: AudioSpecificConfig
+	tag, asc, _ := readMP4Descriptor(body[13:])
+	if tag != 0x05 || len(asc) < 2 {
+		return true // Keep the sample entry's rate and channels
+	}
+	br := &bitReader{data: asc}
+	objectType := br.read(5)
+	if objectType == 31 {
+		objectType = 32 + br.read(6)
+	}
+	rate := aacSampleRate(br)
+	channelConfig := br.read(4)
+	if objectType == 5 || objectType == 29 {
+		// HE-AAC: ffprobe reports the SBR output rate
+		rate = aacSampleRate(br)
+	}
+	if br.err {
+		return true
+	}
+	if rate > 0 {
+		props.SampleRate = rate
+	}
+	switch {
+	case objectType == 29:
+		props.Channels = 2 // Parametric stereo decodes mono to stereo
+	case channelConfig >= 1 && channelConfig <= 6:
+		props.Channels = channelConfig
+	case channelConfig == 7:
+		props.Channels = 8
+	}
+	return true
+}
+
+// readMP4Descriptor reads an MPEG-4 descriptor tag and body with its
+// variable-length size
+func readMP4Descriptor(b []byte) (tag byte, body, rest []byte) {
+	if len(b) < 2 {
+		return 0, nil, nil
+	}
+	tag = b[0]
+	size, i := 0, 1
+	for ; i < len(b) && i <= 4; i++ {
+		size = size<<7 | int(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			i++
+			break
+		}
+	}
+	if i+size > len(b) {
+		return 0, nil, nil
+	}
+	return tag, b[i : i+size], b[i+size:]
+}
+
+// aacSampleRate reads a 4-bit sampling frequency index or explicit 24-bit rate
+func aacSampleRate(br *bitReader) int {
+	index := br.read(4)
+	if index == 15 {
+		return br.read(24)
+	}
+	if index < len(aacSampleRates) {
+		return aacSampleRates[index]
+	}
+	return 0
+}
+
+// skipBytes drops n bytes from b, returning nil if b is shorter
+func skipBytes(b []byte, n int) []byte {
+	if n > len(b) {
+		return nil
+	}
+	return b[n:]
+}
+
+// bitReader reads big-endian bit fields
+type bitReader struct {
+	data []byte
+	pos  int // Bit position
+	err  bool
+}
+
+// read returns the next n bits, setting err if the data runs out
+func (br *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		if br.pos/8 >= len(br.data) {
+			br.err = true
+			return 0
+		}
+		bit := br.data[br.pos/8] >> (7 - br.pos%8) & 1
+		v = v<<1 | int(bit)
+		br.pos++
+	}
+	return v
+}
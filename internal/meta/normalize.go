@@ -9,34 +9,32 @@ import (
 	"golang.org/x/text/unicode/norm"
 )
 
-// MusicBrainzNormalizer interface for MusicBrainz lookups
-// This allows dependency injection and testing without circular imports
+// MusicBrainzNormalizer resolves an artist name to its canonical name
+// Implementations return "" when they do not know the name. This allows
+// dependency injection and testing without circular imports
 type MusicBrainzNormalizer interface {
 	NormalizeArtistName(ctx context.Context, artistName string) (string, error)
 }
 
-var (
-	// GlobalMBNormalizer is the optional MusicBrainz normalizer
-	// Set this when MusicBrainz integration is enabled
-	GlobalMBNormalizer MusicBrainzNormalizer
-)
-
-// NormalizeArtist normalizes an artist name for comparison
-// Uses MusicBrainz if available, falls back to local rules
+// NormalizeArtist normalizes an artist name for comparison using local rules
 func NormalizeArtist(artist string) string {
+	return NormalizeArtistWith(nil, artist)
+}
+
+// NormalizeArtistWith normalizes an artist name for comparison, resolving it
+// to its canonical name with n first (alias map, MusicBrainz) when n is set
+func NormalizeArtistWith(n MusicBrainzNormalizer, artist string) string {
 	if artist == "" {
 		return ""
 	}
 
-	// Try MusicBrainz normalization if available
-	if GlobalMBNormalizer != nil {
-		ctx := context.Background()
-		canonical, err := GlobalMBNormalizer.NormalizeArtistName(ctx, artist)
+	if n != nil {
+		canonical, err := n.NormalizeArtistName(context.Background(), artist)
 		if err == nil && canonical != "" {
-			// MusicBrainz succeeded - use canonical name but still apply local normalization
+			// Use the canonical name but still apply local normalization
 			artist = canonical
 		}
-		// If MusicBrainz fails, fall through to local rules
+		// If the lookup fails, fall through to local rules
 	}
 
 	// Apply local normalization rules
@@ -146,6 +144,33 @@ func CleanString(s string) string {
 	return s
 }
 
+// foldReplacer maps letters that don't decompose into a base letter plus accent
+var foldReplacer = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "Æ", "AE", "œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O", "ł", "l", "Ł", "L", "đ", "d", "Đ", "D",
+	"ı", "i", "þ", "th", "Þ", "TH",
+)
+
+// FoldDiacritics removes accents and folds special letters to ASCII equivalents
+// e.g., "Beyoncé" -> "Beyonce", "Motörhead" -> "Motorhead", "Sigur Rós" -> "Sigur Ros"
+// Used for fuzzy matching only; stored metadata keeps the original spelling
+func FoldDiacritics(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return foldReplacer.Replace(b.String())
+}
+
 // removePunctuation removes common punctuation characters
 func removePunctuation(s string) string {
 	// Remove: . , ! ? ' " : ; - /
@@ -343,6 +368,12 @@ func GetTitleForPath(title, filename string) string {
 // CanonicalizeArtistName applies consistent capitalization rules to artist names
 // This ensures that "&me" and "&ME" both become "&ME" in destination paths
 func CanonicalizeArtistName(artist string) string {
+	return CanonicalizeArtistNameWith(nil, artist)
+}
+
+// CanonicalizeArtistNameWith canonicalizes artist using the canonical-stage
+// rules of rs (nil: the built-in rules)
+func CanonicalizeArtistNameWith(rs *RuleSet, artist string) string {
 	if artist == "" {
 		return ""
 	}
@@ -353,20 +384,8 @@ func CanonicalizeArtistName(artist string) string {
 	// Trim whitespace
 	artist = strings.TrimSpace(artist)
 
-	// Handle special cases where ALL CAPS is the norm
-	allCapsExceptions := map[string]string{
-		"ac/dc":   "AC/DC",
-		"acdc":    "AC/DC",
-		"ac_dc":   "AC/DC",
-		"abba":    "ABBA",
-		"mgmt":    "MGMT",
-		"mstrkrft": "MSTRKRFT",
-		"unkle":   "UNKLE",
-	}
-
-	// Check if it's an all-caps exception
-	lowerArtist := strings.ToLower(artist)
-	if canonical, ok := allCapsExceptions[lowerArtist]; ok {
+	// Names with a fixed spelling, such as AC/DC, come from the canonical rules
+	if canonical, ok := rulesOrDefault(rs).Canonicalize(artist); ok {
 		return canonical
 	}
 
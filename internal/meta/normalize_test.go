@@ -187,6 +187,29 @@ func TestCleanString(t *testing.T) {
 	}
 }
 
+func TestFoldDiacritics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Beyoncé", "Beyonce"},
+		{"Motörhead", "Motorhead"},
+		{"Sigur Rós", "Sigur Ros"},
+		{"Mø", "Mo"},
+		{"Straße", "Strasse"},
+		{"Crème Brûlée", "Creme Brulee"},
+		{"Plain ASCII", "Plain ASCII"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		result := FoldDiacritics(tt.input)
+		if result != tt.expected {
+			t.Errorf("FoldDiacritics(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
 func TestGetArtistForPath(t *testing.T) {
 	tests := []struct {
 		albumArtist string
@@ -218,6 +241,9 @@ func TestIsLosslessCodec(t *testing.T) {
 		{"ape", true},
 		{"wavpack", true},
 		{"pcm", true},
+		{"tak", true},
+		{"dsd_lsbf_planar", true},
+		{"dsd_msbf", true},
 		{"mp3", false},
 		{"aac", false},
 		{"opus", false},
@@ -0,0 +1,92 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const oggTailWindow = 64 * 1024 // Bytes searched from the end for the last page
+
+// parseOggProperties reads stream properties from the identification header on
+// the first Ogg page and the granule position of the stream's last page
+func parseOggProperties(r io.ReaderAt, offset, size int64) (*AudioProperties, error) {
+	header := make([]byte, 27+255)
+	n, err := r.ReadAt(header, offset)
+	if n < 27 {
+		return nil, fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+	header = header[:n]
+	segments := int(header[26])
+	if len(header) < 27+segments {
+		return nil, fmt.Errorf("truncated Ogg page header")
+	}
+	serial := binary.LittleEndian.Uint32(header[14:])
+	bodyLen := 0
+	for _, lacing := range header[27 : 27+segments] {
+		bodyLen += int(lacing)
+	}
+	packet := make([]byte, bodyLen)
+	if err := readFull(r, packet, offset+27+int64(segments)); err != nil {
+		return nil, fmt.Errorf("failed to read Ogg packet: %w", err)
+	}
+
+	props := &AudioProperties{Container: "ogg"}
+	preSkip := int64(0)
+	switch {
+	case len(packet) >= 30 && bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		props.Codec = "vorbis"
+		props.Channels = int(packet[11])
+		props.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+	case len(packet) >= 19 && bytes.HasPrefix(packet, []byte("OpusHead")):
+		props.Codec = "opus"
+		props.Channels = int(packet[9])
+		props.SampleRate = 48000 // Opus always decodes at 48 kHz
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:]))
+	case len(packet) >= 13+4+34 && bytes.HasPrefix(packet, []byte("\x7fFLAC")):
+		si, err := parseFLACStreamInfo(packet[17:])
+		if err != nil {
+			return nil, err
+		}
+		props.Codec = "flac"
+		props.Channels = si.channels
+		props.SampleRate = si.sampleRate
+		props.BitDepth = si.bitsPerSample
+	default:
+		return nil, errUnsupportedAudio
+	}
+
+	granule, err := lastOggGranule(r, size, serial)
+	if err != nil {
+		return nil, err
+	}
+	props.DurationMs = samplesToMs(granule-preSkip, props.SampleRate)
+	return props, nil
+}
+
+// lastOggGranule returns the granule position of the last page of a logical stream
+func lastOggGranule(r io.ReaderAt, size int64, serial uint32) (int64, error) {
+	for window := int64(oggTailWindow); ; window *= 4 {
+		start := size - window
+		if start < 0 {
+			start = 0
+		}
+		buf := make([]byte, size-start)
+		if err := readFull(r, buf, start); err != nil {
+			return 0, fmt.Errorf("failed to read Ogg tail: %w", err)
+		}
+		for i := bytes.LastIndex(buf, []byte("OggS")); i >= 0; i = bytes.LastIndex(buf[:i], []byte("OggS")) {
+			if i+27 > len(buf) || binary.LittleEndian.Uint32(buf[i+14:]) != serial {
+				continue
+			}
+			granule := int64(binary.LittleEndian.Uint64(buf[i+6:]))
+			if granule > 0 {
+				return granule, nil
+			}
+		}
+		if start == 0 {
+			return 0, fmt.Errorf("no Ogg page with a granule position")
+		}
+	}
+}
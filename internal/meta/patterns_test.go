@@ -48,14 +48,10 @@ func TestCleanAlbumNamePatterns(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.description, func(t *testing.T) {
-			result := &PatternCleaningResult{
-				Changed:       false,
-				FieldsCleaned: make([]string, 0),
-				Warnings:      make([]string, 0),
-			}
-			got := cleanAlbumName(tt.input, result)
-			if got != tt.want {
-				t.Errorf("cleanAlbumName(%q) = %q, want %q", tt.input, got, tt.want)
+			m := &store.Metadata{TagAlbum: tt.input}
+			ApplyPatternCleaning(m, "/music/track.mp3")
+			if m.TagAlbum != tt.want {
+				t.Errorf("cleaned album %q = %q, want %q", tt.input, m.TagAlbum, tt.want)
 			}
 		})
 	}
@@ -108,9 +104,9 @@ func TestDetectCompilation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.description, func(t *testing.T) {
-			got := detectCompilation(tt.metadata, tt.srcPath)
-			if got != tt.want {
-				t.Errorf("detectCompilation() = %v, want %v", got, tt.want)
+			ApplyPatternCleaning(tt.metadata, tt.srcPath)
+			if got := tt.metadata.TagCompilation; got != tt.want {
+				t.Errorf("TagCompilation = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -131,9 +127,10 @@ func TestExtractCatalogNumber(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := extractCatalogNumber(tt.input)
-		if got != tt.want {
-			t.Errorf("extractCatalogNumber(%q) = %q, want %q", tt.input, got, tt.want)
+		m := &store.Metadata{TagAlbum: tt.input}
+		ApplyPatternCleaning(m, "/music/track.mp3")
+		if m.TagCatalogNumber != tt.want {
+			t.Errorf("catalog number of %q = %q, want %q", tt.input, m.TagCatalogNumber, tt.want)
 		}
 	}
 }
@@ -154,9 +151,15 @@ func TestIsURLBased(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		got := isURLBased(tt.input)
+		result := ApplyPatternCleaning(&store.Metadata{TagAlbum: tt.input}, "/music/track.mp3")
+		got := false
+		for _, w := range result.Warnings {
+			if strings.HasPrefix(w, "suspicious_album_name:") {
+				got = true
+			}
+		}
 		if got != tt.want {
-			t.Errorf("isURLBased(%q) = %v, want %v", tt.input, got, tt.want)
+			t.Errorf("album %q flagged as URL = %v, want %v", tt.input, got, tt.want)
 		}
 	}
 }
@@ -197,6 +200,7 @@ func TestApplyPatternCleaning(t *testing.T) {
 			srcPath:     "/music/track.mp3",
 			wantChanged: true,
 			// Note: catalog number is extracted before cleaning, so warning should appear
+			wantFields:   []string{"album", "catalog_number"},
 			wantWarnings: []string{"catalog_number:MST027"},
 		},
 		{
@@ -290,17 +294,30 @@ func TestCleanTitleName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.description, func(t *testing.T) {
-			result := &PatternCleaningResult{
-				Warnings: make([]string, 0),
-			}
-			got := cleanTitleName(tt.input, result)
-			if got != tt.want {
-				t.Errorf("cleanTitleName(%q) = %q, want %q", tt.input, got, tt.want)
+			m := &store.Metadata{TagTitle: tt.input}
+			result := ApplyPatternCleaning(m, "/music/track.mp3")
+			if m.TagTitle != tt.want {
+				t.Errorf("cleaned title %q = %q, want %q", tt.input, m.TagTitle, tt.want)
 			}
 			hasWarning := len(result.Warnings) > 0
 			if hasWarning != tt.wantWarning {
-				t.Errorf("cleanTitleName(%q) warning = %v, want %v", tt.input, hasWarning, tt.wantWarning)
+				t.Errorf("title %q warning = %v, want %v", tt.input, hasWarning, tt.wantWarning)
 			}
 		})
 	}
 }
+
+func TestApplyPatternCleaningKeepsCatalogNumber(t *testing.T) {
+	m := &store.Metadata{TagAlbum: "Album [MST027]"}
+	ApplyPatternCleaning(m, "/music/track.mp3")
+	if m.TagCatalogNumber != "MST027" {
+		t.Errorf("TagCatalogNumber = %q, want %q", m.TagCatalogNumber, "MST027")
+	}
+
+	// A catalog number from the tags wins over one parsed from the album name
+	m = &store.Metadata{TagAlbum: "Album [MST027]", TagCatalogNumber: "MST-027"}
+	ApplyPatternCleaning(m, "/music/track.mp3")
+	if m.TagCatalogNumber != "MST-027" {
+		t.Errorf("TagCatalogNumber = %q, want tag value %q", m.TagCatalogNumber, "MST-027")
+	}
+}
@@ -0,0 +1,186 @@
+package meta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAVE format tags
+const (
+	waveFormatPCM        = 0x0001
+	waveFormatIEEEFloat  = 0x0003
+	waveFormatALaw       = 0x0006
+	waveFormatMuLaw      = 0x0007
+	waveFormatExtensible = 0xfffe
+)
+
+// parseWAVProperties reads stream properties from the fmt and data chunks of a WAV file
+func parseWAVProperties(r io.ReaderAt, size int64) (*AudioProperties, error) {
+	var format []byte
+	dataSize := int64(-1)
+	err := walkChunks(r, 12, size, binary.LittleEndian, func(id string, offset, length int64) (bool, error) {
+		switch id {
+		case "fmt ":
+			if length < 16 || length > 1024 {
+				return false, fmt.Errorf("invalid WAV fmt chunk size %d", length)
+			}
+			format = make([]byte, length)
+			if err := readFull(r, format, offset); err != nil {
+				return false, fmt.Errorf("failed to read WAV fmt chunk: %w", err)
+			}
+		case "data":
+			dataSize = length
+			if offset+length > size {
+				dataSize = size - offset // Streamed or truncated files
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if format == nil || dataSize < 0 {
+		return nil, fmt.Errorf("WAV file missing fmt or data chunk")
+	}
+
+	tag := binary.LittleEndian.Uint16(format)
+	channels := int(binary.LittleEndian.Uint16(format[2:]))
+	sampleRate := int(binary.LittleEndian.Uint32(format[4:]))
+	blockAlign := int64(binary.LittleEndian.Uint16(format[12:]))
+	bits := int(binary.LittleEndian.Uint16(format[14:]))
+	if tag == waveFormatExtensible && len(format) >= 26 {
+		tag = binary.LittleEndian.Uint16(format[24:]) // Sub-format GUID starts with the tag
+	}
+
+	codec := ""
+	switch tag {
+	case waveFormatPCM:
+		if bits == 8 {
+			codec = "pcm_u8"
+		} else if bits == 16 || bits == 24 || bits == 32 {
+			codec = fmt.Sprintf("pcm_s%dle", bits)
+		}
+	case waveFormatIEEEFloat:
+		if bits == 32 || bits == 64 {
+			codec = fmt.Sprintf("pcm_f%dle", bits)
+		}
+	case waveFormatALaw:
+		codec = "pcm_alaw"
+	case waveFormatMuLaw:
+		codec = "pcm_mulaw"
+	}
+	if codec == "" || blockAlign == 0 {
+		return nil, errUnsupportedAudio
+	}
+
+	return &AudioProperties{
+		Container:  "wav",
+		Codec:      codec,
+		DurationMs: samplesToMs(dataSize/blockAlign, sampleRate),
+		SampleRate: sampleRate,
+		BitDepth:   bits,
+		Channels:   channels,
+	}, nil
+}
+
+// parseAIFFProperties reads stream properties from the COMM chunk of an AIFF or AIFC file
+func parseAIFFProperties(r io.ReaderAt, size int64) (*AudioProperties, error) {
+	var comm []byte
+	err := walkChunks(r, 12, size, binary.BigEndian, func(id string, offset, length int64) (bool, error) {
+		if id != "COMM" {
+			return true, nil
+		}
+		if length < 18 || length > 1024 {
+			return false, fmt.Errorf("invalid AIFF COMM chunk size %d", length)
+		}
+		comm = make([]byte, length)
+		if err := readFull(r, comm, offset); err != nil {
+			return false, fmt.Errorf("failed to read AIFF COMM chunk: %w", err)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if comm == nil {
+		return nil, fmt.Errorf("AIFF file missing COMM chunk")
+	}
+
+	channels := int(binary.BigEndian.Uint16(comm))
+	frames := int64(binary.BigEndian.Uint32(comm[2:]))
+	bits := int(binary.BigEndian.Uint16(comm[6:]))
+	sampleRate := int(extendedToFloat(comm[8:18]))
+
+	compression := "NONE"
+	if len(comm) >= 22 {
+		compression = string(comm[18:22]) // AIFC only
+	}
+	codec := ""
+	switch compression {
+	case "NONE", "twos":
+		if bits == 8 {
+			codec = "pcm_s8"
+		} else if bits == 16 || bits == 24 || bits == 32 {
+			codec = fmt.Sprintf("pcm_s%dbe", bits)
+		}
+	case "sowt":
+		if bits == 16 || bits == 24 || bits == 32 {
+			codec = fmt.Sprintf("pcm_s%dle", bits)
+		}
+	case "fl32", "FL32":
+		codec, bits = "pcm_f32be", 32
+	case "fl64", "FL64":
+		codec, bits = "pcm_f64be", 64
+	case "alaw", "ALAW":
+		codec, bits = "pcm_alaw", 8
+	case "ulaw", "ULAW":
+		codec, bits = "pcm_mulaw", 8
+	}
+	if codec == "" {
+		return nil, errUnsupportedAudio
+	}
+
+	return &AudioProperties{
+		Container:  "aiff",
+		Codec:      codec,
+		DurationMs: samplesToMs(frames, sampleRate),
+		SampleRate: sampleRate,
+		BitDepth:   bits,
+		Channels:   channels,
+	}, nil
+}
+
+// walkChunks calls fn for each RIFF/IFF chunk from offset with its body offset
+// and length, stopping when fn returns false
+func walkChunks(r io.ReaderAt, offset, size int64, order binary.ByteOrder, fn func(id string, offset, length int64) (bool, error)) error {
+	header := make([]byte, 8)
+	for offset+8 <= size {
+		if err := readFull(r, header, offset); err != nil {
+			return fmt.Errorf("failed to read chunk header: %w", err)
+		}
+		length := int64(order.Uint32(header[4:]))
+		more, err := fn(string(header[:4]), offset+8, length)
+		if err != nil || !more {
+			return err
+		}
+		offset += 8 + length + length&1 // Chunks are padded to even sizes
+	}
+	return nil
+}
+
+// extendedToFloat converts an 80-bit IEEE 754 extended precision number
+func extendedToFloat(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b) & 0x7fff)
+	mantissa := binary.BigEndian.Uint64(b[2:])
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	value := math.Ldexp(float64(mantissa), exponent-16383-63)
+	if b[0]&0x80 != 0 {
+		value = -value
+	}
+	return value
+}
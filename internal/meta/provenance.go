@@ -0,0 +1,139 @@
+package meta
+
+import (
+	"strconv"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// DefaultMinWriteConfidence is the confidence inferred values need to be
+// written back as tags without opting in
+const DefaultMinWriteConfidence = 0.8
+
+// Confidence of values inferred from the folder structure and rewritten by rules
+const (
+	folderConfidence     = 0.5 // Plain folder name used as artist or album
+	folderYearConfidence = 0.7 // "YYYY - Album" folder
+	discFolderConfidence = 0.8 // "CD1", "Disc 2"
+	trackNameConfidence  = 0.7 // Track number from "01 - Title" file names
+	titleNameConfidence  = 0.6 // Title from "01 - Title" file names
+	ruleConfidence       = 0.9 // Rule rewrite of an existing value
+	ruleFillConfidence   = 0.6 // Rule filling an empty field, e.g. from the path
+)
+
+// provenanceFields are the fields whose origin is recorded when they are read
+var provenanceFields = []string{"artist", "album", "album_artist", "title", "date", "track", "disc", "genre"}
+
+// fieldValue returns a metadata field by its rule field name, or "" if unset
+func fieldValue(m *store.Metadata, field string) string {
+	switch field {
+	case "track":
+		if m.TagTrack > 0 {
+			return strconv.Itoa(m.TagTrack)
+		}
+		return ""
+	case "disc":
+		if m.TagDisc > 0 {
+			return strconv.Itoa(m.TagDisc)
+		}
+		return ""
+	}
+	t := ruleTarget{m: m}
+	return t.get(field)
+}
+
+// clearField empties a metadata field by its rule field name
+func clearField(m *store.Metadata, field string) {
+	switch field {
+	case "track":
+		m.TagTrack, m.TagTrackTotal = 0, 0
+	case "disc":
+		m.TagDisc, m.TagDiscTotal = 0, 0
+	case "artist":
+		m.TagArtist, m.Artists = "", nil
+	default:
+		t := ruleTarget{m: m}
+		t.set(field, "")
+	}
+}
+
+// SetProvenance records that the current value of field came from source,
+// replacing any earlier record for the field
+func SetProvenance(m *store.Metadata, field, source string, confidence float64, detail string) {
+	p := store.FieldProvenance{
+		Field:      field,
+		Source:     source,
+		Confidence: confidence,
+		Value:      fieldValue(m, field),
+		Detail:     detail,
+	}
+	for i := range m.Provenance {
+		if m.Provenance[i].Field == field {
+			m.Provenance[i] = p
+			return
+		}
+	}
+	m.Provenance = append(m.Provenance, p)
+}
+
+// GetProvenance returns the provenance record of field, or nil if none
+func GetProvenance(m *store.Metadata, field string) *store.FieldProvenance {
+	for i := range m.Provenance {
+		if m.Provenance[i].Field == field {
+			return &m.Provenance[i]
+		}
+	}
+	return nil
+}
+
+// recordReadProvenance marks the fields set after extraction as read from tags:
+// by the tag library where its value was kept, otherwise by ffprobe
+func recordReadProvenance(m, tagMetadata *store.Metadata) {
+	for _, field := range provenanceFields {
+		value := fieldValue(m, field)
+		if value == "" {
+			continue
+		}
+		source := store.SourceFFprobe
+		if tagMetadata != nil && fieldValue(tagMetadata, field) == value {
+			source = store.SourceTag
+		}
+		SetProvenance(m, field, source, 1.0, "")
+	}
+}
+
+// recordRuleProvenance attributes the fields changed by cleaning rules to the
+// rules. Rewrites keep the confidence of the value they cleaned, so a cleaned
+// guess is still a guess
+func recordRuleProvenance(m *store.Metadata, changes []RuleChange) {
+	for _, c := range changes {
+		confidence := ruleConfidence
+		if c.Before == "" {
+			confidence = ruleFillConfidence
+		} else if prev := GetProvenance(m, c.Field); prev != nil && prev.Confidence < confidence {
+			confidence = prev.Confidence
+		}
+		SetProvenance(m, c.Field, store.SourceRule, confidence, c.Rule)
+	}
+}
+
+// WithoutUncertainFields returns a copy of m with the inferred field values
+// below minConfidence cleared, and the names of the cleared fields, so they are
+// not written back as tags. Values changed since provenance was recorded are kept
+func WithoutUncertainFields(m *store.Metadata, provenance []store.FieldProvenance, minConfidence float64) (*store.Metadata, []string) {
+	var blocked []string
+	for _, p := range provenance {
+		if p.Inferred() && p.Confidence < minConfidence && fieldValue(m, p.Field) == p.Value && p.Value != "" {
+			blocked = append(blocked, p.Field)
+		}
+	}
+	if len(blocked) == 0 {
+		return m, nil
+	}
+
+	out := *m
+	for _, field := range blocked {
+		clearField(&out, field)
+	}
+	return &out, blocked
+}
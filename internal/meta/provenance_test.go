@@ -0,0 +1,99 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestEnrichMetadataProvenance(t *testing.T) {
+	m := &store.Metadata{TagArtist: "Tagged Artist"}
+	m.Provenance = []store.FieldProvenance{{Field: "artist", Source: store.SourceTag, Confidence: 1.0, Value: "Tagged Artist"}}
+
+	EnrichMetadata(m, "/music/Tagged Artist/2001 - Album/03 - Song.mp3")
+
+	tests := []struct {
+		field  string
+		source string
+	}{
+		{"artist", store.SourceTag},
+		{"album", store.SourceFolder},
+		{"date", store.SourceFolder},
+		{"title", store.SourceFilename},
+		{"track", store.SourceFilename},
+	}
+	for _, tt := range tests {
+		p := GetProvenance(m, tt.field)
+		if p == nil {
+			t.Errorf("%s: no provenance recorded", tt.field)
+			continue
+		}
+		if p.Source != tt.source {
+			t.Errorf("%s: source = %q, expected %q", tt.field, p.Source, tt.source)
+		}
+		if p.Value != fieldValue(m, tt.field) {
+			t.Errorf("%s: value = %q, expected %q", tt.field, p.Value, fieldValue(m, tt.field))
+		}
+		if p.Confidence <= 0 || p.Confidence > 1 {
+			t.Errorf("%s: confidence %.2f out of range", tt.field, p.Confidence)
+		}
+	}
+}
+
+func TestRecordRuleProvenance(t *testing.T) {
+	m := &store.Metadata{TagAlbum: "Album", TagTitle: "Song"}
+	SetProvenance(m, "album", store.SourceFolder, 0.5, "Album [FLAC]")
+	SetProvenance(m, "title", store.SourceTag, 1.0, "")
+
+	recordRuleProvenance(m, []RuleChange{
+		{Rule: "format-marker", Field: "album", Before: "Album [FLAC]", After: "Album"},
+		{Rule: "promo-marker", Field: "title", Before: "Song (Promo)", After: "Song"},
+		{Rule: "compilation-path", Field: "genre", Before: "", After: "Soundtrack"},
+	})
+
+	tests := []struct {
+		field      string
+		confidence float64
+	}{
+		{"album", 0.5}, // Cleaned guess stays a guess
+		{"title", ruleConfidence},
+		{"genre", ruleFillConfidence},
+	}
+	for _, tt := range tests {
+		p := GetProvenance(m, tt.field)
+		if p == nil || p.Source != store.SourceRule {
+			t.Fatalf("%s: expected rule provenance, got %+v", tt.field, p)
+		}
+		if p.Confidence != tt.confidence {
+			t.Errorf("%s: confidence = %.2f, expected %.2f", tt.field, p.Confidence, tt.confidence)
+		}
+	}
+}
+
+func TestWithoutUncertainFields(t *testing.T) {
+	m := &store.Metadata{TagArtist: "Artist", TagAlbum: "Folder Album", TagTitle: "Song", TagTrack: 3, TagTrackTotal: 12}
+	provenance := []store.FieldProvenance{
+		{Field: "artist", Source: store.SourceTag, Confidence: 1.0, Value: "Artist"},
+		{Field: "album", Source: store.SourceFolder, Confidence: 0.5, Value: "Folder Album"},
+		{Field: "title", Source: store.SourceFilename, Confidence: 0.5, Value: "Old Guess"}, // Edited since
+		{Field: "track", Source: store.SourceFilename, Confidence: 0.9, Value: "3"},
+	}
+
+	tags, blocked := WithoutUncertainFields(m, provenance, DefaultMinWriteConfidence)
+	if len(blocked) != 1 || blocked[0] != "album" {
+		t.Fatalf("Expected only album to be blocked, got %v", blocked)
+	}
+	if tags.TagAlbum != "" {
+		t.Errorf("Expected album to be cleared, got %q", tags.TagAlbum)
+	}
+	if tags.TagArtist != "Artist" || tags.TagTitle != "Song" || tags.TagTrack != 3 {
+		t.Errorf("Expected other fields to be kept, got %+v", tags)
+	}
+	if m.TagAlbum != "Folder Album" {
+		t.Errorf("Expected original metadata to be unchanged, got album %q", m.TagAlbum)
+	}
+
+	if _, blocked := WithoutUncertainFields(m, provenance, 0.4); len(blocked) != 0 {
+		t.Errorf("Expected nothing blocked at a lower threshold, got %v", blocked)
+	}
+}
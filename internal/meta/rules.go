@@ -0,0 +1,542 @@
+package meta
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+	"go.yaml.in/yaml/v3"
+)
+
+// Rule stages
+const (
+	StageClean     = "clean"     // Run on tags during metadata extraction
+	StageCanonical = "canonical" // Run on artist names used in destination paths
+)
+
+//go:embed rules_default.yaml
+var defaultRulesYAML []byte
+
+// DefaultRulesYAML returns the built-in ruleset as shipped
+func DefaultRulesYAML() []byte {
+	return defaultRulesYAML
+}
+
+// Rule is one user-definable cleaning rule: a regex matched against a field,
+// and what to do when it matches
+type Rule struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Stage       string            `yaml:"stage,omitempty"`
+	Field       string            `yaml:"field"`
+	Match       string            `yaml:"match"`
+	Replace     *string           `yaml:"replace,omitempty"` // nil leaves the field as it is
+	Set         map[string]string `yaml:"set,omitempty"`     // Fields to overwrite
+	Fill        map[string]string `yaml:"fill,omitempty"`    // Fields to set only when empty
+	Warning     string            `yaml:"warning,omitempty"`
+	When        *RuleConditions   `yaml:"when,omitempty"`
+	Disabled    bool              `yaml:"disabled,omitempty"`
+	Tests       []RuleTest        `yaml:"tests,omitempty"`
+
+	re     *regexp.Regexp
+	pathRe *regexp.Regexp
+}
+
+// RuleConditions restricts when a rule runs
+type RuleConditions struct {
+	Empty []string `yaml:"empty,omitempty"` // Fields that must be empty
+	Set   []string `yaml:"set,omitempty"`   // Fields that must not be empty
+	Path  string   `yaml:"path,omitempty"`  // Regex the source path must match
+}
+
+// RuleTest is an example embedded in a rule and checked by `mlc rules test`
+type RuleTest struct {
+	In      string            `yaml:"in,omitempty"`
+	Out     *string           `yaml:"out,omitempty"` // nil expects the field unchanged
+	Path    string            `yaml:"path,omitempty"`
+	Fields  map[string]string `yaml:"fields,omitempty"`
+	Want    map[string]string `yaml:"want,omitempty"`
+	Warning string            `yaml:"warning,omitempty"`
+}
+
+// RuleSet is an ordered list of rules
+type RuleSet struct {
+	Extends string   `yaml:"extends,omitempty"` // "default" merges into the built-in rules
+	Protect []string `yaml:"protect,omitempty"` // Fields the clean rules may not empty
+	Rules   []*Rule  `yaml:"rules"`
+}
+
+// RuleChange records one field changed by a rule
+type RuleChange struct {
+	Rule   string
+	Field  string
+	Before string
+	After  string
+}
+
+// RuleTestFailure is a rule example that did not produce the expected result
+type RuleTestFailure struct {
+	Rule    string
+	Case    int // 1-based position in the rule's tests
+	Message string
+}
+
+func (f RuleTestFailure) String() string {
+	return fmt.Sprintf("%s (test %d): %s", f.Rule, f.Case, f.Message)
+}
+
+// ruleFields lists the fields rules can read; path is read-only
+var ruleFields = []string{
+	"album", "artist", "title", "album_artist", "date", "genre", "composer",
+	"conductor", "label", "catalog_number", "comment", "compilation", "path",
+}
+
+var (
+	defaultRulesOnce sync.Once
+	defaultRules     *RuleSet
+)
+
+// DefaultRules returns the built-in ruleset
+func DefaultRules() *RuleSet {
+	defaultRulesOnce.Do(func() {
+		rs, err := ParseRules(defaultRulesYAML)
+		if err != nil {
+			panic(fmt.Sprintf("invalid built-in rules: %v", err))
+		}
+		defaultRules = rs
+	})
+	return defaultRules
+}
+
+// rulesOrDefault returns rs, or the built-in rules when rs is nil
+func rulesOrDefault(rs *RuleSet) *RuleSet {
+	if rs != nil {
+		return rs
+	}
+	return DefaultRules()
+}
+
+// LoadRules reads a rules file, merging it into the built-in rules when it
+// says `extends: default`
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	rs, err := ParseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// ParseRules parses and validates a ruleset
+func ParseRules(data []byte) (*RuleSet, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var rs RuleSet
+	if err := dec.Decode(&rs); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	switch rs.Extends {
+	case "":
+	case "default":
+		merged, err := mergeRules(DefaultRules(), &rs)
+		if err != nil {
+			return nil, err
+		}
+		rs = *merged
+	default:
+		return nil, fmt.Errorf("unknown ruleset %q in extends (only \"default\")", rs.Extends)
+	}
+
+	if err := rs.compile(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// mergeRules applies custom rules on top of base: a rule with the name of a
+// base rule replaces it (or removes it when disabled), others are appended
+func mergeRules(base, custom *RuleSet) (*RuleSet, error) {
+	merged := &RuleSet{
+		Protect: slices.Clone(base.Protect),
+		Rules:   slices.Clone(base.Rules),
+	}
+	for _, field := range custom.Protect {
+		if !slices.Contains(merged.Protect, field) {
+			merged.Protect = append(merged.Protect, field)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range custom.Rules {
+		if rule == nil {
+			continue
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		i := slices.IndexFunc(merged.Rules, func(r *Rule) bool { return r.Name == rule.Name })
+		switch {
+		case i < 0:
+			merged.Rules = append(merged.Rules, rule)
+		case rule.Disabled:
+			merged.Rules = slices.Delete(merged.Rules, i, i+1)
+		default:
+			merged.Rules[i] = rule
+		}
+	}
+	return merged, nil
+}
+
+// compile validates every rule and compiles its regexes; disabled rules are dropped
+func (rs *RuleSet) compile() error {
+	for _, field := range rs.Protect {
+		if !validRuleField(field) || field == "path" {
+			return fmt.Errorf("protect: unknown field %q", field)
+		}
+	}
+
+	seen := make(map[string]bool)
+	rules := rs.Rules[:0:0]
+	for i, r := range rs.Rules {
+		if r == nil {
+			continue
+		}
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		seen[r.Name] = true
+		if r.Disabled {
+			continue
+		}
+		if err := r.compile(); err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		rules = append(rules, r)
+	}
+	rs.Rules = rules
+	return nil
+}
+
+func (r *Rule) compile() error {
+	if r.Stage == "" {
+		r.Stage = StageClean
+	}
+	if r.Stage != StageClean && r.Stage != StageCanonical {
+		return fmt.Errorf("unknown stage %q (clean, canonical)", r.Stage)
+	}
+	if !validRuleField(r.Field) {
+		return fmt.Errorf("unknown field %q", r.Field)
+	}
+	if r.Match == "" {
+		return fmt.Errorf("missing match")
+	}
+	re, err := regexp.Compile(r.Match)
+	if err != nil {
+		return fmt.Errorf("invalid match: %w", err)
+	}
+	r.re = re
+
+	if r.Replace == nil && len(r.Set) == 0 && len(r.Fill) == 0 && r.Warning == "" {
+		return fmt.Errorf("needs replace, set, fill or warning")
+	}
+	if r.Replace != nil && r.Field == "path" {
+		return fmt.Errorf("path is read-only")
+	}
+	for _, targets := range []map[string]string{r.Set, r.Fill} {
+		for field := range targets {
+			if !validRuleField(field) || field == "path" {
+				return fmt.Errorf("cannot set field %q", field)
+			}
+		}
+	}
+	if r.Stage == StageCanonical && (r.Field != "artist" || r.Replace == nil || len(r.Set) > 0 || len(r.Fill) > 0 || r.When != nil) {
+		return fmt.Errorf("canonical rules can only replace the artist")
+	}
+
+	if r.When != nil {
+		for _, field := range append(slices.Clone(r.When.Empty), r.When.Set...) {
+			if !validRuleField(field) {
+				return fmt.Errorf("when: unknown field %q", field)
+			}
+		}
+		if r.When.Path != "" {
+			if r.pathRe, err = regexp.Compile(r.When.Path); err != nil {
+				return fmt.Errorf("invalid when.path: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func validRuleField(field string) bool {
+	return slices.Contains(ruleFields, field)
+}
+
+// ruleTarget gives rules access to metadata fields by name
+type ruleTarget struct {
+	m    *store.Metadata
+	path string
+}
+
+func (t *ruleTarget) get(field string) string {
+	switch field {
+	case "album":
+		return t.m.TagAlbum
+	case "artist":
+		return t.m.TagArtist
+	case "title":
+		return t.m.TagTitle
+	case "album_artist":
+		return t.m.TagAlbumArtist
+	case "date":
+		return t.m.TagDate
+	case "genre":
+		return t.m.TagGenre
+	case "composer":
+		return t.m.TagComposer
+	case "conductor":
+		return t.m.TagConductor
+	case "label":
+		return t.m.TagLabel
+	case "catalog_number":
+		return t.m.TagCatalogNumber
+	case "comment":
+		return t.m.TagComment
+	case "compilation":
+		if t.m.TagCompilation {
+			return "true"
+		}
+		return "false"
+	case "path":
+		return t.path
+	}
+	return ""
+}
+
+func (t *ruleTarget) set(field, value string) {
+	switch field {
+	case "album":
+		t.m.TagAlbum = value
+	case "artist":
+		t.m.TagArtist = value
+	case "title":
+		t.m.TagTitle = value
+	case "album_artist":
+		t.m.TagAlbumArtist = value
+	case "date":
+		t.m.TagDate = value
+	case "genre":
+		t.m.TagGenre = value
+	case "composer":
+		t.m.TagComposer = value
+	case "conductor":
+		t.m.TagConductor = value
+	case "label":
+		t.m.TagLabel = value
+	case "catalog_number":
+		t.m.TagCatalogNumber = value
+	case "comment":
+		t.m.TagComment = value
+	case "compilation":
+		t.m.TagCompilation = value == "true" || value == "1" || value == "yes"
+	}
+}
+
+// apply runs the rule against the target, recording changes and warnings
+func (r *Rule) apply(t *ruleTarget, result *PatternCleaningResult) {
+	if !r.conditionsMet(t) {
+		return
+	}
+	value := t.get(r.Field)
+	loc := r.re.FindStringSubmatchIndex(value)
+	if loc == nil {
+		return
+	}
+	expand := func(template string) string {
+		return string(r.re.ExpandString(nil, template, value, loc))
+	}
+
+	if r.Warning != "" {
+		result.Warnings = append(result.Warnings, expand(r.Warning))
+	}
+	if r.Replace != nil {
+		r.change(t, result, r.Field, r.re.ReplaceAllString(value, *r.Replace))
+	}
+	for _, field := range slices.Sorted(maps.Keys(r.Fill)) {
+		if t.get(field) == "" {
+			r.change(t, result, field, expand(r.Fill[field]))
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(r.Set)) {
+		r.change(t, result, field, expand(r.Set[field]))
+	}
+}
+
+func (r *Rule) change(t *ruleTarget, result *PatternCleaningResult, field, value string) {
+	before := t.get(field)
+	t.set(field, value)
+	if after := t.get(field); after != before {
+		result.Changes = append(result.Changes, RuleChange{Rule: r.Name, Field: field, Before: before, After: after})
+	}
+}
+
+func (r *Rule) conditionsMet(t *ruleTarget) bool {
+	if r.When == nil {
+		return true
+	}
+	for _, field := range r.When.Empty {
+		if v := t.get(field); v != "" && v != "false" {
+			return false
+		}
+	}
+	for _, field := range r.When.Set {
+		if v := t.get(field); v == "" || v == "false" {
+			return false
+		}
+	}
+	return r.pathRe == nil || r.pathRe.MatchString(t.path)
+}
+
+// Fingerprint identifies the rules; nil has the fingerprint of the built-in rules
+func (rs *RuleSet) Fingerprint() string {
+	data, err := yaml.Marshal(rulesOrDefault(rs))
+	if err != nil {
+		return ""
+	}
+	return util.SettingsFingerprint(string(data))
+}
+
+// Clean runs the clean-stage rules on metadata from srcPath
+func (rs *RuleSet) Clean(metadata *store.Metadata, srcPath string) *PatternCleaningResult {
+	result := &PatternCleaningResult{
+		Changed:       false,
+		FieldsCleaned: make([]string, 0),
+		Warnings:      make([]string, 0),
+	}
+	t := &ruleTarget{m: metadata, path: srcPath}
+
+	original := make(map[string]string, len(ruleFields))
+	for _, field := range ruleFields {
+		original[field] = t.get(field)
+	}
+
+	for _, r := range rs.Rules {
+		if r.Stage == StageClean {
+			r.apply(t, result)
+		}
+	}
+
+	// Keep protected fields rather than leaving them empty
+	for _, field := range rs.Protect {
+		if t.get(field) == "" && original[field] != "" {
+			t.set(field, original[field])
+			result.Warnings = append(result.Warnings, "suspicious_"+field+"_name:"+original[field])
+			result.Changes = slices.DeleteFunc(result.Changes, func(c RuleChange) bool { return c.Field == field })
+		}
+	}
+
+	for _, c := range result.Changes {
+		name := c.Field
+		if name == "compilation" {
+			name = "compilation_flag"
+		}
+		if t.get(c.Field) != original[c.Field] && !slices.Contains(result.FieldsCleaned, name) {
+			result.FieldsCleaned = append(result.FieldsCleaned, name)
+		}
+	}
+	result.Changed = len(result.FieldsCleaned) > 0
+	return result
+}
+
+// Canonicalize runs the canonical-stage rules on an artist name; the first
+// rule that matches decides the name
+func (rs *RuleSet) Canonicalize(artist string) (string, bool) {
+	for _, r := range rs.Rules {
+		if r.Stage != StageCanonical {
+			continue
+		}
+		if r.re.MatchString(artist) {
+			return r.re.ReplaceAllString(artist, *r.Replace), true
+		}
+	}
+	return artist, false
+}
+
+// RunTests checks every rule against its embedded examples
+// Returns the number of examples run and the failures
+func (rs *RuleSet) RunTests() (int, []RuleTestFailure) {
+	var failures []RuleTestFailure
+	count := 0
+	for _, r := range rs.Rules {
+		for i, tc := range r.Tests {
+			count++
+			if msg := r.runTest(tc); msg != "" {
+				failures = append(failures, RuleTestFailure{Rule: r.Name, Case: i + 1, Message: msg})
+			}
+		}
+	}
+	return count, failures
+}
+
+// runTest runs one example through the rule alone
+// Returns a description of the mismatch, or "" when it passes
+func (r *Rule) runTest(tc RuleTest) string {
+	if r.Stage == StageCanonical {
+		got, _ := (&RuleSet{Rules: []*Rule{r}}).Canonicalize(tc.In)
+		want := tc.In
+		if tc.Out != nil {
+			want = *tc.Out
+		}
+		if got != want {
+			return fmt.Sprintf("%q became %q, want %q", tc.In, got, want)
+		}
+		return ""
+	}
+
+	t := &ruleTarget{m: &store.Metadata{}, path: tc.Path}
+	for _, field := range slices.Sorted(maps.Keys(tc.Fields)) {
+		t.set(field, tc.Fields[field])
+	}
+	if r.Field != "path" {
+		t.set(r.Field, tc.In)
+	}
+	result := &PatternCleaningResult{}
+	r.apply(t, result)
+
+	var problems []string
+	if r.Field != "path" {
+		want := tc.In
+		if tc.Out != nil {
+			want = *tc.Out
+		}
+		if got := t.get(r.Field); got != want {
+			problems = append(problems, fmt.Sprintf("%s %q became %q, want %q", r.Field, tc.In, got, want))
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(tc.Want)) {
+		if got := t.get(field); got != tc.Want[field] {
+			problems = append(problems, fmt.Sprintf("%s = %q, want %q", field, got, tc.Want[field]))
+		}
+	}
+	if tc.Warning != "" && !slices.Contains(result.Warnings, tc.Warning) {
+		problems = append(problems, fmt.Sprintf("warnings %q, want %q", result.Warnings, tc.Warning))
+	}
+	return strings.Join(problems, "; ")
+}
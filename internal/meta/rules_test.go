@@ -0,0 +1,179 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestDefaultRulesExamples(t *testing.T) {
+	count, failures := DefaultRules().RunTests()
+	if count == 0 {
+		t.Fatal("built-in rules have no examples")
+	}
+	for _, f := range failures {
+		t.Error(f)
+	}
+}
+
+func TestParseRulesExtends(t *testing.T) {
+	rs, err := ParseRules([]byte(`
+extends: default
+protect: [title]
+rules:
+  - name: album-bootleg-promo
+    disabled: true
+  - name: caps-abba
+    stage: canonical
+    field: artist
+    match: '(?i)^abba$'
+    replace: 'Abba'
+  - name: genre-dnb
+    field: genre
+    match: '(?i)^(dnb|drum n bass)$'
+    replace: "Drum & Bass"
+    tests:
+      - {in: dnb, out: "Drum & Bass"}
+`))
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	names := make(map[string]int)
+	for i, r := range rs.Rules {
+		names[r.Name] = i
+	}
+	if _, ok := names["album-bootleg-promo"]; ok {
+		t.Error("disabled rule still present")
+	}
+	if names["genre-dnb"] != len(rs.Rules)-1 {
+		t.Error("new rule should be appended")
+	}
+	if len(rs.Protect) != 2 {
+		t.Errorf("protect = %v, want album and title", rs.Protect)
+	}
+	if got, _ := rs.Canonicalize("ABBA"); got != "Abba" {
+		t.Errorf("replaced rule not used: %q", got)
+	}
+	if _, failures := rs.RunTests(); len(failures) > 0 {
+		t.Errorf("unexpected failures: %v", failures)
+	}
+
+	m := &store.Metadata{TagAlbum: "Album-Promo", TagGenre: "DnB"}
+	result := rs.Clean(m, "/music/track.flac")
+	if m.TagAlbum != "Album-Promo" || m.TagGenre != "Drum & Bass" {
+		t.Errorf("album %q, genre %q", m.TagAlbum, m.TagGenre)
+	}
+	if len(result.FieldsCleaned) != 1 || result.FieldsCleaned[0] != "genre" {
+		t.Errorf("FieldsCleaned = %v", result.FieldsCleaned)
+	}
+
+	// The built-in rules are not modified
+	if got, _ := DefaultRules().Canonicalize("abba"); got != "ABBA" {
+		t.Errorf("default rules changed: %q", got)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown key", "rules:\n  - name: a\n    field: album\n    match: x\n    replce: y\n", "replce"},
+		{"missing name", "rules:\n  - field: album\n    match: x\n    replace: y\n", "missing name"},
+		{"unknown field", "rules:\n  - name: a\n    field: year\n    match: x\n    replace: y\n", "unknown field"},
+		{"bad regex", "rules:\n  - name: a\n    field: album\n    match: '('\n    replace: y\n", "invalid match"},
+		{"no action", "rules:\n  - name: a\n    field: album\n    match: x\n", "needs replace"},
+		{"read-only path", "rules:\n  - name: a\n    field: path\n    match: x\n    replace: y\n", "read-only"},
+		{"duplicate", "rules:\n  - {name: a, field: album, match: x, replace: y}\n  - {name: a, field: album, match: x, replace: y}\n", "duplicate"},
+		{"bad stage", "rules:\n  - {name: a, stage: late, field: album, match: x, replace: y}\n", "unknown stage"},
+		{"canonical set", "rules:\n  - {name: a, stage: canonical, field: artist, match: x, set: {genre: y}}\n", "canonical"},
+		{"extends", "extends: custom\nrules: []\n", "unknown ruleset"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseRules([]byte(tc.yaml))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleConditionsAndChanges(t *testing.T) {
+	rs, err := ParseRules([]byte(`
+rules:
+  - name: label-from-path
+    field: path
+    match: '/labels/([^/]+)/'
+    fill: {label: '$1'}
+    when: {empty: [catalog_number]}
+  - name: live-genre
+    field: album
+    match: '(?i)\blive\b'
+    set: {genre: Live}
+    when: {set: [artist], path: '\.flac$'}
+`))
+	if err != nil {
+		t.Fatalf("ParseRules failed: %v", err)
+	}
+
+	m := &store.Metadata{TagAlbum: "Live at Home", TagArtist: "Band"}
+	result := rs.Clean(m, "/music/labels/Hospital/x.flac")
+	if m.TagLabel != "Hospital" || m.TagGenre != "Live" {
+		t.Errorf("label %q, genre %q", m.TagLabel, m.TagGenre)
+	}
+	want := []RuleChange{
+		{Rule: "label-from-path", Field: "label", Before: "", After: "Hospital"},
+		{Rule: "live-genre", Field: "genre", Before: "", After: "Live"},
+	}
+	if len(result.Changes) != len(want) {
+		t.Fatalf("Changes = %+v", result.Changes)
+	}
+	for i := range want {
+		if result.Changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, result.Changes[i], want[i])
+		}
+	}
+
+	m = &store.Metadata{TagAlbum: "Live at Home", TagCatalogNumber: "HOSP1"}
+	rs.Clean(m, "/music/labels/Hospital/x.mp3")
+	if m.TagLabel != "" || m.TagGenre != "" {
+		t.Errorf("conditions ignored: label %q, genre %q", m.TagLabel, m.TagGenre)
+	}
+}
+
+func TestCleanProtectsAlbum(t *testing.T) {
+	m := &store.Metadata{TagAlbum: "[WEB]"}
+	result := DefaultRules().Clean(m, "/music/track.mp3")
+	if m.TagAlbum != "[WEB]" || result.Changed || len(result.Changes) != 0 {
+		t.Errorf("album %q, changed %v, changes %v", m.TagAlbum, result.Changed, result.Changes)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0] != "suspicious_album_name:[WEB]" {
+		t.Errorf("Warnings = %v", result.Warnings)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	os.WriteFile(path, []byte("extends: default\nrules:\n  - {name: caps-moderat, stage: canonical, field: artist, match: '(?i)^moderat$', replace: MODERAT}\n"), 0644)
+	rs, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules failed: %v", err)
+	}
+
+	if got := CanonicalizeArtistNameWith(rs, "moderat"); got != "MODERAT" {
+		t.Errorf("CanonicalizeArtistNameWith = %q, want MODERAT", got)
+	}
+	if got := CanonicalizeArtistName("moderat"); got != "Moderat" {
+		t.Errorf("CanonicalizeArtistName with default rules = %q, want Moderat", got)
+	}
+
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
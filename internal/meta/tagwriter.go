@@ -35,9 +35,13 @@ func WriteTagsToFile(filePath string, metadata *store.Metadata) error {
 	tempPath := filePath + ".tagged"
 
 	// Build ffmpeg command
-	// ffmpeg -i input.mp3 -metadata title="Title" -metadata artist="Artist" -c copy output.mp3
+	// ffmpeg -i input.mp3 -map 0 -map_metadata 0 -metadata title="Title" -c copy output.mp3
+	// ffmpeg keeps one stream per type by default; -map 0 keeps every audio track,
+	// subtitle, cover and attachment of multi-stream containers (.mkv, .mp4, .m4b)
 	args := []string{
 		"-i", filePath,
+		"-map", "0",
+		"-map_metadata", "0",
 	}
 	args = append(args, metadataArgs...)
 	args = append(args,
@@ -63,6 +67,13 @@ func WriteTagsToFile(filePath string, metadata *store.Metadata) error {
 		return fmt.Errorf("failed to rename tagged file: %w", err)
 	}
 
+	// ffmpeg writes one value per field; FLAC gets one ARTISTS comment per artist
+	if names := creditedArtists(metadata); len(names) > 1 && strings.ToLower(filepath.Ext(filePath)) == ".flac" {
+		if err := SetFLACComments(filePath, map[string][]string{"ARTISTS": names}); err != nil {
+			return fmt.Errorf("failed to write artist credits: %w", err)
+		}
+	}
+
 	util.DebugLog("Wrote tags to: %s", filePath)
 	return nil
 }
@@ -81,6 +92,10 @@ func buildMetadataArgs(m *store.Metadata) []string {
 	// Core tags
 	addMeta("title", m.TagTitle)
 	addMeta("artist", m.TagArtist)
+	if names := creditedArtists(m); len(names) > 1 {
+		// Multi-valued artist list alongside the display artist (TXXX:ARTISTS in ID3)
+		addMeta("ARTISTS", strings.Join(names, "; "))
+	}
 	addMeta("album", m.TagAlbum)
 	addMeta("album_artist", m.TagAlbumArtist)
 	addMeta("date", m.TagDate)
@@ -110,10 +125,23 @@ func buildMetadataArgs(m *store.Metadata) []string {
 	// MusicBrainz IDs
 	addMeta("musicbrainz_trackid", m.MusicBrainzRecordingID)
 	addMeta("musicbrainz_albumid", m.MusicBrainzReleaseID)
+	addMeta("isrc", m.ISRC)
 
 	return args
 }
 
+// creditedArtists returns every artist credited in the metadata
+func creditedArtists(m *store.Metadata) []string {
+	if len(m.Artists) > 0 {
+		names := make([]string, len(m.Artists))
+		for i, credit := range m.Artists {
+			names[i] = credit.Name
+		}
+		return names
+	}
+	return CreditsOf(m).Names()
+}
+
 // CanWriteTags checks if we can write tags for this file format
 func CanWriteTags(filePath string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -132,6 +160,18 @@ func CanWriteTags(filePath string) bool {
 		".wv":   true, // WavPack
 		".tta":  true,
 		".mpc":  true,
+		".m4b":  true, // Audiobook MP4
+		".caf":  true, // Core Audio (ALAC)
+		".mka":  true, // Matroska
+		".mp4":  true, // Music video
+		".mkv":  true, // Music video
+
+		// Read by ffprobe but not writable by ffmpeg: placed without tag enrichment
+		".dsf":  false, // No DSD muxer
+		".dff":  false, // No DSD muxer
+		".tak":  false, // No TAK muxer
+		".alac": false, // Raw ALAC has no muxer
+		".w64":  false, // Wave64 muxer drops all metadata
 	}
 
 	return supportedFormats[ext]
@@ -1,6 +1,10 @@
 package meta
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/franz/music-janitor/internal/store"
@@ -48,6 +52,13 @@ func TestBuildMetadataArgs(t *testing.T) {
 			},
 			expected: 4, // title + track = 2 fields * 2 args
 		},
+		{
+			name: "featured artist",
+			metadata: &store.Metadata{
+				TagArtist: "Artist feat. Guest",
+			},
+			expected: 4, // artist + ARTISTS = 2 fields * 2 args
+		},
 		{
 			name: "compilation flag",
 			metadata: &store.Metadata{
@@ -99,6 +110,16 @@ func TestCanWriteTags(t *testing.T) {
 		{"/path/to/file.tta", true},
 		{"/path/to/file.mpc", true},
 		{"/path/to/file.wma", true},
+		{"/path/to/file.m4b", true},
+		{"/path/to/file.caf", true},
+		{"/path/to/file.mka", true},
+		{"/path/to/file.mp4", true},
+		{"/path/to/file.mkv", true},
+		{"/path/to/file.dsf", false}, // No muxer: placed without tags
+		{"/path/to/file.dff", false},
+		{"/path/to/file.tak", false},
+		{"/path/to/file.alac", false},
+		{"/path/to/file.w64", false},
 		{"/path/to/file.txt", false},
 		{"/path/to/file.jpg", false},
 		{"/path/to/file", false},
@@ -150,3 +171,40 @@ func TestBuildMetadataArgs_Format(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteTagsToFileKeepsStreams(t *testing.T) {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available", tool)
+		}
+	}
+
+	// Two audio tracks and a subtitle; ffmpeg's default mapping keeps only one audio track
+	path := filepath.Join(t.TempDir(), "multi.mkv")
+	srt := filepath.Join(t.TempDir(), "subs.srt")
+	if err := os.WriteFile(srt, []byte("1\n00:00:00,000 --> 00:00:01,000\nHello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write subtitles: %v", err)
+	}
+	create := exec.Command("ffmpeg", "-f", "lavfi", "-i", "sine=frequency=440:duration=1",
+		"-f", "lavfi", "-i", "sine=frequency=880:duration=1", "-i", srt,
+		"-map", "0", "-map", "1", "-map", "2", "-c:a", "flac", "-c:s", "srt", "-y", path)
+	if output, err := create.CombinedOutput(); err != nil {
+		t.Skipf("ffmpeg cannot create test file: %v (output: %s)", err, output)
+	}
+
+	countStreams := func() int {
+		output, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "stream=index", "-of", "csv=p=0", path).Output()
+		if err != nil {
+			t.Fatalf("ffprobe failed: %v", err)
+		}
+		return len(strings.Fields(string(output)))
+	}
+
+	before := countStreams()
+	if err := WriteTagsToFile(path, &store.Metadata{TagTitle: "Title", TagArtist: "Artist"}); err != nil {
+		t.Fatalf("WriteTagsToFile() error: %v", err)
+	}
+	if after := countStreams(); after != before {
+		t.Errorf("Stream count after tagging = %d, want %d", after, before)
+	}
+}
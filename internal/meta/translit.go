@@ -0,0 +1,185 @@
+package meta
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// cyrillicLatin romanizes Russian, Ukrainian, Belarusian and Serbian letters
+// (lowercase; uppercase letters are mapped through their lowercase form)
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	// Ukrainian and Belarusian
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u",
+	// Serbian and Macedonian
+	'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz", 'ѓ': "gj", 'ќ': "kj", 'ѕ': "dz",
+}
+
+// greekLatin romanizes Greek letters, including accented vowels
+var greekLatin = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// kanaLatin romanizes hiragana (Hepburn); katakana is mapped to hiragana first
+var kanaLatin = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "wi", 'ゑ': "we", 'を': "wo", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ゔ': "vu", 'ゕ': "ka", 'ゖ': "ke",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa",
+}
+
+// Katakana letters are hiragana shifted by this offset
+const katakanaOffset = 'ア' - 'あ'
+
+// Transliterate romanizes Cyrillic, Greek, hiragana and katakana, e.g.
+// "Кино" -> "Kino", "Σωκράτης" -> "Sokratis", "カタカナ" -> "katakana".
+// Other characters, including kanji, are kept as they are
+func Transliterate(s string) string {
+	if s == "" {
+		return ""
+	}
+	runes := []rune(norm.NFC.String(s))
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case isKana(r):
+			n := transliterateKana(runes[i:], &b)
+			i += n - 1
+		case r == '・': // Katakana middle dot separates words
+			b.WriteByte(' ')
+		default:
+			lower := unicode.ToLower(r)
+			latin, ok := cyrillicLatin[lower]
+			if !ok {
+				latin, ok = greekLatin[lower]
+				// Greek "ου" is written "ou"
+				if ok && lower == 'ο' && i+1 < len(runes) && unicode.ToLower(runes[i+1]) == 'υ' {
+					latin = "ou"
+					i++
+				}
+			}
+			if !ok {
+				b.WriteRune(r)
+				continue
+			}
+			b.WriteString(matchCase(latin, r, runes, i))
+		}
+	}
+	return b.String()
+}
+
+// ToASCII transliterates s and folds diacritics, e.g. "Кино" -> "Kino",
+// "Beyoncé" -> "Beyonce". Characters without a transliteration are kept
+func ToASCII(s string) string {
+	return FoldDiacritics(Transliterate(s))
+}
+
+// matchCase capitalizes latin like the letter r it replaces: "Ж" -> "Zh",
+// or "ZH" when the neighbouring letters are capitals too ("ЖУК" -> "ZHUK")
+func matchCase(latin string, r rune, runes []rune, i int) string {
+	if !unicode.IsUpper(r) || latin == "" {
+		return latin
+	}
+	allCaps := (i+1 < len(runes) && unicode.IsUpper(runes[i+1])) ||
+		(i > 0 && unicode.IsUpper(runes[i-1]) && (i+1 >= len(runes) || !unicode.IsLower(runes[i+1])))
+	if allCaps {
+		return strings.ToUpper(latin)
+	}
+	return strings.ToUpper(latin[:1]) + latin[1:]
+}
+
+func isKana(r rune) bool {
+	return (r >= 'ぁ' && r <= 'ゖ') || (r >= 'ァ' && r <= 'ヶ') || r == 'ー'
+}
+
+// toHiragana maps a katakana letter to its hiragana counterpart
+func toHiragana(r rune) rune {
+	if r >= 'ァ' && r <= 'ヶ' {
+		return r - katakanaOffset
+	}
+	return r
+}
+
+// transliterateKana romanizes the run of kana at the start of runes
+// Returns the number of runes consumed
+func transliterateKana(runes []rune, b *strings.Builder) int {
+	var syllables []string
+	geminate := false // Small tsu doubles the next consonant
+	n := 0
+	for ; n < len(runes) && isKana(runes[n]); n++ {
+		r := toHiragana(runes[n])
+		switch r {
+		case 'っ':
+			geminate = true
+			continue
+		case 'ー':
+			continue // Long vowel mark
+		}
+
+		latin := kanaLatin[r]
+		last := len(syllables) - 1
+		switch {
+		case (r == 'ゃ' || r == 'ゅ' || r == 'ょ') && last >= 0 && strings.HasSuffix(syllables[last], "i") && len(syllables[last]) > 1:
+			// Contracted sounds: きゃ kya, しゃ sha, ちゃ cha, じゃ ja
+			base := strings.TrimSuffix(syllables[last], "i")
+			if strings.HasSuffix(base, "sh") || strings.HasSuffix(base, "ch") || strings.HasSuffix(base, "j") {
+				latin = latin[1:]
+			}
+			syllables[last] = base + latin
+			continue
+		case isSmallVowel(r) && last >= 0 && len(syllables[last]) > 1:
+			// Extended katakana: ファ fa, ティ ti, チェ che
+			prev := syllables[last]
+			syllables[last] = prev[:len(prev)-1] + latin
+			continue
+		case isSmallVowel(r) && last >= 0 && syllables[last] == "u":
+			// ウィ wi, ウェ we, ウォ wo
+			syllables[last] = "w" + latin
+			continue
+		}
+
+		if geminate && latin != "" {
+			if strings.HasPrefix(latin, "ch") {
+				latin = "t" + latin
+			} else if !strings.ContainsRune("aeiou", rune(latin[0])) {
+				latin = latin[:1] + latin
+			}
+		}
+		geminate = false
+		syllables = append(syllables, latin)
+	}
+	b.WriteString(strings.Join(syllables, ""))
+	return n
+}
+
+func isSmallVowel(r rune) bool {
+	return r == 'ぁ' || r == 'ぃ' || r == 'ぅ' || r == 'ぇ' || r == 'ぉ'
+}
@@ -0,0 +1,61 @@
+package meta
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Cyrillic
+		{"Кино", "Kino"},
+		{"КИНО", "KINO"},
+		{"Жук", "Zhuk"},
+		{"ЖУК", "ZHUK"},
+		{"Группа крови", "Gruppa krovi"},
+		{"Ёлка", "Yolka"},
+		{"Їжак", "Yizhak"},
+		{"Земфира", "Zemfira"},
+		// Greek
+		{"Σωκράτης", "Sokratis"},
+		{"Μουσική", "Mousiki"},
+		// Hiragana and katakana
+		{"きゃりーぱみゅぱみゅ", "kyaripamyupamyu"},
+		{"ファイナルファンタジー", "fainarufantaji"},
+		{"ちょっと", "chotto"},
+		{"マッチ", "matchi"},
+		{"ウィーン", "win"},
+		{"ポリリズム", "poririzumu"},
+		// Kept as they are
+		{"坂本龍一", "坂本龍一"},
+		{"Beyoncé", "Beyoncé"},
+		{"Plain ASCII", "Plain ASCII"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		result := Transliterate(tt.input)
+		if result != tt.expected {
+			t.Errorf("Transliterate(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Кино", "Kino"},
+		{"Beyoncé", "Beyonce"},
+		{"Αλκίνοος Ιωαννίδης", "Alkinoos Ioannidis"},
+		{"Mötley Crüe", "Motley Crue"},
+	}
+
+	for _, tt := range tests {
+		result := ToASCII(tt.input)
+		if result != tt.expected {
+			t.Errorf("ToASCII(%q) = %q, expected %q", tt.input, result, tt.expected)
+		}
+	}
+}
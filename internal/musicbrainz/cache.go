@@ -100,6 +100,75 @@ func (c *Cache) NormalizeArtistName(ctx context.Context, artistName string) (str
 	return canonical, err
 }
 
+// NormalizerFingerprint is shared with the offline cache: both resolve artists
+// to the cached MusicBrainz names, so switching between them keeps cluster keys
+func (c *Cache) NormalizerFingerprint() string {
+	return "musicbrainz"
+}
+
+// Offline returns a normalizer that answers from the cache only, without API
+// lookups; names not in the cache resolve to ""
+func (c *Cache) Offline() *OfflineCache {
+	return &OfflineCache{cache: c}
+}
+
+// OfflineCache resolves artist names from previously cached MusicBrainz lookups
+type OfflineCache struct {
+	cache *Cache
+}
+
+// NormalizerFingerprint matches the online cache's
+func (o *OfflineCache) NormalizerFingerprint() string {
+	return o.cache.NormalizerFingerprint()
+}
+
+// NormalizeArtistName returns the cached canonical name, or "" on a cache miss
+func (o *OfflineCache) NormalizeArtistName(ctx context.Context, artistName string) (string, error) {
+	searchKey := strings.ToLower(strings.TrimSpace(artistName))
+	if searchKey == "" {
+		return "", nil
+	}
+	cached, err := o.cache.getFromCache(searchKey)
+	if err != nil || cached == nil {
+		return "", err
+	}
+	o.cache.incrementHitCount(searchKey)
+	return cached.CanonicalName, nil
+}
+
+// Mappings returns the names looked up so far, grouped by canonical name
+// Names that only differ from their canonical name in case are left out.
+// It only reads, so it works on a read-only database that was never used
+// with MusicBrainz.
+func (c *Cache) Mappings() (map[string][]string, error) {
+	var tables int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'musicbrainz_cache'`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("failed to query cache: %w", err)
+	}
+	if tables == 0 {
+		return map[string][]string{}, nil
+	}
+
+	rows, err := c.db.Query(`SELECT search_name, canonical_name FROM musicbrainz_cache ORDER BY canonical_name, search_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cache: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := make(map[string][]string)
+	for rows.Next() {
+		var searchName, canonical string
+		if err := rows.Scan(&searchName, &canonical); err != nil {
+			return nil, fmt.Errorf("failed to scan cache entry: %w", err)
+		}
+		if canonical == "" || strings.EqualFold(searchName, canonical) {
+			continue
+		}
+		mappings[canonical] = append(mappings[canonical], searchName)
+	}
+	return mappings, rows.Err()
+}
+
 // getFromCache retrieves a cached lookup
 func (c *Cache) getFromCache(searchName string) (*CachedArtist, error) {
 	query := `
@@ -0,0 +1,70 @@
+package musicbrainz
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestOfflineCacheAndMappings(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer db.Close()
+
+	// No client: the offline cache must never reach the API
+	cache := NewCache(db.DB(), nil)
+	if err := cache.EnsureSchema(); err != nil {
+		t.Fatalf("EnsureSchema failed: %v", err)
+	}
+	for search, canonical := range map[string]string{
+		"acdc":        "AC/DC",
+		"ac dc":       "AC/DC",
+		"the beatles": "The Beatles",
+	} {
+		if err := cache.storeInCache(search, canonical, nil, 100); err != nil {
+			t.Fatalf("storeInCache failed: %v", err)
+		}
+	}
+
+	offline := cache.Offline()
+	ctx := context.Background()
+	if got, err := offline.NormalizeArtistName(ctx, " ACDC "); got != "AC/DC" || err != nil {
+		t.Errorf("NormalizeArtistName(ACDC) = %q, %v", got, err)
+	}
+	if got, err := offline.NormalizeArtistName(ctx, "Queen"); got != "" || err != nil {
+		t.Errorf("cache miss = %q, %v; want empty", got, err)
+	}
+
+	// 'mlc watch' uses the offline cache alone, 'mlc plan' adds API lookups
+	watchChain := meta.NormalizerChain{offline}
+	planChain := meta.NormalizerChain{offline, cache}
+	if meta.NormalizerFingerprint(watchChain) != meta.NormalizerFingerprint(planChain) {
+		t.Error("expected online and offline lookups to share a fingerprint")
+	}
+
+	mappings, err := cache.Mappings()
+	if err != nil {
+		t.Fatalf("Mappings failed: %v", err)
+	}
+	if len(mappings) != 1 || len(mappings["AC/DC"]) != 2 || mappings["AC/DC"][0] != "ac dc" {
+		t.Errorf("Mappings = %v, want AC/DC: [ac dc acdc]", mappings)
+	}
+}
+
+func TestMappingsWithoutCache(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer db.Close()
+
+	mappings, err := NewCache(db.DB(), nil).Mappings()
+	if err != nil || len(mappings) != 0 {
+		t.Errorf("Mappings on a database without a cache = %v, %v; want none", mappings, err)
+	}
+}
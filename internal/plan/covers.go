@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// MinCoverSize is the smallest side in pixels of a cover that is good enough;
+// smaller covers are only used when an album has nothing better
+const MinCoverSize = 500
+
+// selectCovers chooses the cover of every destination album folder from the
+// pictures embedded in its planned files and their duplicates
+// Returns the number of albums with a cover
+func (p *Planner) selectCovers() (int, error) {
+	candidates, err := p.store.GetCoverCandidates()
+	if err != nil {
+		return 0, err
+	}
+
+	byAlbum := make(map[string][]*store.CoverCandidate)
+	for _, c := range candidates {
+		dir := filepath.Dir(c.DestPath)
+		byAlbum[dir] = append(byAlbum[dir], c)
+	}
+
+	covers := make([]*store.AlbumCover, 0, len(byAlbum))
+	for dir, albumCandidates := range byAlbum {
+		best := bestCover(albumCandidates)
+		if best == nil {
+			continue
+		}
+		covers = append(covers, &store.AlbumCover{
+			AlbumDir: dir,
+			FileID:   best.FileID,
+			SHA1:     best.Picture.SHA1,
+			MIMEType: best.Picture.MIMEType,
+			Width:    best.Picture.Width,
+			Height:   best.Picture.Height,
+		})
+	}
+	sort.Slice(covers, func(i, j int) bool { return covers[i].AlbumDir < covers[j].AlbumDir })
+
+	if err := p.store.ReplaceAlbumCovers(covers); err != nil {
+		return 0, err
+	}
+	util.DebugLog("Chose covers for %d of %d albums with artwork", len(covers), len(byAlbum))
+	return len(covers), nil
+}
+
+// bestCover picks an album's cover: a picture at least MinCoverSize on its
+// shorter side first, then front covers, then the largest, then the one
+// embedded in the most files. Pictures that could not be decoded are never used
+func bestCover(candidates []*store.CoverCandidate) *store.CoverCandidate {
+	uses := make(map[string]int)
+	for _, c := range candidates {
+		uses[c.Picture.SHA1]++
+	}
+
+	var best *store.CoverCandidate
+	for _, c := range candidates {
+		if c.Picture.Width <= 0 || c.Picture.Height <= 0 {
+			continue
+		}
+		if best == nil || coverBetter(c, best, uses) {
+			best = c
+		}
+	}
+	return best
+}
+
+// coverBetter reports whether cover a ranks above cover b
+func coverBetter(a, b *store.CoverCandidate, uses map[string]int) bool {
+	pa, pb := a.Picture, b.Picture
+	if bigA, bigB := min(pa.Width, pa.Height) >= MinCoverSize, min(pb.Width, pb.Height) >= MinCoverSize; bigA != bigB {
+		return bigA
+	}
+	if frontA, frontB := pa.Type == store.PictureFrontCover, pb.Type == store.PictureFrontCover; frontA != frontB {
+		return frontA
+	}
+	if areaA, areaB := pa.Width*pa.Height, pb.Width*pb.Height; areaA != areaB {
+		return areaA > areaB
+	}
+	if uses[pa.SHA1] != uses[pb.SHA1] {
+		return uses[pa.SHA1] > uses[pb.SHA1]
+	}
+	// Deterministic choice between equal covers
+	if pa.SHA1 != pb.SHA1 {
+		return pa.SHA1 < pb.SHA1
+	}
+	return a.FileID < b.FileID
+}
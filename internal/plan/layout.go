@@ -0,0 +1,158 @@
+package plan
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/franz/music-janitor/internal/meta"
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// LayoutPlaceholders are the fields a layout template can use
+var LayoutPlaceholders = []string{"artist", "albumartist", "album", "title", "track", "disc", "year", "date", "genre"}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+	fullDatePattern    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	driveLetterPattern = regexp.MustCompile(`^[A-Za-z]:`)
+)
+
+// ValidateLayout checks that a layout template only uses known placeholders
+// and stays below the destination root
+func ValidateLayout(layout string) error {
+	if strings.TrimSpace(layout) == "" {
+		return fmt.Errorf("layout is empty")
+	}
+	known := make(map[string]bool, len(LayoutPlaceholders))
+	for _, name := range LayoutPlaceholders {
+		known[name] = true
+	}
+
+	var unknown []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(layout, -1) {
+		if !known[match[1]] {
+			unknown = append(unknown, "{"+match[1]+"}")
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown placeholders %s in layout %q (use: {%s})",
+			strings.Join(unknown, ", "), layout, strings.Join(LayoutPlaceholders, "}, {"))
+	}
+	if rest := placeholderPattern.ReplaceAllString(layout, ""); strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("unbalanced braces in layout %q", layout)
+	}
+
+	// Layouts stay below the destination root
+	if strings.HasPrefix(layout, "/") || strings.HasPrefix(layout, `\`) || driveLetterPattern.MatchString(layout) {
+		return fmt.Errorf("layout %q must be relative to the destination", layout)
+	}
+	for _, part := range strings.FieldsFunc(layout, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part := strings.TrimSpace(part); part == ".." || part == "." {
+			return fmt.Errorf("layout %q may not contain %q folders", layout, part)
+		}
+	}
+	return nil
+}
+
+// RenderLayout builds a destination path from a layout template such as
+// "Podcasts/{album}/{date} - {title}"; the source extension is appended.
+// Separators next to empty fields are dropped ("{track} - {title}" without a
+// track is just the title), and so are folders that end up empty. Artist
+// names are canonicalized by rules (nil: the built-in rules).
+func RenderLayout(destRoot, layout string, m *store.Metadata, srcPath, featCredits string, rules *meta.RuleSet) string {
+	values := layoutValues(m, srcPath, featCredits, rules)
+
+	components := []string{destRoot}
+	parts := strings.Split(filepath.ToSlash(layout), "/")
+	for i, part := range parts {
+		component := placeholderPattern.ReplaceAllStringFunc(part, func(placeholder string) string {
+			return values[strings.Trim(placeholder, "{}")]
+		})
+		component = tidyLayoutComponent(component)
+		if component == "" {
+			if i < len(parts)-1 {
+				continue
+			}
+			component = SanitizePathComponent(values["title"])
+		}
+		components = append(components, component)
+	}
+
+	path := filepath.Join(components...)
+	return path + strings.ToLower(filepath.Ext(srcPath))
+}
+
+// layoutValues returns the sanitized value of every placeholder for a file
+func layoutValues(m *store.Metadata, srcPath, featCredits string, rules *meta.RuleSet) map[string]string {
+	artist, albumArtist, _, title := creditedNames(m, featCredits)
+	if artist == "" {
+		artist = "Unknown Artist"
+	}
+	artist = meta.CanonicalizeArtistNameWith(rules, artist)
+	if albumArtist == "" {
+		albumArtist = artist
+	} else {
+		albumArtist = meta.CanonicalizeArtistNameWith(rules, albumArtist)
+	}
+
+	album := meta.CleanAlbumName(m.TagAlbum)
+	if album == "" {
+		album = "Unknown Album"
+	}
+
+	if title == "" {
+		base := filepath.Base(srcPath)
+		title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	track := ""
+	if m.TagTrack > 0 {
+		if m.TagTrackTotal >= 100 {
+			track = fmt.Sprintf("%03d", m.TagTrack)
+		} else {
+			track = fmt.Sprintf("%02d", m.TagTrack)
+		}
+	}
+
+	disc := ""
+	if m.TagDisc > 0 && m.TagDiscTotal > 1 {
+		disc = fmt.Sprintf("Disc %02d", m.TagDisc)
+	}
+
+	year := extractYear(m.TagDate)
+	date := year
+	if full := fullDatePattern.FindString(m.TagDate); full != "" {
+		date = full
+	}
+
+	values := map[string]string{
+		"artist":      artist,
+		"albumartist": albumArtist,
+		"album":       album,
+		"title":       title,
+		"track":       track,
+		"disc":        disc,
+		"year":        year,
+		"date":        date,
+		"genre":       m.TagGenre,
+	}
+	for name, value := range values {
+		if value != "" {
+			values[name] = SanitizePathComponent(value)
+		}
+	}
+	return values
+}
+
+// tidyLayoutComponent removes the separators an empty field leaves behind
+func tidyLayoutComponent(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	for strings.Contains(s, "- -") {
+		s = strings.ReplaceAll(s, "- -", "-")
+	}
+	return strings.Trim(s, " -_")
+}
@@ -0,0 +1,113 @@
+package plan
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+func TestRenderLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		metadata *store.Metadata
+		srcPath  string
+		expected string
+	}{
+		{
+			name:   "podcast episode",
+			layout: "Podcasts/{album}/{date} - {title}",
+			metadata: &store.Metadata{
+				TagArtist: "Host",
+				TagAlbum:  "The Show",
+				TagTitle:  "Episode 12: Guests",
+				TagDate:   "2023-04-05T08:00:00Z",
+			},
+			srcPath:  "/src/ep12.MP3",
+			expected: "/dest/Podcasts/The Show/2023-04-05 - Episode 12_ Guests.mp3",
+		},
+		{
+			name:   "audiobook without disc",
+			layout: "Audiobooks/{albumartist}/{album}/{disc}/{track} - {title}",
+			metadata: &store.Metadata{
+				TagArtist: "Author",
+				TagAlbum:  "Book",
+				TagTitle:  "Chapter 1",
+				TagTrack:  1,
+			},
+			srcPath:  "/src/01.m4b",
+			expected: "/dest/Audiobooks/Author/Book/01 - Chapter 1.m4b",
+		},
+		{
+			name:   "audiobook with discs",
+			layout: "Audiobooks/{albumartist}/{album}/{disc}/{track} - {title}",
+			metadata: &store.Metadata{
+				TagArtist:    "Author",
+				TagAlbum:     "Book",
+				TagTitle:     "Chapter 9",
+				TagTrack:     3,
+				TagDisc:      2,
+				TagDiscTotal: 4,
+			},
+			srcPath:  "/src/09.mp3",
+			expected: "/dest/Audiobooks/Author/Book/Disc 02/03 - Chapter 9.mp3",
+		},
+		{
+			name:   "mix without year",
+			layout: "Mixes/{artist}/{year} - {title}",
+			metadata: &store.Metadata{
+				TagArtist: "Solomun",
+				TagTitle:  "Live Set",
+			},
+			srcPath:  "/src/set.flac",
+			expected: "/dest/Mixes/Solomun/Live Set.flac",
+		},
+		{
+			name:     "missing tags fall back",
+			layout:   "{artist}/{album}/{title}",
+			metadata: &store.Metadata{},
+			srcPath:  "/src/recording.wav",
+			expected: "/dest/Unknown Artist/Unknown Album/recording.wav",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderLayout("/dest", tt.layout, tt.metadata, tt.srcPath, "", nil)
+			if got != filepath.FromSlash(tt.expected) {
+				t.Errorf("RenderLayout() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	tests := []struct {
+		layout  string
+		wantErr bool
+	}{
+		{"Podcasts/{album}/{date} - {title}", false},
+		{"{albumartist}/{album}/{disc}/{track} - {title}", false},
+		{"Mixes/{artist}/{name}", true},
+		{"Mixes/{artist/{title}", true},
+		{"", true},
+		{"../Podcasts/{title}", true},
+		{"Podcasts/../../{title}", true},
+		{`Podcasts\..\{title}`, true},
+		{"./{title}", true},
+		{"/srv/podcasts/{title}", true},
+		{`\\server\share\{title}`, true},
+		{"C:/Podcasts/{title}", true},
+		{"Podcasts/{album}..{title}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			err := ValidateLayout(tt.layout)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLayout(%q) error = %v, wantErr %v", tt.layout, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/score"
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// TestPipelineInMemory runs cluster, score and plan against the in-memory
+// store, first in full and then incrementally after a better copy shows up
+func TestPipelineInMemory(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	destRoot := filepath.Join(t.TempDir(), "library")
+
+	addFile := func(name string, m store.Metadata) *store.File {
+		f := &store.File{FileKey: "key:" + name, SrcPath: "/src/" + name, SizeBytes: 5000000, Status: "meta_ok"}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("InsertFile(%s) error: %v", name, err)
+		}
+		m.FileID = f.ID
+		if err := db.InsertMetadata(&m); err != nil {
+			t.Fatalf("InsertMetadata(%s) error: %v", name, err)
+		}
+		return f
+	}
+	song := func(format, codec string, lossless bool, bitrate int) store.Metadata {
+		return store.Metadata{
+			Format: format, Codec: codec, Lossless: lossless, BitrateKbps: bitrate, SampleRate: 44100, Channels: 2,
+			DurationMs: 200000, TagArtist: "Artist", TagAlbumArtist: "Artist", TagAlbum: "Album", TagTitle: "Song", TagTrack: 1,
+		}
+	}
+
+	mp3 := addFile("song.mp3", song("mp3", "mp3", false, 128))
+	flac := addFile("song.flac", song("flac", "flac", true, 900))
+	other := store.Metadata{Format: "mp3", Codec: "mp3", BitrateKbps: 320, DurationMs: 150000,
+		TagArtist: "Artist", TagAlbumArtist: "Artist", TagAlbum: "Album", TagTitle: "Other Song", TagTrack: 2}
+	single := addFile("other.mp3", other)
+
+	run := func(incremental bool) *Result {
+		t.Helper()
+		if _, err := cluster.New(&cluster.Config{Store: db}).Cluster(ctx); err != nil {
+			t.Fatalf("Cluster() error: %v", err)
+		}
+		if _, err := score.New(&score.Config{Store: db}).Score(ctx); err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		result, err := New(&Config{Store: db, Mode: "copy", Incremental: incremental}).Plan(ctx, destRoot)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		return result
+	}
+	actions := func() map[int64]string {
+		plans, err := db.GetAllPlans()
+		if err != nil {
+			t.Fatalf("GetAllPlans() error: %v", err)
+		}
+		got := make(map[int64]string)
+		for _, p := range plans {
+			got[p.FileID] = p.Action
+			if p.Action == "copy" {
+				got[p.FileID] += " " + filepath.ToSlash(p.DestPath[len(destRoot):])
+			}
+		}
+		return got
+	}
+	expectActions := func(want map[int64]string) {
+		t.Helper()
+		got := actions()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("plans = %v, want %v", got, want)
+		}
+	}
+
+	result := run(false)
+	if result.WinnersPlanned != 2 || result.SingletonsPlanned != 1 || result.DuplicatesSkipped != 1 {
+		t.Errorf("Plan() = %+v, want 2 winners (1 singleton) and 1 duplicate skipped", result)
+	}
+	expectActions(map[int64]string{
+		mp3.ID:    "skip",
+		flac.ID:   "copy /Artist/Album/01 - Song.flac",
+		single.ID: "copy /Artist/Album/02 - Other Song.mp3",
+	})
+
+	// A hi-res copy joins the existing cluster; only that cluster is replanned
+	hires := song("flac", "flac", true, 2800)
+	hires.BitDepth, hires.SampleRate = 24, 96000
+	better := addFile("song-hires.flac", hires)
+
+	run(true)
+	dirty, _ := db.GetDirtyClusters()
+	if len(dirty) != 0 {
+		t.Errorf("GetDirtyClusters() after planning = %d clusters, want 0", len(dirty))
+	}
+	expectActions(map[int64]string{
+		mp3.ID:    "skip",
+		flac.ID:   "skip",
+		better.ID: "copy /Artist/Album/01 - Song.flac",
+		single.ID: "copy /Artist/Album/02 - Other Song.mp3",
+	})
+}
+
+// failingPlans fails every plan write
+type failingPlans struct {
+	*store.Memory
+}
+
+func (failingPlans) InsertPlanBatch([]*store.Plan) error {
+	return fmt.Errorf("disk full")
+}
+
+// TestPlanSettingsChange checks that incremental planning re-plans unchanged
+// clusters when path settings change, and keeps changed clusters flagged
+// when their plans fail to save
+func TestPlanSettingsChange(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+	destRoot := filepath.Join(t.TempDir(), "library")
+
+	addFile := func(name, title string) *store.File {
+		f := &store.File{FileKey: "key:" + name, SrcPath: "/src/" + name, SizeBytes: 5000000, Status: "meta_ok"}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("InsertFile(%s) error: %v", name, err)
+		}
+		m := &store.Metadata{FileID: f.ID, Format: "mp3", Codec: "mp3", BitrateKbps: 320, DurationMs: 200000,
+			TagArtist: "Кино", TagAlbumArtist: "Кино", TagAlbum: "Группа крови", TagTitle: title, TagTrack: 1}
+		if err := db.InsertMetadata(m); err != nil {
+			t.Fatalf("InsertMetadata(%s) error: %v", name, err)
+		}
+		return f
+	}
+	run := func(repo Repo, asciiPaths bool) *Result {
+		t.Helper()
+		if _, err := cluster.New(&cluster.Config{Store: db}).Cluster(ctx); err != nil {
+			t.Fatalf("Cluster() error: %v", err)
+		}
+		if _, err := score.New(&score.Config{Store: db}).Score(ctx); err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		result, err := New(&Config{Store: repo, Mode: "copy", Incremental: true, ASCIIPaths: asciiPaths}).Plan(ctx, destRoot)
+		if err != nil {
+			t.Fatalf("Plan() error: %v", err)
+		}
+		return result
+	}
+	destPath := func(id int64) string {
+		t.Helper()
+		plans, err := db.GetAllPlans()
+		if err != nil {
+			t.Fatalf("GetAllPlans() error: %v", err)
+		}
+		for _, p := range plans {
+			if p.FileID == id {
+				return filepath.ToSlash(p.DestPath[len(destRoot):])
+			}
+		}
+		t.Fatalf("no plan for file %d", id)
+		return ""
+	}
+
+	song := addFile("song.mp3", "Кукушка")
+	run(db, false)
+	if got, want := destPath(song.ID), "/Кино/Группа крови/01 - Кукушка.mp3"; got != want {
+		t.Errorf("dest path = %q, want %q", got, want)
+	}
+
+	// No cluster changed, but every path depends on the new setting
+	run(db, true)
+	if got, want := destPath(song.ID), "/Kino/Gruppa krovi/01 - Kukushka.mp3"; got != want {
+		t.Errorf("dest path after enabling ascii_paths = %q, want %q", got, want)
+	}
+
+	// A new file's plan fails to save: its cluster stays flagged for the next run
+	addFile("other.mp3", "Звезда")
+	if result := run(failingPlans{db}, true); len(result.Errors) == 0 {
+		t.Fatalf("Plan() errors = none, want the failed plan batch")
+	}
+	dirty, err := db.GetDirtyClusters()
+	if err != nil {
+		t.Fatalf("GetDirtyClusters() error: %v", err)
+	}
+	if len(dirty) != 1 {
+		t.Errorf("GetDirtyClusters() after failed save = %d clusters, want 1", len(dirty))
+	}
+}
@@ -7,45 +7,123 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/franz/music-janitor/internal/classify"
 	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/report"
 	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 )
 
+// Repo is the state planning reads and writes
+type Repo interface {
+	store.FileRepo
+	store.MetadataRepo
+	store.ClusterRepo
+	store.PlanRepo
+	store.FingerprintRepo
+}
+
 // Planner creates execution plans for clustered files
 type Planner struct {
-	store  *store.Store
-	mode   string // copy, move, hardlink, symlink
-	logger *report.EventLogger
+	store       Repo
+	mode        string // copy, move, hardlink, symlink
+	logger      *report.EventLogger
+	incremental bool
+	featCredits string
+	classifier  *classify.Classifier
+	rules       *meta.RuleSet
+
+	asciiPaths bool
+}
+
+// Where feature credits ("A feat. B") go in destination paths
+const (
+	FeatCreditsTitle  = "title"  // In the title: "A/Album/01 - Song (feat. B).mp3"
+	FeatCreditsArtist = "artist" // On the artist in compilation filenames; titles without them
+	FeatCreditsDrop   = "drop"   // Removed from artists and titles
+	FeatCreditsKeep   = "keep"   // Artist and title exactly as tagged
+)
+
+// ValidFeatCredits reports whether s is a supported feature credit rule
+func ValidFeatCredits(s string) bool {
+	switch s {
+	case FeatCreditsTitle, FeatCreditsArtist, FeatCreditsDrop, FeatCreditsKeep:
+		return true
+	}
+	return false
 }
 
 // Config holds planner configuration
 type Config struct {
-	Store  *store.Store
+	Store  Repo
 	Mode   string // copy, move, hardlink, symlink
 	Logger *report.EventLogger
+
+	// Incremental re-plans only clusters marked dirty by incremental clustering,
+	// provided existing plans were made for the same destination and mode
+	Incremental bool
+
+	// FeatCredits places feature credits in destination paths (default: FeatCreditsTitle)
+	FeatCredits string
+
+	// ASCIIPaths transliterates destination folders and file names to Latin
+	// script ("Кино" -> "Kino"); by default they keep the tagged script
+	ASCIIPaths bool
+
+	// Classifier labels cluster winners (music, audiobook, podcast, ...) whose
+	// class rule picks the layout or excludes them (nil: everything is music)
+	Classifier *classify.Classifier
+
+	// Rules canonicalizes artist names in destination paths (nil: the built-in rules)
+	Rules *meta.RuleSet
 }
 
+// layoutVersion identifies the destination path algorithm; bump it whenever
+// GenerateDestPath or RenderLayout change, so existing plans are rebuilt
+const layoutVersion = "3"
+
 // New creates a new Planner
 func New(cfg *Config) *Planner {
 	if cfg.Mode == "" {
 		cfg.Mode = "copy" // Default to safe copy mode
 	}
+	if cfg.FeatCredits == "" {
+		cfg.FeatCredits = FeatCreditsTitle
+	}
 
 	return &Planner{
-		store:  cfg.Store,
-		mode:   cfg.Mode,
-		logger: cfg.Logger,
+		store:       cfg.Store,
+		mode:        cfg.Mode,
+		logger:      cfg.Logger,
+		incremental: cfg.Incremental,
+		featCredits: cfg.FeatCredits,
+		classifier:  cfg.Classifier,
+		asciiPaths:  cfg.ASCIIPaths,
+		rules:       cfg.Rules,
 	}
 }
 
+// fingerprint identifies the settings destination paths are built with;
+// the destination and mode are checked against the plans themselves
+func (p *Planner) fingerprint() string {
+	return util.SettingsFingerprint(
+		"layout="+layoutVersion,
+		"feat_credits="+p.featCredits,
+		fmt.Sprintf("ascii_paths=%t", p.asciiPaths),
+		"classes="+p.classifier.Fingerprint(),
+		"rules="+p.rules.Fingerprint(),
+	)
+}
+
 // Result represents planning results
 type Result struct {
 	WinnersPlanned int
 	DuplicatesSkipped int
 	SingletonsPlanned int
+	Excluded int // Files skipped because their content class is excluded
 	Errors []error
 }
 
@@ -69,10 +147,28 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 	}
 	util.InfoLog("Loaded %d metadata records", len(metadataMap))
 
-	// Get all clusters
-	clusters, err := p.store.GetAllClusters()
+	// Get clusters to plan: only changed ones when existing plans can be kept
+	incremental, err := p.canPlanIncrementally(destRoot)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clusters: %w", err)
+		return nil, fmt.Errorf("failed to check existing plans: %w", err)
+	}
+
+	var clusters []*store.Cluster
+	if incremental {
+		clusters, err = p.store.GetDirtyClusters()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changed clusters: %w", err)
+		}
+		if len(clusters) == 0 {
+			util.InfoLog("Plans up to date (no changed clusters)")
+			return &Result{}, nil
+		}
+		util.InfoLog("Incremental planning: %d changed clusters", len(clusters))
+	} else {
+		clusters, err = p.store.GetAllClusters()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clusters: %w", err)
+		}
 	}
 
 	if len(clusters) == 0 {
@@ -104,8 +200,15 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 	}
 
 	// Clear existing plans (idempotent operation)
-	if err := p.store.ClearPlans(); err != nil {
-		return nil, fmt.Errorf("failed to clear plans: %w", err)
+	// Incremental runs overwrite the plans of changed clusters' members instead
+	// An interrupted full re-plan leaves no settings behind, so the next run re-plans again
+	if !incremental {
+		if err := p.store.SetFingerprint(store.FingerprintPlanLayout, ""); err != nil {
+			return nil, fmt.Errorf("failed to reset path settings: %w", err)
+		}
+		if err := p.store.ClearPlans(); err != nil {
+			return nil, fmt.Errorf("failed to clear plans: %w", err)
+		}
 	}
 
 	// Prepare batch plans
@@ -116,6 +219,7 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 	var winnersPlanned atomic.Int64
 	var duplicatesSkipped atomic.Int64
 	var singletonsPlanned atomic.Int64
+	var excluded atomic.Int64
 
 	// Start progress reporter
 	progressCtx, cancelProgress := context.WithCancel(ctx)
@@ -148,6 +252,7 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 			result.WinnersPlanned = int(winnersPlanned.Load())
 			result.DuplicatesSkipped = int(duplicatesSkipped.Load())
 			result.SingletonsPlanned = int(singletonsPlanned.Load())
+			result.Excluded = int(excluded.Load())
 			return result, ctx.Err()
 		default:
 		}
@@ -191,21 +296,50 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 			continue
 		}
 
-		// Check if this is a true compilation (compilation flag + multiple artists)
-		isCompilation := false
-		if winnerMeta.TagCompilation {
-			isCompilation = p.isRealCompilationFast(winner.FileID, winnerMeta.TagAlbum, membersMap, metadataMap)
+		// The winner's content class picks the layout, or leaves the cluster out
+		class := p.classifier.Classify(winnerMeta, winnerFile.SrcPath)
+		rule := p.classifier.Rule(class.Class)
+		if rule.Exclude {
+			reason := fmt.Sprintf("excluded %s (%s)", class.Class, class.Reason)
+			for _, member := range members {
+				allPlans = append(allPlans, &store.Plan{FileID: member.FileID, Action: "skip", Reason: reason})
+				if p.logger != nil {
+					if memberFile, ok := filesMap[member.FileID]; ok {
+						p.logger.LogPlan(memberFile.FileKey, memberFile.SrcPath, "", "skip", reason)
+					}
+				}
+			}
+			excluded.Add(int64(len(members)))
+			processed.Add(1)
+			continue
 		}
 
 		// Generate destination path
-		destPath := GenerateDestPath(destRoot, winnerMeta, winnerFile.SrcPath, isCompilation)
+		var destPath string
+		if rule.Layout != "" {
+			destPath = RenderLayout(destRoot, rule.Layout, winnerMeta, winnerFile.SrcPath, p.featCredits, p.rules)
+		} else {
+			// Check if this is a true compilation (compilation flag + multiple artists)
+			isCompilation := false
+			if winnerMeta.TagCompilation {
+				isCompilation = p.isRealCompilationFast(winner.FileID, winnerMeta.TagAlbum, membersMap, metadataMap)
+			}
+			destPath = GenerateDestPathWithCredits(destRoot, winnerMeta, winnerFile.SrcPath, isCompilation, p.featCredits, p.rules)
+		}
+		if p.asciiPaths {
+			destPath = ASCIIDestPath(destRoot, destPath)
+		}
 
 		// Queue plan for winner
+		reason := fmt.Sprintf("winner (score: %.1f)", winner.QualityScore)
+		if class.Class != classify.Music {
+			reason = fmt.Sprintf("winner (score: %.1f, %s: %s)", winner.QualityScore, class.Class, class.Reason)
+		}
 		winnerPlan := &store.Plan{
 			FileID:   winner.FileID,
 			Action:   p.mode, // copy, move, etc.
 			DestPath: destPath,
-			Reason:   fmt.Sprintf("winner (score: %.1f)", winner.QualityScore),
+			Reason:   reason,
 		}
 		allPlans = append(allPlans, winnerPlan)
 
@@ -249,6 +383,7 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 	result.WinnersPlanned = int(winnersPlanned.Load())
 	result.DuplicatesSkipped = int(duplicatesSkipped.Load())
 	result.SingletonsPlanned = int(singletonsPlanned.Load())
+	result.Excluded = int(excluded.Load())
 
 	util.InfoLog("Initial planning: %d winners, %d duplicates skipped",
 		result.WinnersPlanned, result.DuplicatesSkipped)
@@ -282,12 +417,74 @@ func (p *Planner) Plan(ctx context.Context, destRoot string) (*Result, error) {
 		result.WinnersPlanned -= collisionsResolved
 	}
 
+	// Album covers from embedded artwork
+	if covers, err := p.selectCovers(); err != nil {
+		util.WarnLog("Failed to choose album covers: %v", err)
+	} else if covers > 0 {
+		util.InfoLog("Chose covers for %d albums", covers)
+	}
+
+	// All changed clusters are now planned, unless plans failed to save:
+	// then the next run plans them again
+	if len(result.Errors) > 0 {
+		util.WarnLog("Keeping changed cluster flags: %d plan batches failed to save", len(result.Errors))
+	} else {
+		if err := p.store.ClearDirtyClusters(); err != nil {
+			util.WarnLog("Failed to clear changed cluster flags: %v", err)
+		}
+		if !incremental {
+			if err := p.store.SetFingerprint(store.FingerprintPlanLayout, p.fingerprint()); err != nil {
+				util.WarnLog("Failed to save path settings: %v", err)
+			}
+		}
+	}
+
 	util.SuccessLog("Planning complete: %d winners, %d duplicates skipped (%d singletons)",
 		result.WinnersPlanned, result.DuplicatesSkipped, result.SingletonsPlanned)
 
 	return result, nil
 }
 
+// canPlanIncrementally reports whether only changed clusters need planning:
+// incremental mode is enabled, plans exist, all of them target destRoot with the
+// current mode, and they were made with the current path settings
+func (p *Planner) canPlanIncrementally(destRoot string) (bool, error) {
+	if !p.incremental {
+		return false, nil
+	}
+
+	planCount, err := p.store.CountPlans()
+	if err != nil {
+		return false, err
+	}
+	if planCount == 0 {
+		return false, nil
+	}
+
+	inconsistent, err := p.store.CountPlansInconsistentWith(filepath.Clean(destRoot), p.mode)
+	if err != nil {
+		return false, err
+	}
+	if inconsistent > 0 {
+		util.InfoLog("Destination or mode changed since last plan - re-planning all clusters")
+		return false, nil
+	}
+
+	stored, err := p.store.GetFingerprint(store.FingerprintPlanLayout)
+	if err != nil {
+		return false, err
+	}
+	if stored != p.fingerprint() {
+		util.InfoLog("Path settings changed since last plan - re-planning all clusters")
+		if p.logger != nil {
+			p.logger.LogSettingsChanged(store.FingerprintPlanLayout, "full_replan")
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
 // resolvePathCollisions detects when multiple files would be copied to the same dest_path
 // and resolves conflicts by keeping only the highest quality file
 // Handles both case-sensitive and case-insensitive filesystems
@@ -441,8 +638,9 @@ func (p *Planner) isRealCompilation(fileID int64, albumName string) bool {
 
 		// Check if same album
 		if metadata.TagAlbum == albumName && albumName != "" {
-			// Use track artist (not album artist) to detect multiple artists
-			artist := metadata.TagArtist
+			// Use primary track artist (not album artist) to detect multiple artists,
+			// so "A", "A feat. B" and "A & C" count as one
+			artist := meta.CreditsOf(metadata).Primary
 			if artist != "" {
 				artistsInAlbum[strings.ToLower(artist)] = true
 			}
@@ -466,8 +664,9 @@ func (p *Planner) isRealCompilationFast(fileID int64, albumName string, membersM
 	// Iterate through all metadata to find files with same album
 	for _, metadata := range metadataMap {
 		if metadata.TagAlbum == albumName {
-			// Use track artist (not album artist) to detect multiple artists
-			artist := metadata.TagArtist
+			// Use primary track artist (not album artist) to detect multiple artists,
+			// so "A", "A feat. B" and "A & C" count as one
+			artist := meta.CreditsOf(metadata).Primary
 			if artist != "" {
 				artistsInAlbum[strings.ToLower(artist)] = true
 			}
@@ -481,9 +680,22 @@ func (p *Planner) isRealCompilationFast(fileID int64, albumName string, membersM
 // GenerateDestPath creates a destination path for a file
 // Format: {AlbumArtist or Artist}/{Album}/{Track} - {Title}.{ext}
 // For compilations: Various Artists/{Album}/{Track} - {Artist} - {Title}.{ext}
+// Feature credits go in the title (FeatCreditsTitle)
 func GenerateDestPath(destRoot string, m *store.Metadata, srcPath string, isCompilation bool) string {
+	return GenerateDestPathWithCredits(destRoot, m, srcPath, isCompilation, FeatCreditsTitle, nil)
+}
+
+// GenerateDestPathWithCredits creates a destination path, placing feature credits by featCredits
+// Unless featCredits is FeatCreditsKeep, folders use the artists without feature credits
+// Artist names are canonicalized by rules (nil: the built-in rules)
+func GenerateDestPathWithCredits(destRoot string, m *store.Metadata, srcPath string, isCompilation bool, featCredits string, rules *meta.RuleSet) string {
+	artist, albumArtist, trackArtist, title := creditedNames(m, featCredits)
+
+	if m.HasVideo {
+		return musicVideoDestPath(destRoot, artist, title, srcPath, rules)
+	}
+
 	// Determine track artist (for filename in compilations)
-	trackArtist := m.TagArtist
 	if trackArtist == "" {
 		trackArtist = "Unknown Artist"
 	}
@@ -493,15 +705,15 @@ func GenerateDestPath(destRoot string, m *store.Metadata, srcPath string, isComp
 	if isCompilation {
 		folderArtist = "Various Artists"
 	} else {
-		folderArtist = m.TagAlbumArtist
+		folderArtist = albumArtist
 		if folderArtist == "" {
-			folderArtist = m.TagArtist
+			folderArtist = artist
 		}
 		if folderArtist == "" {
 			folderArtist = "Unknown Artist"
 		}
 		// Apply canonical capitalization for consistency
-		folderArtist = meta.CanonicalizeArtistName(folderArtist)
+		folderArtist = meta.CanonicalizeArtistNameWith(rules, folderArtist)
 	}
 	folderArtist = SanitizePathComponent(folderArtist)
 
@@ -538,12 +750,11 @@ func GenerateDestPath(destRoot string, m *store.Metadata, srcPath string, isComp
 
 	// For compilations, include artist in filename
 	if isCompilation {
-		trackArtist = meta.CanonicalizeArtistName(trackArtist)
+		trackArtist = meta.CanonicalizeArtistNameWith(rules, trackArtist)
 		filename += SanitizePathComponent(trackArtist) + " - "
 	}
 
 	// Title
-	title := m.TagTitle
 	if title == "" {
 		// Fall back to source filename without extension
 		base := filepath.Base(srcPath)
@@ -569,6 +780,75 @@ func GenerateDestPath(destRoot string, m *store.Metadata, srcPath string, isComp
 	return filepath.Join(destRoot, folderArtist, album, filename)
 }
 
+// creditedNames returns the folder artist, album artist, track artist and title
+// of m with feature credits placed by featCredits
+func creditedNames(m *store.Metadata, featCredits string) (artist, albumArtist, trackArtist, title string) {
+	artist, albumArtist, title = m.TagArtist, m.TagAlbumArtist, m.TagTitle
+	trackArtist = artist
+	if featCredits != FeatCreditsKeep {
+		credits := meta.CreditsOf(m)
+		artist = credits.Main
+		albumArtist = meta.ParseArtistCredits(m.TagAlbumArtist, "").Main
+		switch featCredits {
+		case FeatCreditsArtist:
+			trackArtist, title = credits.MainWithFeaturing(), credits.Title
+		case FeatCreditsDrop:
+			trackArtist, title = credits.Main, credits.Title
+		default:
+			trackArtist, title = credits.Main, credits.TitleWithFeaturing()
+		}
+	}
+	return artist, albumArtist, trackArtist, title
+}
+
+// MusicVideosFolder is the tree below the destination root holding music videos
+const MusicVideosFolder = "Music Videos"
+
+// musicVideoDestPath places a music video by its performing artist rather than an album:
+// Music Videos/{Artist}/{Title}.{ext}
+func musicVideoDestPath(destRoot, artist, title, srcPath string, rules *meta.RuleSet) string {
+	if artist == "" {
+		artist = "Unknown Artist"
+	}
+	artist = SanitizePathComponent(meta.CanonicalizeArtistNameWith(rules, artist))
+
+	if title == "" {
+		base := filepath.Base(srcPath)
+		title = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	filename := SanitizePathComponent(title) + strings.ToLower(filepath.Ext(srcPath))
+
+	return filepath.Join(destRoot, MusicVideosFolder, artist, filename)
+}
+
+// ASCIIDestPath transliterates the components of destPath below destRoot to
+// Latin script and folds diacritics: "Кино/Группа крови" -> "Kino/Gruppa krovi"
+// A component with letters that have no transliteration (e.g. kanji) keeps
+// its original script rather than mixing scripts: "坂本龍一" stays as it is
+func ASCIIDestPath(destRoot, destPath string) string {
+	rel, err := filepath.Rel(destRoot, destPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return destPath
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if ascii := meta.ToASCII(part); ascii != part && asciiLetters(ascii) {
+			parts[i] = SanitizePathComponent(ascii)
+		}
+	}
+	return filepath.Join(destRoot, filepath.Join(parts...))
+}
+
+// asciiLetters reports whether every letter in s is ASCII
+func asciiLetters(s string) bool {
+	for _, r := range s {
+		if r >= utf8.RuneSelf && unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // SanitizePathComponent removes illegal filesystem characters
 // Enhanced to handle special cases from real-world messy libraries:
 // - Ampersands at start (&me) are preserved
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -73,6 +74,46 @@ func TestGenerateDestPath(t *testing.T) {
 			srcPath:  "/src/unknown.mp3",
 			expected: "/dest/Unknown Artist/_Singles/unknown.mp3",
 		},
+		{
+			name:     "music video - own tree by artist",
+			destRoot: "/dest",
+			metadata: &store.Metadata{
+				TagArtist:      "Artist feat. Guest",
+				TagAlbumArtist: "Various Artists",
+				TagAlbum:       "Album",
+				TagTitle:       "Song",
+				TagTrack:       3,
+				HasVideo:       true,
+			},
+			srcPath:  "/src/Videos/Song.MKV",
+			expected: "/dest/Music Videos/Artist/Song (feat. Guest).mkv",
+		},
+		{
+			name:     "music video - no tags",
+			destRoot: "/dest",
+			metadata: &store.Metadata{HasVideo: true},
+			srcPath:  "/src/Videos/clip.mp4",
+			expected: "/dest/Music Videos/Unknown Artist/clip.mp4",
+		},
+		{
+			name:     "audio-only mp4 - regular layout",
+			destRoot: "/dest",
+			metadata: &store.Metadata{TagArtist: "Artist", TagAlbum: "Album", TagTitle: "Song", TagTrack: 1},
+			srcPath:  "/src/Album/01 Song.mp4",
+			expected: "/dest/Artist/Album/01 - Song.mp4",
+		},
+		{
+			name:     "DSD track - regular layout",
+			destRoot: "/dest",
+			metadata: &store.Metadata{
+				TagArtist: "Artist",
+				TagAlbum:  "Album",
+				TagTitle:  "Song",
+				TagTrack:  1,
+			},
+			srcPath:  "/src/01.dsf",
+			expected: "/dest/Artist/Album/01 - Song.dsf",
+		},
 		{
 			name:     "track number padding - 100+ tracks",
 			destRoot: "/dest",
@@ -149,6 +190,32 @@ func TestSanitizePathComponent(t *testing.T) {
 	}
 }
 
+func TestASCIIDestPath(t *testing.T) {
+	destRoot := filepath.Join("/dest", "Музыка")
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Кино/1988 - Группа крови/01 - Группа крови.flac", "Kino/1988 - Gruppa krovi/01 - Gruppa krovi.flac"},
+		{"Βασίλης Παπακωνσταντίνου/_Singles/02 - Αχ Ελλάδα.mp3", "Vasilis Papakonstantinou/_Singles/02 - Ach Ellada.mp3"},
+		{"Beyoncé/2008 - I Am... Sasha Fierce/03 - Halo.m4a", "Beyonce/2008 - I Am... Sasha Fierce/03 - Halo.m4a"},
+		{"Perfume/2008 - GAME/01 - ポリリズム.flac", "Perfume/2008 - GAME/01 - poririzumu.flac"},
+		{"坂本龍一/1978 - 千のナイフ/01 - 千のナイフ.flac", "坂本龍一/1978 - 千のナイフ/01 - 千のナイフ.flac"},
+		{"Кино/1988 - 千のナイフ/01 - Группа крови.flac", "Kino/1988 - 千のナイフ/01 - Gruppa krovi.flac"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			// destRoot itself is never transliterated
+			result := ASCIIDestPath(destRoot, filepath.Join(destRoot, filepath.FromSlash(tc.input)))
+			expected := filepath.Join(destRoot, filepath.FromSlash(tc.expected))
+			if result != expected {
+				t.Errorf("Expected %q, got %q", expected, result)
+			}
+		})
+	}
+}
+
 func TestSanitizePathComponentLength(t *testing.T) {
 	// Test that very long strings are truncated
 	longString := strings.Repeat("a", 250)
@@ -527,3 +594,101 @@ func TestIsRealCompilation(t *testing.T) {
 		t.Errorf("Expected isRealCompilation to return false for album with only 1 artist")
 	}
 }
+
+func TestFeatCredits(t *testing.T) {
+	metadata := &store.Metadata{
+		TagArtist: "Calvin Harris feat. Rihanna",
+		TagAlbum:  "Motion",
+		TagTitle:  "We Found Love",
+		TagTrack:  3,
+	}
+	titleFeat := &store.Metadata{
+		TagArtist: "Gorillaz",
+		TagAlbum:  "Demon Days",
+		TagTitle:  "Feel Good Inc. (feat. De La Soul)",
+		TagTrack:  6,
+	}
+
+	testCases := []struct {
+		name          string
+		metadata      *store.Metadata
+		isCompilation bool
+		featCredits   string
+		expected      string
+	}{
+		{"title: credit moves to title", metadata, false, FeatCreditsTitle, "/dest/Calvin Harris/Motion/03 - We Found Love (feat. Rihanna).mp3"},
+		{"title: credit already in title", titleFeat, false, FeatCreditsTitle, "/dest/Gorillaz/Demon Days/06 - Feel Good Inc. (feat. De La Soul).mp3"},
+		{"title: compilation", metadata, true, FeatCreditsTitle, "/dest/Various Artists/Motion/03 - Calvin Harris - We Found Love (feat. Rihanna).mp3"},
+		{"artist: compilation", titleFeat, true, FeatCreditsArtist, "/dest/Various Artists/Demon Days/06 - Gorillaz feat. De La Soul - Feel Good Inc.mp3"},
+		{"drop", metadata, false, FeatCreditsDrop, "/dest/Calvin Harris/Motion/03 - We Found Love.mp3"},
+		{"keep", metadata, false, FeatCreditsKeep, "/dest/Calvin Harris feat. Rihanna/Motion/03 - We Found Love.mp3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GenerateDestPathWithCredits("/dest", tc.metadata, "/src/song.mp3", tc.isCompilation, tc.featCredits, nil)
+			if result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestBestCover(t *testing.T) {
+	candidate := func(fileID int64, hash string, pictureType, w, h int) *store.CoverCandidate {
+		return &store.CoverCandidate{
+			DestPath: "/dest/Artist/Album/01 - Song.flac",
+			FileID:   fileID,
+			Picture:  store.Picture{Type: pictureType, Width: w, Height: h, SHA1: hash},
+		}
+	}
+	front := store.PictureFrontCover
+
+	testCases := []struct {
+		name       string
+		candidates []*store.CoverCandidate
+		wantHash   string
+	}{
+		{"largest wins", []*store.CoverCandidate{
+			candidate(1, "small", front, 600, 600),
+			candidate(2, "large", front, 1400, 1400),
+		}, "large"},
+		{"500 px minimum beats front cover", []*store.CoverCandidate{
+			candidate(1, "thumb", front, 300, 300),
+			candidate(2, "back", 4, 800, 800),
+		}, "back"},
+		{"front cover beats larger back cover", []*store.CoverCandidate{
+			candidate(1, "back", 4, 1500, 1500),
+			candidate(2, "front", front, 600, 600),
+		}, "front"},
+		{"shorter side counts for the minimum", []*store.CoverCandidate{
+			candidate(1, "banner", front, 2000, 300),
+			candidate(2, "square", front, 500, 500),
+		}, "square"},
+		{"most used among equals", []*store.CoverCandidate{
+			candidate(1, "b", front, 600, 600),
+			candidate(2, "a", front, 600, 600),
+			candidate(3, "b", front, 600, 600),
+		}, "b"},
+		{"undecodable pictures skipped", []*store.CoverCandidate{
+			candidate(1, "webp", front, 0, 0),
+			candidate(2, "tiny", front, 100, 100),
+		}, "tiny"},
+		{"nothing usable", []*store.CoverCandidate{
+			candidate(1, "webp", front, 0, 0),
+		}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := bestCover(tc.candidates)
+			gotHash := ""
+			if got != nil {
+				gotHash = got.Picture.SHA1
+			}
+			if gotHash != tc.wantHash {
+				t.Errorf("bestCover = %q, want %q", gotHash, tc.wantHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package prune
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/franz/music-janitor/internal/report"
+	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
+)
+
+// Defaults for the safety checks
+const (
+	DefaultMaxMissingRatio = 0.5
+	DefaultStatTimeout     = 10 * time.Second
+
+	// guardMinFiles is the smallest root the missing-ratio check applies to
+	guardMinFiles = 10
+)
+
+// Pruner marks files whose source path no longer exists as missing
+type Pruner struct {
+	store           *store.Store
+	logger          *report.EventLogger
+	concurrency     int
+	dryRun          bool
+	force           bool
+	maxMissingRatio float64
+	statTimeout     time.Duration
+}
+
+// Config holds pruner configuration
+type Config struct {
+	Store       *store.Store
+	Logger      *report.EventLogger
+	Concurrency int
+	DryRun      bool // Report missing files without changing the database
+
+	// Force prunes roots whose missing ratio exceeds MaxMissingRatio
+	// (unreachable roots are never pruned)
+	Force bool
+
+	// MaxMissingRatio is the share of a root's files that may be missing before
+	// the root is treated as unmounted and left alone (<=0: DefaultMaxMissingRatio)
+	MaxMissingRatio float64
+
+	// StatTimeout bounds each stat so hung network mounts don't block the run
+	// (<=0: DefaultStatTimeout)
+	StatTimeout time.Duration
+}
+
+// SkippedRoot is a root whose files were left alone
+type SkippedRoot struct {
+	Root    string // Source path ("" for files without a source)
+	Label   string // Source label
+	Files   int    // Files under the root
+	Missing int    // Files found missing (0 if the root itself was unreachable)
+	Reason  string
+}
+
+// Result summarizes a prune run
+type Result struct {
+	FilesChecked     int
+	Missing          []*store.File // Files marked missing (or that would be, in a dry run)
+	Unreachable      int           // Files whose stat failed for another reason or timed out
+	SkippedRoots     []SkippedRoot
+	PlansRemoved     int
+	ClustersRescored int // Clusters marked dirty for rescoring and replanning
+	ClustersRemoved  int // Clusters left without members
+}
+
+// New creates a new Pruner
+func New(cfg *Config) *Pruner {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = report.NullLogger()
+	}
+	maxMissingRatio := cfg.MaxMissingRatio
+	if maxMissingRatio <= 0 {
+		maxMissingRatio = DefaultMaxMissingRatio
+	}
+	statTimeout := cfg.StatTimeout
+	if statTimeout <= 0 {
+		statTimeout = DefaultStatTimeout
+	}
+	return &Pruner{
+		store:           cfg.Store,
+		logger:          logger,
+		concurrency:     concurrency,
+		dryRun:          cfg.DryRun,
+		force:           cfg.Force,
+		maxMissingRatio: maxMissingRatio,
+		statTimeout:     statTimeout,
+	}
+}
+
+// rootGroup is the set of candidate files under one source root
+type rootGroup struct {
+	root    string
+	label   string
+	files   []*store.File
+	missing []*store.File
+}
+
+// statOutcome is the result of checking one path
+type statOutcome int
+
+const (
+	statPresent statOutcome = iota
+	statMissing
+	statUnreachable
+)
+
+// Prune stats every known source path and marks files that are gone as missing
+// Files under a source root that is missing, empty or unreachable are skipped, as
+// are roots where more than MaxMissingRatio of the files are gone (unless Force),
+// so an unmounted network share doesn't mark the whole library missing.
+func (p *Pruner) Prune(ctx context.Context) (*Result, error) {
+	files, err := p.store.GetPruneCandidates()
+	if err != nil {
+		return nil, err
+	}
+	sources, err := p.store.GetSources()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	groups := groupByRoot(files, sources)
+
+	// Unreachable roots are skipped without statting their files
+	var checked []*rootGroup
+	for _, g := range groups {
+		if g.root != "" {
+			if reason := p.checkRoot(ctx, g.root); reason != "" {
+				util.WarnLog("Skipping %d files under %s: %s", len(g.files), g.root, reason)
+				result.SkippedRoots = append(result.SkippedRoots, SkippedRoot{
+					Root: g.root, Label: g.label, Files: len(g.files), Reason: reason,
+				})
+				continue
+			}
+		}
+		checked = append(checked, g)
+	}
+
+	if err := p.statFiles(ctx, checked, result); err != nil {
+		return result, err
+	}
+
+	var prune []*store.File
+	for _, g := range checked {
+		if len(g.missing) == 0 {
+			continue
+		}
+		ratio := float64(len(g.missing)) / float64(len(g.files))
+		if !p.force && len(g.files) >= guardMinFiles && ratio > p.maxMissingRatio {
+			reason := fmt.Sprintf("%.0f%% of files missing (limit %.0f%%, use --force if they were really deleted)",
+				ratio*100, p.maxMissingRatio*100)
+			util.WarnLog("Skipping %d missing files under %s: %s", len(g.missing), rootName(g), reason)
+			result.SkippedRoots = append(result.SkippedRoots, SkippedRoot{
+				Root: g.root, Label: g.label, Files: len(g.files), Missing: len(g.missing), Reason: reason,
+			})
+			continue
+		}
+		prune = append(prune, g.missing...)
+	}
+	sort.Slice(prune, func(i, j int) bool { return prune[i].SrcPath < prune[j].SrcPath })
+	result.Missing = prune
+
+	if p.dryRun || len(prune) == 0 {
+		return result, nil
+	}
+
+	ids := make([]int64, len(prune))
+	for i, file := range prune {
+		ids[i] = file.ID
+		p.logger.LogPrune(file.FileKey, file.SrcPath)
+	}
+
+	clusterKeys, plansRemoved, err := p.store.MarkFilesMissing(ids)
+	if err != nil {
+		return result, err
+	}
+	result.PlansRemoved = plansRemoved
+
+	emptied, err := p.store.DeleteEmptyClusters(clusterKeys)
+	if err != nil {
+		return result, fmt.Errorf("failed to delete empty clusters: %w", err)
+	}
+	if err := p.store.MarkClustersDirty(clusterKeys); err != nil {
+		return result, fmt.Errorf("failed to mark clusters dirty: %w", err)
+	}
+	result.ClustersRemoved = emptied
+	result.ClustersRescored = len(clusterKeys) - emptied
+
+	return result, nil
+}
+
+// groupByRoot assigns files to the source they were scanned from, falling back
+// to the longest source path containing them; files outside every source share
+// the "" root
+func groupByRoot(files []*store.File, sources []*store.Source) []*rootGroup {
+	byID := make(map[int64]*store.Source, len(sources))
+	for _, src := range sources {
+		byID[src.ID] = src
+	}
+
+	groups := make(map[string]*rootGroup)
+	var order []string
+	for _, file := range files {
+		src := byID[file.SourceID]
+		if src == nil {
+			src = containingSource(file.SrcPath, sources)
+		}
+		root, label := "", ""
+		if src != nil {
+			root, label = src.Path, src.Label
+		}
+		g, ok := groups[root]
+		if !ok {
+			g = &rootGroup{root: root, label: label}
+			groups[root] = g
+			order = append(order, root)
+		}
+		g.files = append(g.files, file)
+	}
+
+	sort.Strings(order)
+	result := make([]*rootGroup, len(order))
+	for i, root := range order {
+		result[i] = groups[root]
+	}
+	return result
+}
+
+// containingSource returns the source with the longest path containing path, or nil
+func containingSource(path string, sources []*store.Source) *store.Source {
+	var best *store.Source
+	for _, src := range sources {
+		prefix := strings.TrimSuffix(src.Path, string(filepath.Separator)) + string(filepath.Separator)
+		if strings.HasPrefix(path, prefix) && (best == nil || len(src.Path) > len(best.Path)) {
+			best = src
+		}
+	}
+	return best
+}
+
+// checkRoot returns why files under root can't be checked, or "" if it is reachable
+// An empty root is an unmounted mount point as far as pruning is concerned.
+func (p *Pruner) checkRoot(ctx context.Context, root string) string {
+	outcome, reason := p.withTimeout(ctx, func() (statOutcome, string) {
+		info, err := os.Stat(root)
+		if err != nil {
+			return statUnreachable, fmt.Sprintf("source root not accessible: %v", err)
+		}
+		if !info.IsDir() {
+			return statUnreachable, "source root is not a directory"
+		}
+		dir, err := os.Open(root)
+		if err != nil {
+			return statUnreachable, fmt.Sprintf("source root not readable: %v", err)
+		}
+		defer dir.Close()
+		if names, err := dir.Readdirnames(1); len(names) == 0 {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return statUnreachable, fmt.Sprintf("source root not readable: %v", err)
+			}
+			return statUnreachable, "source root is empty (not mounted?)"
+		}
+		return statPresent, ""
+	})
+	if outcome == statPresent {
+		return ""
+	}
+	if reason == "" {
+		reason = fmt.Sprintf("source root did not respond within %v", p.statTimeout)
+	}
+	return reason
+}
+
+// statFiles checks the files of every group with bounded concurrency,
+// collecting missing files in their group
+func (p *Pruner) statFiles(ctx context.Context, groups []*rootGroup, result *Result) error {
+	type job struct {
+		group *rootGroup
+		file  *store.File
+	}
+
+	total := 0
+	for _, g := range groups {
+		total += len(g.files)
+	}
+	if total == 0 {
+		return nil
+	}
+	util.InfoLog("Checking %d source paths (concurrency: %d)", total, p.concurrency)
+
+	var processed, unreachable atomic.Int64
+	var mu sync.Mutex
+
+	progressCtx, cancelProgress := context.WithCancel(ctx)
+	defer cancelProgress()
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-progressCtx.Done():
+				return
+			case <-ticker.C:
+				if n := processed.Load(); n > 0 {
+					util.InfoLog("Prune: %d/%d (%.1f%%)", n, total, float64(n)/float64(total)*100)
+				}
+			}
+		}
+	}()
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				switch p.statPath(ctx, j.file.SrcPath) {
+				case statMissing:
+					mu.Lock()
+					j.group.missing = append(j.group.missing, j.file)
+					mu.Unlock()
+				case statUnreachable:
+					unreachable.Add(1)
+				}
+				processed.Add(1)
+			}
+		}()
+	}
+
+feed:
+	for _, g := range groups {
+		for _, file := range g.files {
+			select {
+			case <-ctx.Done():
+				break feed
+			case jobs <- job{group: g, file: file}:
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.FilesChecked = int(processed.Load())
+	result.Unreachable = int(unreachable.Load())
+	return ctx.Err()
+}
+
+// statPath reports whether path exists; only "does not exist" counts as missing
+func (p *Pruner) statPath(ctx context.Context, path string) statOutcome {
+	outcome, _ := p.withTimeout(ctx, func() (statOutcome, string) {
+		var err error
+		if util.IsArchivePath(path) {
+			_, _, err = util.GetFileMetadata(path)
+		} else {
+			_, err = os.Lstat(path)
+		}
+		switch {
+		case err == nil:
+			return statPresent, ""
+		case errors.Is(err, fs.ErrNotExist):
+			return statMissing, ""
+		default:
+			util.DebugLog("Cannot stat %s: %v", path, err)
+			return statUnreachable, err.Error()
+		}
+	})
+	return outcome
+}
+
+// withTimeout runs check, giving up after the stat timeout
+// A stat stuck on a dead network mount can't be interrupted; its goroutine is
+// left behind and the path counts as unreachable with an empty reason.
+func (p *Pruner) withTimeout(ctx context.Context, check func() (statOutcome, string)) (statOutcome, string) {
+	type checked struct {
+		outcome statOutcome
+		reason  string
+	}
+	done := make(chan checked, 1)
+	go func() {
+		outcome, reason := check()
+		done <- checked{outcome, reason}
+	}()
+
+	timer := time.NewTimer(p.statTimeout)
+	defer timer.Stop()
+	select {
+	case c := <-done:
+		return c.outcome, c.reason
+	case <-timer.C:
+		return statUnreachable, ""
+	case <-ctx.Done():
+		return statUnreachable, ""
+	}
+}
+
+// rootName describes a group's root in log messages
+func rootName(g *rootGroup) string {
+	if g.root == "" {
+		return "files outside known sources"
+	}
+	return g.root
+}
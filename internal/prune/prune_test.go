@@ -0,0 +1,180 @@
+package prune
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// setupLibrary creates n files under a source root and records them, clustered
+// in pairs with a plan each
+func setupLibrary(t *testing.T, n int) (*store.Store, string, []*store.File) {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	root := filepath.Join(t.TempDir(), "music")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := &store.Source{Label: "music", Path: root}
+	if err := db.UpsertSource(source); err != nil {
+		t.Fatalf("failed to record source: %v", err)
+	}
+
+	var files []*store.File
+	for i := 0; i < n; i++ {
+		path := filepath.Join(root, fmt.Sprintf("%02d.mp3", i))
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		file := &store.File{FileKey: fmt.Sprintf("key-%d", i), SrcPath: path, Status: "meta_ok", SourceID: source.ID}
+		if err := db.InsertFile(file); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+		file, _ = db.GetFileByKey(file.FileKey)
+		files = append(files, file)
+
+		clusterKey := fmt.Sprintf("cluster-%d", i/2)
+		db.InsertClusterBatch([]*store.Cluster{{ClusterKey: clusterKey}})
+		db.InsertClusterMember(&store.ClusterMember{ClusterKey: clusterKey, FileID: file.ID, Preferred: i%2 == 0})
+		action := "copy"
+		if i%2 == 1 {
+			action = "skip"
+		}
+		db.InsertPlan(&store.Plan{FileID: file.ID, Action: action})
+	}
+	if err := db.ClearDirtyClusters(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db, root, files
+}
+
+func TestPrune(t *testing.T) {
+	db, _, files := setupLibrary(t, 12)
+
+	// Remove both files of cluster-0 and the loser of cluster-1
+	for _, file := range files[:3] {
+		os.Remove(file.SrcPath)
+	}
+
+	// A dry run only reports
+	result, err := New(&Config{Store: db, DryRun: true}).Prune(context.Background())
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(result.Missing) != 3 || result.FilesChecked != 12 {
+		t.Fatalf("expected 3 of 12 files missing, got %d of %d", len(result.Missing), result.FilesChecked)
+	}
+	if f, _ := db.GetFileByID(files[0].ID); f.Status != "meta_ok" {
+		t.Fatalf("dry run changed file status to %s", f.Status)
+	}
+
+	result, err = New(&Config{Store: db, Concurrency: 2}).Prune(context.Background())
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(result.Missing) != 3 || result.PlansRemoved != 3 {
+		t.Errorf("expected 3 files and 3 plans removed, got %d and %d", len(result.Missing), result.PlansRemoved)
+	}
+	if result.ClustersRemoved != 1 || result.ClustersRescored != 1 {
+		t.Errorf("expected 1 cluster removed and 1 rescored, got %d and %d", result.ClustersRemoved, result.ClustersRescored)
+	}
+
+	for _, file := range files[:3] {
+		if f, _ := db.GetFileByID(file.ID); f.Status != store.StatusMissing {
+			t.Errorf("expected %s to be missing, got %s", file.SrcPath, f.Status)
+		}
+	}
+	dirty, _ := db.GetDirtyClusters()
+	if len(dirty) != 1 || dirty[0].ClusterKey != "cluster-1" {
+		t.Errorf("expected cluster-1 to be dirty, got %v", dirty)
+	}
+	if c, _ := db.GetClusterByKey("cluster-0"); c != nil {
+		t.Errorf("expected empty cluster-0 to be deleted")
+	}
+
+	// Nothing left to prune
+	result, err = New(&Config{Store: db}).Prune(context.Background())
+	if err != nil || len(result.Missing) != 0 || result.FilesChecked != 9 {
+		t.Errorf("expected 9 files checked and none missing, got %+v (%v)", result, err)
+	}
+}
+
+func TestPruneSafety(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(root string, files []*store.File)
+		force       bool
+		wantMissing int
+		wantSkipped bool
+	}{
+		{
+			name: "unmounted root is empty",
+			setup: func(root string, files []*store.File) {
+				for _, file := range files {
+					os.Remove(file.SrcPath)
+				}
+			},
+			force:       true,
+			wantSkipped: true,
+		},
+		{
+			name: "root is gone",
+			setup: func(root string, files []*store.File) {
+				os.RemoveAll(root)
+			},
+			force:       true,
+			wantSkipped: true,
+		},
+		{
+			name: "most files gone",
+			setup: func(root string, files []*store.File) {
+				for _, file := range files[:10] {
+					os.Remove(file.SrcPath)
+				}
+			},
+			wantSkipped: true,
+		},
+		{
+			name: "most files gone with force",
+			setup: func(root string, files []*store.File) {
+				for _, file := range files[:10] {
+					os.Remove(file.SrcPath)
+				}
+			},
+			force:       true,
+			wantMissing: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, root, files := setupLibrary(t, 12)
+			tt.setup(root, files)
+
+			result, err := New(&Config{Store: db, Force: tt.force}).Prune(context.Background())
+			if err != nil {
+				t.Fatalf("prune failed: %v", err)
+			}
+			if len(result.Missing) != tt.wantMissing {
+				t.Errorf("expected %d files missing, got %d", tt.wantMissing, len(result.Missing))
+			}
+			if skipped := len(result.SkippedRoots) > 0; skipped != tt.wantSkipped {
+				t.Errorf("expected skipped root %v, got %+v", tt.wantSkipped, result.SkippedRoots)
+			}
+			if count, _ := db.CountFilesByStatus(store.StatusMissing); count != tt.wantMissing {
+				t.Errorf("expected %d missing files in the database, got %d", tt.wantMissing, count)
+			}
+		})
+	}
+}
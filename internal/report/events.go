@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -24,6 +25,9 @@ const (
 	EventConflict  EventType = "conflict"
 	EventError     EventType = "error"
 	EventAutoHeal  EventType = "auto_heal"
+	EventIntegrity EventType = "integrity"
+	EventPrune     EventType = "prune"
+	EventWatch     EventType = "watch"
 )
 
 // EventLevel represents the severity level
@@ -257,6 +261,96 @@ func (l *EventLogger) LogConflict(srcPath, destPath, reason string) error {
 	})
 }
 
+// LogIdentityConflict logs recording identifiers that disagree with each other or the heuristics
+func (l *EventLogger) LogIdentityConflict(conflictKey, kind string, identities []string, fileCount int) error {
+	return l.Log(&Event{
+		Level:      LevelWarning,
+		Event:      EventConflict,
+		ClusterKey: conflictKey,
+		Reason:     "identity_" + kind,
+		Extra: map[string]string{
+			"identities": strings.Join(identities, ","),
+			"file_count": fmt.Sprintf("%d", fileCount),
+		},
+	})
+}
+
+// LogIntegrity logs a file that failed the decode integrity check
+func (l *EventLogger) LogIntegrity(fileKey, srcPath, reason string) error {
+	return l.Log(&Event{
+		Level:   LevelWarning,
+		Event:   EventIntegrity,
+		FileKey: fileKey,
+		SrcPath: srcPath,
+		Reason:  reason,
+	})
+}
+
+// LogPrune logs a file marked missing because its source path is gone
+func (l *EventLogger) LogPrune(fileKey, srcPath string) error {
+	return l.Log(&Event{
+		Level:   LevelInfo,
+		Event:   EventPrune,
+		FileKey: fileKey,
+		SrcPath: srcPath,
+		Action:  "mark_missing",
+	})
+}
+
+// LogWatch logs a batch of settled files ingested by watch mode
+func (l *EventLogger) LogWatch(files, discovered, planned int, duration time.Duration, err error) error {
+	level := LevelInfo
+	errMsg := ""
+	if err != nil {
+		level = LevelError
+		errMsg = err.Error()
+	}
+
+	return l.Log(&Event{
+		Level:    level,
+		Event:    EventWatch,
+		Action:   "ingest",
+		Duration: duration.Milliseconds(),
+		Error:    errMsg,
+		Extra: map[string]string{
+			"files":      fmt.Sprintf("%d", files),
+			"discovered": fmt.Sprintf("%d", discovered),
+			"planned":    fmt.Sprintf("%d", planned),
+		},
+	})
+}
+
+// LogRuleChange logs a metadata field changed by a cleaning rule
+func (l *EventLogger) LogRuleChange(srcPath, rule, field, before, after string) error {
+	return l.Log(&Event{
+		Level:   LevelInfo,
+		Event:   EventAutoHeal,
+		SrcPath: srcPath,
+		Action:  "clean_metadata",
+		Reason:  "rule:" + rule,
+		Extra: map[string]string{
+			"rule":   rule,
+			"field":  field,
+			"before": before,
+			"after":  after,
+		},
+	})
+}
+
+// LogSettingsChanged logs a stage rebuilding its stored output because the
+// settings it was produced with changed
+func (l *EventLogger) LogSettingsChanged(stage, action string) error {
+	return l.Log(&Event{
+		Level:  LevelInfo,
+		Event:  EventAutoHeal,
+		Action: action,
+		Reason: "settings_changed",
+		Extra: map[string]string{
+			"stage": stage,
+		},
+	})
+}
+
 // LogError logs an error event
 func (l *EventLogger) LogError(event EventType, srcPath string, err error) error {
 	return l.Log(&Event{
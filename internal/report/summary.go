@@ -26,6 +26,7 @@ type SummaryReport struct {
 	ClustersCreated   int
 	SingletonClusters int
 	DuplicateClusters int
+	OverriddenClusters int // Clusters with manual overrides (mlc cluster ...)
 
 	// Planning statistics
 	WinnersPlanned    int
@@ -40,7 +41,9 @@ type SummaryReport struct {
 	// Details
 	TopErrors      []ErrorSummary
 	Conflicts      []ConflictInfo
+	IdentityConflicts []IdentityConflictInfo
 	DuplicateSets  []DuplicateSet
+	Sources        []store.SourceStats // Per-source contribution, highest priority first
 
 	// Metadata
 	SourcePath      string
@@ -63,12 +66,21 @@ type ConflictInfo struct {
 	Reason   string
 }
 
+// IdentityConflictInfo represents recording identifiers that disagree with each other or the heuristics
+type IdentityConflictInfo struct {
+	Key        string   // Heuristic cluster key or isrc:<ISRC>
+	Kind       string   // cluster or isrc
+	Identities []string
+	Paths      []string
+}
+
 // DuplicateSet represents a cluster of duplicate files
 type DuplicateSet struct {
 	ClusterKey string
 	Hint       string
 	Winner     DuplicateFile
 	Losers     []DuplicateFile
+	Overrides  []string // Manual override kinds applied to this cluster
 }
 
 // DuplicateFile represents a file in a duplicate set
@@ -115,6 +127,9 @@ func GenerateSummaryReport(db *store.Store, eventLogPath string) (*SummaryReport
 		}
 	}
 
+	overridden, _ := db.GetOverriddenClusterKeys()
+	report.OverriddenClusters = len(overridden)
+
 	// Gather planning statistics
 	copyPlans, _ := db.CountPlansByAction("copy")
 	movePlans, _ := db.CountPlansByAction("move")
@@ -142,12 +157,19 @@ func GenerateSummaryReport(db *store.Store, eventLogPath string) (*SummaryReport
 	// Gather top errors (top 10)
 	report.TopErrors = gatherTopErrors(db, 10)
 
+	// Gather identity conflicts found by the last clustering run
+	report.IdentityConflicts = gatherIdentityConflicts(db)
+
+	// Gather per-source contribution
+	report.Sources, _ = db.GetSourceStats()
+
 	return report, nil
 }
 
 // gatherDuplicateSets retrieves duplicate clusters with details
 func gatherDuplicateSets(db *store.Store, limit int) []DuplicateSet {
 	clusters, _ := db.GetAllClusters()
+	overridden, _ := db.GetOverriddenClusterKeys()
 	sets := make([]DuplicateSet, 0)
 
 	// Build duplicate sets
@@ -161,6 +183,7 @@ func gatherDuplicateSets(db *store.Store, limit int) []DuplicateSet {
 			ClusterKey: cluster.ClusterKey,
 			Hint:       cluster.Hint,
 			Losers:     make([]DuplicateFile, 0),
+			Overrides:  overridden[cluster.ClusterKey],
 		}
 
 		// Get details for each member
@@ -208,6 +231,30 @@ func gatherDuplicateSets(db *store.Store, limit int) []DuplicateSet {
 	return sets
 }
 
+// gatherIdentityConflicts retrieves identity conflicts with the paths of the files involved
+func gatherIdentityConflicts(db *store.Store) []IdentityConflictInfo {
+	conflicts, err := db.GetIdentityConflicts()
+	if err != nil {
+		return nil
+	}
+
+	result := make([]IdentityConflictInfo, 0, len(conflicts))
+	for _, c := range conflicts {
+		info := IdentityConflictInfo{
+			Key:        c.ConflictKey,
+			Kind:       c.Kind,
+			Identities: c.Identities,
+		}
+		for _, fileID := range c.FileIDs {
+			if file, _ := db.GetFileByID(fileID); file != nil {
+				info.Paths = append(info.Paths, file.SrcPath)
+			}
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
 // gatherTopErrors retrieves the most common errors
 func gatherTopErrors(db *store.Store, limit int) []ErrorSummary {
 	errorFiles, _ := db.GetFilesByStatus("error")
@@ -284,6 +331,9 @@ func WriteMarkdownReport(report *SummaryReport, outputPath string) error {
 		md.WriteString(fmt.Sprintf("| Total Clusters | %d |\n", report.ClustersCreated))
 		md.WriteString(fmt.Sprintf("| Unique Files (Singletons) | %d |\n", report.SingletonClusters))
 		md.WriteString(fmt.Sprintf("| Duplicate Groups | %d |\n", report.DuplicateClusters))
+		if report.OverriddenClusters > 0 {
+			md.WriteString(fmt.Sprintf("| Manual Overrides | %d |\n", report.OverriddenClusters))
+		}
 		md.WriteString("\n")
 	}
 
@@ -304,6 +354,26 @@ func WriteMarkdownReport(report *SummaryReport, outputPath string) error {
 		md.WriteString("\n")
 	}
 
+	// Sources
+	if len(report.Sources) > 0 {
+		md.WriteString("## 📂 Sources\n\n")
+		md.WriteString("| Source | Priority | Files | Winners | Duplicates Skipped | Winner Size | Executed |\n")
+		md.WriteString("|--------|----------|-------|---------|--------------------|-------------|----------|\n")
+		for _, src := range report.Sources {
+			label := fmt.Sprintf("%s (`%s`)", src.Source.Label, truncatePath(src.Source.Path, 40))
+			if src.Source.Label == src.Source.Path {
+				label = fmt.Sprintf("`%s`", truncatePath(src.Source.Path, 40))
+			}
+			if src.Unlabelled {
+				label = "*(scanned before sources were recorded)*"
+			}
+			md.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %s | %d |\n",
+				label, src.Source.Priority, src.Files, src.Winners, src.Skipped,
+				util.FormatBytes(src.WinnerBytes), src.ExecutedFiles))
+		}
+		md.WriteString("\n")
+	}
+
 	// Execution
 	if report.FilesExecuted > 0 || report.FilesFailed > 0 {
 		md.WriteString("## ⚡ Execution\n\n")
@@ -333,6 +403,10 @@ func WriteMarkdownReport(report *SummaryReport, outputPath string) error {
 
 			md.WriteString(fmt.Sprintf("**Total copies:** %d duplicates + 1 winner\n\n", len(set.Losers)))
 
+			if len(set.Overrides) > 0 {
+				md.WriteString(fmt.Sprintf("**✋ Manual override:** %s\n\n", strings.Join(set.Overrides, ", ")))
+			}
+
 			// Winner
 			md.WriteString("**✅ Winner (kept):**\n")
 			md.WriteString(fmt.Sprintf("- **Score:** %.1f\n", set.Winner.Score))
@@ -396,6 +470,23 @@ func WriteMarkdownReport(report *SummaryReport, outputPath string) error {
 		md.WriteString("\n")
 	}
 
+	// Identity conflicts
+	if len(report.IdentityConflicts) > 0 {
+		md.WriteString("## 🆔 Identity Conflicts\n\n")
+		md.WriteString("*MusicBrainz IDs or ISRCs disagree; identified files were kept apart*\n\n")
+		for _, conflict := range report.IdentityConflicts {
+			reason := "one cluster, several recordings"
+			if conflict.Kind == store.ConflictISRC {
+				reason = "ISRC tagged with several recordings"
+			}
+			md.WriteString(fmt.Sprintf("**`%s`** (%s): %s\n\n", conflict.Key, reason, strings.Join(conflict.Identities, ", ")))
+			for _, path := range conflict.Paths {
+				md.WriteString(fmt.Sprintf("- `%s`\n", truncatePath(path, 80)))
+			}
+			md.WriteString("\n")
+		}
+	}
+
 	// Footer
 	md.WriteString("---\n\n")
 	md.WriteString("*Generated by [MLC](https://github.com/franz/music-janitor) - Music Library Cleaner*\n")
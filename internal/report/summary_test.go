@@ -547,3 +547,35 @@ func setupTestData(t *testing.T, db *store.Store) {
 		}
 	}
 }
+
+func TestWriteMarkdownReport_Sources(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "summary.md")
+
+	report := &SummaryReport{
+		GeneratedAt: time.Now(),
+		Sources: []store.SourceStats{
+			{Source: store.Source{ID: 1, Label: "rips", Path: "/music/rips", Priority: 10}, Files: 40, Winners: 38, WinnerBytes: 1024 * 1024},
+			{Source: store.Source{ID: 2, Label: "phone", Path: "/music/phone"}, Files: 25, Winners: 3, Skipped: 22},
+			{Unlabelled: true, Files: 5},
+		},
+	}
+
+	if err := WriteMarkdownReport(report, outputPath); err != nil {
+		t.Fatalf("WriteMarkdownReport failed: %v", err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read report file: %v", err)
+	}
+
+	for _, expected := range []string{
+		"## 📂 Sources",
+		"| rips (`/music/rips`) | 10 | 40 | 38 | 0 | 1.0 MB | 0 |",
+		"| phone (`/music/phone`) | 0 | 25 | 3 | 22 |",
+		"scanned before sources were recorded",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Report missing %q", expected)
+		}
+	}
+}
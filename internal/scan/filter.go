@@ -0,0 +1,337 @@
+package scan
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IgnoreFileName is the name of per-folder ignore files
+const IgnoreFileName = ".mlcignore"
+
+// Rule names reported in Result.SkippedByRule besides pattern rules
+const (
+	RuleNotIncluded = "not-included"  // File matched no include pattern
+	RuleMinSize     = "min-file-size" // File smaller than the minimum size
+
+	RuleUnsupportedArchive = "unsupported-archive" // 7z, rar or compressed tar archive
+)
+
+// JunkPresets are built-in exclude patterns for files and folders that NAS
+// systems, operating systems and download clients leave in music folders
+var JunkPresets = map[string][]string{
+	"synology":   {"@eaDir/", "#recycle/", "#snapshot/", "@tmp/"},
+	"qnap":       {".@__thumb/", "@Recycle/", "@Recently-Snapshot/", ".@upload_cache/"},
+	"macos":      {"._*", ".AppleDouble/", ".AppleDB/", ".Spotlight-V100/", ".Trashes/", ".fseventsd/", ".TemporaryItems/"},
+	"windows":    {"$RECYCLE.BIN/", "System Volume Information/", "RECYCLER/"},
+	"podcasts":   {"Podcasts/"},
+	"incomplete": {"Incomplete/", ".incomplete/"},
+}
+
+// DefaultJunkPresets are the presets used when none are configured
+var DefaultJunkPresets = []string{"synology", "qnap", "macos", "windows"}
+
+// FilterConfig holds the rules of a Filter
+type FilterConfig struct {
+	Presets []string   // Junk presets to apply (nil: DefaultJunkPresets; "none" disables them)
+	Include [][]string // Include pattern lists; a file must match every non-empty list
+	Exclude []string   // Exclude patterns
+	MinSize int64      // Minimum file size in bytes (0: no minimum)
+}
+
+// Filter decides which paths below a source root are scanned
+// Patterns follow .gitignore: globs matched case-insensitively against the
+// slash-separated path relative to the root, where "*" and "?" stay within
+// one path component and "**" spans components. A pattern without a slash
+// matches a name at any depth, a pattern ending in "/" only matches folders,
+// and a pattern matching a folder matches everything below it.
+type Filter struct {
+	exclude []*rule
+	include [][]*rule
+	minSize int64
+	ignores map[string][]*rule // .mlcignore rules by folder relative to the root
+}
+
+// rule is a compiled pattern
+type rule struct {
+	name    string // Reported in skip counts, e.g. "preset:synology" or "exclude:*.wav"
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes paths (.mlcignore only)
+	dirOnly bool // "pattern/" only matches folders
+}
+
+// NewFilter compiles the patterns of cfg
+func NewFilter(cfg *FilterConfig) (*Filter, error) {
+	f := &Filter{
+		minSize: cfg.MinSize,
+		ignores: make(map[string][]*rule),
+	}
+
+	presets := cfg.Presets
+	if presets == nil {
+		presets = DefaultJunkPresets
+	}
+	for _, name := range presets {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "none" {
+			continue
+		}
+		patterns, ok := JunkPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown junk preset %q (available: %s)", name, strings.Join(JunkPresetNames(), ", "))
+		}
+		for _, pattern := range patterns {
+			r, err := newRule("preset:"+name, pattern)
+			if err != nil {
+				return nil, err
+			}
+			f.exclude = append(f.exclude, r)
+		}
+	}
+
+	for _, pattern := range cfg.Exclude {
+		r, err := newRule("exclude:"+pattern, pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, r)
+	}
+
+	for _, patterns := range cfg.Include {
+		if len(patterns) == 0 {
+			continue
+		}
+		set := make([]*rule, 0, len(patterns))
+		for _, pattern := range patterns {
+			r, err := newRule("include:"+pattern, pattern)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, r)
+		}
+		f.include = append(f.include, set)
+	}
+
+	return f, nil
+}
+
+// JunkPresetNames returns the names of the built-in junk presets, sorted
+func JunkPresetNames() []string {
+	names := make([]string, 0, len(JunkPresets))
+	for name := range JunkPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check returns the name of the rule that skips the file or folder at rel
+// (relative to the root), or "" if it is scanned
+func (f *Filter) Check(rel string, isDir bool) string {
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		return ""
+	}
+
+	for _, r := range f.exclude {
+		if r.match(rel, isDir) {
+			return r.name
+		}
+	}
+
+	if name := f.checkIgnores(rel, isDir); name != "" {
+		return name
+	}
+
+	if !isDir {
+		for _, set := range f.include {
+			if !matchAny(set, rel, false) {
+				return RuleNotIncluded
+			}
+		}
+	}
+	return ""
+}
+
+// CheckSize returns RuleMinSize if a file of size bytes is too small to scan, or ""
+func (f *Filter) CheckSize(size int64) string {
+	if f.minSize > 0 && size < f.minSize {
+		return RuleMinSize
+	}
+	return ""
+}
+
+// NeedsSize reports whether files must be stat'ed to apply the minimum size
+func (f *Filter) NeedsSize() bool {
+	return f.minSize > 0
+}
+
+// checkIgnores applies the .mlcignore files of the folders above rel, outermost
+// first; as in .gitignore the last matching pattern decides
+func (f *Filter) checkIgnores(rel string, isDir bool) string {
+	if len(f.ignores) == 0 {
+		return ""
+	}
+
+	// The root, then each folder down to rel's parent
+	var dirs []string
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	dirs = append([]string{"."}, dirs...)
+
+	skippedBy := ""
+	for _, dir := range dirs {
+		sub := rel
+		if dir != "." {
+			sub = strings.TrimPrefix(rel, dir+"/")
+		}
+		for _, r := range f.ignores[dir] {
+			if r.match(sub, isDir) {
+				if r.negate {
+					skippedBy = ""
+				} else {
+					skippedBy = r.name
+				}
+			}
+		}
+	}
+	return skippedBy
+}
+
+// LoadIgnoreFile reads the .mlcignore file of the folder at relDir below root, if there is one
+func (f *Filter) LoadIgnoreFile(root, relDir string) error {
+	relDir = filepath.ToSlash(relDir)
+	ignorePath := filepath.Join(root, filepath.FromSlash(relDir), IgnoreFileName)
+
+	file, err := os.Open(ignorePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", ignorePath, err)
+	}
+	defer file.Close()
+
+	name := IgnoreFileName
+	if relDir != "." {
+		name = relDir + "/" + IgnoreFileName
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		negate := strings.HasPrefix(line, "!")
+		pattern := strings.TrimPrefix(line, "!")
+		pattern = strings.TrimPrefix(pattern, `\`) // "\#" and "\!" escape a leading # or !
+
+		r, err := newRule(name+":"+line, pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern in %s: %w", ignorePath, err)
+		}
+		r.negate = negate
+		f.ignores[relDir] = append(f.ignores[relDir], r)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", ignorePath, err)
+	}
+	return nil
+}
+
+// newRule compiles a pattern into a named rule
+func newRule(name, pattern string) (*rule, error) {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &rule{
+		name:    name,
+		re:      re,
+		dirOnly: strings.HasSuffix(filepath.ToSlash(pattern), "/"),
+	}, nil
+}
+
+// match reports whether the rule matches the file or folder at rel
+func (r *rule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		// A file matches a folder pattern through the folders above it
+		rel = path.Dir(rel)
+		if rel == "." {
+			return false
+		}
+	}
+	return r.re.MatchString(rel)
+}
+
+// matchAny reports whether any rule matches rel
+func matchAny(rules []*rule, rel string, isDir bool) bool {
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob translates a glob pattern into a regular expression
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	glob := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(pattern), "./"), "/")
+	anchored := strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+	if glob == "" {
+		return nil, fmt.Errorf("invalid pattern %q: empty", pattern)
+	}
+
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid pattern %q: unterminated [", pattern)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("(?:/.*)?$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
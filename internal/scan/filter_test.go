@@ -0,0 +1,112 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		path     string
+		expected string
+	}{
+		{"no patterns", nil, nil, "Artist/Album/01.mp3", ""},
+		{"extension anywhere", []string{"*.flac"}, nil, "Artist/Album/01.FLAC", ""},
+		{"extension mismatch", []string{"*.flac"}, nil, "Artist/Album/01.mp3", RuleNotIncluded},
+		{"anchored folder", []string{"Rips/**"}, nil, "Rips/Artist/01.mp3", ""},
+		{"anchored folder elsewhere", []string{"Rips/**"}, nil, "Other/Rips/01.mp3", RuleNotIncluded},
+		{"folder name anywhere", nil, []string{"Incomplete"}, "Artist/Incomplete/01.mp3", "exclude:Incomplete"},
+		{"folder name prefix only", nil, []string{"Incomplete"}, "Artist/Incomplete Album/01.mp3", ""},
+		{"folder-only pattern skips files below", nil, []string{"Live/"}, "Artist/Live/01.mp3", "exclude:Live/"},
+		{"folder-only pattern ignores files", nil, []string{"Live/"}, "Artist/Live", ""},
+		{"double star in middle", nil, []string{"Artist/**/*.wav"}, "Artist/A/B/01.wav", "exclude:Artist/**/*.wav"},
+		{"double star matches no folder", nil, []string{"Artist/**/*.wav"}, "Artist/01.wav", "exclude:Artist/**/*.wav"},
+		{"single star stays in component", nil, []string{"Artist/*.wav"}, "Artist/A/01.wav", ""},
+		{"question mark", nil, []string{"0?.mp3"}, "Album/01.mp3", "exclude:0?.mp3"},
+		{"character class", nil, []string{"[!0-9]*.mp3"}, "Album/01.mp3", ""},
+		{"exclude wins over include", []string{"*.mp3"}, []string{"*live*"}, "Album/01 live.mp3", "exclude:*live*"},
+		{"non-ascii", nil, []string{"Björk"}, "Björk/Homogenic/01.mp3", "exclude:Björk"},
+		{"synology thumbnails", nil, nil, "Album/@eaDir/01.mp3/SYNOPHOTO_THUMB.mp3", "preset:synology"},
+		{"macos resource forks", nil, nil, "Album/._01.mp3", "preset:macos"},
+		{"windows recycle bin", nil, nil, "$RECYCLE.BIN/S-1-5/01.mp3", "preset:windows"},
+		{"podcasts are opt-in", nil, nil, "Podcasts/Show/01.mp3", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(&FilterConfig{Include: [][]string{tt.include}, Exclude: tt.exclude})
+			if err != nil {
+				t.Fatalf("NewFilter failed: %v", err)
+			}
+			if got := f.Check(tt.path, false); got != tt.expected {
+				t.Errorf("Check(%q) = %q, expected %q", tt.path, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewFilter(&FilterConfig{Include: [][]string{{"[abc"}}}); err == nil {
+		t.Error("expected an error for an unterminated character class")
+	}
+	if _, err := NewFilter(&FilterConfig{Presets: []string{"synology", "tivo"}}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+
+	f, _ := NewFilter(&FilterConfig{Presets: []string{"none"}, Exclude: []string{"Incomplete/"}})
+	if f.Check("Artist/Incomplete", true) == "" || f.Check(".", true) != "" || f.Check("Artist/@eaDir", true) != "" {
+		t.Error("expected only the Incomplete folder to be excluded")
+	}
+
+	// Every include list must match: global and per-source patterns
+	f, _ = NewFilter(&FilterConfig{Include: [][]string{{"*.flac"}, {"Rips/"}}})
+	if f.Check("Rips/01.flac", false) != "" || f.Check("Other/01.flac", false) != RuleNotIncluded {
+		t.Error("expected files to match both include lists")
+	}
+
+	f, _ = NewFilter(&FilterConfig{MinSize: 1024})
+	if f.CheckSize(100) != RuleMinSize || f.CheckSize(4096) != "" {
+		t.Error("expected files under 1024 bytes to be skipped")
+	}
+}
+
+func TestFilterIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "Artist"), 0755)
+	os.WriteFile(filepath.Join(root, IgnoreFileName), []byte("# Skip demos everywhere\n*demo*\n\n/Loose.mp3\n"), 0644)
+	os.WriteFile(filepath.Join(root, "Artist", IgnoreFileName), []byte("!keep demo.mp3\nBootlegs/\nLive/*\n!Live/Official/\n"), 0644)
+
+	f, err := NewFilter(&FilterConfig{})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	for _, dir := range []string{".", "Artist", "Other"} {
+		if err := f.LoadIgnoreFile(root, dir); err != nil {
+			t.Fatalf("LoadIgnoreFile(%q) failed: %v", dir, err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected string
+	}{
+		{"Other/demo 1.mp3", false, ".mlcignore:*demo*"},
+		{"Loose.mp3", false, ".mlcignore:/Loose.mp3"},
+		{"Other/Loose.mp3", false, ""},                           // Anchored to the root
+		{"Artist/keep demo.mp3", false, ""},                      // Re-included by the deeper file
+		{"Artist/Album/keep demo.mp3", false, ""},                // Unanchored negation applies at any depth
+		{"Artist/Bootlegs", true, "Artist/.mlcignore:Bootlegs/"}, // Relative to its folder
+		{"Other/Bootlegs/01.mp3", false, ""},                     // Not below Artist
+		{"Artist/other demo.mp3", false, ".mlcignore:*demo*"},
+		{"Artist/Live/Tour/01.mp3", false, "Artist/.mlcignore:Live/*"},
+		{"Artist/Live/Official/01.mp3", false, ""}, // Contents excluded, one folder re-included    // Not re-included
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Check(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+}
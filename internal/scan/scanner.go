@@ -2,10 +2,12 @@ package scan
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -30,24 +32,59 @@ var AudioExtensions = []string{
 	".aif",
 	".wma",
 	".ape",
-	".wv",  // WavPack
-	".mpc", // Musepack
+	".wv",   // WavPack
+	".mpc",  // Musepack
+	".dsf",  // DSD (Sony)
+	".dff",  // DSD (Philips DSDIFF)
+	".alac", // Raw Apple Lossless
+	".caf",  // Core Audio (usually ALAC)
+	".mka",  // Matroska audio
+	".m4b",  // Audiobook MP4
+	".tak",  // Tom's lossless Audio Kompressor
+	".w64",  // Sony Wave64
+}
+
+// Repo is the state the scanner reads and writes
+type Repo interface {
+	store.FileRepo
+	store.SourceRepo
 }
 
 // Scanner discovers audio files in a directory tree
 type Scanner struct {
-	store       *store.Store
+	store       Repo
 	extensions  map[string]bool
 	concurrency int
 	logger      *report.EventLogger
+
+	include     []string
+	exclude     []string
+	junkPresets []string
+	minFileSize int64
+
+	disableContentID bool
+	disableArchives  bool
 }
 
 // Config holds scanner configuration
 type Config struct {
-	Store          *store.Store
+	Store          Repo
 	AdditionalExts []string
 	Concurrency    int
 	Logger         *report.EventLogger
+
+	Include     []string // Glob patterns of files to scan in every source (all audio files when empty)
+	Exclude     []string // Glob patterns of files and folders to skip in every source
+	JunkPresets []string // Built-in exclude presets (nil: DefaultJunkPresets)
+	MinFileSize int64    // Skip files smaller than this many bytes (0: no minimum)
+
+	// DisableContentID skips reading the start and end of new files to
+	// relink files that were moved, copied to another disk or touched
+	DisableContentID bool
+
+	// DisableArchives skips zip and tar archives instead of scanning the audio
+	// files inside them as virtual files (archive.zip!/path/track.flac)
+	DisableArchives bool
 }
 
 // New creates a new Scanner
@@ -61,6 +98,9 @@ func New(cfg *Config) *Scanner {
 	for _, ext := range AudioExtensions {
 		extMap[strings.ToLower(ext)] = true
 	}
+	for _, ext := range util.VideoExtensions {
+		extMap[strings.ToLower(ext)] = true
+	}
 	for _, ext := range cfg.AdditionalExts {
 		extMap[strings.ToLower(ext)] = true
 	}
@@ -70,22 +110,128 @@ func New(cfg *Config) *Scanner {
 		extensions:  extMap,
 		concurrency: cfg.Concurrency,
 		logger:      cfg.Logger,
+
+		include:     cfg.Include,
+		exclude:     cfg.Exclude,
+		junkPresets: cfg.JunkPresets,
+		minFileSize: cfg.MinFileSize,
+
+		disableContentID: cfg.DisableContentID,
+		disableArchives:  cfg.DisableArchives,
 	}
 }
 
+// Source is a labelled source root to scan
+type Source struct {
+	Label    string   // Unique name; defaults to the path
+	Path     string   // Root folder
+	Priority int      // Higher priority sources win ties between equally scored duplicates
+	Include  []string // Glob patterns of files to scan (all audio files when empty)
+	Exclude  []string // Glob patterns of files and folders to skip
+}
+
 // Result represents a scan result
 type Result struct {
 	FilesDiscovered int
 	FilesSkipped    int
+	FilesRelinked   int            // New file keys relinked to an existing row by content ID
+	FilesRestored   int            // Files marked missing by prune that were found again
+	FilesExcluded   int            // Audio files skipped by filter rules
+	ArchivesScanned int            // Archives whose audio entries were scanned
+	SkippedByRule   map[string]int // Files and folders skipped, by rule name
 	Errors          []error
 }
 
 // Scan walks the source directory and discovers audio files
 func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error) {
-	util.InfoLog("Starting scan of: %s", sourcePath)
+	return s.ScanSource(ctx, &Source{Path: sourcePath})
+}
+
+// ScanSources scans several source roots and adds up their results
+func (s *Scanner) ScanSources(ctx context.Context, sources []*Source) (*Result, error) {
+	labels := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		label := sourceLabel(src)
+		if labels[label] {
+			return nil, fmt.Errorf("duplicate source label %q", label)
+		}
+		labels[label] = true
+	}
+
+	total := &Result{Errors: make([]error, 0), SkippedByRule: make(map[string]int)}
+	for _, src := range sources {
+		result, err := s.ScanSource(ctx, src)
+		if result != nil {
+			total.FilesDiscovered += result.FilesDiscovered
+			total.FilesSkipped += result.FilesSkipped
+			total.FilesRelinked += result.FilesRelinked
+			total.FilesRestored += result.FilesRestored
+			total.FilesExcluded += result.FilesExcluded
+			total.ArchivesScanned += result.ArchivesScanned
+			for rule, n := range result.SkippedByRule {
+				total.SkippedByRule[rule] += n
+			}
+			total.Errors = append(total.Errors, result.Errors...)
+		}
+		if err != nil {
+			return total, fmt.Errorf("failed to scan source %s: %w", sourceLabel(src), err)
+		}
+	}
+	return total, nil
+}
+
+// sourceLabel returns the label of a source, or its path when unlabelled
+func sourceLabel(src *Source) string {
+	if src.Label != "" {
+		return src.Label
+	}
+	return filepath.Clean(src.Path)
+}
+
+// ScanSource walks a source root and discovers audio files, recording the source on each file
+func (s *Scanner) ScanSource(ctx context.Context, src *Source) (*Result, error) {
+	util.InfoLog("Starting scan of: %s", src.Path)
+	return s.scanSource(ctx, src, nil)
+}
+
+// ScanPaths scans only the given files and folders below a source root, such as
+// the files a watcher saw change. The root's filters and the .mlcignore files
+// above each path apply as in a full scan; paths outside the root are ignored.
+func (s *Scanner) ScanPaths(ctx context.Context, src *Source, paths []string) (*Result, error) {
+	util.InfoLog("Scanning %d changed paths in: %s", len(paths), src.Path)
+	if paths == nil {
+		paths = []string{}
+	}
+	return s.scanSource(ctx, src, paths)
+}
+
+// scanSource scans a source root, or with paths non-nil only those paths below it
+func (s *Scanner) scanSource(ctx context.Context, src *Source, paths []string) (*Result, error) {
+	sourcePath := src.Path
 
 	result := &Result{
-		Errors: make([]error, 0),
+		Errors:        make([]error, 0),
+		SkippedByRule: make(map[string]int),
+	}
+
+	filter, err := NewFilter(&FilterConfig{
+		Presets: s.junkPresets,
+		Include: [][]string{s.include, src.Include},
+		Exclude: append(append([]string{}, s.exclude...), src.Exclude...),
+		MinSize: s.minFileSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patterns of source %s: %w", sourceLabel(src), err)
+	}
+
+	source := &store.Source{Label: sourceLabel(src), Path: filepath.Clean(sourcePath), Priority: src.Priority}
+	if err := s.store.UpsertSource(source); err != nil {
+		return nil, fmt.Errorf("failed to record source: %w", err)
+	}
+	if n, err := s.store.AssignSource(source.ID, source.Path); err != nil {
+		return nil, err
+	} else if n > 0 {
+		util.InfoLog("Assigned %d previously scanned files to source %s", n, source.Label)
 	}
 
 	// Pre-load existing file keys for quick duplicate detection
@@ -96,20 +242,41 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 	}
 	util.InfoLog("Loaded %d existing file keys", len(existingKeys))
 
-	// Thread-safe map for tracking existing keys
-	var keysMutex sync.RWMutex
+	missingFiles, err := s.store.GetMissingFileKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &scanState{
+		sourceID:     source.ID,
+		existingKeys: existingKeys,
+		backfill:     make(map[int64]string),
+		seenKeys:     make(map[string]bool),
+		missingFiles: missingFiles,
+	}
+	if !s.disableContentID {
+		if state.contentIndex, err = s.store.GetContentIDIndex(); err != nil {
+			return nil, fmt.Errorf("failed to load content IDs: %w", err)
+		}
+		if state.missingContentIDs, err = s.store.GetFileKeysWithoutContentID(); err != nil {
+			return nil, fmt.Errorf("failed to load file keys: %w", err)
+		}
+	}
 
 	// Channel for discovered file paths
 	filePaths := make(chan string, 100)
 
-	// Channel for new files to batch insert
+	// Channel for new files to batch insert (and relinked files to batch update)
 	newFiles := make(chan *store.File, 1000)
+	state.newFiles = newFiles
 
 	// Counters for progress reporting (using atomic for thread-safety)
 	var filesFound atomic.Int64
 	var filesProcessed atomic.Int64
 	var filesNew atomic.Int64
 	var filesSkipped atomic.Int64
+	var filesRelinked atomic.Int64
+	var filesExcluded atomic.Int64
 
 	// WaitGroup for workers
 	var wg sync.WaitGroup
@@ -188,10 +355,22 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 			if len(batch) == 0 {
 				return
 			}
-			if err := s.store.InsertFileBatch(batch); err != nil {
+			var inserts, relinks []*store.File
+			for _, file := range batch {
+				if file.ID != 0 {
+					relinks = append(relinks, file)
+				} else {
+					inserts = append(inserts, file)
+				}
+			}
+			if err := s.store.InsertFileBatch(inserts); err != nil {
 				util.ErrorLog("Failed to batch insert files: %v", err)
 				result.Errors = append(result.Errors, err)
 			}
+			if err := s.store.RelinkFileBatch(relinks); err != nil {
+				util.ErrorLog("Failed to batch relink files: %v", err)
+				result.Errors = append(result.Errors, err)
+			}
 			batch = batch[:0] // Reset batch
 		}
 
@@ -230,23 +409,28 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 				default:
 				}
 
-				isNew, err := s.processFileOptimized(path, existingKeys, &keysMutex, newFiles)
+				outcome, err := s.processFileOptimized(path, state)
 				filesProcessed.Add(1)
 
 				if err != nil {
 					util.ErrorLog("Failed to process %s: %v", path, err)
 					result.Errors = append(result.Errors, err)
-				} else if isNew {
+				} else if outcome == fileNew {
 					filesNew.Add(1)
-				} else {
+				} else if outcome == fileCached {
 					filesSkipped.Add(1)
 				}
 			}
 		}()
 	}
 
-	// Walk directory tree
-	walkErr := filepath.WalkDir(sourcePath, func(path string, d fs.DirEntry, err error) error {
+	// Walk the whole tree, or only the given paths
+	walkRoots := []string{sourcePath}
+	if paths != nil {
+		walkRoots = s.walkRoots(sourcePath, paths, filter, result)
+	}
+
+	walkFn := func(path string, d fs.DirEntry, err error) error {
 		// Check for cancellation
 		select {
 		case <-ctx.Done():
@@ -260,13 +444,82 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 			return nil // Continue walking
 		}
 
-		// Skip directories
+		rel, relErr := filepath.Rel(sourcePath, path)
+		if relErr != nil {
+			rel = path
+		}
+
+		// Skip directories, loading their .mlcignore files
 		if d.IsDir() {
+			if rule := filter.Check(rel, true); rule != "" {
+				result.SkippedByRule[rule]++
+				util.DebugLog("Excluded folder: %s (%s)", path, rule)
+				return filepath.SkipDir
+			}
+			if err := filter.LoadIgnoreFile(sourcePath, rel); err != nil {
+				util.WarnLog("%v", err)
+				result.Errors = append(result.Errors, err)
+			}
+			return nil
+		}
+
+		// Archives are scanned like folders holding their audio entries
+		if !s.disableArchives && util.IsArchive(path) {
+			if rule := filter.Check(rel, true); rule != "" {
+				result.SkippedByRule[rule]++
+				util.DebugLog("Excluded archive: %s (%s)", path, rule)
+				return nil
+			}
+			entries, err := util.ListArchive(path)
+			if err != nil {
+				util.WarnLog("Failed to read archive %s: %v", path, err)
+				result.Errors = append(result.Errors, fmt.Errorf("archive error: %s: %w", path, err))
+				return nil
+			}
+			result.ArchivesScanned++
+			for _, entry := range entries {
+				if !s.isAudioFile(entry.Name) {
+					continue
+				}
+				// Patterns see the archive as a folder: Album.zip/CD1/01.flac
+				rule := filter.Check(filepath.ToSlash(rel)+"/"+entry.Name, false)
+				if rule == "" {
+					rule = filter.CheckSize(entry.Size)
+				}
+				if rule != "" {
+					filesExcluded.Add(1)
+					result.SkippedByRule[rule]++
+					continue
+				}
+				filesFound.Add(1)
+				select {
+				case filePaths <- util.ArchiveEntryPath(path, entry.Name):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+		if !s.disableArchives && util.IsUnsupportedArchive(path) {
+			result.SkippedByRule[RuleUnsupportedArchive]++
+			util.DebugLog("Skipped archive in unsupported format: %s", path)
 			return nil
 		}
 
 		// Check if it's an audio file
 		if s.isAudioFile(path) {
+			rule := filter.Check(rel, false)
+			if rule == "" && filter.NeedsSize() {
+				if info, err := d.Info(); err == nil {
+					rule = filter.CheckSize(info.Size())
+				}
+			}
+			if rule != "" {
+				filesExcluded.Add(1)
+				result.SkippedByRule[rule]++
+				util.DebugLog("Excluded: %s (%s)", path, rule)
+				return nil
+			}
 			filesFound.Add(1)
 			select {
 			case filePaths <- path:
@@ -276,16 +529,42 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 		}
 
 		return nil
-	})
+	}
+
+	var walkErr error
+	for _, root := range walkRoots {
+		if walkErr = filepath.WalkDir(root, walkFn); walkErr != nil {
+			break
+		}
+	}
 
 	// Close channel and wait for workers
 	close(filePaths)
 	wg.Wait()
 
+	// Relink moved and touched files now that every existing file found is known
+	if ctx.Err() == nil {
+		relinked, discovered := s.resolvePending(state)
+		filesRelinked.Add(int64(relinked))
+		filesNew.Add(int64(discovered))
+	}
+
 	// Close new files channel and wait for batch writer
 	close(newFiles)
 	writerWg.Wait()
 
+	// Files pruned as missing that are back where they were
+	if err := s.store.RestoreMissingFiles(state.restored); err != nil {
+		util.ErrorLog("Failed to restore missing files: %v", err)
+		result.Errors = append(result.Errors, err)
+	}
+
+	// Record content IDs of files scanned before they were recorded
+	if err := s.store.SetContentIDs(state.backfill); err != nil {
+		util.ErrorLog("Failed to record content IDs: %v", err)
+		result.Errors = append(result.Errors, err)
+	}
+
 	cancelProgress()
 
 	// Finish progress bar
@@ -299,6 +578,9 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 	// Update result with final counts
 	result.FilesDiscovered = int(filesNew.Load())
 	result.FilesSkipped = int(filesSkipped.Load())
+	result.FilesRelinked = int(filesRelinked.Load())
+	result.FilesRestored = len(state.restored)
+	result.FilesExcluded = int(filesExcluded.Load())
 
 	if walkErr != nil && walkErr != context.Canceled {
 		return result, fmt.Errorf("walk error: %w", walkErr)
@@ -306,10 +588,81 @@ func (s *Scanner) Scan(ctx context.Context, sourcePath string) (*Result, error)
 
 	util.SuccessLog("Scan complete: %d files discovered, %d skipped, %d errors",
 		result.FilesDiscovered, result.FilesSkipped, len(result.Errors))
+	if result.FilesRelinked > 0 {
+		util.InfoLog("Relinked %d moved or touched files to their existing records", result.FilesRelinked)
+	}
+	if result.FilesRestored > 0 {
+		util.InfoLog("Restored %d files previously marked missing", result.FilesRestored)
+	}
 
 	return result, nil
 }
 
+// walkRoots returns the paths of a partial scan to walk: paths below the root
+// that still exist and aren't inside another path or an excluded folder. It
+// loads the .mlcignore files of the folders above each of them.
+func (s *Scanner) walkRoots(sourcePath string, paths []string, filter *Filter, result *Result) []string {
+	sorted := make([]string, 0, len(paths))
+	for _, p := range paths {
+		sorted = append(sorted, filepath.Clean(p))
+	}
+	sort.Strings(sorted)
+
+	loaded := make(map[string]bool)
+	var roots []string
+	for _, p := range sorted {
+		if insideAny(p, roots) {
+			continue // Walked with the folder above
+		}
+		rel, err := filepath.Rel(sourcePath, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if _, err := os.Lstat(p); err != nil {
+			continue // Gone again before it was scanned
+		}
+
+		// The folders above p, from the root down
+		var dirs []string
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			dirs = append([]string{dir}, dirs...)
+		}
+		if rel != "." {
+			dirs = append([]string{"."}, dirs...)
+		}
+
+		excluded := false
+		for _, dir := range dirs {
+			if rule := filter.Check(dir, true); rule != "" {
+				util.DebugLog("Excluded: %s (%s)", p, rule)
+				excluded = true
+				break
+			}
+			if !loaded[dir] {
+				loaded[dir] = true
+				if err := filter.LoadIgnoreFile(sourcePath, dir); err != nil {
+					util.WarnLog("%v", err)
+					result.Errors = append(result.Errors, err)
+				}
+			}
+		}
+		if !excluded {
+			roots = append(roots, p)
+		}
+	}
+	return roots
+}
+
+// insideAny reports whether p is one of dirs or below one of them
+func insideAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // processFile processes a single file and stores it in the database
 // Returns (isNew, error) where isNew indicates if the file was newly inserted
 func (s *Scanner) processFile(path string) (bool, error) {
@@ -359,30 +712,72 @@ func (s *Scanner) processFile(path string) (bool, error) {
 	return true, nil
 }
 
+// fileOutcome is what processing a file did
+type fileOutcome int
+
+const (
+	fileCached  fileOutcome = iota // File key already in the database
+	fileNew                        // New file inserted
+	filePending                    // New file matching existing content, resolved after the walk
+)
+
+// scanState is shared by the workers of one source scan
+type scanState struct {
+	sourceID int64
+	newFiles chan<- *store.File
+
+	mu                sync.RWMutex
+	existingKeys      map[string]bool
+	contentIndex      map[string][]*store.File // Existing files by content ID, for relinking
+	missingContentIDs map[string]int64         // Existing files without a content ID, by file key
+	backfill          map[int64]string         // Content IDs computed for those files, by file ID
+	seenKeys          map[string]bool          // Existing file keys found by this scan
+	missingFiles      map[string]int64         // Files marked missing by prune, by file key
+	restored          []int64                  // Missing files found again
+	pending           []*store.File            // New files that may replace an existing file
+}
+
 // processFileOptimized processes a single file using pre-loaded keys and batch inserts
-// Returns (isNew, error) where isNew indicates if the file was newly inserted
-func (s *Scanner) processFileOptimized(path string, existingKeys map[string]bool, keysMutex *sync.RWMutex, newFiles chan<- *store.File) (bool, error) {
+// New files whose content ID matches an existing file are held back for
+// resolvePending, which relinks them once the whole source has been walked.
+func (s *Scanner) processFileOptimized(path string, state *scanState) (fileOutcome, error) {
 	// Generate file key
 	fileKey, err := util.GenerateFileKey(path)
 	if err != nil {
-		return false, fmt.Errorf("failed to generate file key: %w", err)
+		return fileCached, fmt.Errorf("failed to generate file key: %w", err)
 	}
 
 	// Check if file already exists (using pre-loaded map)
-	keysMutex.RLock()
-	exists := existingKeys[fileKey]
-	keysMutex.RUnlock()
+	state.mu.RLock()
+	exists := state.existingKeys[fileKey]
+	fileID, missingContentID := state.missingContentIDs[fileKey]
+	state.mu.RUnlock()
 
 	if exists {
 		// File already scanned, skip
 		util.DebugLog("File already scanned: %s", path)
-		return false, nil
+		state.mu.Lock()
+		state.seenKeys[fileKey] = true
+		if id, ok := state.missingFiles[fileKey]; ok {
+			state.restored = append(state.restored, id)
+			delete(state.missingFiles, fileKey)
+		}
+		state.mu.Unlock()
+		if missingContentID && !s.disableContentID {
+			if contentID, err := util.GenerateContentID(path); err == nil {
+				state.mu.Lock()
+				state.backfill[fileID] = contentID
+				delete(state.missingContentIDs, fileKey)
+				state.mu.Unlock()
+			}
+		}
+		return fileCached, nil
 	}
 
 	// Get file metadata
 	size, mtime, err := util.GetFileMetadata(path)
 	if err != nil {
-		return false, fmt.Errorf("failed to get file metadata: %w", err)
+		return fileCached, fmt.Errorf("failed to get file metadata: %w", err)
 	}
 
 	// Create file record
@@ -392,23 +787,85 @@ func (s *Scanner) processFileOptimized(path string, existingKeys map[string]bool
 		SizeBytes: size,
 		MtimeUnix: mtime,
 		Status:    "discovered",
+		SourceID:  state.sourceID,
 	}
 
-	// Send to batch writer
-	newFiles <- file
+	if !s.disableContentID {
+		contentID, err := util.GenerateContentID(path)
+		if err != nil {
+			util.WarnLog("Failed to read content ID of %s: %v", path, err)
+		}
+		file.ContentID = contentID
+	}
 
 	// Add to existing keys map to prevent duplicates within same scan
-	keysMutex.Lock()
-	existingKeys[fileKey] = true
-	keysMutex.Unlock()
+	state.mu.Lock()
+	state.existingKeys[fileKey] = true
+	pending := len(state.contentIndex[file.ContentID]) > 0
+	if pending {
+		state.pending = append(state.pending, file)
+	}
+	state.mu.Unlock()
 
-	// Log scan event
+	if pending {
+		return filePending, nil
+	}
+
+	// Send to batch writer
+	state.newFiles <- file
+	s.logDiscovered(file)
+	return fileNew, nil
+}
+
+// logDiscovered logs the scan event of a new file
+func (s *Scanner) logDiscovered(file *store.File) {
 	if s.logger != nil {
-		s.logger.LogScan(fileKey, path, size)
+		s.logger.LogScan(file.FileKey, file.SrcPath, file.SizeBytes)
 	}
+	util.DebugLog("Discovered: %s (key: %s)", file.SrcPath, file.FileKey[:8])
+}
 
-	util.DebugLog("Discovered: %s (key: %s)", path, fileKey[:8])
-	return true, nil
+// resolvePending relinks held-back files to the existing file they replace and
+// sends them to the batch writer. A file replaces an existing file with the same
+// content ID that this scan did not find under its file key and that is gone
+// from its old path, or was touched in place. Anything else is a copy and gets
+// its own row. Returns the number of files relinked and discovered.
+func (s *Scanner) resolvePending(state *scanState) (relinked, discovered int) {
+	sort.Slice(state.pending, func(i, j int) bool {
+		return state.pending[i].SrcPath < state.pending[j].SrcPath
+	})
+
+	for _, file := range state.pending {
+		if previous := state.claimRelink(file); previous != nil {
+			file.ID = previous.ID
+			relinked++
+			util.DebugLog("Relinked: %s (was %s)", file.SrcPath, previous.SrcPath)
+		} else {
+			discovered++
+			s.logDiscovered(file)
+		}
+		state.newFiles <- file
+	}
+	return relinked, discovered
+}
+
+// claimRelink returns the existing file that file replaces, if any, and removes
+// it from the index so no other file can claim it. Called after the walk only.
+func (st *scanState) claimRelink(file *store.File) *store.File {
+	candidates := st.contentIndex[file.ContentID]
+	for i, candidate := range candidates {
+		if st.seenKeys[candidate.FileKey] {
+			continue // Still there (possibly renamed); file is a copy
+		}
+		if candidate.SrcPath != file.SrcPath {
+			if _, _, err := util.GetFileMetadata(candidate.SrcPath); !errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+		}
+		st.contentIndex[file.ContentID] = append(candidates[:i:i], candidates[i+1:]...)
+		return candidate
+	}
+	return nil
 }
 
 // isAudioFile checks if a file has a supported audio extension
@@ -417,6 +874,12 @@ func (s *Scanner) isAudioFile(path string) bool {
 	return s.extensions[ext]
 }
 
+// Handles reports whether a scan picks up the file at path: an audio file, or
+// an archive in a supported format unless archives are disabled
+func (s *Scanner) Handles(path string) bool {
+	return s.isAudioFile(path) || (!s.disableArchives && util.IsArchive(path))
+}
+
 // GetSupportedExtensions returns the list of supported extensions
 func (s *Scanner) GetSupportedExtensions() []string {
 	exts := make([]string, 0, len(s.extensions))
@@ -1,12 +1,15 @@
 package scan
 
 import (
+	"archive/zip"
 	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/franz/music-janitor/internal/store"
+	"github.com/franz/music-janitor/internal/util"
 )
 
 func TestIsAudioFile(t *testing.T) {
@@ -40,6 +43,34 @@ func TestIsAudioFile(t *testing.T) {
 	}
 }
 
+func TestDefaultExtensions(t *testing.T) {
+	scanner := New(&Config{})
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"Album/01.dsf", true},
+		{"Album/01.DFF", true},
+		{"Album/01.alac", true},
+		{"Album/01.caf", true},
+		{"Album/01.mka", true},
+		{"Book/Part 1.m4b", true},
+		{"Album/01.tak", true},
+		{"Album/01.w64", true},
+		{"Videos/Clip.mp4", true},
+		{"Videos/Clip.mkv", true},
+		{"Videos/Clip.avi", false},
+		{"Album/cover.jpg", false},
+	}
+
+	for _, tt := range tests {
+		if result := scanner.isAudioFile(tt.path); result != tt.expected {
+			t.Errorf("isAudioFile(%s) = %v, expected %v", tt.path, result, tt.expected)
+		}
+	}
+}
+
 func TestScannerWithRealFiles(t *testing.T) {
 	// Create temporary directory
 	tmpDir := t.TempDir()
@@ -170,3 +201,407 @@ func TestScannerIdempotency(t *testing.T) {
 		t.Errorf("Expected 1 file in database after two scans, got %d", len(files))
 	}
 }
+
+func TestScanSources(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Two source roots; the rips source skips its Incomplete folder
+	testFiles := []string{
+		filepath.Join(tmpDir, "rips", "Artist", "01 - Song.flac"),
+		filepath.Join(tmpDir, "rips", "Incomplete", "02 - Song.flac"),
+		filepath.Join(tmpDir, "phone", "Artist", "01 - Song.mp3"),
+		filepath.Join(tmpDir, "phone", "Voice Memos", "memo.m4a"),
+	}
+	for _, path := range testFiles {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	scanner := New(&Config{Store: db, Concurrency: 1})
+	sources := []*Source{
+		{Label: "rips", Path: filepath.Join(tmpDir, "rips"), Priority: 10, Exclude: []string{"Incomplete/"}},
+		{Label: "phone", Path: filepath.Join(tmpDir, "phone"), Include: []string{"Artist/**"}},
+	}
+
+	result, err := scanner.ScanSources(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.FilesDiscovered != 2 || result.FilesExcluded != 1 {
+		t.Errorf("Expected 2 files discovered and 1 excluded, got %+v", result)
+	}
+
+	recorded, err := db.GetSources()
+	if err != nil || len(recorded) != 2 {
+		t.Fatalf("Expected 2 recorded sources, got %v (%v)", recorded, err)
+	}
+	ids := map[string]int64{}
+	for _, src := range recorded {
+		ids[src.Label] = src.ID
+	}
+
+	files, _ := db.GetAllFiles()
+	for _, f := range files {
+		expected := ids["phone"]
+		if filepath.Ext(f.SrcPath) == ".flac" {
+			expected = ids["rips"]
+		}
+		if f.SourceID != expected {
+			t.Errorf("Expected %s to have source %d, got %d", f.SrcPath, expected, f.SourceID)
+		}
+	}
+
+	if _, err := scanner.ScanSources(context.Background(), []*Source{sources[0], {Label: "rips", Path: tmpDir}}); err == nil {
+		t.Error("Expected an error for duplicate source labels")
+	}
+}
+
+func TestScanSkipRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "music")
+
+	testFiles := map[string]int{
+		"Artist/01 - Song.mp3":                 1024,
+		"Artist/._01 - Song.mp3":               1024, // macOS resource fork
+		"Artist/@eaDir/01 - Song.mp3/SYNO.mp3": 1024, // Synology thumbnail folder
+		"Artist/tiny.mp3":                      10,
+		"Artist/demo.mp3":                      1024,
+		"Podcasts/Show/01.mp3":                 1024,
+		"Podcasts/Show/" + IgnoreFileName:      0,
+		"$RECYCLE.BIN/S-1-5/deleted.mp3":       1024,
+		"Artist/Album/02 - Song.flac":          1024,
+		"Artist/" + IgnoreFileName:             0,
+		"Artist/Album/Scans/cover.mp3":         1024,
+	}
+	for rel, size := range testFiles {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(root, "Artist", IgnoreFileName), []byte("demo.mp3\nScans/\n"), 0644)
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	scanner := New(&Config{
+		Store:       db,
+		Concurrency: 1,
+		JunkPresets: append([]string{"podcasts"}, DefaultJunkPresets...),
+		MinFileSize: 100,
+	})
+	result, err := scanner.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	if result.FilesDiscovered != 2 {
+		t.Errorf("Expected 2 files discovered, got %d", result.FilesDiscovered)
+	}
+	expected := map[string]int{
+		"preset:macos":               1,
+		"preset:synology":            1,
+		"preset:podcasts":            1,
+		"preset:windows":             1,
+		RuleMinSize:                  1,
+		"Artist/.mlcignore:demo.mp3": 1,
+		"Artist/.mlcignore:Scans/":   1,
+	}
+	for rule, n := range expected {
+		if result.SkippedByRule[rule] != n {
+			t.Errorf("Expected %d paths skipped by %s, got %d (%v)", n, rule, result.SkippedByRule[rule], result.SkippedByRule)
+		}
+	}
+	if len(result.SkippedByRule) != len(expected) {
+		t.Errorf("Unexpected skip rules: %v", result.SkippedByRule)
+	}
+	if result.FilesExcluded != 3 {
+		t.Errorf("Expected 3 audio files excluded, got %d", result.FilesExcluded)
+	}
+}
+
+func TestScanRelinksMovedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "music")
+	os.MkdirAll(filepath.Join(root, "Old"), 0755)
+	os.MkdirAll(filepath.Join(root, "New"), 0755)
+
+	song := filepath.Join(root, "Old", "song.flac")
+	other := filepath.Join(root, "Old", "other.flac")
+	kept := filepath.Join(root, "Old", "kept.flac")
+	os.WriteFile(song, []byte("song content"), 0644)
+	os.WriteFile(other, []byte("other content"), 0644)
+	os.WriteFile(kept, []byte("kept file content"), 0644) // Sizes differ so a reused inode can't repeat a file key
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	scanner := New(&Config{Store: db, Concurrency: 2})
+	ctx := context.Background()
+	if _, err := scanner.Scan(ctx, root); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	original, _ := db.GetFileBySrcPath(song)
+	if original == nil || original.ContentID == "" {
+		t.Fatalf("Expected song with a content ID, got %+v", original)
+	}
+	if err := db.InsertMetadata(&store.Metadata{FileID: original.ID, TagTitle: "Song"}); err != nil {
+		t.Fatalf("Failed to insert metadata: %v", err)
+	}
+	db.UpdateFileStatus(original.ID, "meta_ok", "")
+
+	// Move song to another disk (a new inode), touch other in place,
+	// and rename kept (same file key) next to a copy of it
+	moved := filepath.Join(root, "New", "song.flac")
+	os.WriteFile(moved, []byte("song content"), 0644)
+	os.Remove(song)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(other, later, later)
+	os.Rename(kept, filepath.Join(root, "New", "kept.flac"))
+	os.WriteFile(filepath.Join(root, "New", "kept copy.flac"), []byte("kept file content"), 0644)
+
+	result, err := scanner.Scan(ctx, root)
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if result.FilesRelinked != 2 || result.FilesDiscovered != 1 || result.FilesSkipped != 1 {
+		t.Errorf("Expected 2 files relinked, 1 discovered and 1 skipped, got %+v", result)
+	}
+
+	relinked, _ := db.GetFileByID(original.ID)
+	if relinked.SrcPath != moved {
+		t.Fatalf("Expected file %d to be relinked to %s, got %s", original.ID, moved, relinked.SrcPath)
+	}
+	if relinked.Status != "meta_ok" {
+		t.Errorf("Expected relinked file to keep status meta_ok, got %s", relinked.Status)
+	}
+	if m, _ := db.GetMetadata(original.ID); m == nil || m.TagTitle != "Song" {
+		t.Errorf("Expected relinked file to keep its metadata, got %+v", m)
+	}
+
+	files, _ := db.GetAllFiles()
+	if len(files) != 4 {
+		t.Errorf("Expected 4 files after rescan, got %d", len(files))
+	}
+}
+
+func TestScanBackfillsContentIDs(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "song.mp3")
+	os.WriteFile(path, []byte("content"), 0644)
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// A file scanned before content IDs were recorded
+	fileKey, _ := util.GenerateFileKey(path)
+	if err := db.InsertFile(&store.File{FileKey: fileKey, SrcPath: path, Status: "meta_ok"}); err != nil {
+		t.Fatalf("Failed to insert file: %v", err)
+	}
+
+	if _, err := New(&Config{Store: db}).Scan(context.Background(), tmpDir); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	f, _ := db.GetFileByKey(fileKey)
+	expected, _ := util.GenerateContentID(path)
+	if f.ContentID != expected {
+		t.Errorf("Expected content ID %q to be recorded, got %q", expected, f.ContentID)
+	}
+}
+
+func TestScanRestoresMissingFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "music")
+	os.MkdirAll(srcDir, 0755)
+	back := filepath.Join(srcDir, "back.mp3")
+	moved := filepath.Join(srcDir, "moved.mp3")
+	os.WriteFile(back, []byte("remounted"), 0644)
+	os.WriteFile(moved, []byte("moved to a new folder"), 0644)
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := New(&Config{Store: db}).Scan(context.Background(), srcDir); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	backKey, _ := util.GenerateFileKey(back)
+	movedKey, _ := util.GenerateFileKey(moved)
+	backFile, _ := db.GetFileByKey(backKey)
+	movedFile, _ := db.GetFileByKey(movedKey)
+
+	// Both files were pruned while unavailable; one comes back in place, the other elsewhere
+	if _, _, err := db.MarkFilesMissing([]int64{backFile.ID, movedFile.ID}); err != nil {
+		t.Fatalf("Failed to mark files missing: %v", err)
+	}
+	os.MkdirAll(filepath.Join(srcDir, "sub"), 0755)
+	if err := os.Rename(moved, filepath.Join(srcDir, "sub", "moved.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(srcDir, "sub", "moved.mp3"), time.Now(), time.Now().Add(time.Hour))
+
+	result, err := New(&Config{Store: db}).Scan(context.Background(), srcDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.FilesRestored != 1 {
+		t.Errorf("Expected 1 file restored, got %d", result.FilesRestored)
+	}
+
+	for _, id := range []int64{backFile.ID, movedFile.ID} {
+		f, _ := db.GetFileByID(id)
+		if f.Status != "discovered" {
+			t.Errorf("Expected %s to be discovered again, got %s", f.SrcPath, f.Status)
+		}
+	}
+	if missing, _ := db.CountFilesByStatus(store.StatusMissing); missing != 0 {
+		t.Errorf("Expected no missing files, got %d", missing)
+	}
+}
+
+func TestScanArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "music")
+	os.MkdirAll(root, 0755)
+
+	archive := filepath.Join(root, "Artist - Album.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, name := range []string{"CD1/01.flac", "CD1/02.flac", "Bonus/03.flac", "cover.jpg"} {
+		fw, _ := w.Create(name)
+		fw.Write([]byte("audio " + name))
+	}
+	w.Close()
+	f.Close()
+	os.WriteFile(filepath.Join(root, "Other.7z"), []byte("7z"), 0644)
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Archives can be skipped entirely
+	result, err := New(&Config{Store: db, DisableArchives: true}).Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.FilesDiscovered != 0 || result.ArchivesScanned != 0 {
+		t.Errorf("Expected archives to be ignored, got %d files from %d archives", result.FilesDiscovered, result.ArchivesScanned)
+	}
+
+	scanner := New(&Config{Store: db, Exclude: []string{"Bonus/"}})
+	result, err = scanner.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.FilesDiscovered != 2 || result.ArchivesScanned != 1 || result.FilesExcluded != 1 {
+		t.Errorf("Expected 2 files discovered in 1 archive and 1 excluded, got %d, %d and %d",
+			result.FilesDiscovered, result.ArchivesScanned, result.FilesExcluded)
+	}
+	if result.SkippedByRule[RuleUnsupportedArchive] != 1 {
+		t.Errorf("Expected the 7z archive to be reported, got %v", result.SkippedByRule)
+	}
+
+	entryPath := util.ArchiveEntryPath(archive, "CD1/01.flac")
+	key, err := util.GenerateFileKey(entryPath)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	file, _ := db.GetFileByKey(key)
+	if file == nil || file.SrcPath != entryPath {
+		t.Fatalf("Expected %s to be recorded, got %+v", entryPath, file)
+	}
+
+	// Entries are recognised on the next scan
+	result, err = scanner.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if result.FilesDiscovered != 0 || result.FilesSkipped != 2 {
+		t.Errorf("Expected 2 files skipped on rescan, got %d discovered and %d skipped", result.FilesDiscovered, result.FilesSkipped)
+	}
+}
+
+func TestScanPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "music")
+	for _, rel := range []string{
+		"Old/01.mp3",
+		"New/01.mp3",
+		"New/CD2/02.mp3",
+		"Single.mp3",
+		"Demos/demo.mp3",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("audio "+rel), 0644)
+	}
+	os.WriteFile(filepath.Join(root, IgnoreFileName), []byte("Demos/\n"), 0644)
+	outside := filepath.Join(tmpDir, "elsewhere.mp3")
+	os.WriteFile(outside, []byte("outside"), 0644)
+
+	db, err := store.Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Only the changed paths are scanned; nested and excluded paths are left out
+	scanner := New(&Config{Store: db})
+	result, err := scanner.ScanPaths(context.Background(), &Source{Path: root}, []string{
+		filepath.Join(root, "New"),
+		filepath.Join(root, "New", "CD2", "02.mp3"),
+		filepath.Join(root, "Single.mp3"),
+		filepath.Join(root, "Demos", "demo.mp3"),
+		filepath.Join(root, "Gone.mp3"),
+		outside,
+	})
+	if err != nil {
+		t.Fatalf("ScanPaths failed: %v", err)
+	}
+	if result.FilesDiscovered != 3 {
+		t.Errorf("Expected 3 files discovered, got %d", result.FilesDiscovered)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Unexpected errors: %v", result.Errors)
+	}
+
+	for rel, want := range map[string]bool{
+		"Old/01.mp3":     false,
+		"New/01.mp3":     true,
+		"New/CD2/02.mp3": true,
+		"Single.mp3":     true,
+		"Demos/demo.mp3": false,
+	} {
+		key, _ := util.GenerateFileKey(filepath.Join(root, filepath.FromSlash(rel)))
+		if file, _ := db.GetFileByKey(key); (file != nil) != want {
+			t.Errorf("Expected %s recorded: %v", rel, want)
+		}
+	}
+}
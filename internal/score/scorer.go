@@ -4,28 +4,45 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/report"
 	"github.com/franz/music-janitor/internal/store"
 	"github.com/franz/music-janitor/internal/util"
 )
 
+// Repo is the state scoring reads and writes
+type Repo interface {
+	store.FileRepo
+	store.SourceRepo
+	store.MetadataRepo
+	store.ClusterRepo
+	store.FingerprintRepo
+}
+
 // Scorer calculates quality scores for files and selects winners
 type Scorer struct {
-	store       *store.Store
+	store       Repo
 	logger      *report.EventLogger
 	forceRescore bool
+
+	sourcePriorityBonus float64
 }
 
 // Config holds scorer configuration
 type Config struct {
-	Store       *store.Store
+	Store       Repo
 	Logger      *report.EventLogger
 	ForceRescore bool // If true, re-scores even if winners already exist
+
+	// SourcePriorityBonus adds this many points per level of source priority
+	// to every score. 0 means priority only breaks ties between equal scores.
+	SourcePriorityBonus float64
 }
 
 // New creates a new Scorer
@@ -34,7 +51,25 @@ func New(cfg *Config) *Scorer {
 		store:       cfg.Store,
 		logger:      cfg.Logger,
 		forceRescore: cfg.ForceRescore,
+
+		sourcePriorityBonus: cfg.SourcePriorityBonus,
+	}
+}
+
+// fingerprint identifies the settings scores are computed with beyond the
+// files themselves: the priority of every source and its bonus
+func (s *Scorer) fingerprint(sourcePriorities map[int64]int) string {
+	ids := make([]int64, 0, len(sourcePriorities))
+	for id := range sourcePriorities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	parts := []string{fmt.Sprintf("source_priority_bonus=%g", s.sourcePriorityBonus)}
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("source%d=%d", id, sourcePriorities[id]))
 	}
+	return util.SettingsFingerprint(parts...)
 }
 
 // Result represents scoring results
@@ -51,6 +86,9 @@ type scoredMember struct {
 	file   *store.File
 	meta   *store.Metadata
 	score  float64
+	pinned bool // Manually pinned as winner (mlc cluster pin)
+
+	priority int // Priority of the source the file was scanned from
 }
 
 // Score calculates quality scores for all clustered files and selects winners
@@ -63,17 +101,52 @@ func (s *Scorer) Score(ctx context.Context) (*Result, error) {
 		return nil, fmt.Errorf("failed to count winners: %w", err)
 	}
 
+	sourcePriorities, err := s.store.GetSourcePriorities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source priorities: %w", err)
+	}
+
+	// Scores computed with other source priorities are all stale
+	fingerprint := s.fingerprint(sourcePriorities)
+	if winnersCount > 0 && !s.forceRescore {
+		stored, err := s.store.GetFingerprint(store.FingerprintScoring)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scoring settings: %w", err)
+		}
+		if stored != fingerprint {
+			util.InfoLog("Source priorities changed since the last scoring - re-scoring all clusters")
+			if err := s.markAllClustersDirty(); err != nil {
+				return nil, err
+			}
+			if s.logger != nil {
+				s.logger.LogSettingsChanged(store.FingerprintScoring, "rescore")
+			}
+		}
+	}
+
+	// Clusters to score: all of them, or only those touched by incremental clustering
+	var clusters []*store.Cluster
+
 	if winnersCount > 0 && !s.forceRescore {
-		util.InfoLog("Scoring already complete (%d winners selected)", winnersCount)
-		util.InfoLog("Use --force-recluster to re-score from scratch")
+		clusters, err = s.store.GetDirtyClusters()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get changed clusters: %w", err)
+		}
+
+		if len(clusters) == 0 {
+			util.InfoLog("Scoring already complete (%d winners selected)", winnersCount)
+			util.InfoLog("Use --force-recluster to re-score from scratch")
+
+			// Get clusters for stats
+			allClusters, _ := s.store.GetAllClusters()
+			return &Result{
+				ClustersProcessed: len(allClusters),
+				WinnersSelected:   winnersCount,
+				FilesScored:       winnersCount, // At minimum, winners were scored
+			}, nil
+		}
 
-		// Get clusters for stats
-		clusters, _ := s.store.GetAllClusters()
-		return &Result{
-			ClustersProcessed: len(clusters),
-			WinnersSelected:   winnersCount,
-			FilesScored:       winnersCount, // At minimum, winners were scored
-		}, nil
+		util.InfoLog("Incremental re-scoring: %d changed clusters", len(clusters))
 	}
 
 	if s.forceRescore && winnersCount > 0 {
@@ -98,10 +171,17 @@ func (s *Scorer) Score(ctx context.Context) (*Result, error) {
 	}
 	util.InfoLog("Loaded %d metadata records", len(metadataMap))
 
-	// Step 2: Get all clusters
-	clusters, err := s.store.GetAllClusters()
+	pinnedFiles, err := s.store.GetOverrideFileIDs(store.OverridePin)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clusters: %w", err)
+		return nil, fmt.Errorf("failed to load pinned files: %w", err)
+	}
+
+	// Step 2: Get all clusters (unless only changed clusters are rescored)
+	if clusters == nil {
+		clusters, err = s.store.GetAllClusters()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get clusters: %w", err)
+		}
 	}
 
 	if len(clusters) == 0 {
@@ -194,7 +274,8 @@ func (s *Scorer) Score(ctx context.Context) (*Result, error) {
 			}
 
 			// Calculate quality score
-			score := CalculateQualityScore(metadata, file)
+			priority := sourcePriorities[file.SourceID]
+			score := CalculateQualityScore(metadata, file) + float64(priority)*s.sourcePriorityBonus
 
 			// Queue score update
 			scoreUpdates = append(scoreUpdates, struct {
@@ -208,6 +289,9 @@ func (s *Scorer) Score(ctx context.Context) (*Result, error) {
 				file:   file,
 				meta:   metadata,
 				score:  score,
+				pinned: pinnedFiles[member.FileID],
+
+				priority: priority,
 			})
 
 			scored.Add(1)
@@ -277,12 +361,35 @@ func (s *Scorer) Score(ctx context.Context) (*Result, error) {
 	result.FilesScored = int(scored.Load())
 	result.WinnersSelected = int(winners.Load())
 
+	// Record the settings only once all scores are saved, so a failed run is re-scored in full
+	if len(result.Errors) == 0 {
+		if err := s.store.SetFingerprint(store.FingerprintScoring, fingerprint); err != nil {
+			util.WarnLog("Failed to save scoring settings: %v", err)
+		}
+	}
+
 	util.SuccessLog("Scoring complete: %d clusters processed, %d files scored, %d winners selected",
 		result.ClustersProcessed, result.FilesScored, result.WinnersSelected)
 
 	return result, nil
 }
 
+// markAllClustersDirty flags every cluster for re-scoring and re-planning
+func (s *Scorer) markAllClustersDirty() error {
+	clusters, err := s.store.GetAllClusters()
+	if err != nil {
+		return fmt.Errorf("failed to get clusters: %w", err)
+	}
+	keys := make([]string, len(clusters))
+	for i, c := range clusters {
+		keys[i] = c.ClusterKey
+	}
+	if err := s.store.MarkClustersDirty(keys); err != nil {
+		return fmt.Errorf("failed to mark clusters changed: %w", err)
+	}
+	return nil
+}
+
 // CalculateQualityScore calculates a quality score for a file
 // Higher score = better quality
 func CalculateQualityScore(m *store.Metadata, f *store.File) float64 {
@@ -290,9 +397,14 @@ func CalculateQualityScore(m *store.Metadata, f *store.File) float64 {
 
 	// 1. Codec tier scoring (largest weight)
 	score += getCodecScore(m.Codec, m.Lossless, m.BitrateKbps)
+	if strings.EqualFold(m.Codec, "mp3") {
+		score += getMP3EncoderScore(meta.ParseEncoderJSON(m.EncoderJSON), m.BitrateKbps)
+	}
 
-	// 2. Bit depth & sample rate bonuses
-	score += getBitDepthScore(m.BitDepth)
+	// 2. Bit depth & sample rate bonuses (DSD is 1-bit by design, not low quality)
+	if !isDSDCodec(m.Codec) {
+		score += getBitDepthScore(m.BitDepth)
+	}
 	score += getSampleRateScore(m.SampleRate)
 
 	// 3. Lossless verification bonus
@@ -314,9 +426,18 @@ func CalculateQualityScore(m *store.Metadata, f *store.File) float64 {
 		}
 	}
 
+	// 6. Damaged files (failed decode check) lose to any intact copy
+	if m.IntegrityStatus == store.IntegrityDamaged {
+		score += damagedPenalty
+	}
+
 	return score
 }
 
+// damagedPenalty outweighs every other scoring difference, so a damaged file only
+// wins a cluster in which every member is damaged
+const damagedPenalty = -100.0
+
 // getCodecScore returns score based on codec tier
 // Scoring philosophy: Lossless > High-bitrate AAC > High-bitrate MP3
 // Based on real-world library analysis (58% MP3, 22% M4A, 13% WAV, 4% AIFF, 2% FLAC)
@@ -329,9 +450,9 @@ func getCodecScore(codec string, lossless bool, bitrateKbps int) float64 {
 		case "flac":
 			return 45.0 // Most common lossless, excellent compression
 		case "alac":
-			return 45.0 // Apple Lossless, equivalent to FLAC
-		case "ape":
-			return 38.0 // Monkey's Audio - good but less portable
+			return 45.0 // Apple Lossless (M4A or CAF), equivalent to FLAC
+		case "ape", "tak":
+			return 38.0 // Monkey's Audio, TAK - good but less portable
 		case "wavpack", "wv":
 			return 38.0 // WavPack - good hybrid codec
 		case "tta":
@@ -340,7 +461,10 @@ func getCodecScore(codec string, lossless bool, bitrateKbps int) float64 {
 			return 35.0 // Musepack - actually lossy but high quality
 		default:
 			if strings.HasPrefix(codec, "pcm_") {
-				return 42.0 // WAV/AIFF PCM - uncompressed but larger files
+				return 42.0 // WAV/AIFF/W64 PCM - uncompressed but larger files
+			}
+			if isDSDCodec(codec) {
+				return 42.0 // DSF/DFF SACD rips - huge, few players handle them natively
 			}
 			return 35.0 // Unknown lossless
 		}
@@ -419,6 +543,48 @@ func getCodecScore(codec string, lossless bool, bitrateKbps int) float64 {
 	}
 }
 
+// Codec score an MP3 deserves for its LAME -V level, regardless of average bitrate:
+// V0 is on par with CBR 320, V2 with 256
+var mp3VBRLevelScores = []float64{22.0, 21.0, 20.0, 18.0, 17.0}
+
+// getMP3EncoderScore adjusts the bitrate-based MP3 score using the LAME/Xing info tag
+// VBR presets score by quality level instead of average bitrate, LAME encodes get a
+// small bonus over files from the old Xing encoder, and a lowpass far below what the
+// bitrate warrants (typical of transcodes and badly configured encoders) is penalized
+func getMP3EncoderScore(info *meta.EncoderInfo, bitrateKbps int) float64 {
+	if info == nil {
+		return 0.0
+	}
+
+	adjustment := 0.0
+	level := info.VBRLevel()
+	if level >= 0 && level < len(mp3VBRLevelScores) {
+		if diff := mp3VBRLevelScores[level] - getCodecScore("mp3", false, bitrateKbps); diff > 0 {
+			adjustment += diff
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(info.Encoder, "LAME"):
+		adjustment += 1.0 // Well-tuned psychoacoustics, gapless info
+	case info.Header == "Xing" && info.Encoder == "":
+		adjustment -= 2.0 // Old Xing encoder
+	}
+
+	// LAME's own lowpass is 17 kHz at 128 kbps and 19.5+ kHz for V0/320
+	highQuality := bitrateKbps >= 192 || (level >= 0 && level <= 2)
+	if info.LowpassHz > 0 && info.LowpassHz <= 16000 && highQuality {
+		adjustment -= 5.0
+	}
+
+	return adjustment
+}
+
+// isDSDCodec reports whether codec is one of ffprobe's DSD codecs
+func isDSDCodec(codec string) bool {
+	return strings.HasPrefix(strings.ToLower(codec), "dsd_")
+}
+
 // getBitDepthScore returns bonus for higher bit depth
 func getBitDepthScore(bitDepth int) float64 {
 	switch {
@@ -476,12 +642,19 @@ func getTagCompletenessScore(m *store.Metadata) float64 {
 }
 
 // selectWinner chooses the best file from scored members
-// Tie-breakers: highest score → largest file size → oldest mtime → lexical path
+// A manually pinned member always wins; otherwise
+// tie-breakers: highest score → source priority → largest file size → oldest mtime → lexical path
 func selectWinner(members []scoredMember) scoredMember {
 	if len(members) == 0 {
 		return scoredMember{}
 	}
 
+	for _, member := range members {
+		if member.pinned {
+			return member
+		}
+	}
+
 	winner := members[0]
 
 	for i := 1; i < len(members); i++ {
@@ -495,7 +668,15 @@ func selectWinner(members []scoredMember) scoredMember {
 			continue
 		}
 
-		// Tie-breaker 1: File size (larger is better for same quality)
+		// Tie-breaker 1: Source priority (higher is better)
+		if candidate.priority > winner.priority {
+			winner = candidate
+			continue
+		} else if candidate.priority < winner.priority {
+			continue
+		}
+
+		// Tie-breaker 2: File size (larger is better for same quality)
 		if candidate.file.SizeBytes > winner.file.SizeBytes {
 			winner = candidate
 			continue
@@ -503,7 +684,7 @@ func selectWinner(members []scoredMember) scoredMember {
 			continue
 		}
 
-		// Tie-breaker 2: Older file (mtime)
+		// Tie-breaker 3: Older file (mtime)
 		if candidate.file.MtimeUnix < winner.file.MtimeUnix {
 			winner = candidate
 			continue
@@ -511,7 +692,7 @@ func selectWinner(members []scoredMember) scoredMember {
 			continue
 		}
 
-		// Tie-breaker 3: Lexical path order (deterministic)
+		// Tie-breaker 4: Lexical path order (deterministic)
 		if candidate.file.SrcPath < winner.file.SrcPath {
 			winner = candidate
 		}
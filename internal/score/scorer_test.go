@@ -1,9 +1,12 @@
 package score
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/franz/music-janitor/internal/cluster"
+	"github.com/franz/music-janitor/internal/meta"
 	"github.com/franz/music-janitor/internal/store"
 )
 
@@ -54,6 +57,25 @@ func TestCalculateQualityScore(t *testing.T) {
 			expectedMax: 63.0,
 			description: "CD quality FLAC",
 		},
+		{
+			name: "DSD64 DSF with complete tags",
+			metadata: &store.Metadata{
+				Codec:      "dsd_lsbf_planar",
+				Lossless:   true,
+				BitDepth:   1,
+				SampleRate: 2822400,
+				TagArtist:  "Artist",
+				TagAlbum:   "Album",
+				TagTitle:   "Title",
+				TagTrack:   1,
+			},
+			file: &store.File{
+				SizeBytes: 200 * 1024 * 1024,
+			},
+			expectedMin: 62.0, // 42 (DSD) + 10 (lossless) + 0 (1-bit not penalized) + 5 (hi-res) + 5 (tags) + 2 (size)
+			expectedMax: 66.0,
+			description: "SACD rip",
+		},
 		{
 			name: "MP3 320 CBR with complete tags",
 			metadata: &store.Metadata{
@@ -132,6 +154,8 @@ func TestGetCodecScore(t *testing.T) {
 		{"flac", true, 0, 45.0, 45.0},     // Improved from 40
 		{"alac", true, 0, 45.0, 45.0},     // Improved from 40
 		{"pcm_s16le", true, 0, 42.0, 42.0}, // Improved from 40
+		{"dsd_lsbf_planar", true, 0, 42.0, 42.0},
+		{"tak", true, 0, 38.0, 38.0},
 		{"mp3", false, 320, 22.0, 22.0},   // Improved from 20
 		{"mp3", false, 256, 20.0, 20.0},   // Improved from 18
 		{"mp3", false, 192, 17.0, 17.0},   // Improved from 15
@@ -154,6 +178,64 @@ func TestGetCodecScore(t *testing.T) {
 	}
 }
 
+func TestGetMP3EncoderScore(t *testing.T) {
+	testCases := []struct {
+		name        string
+		info        *meta.EncoderInfo
+		bitrateKbps int
+		expected    float64
+	}{
+		{"no info tag", nil, 320, 0.0},
+		{"LAME V0 at 245 kbps average", &meta.EncoderInfo{Header: "Xing", Encoder: "LAME3.100", Method: "vbr", Preset: "V0", LowpassHz: 19500}, 245, 6.0},
+		{"LAME V2 at 190 kbps average", &meta.EncoderInfo{Header: "Xing", Encoder: "LAME3.100", Method: "vbr", Preset: "V2", LowpassHz: 18600}, 190, 8.0},
+		{"LAME preset extreme", &meta.EncoderInfo{Header: "Xing", Encoder: "LAME3.97", Method: "vbr", Preset: "extreme", LowpassHz: 19000}, 260, 3.0},
+		{"LAME CBR 320", &meta.EncoderInfo{Header: "Info", Encoder: "LAME3.100", Method: "cbr", Preset: "CBR 320", LowpassHz: 20500}, 320, 1.0},
+		{"old Xing encoder", &meta.EncoderInfo{Header: "Xing", Method: "vbr"}, 320, -2.0},
+		{"CBR 320 with 16 kHz lowpass", &meta.EncoderInfo{Header: "Info", Encoder: "LAME3.100", Method: "cbr", LowpassHz: 15500}, 320, -4.0},
+		{"low lowpass at low bitrate", &meta.EncoderInfo{Header: "Info", Encoder: "LAME3.100", Method: "cbr", LowpassHz: 15500}, 128, 1.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := getMP3EncoderScore(tc.info, tc.bitrateKbps); got != tc.expected {
+				t.Errorf("getMP3EncoderScore = %.1f, want %.1f", got, tc.expected)
+			}
+		})
+	}
+
+	// A LAME V0 file must outscore an old Xing CBR 320 and a low-passed transcode
+	v0 := &store.Metadata{Codec: "mp3", BitrateKbps: 245, SampleRate: 44100, BitDepth: 16,
+		EncoderJSON: `{"header":"Xing","encoder":"LAME3.100","method":"vbr","preset":"V0","lowpass_hz":19500}`}
+	xing := &store.Metadata{Codec: "mp3", BitrateKbps: 320, SampleRate: 44100, BitDepth: 16,
+		EncoderJSON: `{"header":"Xing","method":"vbr"}`}
+	transcode := &store.Metadata{Codec: "mp3", BitrateKbps: 320, SampleRate: 44100, BitDepth: 16,
+		EncoderJSON: `{"header":"Info","encoder":"LAME3.100","method":"cbr","lowpass_hz":16000}`}
+	f := &store.File{SizeBytes: 10 << 20}
+	v0Score := CalculateQualityScore(v0, f)
+	if xingScore := CalculateQualityScore(xing, f); v0Score <= xingScore {
+		t.Errorf("LAME V0 score %.1f should beat old Xing CBR 320 score %.1f", v0Score, xingScore)
+	}
+	if transcodeScore := CalculateQualityScore(transcode, f); v0Score <= transcodeScore {
+		t.Errorf("LAME V0 score %.1f should beat transcode score %.1f", v0Score, transcodeScore)
+	}
+}
+
+func TestCalculateQualityScoreDamaged(t *testing.T) {
+	f := &store.File{SizeBytes: 30 << 20}
+	damagedFLAC := &store.Metadata{Codec: "flac", Lossless: true, BitDepth: 24, SampleRate: 96000,
+		TagArtist: "Artist", TagTitle: "Title", TagAlbum: "Album", IntegrityStatus: store.IntegrityDamaged}
+	intactMP3 := &store.Metadata{Codec: "mp3", BitrateKbps: 128, SampleRate: 44100, IntegrityStatus: store.IntegrityOK}
+	unchecked := *damagedFLAC
+	unchecked.IntegrityStatus = ""
+
+	if d, m := CalculateQualityScore(damagedFLAC, f), CalculateQualityScore(intactMP3, f); d >= m {
+		t.Errorf("damaged FLAC score %.1f should lose to intact 128k MP3 score %.1f", d, m)
+	}
+	if d, u := CalculateQualityScore(damagedFLAC, f), CalculateQualityScore(&unchecked, f); u-d != -damagedPenalty {
+		t.Errorf("damaged penalty = %.1f, want %.1f", d-u, damagedPenalty)
+	}
+}
+
 func TestGetBitDepthScore(t *testing.T) {
 	testCases := []struct {
 		bitDepth int
@@ -302,6 +384,118 @@ func TestSelectWinnerTieBreakers(t *testing.T) {
 	}
 }
 
+func TestSelectWinnerPinned(t *testing.T) {
+	// A pinned member wins even with a lower score
+	members := []scoredMember{
+		{
+			score: 80.0,
+			file:  &store.File{ID: 1, SizeBytes: 30000, SrcPath: "/a.flac"},
+		},
+		{
+			score:  20.0,
+			file:   &store.File{ID: 2, SizeBytes: 5000, SrcPath: "/b.mp3"},
+			pinned: true,
+		},
+	}
+
+	winner := selectWinner(members)
+
+	if winner.file.ID != 2 {
+		t.Errorf("Expected pinned file ID 2 to win, got ID %d", winner.file.ID)
+	}
+}
+
+func TestSelectWinnerSourcePriority(t *testing.T) {
+	// Source priority breaks score ties before file size
+	members := []scoredMember{
+		{
+			score: 50.0,
+			file:  &store.File{ID: 1, SizeBytes: 30000, SrcPath: "/phone/a.mp3"},
+		},
+		{
+			score:    50.0,
+			file:     &store.File{ID: 2, SizeBytes: 10000, SrcPath: "/rips/a.mp3"},
+			priority: 10,
+		},
+		{
+			score: 60.0, // Higher score still wins over priority
+			file:  &store.File{ID: 3, SizeBytes: 5000, SrcPath: "/other/a.mp3"},
+		},
+	}
+
+	if winner := selectWinner(members[:2]); winner.file.ID != 2 {
+		t.Errorf("Expected higher priority file ID 2 to win the tie, got ID %d", winner.file.ID)
+	}
+	if winner := selectWinner(members); winner.file.ID != 3 {
+		t.Errorf("Expected higher score file ID 3 to win, got ID %d", winner.file.ID)
+	}
+}
+
+// TestScoreSourcePriorityChange checks that changing a source's priority
+// re-scores clusters that did not change otherwise
+func TestScoreSourcePriorityChange(t *testing.T) {
+	ctx := context.Background()
+	db := store.NewMemory()
+
+	var files []*store.File
+	for _, label := range []string{"a", "b"} {
+		src := &store.Source{Label: label, Path: "/" + label}
+		if err := db.UpsertSource(src); err != nil {
+			t.Fatalf("UpsertSource(%s) error: %v", label, err)
+		}
+		f := &store.File{FileKey: "key:" + label, SrcPath: "/" + label + "/song.mp3", SourceID: src.ID, SizeBytes: 5000000, Status: "meta_ok"}
+		if err := db.InsertFile(f); err != nil {
+			t.Fatalf("InsertFile(%s) error: %v", label, err)
+		}
+		m := &store.Metadata{FileID: f.ID, Format: "mp3", Codec: "mp3", BitrateKbps: 320, SampleRate: 44100, Channels: 2,
+			DurationMs: 200000, TagArtist: "Artist", TagAlbum: "Album", TagTitle: "Song", TagTrack: 1}
+		if err := db.InsertMetadata(m); err != nil {
+			t.Fatalf("InsertMetadata(%s) error: %v", label, err)
+		}
+		files = append(files, f)
+	}
+	if _, err := cluster.New(&cluster.Config{Store: db}).Cluster(ctx); err != nil {
+		t.Fatalf("Cluster() error: %v", err)
+	}
+
+	winner := func() int64 {
+		t.Helper()
+		if _, err := New(&Config{Store: db, SourcePriorityBonus: 5}).Score(ctx); err != nil {
+			t.Fatalf("Score() error: %v", err)
+		}
+		if err := db.ClearDirtyClusters(); err != nil {
+			t.Fatalf("ClearDirtyClusters() error: %v", err)
+		}
+		members, _ := db.GetAllClusterMembers()
+		for _, cm := range members {
+			for _, m := range cm {
+				if m.Preferred {
+					return m.FileID
+				}
+			}
+		}
+		t.Fatal("no winner selected")
+		return 0
+	}
+
+	for _, tt := range []struct {
+		name     string
+		priority int // of source b
+		want     int64
+	}{
+		{"equal priorities", 0, files[0].ID},
+		{"b preferred", 1, files[1].ID},
+		{"a preferred again", -1, files[0].ID},
+	} {
+		if err := db.UpsertSource(&store.Source{Label: "b", Path: "/b", Priority: tt.priority}); err != nil {
+			t.Fatalf("UpsertSource(b) error: %v", err)
+		}
+		if got := winner(); got != tt.want {
+			t.Errorf("%s: winner = file %d, want file %d", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestGetDurationProximityScore(t *testing.T) {
 	testCases := []struct {
 		dur1     int
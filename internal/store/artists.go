@@ -0,0 +1,62 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Artist credit roles
+const (
+	ArtistRolePrimary      = "primary"      // First credited artist; used for cluster keys
+	ArtistRoleCollaborator = "collaborator" // Equal billing: "A & B", "A x B", multi-valued ARTIST
+	ArtistRoleFeatured     = "featured"     // "A feat. B", "Title (ft. B)"
+)
+
+// ArtistCredit is one artist credited on a file
+type ArtistCredit struct {
+	Role     string
+	Position int // Order within the role, starting at 0
+	Name     string
+}
+
+// replaceArtistCredits rewrites the artist credits of a file within a transaction
+func replaceArtistCredits(tx *sql.Tx, fileID int64, credits []ArtistCredit) error {
+	if _, err := tx.Exec(`DELETE FROM metadata_artists WHERE file_id = ?`, fileID); err != nil {
+		return fmt.Errorf("failed to clear artist credits: %w", err)
+	}
+
+	for _, c := range credits {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO metadata_artists (file_id, role, position, name)
+			VALUES (?, ?, ?, ?)
+		`, fileID, c.Role, c.Position, c.Name); err != nil {
+			return fmt.Errorf("failed to insert artist credit: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetArtistCredits returns the artist credits of a file, primary first
+func (s *Store) GetArtistCredits(fileID int64) ([]ArtistCredit, error) {
+	rows, err := s.db.Query(`
+		SELECT role, position, name FROM metadata_artists
+		WHERE file_id = ?
+		ORDER BY CASE role WHEN 'primary' THEN 0 WHEN 'collaborator' THEN 1 ELSE 2 END, position
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query artist credits: %w", err)
+	}
+	defer rows.Close()
+
+	var credits []ArtistCredit
+	for rows.Next() {
+		var c ArtistCredit
+		if err := rows.Scan(&c.Role, &c.Position, &c.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan artist credit: %w", err)
+		}
+		credits = append(credits, c)
+	}
+
+	return credits, rows.Err()
+}
@@ -0,0 +1,422 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClusteredFile records the cluster key a file was assigned to and the
+// metadata version it was computed from
+type ClusteredFile struct {
+	FileID          int64
+	ClusterKey      string
+	HeuristicKey    string // Key before identifier reconciliation and overrides ("" = same as ClusterKey)
+	MetadataVersion string
+}
+
+// ClusteringCandidate is a file whose cluster key must be (re)computed,
+// either because it was never clustered or because its metadata changed
+type ClusteringCandidate struct {
+	File            *File
+	MetadataVersion string
+}
+
+// GetClusteringCandidates returns meta_ok files that are not yet clustered or
+// whose metadata version differs from the one recorded at clustering time
+func (s *Store) GetClusteringCandidates() ([]*ClusteringCandidate, error) {
+	rows, err := s.db.Query(`
+		SELECT f.id, f.file_key, f.src_path, f.size_bytes, f.mtime_unix,
+		       COALESCE(f.sha1, ''), f.status, COALESCE(f.error, ''),
+		       f.first_seen_at, f.last_update_at,
+		       CAST(f.last_update_at AS TEXT)
+		FROM files f
+		LEFT JOIN clustered_files cf ON cf.file_id = f.id
+		WHERE f.status = 'meta_ok'
+		  AND (cf.file_id IS NULL OR cf.metadata_version IS NOT CAST(f.last_update_at AS TEXT))
+		ORDER BY f.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clustering candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*ClusteringCandidate
+	for rows.Next() {
+		f := &File{}
+		c := &ClusteringCandidate{File: f}
+		err := rows.Scan(
+			&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
+			&f.SHA1, &f.Status, &f.Error,
+			&f.FirstSeenAt, &f.LastUpdate,
+			&c.MetadataVersion,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan clustering candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// GetRemovedClusteredFiles returns clustered files that should no longer be
+// part of any cluster: the file row or its metadata is gone, the file was
+// pruned as missing, or metadata extraction failed on a later rescan
+// (execution failures are not counted)
+func (s *Store) GetRemovedClusteredFiles() ([]*ClusteredFile, error) {
+	rows, err := s.db.Query(`
+		SELECT cf.file_id, cf.cluster_key, cf.metadata_version
+		FROM clustered_files cf
+		LEFT JOIN files f ON f.id = cf.file_id
+		LEFT JOIN metadata m ON m.file_id = cf.file_id
+		WHERE f.id IS NULL
+		   OR m.file_id IS NULL
+		   OR f.status IN ('discovered', 'missing')
+		   OR (f.status = 'error' AND NOT EXISTS (SELECT 1 FROM executions e WHERE e.file_id = f.id))
+		ORDER BY cf.file_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query removed clustered files: %w", err)
+	}
+	defer rows.Close()
+
+	var removed []*ClusteredFile
+	for rows.Next() {
+		var cf ClusteredFile
+		if err := rows.Scan(&cf.FileID, &cf.ClusterKey, &cf.MetadataVersion); err != nil {
+			return nil, fmt.Errorf("failed to scan clustered file: %w", err)
+		}
+		removed = append(removed, &cf)
+	}
+
+	return removed, rows.Err()
+}
+
+// GetFileClusterKeys returns the current cluster key for every clustered file
+func (s *Store) GetFileClusterKeys() (map[int64]string, error) {
+	rows, err := s.db.Query(`SELECT file_id, cluster_key FROM cluster_members`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster members: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]string)
+	for rows.Next() {
+		var fileID int64
+		var clusterKey string
+		if err := rows.Scan(&fileID, &clusterKey); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster member: %w", err)
+		}
+		result[fileID] = clusterKey
+	}
+
+	return result, rows.Err()
+}
+
+// GetClusterMemberDurations returns the duration of every clustered file (0 if unknown)
+func (s *Store) GetClusterMemberDurations() (map[int64]int, error) {
+	rows, err := s.db.Query(`
+		SELECT cm.file_id, COALESCE(m.duration_ms, 0)
+		FROM cluster_members cm
+		LEFT JOIN metadata m ON m.file_id = cm.file_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query member durations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]int)
+	for rows.Next() {
+		var fileID int64
+		var durationMs int
+		if err := rows.Scan(&fileID, &durationMs); err != nil {
+			return nil, fmt.Errorf("failed to scan member duration: %w", err)
+		}
+		result[fileID] = durationMs
+	}
+
+	return result, rows.Err()
+}
+
+// UpsertClusteredFileBatch records cluster keys and metadata versions in a single transaction
+func (s *Store) UpsertClusteredFileBatch(files []*ClusteredFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO clustered_files (file_id, cluster_key, heuristic_key, metadata_version, clustered_at)
+		VALUES (?, ?, NULLIF(?, ''), ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(file_id) DO UPDATE SET
+			cluster_key = excluded.cluster_key,
+			heuristic_key = excluded.heuristic_key,
+			metadata_version = excluded.metadata_version,
+			clustered_at = excluded.clustered_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, cf := range files {
+		if _, err := stmt.Exec(cf.FileID, cf.ClusterKey, cf.HeuristicKey, cf.MetadataVersion); err != nil {
+			return fmt.Errorf("failed to upsert clustered file %d: %w", cf.FileID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SyncClusteredFilesFromMembers rebuilds clustered_files from cluster_members
+// using each file's current metadata version (used after a full clustering run)
+func (s *Store) SyncClusteredFilesFromMembers() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM clustered_files`); err != nil {
+		return fmt.Errorf("failed to clear clustered files: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO clustered_files (file_id, cluster_key, metadata_version)
+		SELECT cm.file_id, cm.cluster_key, CAST(f.last_update_at AS TEXT)
+		FROM cluster_members cm
+		INNER JOIN files f ON f.id = cm.file_id
+	`); err != nil {
+		return fmt.Errorf("failed to sync clustered files: %w", err)
+	}
+
+	// Manually excluded files have no membership but still count as clustered
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO clustered_files (file_id, cluster_key, metadata_version)
+		SELECT o.file_id, o.cluster_key, CAST(f.last_update_at AS TEXT)
+		FROM cluster_overrides o
+		INNER JOIN files f ON f.id = o.file_id
+		WHERE o.kind = 'exclude' AND f.status = 'meta_ok'
+	`); err != nil {
+		return fmt.Errorf("failed to sync clustered files: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteClusterMembersByFileIDs removes the cluster memberships and
+// clustered_files records for the given files
+func (s *Store) DeleteClusterMembersByFileIDs(fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	memberStmt, err := tx.Prepare(`DELETE FROM cluster_members WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer memberStmt.Close()
+
+	trackStmt, err := tx.Prepare(`DELETE FROM clustered_files WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer trackStmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := memberStmt.Exec(id); err != nil {
+			return fmt.Errorf("failed to delete cluster member %d: %w", id, err)
+		}
+		if _, err := trackStmt.Exec(id); err != nil {
+			return fmt.Errorf("failed to delete clustered file %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ForgetClusteredFiles deletes clustered_files records so the next incremental
+// run recomputes the files' cluster keys from scratch
+func (s *Store) ForgetClusteredFiles(fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM clustered_files WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := stmt.Exec(id); err != nil {
+			return fmt.Errorf("failed to forget clustered file %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// EnsureClusterBatch inserts clusters that don't exist yet, leaving existing ones untouched
+func (s *Store) EnsureClusterBatch(clusters []*Cluster) error {
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO clusters (cluster_key, hint) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, cluster := range clusters {
+		if _, err := stmt.Exec(cluster.ClusterKey, cluster.Hint); err != nil {
+			return fmt.Errorf("failed to insert cluster %s: %w", cluster.ClusterKey, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteEmptyClusters removes clusters among the given keys that no longer have members
+// Returns the number of clusters deleted
+func (s *Store) DeleteEmptyClusters(clusterKeys []string) (int, error) {
+	deleted := 0
+	for _, chunk := range chunkStrings(clusterKeys, 500) {
+		result, err := s.db.Exec(`
+			DELETE FROM clusters
+			WHERE cluster_key IN (`+placeholders(len(chunk))+`)
+			  AND NOT EXISTS (SELECT 1 FROM cluster_members cm WHERE cm.cluster_key = clusters.cluster_key)
+		`, stringArgs(chunk)...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete empty clusters: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+// MarkClustersDirty flags clusters for rescoring and replanning and resets
+// their members' scores and winners
+func (s *Store) MarkClustersDirty(clusterKeys []string) error {
+	for _, chunk := range chunkStrings(clusterKeys, 500) {
+		args := stringArgs(chunk)
+		in := placeholders(len(chunk))
+		if _, err := s.db.Exec(`UPDATE clusters SET dirty = 1 WHERE cluster_key IN (`+in+`)`, args...); err != nil {
+			return fmt.Errorf("failed to mark clusters dirty: %w", err)
+		}
+		if _, err := s.db.Exec(`UPDATE cluster_members SET quality_score = 0.0, preferred = 0 WHERE cluster_key IN (`+in+`)`, args...); err != nil {
+			return fmt.Errorf("failed to reset scores for dirty clusters: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetDirtyClusters returns clusters flagged for rescoring and replanning
+func (s *Store) GetDirtyClusters() ([]*Cluster, error) {
+	rows, err := s.db.Query(`
+		SELECT cluster_key, COALESCE(hint, '')
+		FROM clusters
+		WHERE dirty = 1
+		ORDER BY cluster_key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clusters []*Cluster
+	for rows.Next() {
+		var c Cluster
+		if err := rows.Scan(&c.ClusterKey, &c.Hint); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, &c)
+	}
+
+	return clusters, rows.Err()
+}
+
+// ClearDirtyClusters resets the dirty flag on all clusters (called after planning)
+func (s *Store) ClearDirtyClusters() error {
+	_, err := s.db.Exec(`UPDATE clusters SET dirty = 0 WHERE dirty = 1`)
+	if err != nil {
+		return fmt.Errorf("failed to clear dirty clusters: %w", err)
+	}
+	return nil
+}
+
+// chunkStrings splits values into chunks of at most size elements
+// (keeps IN (...) lists below SQLite's variable limit)
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for i := 0; i < len(values); i += size {
+		end := i + size
+		if end > len(values) {
+			end = len(values)
+		}
+		chunks = append(chunks, values[i:end])
+	}
+	return chunks
+}
+
+// placeholders returns a comma-separated list of n "?" placeholders
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -29,13 +29,21 @@ func (s *Store) InsertClusterMember(member *ClusterMember) error {
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO cluster_members (cluster_key, file_id, quality_score, preferred)
-		VALUES (?, ?, ?, ?)
-	`, member.ClusterKey, member.FileID, member.QualityScore, preferred)
+		INSERT INTO cluster_members (cluster_key, file_id, quality_score, preferred, confidence)
+		VALUES (?, ?, ?, ?, ?)
+	`, member.ClusterKey, member.FileID, member.QualityScore, preferred, memberConfidence(member))
 
 	return err
 }
 
+// memberConfidence returns the confidence to store for a member (unset means exact match)
+func memberConfidence(member *ClusterMember) float64 {
+	if member.Confidence <= 0 {
+		return 1.0
+	}
+	return member.Confidence
+}
+
 // UpdateClusterMemberScore updates the quality score for a cluster member
 func (s *Store) UpdateClusterMemberScore(clusterKey string, fileID int64, score float64) error {
 	_, err := s.db.Exec(`
@@ -132,7 +140,7 @@ func (s *Store) BatchUpdateClusterMemberPreferred(updates []struct {
 // GetClusterMembers returns all members of a cluster
 func (s *Store) GetClusterMembers(clusterKey string) ([]*ClusterMember, error) {
 	rows, err := s.db.Query(`
-		SELECT cluster_key, file_id, quality_score, preferred
+		SELECT cluster_key, file_id, quality_score, preferred, COALESCE(confidence, 1.0)
 		FROM cluster_members
 		WHERE cluster_key = ?
 		ORDER BY quality_score DESC, file_id ASC
@@ -147,7 +155,7 @@ func (s *Store) GetClusterMembers(clusterKey string) ([]*ClusterMember, error) {
 		var m ClusterMember
 		var preferredInt int
 
-		err := rows.Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt)
+		err := rows.Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt, &m.Confidence)
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +211,7 @@ func (s *Store) GetClusterByKey(clusterKey string) (*Cluster, error) {
 // GetAllClusterMembers returns all cluster members as a map indexed by cluster_key
 func (s *Store) GetAllClusterMembers() (map[string][]*ClusterMember, error) {
 	rows, err := s.db.Query(`
-		SELECT cluster_key, file_id, quality_score, preferred
+		SELECT cluster_key, file_id, quality_score, preferred, COALESCE(confidence, 1.0)
 		FROM cluster_members
 		ORDER BY cluster_key, preferred DESC, quality_score DESC
 	`)
@@ -217,7 +225,7 @@ func (s *Store) GetAllClusterMembers() (map[string][]*ClusterMember, error) {
 		var m ClusterMember
 		var preferredInt int
 
-		err := rows.Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt)
+		err := rows.Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt, &m.Confidence)
 		if err != nil {
 			return nil, err
 		}
@@ -241,6 +249,15 @@ func (s *Store) ClearClusters() error {
 		return fmt.Errorf("failed to clear clusters: %w", err)
 	}
 
+	// Forget which files were clustered so they are all picked up again
+	if _, err := s.db.Exec(`DELETE FROM clustered_files`); err != nil {
+		return fmt.Errorf("failed to clear clustered files: %w", err)
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM identity_conflicts`); err != nil {
+		return fmt.Errorf("failed to clear identity conflicts: %w", err)
+	}
+
 	return nil
 }
 
@@ -255,16 +272,14 @@ func (s *Store) CountClusters() (int, error) {
 func (s *Store) CountDuplicateClusters() (int, error) {
 	var count int
 	err := s.db.QueryRow(`
-		SELECT COUNT(DISTINCT cluster_key)
-		FROM cluster_members
-		GROUP BY cluster_key
-		HAVING COUNT(*) > 1
+		SELECT COUNT(*) FROM (
+			SELECT cluster_key
+			FROM cluster_members
+			GROUP BY cluster_key
+			HAVING COUNT(*) > 1
+		)
 	`).Scan(&count)
 
-	if err == sql.ErrNoRows {
-		return 0, nil
-	}
-
 	return count, err
 }
 
@@ -290,10 +305,10 @@ func (s *Store) GetClusterMember(clusterKey string, fileID int64) (*ClusterMembe
 	var preferredInt int
 
 	err := s.db.QueryRow(`
-		SELECT cluster_key, file_id, quality_score, preferred
+		SELECT cluster_key, file_id, quality_score, preferred, COALESCE(confidence, 1.0)
 		FROM cluster_members
 		WHERE cluster_key = ? AND file_id = ?
-	`, clusterKey, fileID).Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt)
+	`, clusterKey, fileID).Scan(&m.ClusterKey, &m.FileID, &m.QualityScore, &preferredInt, &m.Confidence)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -350,7 +365,7 @@ func (s *Store) InsertClusterMemberBatch(members []*ClusterMember) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT INTO cluster_members (cluster_key, file_id, quality_score, preferred) VALUES (?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(`INSERT INTO cluster_members (cluster_key, file_id, quality_score, preferred, confidence) VALUES (?, ?, ?, ?, ?)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
@@ -361,7 +376,7 @@ func (s *Store) InsertClusterMemberBatch(members []*ClusterMember) error {
 		if member.Preferred {
 			preferred = 1
 		}
-		if _, err := stmt.Exec(member.ClusterKey, member.FileID, member.QualityScore, preferred); err != nil {
+		if _, err := stmt.Exec(member.ClusterKey, member.FileID, member.QualityScore, preferred, memberConfidence(member)); err != nil {
 			return fmt.Errorf("failed to insert cluster member: %w", err)
 		}
 	}
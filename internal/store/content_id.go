@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// nullableString stores empty strings as NULL
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// GetContentIDIndex returns the files that have a content ID, keyed by content ID
+// Only ID, FileKey, SrcPath and ContentID are set.
+func (s *Store) GetContentIDIndex() (map[string][]*File, error) {
+	rows, err := s.db.Query(`
+		SELECT id, file_key, src_path, content_id
+		FROM files
+		WHERE content_id IS NOT NULL
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query content IDs: %w", err)
+	}
+	defer rows.Close()
+
+	index := make(map[string][]*File)
+	for rows.Next() {
+		f := &File{}
+		if err := rows.Scan(&f.ID, &f.FileKey, &f.SrcPath, &f.ContentID); err != nil {
+			return nil, fmt.Errorf("failed to scan content ID: %w", err)
+		}
+		index[f.ContentID] = append(index[f.ContentID], f)
+	}
+
+	return index, rows.Err()
+}
+
+// GetFileKeysWithoutContentID returns the IDs of files scanned before content IDs
+// were recorded, keyed by file key
+func (s *Store) GetFileKeysWithoutContentID() (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT file_key, id FROM files WHERE content_id IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file keys: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var fileKey string
+		var id int64
+		if err := rows.Scan(&fileKey, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan file key: %w", err)
+		}
+		result[fileKey] = id
+	}
+
+	return result, rows.Err()
+}
+
+// SetContentIDs records content IDs, keyed by file ID, in a single transaction
+func (s *Store) SetContentIDs(contentIDs map[int64]string) error {
+	if len(contentIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE files SET content_id = ? WHERE id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for id, contentID := range contentIDs {
+		if _, err := stmt.Exec(contentID, id); err != nil {
+			return fmt.Errorf("failed to set content ID of file %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RelinkFileBatch points existing file rows at new locations in a single transaction
+// Each file's ID selects the row; its file key, path, size, mtime, source and
+// content ID are updated while status, metadata, clusters and executions are kept.
+// Missing files found again go back to discovered.
+func (s *Store) RelinkFileBatch(files []*File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE files SET
+			file_key = ?,
+			src_path = ?,
+			size_bytes = ?,
+			mtime_unix = ?,
+			source_id = COALESCE(?, source_id),
+			content_id = COALESCE(?, content_id),
+			status = CASE WHEN status = 'missing' THEN 'discovered' ELSE status END,
+			last_update_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, f := range files {
+		if _, err := stmt.Exec(f.FileKey, f.SrcPath, f.SizeBytes, f.MtimeUnix,
+			nullableSourceID(f.SourceID), nullableString(f.ContentID), f.ID); err != nil {
+			return fmt.Errorf("failed to relink file %d: %w", f.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
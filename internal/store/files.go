@@ -9,14 +9,16 @@ import (
 // InsertFile inserts or updates a file record
 func (s *Store) InsertFile(f *File) error {
 	result, err := s.db.Exec(`
-		INSERT INTO files (file_key, src_path, size_bytes, mtime_unix, status)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO files (file_key, src_path, size_bytes, mtime_unix, status, source_id, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(file_key) DO UPDATE SET
 			src_path = excluded.src_path,
 			size_bytes = excluded.size_bytes,
 			mtime_unix = excluded.mtime_unix,
+			source_id = COALESCE(excluded.source_id, files.source_id),
+			content_id = COALESCE(excluded.content_id, files.content_id),
 			last_update_at = CURRENT_TIMESTAMP
-		`, f.FileKey, f.SrcPath, f.SizeBytes, f.MtimeUnix, f.Status)
+		`, f.FileKey, f.SrcPath, f.SizeBytes, f.MtimeUnix, f.Status, nullableSourceID(f.SourceID), nullableString(f.ContentID))
 
 	if err != nil {
 		return fmt.Errorf("failed to insert file: %w", err)
@@ -45,12 +47,12 @@ func (s *Store) GetFileByKey(fileKey string) (*File, error) {
 	err := s.db.QueryRow(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files WHERE file_key = ?
 	`, fileKey).Scan(
 		&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 		&f.SHA1, &f.Status, &f.Error,
-		&f.FirstSeenAt, &f.LastUpdate,
+		&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -96,7 +98,7 @@ func (s *Store) GetFilesByStatus(status string) ([]*File, error) {
 	rows, err := s.db.Query(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files WHERE status = ?
 		ORDER BY id
 	`, status)
@@ -112,7 +114,7 @@ func (s *Store) GetFilesByStatus(status string) ([]*File, error) {
 		err := rows.Scan(
 			&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 			&f.SHA1, &f.Status, &f.Error,
-			&f.FirstSeenAt, &f.LastUpdate,
+			&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
@@ -138,7 +140,7 @@ func (s *Store) GetAllFiles() ([]*File, error) {
 	rows, err := s.db.Query(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files
 		ORDER BY id
 	`)
@@ -154,7 +156,7 @@ func (s *Store) GetAllFiles() ([]*File, error) {
 		err := rows.Scan(
 			&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 			&f.SHA1, &f.Status, &f.Error,
-			&f.FirstSeenAt, &f.LastUpdate,
+			&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
@@ -170,7 +172,7 @@ func (s *Store) GetAllFilesMap() (map[int64]*File, error) {
 	rows, err := s.db.Query(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files
 	`)
 
@@ -185,7 +187,7 @@ func (s *Store) GetAllFilesMap() (map[int64]*File, error) {
 		err := rows.Scan(
 			&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 			&f.SHA1, &f.Status, &f.Error,
-			&f.FirstSeenAt, &f.LastUpdate,
+			&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file: %w", err)
@@ -202,12 +204,38 @@ func (s *Store) GetFileByID(id int64) (*File, error) {
 	err := s.db.QueryRow(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files WHERE id = ?
 	`, id).Scan(
 		&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 		&f.SHA1, &f.Status, &f.Error,
-		&f.FirstSeenAt, &f.LastUpdate,
+		&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	return f, nil
+}
+
+// GetFileBySrcPath retrieves the most recently discovered file at a source path
+func (s *Store) GetFileBySrcPath(srcPath string) (*File, error) {
+	f := &File{}
+	err := s.db.QueryRow(`
+		SELECT id, file_key, src_path, size_bytes, mtime_unix,
+		       COALESCE(sha1, ''), status, COALESCE(error, ''),
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
+		FROM files WHERE src_path = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, srcPath).Scan(
+		&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
+		&f.SHA1, &f.Status, &f.Error,
+		&f.FirstSeenAt, &f.LastUpdate, &f.SourceID, &f.ContentID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -233,12 +261,14 @@ func (s *Store) InsertFileBatch(files []*File) error {
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO files (file_key, src_path, size_bytes, mtime_unix, status)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO files (file_key, src_path, size_bytes, mtime_unix, status, source_id, content_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(file_key) DO UPDATE SET
 			src_path = excluded.src_path,
 			size_bytes = excluded.size_bytes,
 			mtime_unix = excluded.mtime_unix,
+			source_id = COALESCE(excluded.source_id, files.source_id),
+			content_id = COALESCE(excluded.content_id, files.content_id),
 			last_update_at = CURRENT_TIMESTAMP
 	`)
 	if err != nil {
@@ -247,7 +277,7 @@ func (s *Store) InsertFileBatch(files []*File) error {
 	defer stmt.Close()
 
 	for _, file := range files {
-		_, err := stmt.Exec(file.FileKey, file.SrcPath, file.SizeBytes, file.MtimeUnix, file.Status)
+		_, err := stmt.Exec(file.FileKey, file.SrcPath, file.SizeBytes, file.MtimeUnix, file.Status, nullableSourceID(file.SourceID), nullableString(file.ContentID))
 		if err != nil {
 			return fmt.Errorf("failed to insert file %s: %w", file.FileKey, err)
 		}
@@ -325,7 +355,7 @@ func (s *Store) GetFilesByDirectory(dirPath string) ([]*File, error) {
 	rows, err := s.db.Query(`
 		SELECT id, file_key, src_path, size_bytes, mtime_unix,
 		       COALESCE(sha1, ''), status, COALESCE(error, ''),
-		       first_seen_at, last_update_at
+		       first_seen_at, last_update_at, COALESCE(source_id, 0), COALESCE(content_id, '')
 		FROM files
 		WHERE src_path LIKE ?
 		  AND src_path NOT LIKE ?
@@ -345,7 +375,7 @@ func (s *Store) GetFilesByDirectory(dirPath string) ([]*File, error) {
 		err := rows.Scan(
 			&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
 			&f.SHA1, &f.Status, &f.Error,
-			&firstSeenStr, &lastUpdateStr,
+			&firstSeenStr, &lastUpdateStr, &f.SourceID, &f.ContentID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file row: %w", err)
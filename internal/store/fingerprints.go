@@ -0,0 +1,44 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Stages whose stored output depends on settings. A recorded fingerprint that
+// differs from the current settings means the output has to be rebuilt.
+const (
+	FingerprintClusterKeys = "cluster_keys" // Settings that shape cluster keys
+	FingerprintPlanLayout  = "plan_layout"  // Settings that shape destination paths
+	FingerprintScoring     = "scoring"      // Source priorities and their bonus
+)
+
+// GetFingerprint returns the settings fingerprint recorded for a stage; "" if none
+func (s *Store) GetFingerprint(stage string) (string, error) {
+	var fingerprint string
+	err := s.db.QueryRow(`SELECT fingerprint FROM fingerprints WHERE stage = ?`, stage).Scan(&fingerprint)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query fingerprint: %w", err)
+	}
+	return fingerprint, nil
+}
+
+// SetFingerprint records the settings fingerprint of a stage; "" clears it
+func (s *Store) SetFingerprint(stage, fingerprint string) error {
+	var err error
+	if fingerprint == "" {
+		_, err = s.db.Exec(`DELETE FROM fingerprints WHERE stage = ?`, stage)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT OR REPLACE INTO fingerprints (stage, fingerprint, updated_at)
+			VALUES (?, ?, CURRENT_TIMESTAMP)
+		`, stage, fingerprint)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record fingerprint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identity conflict kinds
+const (
+	ConflictCluster = "cluster" // One heuristic cluster holds several recordings
+	ConflictISRC    = "isrc"    // One ISRC is tagged with several recording MBIDs
+)
+
+// IdentityMember is a clustered file with its recording identifiers
+type IdentityMember struct {
+	FileID       int64
+	ClusterKey   string // Current cluster
+	HeuristicKey string // Cluster assigned by the heuristic pass
+	MBID         string
+	ISRC         string
+}
+
+// IdentityConflict records files whose identifiers disagree with each other or with the heuristics
+type IdentityConflict struct {
+	ID          int64
+	ConflictKey string   // Heuristic cluster key or isrc:<ISRC>
+	Kind        string   // ConflictCluster or ConflictISRC
+	Identities  []string // Identity keys involved (mbid:..., isrc:...)
+	FileIDs     []int64
+	DetectedAt  time.Time
+}
+
+// GetIdentityMembers returns every cluster member with its heuristic key and identifiers
+// Files clustered before heuristic keys were recorded fall back to their recorded cluster key
+func (s *Store) GetIdentityMembers() ([]*IdentityMember, error) {
+	rows, err := s.db.Query(`
+		SELECT cm.file_id, cm.cluster_key,
+		       COALESCE(cf.heuristic_key, cf.cluster_key, cm.cluster_key),
+		       COALESCE(m.musicbrainz_recording_id, ''), COALESCE(m.isrc, '')
+		FROM cluster_members cm
+		LEFT JOIN clustered_files cf ON cf.file_id = cm.file_id
+		LEFT JOIN metadata m ON m.file_id = cm.file_id
+		ORDER BY cm.file_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query identity members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*IdentityMember
+	for rows.Next() {
+		m := &IdentityMember{}
+		if err := rows.Scan(&m.FileID, &m.ClusterKey, &m.HeuristicKey, &m.MBID, &m.ISRC); err != nil {
+			return nil, fmt.Errorf("failed to scan identity member: %w", err)
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// GetHeuristicKeys returns the heuristic cluster key of every clustered file
+func (s *Store) GetHeuristicKeys() (map[int64]string, error) {
+	rows, err := s.db.Query(`SELECT file_id, COALESCE(heuristic_key, cluster_key) FROM clustered_files`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query heuristic keys: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]string)
+	for rows.Next() {
+		var fileID int64
+		var key string
+		if err := rows.Scan(&fileID, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan heuristic key: %w", err)
+		}
+		result[fileID] = key
+	}
+
+	return result, rows.Err()
+}
+
+// SetHeuristicKeys records the heuristic cluster key of already clustered files
+func (s *Store) SetHeuristicKeys(keys map[int64]string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE clustered_files SET heuristic_key = ? WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for fileID, key := range keys {
+		if _, err := stmt.Exec(key, fileID); err != nil {
+			return fmt.Errorf("failed to set heuristic key for file %d: %w", fileID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceIdentityConflicts replaces all recorded identity conflicts
+func (s *Store) ReplaceIdentityConflicts(conflicts []*IdentityConflict) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM identity_conflicts`); err != nil {
+		return fmt.Errorf("failed to clear identity conflicts: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO identity_conflicts (conflict_key, kind, identities, file_ids) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range conflicts {
+		ids := make([]string, len(c.FileIDs))
+		for i, id := range c.FileIDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		if _, err := stmt.Exec(c.ConflictKey, c.Kind, strings.Join(c.Identities, ","), strings.Join(ids, ",")); err != nil {
+			return fmt.Errorf("failed to insert identity conflict %s: %w", c.ConflictKey, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetIdentityConflicts returns the conflicts found by the last clustering run
+func (s *Store) GetIdentityConflicts() ([]*IdentityConflict, error) {
+	rows, err := s.db.Query(`
+		SELECT id, conflict_key, kind, identities, file_ids, detected_at
+		FROM identity_conflicts
+		ORDER BY kind, conflict_key
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query identity conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var conflicts []*IdentityConflict
+	for rows.Next() {
+		c := &IdentityConflict{}
+		var identities, fileIDs string
+		if err := rows.Scan(&c.ID, &c.ConflictKey, &c.Kind, &identities, &fileIDs, &c.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan identity conflict: %w", err)
+		}
+		if identities != "" {
+			c.Identities = strings.Split(identities, ",")
+		}
+		for _, id := range strings.Split(fileIDs, ",") {
+			if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+				c.FileIDs = append(c.FileIDs, n)
+			}
+		}
+		conflicts = append(conflicts, c)
+	}
+
+	return conflicts, rows.Err()
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"fmt"
+)
+
+// Integrity check outcomes stored in metadata.integrity_status
+const (
+	IntegrityOK      = "ok"
+	IntegrityDamaged = "damaged"
+)
+
+// IntegrityResult is the outcome of decoding one file
+type IntegrityResult struct {
+	FileID            int64
+	Status            string // IntegrityOK or IntegrityDamaged
+	Error             string // Why the file is damaged
+	DecodedDurationMs int
+}
+
+// UpdateIntegrity records the decode check result for a file
+func (s *Store) UpdateIntegrity(r *IntegrityResult) error {
+	_, err := s.db.Exec(`
+		UPDATE metadata
+		SET integrity_status = ?, integrity_error = ?, decoded_duration_ms = ?
+		WHERE file_id = ?
+	`, r.Status, r.Error, r.DecodedDurationMs, r.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to update integrity: %w", err)
+	}
+	return nil
+}
+
+// ClearIntegrity forgets all decode check results so every file is checked again
+func (s *Store) ClearIntegrity() error {
+	_, err := s.db.Exec(`
+		UPDATE metadata
+		SET integrity_status = NULL, integrity_error = NULL, decoded_duration_ms = NULL
+		WHERE integrity_status IS NOT NULL
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to clear integrity results: %w", err)
+	}
+	return nil
+}
+
+// GetUncheckedFiles returns files with metadata that have not been decode checked
+// With winnersOnly, only files selected as their cluster's winner are returned
+func (s *Store) GetUncheckedFiles(winnersOnly bool) ([]*File, error) {
+	query := `
+		SELECT f.id, f.file_key, f.src_path, f.size_bytes, f.mtime_unix,
+		       COALESCE(f.sha1, ''), f.status, COALESCE(f.error, ''),
+		       f.first_seen_at, f.last_update_at
+		FROM files f
+		INNER JOIN metadata m ON m.file_id = f.id
+		WHERE f.status = 'meta_ok' AND m.integrity_status IS NULL`
+	if winnersOnly {
+		query += `
+		  AND EXISTS (SELECT 1 FROM cluster_members cm WHERE cm.file_id = f.id AND cm.preferred = 1)`
+	}
+	query += `
+		ORDER BY f.id`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unchecked files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*File
+	for rows.Next() {
+		f := &File{}
+		if err := rows.Scan(&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix,
+			&f.SHA1, &f.Status, &f.Error, &f.FirstSeenAt, &f.LastUpdate); err != nil {
+			return nil, fmt.Errorf("failed to scan file: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// GetClustersWithDamagedWinners returns clusters whose winner failed the decode check
+// while another member has not been found damaged, so rescoring can pick a better file
+// Clusters with a pinned winner are left alone
+func (s *Store) GetClustersWithDamagedWinners() ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT cm.cluster_key
+		FROM cluster_members cm
+		INNER JOIN metadata m ON m.file_id = cm.file_id
+		WHERE cm.preferred = 1
+		  AND m.integrity_status = ?
+		  AND EXISTS (
+		    SELECT 1 FROM cluster_members other
+		    INNER JOIN metadata om ON om.file_id = other.file_id
+		    WHERE other.cluster_key = cm.cluster_key
+		      AND other.file_id != cm.file_id
+		      AND COALESCE(om.integrity_status, '') != ?
+		  )
+		  AND NOT EXISTS (
+		    SELECT 1 FROM cluster_overrides o
+		    WHERE o.kind = ? AND o.file_id = cm.file_id
+		  )
+		ORDER BY cm.cluster_key
+	`, IntegrityDamaged, IntegrityDamaged, OverridePin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query damaged winners: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// CountDamagedFiles returns the number of files that failed the decode check
+func (s *Store) CountDamagedFiles() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM metadata WHERE integrity_status = ?`, IntegrityDamaged).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count damaged files: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// writerLockName is the row of the single-writer lock in the locks table
+const writerLockName = "writer"
+
+// LockInfo identifies the process holding the writer lock of a database
+type LockInfo struct {
+	PID        int       `json:"pid"`
+	Host       string    `json:"host"`
+	Command    string    `json:"command"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// String describes the lock holder, e.g. "mlc plan (PID 123 on nas, since 2024-01-02 15:04:05)"
+func (l *LockInfo) String() string {
+	return fmt.Sprintf("mlc %s (PID %d on %s, since %s)",
+		l.Command, l.PID, l.Host, l.AcquiredAt.Local().Format("2006-01-02 15:04:05"))
+}
+
+// Local reports whether the holder runs on this host
+func (l *LockInfo) Local() bool {
+	host, err := os.Hostname()
+	return err == nil && l.Host == host
+}
+
+// Stale reports whether the holder runs on this host and is no longer running.
+// Holders on other hosts cannot be checked and are never stale.
+func (l *LockInfo) Stale() bool {
+	return l.Local() && !processAlive(l.PID)
+}
+
+// LockedError is returned when another process holds the writer lock
+type LockedError struct {
+	Path   string    // Lock file
+	Holder *LockInfo // nil if the lock file cannot be read
+}
+
+func (e *LockedError) Error() string {
+	switch {
+	case e.Holder == nil:
+		return fmt.Sprintf("database is locked by another mlc process (unreadable lock file %s); "+
+			"rerun with --break-lock if no mlc process is using the database", e.Path)
+	case e.Holder.Stale():
+		return fmt.Sprintf("database has a stale lock from %s, which is no longer running; "+
+			"rerun with --break-lock to remove %s", e.Holder, e.Path)
+	case !e.Holder.Local():
+		return fmt.Sprintf("database is locked by %s; wait for it to finish, "+
+			"or rerun with --break-lock if it is no longer running", e.Holder)
+	default:
+		return fmt.Sprintf("database is locked by %s; wait for it to finish", e.Holder)
+	}
+}
+
+// LockPath returns the lock file of the database at dbPath
+func LockPath(dbPath string) string {
+	return dbPath + ".lock"
+}
+
+// ReadLock returns the holder recorded in a lock file, or nil if there is none
+func ReadLock(lockPath string) (*LockInfo, error) {
+	data, err := os.ReadFile(lockPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %s: %w", lockPath, err)
+	}
+	return &info, nil
+}
+
+// acquireLock creates the lock file for command; with breakLock, a lock that
+// is stale, unreadable or held on another host is removed first
+func acquireLock(lockPath, command string, breakLock bool) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	info := &LockInfo{PID: os.Getpid(), Host: host, Command: command, AcquiredAt: time.Now().UTC()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		holder, _ := ReadLock(lockPath)
+		breakable := holder == nil || holder.Stale() || !holder.Local()
+		if !breakLock || !breakable || attempt > 0 {
+			return &LockedError{Path: lockPath, Holder: holder}
+		}
+		if err := os.Remove(lockPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+}
+
+// releaseLock removes a lock file taken by acquireLock
+func releaseLock(lockPath string) {
+	if lockPath != "" {
+		os.Remove(lockPath)
+	}
+}
+
+// recordLock mirrors the writer lock of this process in the locks table
+func (s *Store) recordLock(command string) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to get hostname: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO locks (name, pid, host, command, acquired_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, writerLockName, os.Getpid(), host, command, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record lock: %w", err)
+	}
+	return nil
+}
+
+// clearLock removes the writer lock row of this process
+func (s *Store) clearLock() {
+	s.db.Exec("DELETE FROM locks WHERE name = ? AND pid = ?", writerLockName, os.Getpid())
+}
+
+// WriterLock returns the holder of the writer lock recorded in the database,
+// or nil if no mutating command has it. The holder may be stale if it crashed.
+func (s *Store) WriterLock() (*LockInfo, error) {
+	var info LockInfo
+	err := s.db.QueryRow(`
+		SELECT pid, host, command, acquired_at FROM locks WHERE name = ?
+	`, writerLockName).Scan(&info.PID, &info.Host, &info.Command, &info.AcquiredAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get writer lock: %w", err)
+	}
+	return &info, nil
+}
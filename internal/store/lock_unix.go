@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,1400 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory Repository for fast pipeline tests
+// It follows the SQLite store's ordering, upsert and not-found behaviour; the
+// conformance suite in storetest keeps the two in line. Artist credits are
+// not kept since no stage reads them back.
+type Memory struct {
+	mu sync.Mutex
+
+	files        map[int64]*File
+	fileIDs      map[string]int64 // By file key
+	versions     map[int64]int64  // Bumped whenever a file's last update changes
+	nextFileID   int64
+	nextVersion  int64
+	sources      map[string]*Source // By label
+	nextSourceID int64
+
+	metadata   map[int64]*Metadata // Without Artists, Pictures and Provenance
+	pictures   map[int64][]Picture
+	provenance map[int64][]FieldProvenance
+
+	clusters       map[string]*memoryCluster
+	members        map[memberKey]*ClusterMember
+	clustered      map[int64]*ClusteredFile // HeuristicKey "" = not recorded
+	progress       *ClusteringProgress
+	conflicts      []*IdentityConflict
+	overrides      []*ClusterOverride
+	nextOverrideID int64
+
+	plans      map[int64]*Plan
+	covers     map[string]*AlbumCover
+	executions map[int64]*Execution
+
+	fingerprints map[string]string
+}
+
+type memoryCluster struct {
+	Cluster
+	dirty bool
+}
+
+type memberKey struct {
+	clusterKey string
+	fileID     int64
+}
+
+// NewMemory returns an empty in-memory repository
+func NewMemory() *Memory {
+	return &Memory{
+		files:      make(map[int64]*File),
+		fileIDs:    make(map[string]int64),
+		versions:   make(map[int64]int64),
+		sources:    make(map[string]*Source),
+		metadata:   make(map[int64]*Metadata),
+		pictures:   make(map[int64][]Picture),
+		provenance: make(map[int64][]FieldProvenance),
+		clusters:   make(map[string]*memoryCluster),
+		members:    make(map[memberKey]*ClusterMember),
+		clustered:  make(map[int64]*ClusteredFile),
+		plans:      make(map[int64]*Plan),
+		covers:     make(map[string]*AlbumCover),
+		executions: make(map[int64]*Execution),
+
+		fingerprints: make(map[string]string),
+	}
+}
+
+// touch records a change of f, like last_update_at = CURRENT_TIMESTAMP
+func (m *Memory) touch(f *File) {
+	f.LastUpdate = time.Now().UTC()
+	m.nextVersion++
+	m.versions[f.ID] = m.nextVersion
+}
+
+// metadataVersion identifies the state of a file for incremental clustering
+func (m *Memory) metadataVersion(fileID int64) string {
+	return strconv.FormatInt(m.versions[fileID], 10)
+}
+
+func copyFile(f *File) *File {
+	c := *f
+	return &c
+}
+
+// sortedFiles returns copies of the files matching keep, ordered by ID
+func (m *Memory) sortedFiles(keep func(*File) bool) []*File {
+	var files []*File
+	for _, f := range m.files {
+		if keep(f) {
+			files = append(files, copyFile(f))
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files
+}
+
+// upsertFile inserts f or updates the file with the same key; returns the ID
+func (m *Memory) upsertFile(f *File) int64 {
+	if id, ok := m.fileIDs[f.FileKey]; ok {
+		existing := m.files[id]
+		existing.SrcPath = f.SrcPath
+		existing.SizeBytes = f.SizeBytes
+		existing.MtimeUnix = f.MtimeUnix
+		if f.SourceID > 0 {
+			existing.SourceID = f.SourceID
+		}
+		if f.ContentID != "" {
+			existing.ContentID = f.ContentID
+		}
+		m.touch(existing)
+		return id
+	}
+
+	m.nextFileID++
+	now := time.Now().UTC()
+	stored := &File{
+		ID:          m.nextFileID,
+		FileKey:     f.FileKey,
+		SrcPath:     f.SrcPath,
+		SizeBytes:   f.SizeBytes,
+		MtimeUnix:   f.MtimeUnix,
+		Status:      f.Status,
+		FirstSeenAt: now,
+		SourceID:    f.SourceID,
+		ContentID:   f.ContentID,
+	}
+	if stored.SourceID < 0 {
+		stored.SourceID = 0
+	}
+	m.files[stored.ID] = stored
+	m.fileIDs[stored.FileKey] = stored.ID
+	m.touch(stored)
+	return stored.ID
+}
+
+// InsertFile inserts or updates a file record
+func (m *Memory) InsertFile(f *File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.upsertFile(f)
+	if f.ID == 0 {
+		f.ID = id
+	}
+	return nil
+}
+
+// InsertFileBatch inserts or updates multiple files
+func (m *Memory) InsertFileBatch(files []*File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range files {
+		m.upsertFile(f)
+	}
+	return nil
+}
+
+// RelinkFileBatch points existing file rows at new locations
+func (m *Memory) RelinkFileBatch(files []*File) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range files {
+		if id, ok := m.fileIDs[f.FileKey]; ok && id != f.ID {
+			return fmt.Errorf("failed to relink file %d: file key %s is taken by file %d", f.ID, f.FileKey, id)
+		}
+	}
+
+	for _, f := range files {
+		existing := m.files[f.ID]
+		if existing == nil {
+			continue
+		}
+		delete(m.fileIDs, existing.FileKey)
+		existing.FileKey = f.FileKey
+		existing.SrcPath = f.SrcPath
+		existing.SizeBytes = f.SizeBytes
+		existing.MtimeUnix = f.MtimeUnix
+		if f.SourceID > 0 {
+			existing.SourceID = f.SourceID
+		}
+		if f.ContentID != "" {
+			existing.ContentID = f.ContentID
+		}
+		if existing.Status == StatusMissing {
+			existing.Status = "discovered"
+		}
+		m.fileIDs[existing.FileKey] = existing.ID
+		m.touch(existing)
+	}
+	return nil
+}
+
+// GetFileByID retrieves a file by its ID
+func (m *Memory) GetFileByID(id int64) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.files[id]; ok {
+		return copyFile(f), nil
+	}
+	return nil, nil
+}
+
+// GetFileByKey retrieves a file by its file key
+func (m *Memory) GetFileByKey(fileKey string) (*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.fileIDs[fileKey]; ok {
+		return copyFile(m.files[id]), nil
+	}
+	return nil, nil
+}
+
+// GetFilesByStatus retrieves files with a given status
+func (m *Memory) GetFilesByStatus(status string) ([]*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedFiles(func(f *File) bool { return f.Status == status }), nil
+}
+
+// GetFilesByDirectory returns the discovered and meta_ok files directly in dirPath
+func (m *Memory) GetFilesByDirectory(dirPath string) ([]*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := dirPath + "/"
+	files := m.sortedFiles(func(f *File) bool {
+		name, ok := strings.CutPrefix(f.SrcPath, prefix)
+		return ok && !strings.Contains(name, "/") && (f.Status == "meta_ok" || f.Status == "discovered")
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].SrcPath < files[j].SrcPath })
+	return files, nil
+}
+
+// GetAllFiles retrieves all files
+func (m *Memory) GetAllFiles() ([]*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedFiles(func(*File) bool { return true }), nil
+}
+
+// GetAllFilesMap retrieves all files as a map indexed by ID
+func (m *Memory) GetAllFilesMap() (map[int64]*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]*File, len(m.files))
+	for id, f := range m.files {
+		result[id] = copyFile(f)
+	}
+	return result, nil
+}
+
+// GetAllFileKeysMap returns a map of all file keys
+func (m *Memory) GetAllFileKeysMap() (map[string]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]bool, len(m.fileIDs))
+	for key := range m.fileIDs {
+		result[key] = true
+	}
+	return result, nil
+}
+
+// GetMissingFileKeys returns the IDs of missing files, keyed by file key
+func (m *Memory) GetMissingFileKeys() (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]int64)
+	for _, f := range m.files {
+		if f.Status == StatusMissing {
+			result[f.FileKey] = f.ID
+		}
+	}
+	return result, nil
+}
+
+// RestoreMissingFiles resets missing files that reappeared to discovered
+func (m *Memory) RestoreMissingFiles(fileIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range fileIDs {
+		if f := m.files[id]; f != nil && f.Status == StatusMissing {
+			f.Status = "discovered"
+			m.touch(f)
+		}
+	}
+	return nil
+}
+
+// UpdateFileStatus updates the status of a file
+func (m *Memory) UpdateFileStatus(fileID int64, status string, errorMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f := m.files[fileID]; f != nil {
+		f.Status = status
+		f.Error = errorMsg
+		m.touch(f)
+	}
+	return nil
+}
+
+// BatchUpdateFileStatus updates multiple file statuses
+func (m *Memory) BatchUpdateFileStatus(updates []struct {
+	FileID   int64
+	Status   string
+	ErrorMsg string
+}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range updates {
+		if f := m.files[update.FileID]; f != nil {
+			f.Status = update.Status
+			f.Error = update.ErrorMsg
+			m.touch(f)
+		}
+	}
+	return nil
+}
+
+// GetContentIDIndex returns the files that have a content ID, keyed by content ID
+// Only ID, FileKey, SrcPath and ContentID are set.
+func (m *Memory) GetContentIDIndex() (map[string][]*File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := make(map[string][]*File)
+	for _, f := range m.sortedFiles(func(f *File) bool { return f.ContentID != "" }) {
+		index[f.ContentID] = append(index[f.ContentID], &File{ID: f.ID, FileKey: f.FileKey, SrcPath: f.SrcPath, ContentID: f.ContentID})
+	}
+	return index, nil
+}
+
+// GetFileKeysWithoutContentID returns the IDs of files without a content ID, keyed by file key
+func (m *Memory) GetFileKeysWithoutContentID() (map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]int64)
+	for _, f := range m.files {
+		if f.ContentID == "" {
+			result[f.FileKey] = f.ID
+		}
+	}
+	return result, nil
+}
+
+// SetContentIDs records content IDs, keyed by file ID
+func (m *Memory) SetContentIDs(contentIDs map[int64]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, contentID := range contentIDs {
+		if f := m.files[id]; f != nil {
+			f.ContentID = contentID
+		}
+	}
+	return nil
+}
+
+// UpsertSource inserts a source or updates the source with the same label
+// Sets src.ID.
+func (m *Memory) UpsertSource(src *Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := m.sources[src.Label]
+	if existing == nil {
+		m.nextSourceID++
+		existing = &Source{ID: m.nextSourceID, Label: src.Label}
+		m.sources[src.Label] = existing
+	}
+	existing.Path = src.Path
+	existing.Priority = src.Priority
+	src.ID = existing.ID
+	return nil
+}
+
+// AssignSource records sourceID on files under root that have no source yet
+func (m *Memory) AssignSource(sourceID int64, root string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := strings.TrimSuffix(filepath.Clean(root), string(filepath.Separator)) + string(filepath.Separator)
+	n := 0
+	for _, f := range m.files {
+		if f.SourceID == 0 && strings.HasPrefix(f.SrcPath, prefix) {
+			f.SourceID = sourceID
+			n++
+		}
+	}
+	return n, nil
+}
+
+// GetSourcePriorities returns the priority of every source, keyed by source ID
+func (m *Memory) GetSourcePriorities() (map[int64]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	priorities := make(map[int64]int, len(m.sources))
+	for _, src := range m.sources {
+		priorities[src.ID] = src.Priority
+	}
+	return priorities, nil
+}
+
+// putMetadata stores md and replaces its pictures and provenance
+func (m *Memory) putMetadata(md *Metadata) {
+	stored := *md
+	stored.IntegrityStatus = ""
+	stored.IntegrityError = ""
+	stored.DecodedDurationMs = 0
+	stored.Artists = nil
+	stored.Pictures = nil
+	stored.Provenance = nil
+	m.metadata[md.FileID] = &stored
+
+	// Later entries replace earlier ones with the same key, like INSERT OR REPLACE
+	pictures := make(map[int]Picture)
+	for _, p := range md.Pictures {
+		pictures[p.Position] = p
+	}
+	m.pictures[md.FileID] = nil
+	for _, p := range pictures {
+		m.pictures[md.FileID] = append(m.pictures[md.FileID], p)
+	}
+	sort.Slice(m.pictures[md.FileID], func(i, j int) bool {
+		return m.pictures[md.FileID][i].Position < m.pictures[md.FileID][j].Position
+	})
+
+	provenance := make(map[string]FieldProvenance)
+	for _, p := range md.Provenance {
+		provenance[p.Field] = p
+	}
+	m.provenance[md.FileID] = nil
+	for _, p := range provenance {
+		m.provenance[md.FileID] = append(m.provenance[md.FileID], p)
+	}
+	sort.Slice(m.provenance[md.FileID], func(i, j int) bool {
+		return m.provenance[md.FileID][i].Field < m.provenance[md.FileID][j].Field
+	})
+}
+
+// InsertMetadata inserts or replaces metadata for a file
+func (m *Memory) InsertMetadata(md *Metadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.putMetadata(md)
+	return nil
+}
+
+// InsertMetadataBatch inserts multiple metadata records
+// Like the SQLite store, raw tags are not written.
+func (m *Memory) InsertMetadataBatch(metadataList []*Metadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, md := range metadataList {
+		m.putMetadata(md)
+		stored := m.metadata[md.FileID]
+		stored.RawTagsJSON = ""
+	}
+	return nil
+}
+
+// GetMetadata retrieves metadata for a file
+func (m *Memory) GetMetadata(fileID int64) (*Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if md, ok := m.metadata[fileID]; ok {
+		c := *md
+		return &c, nil
+	}
+	return nil, nil
+}
+
+// GetMetadataByFileID retrieves metadata for a file; nil if there is none
+func (m *Memory) GetMetadataByFileID(fileID int64) (*Metadata, error) {
+	return m.GetMetadata(fileID)
+}
+
+// GetAllMetadata returns all metadata records as a map indexed by file ID
+// Like the SQLite store, compilation, release ID and raw tags are not loaded.
+func (m *Memory) GetAllMetadata() (map[int64]*Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]*Metadata, len(m.metadata))
+	for id, md := range m.metadata {
+		c := *md
+		c.TagCompilation = false
+		c.MusicBrainzReleaseID = ""
+		c.RawTagsJSON = ""
+		result[id] = &c
+	}
+	return result, nil
+}
+
+// GetProvenance returns the field provenance of a file, ordered by field
+func (m *Memory) GetProvenance(fileID int64) ([]FieldProvenance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]FieldProvenance(nil), m.provenance[fileID]...), nil
+}
+
+// GetInferredProvenance returns the provenance of inferred field values, keyed by file ID
+func (m *Memory) GetInferredProvenance() (map[int64][]FieldProvenance, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64][]FieldProvenance)
+	for id, provenance := range m.provenance {
+		for _, p := range provenance {
+			if p.Inferred() {
+				result[id] = append(result[id], p)
+			}
+		}
+	}
+	return result, nil
+}
+
+// InsertClusterBatch inserts clusters; fails if any of them exists
+func (m *Memory) InsertClusterBatch(clusters []*Cluster) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool, len(clusters))
+	for _, c := range clusters {
+		if m.clusters[c.ClusterKey] != nil || seen[c.ClusterKey] {
+			return fmt.Errorf("failed to insert cluster %s: cluster exists", c.ClusterKey)
+		}
+		seen[c.ClusterKey] = true
+	}
+	for _, c := range clusters {
+		m.clusters[c.ClusterKey] = &memoryCluster{Cluster: *c}
+	}
+	return nil
+}
+
+// EnsureClusterBatch inserts clusters that don't exist yet
+func (m *Memory) EnsureClusterBatch(clusters []*Cluster) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range clusters {
+		if m.clusters[c.ClusterKey] == nil {
+			m.clusters[c.ClusterKey] = &memoryCluster{Cluster: *c}
+		}
+	}
+	return nil
+}
+
+// sortedClusters returns copies of the clusters matching keep, ordered by key
+func (m *Memory) sortedClusters(keep func(*memoryCluster) bool) []*Cluster {
+	var clusters []*Cluster
+	for _, c := range m.clusters {
+		if keep(c) {
+			cluster := c.Cluster
+			clusters = append(clusters, &cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].ClusterKey < clusters[j].ClusterKey })
+	return clusters
+}
+
+// GetAllClusters returns all clusters
+func (m *Memory) GetAllClusters() ([]*Cluster, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedClusters(func(*memoryCluster) bool { return true }), nil
+}
+
+// CountClusters returns the total number of clusters
+func (m *Memory) CountClusters() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.clusters), nil
+}
+
+// CountDuplicateClusters returns the number of clusters with multiple members
+func (m *Memory) CountDuplicateClusters() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sizes := make(map[string]int)
+	for key := range m.members {
+		sizes[key.clusterKey]++
+	}
+	count := 0
+	for _, n := range sizes {
+		if n > 1 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ClearClusters removes all clusters, members, clustered files and identity conflicts
+func (m *Memory) ClearClusters() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clusters = make(map[string]*memoryCluster)
+	m.members = make(map[memberKey]*ClusterMember)
+	m.clustered = make(map[int64]*ClusteredFile)
+	m.conflicts = nil
+	return nil
+}
+
+// DeleteEmptyClusters removes clusters among the given keys that have no members
+// Returns the number of clusters deleted
+func (m *Memory) DeleteEmptyClusters(clusterKeys []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	populated := make(map[string]bool)
+	for key := range m.members {
+		populated[key.clusterKey] = true
+	}
+	deleted := 0
+	for _, key := range clusterKeys {
+		if m.clusters[key] != nil && !populated[key] {
+			delete(m.clusters, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// MarkClustersDirty flags clusters for rescoring and replanning and resets
+// their members' scores and winners
+func (m *Memory) MarkClustersDirty(clusterKeys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	marked := make(map[string]bool, len(clusterKeys))
+	for _, key := range clusterKeys {
+		marked[key] = true
+		if c := m.clusters[key]; c != nil {
+			c.dirty = true
+		}
+	}
+	for key, member := range m.members {
+		if marked[key.clusterKey] {
+			member.QualityScore = 0
+			member.Preferred = false
+		}
+	}
+	return nil
+}
+
+// GetDirtyClusters returns clusters flagged for rescoring and replanning
+func (m *Memory) GetDirtyClusters() ([]*Cluster, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sortedClusters(func(c *memoryCluster) bool { return c.dirty }), nil
+}
+
+// ClearDirtyClusters resets the dirty flag on all clusters
+func (m *Memory) ClearDirtyClusters() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.clusters {
+		c.dirty = false
+	}
+	return nil
+}
+
+// InsertClusterMemberBatch inserts cluster members; fails if any of them exists
+func (m *Memory) InsertClusterMemberBatch(members []*ClusterMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[memberKey]bool, len(members))
+	for _, member := range members {
+		key := memberKey{member.ClusterKey, member.FileID}
+		if m.members[key] != nil || seen[key] {
+			return fmt.Errorf("failed to insert cluster member: file %d is already in cluster %s", member.FileID, member.ClusterKey)
+		}
+		seen[key] = true
+	}
+	for _, member := range members {
+		stored := *member
+		stored.Confidence = memberConfidence(member)
+		m.members[memberKey{member.ClusterKey, member.FileID}] = &stored
+	}
+	return nil
+}
+
+// membersOf returns copies of the members matching keep
+func (m *Memory) membersOf(keep func(*ClusterMember) bool) []*ClusterMember {
+	var members []*ClusterMember
+	for _, member := range m.members {
+		if keep(member) {
+			c := *member
+			members = append(members, &c)
+		}
+	}
+	return members
+}
+
+// GetClusterMembers returns all members of a cluster, best score first
+func (m *Memory) GetClusterMembers(clusterKey string) ([]*ClusterMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := m.membersOf(func(member *ClusterMember) bool { return member.ClusterKey == clusterKey })
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].QualityScore != members[j].QualityScore {
+			return members[i].QualityScore > members[j].QualityScore
+		}
+		return members[i].FileID < members[j].FileID
+	})
+	return members, nil
+}
+
+// GetAllClusterMembers returns all cluster members indexed by cluster key,
+// winners first
+func (m *Memory) GetAllClusterMembers() (map[string][]*ClusterMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := m.membersOf(func(*ClusterMember) bool { return true })
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.Preferred != b.Preferred {
+			return a.Preferred
+		}
+		if a.QualityScore != b.QualityScore {
+			return a.QualityScore > b.QualityScore
+		}
+		return a.FileID < b.FileID
+	})
+
+	result := make(map[string][]*ClusterMember)
+	for _, member := range members {
+		result[member.ClusterKey] = append(result[member.ClusterKey], member)
+	}
+	return result, nil
+}
+
+// GetFileClusterKeys returns the current cluster key for every clustered file
+func (m *Memory) GetFileClusterKeys() (map[int64]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]string, len(m.members))
+	for key := range m.members {
+		result[key.fileID] = key.clusterKey
+	}
+	return result, nil
+}
+
+// GetClusterMemberDurations returns the duration of every clustered file (0 if unknown)
+func (m *Memory) GetClusterMemberDurations() (map[int64]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]int, len(m.members))
+	for key := range m.members {
+		result[key.fileID] = 0
+		if md := m.metadata[key.fileID]; md != nil {
+			result[key.fileID] = md.DurationMs
+		}
+	}
+	return result, nil
+}
+
+// MoveClusterMembers reassigns files to another cluster and resets their scores
+func (m *Memory) MoveClusterMembers(fileIDs []int64, toKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	moving := make(map[int64]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		moving[id] = true
+	}
+	for key := range m.members {
+		if moving[key.fileID] && key.clusterKey != toKey && m.members[memberKey{toKey, key.fileID}] != nil {
+			return fmt.Errorf("failed to move cluster member %d: already in cluster %s", key.fileID, toKey)
+		}
+	}
+
+	for key, member := range m.members {
+		if !moving[key.fileID] {
+			continue
+		}
+		delete(m.members, key)
+		member.ClusterKey = toKey
+		member.QualityScore = 0
+		member.Preferred = false
+		member.Confidence = 1.0
+		m.members[memberKey{toKey, key.fileID}] = member
+	}
+	for _, id := range fileIDs {
+		if cf := m.clustered[id]; cf != nil {
+			cf.ClusterKey = toKey
+		}
+	}
+	return nil
+}
+
+// removeMembers deletes the memberships of the given files
+func (m *Memory) removeMembers(fileIDs []int64) {
+	removing := make(map[int64]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		removing[id] = true
+	}
+	for key := range m.members {
+		if removing[key.fileID] {
+			delete(m.members, key)
+		}
+	}
+}
+
+// RemoveClusterMembers removes cluster memberships but keeps clustered files
+func (m *Memory) RemoveClusterMembers(fileIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeMembers(fileIDs)
+	return nil
+}
+
+// DeleteClusterMembersByFileIDs removes the cluster memberships and clustered
+// files records for the given files
+func (m *Memory) DeleteClusterMembersByFileIDs(fileIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeMembers(fileIDs)
+	for _, id := range fileIDs {
+		delete(m.clustered, id)
+	}
+	return nil
+}
+
+// BatchUpdateClusterMemberScores updates multiple scores
+func (m *Memory) BatchUpdateClusterMemberScores(updates []struct {
+	ClusterKey string
+	FileID     int64
+	Score      float64
+}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range updates {
+		if member := m.members[memberKey{update.ClusterKey, update.FileID}]; member != nil {
+			member.QualityScore = update.Score
+		}
+	}
+	return nil
+}
+
+// BatchUpdateClusterMemberPreferred updates multiple preferred flags
+func (m *Memory) BatchUpdateClusterMemberPreferred(updates []struct {
+	ClusterKey string
+	FileID     int64
+	Preferred  bool
+}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range updates {
+		if member := m.members[memberKey{update.ClusterKey, update.FileID}]; member != nil {
+			member.Preferred = update.Preferred
+		}
+	}
+	return nil
+}
+
+// ClearScores resets all quality scores and preferred flags
+func (m *Memory) ClearScores() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, member := range m.members {
+		member.QualityScore = 0
+		member.Preferred = false
+	}
+	return nil
+}
+
+// CountWinners returns the number of cluster members marked as preferred
+func (m *Memory) CountWinners() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, member := range m.members {
+		if member.Preferred {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// InsertClusterOverride stores a manual override and sets its ID
+func (m *Memory) InsertClusterOverride(o *ClusterOverride) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextOverrideID++
+	stored := *o
+	stored.ID = m.nextOverrideID
+	stored.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	if stored.FileID < 0 {
+		stored.FileID = 0
+	}
+	if stored.OtherFileID < 0 {
+		stored.OtherFileID = 0
+	}
+	m.overrides = append(m.overrides, &stored)
+	o.ID = stored.ID
+	return nil
+}
+
+// GetClusterOverrides returns all manual overrides in creation order
+func (m *Memory) GetClusterOverrides() ([]*ClusterOverride, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var overrides []*ClusterOverride
+	for _, o := range m.overrides {
+		c := *o
+		overrides = append(overrides, &c)
+	}
+	return overrides, nil
+}
+
+// UpdateClusterOverrideKeys records the cluster keys an override resolved to
+func (m *Memory) UpdateClusterOverrideKeys(id int64, clusterKey, otherKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, o := range m.overrides {
+		if o.ID == id {
+			o.ClusterKey = clusterKey
+			o.OtherKey = otherKey
+		}
+	}
+	return nil
+}
+
+// GetOverrideFileIDs returns the set of files with an override of the given kind
+func (m *Memory) GetOverrideFileIDs(kind string) (map[int64]bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]bool)
+	for _, o := range m.overrides {
+		if o.Kind == kind && o.FileID > 0 {
+			result[o.FileID] = true
+		}
+	}
+	return result, nil
+}
+
+// GetClusteringCandidates returns meta_ok files that are not yet clustered or
+// changed since they were
+func (m *Memory) GetClusteringCandidates() ([]*ClusteringCandidate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var candidates []*ClusteringCandidate
+	for _, f := range m.sortedFiles(func(f *File) bool { return f.Status == "meta_ok" }) {
+		version := m.metadataVersion(f.ID)
+		if cf := m.clustered[f.ID]; cf != nil && cf.MetadataVersion == version {
+			continue
+		}
+		f.SourceID = 0
+		f.ContentID = ""
+		candidates = append(candidates, &ClusteringCandidate{File: f, MetadataVersion: version})
+	}
+	return candidates, nil
+}
+
+// GetRemovedClusteredFiles returns clustered files that should no longer be
+// part of any cluster
+func (m *Memory) GetRemovedClusteredFiles() ([]*ClusteredFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var removed []*ClusteredFile
+	for id, cf := range m.clustered {
+		f := m.files[id]
+		gone := f == nil || m.metadata[id] == nil ||
+			f.Status == "discovered" || f.Status == StatusMissing ||
+			(f.Status == "error" && m.executions[id] == nil)
+		if gone {
+			removed = append(removed, &ClusteredFile{FileID: id, ClusterKey: cf.ClusterKey, MetadataVersion: cf.MetadataVersion})
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].FileID < removed[j].FileID })
+	return removed, nil
+}
+
+// UpsertClusteredFileBatch records cluster keys and metadata versions
+func (m *Memory) UpsertClusteredFileBatch(files []*ClusteredFile) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, cf := range files {
+		c := *cf
+		m.clustered[cf.FileID] = &c
+	}
+	return nil
+}
+
+// SyncClusteredFilesFromMembers rebuilds clustered files from cluster members
+// using each file's current metadata version
+func (m *Memory) SyncClusteredFilesFromMembers() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clustered = make(map[int64]*ClusteredFile)
+	for key := range m.members {
+		if m.files[key.fileID] == nil {
+			continue
+		}
+		m.clustered[key.fileID] = &ClusteredFile{
+			FileID:          key.fileID,
+			ClusterKey:      key.clusterKey,
+			MetadataVersion: m.metadataVersion(key.fileID),
+		}
+	}
+
+	// Manually excluded files have no membership but still count as clustered
+	for _, o := range m.overrides {
+		f := m.files[o.FileID]
+		if o.Kind != OverrideExclude || f == nil || f.Status != "meta_ok" || m.clustered[o.FileID] != nil {
+			continue
+		}
+		m.clustered[o.FileID] = &ClusteredFile{
+			FileID:          o.FileID,
+			ClusterKey:      o.ClusterKey,
+			MetadataVersion: m.metadataVersion(o.FileID),
+		}
+	}
+	return nil
+}
+
+// GetClusteringProgress retrieves the current clustering progress; nil if none
+func (m *Memory) GetClusteringProgress() (*ClusteringProgress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.progress == nil {
+		return nil, nil
+	}
+	p := *m.progress
+	return &p, nil
+}
+
+// InitClusteringProgress initializes or resets clustering progress
+func (m *Memory) InitClusteringProgress(totalFiles int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	m.progress = &ClusteringProgress{TotalFiles: totalFiles, StartedAt: now, UpdatedAt: now}
+	return nil
+}
+
+// UpdateClusteringProgress updates progress during clustering
+func (m *Memory) UpdateClusteringProgress(lastFileID int64, filesProcessed, clustersCreated int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.progress != nil {
+		m.progress.LastProcessedFileID = lastFileID
+		m.progress.FilesProcessed = filesProcessed
+		m.progress.ClustersCreated = clustersCreated
+		m.progress.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	}
+	return nil
+}
+
+// ClearClusteringProgress removes progress tracking
+func (m *Memory) ClearClusteringProgress() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.progress = nil
+	return nil
+}
+
+// GetIdentityMembers returns every cluster member with its heuristic key and identifiers
+func (m *Memory) GetIdentityMembers() ([]*IdentityMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var members []*IdentityMember
+	for key := range m.members {
+		im := &IdentityMember{FileID: key.fileID, ClusterKey: key.clusterKey, HeuristicKey: key.clusterKey}
+		if cf := m.clustered[key.fileID]; cf != nil {
+			im.HeuristicKey = cf.ClusterKey
+			if cf.HeuristicKey != "" {
+				im.HeuristicKey = cf.HeuristicKey
+			}
+		}
+		if md := m.metadata[key.fileID]; md != nil {
+			im.MBID = md.MusicBrainzRecordingID
+			im.ISRC = md.ISRC
+		}
+		members = append(members, im)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].FileID != members[j].FileID {
+			return members[i].FileID < members[j].FileID
+		}
+		return members[i].ClusterKey < members[j].ClusterKey
+	})
+	return members, nil
+}
+
+// GetHeuristicKeys returns the heuristic cluster key of every clustered file
+func (m *Memory) GetHeuristicKeys() (map[int64]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]string, len(m.clustered))
+	for id, cf := range m.clustered {
+		result[id] = cf.ClusterKey
+		if cf.HeuristicKey != "" {
+			result[id] = cf.HeuristicKey
+		}
+	}
+	return result, nil
+}
+
+// SetHeuristicKeys records the heuristic cluster key of already clustered files
+func (m *Memory) SetHeuristicKeys(keys map[int64]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, key := range keys {
+		if cf := m.clustered[id]; cf != nil {
+			cf.HeuristicKey = key
+		}
+	}
+	return nil
+}
+
+// ReplaceIdentityConflicts replaces all recorded identity conflicts
+func (m *Memory) ReplaceIdentityConflicts(conflicts []*IdentityConflict) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	m.conflicts = nil
+	for i, c := range conflicts {
+		stored := *c
+		stored.ID = int64(i + 1)
+		stored.DetectedAt = now
+		stored.Identities = append([]string(nil), c.Identities...)
+		stored.FileIDs = append([]int64(nil), c.FileIDs...)
+		m.conflicts = append(m.conflicts, &stored)
+	}
+	return nil
+}
+
+// InsertPlan inserts or replaces the plan for a file
+func (m *Memory) InsertPlan(plan *Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := *plan
+	m.plans[plan.FileID] = &p
+	return nil
+}
+
+// InsertPlanBatch inserts or replaces multiple plans
+func (m *Memory) InsertPlanBatch(plans []*Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, plan := range plans {
+		p := *plan
+		m.plans[plan.FileID] = &p
+	}
+	return nil
+}
+
+// GetAllPlans returns all plans, ordered by file ID
+func (m *Memory) GetAllPlans() ([]*Plan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var plans []*Plan
+	for _, plan := range m.plans {
+		p := *plan
+		plans = append(plans, &p)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].FileID < plans[j].FileID })
+	return plans, nil
+}
+
+// CountPlans returns the total number of plans
+func (m *Memory) CountPlans() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.plans), nil
+}
+
+// ClearPlans removes all plans
+func (m *Memory) ClearPlans() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.plans = make(map[int64]*Plan)
+	return nil
+}
+
+// DeletePlansByFileIDs removes plans for the given files
+func (m *Memory) DeletePlansByFileIDs(fileIDs []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range fileIDs {
+		delete(m.plans, id)
+	}
+	return nil
+}
+
+// CountPlansInconsistentWith returns the number of non-skip plans whose action
+// differs from mode or whose destination lies outside destRoot
+func (m *Memory) CountPlansInconsistentWith(destRoot, mode string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, p := range m.plans {
+		if p.Action != "skip" && (p.Action != mode || !strings.HasPrefix(p.DestPath, destRoot)) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetCoverCandidates returns the pictures of every planned (non-skip) file and
+// of the other members of its cluster
+func (m *Memory) GetCoverCandidates() ([]*CoverCandidate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var candidates []*CoverCandidate
+	for _, p := range m.plans {
+		if p.Action == "skip" || p.DestPath == "" {
+			continue
+		}
+		for winner := range m.members {
+			if winner.fileID != p.FileID {
+				continue
+			}
+			for member := range m.members {
+				if member.clusterKey != winner.clusterKey {
+					continue
+				}
+				for _, pic := range m.pictures[member.fileID] {
+					candidates = append(candidates, &CoverCandidate{DestPath: p.DestPath, FileID: member.fileID, Picture: pic})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.DestPath != b.DestPath {
+			return a.DestPath < b.DestPath
+		}
+		if a.FileID != b.FileID {
+			return a.FileID < b.FileID
+		}
+		return a.Picture.Position < b.Picture.Position
+	})
+	return candidates, nil
+}
+
+// ReplaceAlbumCovers replaces all chosen album covers
+func (m *Memory) ReplaceAlbumCovers(covers []*AlbumCover) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.covers = make(map[string]*AlbumCover, len(covers))
+	for _, cover := range covers {
+		c := *cover
+		m.covers[cover.AlbumDir] = &c
+	}
+	return nil
+}
+
+// GetAlbumCovers returns the chosen cover of every album folder
+func (m *Memory) GetAlbumCovers() ([]*AlbumCover, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var covers []*AlbumCover
+	for _, cover := range m.covers {
+		c := *cover
+		covers = append(covers, &c)
+	}
+	sort.Slice(covers, func(i, j int) bool { return covers[i].AlbumDir < covers[j].AlbumDir })
+	return covers, nil
+}
+
+// InsertOrUpdateExecution inserts or replaces an execution record
+func (m *Memory) InsertOrUpdateExecution(exec *Execution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := *exec
+	m.executions[exec.FileID] = &e
+	return nil
+}
+
+// BatchInsertOrUpdateExecution inserts or replaces multiple execution records
+func (m *Memory) BatchInsertOrUpdateExecution(executions []*Execution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, exec := range executions {
+		e := *exec
+		m.executions[exec.FileID] = &e
+	}
+	return nil
+}
+
+// GetExecution gets the execution record for a file; nil if there is none
+func (m *Memory) GetExecution(fileID int64) (*Execution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if exec, ok := m.executions[fileID]; ok {
+		e := *exec
+		return &e, nil
+	}
+	return nil, nil
+}
+
+// GetAllExecutionsMap returns all execution records indexed by file ID
+func (m *Memory) GetAllExecutionsMap() (map[int64]*Execution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[int64]*Execution, len(m.executions))
+	for id, exec := range m.executions {
+		e := *exec
+		result[id] = &e
+	}
+	return result, nil
+}
+
+// GetFingerprint returns the settings fingerprint recorded for a stage; "" if none
+func (m *Memory) GetFingerprint(stage string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.fingerprints[stage], nil
+}
+
+// SetFingerprint records the settings fingerprint of a stage; "" clears it
+func (m *Memory) SetFingerprint(stage, fingerprint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if fingerprint == "" {
+		delete(m.fingerprints, stage)
+	} else {
+		m.fingerprints[stage] = fingerprint
+	}
+	return nil
+}
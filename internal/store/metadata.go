@@ -7,14 +7,23 @@ import (
 
 // InsertMetadata inserts or replaces metadata for a file
 func (s *Store) InsertMetadata(m *Metadata) error {
-	_, err := s.db.Exec(`
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 		INSERT INTO metadata (
 			file_id, format, codec, container,
 			duration_ms, sample_rate, bit_depth, channels, bitrate_kbps, lossless,
 			tag_artist, tag_album, tag_title, tag_albumartist, tag_date,
 			tag_disc, tag_disc_total, tag_track, tag_track_total, tag_compilation,
-			musicbrainz_recording_id, musicbrainz_release_id, raw_tags_json
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			musicbrainz_recording_id, musicbrainz_release_id, isrc,
+			tag_genre, tag_composer, tag_conductor, tag_label, tag_catalog_number, tag_bpm,
+			tag_original_date, tag_artist_sort, tag_album_sort, tag_comment,
+			raw_tags_json, encoder_json, chapters, podcast_id, has_video, tag_artists
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(file_id) DO UPDATE SET
 			format = excluded.format,
 			codec = excluded.codec,
@@ -37,19 +46,55 @@ func (s *Store) InsertMetadata(m *Metadata) error {
 			tag_compilation = excluded.tag_compilation,
 			musicbrainz_recording_id = excluded.musicbrainz_recording_id,
 			musicbrainz_release_id = excluded.musicbrainz_release_id,
-			raw_tags_json = excluded.raw_tags_json
+			isrc = excluded.isrc,
+			tag_genre = excluded.tag_genre,
+			tag_composer = excluded.tag_composer,
+			tag_conductor = excluded.tag_conductor,
+			tag_label = excluded.tag_label,
+			tag_catalog_number = excluded.tag_catalog_number,
+			tag_bpm = excluded.tag_bpm,
+			tag_original_date = excluded.tag_original_date,
+			tag_artist_sort = excluded.tag_artist_sort,
+			tag_album_sort = excluded.tag_album_sort,
+			tag_comment = excluded.tag_comment,
+			raw_tags_json = excluded.raw_tags_json,
+			encoder_json = excluded.encoder_json,
+			chapters = excluded.chapters,
+			podcast_id = excluded.podcast_id,
+			has_video = excluded.has_video,
+			tag_artists = excluded.tag_artists,
+			integrity_status = NULL,
+			integrity_error = NULL,
+			decoded_duration_ms = NULL
 	`,
 		m.FileID, m.Format, m.Codec, m.Container,
 		m.DurationMs, m.SampleRate, m.BitDepth, m.Channels, m.BitrateKbps, m.Lossless,
 		m.TagArtist, m.TagAlbum, m.TagTitle, m.TagAlbumArtist, m.TagDate,
 		m.TagDisc, m.TagDiscTotal, m.TagTrack, m.TagTrackTotal, m.TagCompilation,
-		m.MusicBrainzRecordingID, m.MusicBrainzReleaseID, m.RawTagsJSON,
+		m.MusicBrainzRecordingID, m.MusicBrainzReleaseID, m.ISRC,
+		m.TagGenre, m.TagComposer, m.TagConductor, m.TagLabel, m.TagCatalogNumber, m.TagBPM,
+		m.TagOriginalDate, m.TagArtistSort, m.TagAlbumSort, m.TagComment,
+		m.RawTagsJSON, m.EncoderJSON, m.Chapters, m.PodcastID, m.HasVideo, m.TagArtists,
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert metadata: %w", err)
 	}
 
+	if err := replaceArtistCredits(tx, m.FileID, m.Artists); err != nil {
+		return err
+	}
+	if err := replacePictures(tx, m.FileID, m.Pictures); err != nil {
+		return err
+	}
+	if err := replaceProvenance(tx, m.FileID, m.Provenance); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit metadata: %w", err)
+	}
+
 	return nil
 }
 
@@ -65,15 +110,24 @@ func (s *Store) GetMetadata(fileID int64) (*Metadata, error) {
 		       COALESCE(tag_date, ''), COALESCE(tag_disc, 0), COALESCE(tag_disc_total, 0),
 		       COALESCE(tag_track, 0), COALESCE(tag_track_total, 0), COALESCE(tag_compilation, 0),
 		       COALESCE(musicbrainz_recording_id, ''),
-		       COALESCE(musicbrainz_release_id, ''),
-		       COALESCE(raw_tags_json, '')
+		       COALESCE(musicbrainz_release_id, ''), COALESCE(isrc, ''),
+		       COALESCE(tag_genre, ''), COALESCE(tag_composer, ''), COALESCE(tag_conductor, ''),
+		       COALESCE(tag_label, ''), COALESCE(tag_catalog_number, ''), COALESCE(tag_bpm, 0),
+		       COALESCE(tag_original_date, ''), COALESCE(tag_artist_sort, ''),
+		       COALESCE(tag_album_sort, ''), COALESCE(tag_comment, ''),
+		       COALESCE(raw_tags_json, ''), COALESCE(encoder_json, ''), COALESCE(chapters, 0), COALESCE(podcast_id, ''), COALESCE(has_video, 0), COALESCE(tag_artists, ''),
+		       COALESCE(integrity_status, ''), COALESCE(integrity_error, ''), COALESCE(decoded_duration_ms, 0)
 		FROM metadata WHERE file_id = ?
 	`, fileID).Scan(
 		&m.FileID, &m.Format, &m.Codec, &m.Container,
 		&m.DurationMs, &m.SampleRate, &m.BitDepth, &m.Channels, &m.BitrateKbps, &m.Lossless,
 		&m.TagArtist, &m.TagAlbum, &m.TagTitle, &m.TagAlbumArtist, &m.TagDate,
 		&m.TagDisc, &m.TagDiscTotal, &m.TagTrack, &m.TagTrackTotal, &m.TagCompilation,
-		&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.RawTagsJSON,
+		&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.ISRC,
+		&m.TagGenre, &m.TagComposer, &m.TagConductor, &m.TagLabel, &m.TagCatalogNumber, &m.TagBPM,
+		&m.TagOriginalDate, &m.TagArtistSort, &m.TagAlbumSort, &m.TagComment,
+		&m.RawTagsJSON, &m.EncoderJSON, &m.Chapters, &m.PodcastID, &m.HasVideo, &m.TagArtists,
+		&m.IntegrityStatus, &m.IntegrityError, &m.DecodedDurationMs,
 	)
 
 	if err == sql.ErrNoRows {
@@ -104,8 +158,13 @@ func (s *Store) GetFilesWithMetadata() ([]struct {
 			COALESCE(m.tag_date, ''), COALESCE(m.tag_disc, 0), COALESCE(m.tag_disc_total, 0),
 			COALESCE(m.tag_track, 0), COALESCE(m.tag_track_total, 0), COALESCE(m.tag_compilation, 0),
 			COALESCE(m.musicbrainz_recording_id, ''),
-			COALESCE(m.musicbrainz_release_id, ''),
-			COALESCE(m.raw_tags_json, '')
+			COALESCE(m.musicbrainz_release_id, ''), COALESCE(m.isrc, ''),
+			COALESCE(m.tag_genre, ''), COALESCE(m.tag_composer, ''), COALESCE(m.tag_conductor, ''),
+			COALESCE(m.tag_label, ''), COALESCE(m.tag_catalog_number, ''), COALESCE(m.tag_bpm, 0),
+			COALESCE(m.tag_original_date, ''), COALESCE(m.tag_artist_sort, ''),
+			COALESCE(m.tag_album_sort, ''), COALESCE(m.tag_comment, ''),
+			COALESCE(m.raw_tags_json, ''), COALESCE(m.encoder_json, ''), COALESCE(m.chapters, 0), COALESCE(m.podcast_id, ''), COALESCE(m.has_video, 0), COALESCE(m.tag_artists, ''),
+			COALESCE(m.integrity_status, ''), COALESCE(m.integrity_error, ''), COALESCE(m.decoded_duration_ms, 0)
 		FROM files f
 		INNER JOIN metadata m ON f.id = m.file_id
 		WHERE f.status = 'meta_ok'
@@ -134,7 +193,11 @@ func (s *Store) GetFilesWithMetadata() ([]struct {
 			&m.DurationMs, &m.SampleRate, &m.BitDepth, &m.Channels, &m.BitrateKbps, &m.Lossless,
 			&m.TagArtist, &m.TagAlbum, &m.TagTitle, &m.TagAlbumArtist, &m.TagDate,
 			&m.TagDisc, &m.TagDiscTotal, &m.TagTrack, &m.TagTrackTotal, &m.TagCompilation,
-			&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.RawTagsJSON,
+			&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.ISRC,
+			&m.TagGenre, &m.TagComposer, &m.TagConductor, &m.TagLabel, &m.TagCatalogNumber, &m.TagBPM,
+			&m.TagOriginalDate, &m.TagArtistSort, &m.TagAlbumSort, &m.TagComment,
+			&m.RawTagsJSON, &m.EncoderJSON, &m.Chapters, &m.PodcastID, &m.HasVideo, &m.TagArtists,
+			&m.IntegrityStatus, &m.IntegrityError, &m.DecodedDurationMs,
 		)
 
 		if err != nil {
@@ -157,8 +220,16 @@ func (s *Store) GetAllMetadata() (map[int64]*Metadata, error) {
 		       COALESCE(duration_ms, 0), COALESCE(sample_rate, 0), COALESCE(bit_depth, 0),
 		       COALESCE(channels, 0), COALESCE(bitrate_kbps, 0), COALESCE(lossless, 0),
 		       COALESCE(tag_artist, ''), COALESCE(tag_album, ''),
-		       COALESCE(tag_title, ''), COALESCE(tag_track, 0), COALESCE(tag_disc, 0),
-		       COALESCE(tag_date, ''), COALESCE(tag_albumartist, '')
+		       COALESCE(tag_title, ''), COALESCE(tag_track, 0), COALESCE(tag_track_total, 0),
+		       COALESCE(tag_disc, 0), COALESCE(tag_disc_total, 0),
+		       COALESCE(tag_date, ''), COALESCE(tag_albumartist, ''),
+		       COALESCE(musicbrainz_recording_id, ''), COALESCE(isrc, ''),
+		       COALESCE(tag_genre, ''), COALESCE(tag_composer, ''), COALESCE(tag_conductor, ''),
+		       COALESCE(tag_label, ''), COALESCE(tag_catalog_number, ''), COALESCE(tag_bpm, 0),
+		       COALESCE(tag_original_date, ''), COALESCE(tag_artist_sort, ''),
+		       COALESCE(tag_album_sort, ''), COALESCE(tag_comment, ''),
+		       COALESCE(encoder_json, ''), COALESCE(chapters, 0), COALESCE(podcast_id, ''), COALESCE(has_video, 0), COALESCE(tag_artists, ''),
+		       COALESCE(integrity_status, ''), COALESCE(integrity_error, ''), COALESCE(decoded_duration_ms, 0)
 		FROM metadata
 	`)
 	if err != nil {
@@ -174,8 +245,13 @@ func (s *Store) GetAllMetadata() (map[int64]*Metadata, error) {
 			&m.FileID, &m.Format, &m.Codec, &m.Container,
 			&m.DurationMs, &m.SampleRate, &m.BitDepth, &m.Channels, &m.BitrateKbps, &losslessInt,
 			&m.TagArtist, &m.TagAlbum,
-			&m.TagTitle, &m.TagTrack, &m.TagDisc, &m.TagDate,
+			&m.TagTitle, &m.TagTrack, &m.TagTrackTotal, &m.TagDisc, &m.TagDiscTotal, &m.TagDate,
 			&m.TagAlbumArtist,
+			&m.MusicBrainzRecordingID, &m.ISRC,
+			&m.TagGenre, &m.TagComposer, &m.TagConductor, &m.TagLabel, &m.TagCatalogNumber, &m.TagBPM,
+			&m.TagOriginalDate, &m.TagArtistSort, &m.TagAlbumSort, &m.TagComment,
+			&m.EncoderJSON, &m.Chapters, &m.PodcastID, &m.HasVideo, &m.TagArtists,
+			&m.IntegrityStatus, &m.IntegrityError, &m.DecodedDurationMs,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan metadata: %w", err)
@@ -204,9 +280,13 @@ func (s *Store) InsertMetadataBatch(metadataList []*Metadata) error {
 		INSERT OR REPLACE INTO metadata (
 			file_id, format, codec, container, duration_ms, sample_rate, bit_depth,
 			channels, bitrate_kbps, lossless,
-			tag_artist, tag_album, tag_title, tag_track, tag_disc, tag_date,
-			tag_albumartist, tag_compilation
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			tag_artist, tag_album, tag_title, tag_track, tag_track_total, tag_disc, tag_disc_total, tag_date,
+			tag_albumartist, tag_compilation,
+			musicbrainz_recording_id, musicbrainz_release_id, isrc,
+			tag_genre, tag_composer, tag_conductor, tag_label, tag_catalog_number, tag_bpm,
+			tag_original_date, tag_artist_sort, tag_album_sort, tag_comment,
+			encoder_json, chapters, podcast_id, has_video, tag_artists
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
@@ -227,25 +307,40 @@ func (s *Store) InsertMetadataBatch(metadataList []*Metadata) error {
 			m.FileID, m.Format, m.Codec, m.Container,
 			m.DurationMs, m.SampleRate, m.BitDepth, m.Channels,
 			m.BitrateKbps, losslessInt,
-			m.TagArtist, m.TagAlbum, m.TagTitle, m.TagTrack, m.TagDisc, m.TagDate,
+			m.TagArtist, m.TagAlbum, m.TagTitle, m.TagTrack, m.TagTrackTotal, m.TagDisc, m.TagDiscTotal, m.TagDate,
 			m.TagAlbumArtist, compilationInt,
+			m.MusicBrainzRecordingID, m.MusicBrainzReleaseID, m.ISRC,
+			m.TagGenre, m.TagComposer, m.TagConductor, m.TagLabel, m.TagCatalogNumber, m.TagBPM,
+			m.TagOriginalDate, m.TagArtistSort, m.TagAlbumSort, m.TagComment,
+			m.EncoderJSON, m.Chapters, m.PodcastID, m.HasVideo, m.TagArtists,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to insert metadata for file %d: %w", m.FileID, err)
 		}
+		if err := replaceArtistCredits(tx, m.FileID, m.Artists); err != nil {
+			return err
+		}
+		if err := replacePictures(tx, m.FileID, m.Pictures); err != nil {
+			return err
+		}
+		if err := replaceProvenance(tx, m.FileID, m.Provenance); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
 }
 
 // GetAllUniqueArtists returns all unique artist names from the metadata table
-// Returns both tag_artist and tag_albumartist values (deduplicated)
+// Returns tag_artist, tag_albumartist and individually credited artist names (deduplicated)
 func (s *Store) GetAllUniqueArtists() ([]string, error) {
 	rows, err := s.db.Query(`
 		SELECT DISTINCT artist_name FROM (
 			SELECT tag_artist AS artist_name FROM metadata WHERE tag_artist != ''
 			UNION
 			SELECT tag_albumartist AS artist_name FROM metadata WHERE tag_albumartist != ''
+			UNION
+			SELECT name AS artist_name FROM metadata_artists
 		)
 		ORDER BY artist_name
 	`)
@@ -278,8 +373,13 @@ func (s *Store) GetMetadataByFileID(fileID int64) (*Metadata, error) {
 		       COALESCE(tag_albumartist, ''), COALESCE(tag_date, ''),
 		       COALESCE(tag_disc, 0), COALESCE(tag_disc_total, 0), COALESCE(tag_track, 0),
 		       COALESCE(tag_track_total, 0), COALESCE(tag_compilation, 0),
-		       COALESCE(musicbrainz_recording_id, ''), COALESCE(musicbrainz_release_id, ''),
-		       COALESCE(raw_tags_json, '')
+		       COALESCE(musicbrainz_recording_id, ''), COALESCE(musicbrainz_release_id, ''), COALESCE(isrc, ''),
+		       COALESCE(tag_genre, ''), COALESCE(tag_composer, ''), COALESCE(tag_conductor, ''),
+		       COALESCE(tag_label, ''), COALESCE(tag_catalog_number, ''), COALESCE(tag_bpm, 0),
+		       COALESCE(tag_original_date, ''), COALESCE(tag_artist_sort, ''),
+		       COALESCE(tag_album_sort, ''), COALESCE(tag_comment, ''),
+		       COALESCE(raw_tags_json, ''), COALESCE(encoder_json, ''), COALESCE(chapters, 0), COALESCE(podcast_id, ''), COALESCE(has_video, 0), COALESCE(tag_artists, ''),
+		       COALESCE(integrity_status, ''), COALESCE(integrity_error, ''), COALESCE(decoded_duration_ms, 0)
 		FROM metadata
 		WHERE file_id = ?
 	`, fileID).Scan(
@@ -289,7 +389,11 @@ func (s *Store) GetMetadataByFileID(fileID int64) (*Metadata, error) {
 		&m.TagAlbumArtist, &m.TagDate,
 		&m.TagDisc, &m.TagDiscTotal, &m.TagTrack, &m.TagTrackTotal, &m.TagCompilation,
 		&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID,
-		&m.RawTagsJSON,
+		&m.ISRC,
+		&m.TagGenre, &m.TagComposer, &m.TagConductor, &m.TagLabel, &m.TagCatalogNumber, &m.TagBPM,
+		&m.TagOriginalDate, &m.TagArtistSort, &m.TagAlbumSort, &m.TagComment,
+		&m.RawTagsJSON, &m.EncoderJSON, &m.Chapters, &m.PodcastID, &m.HasVideo, &m.TagArtists,
+		&m.IntegrityStatus, &m.IntegrityError, &m.DecodedDurationMs,
 	)
 
 	if err == sql.ErrNoRows {
@@ -318,7 +422,18 @@ type MetadataQueryOptions struct {
 	EmptyTitle   bool
 	ShowErrors   bool
 	Limit        int
-	SortBy       string // "artist", "album", "title", "path", "duration", "bitrate"
+	SortBy       string // "artist", "album", "title", "path", "duration", "bitrate", "genre", "composer", "bpm"
+
+	// Extended tag filters
+	Genre         string // Partial match
+	Composer      string // Partial match
+	Conductor     string // Partial match
+	Label         string // Partial match
+	CatalogNumber string // Case-insensitive exact match
+	ISRC          string // Exact match (normalized)
+	BPMMin        int    // 0 = no lower bound
+	BPMMax        int    // 0 = no upper bound
+	EmptyGenre    bool
 }
 
 // FileWithMetadata combines file and metadata information
@@ -342,8 +457,13 @@ func (s *Store) QueryFilesWithMetadata(opts *MetadataQueryOptions) ([]*FileWithM
 			COALESCE(m.tag_date, ''), COALESCE(m.tag_disc, 0), COALESCE(m.tag_disc_total, 0),
 			COALESCE(m.tag_track, 0), COALESCE(m.tag_track_total, 0), COALESCE(m.tag_compilation, 0),
 			COALESCE(m.musicbrainz_recording_id, ''),
-			COALESCE(m.musicbrainz_release_id, ''),
-			COALESCE(m.raw_tags_json, '')
+			COALESCE(m.musicbrainz_release_id, ''), COALESCE(m.isrc, ''),
+			COALESCE(m.tag_genre, ''), COALESCE(m.tag_composer, ''), COALESCE(m.tag_conductor, ''),
+			COALESCE(m.tag_label, ''), COALESCE(m.tag_catalog_number, ''), COALESCE(m.tag_bpm, 0),
+			COALESCE(m.tag_original_date, ''), COALESCE(m.tag_artist_sort, ''),
+			COALESCE(m.tag_album_sort, ''), COALESCE(m.tag_comment, ''),
+			COALESCE(m.raw_tags_json, ''), COALESCE(m.encoder_json, ''), COALESCE(m.chapters, 0), COALESCE(m.podcast_id, ''), COALESCE(m.has_video, 0), COALESCE(m.tag_artists, ''),
+			COALESCE(m.integrity_status, ''), COALESCE(m.integrity_error, ''), COALESCE(m.decoded_duration_ms, 0)
 		FROM files f
 		INNER JOIN metadata m ON f.id = m.file_id
 		WHERE 1=1
@@ -353,8 +473,9 @@ func (s *Store) QueryFilesWithMetadata(opts *MetadataQueryOptions) ([]*FileWithM
 
 	// Artist filter
 	if opts.Artist != "" {
-		query += " AND (m.tag_artist = ? OR m.tag_albumartist = ?)"
-		args = append(args, opts.Artist, opts.Artist)
+		query += ` AND (m.tag_artist = ? OR m.tag_albumartist = ? OR EXISTS (
+			SELECT 1 FROM metadata_artists ma WHERE ma.file_id = m.file_id AND ma.name = ? COLLATE NOCASE))`
+		args = append(args, opts.Artist, opts.Artist, opts.Artist)
 	}
 
 	// Album filter
@@ -406,6 +527,43 @@ func (s *Store) QueryFilesWithMetadata(opts *MetadataQueryOptions) ([]*FileWithM
 		query += " AND (m.tag_title = '' OR m.tag_title IS NULL)"
 	}
 
+	// Extended tag filters
+	if opts.Genre != "" {
+		query += " AND m.tag_genre LIKE ?"
+		args = append(args, "%"+opts.Genre+"%")
+	}
+	if opts.Composer != "" {
+		query += " AND m.tag_composer LIKE ?"
+		args = append(args, "%"+opts.Composer+"%")
+	}
+	if opts.Conductor != "" {
+		query += " AND m.tag_conductor LIKE ?"
+		args = append(args, "%"+opts.Conductor+"%")
+	}
+	if opts.Label != "" {
+		query += " AND m.tag_label LIKE ?"
+		args = append(args, "%"+opts.Label+"%")
+	}
+	if opts.CatalogNumber != "" {
+		query += " AND m.tag_catalog_number = ? COLLATE NOCASE"
+		args = append(args, opts.CatalogNumber)
+	}
+	if opts.ISRC != "" {
+		query += " AND m.isrc = ?"
+		args = append(args, opts.ISRC)
+	}
+	if opts.BPMMin > 0 {
+		query += " AND m.tag_bpm >= ?"
+		args = append(args, opts.BPMMin)
+	}
+	if opts.BPMMax > 0 {
+		query += " AND m.tag_bpm > 0 AND m.tag_bpm <= ?"
+		args = append(args, opts.BPMMax)
+	}
+	if opts.EmptyGenre {
+		query += " AND (m.tag_genre = '' OR m.tag_genre IS NULL)"
+	}
+
 	// Error status filter
 	if opts.ShowErrors {
 		query += " AND f.status = 'meta_error'"
@@ -424,6 +582,12 @@ func (s *Store) QueryFilesWithMetadata(opts *MetadataQueryOptions) ([]*FileWithM
 		sortColumn = "m.duration_ms DESC"
 	case "bitrate":
 		sortColumn = "m.bitrate_kbps DESC"
+	case "genre":
+		sortColumn = "m.tag_genre, m.tag_artist, m.tag_album, m.tag_track"
+	case "composer":
+		sortColumn = "m.tag_composer, m.tag_album, m.tag_track"
+	case "bpm":
+		sortColumn = "m.tag_bpm DESC"
 	default:
 		sortColumn = "f.src_path"
 	}
@@ -455,7 +619,11 @@ func (s *Store) QueryFilesWithMetadata(opts *MetadataQueryOptions) ([]*FileWithM
 			&m.DurationMs, &m.SampleRate, &m.BitDepth, &m.Channels, &m.BitrateKbps, &m.Lossless,
 			&m.TagArtist, &m.TagAlbum, &m.TagTitle, &m.TagAlbumArtist, &m.TagDate,
 			&m.TagDisc, &m.TagDiscTotal, &m.TagTrack, &m.TagTrackTotal, &m.TagCompilation,
-			&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.RawTagsJSON,
+			&m.MusicBrainzRecordingID, &m.MusicBrainzReleaseID, &m.ISRC,
+			&m.TagGenre, &m.TagComposer, &m.TagConductor, &m.TagLabel, &m.TagCatalogNumber, &m.TagBPM,
+			&m.TagOriginalDate, &m.TagArtistSort, &m.TagAlbumSort, &m.TagComment,
+			&m.RawTagsJSON, &m.EncoderJSON, &m.Chapters, &m.PodcastID, &m.HasVideo, &m.TagArtists,
+			&m.IntegrityStatus, &m.IntegrityError, &m.DecodedDurationMs,
 		)
 
 		if err != nil {
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Override kinds
+const (
+	OverrideMerge   = "merge"   // Move all members of OtherKey (or OtherFileID's cluster) into ClusterKey (or FileID's cluster)
+	OverrideSplit   = "split"   // Move FileID out of ClusterKey into OtherKey
+	OverridePin     = "pin"     // Force FileID to win its cluster
+	OverrideExclude = "exclude" // Keep FileID out of every cluster
+)
+
+// ClusterOverride is a manual correction applied after automatic clustering
+// Merges record a member file of each cluster (FileID, OtherFileID) so they
+// still apply when cluster keys change
+type ClusterOverride struct {
+	ID          int64
+	Kind        string
+	ClusterKey  string
+	OtherKey    string
+	FileID      int64
+	OtherFileID int64
+	Note        string
+	CreatedAt   time.Time
+}
+
+// InsertClusterOverride stores a manual override and sets its ID
+func (s *Store) InsertClusterOverride(o *ClusterOverride) error {
+	var fileID, otherFileID interface{}
+	if o.FileID > 0 {
+		fileID = o.FileID
+	}
+	if o.OtherFileID > 0 {
+		otherFileID = o.OtherFileID
+	}
+
+	result, err := s.db.Exec(`
+		INSERT INTO cluster_overrides (kind, cluster_key, other_key, file_id, other_file_id, note)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, o.Kind, o.ClusterKey, o.OtherKey, fileID, otherFileID, o.Note)
+	if err != nil {
+		return fmt.Errorf("failed to insert cluster override: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get override ID: %w", err)
+	}
+	o.ID = id
+
+	return nil
+}
+
+// GetClusterOverrides returns all manual overrides in creation order
+func (s *Store) GetClusterOverrides() ([]*ClusterOverride, error) {
+	rows, err := s.db.Query(`
+		SELECT id, kind, cluster_key, COALESCE(other_key, ''), COALESCE(file_id, 0),
+		       COALESCE(other_file_id, 0), COALESCE(note, ''), created_at
+		FROM cluster_overrides
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cluster overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []*ClusterOverride
+	for rows.Next() {
+		var o ClusterOverride
+		if err := rows.Scan(&o.ID, &o.Kind, &o.ClusterKey, &o.OtherKey, &o.FileID, &o.OtherFileID, &o.Note, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster override: %w", err)
+		}
+		overrides = append(overrides, &o)
+	}
+
+	return overrides, rows.Err()
+}
+
+// GetClusterOverride returns a single override by ID
+func (s *Store) GetClusterOverride(id int64) (*ClusterOverride, error) {
+	var o ClusterOverride
+	err := s.db.QueryRow(`
+		SELECT id, kind, cluster_key, COALESCE(other_key, ''), COALESCE(file_id, 0),
+		       COALESCE(other_file_id, 0), COALESCE(note, ''), created_at
+		FROM cluster_overrides
+		WHERE id = ?
+	`, id).Scan(&o.ID, &o.Kind, &o.ClusterKey, &o.OtherKey, &o.FileID, &o.OtherFileID, &o.Note, &o.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster override: %w", err)
+	}
+
+	return &o, nil
+}
+
+// UpdateClusterOverrideKeys records the cluster keys an override resolved to
+// after the keys of its anchor files changed
+func (s *Store) UpdateClusterOverrideKeys(id int64, clusterKey, otherKey string) error {
+	_, err := s.db.Exec(`UPDATE cluster_overrides SET cluster_key = ?, other_key = ? WHERE id = ?`, clusterKey, otherKey, id)
+	if err != nil {
+		return fmt.Errorf("failed to update cluster override: %w", err)
+	}
+	return nil
+}
+
+// DeleteClusterOverride removes an override by ID
+func (s *Store) DeleteClusterOverride(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM cluster_overrides WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete cluster override: %w", err)
+	}
+	return nil
+}
+
+// DeleteFileOverrides removes all overrides of a kind for a file
+func (s *Store) DeleteFileOverrides(kind string, fileID int64) error {
+	_, err := s.db.Exec(`DELETE FROM cluster_overrides WHERE kind = ? AND file_id = ?`, kind, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s overrides: %w", kind, err)
+	}
+	return nil
+}
+
+// GetOverrideFileIDs returns the set of files with an override of the given kind
+// (e.g. OverridePin for pinned winners, OverrideExclude for excluded files)
+func (s *Store) GetOverrideFileIDs(kind string) (map[int64]bool, error) {
+	rows, err := s.db.Query(`SELECT file_id FROM cluster_overrides WHERE kind = ? AND file_id IS NOT NULL`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s overrides: %w", kind, err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]bool)
+	for rows.Next() {
+		var fileID int64
+		if err := rows.Scan(&fileID); err != nil {
+			return nil, fmt.Errorf("failed to scan override file: %w", err)
+		}
+		result[fileID] = true
+	}
+
+	return result, rows.Err()
+}
+
+// GetOverriddenClusterKeys returns the override kinds affecting each current cluster
+// A cluster is overridden if it is a merge target, lost a file to exclude/split,
+// or currently contains a pinned or split file
+func (s *Store) GetOverriddenClusterKeys() (map[string][]string, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT cluster_key, kind FROM (
+			SELECT cluster_key, kind FROM cluster_overrides
+			WHERE kind IN ('merge', 'exclude', 'split')
+			UNION ALL
+			SELECT cm.cluster_key, o.kind
+			FROM cluster_overrides o
+			INNER JOIN cluster_members cm ON cm.file_id = o.file_id
+			WHERE o.kind IN ('pin', 'split')
+		)
+		WHERE cluster_key IN (SELECT cluster_key FROM clusters)
+		ORDER BY cluster_key, kind
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overridden clusters: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]string)
+	for rows.Next() {
+		var clusterKey, kind string
+		if err := rows.Scan(&clusterKey, &kind); err != nil {
+			return nil, fmt.Errorf("failed to scan overridden cluster: %w", err)
+		}
+		result[clusterKey] = append(result[clusterKey], kind)
+	}
+
+	return result, rows.Err()
+}
+
+// MoveClusterMembers reassigns files to another cluster and resets their scores
+// Manual moves are treated as certain, so confidence is reset to 1.0
+func (s *Store) MoveClusterMembers(fileIDs []int64, toKey string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	memberStmt, err := tx.Prepare(`UPDATE cluster_members SET cluster_key = ?, quality_score = 0.0, preferred = 0, confidence = 1.0 WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer memberStmt.Close()
+
+	trackStmt, err := tx.Prepare(`UPDATE clustered_files SET cluster_key = ? WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer trackStmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := memberStmt.Exec(toKey, id); err != nil {
+			return fmt.Errorf("failed to move cluster member %d: %w", id, err)
+		}
+		if _, err := trackStmt.Exec(toKey, id); err != nil {
+			return fmt.Errorf("failed to move clustered file %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveClusterMembers removes cluster memberships but keeps clustered_files
+// records, so incremental clustering doesn't pick the files up again
+func (s *Store) RemoveClusterMembers(fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM cluster_members WHERE file_id = ?`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := stmt.Exec(id); err != nil {
+			return fmt.Errorf("failed to remove cluster member %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
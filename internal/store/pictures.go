@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PictureFrontCover is the ID3/FLAC picture type of a front cover
+const PictureFrontCover = 3
+
+// Picture describes an image embedded in an audio file
+type Picture struct {
+	Position  int    // Order within the file, starting at 0
+	Type      int    // ID3/FLAC picture type (PictureFrontCover, ...)
+	MIMEType  string // e.g. "image/jpeg"; empty if unknown
+	Width     int    // 0 if the image could not be decoded
+	Height    int
+	SizeBytes int
+	SHA1      string // Hash of the image data
+}
+
+// AlbumCover is the cover the planner chose for a destination album folder
+type AlbumCover struct {
+	AlbumDir string
+	FileID   int64 // File the picture is embedded in
+	SHA1     string
+	MIMEType string
+	Width    int
+	Height   int
+}
+
+// CoverCandidate is a picture that can serve as the cover of a planned file's
+// album: one embedded in the file itself or in a duplicate of it
+type CoverCandidate struct {
+	DestPath string // Destination path of the planned file
+	FileID   int64  // File the picture is embedded in
+	Picture  Picture
+}
+
+// replacePictures rewrites the pictures of a file within a transaction
+func replacePictures(tx *sql.Tx, fileID int64, pictures []Picture) error {
+	if _, err := tx.Exec(`DELETE FROM pictures WHERE file_id = ?`, fileID); err != nil {
+		return fmt.Errorf("failed to clear pictures: %w", err)
+	}
+
+	for _, p := range pictures {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO pictures (file_id, position, picture_type, mime, width, height, size_bytes, sha1)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, fileID, p.Position, p.Type, p.MIMEType, p.Width, p.Height, p.SizeBytes, p.SHA1); err != nil {
+			return fmt.Errorf("failed to insert picture: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetPictures returns the pictures embedded in a file, in file order
+func (s *Store) GetPictures(fileID int64) ([]Picture, error) {
+	rows, err := s.db.Query(`
+		SELECT position, picture_type, COALESCE(mime, ''), COALESCE(width, 0), COALESCE(height, 0),
+		       COALESCE(size_bytes, 0), sha1
+		FROM pictures
+		WHERE file_id = ?
+		ORDER BY position
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pictures: %w", err)
+	}
+	defer rows.Close()
+
+	var pictures []Picture
+	for rows.Next() {
+		var p Picture
+		if err := rows.Scan(&p.Position, &p.Type, &p.MIMEType, &p.Width, &p.Height, &p.SizeBytes, &p.SHA1); err != nil {
+			return nil, fmt.Errorf("failed to scan picture: %w", err)
+		}
+		pictures = append(pictures, p)
+	}
+
+	return pictures, rows.Err()
+}
+
+// GetCoverCandidates returns the pictures of every planned (non-skip) file and of
+// the other members of its cluster, so a cover can come from a duplicate
+func (s *Store) GetCoverCandidates() ([]*CoverCandidate, error) {
+	rows, err := s.db.Query(`
+		SELECT p.dest_path, pic.file_id, pic.position, pic.picture_type, COALESCE(pic.mime, ''),
+		       COALESCE(pic.width, 0), COALESCE(pic.height, 0), COALESCE(pic.size_bytes, 0), pic.sha1
+		FROM plans p
+		INNER JOIN cluster_members winner ON winner.file_id = p.file_id
+		INNER JOIN cluster_members member ON member.cluster_key = winner.cluster_key
+		INNER JOIN pictures pic ON pic.file_id = member.file_id
+		WHERE p.action != 'skip' AND p.dest_path != ''
+		ORDER BY p.dest_path, pic.file_id, pic.position
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cover candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*CoverCandidate
+	for rows.Next() {
+		c := &CoverCandidate{}
+		p := &c.Picture
+		if err := rows.Scan(&c.DestPath, &c.FileID, &p.Position, &p.Type, &p.MIMEType,
+			&p.Width, &p.Height, &p.SizeBytes, &p.SHA1); err != nil {
+			return nil, fmt.Errorf("failed to scan cover candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// ReplaceAlbumCovers replaces all chosen album covers
+func (s *Store) ReplaceAlbumCovers(covers []*AlbumCover) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM album_covers`); err != nil {
+		return fmt.Errorf("failed to clear album covers: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT OR REPLACE INTO album_covers (album_dir, file_id, sha1, mime, width, height)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range covers {
+		if _, err := stmt.Exec(c.AlbumDir, c.FileID, c.SHA1, c.MIMEType, c.Width, c.Height); err != nil {
+			return fmt.Errorf("failed to insert album cover: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAlbumCovers returns the chosen cover of every album folder
+func (s *Store) GetAlbumCovers() ([]*AlbumCover, error) {
+	rows, err := s.db.Query(`
+		SELECT album_dir, file_id, sha1, COALESCE(mime, ''), COALESCE(width, 0), COALESCE(height, 0)
+		FROM album_covers
+		ORDER BY album_dir
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query album covers: %w", err)
+	}
+	defer rows.Close()
+
+	var covers []*AlbumCover
+	for rows.Next() {
+		c := &AlbumCover{}
+		if err := rows.Scan(&c.AlbumDir, &c.FileID, &c.SHA1, &c.MIMEType, &c.Width, &c.Height); err != nil {
+			return nil, fmt.Errorf("failed to scan album cover: %w", err)
+		}
+		covers = append(covers, c)
+	}
+
+	return covers, rows.Err()
+}
@@ -91,6 +91,13 @@ func (s *Store) CountPlansByAction(action string) (int, error) {
 	return count, err
 }
 
+// CountPlans returns the total number of plans
+func (s *Store) CountPlans() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM plans`).Scan(&count)
+	return count, err
+}
+
 // ClearPlans removes all plans (for idempotent re-planning)
 func (s *Store) ClearPlans() error {
 	_, err := s.db.Exec(`DELETE FROM plans`)
@@ -123,3 +130,44 @@ func (s *Store) InsertPlanBatch(plans []*Plan) error {
 
 	return tx.Commit()
 }
+
+// DeletePlansByFileIDs removes plans for the given files
+func (s *Store) DeletePlansByFileIDs(fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`DELETE FROM plans WHERE file_id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := stmt.Exec(id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CountPlansInconsistentWith returns the number of non-skip plans whose action
+// differs from mode or whose destination lies outside destRoot
+// A non-zero count means existing plans can't be updated incrementally
+func (s *Store) CountPlansInconsistentWith(destRoot, mode string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM plans
+		WHERE action != 'skip'
+		  AND (action != ? OR substr(CAST(COALESCE(dest_path, '') AS BLOB), 1, ?) != CAST(? AS BLOB))
+	`, mode, len(destRoot), destRoot).Scan(&count)
+
+	return count, err
+}
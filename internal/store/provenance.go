@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Sources of metadata field values
+const (
+	SourceTag      = "tag"      // Read from the file's tags by the tag library
+	SourceFFprobe  = "ffprobe"  // Read from the file's tags by ffprobe
+	SourceFilename = "filename" // Parsed from the file name
+	SourceFolder   = "folder"   // Parsed from the folder names
+	SourceSibling  = "sibling"  // Voted by the other files in the folder
+	SourceRule     = "rule"     // Rewritten by a metadata cleaning rule
+)
+
+// FieldProvenance records where the value of a metadata field came from
+type FieldProvenance struct {
+	Field      string  // Metadata field name, as in cleaning rules: artist, album, title, track, ...
+	Source     string  // SourceTag, SourceFilename, ...
+	Confidence float64 // 0.0-1.0; values read from tags are 1.0
+	Value      string  // Value the source produced
+	Detail     string  // e.g. the rule name or the folder the value was parsed from
+}
+
+// Inferred reports whether the value was guessed rather than read from tags
+func (p *FieldProvenance) Inferred() bool {
+	return p.Source != SourceTag && p.Source != SourceFFprobe
+}
+
+// replaceProvenance rewrites the field provenance of a file within a transaction
+func replaceProvenance(tx *sql.Tx, fileID int64, provenance []FieldProvenance) error {
+	if _, err := tx.Exec(`DELETE FROM metadata_provenance WHERE file_id = ?`, fileID); err != nil {
+		return fmt.Errorf("failed to clear provenance: %w", err)
+	}
+
+	for _, p := range provenance {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO metadata_provenance (file_id, field, source, confidence, value, detail)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, fileID, p.Field, p.Source, p.Confidence, p.Value, p.Detail); err != nil {
+			return fmt.Errorf("failed to insert provenance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetProvenance returns the field provenance of a file, ordered by field
+func (s *Store) GetProvenance(fileID int64) ([]FieldProvenance, error) {
+	rows, err := s.db.Query(`
+		SELECT field, source, confidence, COALESCE(value, ''), COALESCE(detail, '')
+		FROM metadata_provenance
+		WHERE file_id = ?
+		ORDER BY field
+	`, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provenance: %w", err)
+	}
+	defer rows.Close()
+
+	var provenance []FieldProvenance
+	for rows.Next() {
+		var p FieldProvenance
+		if err := rows.Scan(&p.Field, &p.Source, &p.Confidence, &p.Value, &p.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan provenance: %w", err)
+		}
+		provenance = append(provenance, p)
+	}
+
+	return provenance, rows.Err()
+}
+
+// GetInferredProvenance returns the provenance of inferred (not read from tags)
+// field values of all files, keyed by file ID
+func (s *Store) GetInferredProvenance() (map[int64][]FieldProvenance, error) {
+	rows, err := s.db.Query(`
+		SELECT file_id, field, source, confidence, COALESCE(value, ''), COALESCE(detail, '')
+		FROM metadata_provenance
+		WHERE source NOT IN (?, ?)
+		ORDER BY file_id, field
+	`, SourceTag, SourceFFprobe)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provenance: %w", err)
+	}
+	defer rows.Close()
+
+	provenance := make(map[int64][]FieldProvenance)
+	for rows.Next() {
+		var fileID int64
+		var p FieldProvenance
+		if err := rows.Scan(&fileID, &p.Field, &p.Source, &p.Confidence, &p.Value, &p.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan provenance: %w", err)
+		}
+		provenance[fileID] = append(provenance[fileID], p)
+	}
+
+	return provenance, rows.Err()
+}
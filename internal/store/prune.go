@@ -0,0 +1,185 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StatusMissing marks files whose source path no longer exists
+const StatusMissing = "missing"
+
+// GetPruneCandidates returns the files whose source path should still exist:
+// every file not already missing, except executed files (their source may have
+// been moved away or cleaned up, and their plan records what is in the destination)
+func (s *Store) GetPruneCandidates() ([]*File, error) {
+	rows, err := s.db.Query(`
+		SELECT f.id, f.file_key, f.src_path, f.size_bytes, f.mtime_unix, f.status, COALESCE(f.source_id, 0)
+		FROM files f
+		WHERE f.status != ?
+		  AND NOT EXISTS (SELECT 1 FROM executions e WHERE e.file_id = f.id AND e.verify_ok = 1)
+		ORDER BY f.src_path
+	`, StatusMissing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prune candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*File
+	for rows.Next() {
+		f := &File{}
+		if err := rows.Scan(&f.ID, &f.FileKey, &f.SrcPath, &f.SizeBytes, &f.MtimeUnix, &f.Status, &f.SourceID); err != nil {
+			return nil, fmt.Errorf("failed to scan prune candidate: %w", err)
+		}
+		files = append(files, f)
+	}
+
+	return files, rows.Err()
+}
+
+// MarkFilesMissing sets status=missing on the given files and removes their
+// cluster memberships, clustered_files records and plans in one transaction
+// Returns the keys of the clusters the files belonged to and the number of plans removed.
+func (s *Store) MarkFilesMissing(fileIDs []int64) ([]string, int, error) {
+	if len(fileIDs) == 0 {
+		return nil, 0, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	keyStmt, err := tx.Prepare(`SELECT cluster_key FROM cluster_members WHERE file_id = ?`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer keyStmt.Close()
+
+	memberStmt, err := tx.Prepare(`DELETE FROM cluster_members WHERE file_id = ?`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer memberStmt.Close()
+
+	trackStmt, err := tx.Prepare(`DELETE FROM clustered_files WHERE file_id = ?`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer trackStmt.Close()
+
+	planStmt, err := tx.Prepare(`DELETE FROM plans WHERE file_id = ?`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer planStmt.Close()
+
+	statusStmt, err := tx.Prepare(`
+		UPDATE files SET status = ?, error = NULL, last_update_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer statusStmt.Close()
+
+	touched := make(map[string]bool)
+	plansRemoved := 0
+	for _, id := range fileIDs {
+		rows, err := keyStmt.Query(id)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to query clusters of file %d: %w", id, err)
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return nil, 0, fmt.Errorf("failed to scan cluster key: %w", err)
+			}
+			touched[key] = true
+		}
+		rows.Close()
+
+		if _, err := memberStmt.Exec(id); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete cluster member %d: %w", id, err)
+		}
+		if _, err := trackStmt.Exec(id); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete clustered file %d: %w", id, err)
+		}
+		result, err := planStmt.Exec(id)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to delete plan %d: %w", id, err)
+		}
+		n, _ := result.RowsAffected()
+		plansRemoved += int(n)
+		if _, err := statusStmt.Exec(StatusMissing, id); err != nil {
+			return nil, 0, fmt.Errorf("failed to mark file %d missing: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	keys := make([]string, 0, len(touched))
+	for key := range touched {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, plansRemoved, nil
+}
+
+// GetMissingFileKeys returns the IDs of missing files, keyed by file key
+func (s *Store) GetMissingFileKeys() (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT file_key, id FROM files WHERE status = ?`, StatusMissing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missing files: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var id int64
+		if err := rows.Scan(&key, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan missing file: %w", err)
+		}
+		result[key] = id
+	}
+
+	return result, rows.Err()
+}
+
+// RestoreMissingFiles resets missing files that reappeared to discovered so
+// their metadata is re-extracted and they are clustered again
+func (s *Store) RestoreMissingFiles(fileIDs []int64) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE files SET status = 'discovered', last_update_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range fileIDs {
+		if _, err := stmt.Exec(id, StatusMissing); err != nil {
+			return fmt.Errorf("failed to restore file %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
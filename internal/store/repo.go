@@ -0,0 +1,157 @@
+package store
+
+// The pipeline stages depend on the narrow interfaces below rather than on
+// *Store, so they can run against the in-memory implementation in tests.
+// Both implementations must pass the conformance suite in storetest.
+
+// FileRepo stores discovered files
+type FileRepo interface {
+	InsertFile(f *File) error
+	InsertFileBatch(files []*File) error
+	RelinkFileBatch(files []*File) error
+	GetFileByID(id int64) (*File, error)
+	GetFileByKey(fileKey string) (*File, error)
+	GetFilesByStatus(status string) ([]*File, error)
+	GetFilesByDirectory(dirPath string) ([]*File, error)
+	GetAllFiles() ([]*File, error)
+	GetAllFilesMap() (map[int64]*File, error)
+	GetAllFileKeysMap() (map[string]bool, error)
+	GetMissingFileKeys() (map[string]int64, error)
+	RestoreMissingFiles(fileIDs []int64) error
+	UpdateFileStatus(fileID int64, status string, errorMsg string) error
+	BatchUpdateFileStatus(updates []struct {
+		FileID   int64
+		Status   string
+		ErrorMsg string
+	}) error
+	GetContentIDIndex() (map[string][]*File, error)
+	GetFileKeysWithoutContentID() (map[string]int64, error)
+	SetContentIDs(contentIDs map[int64]string) error
+}
+
+// SourceRepo stores the labelled source roots files are scanned from
+type SourceRepo interface {
+	UpsertSource(src *Source) error
+	AssignSource(sourceID int64, root string) (int, error)
+	GetSourcePriorities() (map[int64]int, error)
+}
+
+// MetadataRepo stores extracted metadata and where its values came from
+type MetadataRepo interface {
+	InsertMetadata(m *Metadata) error
+	InsertMetadataBatch(metadataList []*Metadata) error
+	GetMetadata(fileID int64) (*Metadata, error)
+	GetMetadataByFileID(fileID int64) (*Metadata, error)
+	GetAllMetadata() (map[int64]*Metadata, error)
+	GetProvenance(fileID int64) ([]FieldProvenance, error)
+	GetInferredProvenance() (map[int64][]FieldProvenance, error)
+}
+
+// ClusterRepo stores duplicate clusters, their members' scores and the
+// manual overrides applied to them
+type ClusterRepo interface {
+	InsertClusterBatch(clusters []*Cluster) error
+	EnsureClusterBatch(clusters []*Cluster) error
+	GetAllClusters() ([]*Cluster, error)
+	CountClusters() (int, error)
+	CountDuplicateClusters() (int, error)
+	ClearClusters() error
+	DeleteEmptyClusters(clusterKeys []string) (int, error)
+	MarkClustersDirty(clusterKeys []string) error
+	GetDirtyClusters() ([]*Cluster, error)
+	ClearDirtyClusters() error
+
+	InsertClusterMemberBatch(members []*ClusterMember) error
+	GetClusterMembers(clusterKey string) ([]*ClusterMember, error)
+	GetAllClusterMembers() (map[string][]*ClusterMember, error)
+	GetFileClusterKeys() (map[int64]string, error)
+	GetClusterMemberDurations() (map[int64]int, error)
+	MoveClusterMembers(fileIDs []int64, toKey string) error
+	RemoveClusterMembers(fileIDs []int64) error
+	DeleteClusterMembersByFileIDs(fileIDs []int64) error
+
+	BatchUpdateClusterMemberScores(updates []struct {
+		ClusterKey string
+		FileID     int64
+		Score      float64
+	}) error
+	BatchUpdateClusterMemberPreferred(updates []struct {
+		ClusterKey string
+		FileID     int64
+		Preferred  bool
+	}) error
+	ClearScores() error
+	CountWinners() (int, error)
+
+	InsertClusterOverride(o *ClusterOverride) error
+	GetClusterOverrides() ([]*ClusterOverride, error)
+	UpdateClusterOverrideKeys(id int64, clusterKey, otherKey string) error
+	GetOverrideFileIDs(kind string) (map[int64]bool, error)
+}
+
+// ClusterStateRepo stores the bookkeeping of incremental clustering: which
+// files were clustered with which metadata, progress of a running pass and
+// identifier reconciliation
+type ClusterStateRepo interface {
+	GetClusteringCandidates() ([]*ClusteringCandidate, error)
+	GetRemovedClusteredFiles() ([]*ClusteredFile, error)
+	UpsertClusteredFileBatch(files []*ClusteredFile) error
+	SyncClusteredFilesFromMembers() error
+
+	GetClusteringProgress() (*ClusteringProgress, error)
+	InitClusteringProgress(totalFiles int) error
+	UpdateClusteringProgress(lastFileID int64, filesProcessed, clustersCreated int) error
+	ClearClusteringProgress() error
+
+	GetIdentityMembers() ([]*IdentityMember, error)
+	GetHeuristicKeys() (map[int64]string, error)
+	SetHeuristicKeys(keys map[int64]string) error
+	ReplaceIdentityConflicts(conflicts []*IdentityConflict) error
+}
+
+// PlanRepo stores planned actions and the covers chosen for album folders
+type PlanRepo interface {
+	InsertPlan(plan *Plan) error
+	InsertPlanBatch(plans []*Plan) error
+	GetAllPlans() ([]*Plan, error)
+	CountPlans() (int, error)
+	ClearPlans() error
+	DeletePlansByFileIDs(fileIDs []int64) error
+	CountPlansInconsistentWith(destRoot, mode string) (int, error)
+
+	GetCoverCandidates() ([]*CoverCandidate, error)
+	ReplaceAlbumCovers(covers []*AlbumCover) error
+	GetAlbumCovers() ([]*AlbumCover, error)
+}
+
+// ExecutionRepo stores the results of executed plans
+type ExecutionRepo interface {
+	InsertOrUpdateExecution(exec *Execution) error
+	BatchInsertOrUpdateExecution(executions []*Execution) error
+	GetExecution(fileID int64) (*Execution, error)
+	GetAllExecutionsMap() (map[int64]*Execution, error)
+}
+
+// FingerprintRepo records the settings each stage's stored output was
+// produced with, so a stage can tell when it has to start over
+type FingerprintRepo interface {
+	GetFingerprint(stage string) (string, error)
+	SetFingerprint(stage, fingerprint string) error
+}
+
+// Repository is everything the pipeline stages need from a state database
+type Repository interface {
+	FileRepo
+	SourceRepo
+	MetadataRepo
+	ClusterRepo
+	ClusterStateRepo
+	PlanRepo
+	ExecutionRepo
+	FingerprintRepo
+}
+
+var (
+	_ Repository = (*Store)(nil)
+	_ Repository = (*Memory)(nil)
+)
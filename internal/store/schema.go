@@ -123,3 +123,267 @@ CREATE TABLE IF NOT EXISTS clustering_progress (
   updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 `
+
+// Schema v4 - Incremental clustering
+const schemaV4 = `
+-- Track which files have been clustered, under which key, and against which
+-- metadata version (files.last_update_at at clustering time)
+CREATE TABLE IF NOT EXISTS clustered_files (
+  file_id INTEGER PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+  cluster_key TEXT NOT NULL,
+  metadata_version TEXT NOT NULL,
+  clustered_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_clustered_files_cluster_key ON clustered_files(cluster_key);
+
+-- Clusters touched by incremental clustering that need rescoring and replanning
+ALTER TABLE clusters ADD COLUMN dirty INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_clusters_dirty ON clusters(dirty);
+
+-- Backfill from existing memberships with an empty version so the first
+-- incremental run re-verifies every key once
+INSERT OR IGNORE INTO clustered_files (file_id, cluster_key, metadata_version)
+SELECT file_id, cluster_key, '' FROM cluster_members;
+`
+
+// Schema v5 - Manual cluster overrides
+const schemaV5 = `
+-- User-defined corrections applied after automatic clustering
+-- Kept separate from clusters so they survive --force-recluster
+CREATE TABLE IF NOT EXISTS cluster_overrides (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind TEXT NOT NULL,           -- merge, split, pin, exclude
+  cluster_key TEXT NOT NULL,    -- merge: target cluster; otherwise the file's cluster when created
+  other_key TEXT,               -- merge: cluster merged into target; split: new cluster for the file
+  file_id INTEGER,              -- split, pin, exclude: affected file
+  note TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cluster_overrides_kind ON cluster_overrides(kind);
+CREATE INDEX IF NOT EXISTS idx_cluster_overrides_file_id ON cluster_overrides(file_id);
+`
+
+// Schema v6 - Placement confidence of cluster members
+const schemaV6 = `
+-- Similarity of a member's own cluster key to the cluster it was placed in
+-- 1.0 for exact key matches and manual moves; lower for fuzzy title/artist matches
+ALTER TABLE cluster_members ADD COLUMN confidence REAL DEFAULT 1.0;
+`
+
+// Schema v7 - Recording identifiers
+const schemaV7 = `
+-- International Standard Recording Code (ID3 TSRC, Vorbis ISRC, MP4 freeform ISRC)
+ALTER TABLE metadata ADD COLUMN isrc TEXT;
+
+-- Recording identifiers are authoritative cluster keys
+CREATE INDEX IF NOT EXISTS idx_metadata_isrc ON metadata(isrc);
+CREATE INDEX IF NOT EXISTS idx_metadata_mb_recording ON metadata(musicbrainz_recording_id);
+
+-- Key the heuristic pass (tags, duration linking, fuzzy matching) assigned to each
+-- file, before identifier reconciliation and manual overrides move it
+ALTER TABLE clustered_files ADD COLUMN heuristic_key TEXT;
+
+-- Disagreements between identifiers and heuristics, rebuilt on every clustering run
+CREATE TABLE IF NOT EXISTS identity_conflicts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  conflict_key TEXT NOT NULL,   -- heuristic cluster key or isrc:<ISRC>
+  kind TEXT NOT NULL,           -- cluster (several recordings in one heuristic cluster) or isrc (ISRC shared by several MBIDs)
+  identities TEXT NOT NULL,     -- comma-separated identity keys involved
+  file_ids TEXT NOT NULL,       -- comma-separated file IDs involved
+  detected_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Schema v8 - Extended metadata (ISRC was added in v7)
+const schemaV8 = `
+-- First-class columns for tags previously only kept in raw_tags_json
+ALTER TABLE metadata ADD COLUMN tag_genre TEXT;
+ALTER TABLE metadata ADD COLUMN tag_composer TEXT;
+ALTER TABLE metadata ADD COLUMN tag_conductor TEXT;
+ALTER TABLE metadata ADD COLUMN tag_label TEXT;
+ALTER TABLE metadata ADD COLUMN tag_catalog_number TEXT;
+ALTER TABLE metadata ADD COLUMN tag_bpm INTEGER;
+ALTER TABLE metadata ADD COLUMN tag_original_date TEXT;
+ALTER TABLE metadata ADD COLUMN tag_artist_sort TEXT;
+ALTER TABLE metadata ADD COLUMN tag_album_sort TEXT;
+ALTER TABLE metadata ADD COLUMN tag_comment TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_metadata_genre ON metadata(tag_genre);
+CREATE INDEX IF NOT EXISTS idx_metadata_composer ON metadata(tag_composer);
+`
+
+// Schema v9 - Parsed artist credits
+const schemaV9 = `
+-- Artists credited on each file: the primary artist, collaborators ("A & B",
+-- "A x B", multi-valued ARTIST fields) and featured artists ("A feat. B")
+CREATE TABLE IF NOT EXISTS metadata_artists (
+  file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+  role TEXT NOT NULL, -- primary, collaborator, featured
+  position INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  PRIMARY KEY (file_id, role, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metadata_artists_name ON metadata_artists(name COLLATE NOCASE);
+`
+
+// Schema v10 - MP3 encoder details
+const schemaV10 = `
+-- LAME/Xing info tag of MP3 files as JSON: encoder version, VBR method,
+-- preset, lowpass and encoder delay/padding
+ALTER TABLE metadata ADD COLUMN encoder_json TEXT;
+`
+
+// Schema v11 - Decode integrity checks
+const schemaV11 = `
+-- Result of decoding each file with ffmpeg: NULL until checked, then ok or damaged
+ALTER TABLE metadata ADD COLUMN integrity_status TEXT;
+ALTER TABLE metadata ADD COLUMN integrity_error TEXT;
+ALTER TABLE metadata ADD COLUMN decoded_duration_ms INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_metadata_integrity ON metadata(integrity_status);
+`
+
+// Schema v12 - Embedded artwork
+const schemaV12 = `
+-- Pictures embedded in each file (ID3 APIC, FLAC PICTURE, MP4 covr); the image
+-- data stays in the file and is read again when a cover is written
+CREATE TABLE IF NOT EXISTS pictures (
+  file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,   -- Order within the file
+  picture_type INTEGER NOT NULL, -- ID3/FLAC picture type; 3 = front cover
+  mime TEXT,
+  width INTEGER,
+  height INTEGER,
+  size_bytes INTEGER,
+  sha1 TEXT NOT NULL,
+  PRIMARY KEY (file_id, position)
+);
+
+-- Cover chosen by the planner for each destination album folder
+CREATE TABLE IF NOT EXISTS album_covers (
+  album_dir TEXT PRIMARY KEY,
+  file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+  sha1 TEXT NOT NULL,
+  mime TEXT,
+  width INTEGER,
+  height INTEGER
+);
+`
+
+// Schema v13 - Metadata provenance
+const schemaV13 = `
+-- Where each metadata field value came from (tag, ffprobe, filename, folder,
+-- sibling, musicbrainz, rule) and how confident the source was
+CREATE TABLE IF NOT EXISTS metadata_provenance (
+  file_id INTEGER NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+  field TEXT NOT NULL,
+  source TEXT NOT NULL,
+  confidence REAL NOT NULL,
+  value TEXT,
+  detail TEXT,
+  PRIMARY KEY (file_id, field)
+);
+
+CREATE INDEX IF NOT EXISTS idx_metadata_provenance_source ON metadata_provenance(source);
+`
+
+// Schema v14 - Named source roots
+const schemaV14 = `
+-- Named source roots; files record the source they were scanned from
+CREATE TABLE IF NOT EXISTS sources (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  label TEXT NOT NULL UNIQUE,
+  path TEXT NOT NULL,
+  priority INTEGER NOT NULL DEFAULT 0,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE files ADD COLUMN source_id INTEGER REFERENCES sources(id);
+
+CREATE INDEX IF NOT EXISTS idx_files_source ON files(source_id);
+`
+
+// Schema v15 - Content IDs
+const schemaV15 = `
+-- Partial-content hash that identifies a file across remounts, copies and touches
+ALTER TABLE files ADD COLUMN content_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_files_content_id ON files(content_id);
+`
+
+// Schema v16 - Classification signals
+const schemaV16 = `
+-- Signals for classifying files as music, audiobooks, podcasts, mixes or spoken word
+ALTER TABLE metadata ADD COLUMN chapters INTEGER;
+ALTER TABLE metadata ADD COLUMN podcast_id TEXT;
+`
+
+// Schema v17 - Writer lock holder
+const schemaV17 = `
+-- Holder of the single-writer lock, mirrored from the lock file for readers
+CREATE TABLE IF NOT EXISTS locks (
+  name TEXT PRIMARY KEY,
+  pid INTEGER NOT NULL,
+  host TEXT NOT NULL,
+  command TEXT NOT NULL,
+  acquired_at DATETIME NOT NULL
+);
+`
+
+// Schema v18 - Settings fingerprints
+const schemaV18 = `
+-- Fingerprint of the settings each stage's stored output was produced with
+CREATE TABLE IF NOT EXISTS fingerprints (
+  stage TEXT PRIMARY KEY,
+  fingerprint TEXT NOT NULL,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Schema v19 - Re-extract long files for chapter markers
+const schemaV19 = `
+-- Metadata extracted before v16 lacks chapters; they are only probed from 10
+-- minutes on (audiobooks, podcasts, mixes), so only those files are extracted again
+UPDATE files SET status = 'discovered', last_update_at = CURRENT_TIMESTAMP
+WHERE status = 'meta_ok'
+  AND id IN (SELECT file_id FROM metadata WHERE chapters IS NULL AND duration_ms >= 600000);
+`
+
+// Schema v20 - Music videos by video stream
+const schemaV20 = `
+-- Music videos are recognized by their video stream instead of their extension;
+-- files with a video extension are extracted again to record it
+ALTER TABLE metadata ADD COLUMN has_video INTEGER NOT NULL DEFAULT 0;
+UPDATE files SET status = 'discovered', last_update_at = CURRENT_TIMESTAMP
+WHERE status = 'meta_ok'
+  AND (lower(src_path) LIKE '%.mp4' OR lower(src_path) LIKE '%.mkv');
+`
+
+// Schema v21 - Multi-valued ARTISTS tag
+const schemaV21 = `
+-- The ARTISTS tag decides whether "A & B" is two artists or one; files with
+-- such a credit are extracted again to read it
+ALTER TABLE metadata ADD COLUMN tag_artists TEXT;
+UPDATE files SET status = 'discovered', last_update_at = CURRENT_TIMESTAMP
+WHERE status = 'meta_ok'
+  AND id IN (
+    SELECT file_id FROM metadata
+    WHERE tag_artist LIKE '% & %' OR tag_artist LIKE '% x %'
+       OR tag_artist LIKE '% × %' OR tag_artist LIKE '% vs %' OR tag_artist LIKE '% vs. %'
+  );
+`
+
+// Schema v22 - Anchor merge overrides on member files
+const schemaV22 = `
+-- Merges record a member file of each cluster, so they still find both clusters
+-- after their keys change (new aliases, key version bumps); existing merges
+-- are anchored on the current members
+ALTER TABLE cluster_overrides ADD COLUMN other_file_id INTEGER;
+UPDATE cluster_overrides
+SET file_id = (SELECT MIN(file_id) FROM cluster_members WHERE cluster_key = cluster_overrides.cluster_key)
+WHERE kind = 'merge' AND file_id IS NULL;
+`
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Source is a named source root files are scanned from
+type Source struct {
+	ID       int64
+	Label    string
+	Path     string
+	Priority int // Higher priority sources win ties between equally scored duplicates
+}
+
+// SourceStats summarizes what a source contributed to the library
+type SourceStats struct {
+	Source        Source
+	Files         int
+	Winners       int   // Files planned to be copied, moved or linked
+	Skipped       int   // Duplicates planned to be skipped
+	WinnerBytes   int64 // Size of the winning files
+	ExecutedFiles int   // Files executed and verified
+	Unlabelled    bool  // Files scanned before sources were recorded
+}
+
+// nullableSourceID stores unknown sources as NULL
+func nullableSourceID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id > 0}
+}
+
+// UpsertSource inserts a source or updates the path and priority of the source with the same label
+// Sets src.ID.
+func (s *Store) UpsertSource(src *Source) error {
+	if _, err := s.db.Exec(`
+		INSERT INTO sources (label, path, priority)
+		VALUES (?, ?, ?)
+		ON CONFLICT(label) DO UPDATE SET
+			path = excluded.path,
+			priority = excluded.priority
+	`, src.Label, src.Path, src.Priority); err != nil {
+		return fmt.Errorf("failed to upsert source: %w", err)
+	}
+
+	if err := s.db.QueryRow(`SELECT id FROM sources WHERE label = ?`, src.Label).Scan(&src.ID); err != nil {
+		return fmt.Errorf("failed to get source ID: %w", err)
+	}
+	return nil
+}
+
+// GetSources returns all sources, highest priority first
+func (s *Store) GetSources() ([]*Source, error) {
+	rows, err := s.db.Query(`SELECT id, label, path, priority FROM sources ORDER BY priority DESC, label`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []*Source
+	for rows.Next() {
+		src := &Source{}
+		if err := rows.Scan(&src.ID, &src.Label, &src.Path, &src.Priority); err != nil {
+			return nil, fmt.Errorf("failed to scan source: %w", err)
+		}
+		sources = append(sources, src)
+	}
+
+	return sources, rows.Err()
+}
+
+// GetSourcePriorities returns the priority of every source, keyed by source ID
+func (s *Store) GetSourcePriorities() (map[int64]int, error) {
+	sources, err := s.GetSources()
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := make(map[int64]int, len(sources))
+	for _, src := range sources {
+		priorities[src.ID] = src.Priority
+	}
+	return priorities, nil
+}
+
+// AssignSource records sourceID on files under root that have no source yet
+// (files scanned before sources were recorded). Returns the number of files updated.
+func (s *Store) AssignSource(sourceID int64, root string) (int, error) {
+	prefix := strings.TrimSuffix(filepath.Clean(root), string(filepath.Separator)) + string(filepath.Separator)
+	result, err := s.db.Exec(`
+		UPDATE files SET source_id = ?
+		WHERE source_id IS NULL AND substr(src_path, 1, ?) = ?
+	`, sourceID, len(prefix), prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to assign source: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// GetSourceStats returns the contribution of each source, highest priority first
+// Files without a source are reported last with Unlabelled set.
+func (s *Store) GetSourceStats() ([]SourceStats, error) {
+	rows, err := s.db.Query(`
+		SELECT COALESCE(s.id, 0), COALESCE(s.label, ''), COALESCE(s.path, ''), COALESCE(s.priority, 0),
+		       COUNT(f.id),
+		       COALESCE(SUM(CASE WHEN p.action IS NOT NULL AND p.action != 'skip' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN p.action = 'skip' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN p.action IS NOT NULL AND p.action != 'skip' THEN f.size_bytes ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN e.verify_ok = 1 THEN 1 ELSE 0 END), 0)
+		FROM files f
+		LEFT JOIN sources s ON s.id = f.source_id
+		LEFT JOIN plans p ON p.file_id = f.id
+		LEFT JOIN executions e ON e.file_id = f.id
+		GROUP BY s.id
+		ORDER BY s.id IS NULL, s.priority DESC, s.label
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []SourceStats
+	for rows.Next() {
+		var st SourceStats
+		if err := rows.Scan(&st.Source.ID, &st.Source.Label, &st.Source.Path, &st.Source.Priority,
+			&st.Files, &st.Winners, &st.Skipped, &st.WinnerBytes, &st.ExecutedFiles); err != nil {
+			return nil, fmt.Errorf("failed to scan source stats: %w", err)
+		}
+		st.Unlabelled = st.Source.ID == 0
+		stats = append(stats, st)
+	}
+
+	return stats, rows.Err()
+}
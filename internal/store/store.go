@@ -2,24 +2,41 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
 const (
-	currentSchemaVersion = 3
+	currentSchemaVersion = 22
 )
 
+// ErrOutdatedSchema is returned when a database opened read-only needs a migration
+var ErrOutdatedSchema = errors.New("database schema is outdated")
+
 // Store represents the application's persistent state
 type Store struct {
-	db *sql.DB
+	db       *sql.DB
+	lockPath string // Writer lock file held by this store, if any
 }
 
 // OpenOptions holds options for opening a database
 type OpenOptions struct {
 	NetworkOptimized bool // Apply network-optimized pragmas
+
+	// Lock takes the single-writer lock for the named command (e.g. "plan");
+	// opening fails with a *LockedError while another process holds it
+	Lock string
+
+	// BreakLock removes a lock left behind by a process that is no longer
+	// running (or runs on another host) before taking it
+	BreakLock bool
+
+	// ReadOnly opens an existing database without migrating or locking it
+	ReadOnly bool
 }
 
 // Open opens or creates a SQLite database at the given path with default options
@@ -32,11 +49,23 @@ func OpenWithOptions(path string, opts *OpenOptions) (*Store, error) {
 	if opts == nil {
 		opts = &OpenOptions{}
 	}
+	if opts.ReadOnly {
+		return openReadOnly(path)
+	}
+
+	var lockPath string
+	if opts.Lock != "" {
+		lockPath = LockPath(path)
+		if err := acquireLock(lockPath, opts.Lock, opts.BreakLock); err != nil {
+			return nil, err
+		}
+	}
 
 	// Open with pragmas for performance and reliability
-	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_timeout=5000&_busy_timeout=5000", path)
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_timeout=5000&_busy_timeout=5000&_pragma=busy_timeout(5000)", path)
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
+		releaseLock(lockPath)
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
@@ -45,22 +74,59 @@ func OpenWithOptions(path string, opts *OpenOptions) (*Store, error) {
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(0)
 
-	store := &Store{db: db}
+	store := &Store{db: db, lockPath: lockPath}
 
 	// Apply network-optimized pragmas if requested
 	if opts.NetworkOptimized {
 		if err := store.applyNetworkPragmas(); err != nil {
-			db.Close()
+			store.Close()
 			return nil, fmt.Errorf("failed to apply network pragmas: %w", err)
 		}
 	}
 
 	// Run migrations
 	if err := store.migrate(); err != nil {
-		db.Close()
+		store.Close()
 		return nil, fmt.Errorf("migration failed: %w", err)
 	}
 
+	if lockPath != "" {
+		if err := store.recordLock(opts.Lock); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// openReadOnly opens an existing database for queries only
+func openReadOnly(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	store := &Store{db: db}
+	version, err := store.getSchemaVersion()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version < currentSchemaVersion {
+		db.Close()
+		return nil, fmt.Errorf("%w: %s is at v%d, this version needs v%d (run 'mlc scan' or 'mlc plan' to upgrade it)",
+			ErrOutdatedSchema, path, version, currentSchemaVersion)
+	}
+
 	return store, nil
 }
 
@@ -93,9 +159,17 @@ func (s *Store) applyNetworkPragmas() error {
 	return nil
 }
 
-// Close closes the database connection
+// Close closes the database connection and releases the writer lock
 func (s *Store) Close() error {
-	return s.db.Close()
+	if s.lockPath == "" {
+		return s.db.Close()
+	}
+
+	s.clearLock()
+	err := s.db.Close()
+	releaseLock(s.lockPath)
+	s.lockPath = ""
+	return err
 }
 
 // DB returns the underlying database connection
@@ -185,8 +259,184 @@ func (s *Store) migrate() error {
 		}
 	}
 
+	// Apply schema v4 - Incremental clustering
+	if version < 4 {
+		if _, err := tx.Exec(schemaV4); err != nil {
+			return fmt.Errorf("failed to apply schema v4: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 4); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	// Apply schema v5 - Manual cluster overrides
+	if version < 5 {
+		if _, err := tx.Exec(schemaV5); err != nil {
+			return fmt.Errorf("failed to apply schema v5: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 5); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	// Apply schema v6 - Fuzzy match confidence for cluster members
+	if version < 6 {
+		if _, err := tx.Exec(schemaV6); err != nil {
+			return fmt.Errorf("failed to apply schema v6: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 6); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	// Apply schema v7 - ISRC column for identifier-based clustering
+	if version < 7 {
+		if _, err := tx.Exec(schemaV7); err != nil {
+			return fmt.Errorf("failed to apply schema v7: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 7); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	// Apply schema v8 - Extended metadata columns
+	if version < 8 {
+		if _, err := tx.Exec(schemaV8); err != nil {
+			return fmt.Errorf("failed to apply schema v8: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 8); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 9 {
+		if _, err := tx.Exec(schemaV9); err != nil {
+			return fmt.Errorf("failed to apply schema v9: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 9); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 10 {
+		if _, err := tx.Exec(schemaV10); err != nil {
+			return fmt.Errorf("failed to apply schema v10: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 10); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 11 {
+		if _, err := tx.Exec(schemaV11); err != nil {
+			return fmt.Errorf("failed to apply schema v11: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 11); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 12 {
+		if _, err := tx.Exec(schemaV12); err != nil {
+			return fmt.Errorf("failed to apply schema v12: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 12); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 13 {
+		if _, err := tx.Exec(schemaV13); err != nil {
+			return fmt.Errorf("failed to apply schema v13: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 13); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 14 {
+		if _, err := tx.Exec(schemaV14); err != nil {
+			return fmt.Errorf("failed to apply schema v14: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 14); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 15 {
+		if _, err := tx.Exec(schemaV15); err != nil {
+			return fmt.Errorf("failed to apply schema v15: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 15); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 16 {
+		if _, err := tx.Exec(schemaV16); err != nil {
+			return fmt.Errorf("failed to apply schema v16: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 16); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 17 {
+		if _, err := tx.Exec(schemaV17); err != nil {
+			return fmt.Errorf("failed to apply schema v17: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 17); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 18 {
+		if _, err := tx.Exec(schemaV18); err != nil {
+			return fmt.Errorf("failed to apply schema v18: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 18); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 19 {
+		if _, err := tx.Exec(schemaV19); err != nil {
+			return fmt.Errorf("failed to apply schema v19: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 19); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 20 {
+		if _, err := tx.Exec(schemaV20); err != nil {
+			return fmt.Errorf("failed to apply schema v20: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 20); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 21 {
+		if _, err := tx.Exec(schemaV21); err != nil {
+			return fmt.Errorf("failed to apply schema v21: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 21); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
+	if version < 22 {
+		if _, err := tx.Exec(schemaV22); err != nil {
+			return fmt.Errorf("failed to apply schema v22: %w", err)
+		}
+		if err := s.setSchemaVersion(tx, 22); err != nil {
+			return fmt.Errorf("failed to set schema version: %w", err)
+		}
+	}
+
 	// Future migrations would go here:
-	// if version < 4 { ... }
+	// if version < 23 { ... }
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit migration: %w", err)
@@ -259,6 +509,9 @@ type File struct {
 	Error       string
 	FirstSeenAt time.Time
 	LastUpdate  time.Time
+
+	SourceID  int64  // Source root the file was scanned from (0 if unknown)
+	ContentID string // Hash of the size and the first and last 64 KB (util.GenerateContentID)
 }
 
 // Metadata represents extracted audio metadata
@@ -274,6 +527,7 @@ type Metadata struct {
 	BitrateKbps            int
 	Lossless               bool
 	TagArtist              string
+	TagArtists             string // Multi-valued ARTISTS tag, values joined by "; "; empty if none
 	TagAlbum               string
 	TagTitle               string
 	TagAlbumArtist         string
@@ -285,7 +539,39 @@ type Metadata struct {
 	TagCompilation         bool
 	MusicBrainzRecordingID string
 	MusicBrainzReleaseID   string
+	ISRC                   string
+	TagGenre               string
+	TagComposer            string
+	TagConductor           string
+	TagLabel               string
+	TagCatalogNumber       string
+	TagBPM                 int
+	TagOriginalDate        string
+	TagArtistSort          string
+	TagAlbumSort           string
+	TagComment             string
 	RawTagsJSON            string
+	EncoderJSON            string // MP3 encoder info tag (LAME/Xing), JSON-encoded; empty if none
+	Chapters               int    // Chapter markers (MP4 chapters, ID3 CHAP); read for long files only
+	PodcastID              string // Podcast episode GUID or feed URL (ID3 TGID/WFED); empty if none
+	HasVideo               bool   // Has a video stream other than cover art: a music video
+
+	// Decode check results, written by UpdateIntegrity and reset when metadata is re-extracted
+	IntegrityStatus   string // "" (unchecked), IntegrityOK or IntegrityDamaged
+	IntegrityError    string // Why the file was found damaged
+	DecodedDurationMs int    // Duration ffmpeg actually decoded
+
+	// Artists are the parsed credits of TagArtist, written to metadata_artists
+	// Not loaded by the metadata getters; see GetArtistCredits
+	Artists []ArtistCredit
+
+	// Pictures are the embedded images, written to the pictures table
+	// Not loaded by the metadata getters; see GetPictures
+	Pictures []Picture
+
+	// Provenance records where each field value came from, written to
+	// metadata_provenance. Not loaded by the metadata getters; see GetProvenance
+	Provenance []FieldProvenance
 }
 
 // ClusterMember represents a file in a duplicate cluster
@@ -294,6 +580,7 @@ type ClusterMember struct {
 	FileID       int64
 	QualityScore float64
 	Preferred    bool
+	Confidence   float64 // Fuzzy match similarity; 0 is stored as 1.0 (exact match)
 }
 
 // Plan represents the planned action for a file
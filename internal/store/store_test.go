@@ -2,8 +2,12 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -33,7 +37,7 @@ func TestStoreOpenAndMigrate(t *testing.T) {
 	}
 
 	// Verify tables exist
-	tables := []string{"files", "metadata", "clusters", "cluster_members", "plans", "executions", "schema_version"}
+	tables := []string{"files", "metadata", "clusters", "cluster_members", "plans", "executions", "schema_version", "clustered_files"}
 	for _, table := range tables {
 		var count int
 		err := store.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&count)
@@ -1109,3 +1113,823 @@ func TestFileInsertConflict(t *testing.T) {
 		t.Errorf("expected updated size 2048, got %d", retrieved.SizeBytes)
 	}
 }
+
+func TestExtendedMetadataRoundTrip(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	newMetadata := func(key string) *Metadata {
+		file := &File{FileKey: key, SrcPath: "/music/" + key + ".flac", Status: "meta_ok"}
+		if err := store.InsertFile(file); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+		return &Metadata{
+			FileID:           file.ID,
+			TagTitle:         "Symphony No. 5",
+			TagGenre:         "Classical",
+			TagComposer:      "Ludwig van Beethoven",
+			TagConductor:     "Carlos Kleiber",
+			TagLabel:         "Deutsche Grammophon",
+			TagCatalogNumber: "447 400-2",
+			TagBPM:           108,
+			TagOriginalDate:  "1975",
+			TagArtistSort:    "Wiener Philharmoniker",
+			TagAlbumSort:     "Symphonies 5 & 7",
+			TagComment:       "Remastered",
+			ISRC:             "DEF057500010",
+			EncoderJSON:      `{"header":"Xing","encoder":"LAME3.100","preset":"V0"}`,
+		}
+	}
+
+	single := newMetadata("single")
+	if err := store.InsertMetadata(single); err != nil {
+		t.Fatalf("failed to insert metadata: %v", err)
+	}
+	batch := newMetadata("batch")
+	batch.TagBPM = 140
+	if err := store.InsertMetadataBatch([]*Metadata{batch}); err != nil {
+		t.Fatalf("failed to insert metadata batch: %v", err)
+	}
+
+	for _, want := range []*Metadata{single, batch} {
+		got, err := store.GetMetadataByFileID(want.FileID)
+		if err != nil || got == nil {
+			t.Fatalf("failed to retrieve metadata: %v", err)
+		}
+		want.RawTagsJSON = got.RawTagsJSON
+		if !reflect.DeepEqual(*got, *want) {
+			t.Errorf("round trip mismatch:\n got  %+v\n want %+v", *got, *want)
+		}
+	}
+
+	results, err := store.QueryFilesWithMetadata(&MetadataQueryOptions{
+		Composer:      "beethoven",
+		Conductor:     "Kleiber",
+		CatalogNumber: "447 400-2",
+		ISRC:          "DEF057500010",
+		BPMMax:        120,
+	})
+	if err != nil {
+		t.Fatalf("failed to query metadata: %v", err)
+	}
+	if len(results) != 1 || results[0].Metadata.FileID != single.FileID {
+		t.Errorf("expected only the 108 BPM file, got %d results", len(results))
+	}
+}
+
+func TestArtistCredits(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	file := &File{FileKey: "key", SrcPath: "/music/song.mp3", Status: "meta_ok"}
+	if err := store.InsertFile(file); err != nil {
+		t.Fatalf("failed to insert file: %v", err)
+	}
+
+	m := &Metadata{
+		FileID:    file.ID,
+		TagArtist: "A & B feat. C",
+		TagTitle:  "Song",
+		Artists: []ArtistCredit{
+			{Role: ArtistRoleFeatured, Position: 0, Name: "C"},
+			{Role: ArtistRolePrimary, Position: 0, Name: "A"},
+			{Role: ArtistRoleCollaborator, Position: 0, Name: "B"},
+		},
+	}
+	if err := store.InsertMetadata(m); err != nil {
+		t.Fatalf("failed to insert metadata: %v", err)
+	}
+
+	credits, err := store.GetArtistCredits(file.ID)
+	if err != nil {
+		t.Fatalf("failed to get artist credits: %v", err)
+	}
+	want := []ArtistCredit{
+		{Role: ArtistRolePrimary, Position: 0, Name: "A"},
+		{Role: ArtistRoleCollaborator, Position: 0, Name: "B"},
+		{Role: ArtistRoleFeatured, Position: 0, Name: "C"},
+	}
+	if !reflect.DeepEqual(credits, want) {
+		t.Errorf("credits = %+v, want %+v", credits, want)
+	}
+
+	// Featured artists are found by the artist filter
+	results, err := store.QueryFilesWithMetadata(&MetadataQueryOptions{Artist: "c"})
+	if err != nil {
+		t.Fatalf("failed to query metadata: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected featured artist to match 1 file, got %d", len(results))
+	}
+
+	// Re-inserting replaces the previous credits
+	m.TagArtist = "A"
+	m.Artists = []ArtistCredit{{Role: ArtistRolePrimary, Name: "A"}}
+	if err := store.InsertMetadataBatch([]*Metadata{m}); err != nil {
+		t.Fatalf("failed to insert metadata batch: %v", err)
+	}
+	credits, err = store.GetArtistCredits(file.ID)
+	if err != nil {
+		t.Fatalf("failed to get artist credits: %v", err)
+	}
+	if len(credits) != 1 || credits[0].Name != "A" {
+		t.Errorf("expected credits to be replaced, got %+v", credits)
+	}
+}
+
+func TestIntegrity(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	// Two clusters: "a" with a damaged winner and an intact copy, "b" whose
+	// damaged winner is pinned
+	newMember := func(clusterKey, fileKey string, preferred bool) *File {
+		file := &File{FileKey: fileKey, SrcPath: "/music/" + fileKey + ".flac", Status: "meta_ok"}
+		if err := store.InsertFile(file); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+		if err := store.InsertMetadata(&Metadata{FileID: file.ID, Codec: "flac", DurationMs: 540000}); err != nil {
+			t.Fatalf("failed to insert metadata: %v", err)
+		}
+		if preferred {
+			if err := store.InsertCluster(&Cluster{ClusterKey: clusterKey}); err != nil {
+				t.Fatalf("failed to insert cluster: %v", err)
+			}
+		}
+		if err := store.InsertClusterMember(&ClusterMember{ClusterKey: clusterKey, FileID: file.ID, Preferred: preferred}); err != nil {
+			t.Fatalf("failed to insert cluster member: %v", err)
+		}
+		return file
+	}
+	aWinner := newMember("a", "a1", true)
+	aOther := newMember("a", "a2", false)
+	bWinner := newMember("b", "b1", true)
+	newMember("b", "b2", false)
+	if err := store.InsertClusterOverride(&ClusterOverride{Kind: OverridePin, ClusterKey: "b", FileID: bWinner.ID}); err != nil {
+		t.Fatalf("failed to insert override: %v", err)
+	}
+
+	winners, err := store.GetUncheckedFiles(true)
+	if err != nil {
+		t.Fatalf("failed to get unchecked winners: %v", err)
+	}
+	if len(winners) != 2 {
+		t.Fatalf("expected 2 unchecked winners, got %d", len(winners))
+	}
+
+	for _, f := range []*File{aWinner, bWinner} {
+		if err := store.UpdateIntegrity(&IntegrityResult{FileID: f.ID, Status: IntegrityDamaged,
+			Error: "duration mismatch: decoded 2:00 of 9:00", DecodedDurationMs: 120000}); err != nil {
+			t.Fatalf("failed to update integrity: %v", err)
+		}
+	}
+
+	m, err := store.GetMetadataByFileID(aWinner.ID)
+	if err != nil {
+		t.Fatalf("failed to get metadata: %v", err)
+	}
+	if m.IntegrityStatus != IntegrityDamaged || m.DecodedDurationMs != 120000 || m.IntegrityError == "" {
+		t.Errorf("integrity not stored: %+v", m)
+	}
+
+	all, err := store.GetUncheckedFiles(false)
+	if err != nil {
+		t.Fatalf("failed to get unchecked files: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expected 2 unchecked files, got %d", len(all))
+	}
+
+	keys, err := store.GetClustersWithDamagedWinners()
+	if err != nil {
+		t.Fatalf("failed to get damaged winners: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("expected only cluster a, got %v", keys)
+	}
+
+	// Once every member is damaged there is nothing better to pick
+	if err := store.UpdateIntegrity(&IntegrityResult{FileID: aOther.ID, Status: IntegrityDamaged}); err != nil {
+		t.Fatalf("failed to update integrity: %v", err)
+	}
+	if keys, _ := store.GetClustersWithDamagedWinners(); len(keys) != 0 {
+		t.Errorf("expected no clusters when all members are damaged, got %v", keys)
+	}
+	if n, _ := store.CountDamagedFiles(); n != 3 {
+		t.Errorf("expected 3 damaged files, got %d", n)
+	}
+
+	// Re-extracting metadata resets the check
+	if err := store.InsertMetadata(&Metadata{FileID: aWinner.ID, Codec: "flac", DurationMs: 540000}); err != nil {
+		t.Fatalf("failed to re-insert metadata: %v", err)
+	}
+	if m, _ := store.GetMetadataByFileID(aWinner.ID); m.IntegrityStatus != "" || m.DecodedDurationMs != 0 {
+		t.Errorf("integrity not reset on re-extraction: %+v", m)
+	}
+
+	if err := store.ClearIntegrity(); err != nil {
+		t.Fatalf("failed to clear integrity: %v", err)
+	}
+	if n, _ := store.CountDamagedFiles(); n != 0 {
+		t.Errorf("expected no damaged files after clear, got %d", n)
+	}
+}
+
+func TestPicturesAndAlbumCovers(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	// A FLAC winner without art and an MP3 duplicate with a front and back cover
+	flac := &File{FileKey: "flac", SrcPath: "/music/song.flac", Status: "meta_ok"}
+	mp3 := &File{FileKey: "mp3", SrcPath: "/music/song.mp3", Status: "meta_ok"}
+	for _, f := range []*File{flac, mp3} {
+		if err := store.InsertFile(f); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+	}
+	pictures := []Picture{
+		{Position: 0, Type: PictureFrontCover, MIMEType: "image/jpeg", Width: 1000, Height: 1000, SizeBytes: 90000, SHA1: "aaa"},
+		{Position: 1, Type: 4, MIMEType: "image/png", Width: 500, Height: 500, SizeBytes: 40000, SHA1: "bbb"},
+	}
+	if err := store.InsertMetadata(&Metadata{FileID: flac.ID}); err != nil {
+		t.Fatalf("failed to insert metadata: %v", err)
+	}
+	if err := store.InsertMetadataBatch([]*Metadata{{FileID: mp3.ID, Pictures: pictures}}); err != nil {
+		t.Fatalf("failed to insert metadata batch: %v", err)
+	}
+
+	got, err := store.GetPictures(mp3.ID)
+	if err != nil {
+		t.Fatalf("failed to get pictures: %v", err)
+	}
+	if !reflect.DeepEqual(got, pictures) {
+		t.Errorf("pictures round trip mismatch:\n got  %+v\n want %+v", got, pictures)
+	}
+
+	if err := store.InsertCluster(&Cluster{ClusterKey: "song"}); err != nil {
+		t.Fatalf("failed to insert cluster: %v", err)
+	}
+	for _, m := range []*ClusterMember{{ClusterKey: "song", FileID: flac.ID, Preferred: true}, {ClusterKey: "song", FileID: mp3.ID}} {
+		if err := store.InsertClusterMember(m); err != nil {
+			t.Fatalf("failed to insert cluster member: %v", err)
+		}
+	}
+	if err := store.InsertPlanBatch([]*Plan{
+		{FileID: flac.ID, Action: "copy", DestPath: "/dest/Artist/Album/01 - Song.flac"},
+		{FileID: mp3.ID, Action: "skip"},
+	}); err != nil {
+		t.Fatalf("failed to insert plans: %v", err)
+	}
+
+	// The winner's album can use the duplicate's pictures
+	candidates, err := store.GetCoverCandidates()
+	if err != nil {
+		t.Fatalf("failed to get cover candidates: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 cover candidates, got %d", len(candidates))
+	}
+	if c := candidates[0]; c.DestPath != "/dest/Artist/Album/01 - Song.flac" || c.FileID != mp3.ID || c.Picture != pictures[0] {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+
+	covers := []*AlbumCover{{AlbumDir: "/dest/Artist/Album", FileID: mp3.ID, SHA1: "aaa", MIMEType: "image/jpeg", Width: 1000, Height: 1000}}
+	if err := store.ReplaceAlbumCovers(covers); err != nil {
+		t.Fatalf("failed to replace album covers: %v", err)
+	}
+	if err := store.ReplaceAlbumCovers(covers); err != nil {
+		t.Fatalf("failed to replace album covers again: %v", err)
+	}
+	gotCovers, err := store.GetAlbumCovers()
+	if err != nil {
+		t.Fatalf("failed to get album covers: %v", err)
+	}
+	if !reflect.DeepEqual(gotCovers, covers) {
+		t.Errorf("album covers mismatch: got %+v", gotCovers)
+	}
+
+	// Re-extraction without artwork removes the pictures
+	if err := store.InsertMetadata(&Metadata{FileID: mp3.ID}); err != nil {
+		t.Fatalf("failed to re-insert metadata: %v", err)
+	}
+	if got, _ := store.GetPictures(mp3.ID); len(got) != 0 {
+		t.Errorf("expected pictures to be cleared, got %d", len(got))
+	}
+}
+
+func TestMetadataProvenance(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	f := &File{FileKey: "song", SrcPath: "/music/Artist/2001 - Album/01 - Song.mp3", Status: "meta_ok"}
+	if err := store.InsertFile(f); err != nil {
+		t.Fatalf("failed to insert file: %v", err)
+	}
+	provenance := []FieldProvenance{
+		{Field: "album", Source: SourceFolder, Confidence: 0.7, Value: "Album", Detail: "2001 - Album"},
+		{Field: "artist", Source: SourceTag, Confidence: 1.0, Value: "Artist"},
+		{Field: "title", Source: SourceRule, Confidence: 0.9, Value: "Song", Detail: "promo-marker"},
+	}
+	if err := store.InsertMetadata(&Metadata{FileID: f.ID, TagArtist: "Artist", TagAlbum: "Album", TagTitle: "Song", Provenance: provenance}); err != nil {
+		t.Fatalf("failed to insert metadata: %v", err)
+	}
+
+	got, err := store.GetProvenance(f.ID)
+	if err != nil {
+		t.Fatalf("failed to get provenance: %v", err)
+	}
+	if !reflect.DeepEqual(got, provenance) {
+		t.Errorf("provenance round trip mismatch:\n got  %+v\n want %+v", got, provenance)
+	}
+
+	inferred, err := store.GetInferredProvenance()
+	if err != nil {
+		t.Fatalf("failed to get inferred provenance: %v", err)
+	}
+	if want := []FieldProvenance{provenance[0], provenance[2]}; !reflect.DeepEqual(inferred[f.ID], want) {
+		t.Errorf("inferred provenance mismatch:\n got  %+v\n want %+v", inferred[f.ID], want)
+	}
+
+	// Re-extraction replaces the provenance
+	if err := store.InsertMetadataBatch([]*Metadata{{FileID: f.ID, TagTitle: "Song"}}); err != nil {
+		t.Fatalf("failed to insert metadata batch: %v", err)
+	}
+	if got, err := store.GetProvenance(f.ID); err != nil || len(got) != 0 {
+		t.Errorf("expected provenance to be cleared, got %+v (err %v)", got, err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	rips := &Source{Label: "rips", Path: "/music/rips", Priority: 10}
+	phone := &Source{Label: "phone", Path: "/music/phone"}
+	for _, src := range []*Source{rips, phone} {
+		if err := store.UpsertSource(src); err != nil {
+			t.Fatalf("failed to upsert source: %v", err)
+		}
+	}
+
+	// Upserting by label keeps the ID and updates the priority
+	again := &Source{Label: "phone", Path: "/music/phone", Priority: 1}
+	if err := store.UpsertSource(again); err != nil {
+		t.Fatalf("failed to upsert source: %v", err)
+	}
+	if again.ID != phone.ID {
+		t.Errorf("expected upsert to keep ID %d, got %d", phone.ID, again.ID)
+	}
+
+	sources, err := store.GetSources()
+	if err != nil {
+		t.Fatalf("failed to get sources: %v", err)
+	}
+	if len(sources) != 2 || sources[0].Label != "rips" || sources[1].Priority != 1 {
+		t.Fatalf("unexpected sources: %+v %+v", sources[0], sources[1])
+	}
+
+	files := []*File{
+		{FileKey: "a", SrcPath: "/music/rips/a.flac", SizeBytes: 100, Status: "meta_ok", SourceID: rips.ID},
+		{FileKey: "b", SrcPath: "/music/phone/a.mp3", SizeBytes: 10, Status: "meta_ok", SourceID: phone.ID},
+		{FileKey: "c", SrcPath: "/music/phone/c.mp3", SizeBytes: 20, Status: "meta_ok"},
+		{FileKey: "d", SrcPath: "/music/phoneold/d.mp3", SizeBytes: 30, Status: "meta_ok"},
+	}
+	if err := store.InsertFileBatch(files); err != nil {
+		t.Fatalf("failed to insert files: %v", err)
+	}
+
+	// Legacy files under the root are assigned; siblings sharing a name prefix are not
+	n, err := store.AssignSource(phone.ID, "/music/phone/")
+	if err != nil {
+		t.Fatalf("failed to assign source: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 file assigned, got %d", n)
+	}
+
+	a, _ := store.GetFileByKey("a")
+	c, _ := store.GetFileByKey("c")
+	if a.SourceID != rips.ID || c.SourceID != phone.ID {
+		t.Errorf("expected source IDs %d and %d, got %d and %d", rips.ID, phone.ID, a.SourceID, c.SourceID)
+	}
+
+	// Re-inserting without a source keeps the recorded one
+	if err := store.InsertFile(&File{FileKey: "a", SrcPath: "/music/rips/a.flac", SizeBytes: 100, Status: "meta_ok"}); err != nil {
+		t.Fatalf("failed to insert file: %v", err)
+	}
+	if a, _ = store.GetFileByKey("a"); a.SourceID != rips.ID {
+		t.Errorf("expected source ID %d to be kept, got %d", rips.ID, a.SourceID)
+	}
+
+	b, _ := store.GetFileByKey("b")
+	store.InsertPlan(&Plan{FileID: a.ID, Action: "copy", DestPath: "/dest/a.flac"})
+	store.InsertPlan(&Plan{FileID: b.ID, Action: "skip"})
+
+	stats, err := store.GetSourceStats()
+	if err != nil {
+		t.Fatalf("failed to get source stats: %v", err)
+	}
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 source rows, got %+v", stats)
+	}
+	if stats[0].Source.Label != "rips" || stats[0].Files != 1 || stats[0].Winners != 1 || stats[0].WinnerBytes != 100 {
+		t.Errorf("unexpected rips stats: %+v", stats[0])
+	}
+	if stats[1].Source.Label != "phone" || stats[1].Files != 2 || stats[1].Skipped != 1 || stats[1].Winners != 0 {
+		t.Errorf("unexpected phone stats: %+v", stats[1])
+	}
+	if !stats[2].Unlabelled || stats[2].Files != 1 {
+		t.Errorf("unexpected unlabelled stats: %+v", stats[2])
+	}
+}
+
+func TestContentIDsAndRelink(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	files := []*File{
+		{FileKey: "a", SrcPath: "/old/a.flac", SizeBytes: 100, Status: "meta_ok", ContentID: "content-a"},
+		{FileKey: "b", SrcPath: "/old/b.flac", SizeBytes: 200, Status: "meta_ok"},
+	}
+	if err := store.InsertFileBatch(files); err != nil {
+		t.Fatalf("failed to insert files: %v", err)
+	}
+	a, _ := store.GetFileByKey("a")
+	b, _ := store.GetFileByKey("b")
+	if a.ContentID != "content-a" || b.ContentID != "" {
+		t.Fatalf("unexpected content IDs %q and %q", a.ContentID, b.ContentID)
+	}
+
+	missing, err := store.GetFileKeysWithoutContentID()
+	if err != nil || len(missing) != 1 || missing["b"] != b.ID {
+		t.Fatalf("expected only b without a content ID, got %v (%v)", missing, err)
+	}
+	if err := store.SetContentIDs(map[int64]string{b.ID: "content-b"}); err != nil {
+		t.Fatalf("failed to set content IDs: %v", err)
+	}
+
+	index, err := store.GetContentIDIndex()
+	if err != nil {
+		t.Fatalf("failed to get content ID index: %v", err)
+	}
+	if len(index["content-a"]) != 1 || len(index["content-b"]) != 1 || index["content-b"][0].SrcPath != "/old/b.flac" {
+		t.Fatalf("unexpected content ID index: %v", index)
+	}
+
+	// Relinking keeps the row and its status, and updates the location
+	store.InsertMetadata(&Metadata{FileID: a.ID, TagTitle: "A"})
+	if err := store.RelinkFileBatch([]*File{{ID: a.ID, FileKey: "a2", SrcPath: "/new/a.flac", SizeBytes: 100, MtimeUnix: 42}}); err != nil {
+		t.Fatalf("failed to relink file: %v", err)
+	}
+	relinked, _ := store.GetFileByID(a.ID)
+	if relinked.FileKey != "a2" || relinked.SrcPath != "/new/a.flac" || relinked.MtimeUnix != 42 {
+		t.Errorf("unexpected relinked file: %+v", relinked)
+	}
+	if relinked.Status != "meta_ok" || relinked.ContentID != "content-a" {
+		t.Errorf("expected status and content ID to be kept, got %+v", relinked)
+	}
+	if m, _ := store.GetMetadata(a.ID); m == nil || m.TagTitle != "A" {
+		t.Errorf("expected metadata to be kept, got %+v", m)
+	}
+}
+
+func TestMarkFilesMissing(t *testing.T) {
+	store, err := Open(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	files := []*File{
+		{FileKey: "a", SrcPath: "/music/a.flac", Status: "meta_ok"},
+		{FileKey: "b", SrcPath: "/music/b.mp3", Status: "meta_ok"},
+		{FileKey: "c", SrcPath: "/music/c.mp3", Status: "meta_ok"},
+	}
+	if err := store.InsertFileBatch(files); err != nil {
+		t.Fatalf("failed to insert files: %v", err)
+	}
+	a, _ := store.GetFileByKey("a")
+	b, _ := store.GetFileByKey("b")
+	c, _ := store.GetFileByKey("c")
+
+	store.InsertClusterBatch([]*Cluster{{ClusterKey: "song"}, {ClusterKey: "solo"}})
+	store.InsertClusterMemberBatch([]*ClusterMember{
+		{ClusterKey: "song", FileID: a.ID, Preferred: true},
+		{ClusterKey: "song", FileID: b.ID},
+		{ClusterKey: "solo", FileID: c.ID, Preferred: true},
+	})
+	store.InsertPlan(&Plan{FileID: a.ID, Action: "copy", DestPath: "/dest/a.flac"})
+	store.InsertPlan(&Plan{FileID: b.ID, Action: "skip"})
+	store.InsertPlan(&Plan{FileID: c.ID, Action: "copy", DestPath: "/dest/c.mp3"})
+
+	// Executed files are not pruned
+	store.InsertOrUpdateExecution(&Execution{FileID: c.ID, VerifyOK: true})
+	candidates, err := store.GetPruneCandidates()
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("expected 2 prune candidates, got %d (%v)", len(candidates), err)
+	}
+
+	keys, plansRemoved, err := store.MarkFilesMissing([]int64{a.ID})
+	if err != nil {
+		t.Fatalf("failed to mark files missing: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != "song" || plansRemoved != 1 {
+		t.Errorf("expected cluster song and 1 plan removed, got %v and %d", keys, plansRemoved)
+	}
+
+	pruned, _ := store.GetFileByID(a.ID)
+	if pruned.Status != StatusMissing {
+		t.Errorf("expected status missing, got %s", pruned.Status)
+	}
+	if members, _ := store.GetClusterMembers("song"); len(members) != 1 || members[0].FileID != b.ID {
+		t.Errorf("expected only b left in cluster, got %+v", members)
+	}
+	if plan, _ := store.GetPlan(a.ID); plan != nil {
+		t.Errorf("expected plan to be removed, got %+v", plan)
+	}
+	if candidates, _ := store.GetPruneCandidates(); len(candidates) != 1 {
+		t.Errorf("expected missing files to be excluded from candidates, got %d", len(candidates))
+	}
+
+	// Files found again go back to discovered
+	missing, err := store.GetMissingFileKeys()
+	if err != nil || missing["a"] != a.ID || len(missing) != 1 {
+		t.Fatalf("expected a to be missing, got %v (%v)", missing, err)
+	}
+	if err := store.RestoreMissingFiles([]int64{a.ID, b.ID}); err != nil {
+		t.Fatalf("failed to restore files: %v", err)
+	}
+	if restored, _ := store.GetFileByID(a.ID); restored.Status != "discovered" {
+		t.Errorf("expected restored file to be discovered, got %s", restored.Status)
+	}
+	if other, _ := store.GetFileByID(b.ID); other.Status != "meta_ok" {
+		t.Errorf("expected present file to keep its status, got %s", other.Status)
+	}
+}
+
+func TestWriterLock(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	writer, err := OpenWithOptions(dbPath, &OpenOptions{Lock: "plan"})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	holder, err := writer.WriterLock()
+	if err != nil {
+		t.Fatalf("WriterLock failed: %v", err)
+	}
+	if holder == nil || holder.Command != "plan" || holder.PID != os.Getpid() {
+		t.Fatalf("WriterLock = %+v, want plan held by this process", holder)
+	}
+
+	// A second writer is refused, even with BreakLock, while the holder runs
+	for _, breakLock := range []bool{false, true} {
+		_, err := OpenWithOptions(dbPath, &OpenOptions{Lock: "execute", BreakLock: breakLock})
+		var locked *LockedError
+		if !errors.As(err, &locked) {
+			t.Fatalf("second writer (break %v) error = %v, want *LockedError", breakLock, err)
+		}
+		if locked.Holder == nil || locked.Holder.Command != "plan" {
+			t.Errorf("LockedError holder = %+v, want plan", locked.Holder)
+		}
+	}
+
+	// Readers do not need the lock and see its holder
+	reader, err := OpenWithOptions(dbPath, &OpenOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open read-only while locked: %v", err)
+	}
+	if holder, _ := reader.WriterLock(); holder == nil || holder.Command != "plan" {
+		t.Errorf("reader WriterLock = %+v, want plan", holder)
+	}
+	reader.Close()
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close store: %v", err)
+	}
+	if _, err := os.Stat(LockPath(dbPath)); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after Close: %v", err)
+	}
+
+	next, err := OpenWithOptions(dbPath, &OpenOptions{Lock: "execute"})
+	if err != nil {
+		t.Fatalf("failed to take released lock: %v", err)
+	}
+	next.Close()
+}
+
+func TestStaleWriterLock(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	// A lock left by a process that has exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run helper process: %v", err)
+	}
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(&LockInfo{PID: cmd.Process.Pid, Host: host, Command: "execute", AcquiredAt: time.Now()})
+	if err := os.WriteFile(LockPath(dbPath), data, 0644); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+
+	_, err := OpenWithOptions(dbPath, &OpenOptions{Lock: "plan"})
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("error = %v, want *LockedError", err)
+	}
+	if locked.Holder == nil || !locked.Holder.Stale() {
+		t.Errorf("holder = %+v, want stale", locked.Holder)
+	}
+
+	store, err := OpenWithOptions(dbPath, &OpenOptions{Lock: "plan", BreakLock: true})
+	if err != nil {
+		t.Fatalf("BreakLock did not remove stale lock: %v", err)
+	}
+	defer store.Close()
+
+	if holder, _ := ReadLock(LockPath(dbPath)); holder == nil || holder.PID != os.Getpid() {
+		t.Errorf("lock file holder = %+v, want this process", holder)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := t.TempDir() + "/test.db"
+
+	if _, err := OpenWithOptions(dbPath, &OpenOptions{ReadOnly: true}); err == nil {
+		t.Fatal("read-only open of missing database succeeded")
+	}
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	if err := store.InsertFile(&File{FileKey: "a", SrcPath: "/music/a.mp3", Status: "meta_ok"}); err != nil {
+		t.Fatalf("failed to insert file: %v", err)
+	}
+	store.Close()
+
+	reader, err := OpenWithOptions(dbPath, &OpenOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open read-only: %v", err)
+	}
+	defer reader.Close()
+
+	if file, err := reader.GetFileByKey("a"); err != nil || file == nil {
+		t.Errorf("GetFileByKey = %v, %v", file, err)
+	}
+	if err := reader.InsertFile(&File{FileKey: "b", SrcPath: "/music/b.mp3"}); err == nil {
+		t.Error("insert into read-only database succeeded")
+	}
+	if _, err := os.Stat(LockPath(dbPath)); !os.IsNotExist(err) {
+		t.Errorf("read-only open created a lock file: %v", err)
+	}
+
+	// Read-only stores cannot migrate
+	old := t.TempDir() + "/old.db"
+	raw, _ := sql.Open("sqlite", old)
+	raw.Exec("CREATE TABLE schema_version (version INTEGER)")
+	raw.Exec("INSERT INTO schema_version (version) VALUES (3)")
+	raw.Close()
+	if _, err := OpenWithOptions(old, &OpenOptions{ReadOnly: true}); !errors.Is(err, ErrOutdatedSchema) {
+		t.Errorf("read-only open of old schema error = %v, want ErrOutdatedSchema", err)
+	}
+}
+
+// TestMigrateReextractsOldMetadata checks that metadata extracted before
+// chapters, video streams or ARTISTS tags were recorded is queued for
+// extraction again
+func TestMigrateReextractsOldMetadata(t *testing.T) {
+	dbPath := t.TempDir() + "/library.db"
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	old := &File{FileKey: "old", SrcPath: "/music/old.mp3", Status: "meta_ok"}
+	current := &File{FileKey: "current", SrcPath: "/music/current.mp3", Status: "meta_ok"}
+	video := &File{FileKey: "video", SrcPath: "/music/Videos/clip.MP4", Status: "meta_ok"}
+	duo := &File{FileKey: "Simon & Garfunkel", SrcPath: "/music/duo.mp3", Status: "meta_ok"}
+	short := &File{FileKey: "short", SrcPath: "/music/short.mp3", Status: "meta_ok"}
+	for _, f := range []*File{old, current, video, duo, short} {
+		if err := store.InsertFile(f); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+		durationMs := 240000
+		if f == old {
+			durationMs = 3600000 // Long enough to be probed for chapters
+		}
+		if err := store.InsertMetadata(&Metadata{FileID: f.ID, Format: "mp3", TagArtist: f.FileKey, TagTitle: f.FileKey, DurationMs: durationMs}); err != nil {
+			t.Fatalf("failed to insert metadata: %v", err)
+		}
+	}
+
+	// Roll back to v18 with metadata from before v16
+	if _, err := store.db.Exec("UPDATE metadata SET chapters = NULL WHERE file_id IN (?, ?)", old.ID, short.ID); err != nil {
+		t.Fatalf("failed to clear chapters: %v", err)
+	}
+	for _, column := range []string{"has_video", "tag_artists"} {
+		if _, err := store.db.Exec("ALTER TABLE metadata DROP COLUMN " + column); err != nil {
+			t.Fatalf("failed to drop column %s: %v", column, err)
+		}
+	}
+	if _, err := store.db.Exec("ALTER TABLE cluster_overrides DROP COLUMN other_file_id"); err != nil {
+		t.Fatalf("failed to drop column other_file_id: %v", err)
+	}
+	if _, err := store.db.Exec("DELETE FROM schema_version WHERE version > 18"); err != nil {
+		t.Fatalf("failed to reset schema version: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	for _, tt := range []struct {
+		file *File
+		want string
+	}{{old, "discovered"}, {current, "meta_ok"}, {video, "discovered"}, {duo, "discovered"}, {short, "meta_ok"}} {
+		f, err := store.GetFileByID(tt.file.ID)
+		if err != nil {
+			t.Fatalf("GetFileByID(%d) error: %v", tt.file.ID, err)
+		}
+		if f.Status != tt.want {
+			t.Errorf("%s status = %q, want %q", tt.file.FileKey, f.Status, tt.want)
+		}
+	}
+}
+
+// TestMigrateAnchorsMergeOverrides checks that merge overrides created before
+// anchor files were recorded are anchored on a member of their target cluster
+func TestMigrateAnchorsMergeOverrides(t *testing.T) {
+	dbPath := t.TempDir() + "/library.db"
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	var files []*File
+	for _, key := range []string{"a", "b", "c"} {
+		f := &File{FileKey: key, SrcPath: "/music/" + key + ".mp3", Status: "meta_ok"}
+		if err := store.InsertFile(f); err != nil {
+			t.Fatalf("failed to insert file: %v", err)
+		}
+		files = append(files, f)
+	}
+	if err := store.InsertClusterBatch([]*Cluster{{ClusterKey: "target"}}); err != nil {
+		t.Fatalf("failed to insert cluster: %v", err)
+	}
+	if err := store.InsertClusterMemberBatch([]*ClusterMember{
+		{ClusterKey: "target", FileID: files[2].ID},
+		{ClusterKey: "target", FileID: files[1].ID},
+	}); err != nil {
+		t.Fatalf("failed to insert cluster members: %v", err)
+	}
+
+	// Roll back to v21 with a merge recorded by cluster keys only
+	if _, err := store.db.Exec("ALTER TABLE cluster_overrides DROP COLUMN other_file_id"); err != nil {
+		t.Fatalf("failed to drop column other_file_id: %v", err)
+	}
+	if _, err := store.db.Exec("INSERT INTO cluster_overrides (kind, cluster_key, other_key) VALUES ('merge', 'target', 'gone')"); err != nil {
+		t.Fatalf("failed to insert override: %v", err)
+	}
+	if _, err := store.db.Exec("DELETE FROM schema_version WHERE version > 21"); err != nil {
+		t.Fatalf("failed to reset schema version: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	overrides, err := store.GetClusterOverrides()
+	if err != nil || len(overrides) != 1 {
+		t.Fatalf("GetClusterOverrides() = %v, %v", overrides, err)
+	}
+	if o := overrides[0]; o.FileID != files[1].ID || o.OtherFileID != 0 {
+		t.Errorf("merge anchors = %d, %d, want %d, 0", o.FileID, o.OtherFileID, files[1].ID)
+	}
+}
@@ -0,0 +1,837 @@
+// Package storetest is the conformance suite every store.Repository
+// implementation must pass, so stages behave the same on SQLite and in memory
+package storetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/franz/music-janitor/internal/store"
+)
+
+// Run runs the conformance suite; open must return an empty repository
+func Run(t *testing.T, open func(t *testing.T) store.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r store.Repository)
+	}{
+		{"Files", testFiles},
+		{"FilesByDirectory", testFilesByDirectory},
+		{"MissingFiles", testMissingFiles},
+		{"ContentIDs", testContentIDs},
+		{"Sources", testSources},
+		{"Metadata", testMetadata},
+		{"Provenance", testProvenance},
+		{"Clusters", testClusters},
+		{"DuplicateClusters", testDuplicateClusters},
+		{"ClusterMembers", testClusterMembers},
+		{"MoveClusterMembers", testMoveClusterMembers},
+		{"ClusteringCandidates", testClusteringCandidates},
+		{"RemovedClusteredFiles", testRemovedClusteredFiles},
+		{"SyncClusteredFiles", testSyncClusteredFiles},
+		{"ClusteringProgress", testClusteringProgress},
+		{"Overrides", testOverrides},
+		{"Plans", testPlans},
+		{"Covers", testCovers},
+		{"Executions", testExecutions},
+		{"Fingerprints", testFingerprints},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, open(t))
+		})
+	}
+}
+
+// addFiles inserts a file per path with the given status and returns them with IDs set
+func addFiles(t *testing.T, r store.Repository, status string, paths ...string) []*store.File {
+	t.Helper()
+	var files []*store.File
+	for _, path := range paths {
+		f := &store.File{FileKey: "key:" + path, SrcPath: path, SizeBytes: 100, Status: status}
+		if err := r.InsertFile(f); err != nil {
+			t.Fatalf("InsertFile(%s) error: %v", path, err)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// addMetadata inserts metadata with the given duration for each file
+func addMetadata(t *testing.T, r store.Repository, durationMs int, files ...*store.File) {
+	t.Helper()
+	for _, f := range files {
+		if err := r.InsertMetadata(&store.Metadata{FileID: f.ID, TagTitle: f.SrcPath, DurationMs: durationMs}); err != nil {
+			t.Fatalf("InsertMetadata(%d) error: %v", f.ID, err)
+		}
+	}
+}
+
+// addCluster inserts a cluster with the given member files
+func addCluster(t *testing.T, r store.Repository, key string, files ...*store.File) {
+	t.Helper()
+	if err := r.InsertClusterBatch([]*store.Cluster{{ClusterKey: key, Hint: key}}); err != nil {
+		t.Fatalf("InsertClusterBatch(%s) error: %v", key, err)
+	}
+	var members []*store.ClusterMember
+	for _, f := range files {
+		members = append(members, &store.ClusterMember{ClusterKey: key, FileID: f.ID})
+	}
+	if err := r.InsertClusterMemberBatch(members); err != nil {
+		t.Fatalf("InsertClusterMemberBatch(%s) error: %v", key, err)
+	}
+}
+
+func fileIDs(files []*store.File) []int64 {
+	ids := make([]int64, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	return ids
+}
+
+func clusterKeys(clusters []*store.Cluster) []string {
+	keys := make([]string, len(clusters))
+	for i, c := range clusters {
+		keys[i] = c.ClusterKey
+	}
+	return keys
+}
+
+func memberIDs(members []*store.ClusterMember) []int64 {
+	ids := make([]int64, len(members))
+	for i, m := range members {
+		ids[i] = m.FileID
+	}
+	return ids
+}
+
+func expectEqual(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func testFiles(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "discovered", "/src/b.mp3", "/src/a.mp3")
+	if files[0].ID == 0 || files[1].ID == files[0].ID {
+		t.Fatalf("InsertFile IDs = %d, %d, want distinct non-zero", files[0].ID, files[1].ID)
+	}
+
+	got, err := r.GetFileByKey("key:/src/b.mp3")
+	if err != nil || got == nil {
+		t.Fatalf("GetFileByKey() = %v, %v", got, err)
+	}
+	expectEqual(t, "GetFileByKey() path", got.SrcPath, "/src/b.mp3")
+	if missing, err := r.GetFileByKey("nope"); missing != nil || err != nil {
+		t.Errorf("GetFileByKey(unknown) = %v, %v, want nil, nil", missing, err)
+	}
+	if missing, err := r.GetFileByID(9999); missing != nil || err != nil {
+		t.Errorf("GetFileByID(unknown) = %v, %v, want nil, nil", missing, err)
+	}
+
+	// Re-inserting a key updates the location but keeps status and unset fields
+	if err := r.UpdateFileStatus(files[0].ID, "meta_ok", ""); err != nil {
+		t.Fatalf("UpdateFileStatus() error: %v", err)
+	}
+	if err := r.InsertFile(&store.File{FileKey: "key:/src/b.mp3", SrcPath: "/src/c.mp3", SizeBytes: 200, Status: "discovered", ContentID: "cid"}); err != nil {
+		t.Fatalf("InsertFile(existing) error: %v", err)
+	}
+	if err := r.InsertFileBatch([]*store.File{
+		{FileKey: "key:/src/b.mp3", SrcPath: "/src/c.mp3", SizeBytes: 300, Status: "discovered"},
+		{FileKey: "key:/src/d.mp3", SrcPath: "/src/d.mp3", Status: "discovered"},
+	}); err != nil {
+		t.Fatalf("InsertFileBatch() error: %v", err)
+	}
+	got, _ = r.GetFileByID(files[0].ID)
+	expectEqual(t, "upserted file", []interface{}{got.SrcPath, got.SizeBytes, got.Status, got.ContentID},
+		[]interface{}{"/src/c.mp3", int64(300), "meta_ok", "cid"})
+
+	all, err := r.GetAllFiles()
+	if err != nil {
+		t.Fatalf("GetAllFiles() error: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != files[0].ID || all[1].ID != files[1].ID {
+		t.Errorf("GetAllFiles() IDs = %v, want 3 files ordered by ID", fileIDs(all))
+	}
+	byID, _ := r.GetAllFilesMap()
+	keys, _ := r.GetAllFileKeysMap()
+	if len(byID) != 3 || len(keys) != 3 || !keys["key:/src/d.mp3"] {
+		t.Errorf("GetAllFilesMap() = %d files, GetAllFileKeysMap() = %v", len(byID), keys)
+	}
+
+	if err := r.BatchUpdateFileStatus([]struct {
+		FileID   int64
+		Status   string
+		ErrorMsg string
+	}{{files[1].ID, "error", "unreadable"}, {all[2].ID, "meta_ok", ""}}); err != nil {
+		t.Fatalf("BatchUpdateFileStatus() error: %v", err)
+	}
+	metaOK, _ := r.GetFilesByStatus("meta_ok")
+	expectEqual(t, "GetFilesByStatus(meta_ok)", fileIDs(metaOK), []int64{files[0].ID, all[2].ID})
+	failed, _ := r.GetFileByID(files[1].ID)
+	expectEqual(t, "failed file", []string{failed.Status, failed.Error}, []string{"error", "unreadable"})
+}
+
+func testFilesByDirectory(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/music/album/02.mp3", "/music/album/01.mp3", "/music/album/cd2/01.mp3", "/music/other/01.mp3")
+	addFiles(t, r, "error", "/music/album/03.mp3")
+
+	got, err := r.GetFilesByDirectory("/music/album")
+	if err != nil {
+		t.Fatalf("GetFilesByDirectory() error: %v", err)
+	}
+	expectEqual(t, "GetFilesByDirectory()", fileIDs(got), []int64{files[1].ID, files[0].ID})
+}
+
+func testMissingFiles(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, store.StatusMissing, "/src/a.mp3", "/src/b.mp3")
+	addFiles(t, r, "meta_ok", "/src/c.mp3")
+
+	missing, err := r.GetMissingFileKeys()
+	if err != nil {
+		t.Fatalf("GetMissingFileKeys() error: %v", err)
+	}
+	expectEqual(t, "GetMissingFileKeys()", missing, map[string]int64{"key:/src/a.mp3": files[0].ID, "key:/src/b.mp3": files[1].ID})
+
+	if err := r.RestoreMissingFiles([]int64{files[0].ID}); err != nil {
+		t.Fatalf("RestoreMissingFiles() error: %v", err)
+	}
+	restored, _ := r.GetFileByID(files[0].ID)
+	expectEqual(t, "restored status", restored.Status, "discovered")
+
+	// Relinking moves the row to its new key and path; missing files are found again
+	if err := r.RelinkFileBatch([]*store.File{{ID: files[1].ID, FileKey: "key:/new/b.mp3", SrcPath: "/new/b.mp3", SizeBytes: 100, SourceID: 0}}); err != nil {
+		t.Fatalf("RelinkFileBatch() error: %v", err)
+	}
+	relinked, _ := r.GetFileByKey("key:/new/b.mp3")
+	if relinked == nil || relinked.ID != files[1].ID || relinked.Status != "discovered" {
+		t.Errorf("relinked file = %+v, want ID %d with status discovered", relinked, files[1].ID)
+	}
+	if old, _ := r.GetFileByKey("key:/src/b.mp3"); old != nil {
+		t.Errorf("GetFileByKey(old key) = %+v, want nil", old)
+	}
+	missing, _ = r.GetMissingFileKeys()
+	expectEqual(t, "GetMissingFileKeys() after restore", len(missing), 0)
+}
+
+func testContentIDs(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3", "/src/c.mp3")
+
+	if err := r.SetContentIDs(map[int64]string{files[0].ID: "same", files[2].ID: "same"}); err != nil {
+		t.Fatalf("SetContentIDs() error: %v", err)
+	}
+	index, err := r.GetContentIDIndex()
+	if err != nil {
+		t.Fatalf("GetContentIDIndex() error: %v", err)
+	}
+	if len(index) != 1 {
+		t.Fatalf("GetContentIDIndex() = %d content IDs, want 1", len(index))
+	}
+	expectEqual(t, "GetContentIDIndex()[same]", fileIDs(index["same"]), []int64{files[0].ID, files[2].ID})
+	expectEqual(t, "indexed path", index["same"][1].SrcPath, "/src/c.mp3")
+
+	without, _ := r.GetFileKeysWithoutContentID()
+	expectEqual(t, "GetFileKeysWithoutContentID()", without, map[string]int64{"key:/src/b.mp3": files[1].ID})
+}
+
+func testSources(t *testing.T, r store.Repository) {
+	addFiles(t, r, "discovered", "/music/a.mp3", "/music/sub/b.mp3", "/musical/c.mp3")
+	if err := r.InsertFile(&store.File{FileKey: "labelled", SrcPath: "/music/d.mp3", SourceID: 42}); err != nil {
+		t.Fatalf("InsertFile() error: %v", err)
+	}
+
+	src := &store.Source{Label: "nas", Path: "/music", Priority: 1}
+	if err := r.UpsertSource(src); err != nil {
+		t.Fatalf("UpsertSource() error: %v", err)
+	}
+	if src.ID == 0 {
+		t.Fatal("UpsertSource() did not set ID")
+	}
+	again := &store.Source{Label: "nas", Path: "/music/", Priority: 5}
+	if err := r.UpsertSource(again); err != nil {
+		t.Fatalf("UpsertSource(existing) error: %v", err)
+	}
+	expectEqual(t, "UpsertSource(existing) ID", again.ID, src.ID)
+
+	n, err := r.AssignSource(src.ID, "/music/")
+	if err != nil {
+		t.Fatalf("AssignSource() error: %v", err)
+	}
+	expectEqual(t, "AssignSource()", n, 2)
+	labelled, _ := r.GetFileByKey("labelled")
+	expectEqual(t, "labelled source", labelled.SourceID, int64(42))
+
+	priorities, _ := r.GetSourcePriorities()
+	expectEqual(t, "GetSourcePriorities()", priorities, map[int64]int{src.ID: 5})
+}
+
+func testMetadata(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.flac", "/src/b.flac")
+
+	m := &store.Metadata{
+		FileID: files[0].ID, Format: "flac", Lossless: true, DurationMs: 180000,
+		TagArtist: "Artist", TagTitle: "Song", TagTrack: 3, TagTrackTotal: 12, TagDiscTotal: 2,
+		TagCompilation: true, MusicBrainzReleaseID: "release", RawTagsJSON: "{}", HasVideo: true,
+		Pictures: []store.Picture{{Position: 0, SHA1: "abc"}},
+	}
+	if err := r.InsertMetadata(m); err != nil {
+		t.Fatalf("InsertMetadata() error: %v", err)
+	}
+	got, err := r.GetMetadata(files[0].ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetMetadata() = %v, %v", got, err)
+	}
+	want := *m
+	want.Pictures = nil
+	expectEqual(t, "GetMetadata()", *got, want)
+	byFile, _ := r.GetMetadataByFileID(files[0].ID)
+	expectEqual(t, "GetMetadataByFileID()", *byFile, want)
+	if none, err := r.GetMetadata(files[1].ID); none != nil || err != nil {
+		t.Errorf("GetMetadata(no metadata) = %v, %v, want nil, nil", none, err)
+	}
+
+	// GetAllMetadata leaves out the fields no bulk reader needs
+	all, _ := r.GetAllMetadata()
+	want.TagCompilation = false
+	want.MusicBrainzReleaseID, want.RawTagsJSON = "", ""
+	if len(all) != 1 || all[files[0].ID] == nil {
+		t.Fatalf("GetAllMetadata() = %v, want 1 record", all)
+	}
+	expectEqual(t, "GetAllMetadata()", *all[files[0].ID], want)
+
+	// The batch insert does not write raw tags
+	if err := r.InsertMetadataBatch([]*store.Metadata{{FileID: files[1].ID, TagTitle: "Other", TagTrackTotal: 9, TagCompilation: true, RawTagsJSON: "{}"}}); err != nil {
+		t.Fatalf("InsertMetadataBatch() error: %v", err)
+	}
+	batched, _ := r.GetMetadata(files[1].ID)
+	expectEqual(t, "batched metadata", *batched, store.Metadata{FileID: files[1].ID, TagTitle: "Other", TagTrackTotal: 9, TagCompilation: true})
+}
+
+func testProvenance(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3")
+
+	for _, f := range files {
+		if err := r.InsertMetadata(&store.Metadata{FileID: f.ID, Provenance: []store.FieldProvenance{
+			{Field: "title", Source: store.SourceTag, Confidence: 1},
+			{Field: "album", Source: store.SourceFolder, Confidence: 0.6, Value: "Album", Detail: "Album"},
+			{Field: "artist", Source: store.SourceSibling, Confidence: 0.8, Value: "Artist"},
+		}}); err != nil {
+			t.Fatalf("InsertMetadata() error: %v", err)
+		}
+	}
+	// Re-extraction replaces the provenance
+	if err := r.InsertMetadata(&store.Metadata{FileID: files[1].ID, Provenance: []store.FieldProvenance{
+		{Field: "title", Source: store.SourceFFprobe, Confidence: 1},
+	}}); err != nil {
+		t.Fatalf("InsertMetadata() error: %v", err)
+	}
+
+	got, err := r.GetProvenance(files[0].ID)
+	if err != nil {
+		t.Fatalf("GetProvenance() error: %v", err)
+	}
+	var fields []string
+	for _, p := range got {
+		fields = append(fields, p.Field)
+	}
+	expectEqual(t, "GetProvenance() fields", fields, []string{"album", "artist", "title"})
+
+	inferred, _ := r.GetInferredProvenance()
+	if len(inferred) != 1 || len(inferred[files[0].ID]) != 2 {
+		t.Fatalf("GetInferredProvenance() = %v, want 2 inferred fields of file %d", inferred, files[0].ID)
+	}
+	expectEqual(t, "inferred album", inferred[files[0].ID][0], store.FieldProvenance{Field: "album", Source: store.SourceFolder, Confidence: 0.6, Value: "Album", Detail: "Album"})
+}
+
+func testClusters(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3", "/src/c.mp3")
+	addCluster(t, r, "k2", files[0], files[1])
+	addCluster(t, r, "k1", files[2])
+
+	if err := r.InsertClusterBatch([]*store.Cluster{{ClusterKey: "k1"}}); err == nil {
+		t.Error("InsertClusterBatch(existing) succeeded, want error")
+	}
+	if err := r.EnsureClusterBatch([]*store.Cluster{{ClusterKey: "k1", Hint: "changed"}, {ClusterKey: "k3", Hint: "k3"}}); err != nil {
+		t.Fatalf("EnsureClusterBatch() error: %v", err)
+	}
+
+	clusters, err := r.GetAllClusters()
+	if err != nil {
+		t.Fatalf("GetAllClusters() error: %v", err)
+	}
+	expectEqual(t, "GetAllClusters()", clusterKeys(clusters), []string{"k1", "k2", "k3"})
+	expectEqual(t, "ensured hint", clusters[0].Hint, "k1")
+	count, _ := r.CountClusters()
+	expectEqual(t, "CountClusters()", count, 3)
+	duplicates, _ := r.CountDuplicateClusters()
+	expectEqual(t, "CountDuplicateClusters()", duplicates, 1)
+
+	deleted, err := r.DeleteEmptyClusters([]string{"k1", "k3", "unknown"})
+	if err != nil {
+		t.Fatalf("DeleteEmptyClusters() error: %v", err)
+	}
+	expectEqual(t, "DeleteEmptyClusters()", deleted, 1)
+
+	if err := r.MarkClustersDirty([]string{"k2"}); err != nil {
+		t.Fatalf("MarkClustersDirty() error: %v", err)
+	}
+	dirty, _ := r.GetDirtyClusters()
+	expectEqual(t, "GetDirtyClusters()", clusterKeys(dirty), []string{"k2"})
+	if err := r.ClearDirtyClusters(); err != nil {
+		t.Fatalf("ClearDirtyClusters() error: %v", err)
+	}
+	dirty, _ = r.GetDirtyClusters()
+	expectEqual(t, "GetDirtyClusters() after clear", len(dirty), 0)
+
+	if err := r.ClearClusters(); err != nil {
+		t.Fatalf("ClearClusters() error: %v", err)
+	}
+	count, _ = r.CountClusters()
+	keys, _ := r.GetFileClusterKeys()
+	if count != 0 || len(keys) != 0 {
+		t.Errorf("after ClearClusters() %d clusters and %d members remain", count, len(keys))
+	}
+}
+
+// testDuplicateClusters counts every cluster with several members, not the
+// size of the first one
+func testDuplicateClusters(t *testing.T, r store.Repository) {
+	count, err := r.CountDuplicateClusters()
+	if err != nil {
+		t.Fatalf("CountDuplicateClusters() on empty store error: %v", err)
+	}
+	expectEqual(t, "CountDuplicateClusters() on empty store", count, 0)
+
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3", "/src/c.mp3", "/src/d.mp3", "/src/e.mp3", "/src/f.mp3")
+	addCluster(t, r, "k1", files[0], files[1], files[2])
+	addCluster(t, r, "k2", files[3], files[4])
+	addCluster(t, r, "k3", files[5])
+
+	count, err = r.CountDuplicateClusters()
+	if err != nil {
+		t.Fatalf("CountDuplicateClusters() error: %v", err)
+	}
+	expectEqual(t, "CountDuplicateClusters()", count, 2)
+}
+
+func testClusterMembers(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3", "/src/c.mp3")
+	addMetadata(t, r, 180000, files[0], files[1])
+	addCluster(t, r, "k", files...)
+
+	if err := r.InsertClusterMemberBatch([]*store.ClusterMember{{ClusterKey: "k", FileID: files[0].ID}}); err == nil {
+		t.Error("InsertClusterMemberBatch(existing) succeeded, want error")
+	}
+
+	members, err := r.GetClusterMembers("k")
+	if err != nil {
+		t.Fatalf("GetClusterMembers() error: %v", err)
+	}
+	if len(members) != 3 || members[0].Confidence != 1.0 {
+		t.Fatalf("GetClusterMembers() = %d members with confidence %v, want 3 with 1.0", len(members), members[0].Confidence)
+	}
+
+	if err := r.BatchUpdateClusterMemberScores([]struct {
+		ClusterKey string
+		FileID     int64
+		Score      float64
+	}{{"k", files[0].ID, 10}, {"k", files[1].ID, 30}, {"k", files[2].ID, 10}}); err != nil {
+		t.Fatalf("BatchUpdateClusterMemberScores() error: %v", err)
+	}
+	if err := r.BatchUpdateClusterMemberPreferred([]struct {
+		ClusterKey string
+		FileID     int64
+		Preferred  bool
+	}{{"k", files[2].ID, true}}); err != nil {
+		t.Fatalf("BatchUpdateClusterMemberPreferred() error: %v", err)
+	}
+
+	members, _ = r.GetClusterMembers("k")
+	expectEqual(t, "GetClusterMembers() order", memberIDs(members), []int64{files[1].ID, files[0].ID, files[2].ID})
+	all, _ := r.GetAllClusterMembers()
+	if len(all["k"]) != 3 || all["k"][0].FileID != files[2].ID || all["k"][1].FileID != files[1].ID {
+		t.Errorf("GetAllClusterMembers() order = %v, want winner first, then by score", memberIDs(all["k"]))
+	}
+	winners, _ := r.CountWinners()
+	expectEqual(t, "CountWinners()", winners, 1)
+
+	durations, _ := r.GetClusterMemberDurations()
+	expectEqual(t, "GetClusterMemberDurations()", durations, map[int64]int{files[0].ID: 180000, files[1].ID: 180000, files[2].ID: 0})
+
+	if err := r.MarkClustersDirty([]string{"k"}); err != nil {
+		t.Fatalf("MarkClustersDirty() error: %v", err)
+	}
+	members, _ = r.GetClusterMembers("k")
+	for _, m := range members {
+		if m.QualityScore != 0 || m.Preferred {
+			t.Errorf("member %d of dirty cluster kept score %v, preferred %v", m.FileID, m.QualityScore, m.Preferred)
+		}
+	}
+
+	if err := r.RemoveClusterMembers([]int64{files[0].ID}); err != nil {
+		t.Fatalf("RemoveClusterMembers() error: %v", err)
+	}
+	keys, _ := r.GetFileClusterKeys()
+	expectEqual(t, "GetFileClusterKeys()", keys, map[int64]string{files[1].ID: "k", files[2].ID: "k"})
+
+	if err := r.ClearScores(); err != nil {
+		t.Fatalf("ClearScores() error: %v", err)
+	}
+	winners, _ = r.CountWinners()
+	expectEqual(t, "CountWinners() after ClearScores()", winners, 0)
+}
+
+func testMoveClusterMembers(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3")
+	addCluster(t, r, "from", files...)
+	if err := r.UpsertClusteredFileBatch([]*store.ClusteredFile{
+		{FileID: files[0].ID, ClusterKey: "from", MetadataVersion: "v"},
+		{FileID: files[1].ID, ClusterKey: "from", MetadataVersion: "v"},
+	}); err != nil {
+		t.Fatalf("UpsertClusteredFileBatch() error: %v", err)
+	}
+	if err := r.BatchUpdateClusterMemberScores([]struct {
+		ClusterKey string
+		FileID     int64
+		Score      float64
+	}{{"from", files[0].ID, 50}}); err != nil {
+		t.Fatalf("BatchUpdateClusterMemberScores() error: %v", err)
+	}
+
+	if err := r.EnsureClusterBatch([]*store.Cluster{{ClusterKey: "to"}}); err != nil {
+		t.Fatalf("EnsureClusterBatch() error: %v", err)
+	}
+	if err := r.MoveClusterMembers([]int64{files[0].ID}, "to"); err != nil {
+		t.Fatalf("MoveClusterMembers() error: %v", err)
+	}
+
+	moved, _ := r.GetClusterMembers("to")
+	if len(moved) != 1 || moved[0].FileID != files[0].ID || moved[0].QualityScore != 0 {
+		t.Fatalf("GetClusterMembers(to) = %v, want file %d with score reset", memberIDs(moved), files[0].ID)
+	}
+	heuristic, _ := r.GetHeuristicKeys()
+	expectEqual(t, "GetHeuristicKeys()", heuristic, map[int64]string{files[0].ID: "to", files[1].ID: "from"})
+
+	// Heuristic keys survive later moves and feed identity reconciliation
+	if err := r.SetHeuristicKeys(map[int64]string{files[0].ID: "from", 9999: "unknown"}); err != nil {
+		t.Fatalf("SetHeuristicKeys() error: %v", err)
+	}
+	heuristic, _ = r.GetHeuristicKeys()
+	expectEqual(t, "GetHeuristicKeys() after set", heuristic, map[int64]string{files[0].ID: "from", files[1].ID: "from"})
+	identity, err := r.GetIdentityMembers()
+	if err != nil {
+		t.Fatalf("GetIdentityMembers() error: %v", err)
+	}
+	if len(identity) != 2 {
+		t.Fatalf("GetIdentityMembers() = %d members, want 2", len(identity))
+	}
+	expectEqual(t, "identity member", *identity[0], store.IdentityMember{FileID: files[0].ID, ClusterKey: "to", HeuristicKey: "from"})
+
+	if err := r.DeleteClusterMembersByFileIDs([]int64{files[1].ID}); err != nil {
+		t.Fatalf("DeleteClusterMembersByFileIDs() error: %v", err)
+	}
+	heuristic, _ = r.GetHeuristicKeys()
+	expectEqual(t, "GetHeuristicKeys() after delete", heuristic, map[int64]string{files[0].ID: "from"})
+}
+
+func testClusteringCandidates(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3")
+	addFiles(t, r, "discovered", "/src/c.mp3")
+
+	candidates, err := r.GetClusteringCandidates()
+	if err != nil {
+		t.Fatalf("GetClusteringCandidates() error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].File.ID != files[0].ID || candidates[0].MetadataVersion == "" {
+		t.Fatalf("GetClusteringCandidates() = %d candidates, want both meta_ok files with versions", len(candidates))
+	}
+
+	var tracked []*store.ClusteredFile
+	for _, c := range candidates {
+		tracked = append(tracked, &store.ClusteredFile{FileID: c.File.ID, ClusterKey: "k", MetadataVersion: c.MetadataVersion})
+	}
+	if err := r.UpsertClusteredFileBatch(tracked); err != nil {
+		t.Fatalf("UpsertClusteredFileBatch() error: %v", err)
+	}
+	candidates, _ = r.GetClusteringCandidates()
+	expectEqual(t, "GetClusteringCandidates() after clustering", len(candidates), 0)
+
+	// A re-extracted file is a candidate again
+	if err := r.UpdateFileStatus(files[1].ID, "meta_ok", ""); err != nil {
+		t.Fatalf("UpdateFileStatus() error: %v", err)
+	}
+	candidates, _ = r.GetClusteringCandidates()
+	if len(candidates) != 1 || candidates[0].File.ID != files[1].ID {
+		t.Errorf("GetClusteringCandidates() after update = %d candidates, want file %d", len(candidates), files[1].ID)
+	}
+}
+
+func testRemovedClusteredFiles(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/ok.mp3", "/src/nometa.mp3", "/src/missing.mp3", "/src/failed.mp3", "/src/executed.mp3")
+	addMetadata(t, r, 1000, files[0], files[2], files[3], files[4])
+
+	var tracked []*store.ClusteredFile
+	for _, f := range append(files, &store.File{ID: 9999}) {
+		tracked = append(tracked, &store.ClusteredFile{FileID: f.ID, ClusterKey: fmt.Sprintf("k%d", f.ID), MetadataVersion: "v"})
+	}
+	if err := r.UpsertClusteredFileBatch(tracked); err != nil {
+		t.Fatalf("UpsertClusteredFileBatch() error: %v", err)
+	}
+	if err := r.UpdateFileStatus(files[2].ID, store.StatusMissing, ""); err != nil {
+		t.Fatalf("UpdateFileStatus() error: %v", err)
+	}
+	for _, f := range files[3:] {
+		if err := r.UpdateFileStatus(f.ID, "error", "failed"); err != nil {
+			t.Fatalf("UpdateFileStatus() error: %v", err)
+		}
+	}
+	if err := r.InsertOrUpdateExecution(&store.Execution{FileID: files[4].ID, Error: "disk full"}); err != nil {
+		t.Fatalf("InsertOrUpdateExecution() error: %v", err)
+	}
+
+	removed, err := r.GetRemovedClusteredFiles()
+	if err != nil {
+		t.Fatalf("GetRemovedClusteredFiles() error: %v", err)
+	}
+	var ids []int64
+	for _, cf := range removed {
+		ids = append(ids, cf.FileID)
+	}
+	expectEqual(t, "GetRemovedClusteredFiles()", ids, []int64{files[1].ID, files[2].ID, files[3].ID, 9999})
+	expectEqual(t, "removed cluster key", removed[0].ClusterKey, fmt.Sprintf("k%d", files[1].ID))
+}
+
+func testSyncClusteredFiles(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/a.mp3", "/src/b.mp3", "/src/excluded.mp3")
+	addCluster(t, r, "k", files[0], files[1])
+	if err := r.InsertClusterOverride(&store.ClusterOverride{Kind: store.OverrideExclude, ClusterKey: "old", FileID: files[2].ID}); err != nil {
+		t.Fatalf("InsertClusterOverride() error: %v", err)
+	}
+	if err := r.UpsertClusteredFileBatch([]*store.ClusteredFile{{FileID: 9999, ClusterKey: "stale", MetadataVersion: "v"}}); err != nil {
+		t.Fatalf("UpsertClusteredFileBatch() error: %v", err)
+	}
+
+	if err := r.SyncClusteredFilesFromMembers(); err != nil {
+		t.Fatalf("SyncClusteredFilesFromMembers() error: %v", err)
+	}
+	heuristic, _ := r.GetHeuristicKeys()
+	expectEqual(t, "GetHeuristicKeys()", heuristic, map[int64]string{files[0].ID: "k", files[1].ID: "k", files[2].ID: "old"})
+
+	// Synced versions match the files, so nothing is left to cluster
+	candidates, _ := r.GetClusteringCandidates()
+	expectEqual(t, "GetClusteringCandidates()", len(candidates), 0)
+}
+
+func testClusteringProgress(t *testing.T, r store.Repository) {
+	if p, err := r.GetClusteringProgress(); p != nil || err != nil {
+		t.Fatalf("GetClusteringProgress() = %v, %v, want nil, nil", p, err)
+	}
+	if err := r.UpdateClusteringProgress(5, 5, 1); err != nil {
+		t.Fatalf("UpdateClusteringProgress() error: %v", err)
+	}
+	if p, _ := r.GetClusteringProgress(); p != nil {
+		t.Errorf("UpdateClusteringProgress() without a run created progress %+v", p)
+	}
+
+	if err := r.InitClusteringProgress(10); err != nil {
+		t.Fatalf("InitClusteringProgress() error: %v", err)
+	}
+	if err := r.UpdateClusteringProgress(7, 4, 2); err != nil {
+		t.Fatalf("UpdateClusteringProgress() error: %v", err)
+	}
+	p, err := r.GetClusteringProgress()
+	if err != nil || p == nil {
+		t.Fatalf("GetClusteringProgress() = %v, %v", p, err)
+	}
+	expectEqual(t, "progress", []int64{p.LastProcessedFileID, int64(p.TotalFiles), int64(p.FilesProcessed), int64(p.ClustersCreated)}, []int64{7, 10, 4, 2})
+
+	if err := r.ClearClusteringProgress(); err != nil {
+		t.Fatalf("ClearClusteringProgress() error: %v", err)
+	}
+	if p, _ := r.GetClusteringProgress(); p != nil {
+		t.Errorf("GetClusteringProgress() after clear = %+v, want nil", p)
+	}
+}
+
+func testOverrides(t *testing.T, r store.Repository) {
+	overrides := []*store.ClusterOverride{
+		{Kind: store.OverridePin, ClusterKey: "k", FileID: 3, Note: "best rip"},
+		{Kind: store.OverrideMerge, ClusterKey: "k", OtherKey: "other", FileID: 1, OtherFileID: 2},
+		{Kind: store.OverridePin, ClusterKey: "j", FileID: 5},
+	}
+	for _, o := range overrides {
+		if err := r.InsertClusterOverride(o); err != nil {
+			t.Fatalf("InsertClusterOverride() error: %v", err)
+		}
+	}
+	if overrides[0].ID == 0 || overrides[1].ID <= overrides[0].ID {
+		t.Errorf("InsertClusterOverride() IDs = %d, %d, want increasing", overrides[0].ID, overrides[1].ID)
+	}
+
+	got, err := r.GetClusterOverrides()
+	if err != nil {
+		t.Fatalf("GetClusterOverrides() error: %v", err)
+	}
+	if len(got) != 3 || got[1].OtherKey != "other" || got[1].OtherFileID != 2 || got[0].Note != "best rip" {
+		t.Errorf("GetClusterOverrides() = %+v, want the 3 overrides in order", got)
+	}
+
+	if err := r.UpdateClusterOverrideKeys(overrides[1].ID, "k2", "other2"); err != nil {
+		t.Fatalf("UpdateClusterOverrideKeys() error: %v", err)
+	}
+	got, _ = r.GetClusterOverrides()
+	expectEqual(t, "UpdateClusterOverrideKeys() keys", []string{got[1].ClusterKey, got[1].OtherKey, got[0].ClusterKey}, []string{"k2", "other2", "k"})
+
+	pinned, _ := r.GetOverrideFileIDs(store.OverridePin)
+	expectEqual(t, "GetOverrideFileIDs(pin)", pinned, map[int64]bool{3: true, 5: true})
+	merged, _ := r.GetOverrideFileIDs(store.OverrideMerge)
+	expectEqual(t, "GetOverrideFileIDs(merge)", merged, map[int64]bool{1: true})
+}
+
+func testPlans(t *testing.T, r store.Repository) {
+	plans := []*store.Plan{
+		{FileID: 3, Action: "copy", DestPath: "/dest/c.mp3", Reason: "winner"},
+		{FileID: 1, Action: "copy", DestPath: "/dest/a.mp3", Reason: "winner"},
+		{FileID: 2, Action: "skip", Reason: "duplicate"},
+	}
+	if err := r.InsertPlanBatch(plans); err != nil {
+		t.Fatalf("InsertPlanBatch() error: %v", err)
+	}
+	if err := r.InsertPlan(&store.Plan{FileID: 3, Action: "move", DestPath: "/elsewhere/c.mp3"}); err != nil {
+		t.Fatalf("InsertPlan() error: %v", err)
+	}
+
+	got, err := r.GetAllPlans()
+	if err != nil {
+		t.Fatalf("GetAllPlans() error: %v", err)
+	}
+	if len(got) != 3 || got[0].FileID != 1 || got[2].Action != "move" {
+		t.Errorf("GetAllPlans() = %+v, want 3 plans by file ID with file 3 replaced", got)
+	}
+	count, _ := r.CountPlans()
+	expectEqual(t, "CountPlans()", count, 3)
+
+	inconsistent, _ := r.CountPlansInconsistentWith("/dest", "copy")
+	expectEqual(t, "CountPlansInconsistentWith(/dest, copy)", inconsistent, 1)
+	inconsistent, _ = r.CountPlansInconsistentWith("/other", "copy")
+	expectEqual(t, "CountPlansInconsistentWith(/other, copy)", inconsistent, 2)
+
+	if err := r.DeletePlansByFileIDs([]int64{1}); err != nil {
+		t.Fatalf("DeletePlansByFileIDs() error: %v", err)
+	}
+	count, _ = r.CountPlans()
+	expectEqual(t, "CountPlans() after delete", count, 2)
+	if err := r.ClearPlans(); err != nil {
+		t.Fatalf("ClearPlans() error: %v", err)
+	}
+	count, _ = r.CountPlans()
+	expectEqual(t, "CountPlans() after clear", count, 0)
+}
+
+func testCovers(t *testing.T, r store.Repository) {
+	files := addFiles(t, r, "meta_ok", "/src/winner.mp3", "/src/dupe.mp3", "/src/alone.mp3")
+	for i, f := range files {
+		if err := r.InsertMetadata(&store.Metadata{FileID: f.ID, Pictures: []store.Picture{
+			{Position: 1, SHA1: fmt.Sprintf("back%d", i)},
+			{Position: 0, SHA1: fmt.Sprintf("front%d", i), Width: 500, Height: 500},
+		}}); err != nil {
+			t.Fatalf("InsertMetadata() error: %v", err)
+		}
+	}
+	addCluster(t, r, "k", files[0], files[1])
+	addCluster(t, r, "j", files[2])
+	if err := r.InsertPlanBatch([]*store.Plan{
+		{FileID: files[0].ID, Action: "copy", DestPath: "/dest/A/01.mp3"},
+		{FileID: files[1].ID, Action: "skip"},
+	}); err != nil {
+		t.Fatalf("InsertPlanBatch() error: %v", err)
+	}
+
+	candidates, err := r.GetCoverCandidates()
+	if err != nil {
+		t.Fatalf("GetCoverCandidates() error: %v", err)
+	}
+	var got []string
+	for _, c := range candidates {
+		got = append(got, fmt.Sprintf("%s %d %s", c.DestPath, c.FileID, c.Picture.SHA1))
+	}
+	expectEqual(t, "GetCoverCandidates()", got, []string{
+		fmt.Sprintf("/dest/A/01.mp3 %d front0", files[0].ID),
+		fmt.Sprintf("/dest/A/01.mp3 %d back0", files[0].ID),
+		fmt.Sprintf("/dest/A/01.mp3 %d front1", files[1].ID),
+		fmt.Sprintf("/dest/A/01.mp3 %d back1", files[1].ID),
+	})
+
+	if err := r.ReplaceAlbumCovers([]*store.AlbumCover{{AlbumDir: "/dest/B", FileID: files[2].ID, SHA1: "old"}}); err != nil {
+		t.Fatalf("ReplaceAlbumCovers() error: %v", err)
+	}
+	covers := []*store.AlbumCover{
+		{AlbumDir: "/dest/Z", FileID: files[1].ID, SHA1: "front1", MIMEType: "image/png", Width: 500, Height: 500},
+		{AlbumDir: "/dest/A", FileID: files[0].ID, SHA1: "front0", MIMEType: "image/jpeg", Width: 500, Height: 500},
+	}
+	if err := r.ReplaceAlbumCovers(covers); err != nil {
+		t.Fatalf("ReplaceAlbumCovers() error: %v", err)
+	}
+	stored, _ := r.GetAlbumCovers()
+	if len(stored) != 2 {
+		t.Fatalf("GetAlbumCovers() = %d covers, want 2", len(stored))
+	}
+	expectEqual(t, "GetAlbumCovers()", []store.AlbumCover{*stored[0], *stored[1]}, []store.AlbumCover{*covers[1], *covers[0]})
+}
+
+func testExecutions(t *testing.T, r store.Repository) {
+	if e, err := r.GetExecution(1); e != nil || err != nil {
+		t.Fatalf("GetExecution(unknown) = %v, %v, want nil, nil", e, err)
+	}
+
+	if err := r.InsertOrUpdateExecution(&store.Execution{FileID: 1, Error: "disk full"}); err != nil {
+		t.Fatalf("InsertOrUpdateExecution() error: %v", err)
+	}
+	if err := r.BatchInsertOrUpdateExecution([]*store.Execution{
+		{FileID: 1, BytesWritten: 100, VerifyOK: true},
+		{FileID: 2, BytesWritten: 200, VerifyOK: true},
+	}); err != nil {
+		t.Fatalf("BatchInsertOrUpdateExecution() error: %v", err)
+	}
+
+	e, err := r.GetExecution(1)
+	if err != nil || e == nil {
+		t.Fatalf("GetExecution() = %v, %v", e, err)
+	}
+	if !e.VerifyOK || e.BytesWritten != 100 || e.Error != "" {
+		t.Errorf("GetExecution() = %+v, want the replaced, verified record", e)
+	}
+	all, _ := r.GetAllExecutionsMap()
+	if len(all) != 2 || all[2].BytesWritten != 200 {
+		t.Errorf("GetAllExecutionsMap() = %v, want 2 records", all)
+	}
+}
+
+func testFingerprints(t *testing.T, r store.Repository) {
+	if got, err := r.GetFingerprint(store.FingerprintClusterKeys); got != "" || err != nil {
+		t.Fatalf("GetFingerprint() before any run = %q, %v, want empty", got, err)
+	}
+
+	for _, fp := range []string{"a1", "b2"} {
+		if err := r.SetFingerprint(store.FingerprintClusterKeys, fp); err != nil {
+			t.Fatalf("SetFingerprint(%s) error: %v", fp, err)
+		}
+	}
+	if err := r.SetFingerprint(store.FingerprintPlanLayout, "c3"); err != nil {
+		t.Fatalf("SetFingerprint() error: %v", err)
+	}
+	got, _ := r.GetFingerprint(store.FingerprintClusterKeys)
+	expectEqual(t, "GetFingerprint() after update", got, "b2")
+
+	if err := r.SetFingerprint(store.FingerprintClusterKeys, ""); err != nil {
+		t.Fatalf("SetFingerprint(empty) error: %v", err)
+	}
+	got, _ = r.GetFingerprint(store.FingerprintClusterKeys)
+	expectEqual(t, "GetFingerprint() after clearing", got, "")
+	got, _ = r.GetFingerprint(store.FingerprintPlanLayout)
+	expectEqual(t, "GetFingerprint() of another stage", got, "c3")
+}
@@ -0,0 +1,431 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveSeparator separates an archive's path from the path of an entry inside
+// it in virtual file paths, e.g. /music/album.zip!/CD1/01.flac
+const ArchiveSeparator = "!/"
+
+// ArchiveExtensions are the archive formats whose audio entries can be scanned
+// Plain tar archives are read entry by entry; compressed tar, 7z and rar would
+// have to be decompressed from the start for every entry.
+var ArchiveExtensions = []string{".zip", ".tar"}
+
+// UnsupportedArchiveExtensions are archive formats that are reported but not read
+var UnsupportedArchiveExtensions = []string{".7z", ".rar", ".tgz", ".tar.gz", ".tar.bz2", ".tar.xz"}
+
+// ArchiveEntry is a regular file inside an archive
+type ArchiveEntry struct {
+	Name    string // Slash-separated path inside the archive
+	Size    int64
+	ModTime time.Time
+}
+
+// IsArchive reports whether path is an archive in a supported format
+func IsArchive(path string) bool {
+	return hasExtension(path, ArchiveExtensions)
+}
+
+// IsUnsupportedArchive reports whether path is an archive in a format that can't be scanned
+func IsUnsupportedArchive(path string) bool {
+	return hasExtension(path, UnsupportedArchiveExtensions)
+}
+
+// hasExtension reports whether path ends with one of exts, ignoring case
+func hasExtension(path string, exts []string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range exts {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveEntryPath returns the virtual path of an entry inside an archive
+func ArchiveEntryPath(archivePath, entryName string) string {
+	return archivePath + ArchiveSeparator + entryName
+}
+
+// SplitArchivePath splits a virtual path into the archive path and the entry name
+// ok is false for regular paths.
+func SplitArchivePath(p string) (archivePath, entryName string, ok bool) {
+	offset := 0
+	for {
+		i := strings.Index(p[offset:], ArchiveSeparator)
+		if i < 0 {
+			return "", "", false
+		}
+		i += offset
+		if IsArchive(p[:i]) {
+			return p[:i], p[i+len(ArchiveSeparator):], true
+		}
+		offset = i + len(ArchiveSeparator)
+	}
+}
+
+// IsArchivePath reports whether p is the virtual path of an archive entry
+func IsArchivePath(p string) bool {
+	_, _, ok := SplitArchivePath(p)
+	return ok
+}
+
+// ArchiveFolderPath returns p with the archive treated as a folder named after
+// it, e.g. /music/Artist - Album.zip!/01.flac becomes /music/Artist - Album/01.flac,
+// for parsing artist and album names from folders. Regular paths are returned as is.
+func ArchiveFolderPath(p string) string {
+	archivePath, entryName, ok := SplitArchivePath(p)
+	if !ok {
+		return p
+	}
+	folder := strings.TrimSuffix(archivePath, filepath.Ext(archivePath))
+	return filepath.Join(folder, filepath.FromSlash(entryName))
+}
+
+// ListArchive returns the regular files inside an archive that can be read
+// Encrypted zip entries and unsupported compression methods are left out.
+func ListArchive(archivePath string) ([]ArchiveEntry, error) {
+	if strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		return listTar(archivePath)
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer r.Close()
+
+	var entries []ArchiveEntry
+	for _, f := range r.File {
+		if !readableZipEntry(f) {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{
+			Name:    zipEntryName(f),
+			Size:    int64(f.UncompressedSize64),
+			ModTime: f.Modified,
+		})
+	}
+	return entries, nil
+}
+
+// listTar returns the regular files of a plain tar archive
+func listTar(archivePath string) ([]ArchiveEntry, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	var entries []ArchiveEntry
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			entries = append(entries, ArchiveEntry{
+				Name:    strings.TrimPrefix(path.Clean(hdr.Name), "./"),
+				Size:    hdr.Size,
+				ModTime: hdr.ModTime,
+			})
+		}
+	}
+}
+
+// readableZipEntry reports whether a zip entry is a file archive/zip can decompress
+func readableZipEntry(f *zip.File) bool {
+	if f.FileInfo().IsDir() || f.Flags&0x1 != 0 { // 0x1: encrypted
+		return false
+	}
+	return f.Method == zip.Store || f.Method == zip.Deflate
+}
+
+// zipEntryName returns the slash-separated name of a zip entry
+// (some Windows tools store backslashes)
+func zipEntryName(f *zip.File) string {
+	return strings.ReplaceAll(f.Name, `\`, "/")
+}
+
+// StatArchiveEntry returns the size and modification time of an archive entry
+// The error wraps fs.ErrNotExist if the archive or the entry is gone.
+func StatArchiveEntry(p string) (ArchiveEntry, error) {
+	rc, entry, err := openArchiveEntry(p, false)
+	if err != nil {
+		return ArchiveEntry{}, err
+	}
+	rc.Close()
+	return entry, nil
+}
+
+// OpenArchiveEntry opens an entry of an archive for reading
+// The error wraps fs.ErrNotExist if the archive or the entry is gone.
+func OpenArchiveEntry(p string) (io.ReadCloser, error) {
+	rc, _, err := openArchiveEntry(p, true)
+	return rc, err
+}
+
+// openArchiveEntry finds the entry at virtual path p; with open set, the
+// returned reader reads its content, otherwise it only closes the archive
+func openArchiveEntry(p string, open bool) (io.ReadCloser, ArchiveEntry, error) {
+	archivePath, entryName, ok := SplitArchivePath(p)
+	if !ok {
+		return nil, ArchiveEntry{}, fmt.Errorf("not an archive entry: %s", p)
+	}
+
+	if strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return nil, ArchiveEntry{}, fmt.Errorf("failed to open archive: %w", err)
+		}
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, ArchiveEntry{}, fmt.Errorf("failed to read archive: %w", err)
+			}
+			if hdr.Typeflag == tar.TypeReg && strings.TrimPrefix(path.Clean(hdr.Name), "./") == entryName {
+				entry := ArchiveEntry{Name: entryName, Size: hdr.Size, ModTime: hdr.ModTime}
+				return &entryReader{Reader: tr, closers: []io.Closer{f}}, entry, nil
+			}
+		}
+		f.Close()
+		return nil, ArchiveEntry{}, fmt.Errorf("%s not found in %s: %w", entryName, archivePath, fs.ErrNotExist)
+	}
+
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, ArchiveEntry{}, fmt.Errorf("failed to open archive: %w", err)
+	}
+	for _, f := range r.File {
+		if zipEntryName(f) != entryName || !readableZipEntry(f) {
+			continue
+		}
+		entry := ArchiveEntry{Name: entryName, Size: int64(f.UncompressedSize64), ModTime: f.Modified}
+		if !open {
+			return &entryReader{Reader: strings.NewReader(""), closers: []io.Closer{r}}, entry, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			r.Close()
+			return nil, ArchiveEntry{}, fmt.Errorf("failed to open %s in %s: %w", entryName, archivePath, err)
+		}
+		return &entryReader{Reader: rc, closers: []io.Closer{rc, r}}, entry, nil
+	}
+	r.Close()
+	return nil, ArchiveEntry{}, fmt.Errorf("%s not found in %s: %w", entryName, archivePath, fs.ErrNotExist)
+}
+
+// entryReader reads an archive entry and closes the entry and the archive
+type entryReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *entryReader) Close() error {
+	var errs []error
+	for _, c := range r.closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// OpenSource opens a source file or archive entry for reading
+func OpenSource(p string) (io.ReadCloser, error) {
+	if IsArchivePath(p) {
+		return OpenArchiveEntry(p)
+	}
+	return os.Open(p)
+}
+
+// MaxBufferedEntrySize is the largest compressed archive entry that is
+// decompressed into memory to be parsed
+const MaxBufferedEntrySize = 512 << 20
+
+// SourceReader is a seekable reader over a source file or an archive entry
+type SourceReader struct {
+	*io.SectionReader
+	closer io.Closer
+}
+
+// Close closes the underlying file
+func (r *SourceReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Reader returns a new reader over the whole source, independent of r's position
+func (r *SourceReader) Reader() *io.SectionReader {
+	return io.NewSectionReader(r.SectionReader, 0, r.Size())
+}
+
+// OpenSourceReader opens a source file or archive entry for random access
+// Files, tar entries and stored zip entries are read in place; compressed zip
+// entries are decompressed into memory, up to MaxBufferedEntrySize.
+// The error wraps fs.ErrNotExist if the file, archive or entry is gone.
+func OpenSourceReader(p string) (*SourceReader, error) {
+	archivePath, entryName, ok := SplitArchivePath(p)
+	if !ok {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		return &SourceReader{SectionReader: io.NewSectionReader(f, 0, info.Size()), closer: f}, nil
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	r, err := openEntrySection(f, archivePath, entryName)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openEntrySection finds entryName in the open archive f
+// On success the returned reader takes over f: it closes f, or f is already
+// closed when the entry was decompressed into memory.
+func openEntrySection(f *os.File, archivePath, entryName string) (*SourceReader, error) {
+	if strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read archive: %w", err)
+			}
+			if hdr.Typeflag != tar.TypeReg || strings.TrimPrefix(path.Clean(hdr.Name), "./") != entryName {
+				continue
+			}
+			// tar.Reader reads whole blocks without buffering, so the file
+			// offset is the start of the entry's data
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read archive: %w", err)
+			}
+			return &SourceReader{SectionReader: io.NewSectionReader(f, offset, hdr.Size), closer: f}, nil
+		}
+		return nil, fmt.Errorf("%s not found in %s: %w", entryName, archivePath, fs.ErrNotExist)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	for _, zf := range zr.File {
+		if zipEntryName(zf) != entryName || !readableZipEntry(zf) {
+			continue
+		}
+		size := int64(zf.UncompressedSize64)
+		if zf.Method == zip.Store {
+			offset, err := zf.DataOffset()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s in %s: %w", entryName, archivePath, err)
+			}
+			return &SourceReader{SectionReader: io.NewSectionReader(f, offset, size), closer: f}, nil
+		}
+
+		if size > MaxBufferedEntrySize {
+			return nil, fmt.Errorf("%s in %s is too large to decompress (%d bytes)", entryName, archivePath, size)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in %s: %w", entryName, archivePath, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s in %s: %w", entryName, archivePath, err)
+		}
+		f.Close()
+		return &SourceReader{SectionReader: io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))}, nil
+	}
+	return nil, fmt.Errorf("%s not found in %s: %w", entryName, archivePath, fs.ErrNotExist)
+}
+
+// archiveEntryKey creates the file key of an archive entry from the archive's
+// key, the entry name and the entry's size and modification time
+func archiveEntryKey(p string) (string, error) {
+	archivePath, entryName, _ := SplitArchivePath(p)
+	archiveKey, err := GenerateFileKey(archivePath)
+	if err != nil {
+		return "", err
+	}
+	entry, err := StatArchiveEntry(p)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%s!%s:%d:%d", archiveKey, entryName, entry.Size, entry.ModTime.Unix())
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// archiveEntryContentID computes GenerateContentID's ID for an archive entry,
+// reading the entry through (compressed entries can't seek)
+func archiveEntryContentID(p string) (string, error) {
+	rc, entry, err := openArchiveEntry(p, true)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%d:", entry.Size)
+
+	if entry.Size <= 2*ContentIDChunkSize {
+		if _, err := io.Copy(h, rc); err != nil {
+			return "", fmt.Errorf("failed to hash entry: %w", err)
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+
+	if _, err := io.CopyN(h, rc, ContentIDChunkSize); err != nil {
+		return "", fmt.Errorf("failed to hash entry start: %w", err)
+	}
+	if _, err := io.CopyN(io.Discard, rc, entry.Size-2*ContentIDChunkSize); err != nil {
+		return "", fmt.Errorf("failed to read entry: %w", err)
+	}
+	if _, err := io.CopyN(h, rc, ContentIDChunkSize); err != nil {
+		return "", fmt.Errorf("failed to hash entry end: %w", err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeZip creates a zip archive with the given entries; deflated selects the
+// compression method
+func writeZip(t *testing.T, path string, entries map[string][]byte, deflated bool) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	method := zip.Store
+	if deflated {
+		method = zip.Deflate
+	}
+	w := zip.NewWriter(f)
+	for name, data := range entries {
+		hdr := &zip.FileHeader{Name: name, Method: method, Modified: time.Unix(1700000000, 0)}
+		fw, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTar creates a plain tar archive with the given entries
+func writeTar(t *testing.T, path string, entries map[string][]byte) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	for name, data := range entries {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(1700000000, 0), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path        string
+		wantArchive string
+		wantEntry   string
+		wantOK      bool
+		wantFolder  string
+	}{
+		{"/music/album.zip!/01.flac", "/music/album.zip", "01.flac", true, "/music/album/01.flac"},
+		{"/music/Album.ZIP!/CD1/01.flac", "/music/Album.ZIP", "CD1/01.flac", true, "/music/Album/CD1/01.flac"},
+		{"/music/box.tar!/a.mp3", "/music/box.tar", "a.mp3", true, "/music/box/a.mp3"},
+		{"/music/Wow!/01.flac", "", "", false, "/music/Wow!/01.flac"},
+		{"/music/Wow!/album.zip!/01.flac", "/music/Wow!/album.zip", "01.flac", true, "/music/Wow!/album/01.flac"},
+		{"/music/album.zip", "", "", false, "/music/album.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			archive, entry, ok := SplitArchivePath(tt.path)
+			if archive != tt.wantArchive || entry != tt.wantEntry || ok != tt.wantOK {
+				t.Errorf("SplitArchivePath() = %q, %q, %v; want %q, %q, %v", archive, entry, ok, tt.wantArchive, tt.wantEntry, tt.wantOK)
+			}
+			if got := ArchiveFolderPath(tt.path); got != filepath.FromSlash(tt.wantFolder) {
+				t.Errorf("ArchiveFolderPath() = %q, want %q", got, tt.wantFolder)
+			}
+		})
+	}
+}
+
+func TestArchiveEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	large := make([]byte, 3*ContentIDChunkSize)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	entries := map[string][]byte{
+		"CD1/01.flac": large,
+		"cover.jpg":   []byte("jpeg"),
+		"CD2/":        nil,
+	}
+	plain := filepath.Join(dir, "01.flac")
+	if err := os.WriteFile(plain, large, 0644); err != nil {
+		t.Fatal(err)
+	}
+	wantID, _ := GenerateContentID(plain)
+
+	stored := filepath.Join(dir, "stored.zip")
+	writeZip(t, stored, entries, false)
+	deflated := filepath.Join(dir, "deflated.zip")
+	writeZip(t, deflated, entries, true)
+	tarball := filepath.Join(dir, "album.tar")
+	delete(entries, "CD2/")
+	writeTar(t, tarball, entries)
+
+	for _, archive := range []string{stored, deflated, tarball} {
+		t.Run(filepath.Base(archive), func(t *testing.T) {
+			listed, err := ListArchive(archive)
+			if err != nil {
+				t.Fatalf("ListArchive failed: %v", err)
+			}
+			if len(listed) != 2 {
+				t.Fatalf("expected 2 files, got %+v", listed)
+			}
+
+			p := ArchiveEntryPath(archive, "CD1/01.flac")
+			size, mtime, err := GetFileMetadata(p)
+			if err != nil || size != int64(len(large)) || mtime != 1700000000 {
+				t.Errorf("GetFileMetadata() = %d, %d, %v", size, mtime, err)
+			}
+
+			rc, err := OpenSource(p)
+			if err != nil {
+				t.Fatalf("OpenSource failed: %v", err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if len(data) != len(large) {
+				t.Errorf("read %d bytes, want %d", len(data), len(large))
+			}
+
+			if id, err := GenerateContentID(p); err != nil || id != wantID {
+				t.Errorf("expected the entry to have the content ID of the same file on disk, got %q (%v)", id, err)
+			}
+
+			key1, err := GenerateFileKey(p)
+			if err != nil {
+				t.Fatalf("GenerateFileKey failed: %v", err)
+			}
+			key2, _ := GenerateFileKey(ArchiveEntryPath(archive, "cover.jpg"))
+			if key1 == key2 {
+				t.Error("expected entries to have different file keys")
+			}
+
+			src, err := OpenSourceReader(p)
+			if err != nil {
+				t.Fatalf("OpenSourceReader failed: %v", err)
+			}
+			if src.Size() != int64(len(large)) {
+				t.Errorf("Size() = %d, want %d", src.Size(), len(large))
+			}
+			tail := make([]byte, 10)
+			if _, err := src.ReadAt(tail, src.Size()-10); err != nil || !bytes.Equal(tail, large[len(large)-10:]) {
+				t.Errorf("ReadAt() = %v, %v", tail, err)
+			}
+			if _, err := src.Seek(5, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := io.ReadAll(src.Reader()); err != nil || !bytes.Equal(data, large) {
+				t.Errorf("Reader() read %d bytes (%v), want the whole entry", len(data), err)
+			}
+			if err := src.Close(); err != nil {
+				t.Errorf("Close failed: %v", err)
+			}
+			if _, err := OpenSourceReader(ArchiveEntryPath(archive, "gone.flac")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected a missing entry to wrap fs.ErrNotExist, got %v", err)
+			}
+
+			if _, _, err := GetFileMetadata(ArchiveEntryPath(archive, "gone.flac")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected a missing entry to wrap fs.ErrNotExist, got %v", err)
+			}
+		})
+	}
+}
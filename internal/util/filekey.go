@@ -11,7 +11,12 @@ import (
 // GenerateFileKey creates a stable key for a file based on its filesystem metadata
 // Key is SHA1 of (dev, inode, size, mtime) for fast comparison
 // This allows detecting file moves/renames without reading content
+// Archive entries are keyed by the archive's key and the entry's name, size and mtime
 func GenerateFileKey(path string) (string, error) {
+	if IsArchivePath(path) {
+		return archiveEntryKey(path)
+	}
+
 	info, err := os.Stat(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to stat file: %w", err)
@@ -39,7 +44,7 @@ func GenerateSimpleFileKey(size int64, mtimeUnix int64) string {
 // GenerateContentHash creates a SHA1 hash of file content
 // Used for verification and winner selection
 func GenerateContentHash(path string) (string, error) {
-	f, err := os.Open(path)
+	f, err := OpenSource(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
@@ -53,8 +58,64 @@ func GenerateContentHash(path string) (string, error) {
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
+// ContentIDChunkSize is how much of the start and end of a file GenerateContentID reads
+const ContentIDChunkSize = 64 * 1024
+
+// GenerateContentID creates a fast identity for a file's content
+// ID is SHA1 of the size and the first and last 64 KB, so it survives remounts,
+// copies to another disk and touches that change the file key
+func GenerateContentID(path string) (string, error) {
+	if IsArchivePath(path) {
+		return archiveEntryContentID(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	size := info.Size()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%d:", size)
+
+	// Small files are hashed whole
+	if size <= 2*ContentIDChunkSize {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", fmt.Errorf("failed to hash file: %w", err)
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+
+	if _, err := io.CopyN(h, f, ContentIDChunkSize); err != nil {
+		return "", fmt.Errorf("failed to hash file start: %w", err)
+	}
+	if _, err := f.Seek(-ContentIDChunkSize, io.SeekEnd); err != nil {
+		return "", fmt.Errorf("failed to seek to file end: %w", err)
+	}
+	if _, err := io.CopyN(h, f, ContentIDChunkSize); err != nil {
+		return "", fmt.Errorf("failed to hash file end: %w", err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
 // GetFileMetadata extracts basic filesystem metadata
+// For archive entries, the entry's size and modification time
 func GetFileMetadata(path string) (size int64, mtime int64, err error) {
+	if IsArchivePath(path) {
+		entry, err := StatArchiveEntry(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		return entry.Size, entry.ModTime.Unix(), nil
+	}
+
 	info, err := os.Stat(path)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to stat file: %w", err)
@@ -62,3 +123,13 @@ func GetFileMetadata(path string) (size int64, mtime int64, err error) {
 
 	return info.Size(), info.ModTime().Unix(), nil
 }
+
+// SettingsFingerprint hashes the settings a stage's output depends on, so a
+// later run can tell whether they changed; the order of parts matters
+func SettingsFingerprint(parts ...string) string {
+	h := sha1.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:%s;", len(part), part)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
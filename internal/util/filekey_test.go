@@ -0,0 +1,87 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateContentID(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	id := func(path string) string {
+		contentID, err := GenerateContentID(path)
+		if err != nil {
+			t.Fatalf("GenerateContentID(%s) failed: %v", path, err)
+		}
+		return contentID
+	}
+
+	large := make([]byte, 3*ContentIDChunkSize)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	original := write("original.flac", large)
+
+	// A copy keeps its content ID; a touch changes the file key but not the content ID
+	copied := write("copy.flac", large)
+	if id(copied) != id(original) {
+		t.Error("expected a copy to have the same content ID")
+	}
+	keyBefore, _ := GenerateFileKey(original)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(original, later, later)
+	keyAfter, _ := GenerateFileKey(original)
+	if keyBefore == keyAfter {
+		t.Error("expected a touch to change the file key")
+	}
+	if id(original) != id(copied) {
+		t.Error("expected a touch to keep the content ID")
+	}
+
+	// Changes in the first or last 64 KB change the ID; the middle is not read
+	for _, tc := range []struct {
+		offset  int
+		changes bool
+	}{
+		{0, true},
+		{len(large) - 1, true},
+		{ContentIDChunkSize + 10, false},
+	} {
+		changed := append([]byte(nil), large...)
+		changed[tc.offset]++
+		if got := id(write("changed.flac", changed)) != id(original); got != tc.changes {
+			t.Errorf("change at offset %d: ID changed = %v, expected %v", tc.offset, got, tc.changes)
+		}
+	}
+
+	// Small files are hashed whole
+	if id(write("a.mp3", []byte("abc"))) == id(write("b.mp3", []byte("abd"))) {
+		t.Error("expected small files with different content to have different IDs")
+	}
+}
+
+func TestSettingsFingerprint(t *testing.T) {
+	base := SettingsFingerprint("fuzzy=0.9", "transliterate=false")
+	if got := SettingsFingerprint("fuzzy=0.9", "transliterate=false"); got != base {
+		t.Errorf("SettingsFingerprint() not stable: %s != %s", got, base)
+	}
+	for _, parts := range [][]string{
+		{"fuzzy=0.8", "transliterate=false"},
+		{"transliterate=false", "fuzzy=0.9"},
+		{"fuzzy=0.9transliterate=false"},
+		{"fuzzy=0.9", "transliterate=false", ""},
+	} {
+		if SettingsFingerprint(parts...) == base {
+			t.Errorf("SettingsFingerprint(%q) = fingerprint of different settings", parts)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package util
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // FormatBytes formats bytes into human-readable format (e.g., "1.5 GB")
 func FormatBytes(bytes int64) string {
@@ -15,3 +19,33 @@ func FormatBytes(bytes int64) string {
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
+
+// ParseBytes parses a size such as "512", "100KB" or "1.5 MB" into bytes
+// Units are binary (1 KB = 1024 bytes), as in FormatBytes
+func ParseBytes(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	if str == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		for _, suffix := range []string{unit + "IB", unit + "B", unit} {
+			if strings.HasSuffix(str, suffix) {
+				multiplier = int64(1) << (10 * (i + 1))
+				str = strings.TrimSuffix(str, suffix)
+				break
+			}
+		}
+		if multiplier > 1 {
+			break
+		}
+	}
+	str = strings.TrimSpace(strings.TrimSuffix(str, "B"))
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
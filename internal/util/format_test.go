@@ -0,0 +1,33 @@
+package util
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"512", 512, false},
+		{"512B", 512, false},
+		{"100KB", 100 * 1024, false},
+		{"100k", 100 * 1024, false},
+		{"1.5 MB", 1536 * 1024, false},
+		{"2MiB", 2 * 1024 * 1024, false},
+		{"1G", 1 << 30, false},
+		{"lots", 0, true},
+		{"-5KB", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBytes(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseBytes(%q) = %d, expected %d", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package util
+
+// VideoExtensions are the containers scanned for music videos. Files with a
+// video stream are clustered and scored by their audio stream and placed in
+// their own tree; audio-only files in these containers are plain music.
+var VideoExtensions = []string{".mp4", ".mkv"}
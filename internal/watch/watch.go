@@ -0,0 +1,278 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/franz/music-janitor/internal/util"
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultSettle is how long a file must stay unchanged before it is handed over
+const DefaultSettle = 30 * time.Second
+
+// Batch holds the settled paths of one pass, by source root
+// A root listed as its own path must be rescanned completely.
+type Batch map[string][]string
+
+// Len returns the number of paths in the batch
+func (b Batch) Len() int {
+	n := 0
+	for _, paths := range b {
+		n += len(paths)
+	}
+	return n
+}
+
+// Handler ingests a batch of settled paths
+type Handler func(ctx context.Context, batch Batch) error
+
+// Watcher follows source roots and hands over files once they stop changing
+type Watcher struct {
+	roots      []string
+	settle     time.Duration
+	interested func(path string) bool
+	fsw        *fsnotify.Watcher
+
+	pending map[string]*pendingFile // Changed files waiting to settle
+	ready   Batch                   // Settled paths not yet handed over
+}
+
+// Config holds watcher configuration
+type Config struct {
+	Roots []string
+
+	// Settle is how long a file's size and modification time must stay the
+	// same before it is handed over (<=0: DefaultSettle)
+	Settle time.Duration
+
+	// Interested selects the files worth handing over, e.g. audio files and
+	// archives (nil: every file). New folders are always followed.
+	Interested func(path string) bool
+}
+
+// pendingFile is a changed file waiting to settle
+type pendingFile struct {
+	root    string
+	size    int64
+	modTime time.Time
+	changed time.Time // Last event or stat change
+}
+
+// New creates a watcher and starts watching every folder below the roots
+func New(cfg *Config) (*Watcher, error) {
+	settle := cfg.Settle
+	if settle <= 0 {
+		settle = DefaultSettle
+	}
+	interested := cfg.Interested
+	if interested == nil {
+		interested = func(string) bool { return true }
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		settle:     settle,
+		interested: interested,
+		fsw:        fsw,
+		pending:    make(map[string]*pendingFile),
+		ready:      make(Batch),
+	}
+	for _, root := range cfg.Roots {
+		root = filepath.Clean(root)
+		w.roots = append(w.roots, root)
+		if err := w.addTree(root, false); err != nil {
+			fsw.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+// Run hands settled files to handle until ctx is cancelled. Events keep being
+// collected while a batch is handled; the next batch starts when it returns.
+// Errors from handle are logged and don't stop the watcher.
+func (w *Watcher) Run(ctx context.Context, handle Handler) error {
+	interval := w.settle / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var done chan error // Non-nil while a batch is handled
+	for {
+		select {
+		case <-ctx.Done():
+			if done != nil {
+				<-done
+			}
+			return nil
+
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(event)
+
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return nil
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				// Events were lost: rescan the roots
+				util.WarnLog("Too many file changes to follow, rescanning sources")
+				for _, root := range w.roots {
+					w.ready[root] = []string{root}
+				}
+				continue
+			}
+			util.WarnLog("Watch error: %v", err)
+
+		case err := <-done:
+			done = nil
+			if err != nil && ctx.Err() == nil {
+				util.ErrorLog("Failed to ingest changes: %v", err)
+			}
+
+		case now := <-ticker.C:
+			w.settlePending(now)
+		}
+
+		if done == nil && len(w.ready) > 0 {
+			batch := w.ready
+			w.ready = make(Batch)
+			done = make(chan error, 1)
+			go func() { done <- handle(ctx, batch) }()
+		}
+	}
+}
+
+// handleEvent records a file change, following new folders
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+	root := w.rootOf(path)
+	if root == "" {
+		return
+	}
+
+	switch {
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+		info, err := os.Lstat(path)
+		if err != nil {
+			delete(w.pending, path)
+			return
+		}
+		if info.IsDir() {
+			// A folder moved or copied in: follow it and everything already in it
+			if err := w.addTree(path, true); err != nil {
+				util.WarnLog("%v", err)
+			}
+			return
+		}
+		w.touch(root, path, info)
+
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		// The new name of a renamed file arrives as a Create
+		delete(w.pending, path)
+		for p := range w.pending {
+			if strings.HasPrefix(p, path+string(filepath.Separator)) {
+				delete(w.pending, p)
+			}
+		}
+		w.fsw.Remove(path) // Stale watch of a folder moved away; fails for files
+	}
+}
+
+// touch marks a file as changed now
+func (w *Watcher) touch(root, path string, info fs.FileInfo) {
+	if !info.Mode().IsRegular() || !w.interested(path) {
+		return
+	}
+	w.pending[path] = &pendingFile{
+		root:    root,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+		changed: time.Now(),
+	}
+}
+
+// settlePending moves files unchanged for the settle time to the ready batch
+func (w *Watcher) settlePending(now time.Time) {
+	for path, p := range w.pending {
+		info, err := os.Lstat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			// Still being written without events (e.g. on some network mounts)
+			p.size, p.modTime, p.changed = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(p.changed) >= w.settle {
+			delete(w.pending, path)
+			w.ready[p.root] = append(w.ready[p.root], path)
+		}
+	}
+	for _, paths := range w.ready {
+		sort.Strings(paths)
+	}
+}
+
+// addTree watches dir and every folder below it; with markFiles set, the files
+// already inside are recorded as changed (they arrived with the folder)
+func (w *Watcher) addTree(dir string, markFiles bool) error {
+	root := w.rootOf(dir)
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return fmt.Errorf("failed to watch %s: %w", dir, err)
+			}
+			util.WarnLog("Cannot watch %s: %v", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if err := w.fsw.Add(path); err != nil {
+				if path == dir {
+					return fmt.Errorf("failed to watch %s: %w", dir, err)
+				}
+				util.WarnLog("Cannot watch %s: %v", path, err)
+			}
+			return nil
+		}
+		if markFiles {
+			if info, err := d.Info(); err == nil {
+				w.touch(root, path, info)
+			}
+		}
+		return nil
+	})
+}
+
+// rootOf returns the watched root path is in, or ""
+func (w *Watcher) rootOf(path string) string {
+	best := ""
+	for _, root := range w.roots {
+		if (path == root || strings.HasPrefix(path, root+string(filepath.Separator))) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
@@ -0,0 +1,122 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startWatcher runs a watcher over root and returns the settled paths it hands over
+func startWatcher(t *testing.T, root string, settle time.Duration) <-chan string {
+	t.Helper()
+
+	w, err := New(&Config{
+		Roots:      []string{root},
+		Settle:     settle,
+		Interested: func(path string) bool { return strings.HasSuffix(path, ".flac") },
+	})
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	settled := make(chan string, 100)
+	go w.Run(ctx, func(ctx context.Context, batch Batch) error {
+		for r, paths := range batch {
+			if r != filepath.Clean(root) {
+				t.Errorf("expected paths of root %s, got %s", root, r)
+			}
+			for _, p := range paths {
+				settled <- p
+			}
+		}
+		return nil
+	})
+	return settled
+}
+
+func TestWatcherHandsOverSettledFiles(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "Existing"), 0755)
+	settled := startWatcher(t, root, 200*time.Millisecond)
+
+	// A new file, a file in a folder that already existed, a file that isn't
+	// interesting and a folder moved in with its files
+	staging := t.TempDir()
+	os.MkdirAll(filepath.Join(staging, "Album", "CD1"), 0755)
+	os.WriteFile(filepath.Join(staging, "Album", "CD1", "02.flac"), []byte("moved in"), 0644)
+
+	os.WriteFile(filepath.Join(root, "01.flac"), []byte("new"), 0644)
+	os.WriteFile(filepath.Join(root, "Existing", "03.flac"), []byte("new"), 0644)
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("ignored"), 0644)
+	if err := os.Rename(filepath.Join(staging, "Album"), filepath.Join(root, "Album")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		filepath.Join(root, "01.flac"):                 true,
+		filepath.Join(root, "Existing", "03.flac"):     true,
+		filepath.Join(root, "Album", "CD1", "02.flac"): true,
+	}
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case p := <-settled:
+			if !want[p] {
+				t.Errorf("unexpected path handed over: %s", p)
+			}
+			delete(want, p)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	// Files in a folder created after the move are followed too
+	later := filepath.Join(root, "Album", "CD2", "01.flac")
+	os.MkdirAll(filepath.Dir(later), 0755)
+	os.WriteFile(later, []byte("later"), 0644)
+	select {
+	case p := <-settled:
+		if p != later {
+			t.Errorf("expected %s, got %s", later, p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", later)
+	}
+}
+
+func TestWatcherWaitsForWritesToStop(t *testing.T) {
+	root := t.TempDir()
+	settle := 300 * time.Millisecond
+	settled := startWatcher(t, root, settle)
+
+	path := filepath.Join(root, "download.flac")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		f.Write([]byte("chunk"))
+		time.Sleep(100 * time.Millisecond)
+	}
+	lastWrite := time.Now()
+	f.Close()
+
+	select {
+	case p := <-settled:
+		if p != path {
+			t.Errorf("expected %s, got %s", path, p)
+		}
+		if waited := time.Since(lastWrite); waited < settle-50*time.Millisecond {
+			t.Errorf("file handed over %v after the last write, expected at least %v", waited, settle)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the file")
+	}
+}